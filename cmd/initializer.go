@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"log"
 	"naimuBack/internal/ai"
+	"naimuBack/internal/commission"
 	"naimuBack/internal/courier"
 	"naimuBack/internal/handlers"
+	"naimuBack/internal/liveops"
 	"naimuBack/internal/models"
 	"naimuBack/internal/repositories"
 	services "naimuBack/internal/services"
@@ -155,8 +157,11 @@ type application struct {
 	taxiDeps    *taxi.TaxiDeps
 	courierMux  http.Handler
 	courierDeps *courier.Deps
+	liveOps     *liveops.Hub
 
 	fcmHandler *handlers.FCMHandler
+
+	commissionHandler *commission.Handler
 }
 
 func initializeApp(db *sql.DB, errorLog, infoLog *log.Logger) *application {
@@ -176,6 +181,9 @@ func initializeApp(db *sql.DB, errorLog, infoLog *log.Logger) *application {
 	// FCM Handler
 	fcmHandler := handlers.NewFCMHandler(fcmClient, db)
 
+	// Commission rules (taxi + courier)
+	commissionHandler := &commission.Handler{Repo: commission.NewRepo(db)}
+
 	// Repositories\
 	invoiceRepo := repositories.NewInvoiceRepo(db)
 	iapRepo := repositories.NewIAPRepository(db)
@@ -652,6 +660,8 @@ func initializeApp(db *sql.DB, errorLog, infoLog *log.Logger) *application {
 		invoiceRepo: invoiceRepo,
 
 		fcmHandler: fcmHandler,
+
+		commissionHandler: commissionHandler,
 	}
 }
 
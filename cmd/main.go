@@ -10,7 +10,10 @@ import (
 	"log"
 	"naimuBack/internal/config"
 	"naimuBack/internal/courier"
+	"naimuBack/internal/liveops"
+	"naimuBack/internal/services"
 	"naimuBack/internal/taxi"
+	taxigeo "naimuBack/internal/taxi/geo"
 	"net/http"
 	"os"
 	"time"
@@ -21,6 +24,13 @@ type taxiLogger struct{ info, err *log.Logger }
 func (l taxiLogger) Infof(f string, a ...interface{})  { l.info.Printf(f, a...) }
 func (l taxiLogger) Errorf(f string, a ...interface{}) { l.err.Printf(f, a...) }
 
+// courierSMS отправляет PIN получателям курьерских заказов через Mobizon.
+type courierSMS struct{ apiKey string }
+
+func (s courierSMS) Send(_ context.Context, phone, message string) error {
+	return services.SendSMS(s.apiKey, phone, message)
+}
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
@@ -78,6 +88,22 @@ func main() {
 		errorLog.Fatal(err)
 	}
 
+	// === Карта оперативного мониторинга для админов (такси + курьеры)
+	liveOpsCfg, err := liveops.LoadConfig()
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	liveOpsHub := liveops.NewHub(liveOpsCfg, liveops.NewSOSRepo(db), taxiLogger{infoLog, errorLog})
+	app.liveOps = liveOpsHub
+
+	// === Возвраты по картам идут через общий AirbaPay-сервис
+	var refunder interface {
+		Return(ctx context.Context, extID string, amount *float64, idempotencyKey string) error
+	}
+	if app.airbapayHandler != nil && app.airbapayHandler.Service != nil {
+		refunder = app.airbapayHandler.Service
+	}
+
 	// === Собираем зависимости Taxi
 	deps := &taxi.TaxiDeps{
 		DB:         db,
@@ -85,6 +111,8 @@ func main() {
 		Logger:     taxiLogger{infoLog, errorLog},
 		Config:     taxiCfg,
 		HTTPClient: &http.Client{Timeout: 5 * time.Second},
+		Refunder:   refunder,
+		LiveOps:    liveOpsHub,
 	}
 
 	// === Заводим stdlib mux для такси и регистрируем его маршруты
@@ -109,6 +137,13 @@ func main() {
 		Logger:     taxiLogger{infoLog, errorLog},
 		Config:     courierCfg,
 		HTTPClient: &http.Client{Timeout: 5 * time.Second},
+		Refunder:   refunder,
+		// ETA курьера считаем через тот же 2GIS, что и такси
+		Router:  taxigeo.NewDGISClient(&http.Client{Timeout: 5 * time.Second}, taxiCfg.DGISAPIKey, taxiCfg.DGISRegionID),
+		LiveOps: liveOpsHub,
+	}
+	if key := os.Getenv("MOBIZON_API_KEY"); key != "" {
+		courierDeps.SMS = courierSMS{apiKey: key}
 	}
 	if err := courier.RegisterCourierRoutes(courierMux, courierDeps); err != nil {
 		errorLog.Fatal(err)
@@ -128,6 +163,7 @@ func main() {
 	if err := courier.StartCourierWorkers(ctx, courierDeps); err != nil {
 		errorLog.Fatal(err)
 	}
+	go liveOpsHub.Run(ctx)
 
 	startTopCleaner(ctx, app.topService, infoLog, errorLog)
 	startSubscriptionCleaner(ctx, app.subscriptionRepo, infoLog, errorLog)
@@ -155,7 +191,8 @@ func main() {
 		AllowOriginRequestFunc: func(r *http.Request, origin string) bool {
 			if r.URL.Path == "/ws" || r.URL.Path == "/ws/location" ||
 				r.URL.Path == "/ws/driver" || r.URL.Path == "/ws/passenger" ||
-				r.URL.Path == "/ws/courier" || r.URL.Path == "/ws/sender" {
+				r.URL.Path == "/ws/courier" || r.URL.Path == "/ws/sender" ||
+				r.URL.Path == "/ws/admin/live" {
 				return true
 			}
 			_, ok := allowedOrigins[origin]
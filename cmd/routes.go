@@ -338,10 +338,27 @@ func (app *application) routes() http.Handler {
 	// === Courier API & WS ===
 	mux.Get("/api/v1/admin/courier/orders", adminAuthMiddleware.Then(app.courierMux))
 	mux.Get("/api/v1/admin/courier/orders/stats", adminAuthMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/admin/courier/orders/:id/refunds", adminAuthMiddleware.Then(app.courierMux))
+	mux.Post("/api/v1/admin/courier/orders/:id/refunds", adminAuthMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/admin/courier/orders/:id/proofs", adminAuthMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/admin/courier/cod/handins", adminAuthMiddleware.Then(app.courierMux))
+	mux.Post("/api/v1/admin/courier/cod/handins/:id/confirm", adminAuthMiddleware.Then(app.courierMux))
+	mux.Post("/api/v1/admin/courier/cod/handins/:id/reject", adminAuthMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/admin/courier/returns", adminAuthMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/admin/courier/merchants", adminAuthMiddleware.Then(app.courierMux))
+	mux.Post("/api/v1/admin/courier/merchants/:id/block", adminAuthMiddleware.Then(app.courierMux))
+	mux.Post("/api/v1/admin/courier/merchants/:id/unblock", adminAuthMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/admin/courier/couriers/:courier_id/cod", adminAuthMiddleware.Then(app.courierMux))
+	mux.Post("/api/v1/admin/courier/couriers/:courier_id/cod_deduction", adminAuthMiddleware.Then(app.courierMux))
 	mux.Get("/api/v1/admin/courier/couriers", adminAuthMiddleware.Then(app.courierMux))
 	mux.Get("/api/v1/admin/courier/couriers/stats", adminAuthMiddleware.Then(app.courierMux))
 	mux.Post("/api/v1/admin/courier/couriers/:courier_id/ban", adminAuthMiddleware.Then(app.courierMux))
 	mux.Post("/api/v1/admin/courier/couriers/:courier_id/approval", adminAuthMiddleware.Then(app.courierMux))
+	mux.Post("/api/v1/admin/courier/couriers/:courier_id/verification", adminAuthMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/admin/courier/claims", adminAuthMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/admin/courier/claims/:id", adminAuthMiddleware.Then(app.courierMux))
+	mux.Post("/api/v1/admin/courier/claims/:id/resolve", adminAuthMiddleware.Then(app.courierMux))
+	mux.Post("/api/v1/admin/courier/claims/:id/paid", adminAuthMiddleware.Then(app.courierMux))
 
 	mux.Post("/api/v1/courier/route/quote", standardMiddleware.Then(app.courierMux))
 	mux.Post("/api/v1/courier/orders", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
@@ -349,6 +366,11 @@ func (app *application) routes() http.Handler {
 	mux.Get("/api/v1/courier/my/orders", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
 	mux.Get("/api/v1/courier/orders/active", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
 	mux.Get("/api/v1/courier/my/orders/active", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Get("/api/v1/courier/my/route", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Get("/api/v1/courier/my/reservations", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Get("/api/v1/courier/scheduled", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/my/orders/:id/reserve", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Del("/api/v1/courier/my/orders/:id/reserve", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
 	mux.Get("/api/v1/courier/orders/:id", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
 	mux.Get("/api/v1/courier/my/orders/:id", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
 	mux.Post("/api/v1/courier/orders/:id/cancel", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
@@ -366,10 +388,71 @@ func (app *application) routes() http.Handler {
 	mux.Post("/api/v1/courier/orders/:id/pause", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
 	mux.Post("/api/v1/courier/orders/:id/resume", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
 	mux.Post("/api/v1/courier/orders/:id/no-show", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/orders/:id/points/:point_id/deliver", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/orders/:id/delivery/attempt", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/orders/:id/delivery/wait", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/orders/:id/delivery/resume", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/orders/:id/return", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/orders/:id/return/arrive", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/orders/:id/return/confirm", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Get("/api/v1/courier/merchant", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/merchant", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Put("/api/v1/courier/merchant", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/merchant/webhook-secret", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Get("/api/v1/courier/merchant/keys", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/merchant/keys", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Del("/api/v1/courier/merchant/keys/:id", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/merchant/keys/:id/rotate", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+
+	// Merchant API: авторизация по API-ключу магазина внутри courierMux.
+	mux.Post("/api/v1/merchant/courier/quote", standardMiddleware.Then(app.courierMux))
+	mux.Post("/api/v1/merchant/courier/orders", standardMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/merchant/courier/orders", standardMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/merchant/courier/orders/:id", standardMiddleware.Then(app.courierMux))
+	mux.Post("/api/v1/merchant/courier/orders/:id/cancel", standardMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/merchant/courier/invoices/:period", standardMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/merchant/courier/webhooks", standardMiddleware.Then(app.courierMux))
+	mux.Get("/api/v1/courier/orders/:id/proofs", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Get("/api/v1/courier/orders/:id/claim", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/orders/:id/claim", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+
+	// Адресная книга, шаблоны заказов и регулярные доставки отправителя.
+	mux.Get("/api/v1/courier/addresses", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/addresses", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Put("/api/v1/courier/addresses/:id", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Del("/api/v1/courier/addresses/:id", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Get("/api/v1/courier/templates", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/templates", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Get("/api/v1/courier/templates/:id", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Put("/api/v1/courier/templates/:id", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Del("/api/v1/courier/templates/:id", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/templates/:id/order", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Get("/api/v1/courier/recurring", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/recurring", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Get("/api/v1/courier/recurring/:id", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Put("/api/v1/courier/recurring/:id", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Del("/api/v1/courier/recurring/:id", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/recurring/:id/pause", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/recurring/:id/resume", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/recurring/:id/skip", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+
+	mux.Get("/api/v1/courier/orders/:id/chat", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/orders/:id/chat", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Get("/api/v1/courier/my/orders/:id/chat", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/my/orders/:id/chat", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/orders/:id/sos", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Post("/api/v1/courier/my/orders/:id/sos", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/my/orders/:id/cod", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Get("/api/v1/courier/my/cod", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/my/cod/handins", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Get("/api/v1/courier/cod/report", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
+	mux.Get("/api/v1/courier/track/:token", standardMiddleware.Then(app.courierMux))
 	mux.Get("/api/v1/courier/my/orders", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
 	mux.Get("/api/v1/courier/my/orders/active", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
 	mux.Post("/api/v1/courier/balance/deposit", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
 	mux.Post("/api/v1/courier/balance/withdraw", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Get("/api/v1/courier/commission/passes", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
+	mux.Post("/api/v1/courier/commission/passes", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
 
 	mux.Post("/api/v1/courier/offers/price", workerAuth.Then(app.withHeaderFromCtxCourier(app.courierMux, "X-Courier-ID")))
 	mux.Post("/api/v1/courier/offers/accept", authMiddleware.Then(app.withHeaderFromCtxSender(app.courierMux, "X-Sender-ID")))
@@ -383,18 +466,40 @@ func (app *application) routes() http.Handler {
 
 	mux.Get("/ws/courier", wsMiddleware.Append(app.wsWithAuthFromQuery).Append(app.JWTMiddlewareWithRole("worker")).Then(app.courierMux))
 	mux.Get("/ws/sender", wsMiddleware.Append(app.wsWithAuthFromQuery).Then(app.courierMux))
+	mux.Get("/ws/courier/track", wsMiddleware.Then(app.courierMux))
 
 	// === Taxi API & WS ===
 	mux.Get("/api/v1/admin/taxi/orders/stats", adminAuthMiddleware.Then(app.taxiMux))
 	mux.Get("/api/v1/admin/taxi/drivers/stats", adminAuthMiddleware.Then(app.taxiMux))
 	mux.Get("/api/v1/admin/taxi/intercity/orders/stats", adminAuthMiddleware.Then(app.taxiMux))
 	mux.Get("/api/v1/admin/taxi/drivers", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/admin/taxi/drivers/online-hours", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/admin/taxi/lost-items", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Post("/api/v1/admin/taxi/lost-items/:id/close", adminAuthMiddleware.Then(app.taxiMux))
 	mux.Post("/api/v1/admin/taxi/drivers/:driver_id/ban", adminAuthMiddleware.Then(app.taxiMux))
 	mux.Post("/api/v1/admin/taxi/drivers/:driver_id/approval", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/admin/taxi/drivers/:driver_id/vehicles", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Post("/api/v1/admin/taxi/drivers/:driver_id/vehicles/:vehicle_id/approval", adminAuthMiddleware.Then(app.taxiMux))
 	mux.Get("/api/v1/admin/taxi/orders", adminAuthMiddleware.Then(app.taxiMux))
 	mux.Get("/api/v1/admin/taxi/intercity/orders", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/admin/taxi/heatmap", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/admin/taxi/orders/:id/refunds", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Post("/api/v1/admin/taxi/orders/:id/refunds", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/admin/taxi/blocks", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/admin/taxi/quests", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Post("/api/v1/admin/taxi/quests", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/admin/taxi/quests/:id", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Put("/api/v1/admin/taxi/quests/:id", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Del("/api/v1/admin/taxi/quests/:id", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/admin/taxi/quests/:id/evaluations", adminAuthMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/admin/commission/rules", adminAuthMiddleware.ThenFunc(app.commissionHandler.ListRules))
+	mux.Post("/api/v1/admin/commission/rules", adminAuthMiddleware.ThenFunc(app.commissionHandler.CreateRule))
+	mux.Put("/api/v1/admin/commission/rules/:id", adminAuthMiddleware.ThenFunc(app.commissionHandler.UpdateRule))
+	mux.Del("/api/v1/admin/commission/rules/:id", adminAuthMiddleware.ThenFunc(app.commissionHandler.DeleteRule))
 
 	mux.Post("/api/v1/route/quote", standardMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/geo/suggest", standardMiddleware.Then(app.taxiMux))
+	mux.Get("/api/v1/geo/reverse", standardMiddleware.Then(app.taxiMux))
 	mux.Get("/api/v1/orders", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
 	mux.Get("/api/v1/orders/active", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
 	mux.Post("/api/v1/orders", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
@@ -404,6 +509,13 @@ func (app *application) routes() http.Handler {
 	mux.Post("/api/v1/orders/:id/status", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
 	mux.Post("/api/v1/orders/:id/review", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
 	mux.Post("/api/v1/orders/:id/review", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Get("/api/v1/orders/:id/chat", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Post("/api/v1/orders/:id/chat", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Post("/api/v1/orders/:id/sos", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Post("/api/v1/orders/:id/lost-item", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Get("/api/v1/passenger/lost-items", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Post("/api/v1/passenger/lost-items/:id/return", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Post("/api/v1/passenger/lost-items/:id/close", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
 	// Taxi: driver profile extras.
 	mux.Post("/api/v1/drivers", authMiddleware.Then(app.taxiMux))           // Возвращает {"driver": {...}, "completed_trips": int, "balance": int}
 	mux.Get("/api/v1/driver/:id/profile", authMiddleware.Then(app.taxiMux)) // Возвращает {"driver": {...}, "completed_trips": int, "balance": int}
@@ -412,8 +524,34 @@ func (app *application) routes() http.Handler {
 
 	mux.Get("/api/v1/driver/orders", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
 	mux.Get("/api/v1/driver/orders/active", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Get("/api/v1/driver/orders/:id/chat", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Post("/api/v1/driver/orders/:id/chat", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Post("/api/v1/driver/orders/:id/sos", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Get("/api/v1/driver/lost-items", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Post("/api/v1/driver/lost-items/:id/reply", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
 	mux.Post("/api/v1/driver/balance/deposit", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
 	mux.Post("/api/v1/driver/balance/withdraw", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Get("/api/v1/driver/destination", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Post("/api/v1/driver/destination", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Del("/api/v1/driver/destination", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Get("/api/v1/driver/blocks", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Post("/api/v1/driver/blocks", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Del("/api/v1/driver/blocks", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Get("/api/v1/driver/commission/passes", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Post("/api/v1/driver/commission/passes", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Get("/api/v1/driver/quests", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Get("/api/v1/driver/fatigue", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Get("/api/v1/driver/vehicles", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Post("/api/v1/driver/vehicles", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Del("/api/v1/driver/vehicles/:id", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Post("/api/v1/driver/vehicles/:id/activate", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
+	mux.Get("/api/v1/passenger/blocks", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Post("/api/v1/passenger/blocks", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Del("/api/v1/passenger/blocks", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Get("/api/v1/passenger/places", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Post("/api/v1/passenger/places", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Put("/api/v1/passenger/places/:id", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
+	mux.Del("/api/v1/passenger/places/:id", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
 	mux.Post("/api/v1/offers/accept", authMiddleware.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
 	mux.Post("/api/v1/offers/propose_price", workerAuth.Then(app.withHeaderFromCtx(app.taxiMux, "X-Driver-ID")))
 	mux.Post("/api/v1/offers/respond", authMiddleware.Then(app.withHeaderFromCtxPassenger(app.taxiMux, "X-Passenger-ID")))
@@ -436,6 +574,9 @@ func (app *application) routes() http.Handler {
 	mux.Get("/ws/passenger", wsMiddleware.Append(app.wsWithAuthFromQuery).Then(app.wsWithQueryUserID(app.taxiMux, "passenger_id")))
 	mux.Get("/ws/driver", wsMiddleware.Append(app.wsWithAuthFromQuery).Append(app.JWTMiddlewareWithRole("worker")).Then(app.wsWithQueryUserID(app.taxiMux, "driver_id")))
 
+	// === Карта оперативного мониторинга (только админы)
+	mux.Get("/ws/admin/live", wsMiddleware.Append(app.wsWithAuthFromQuery).Append(app.JWTMiddlewareWithRole("admin")).Then(app.withHeaderFromCtxPassenger(http.HandlerFunc(app.liveOps.ServeWS), "X-Admin-ID")))
+
 	mux.Post("/location", authMiddleware.ThenFunc(app.locationHandler.UpdateLocation))
 	mux.Post("/location/offline", authMiddleware.ThenFunc(app.locationHandler.GoOffline))
 	mux.Get("/location/:user_id", authMiddleware.ThenFunc(app.locationHandler.GetLocation))
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS courier_order_refunds;
+
+ALTER TABLE courier_orders
+    DROP COLUMN refunded_amount;
@@ -0,0 +1,19 @@
+ALTER TABLE courier_orders
+    ADD COLUMN refunded_amount INT NOT NULL DEFAULT 0 AFTER client_price;
+
+CREATE TABLE courier_order_refunds (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    order_id BIGINT UNSIGNED NOT NULL,
+    amount INT NOT NULL,
+    reason VARCHAR(255) NULL,
+    idempotency_key VARCHAR(64) NOT NULL,
+    provider_ext_id VARCHAR(64) NOT NULL,
+    charge_courier TINYINT(1) NOT NULL DEFAULT 1,
+    courier_adjustment INT NOT NULL DEFAULT 0,
+    status ENUM('pending','succeeded','failed') NOT NULL DEFAULT 'pending',
+    error VARCHAR(255) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT fk_courier_refunds_order FOREIGN KEY (order_id) REFERENCES courier_orders(id),
+    UNIQUE KEY uq_courier_refunds_key (order_id, idempotency_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,7 @@
+ALTER TABLE courier_orders
+    DROP COLUMN commission_pass_id,
+    DROP COLUMN commission_rule_id,
+    DROP COLUMN commission;
+
+DROP TABLE IF EXISTS commission_passes;
+DROP TABLE IF EXISTS commission_rules;
@@ -0,0 +1,41 @@
+CREATE TABLE commission_rules (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(128) NOT NULL,
+    service VARCHAR(16) NULL,
+    city VARCHAR(64) NULL,
+    tariff_class VARCHAR(32) NULL,
+    kind ENUM('percent','shift_pass') NOT NULL DEFAULT 'percent',
+    percent DECIMAL(5,2) NOT NULL DEFAULT 0,
+    cap_amount INT NOT NULL DEFAULT 0,
+    pass_fee INT NOT NULL DEFAULT 0,
+    pass_hours INT NOT NULL DEFAULT 0,
+    newcomer_days INT NOT NULL DEFAULT 0,
+    start_minute SMALLINT NULL,
+    end_minute SMALLINT NULL,
+    weekdays TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    valid_from DATETIME NULL,
+    valid_to DATETIME NULL,
+    priority INT NOT NULL DEFAULT 0,
+    is_active TINYINT(1) NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_commission_rules_lookup (is_active, kind, service)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE commission_passes (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    rule_id BIGINT UNSIGNED NOT NULL,
+    service VARCHAR(16) NOT NULL,
+    actor_id BIGINT UNSIGNED NOT NULL,
+    fee INT NOT NULL,
+    starts_at DATETIME NOT NULL,
+    ends_at DATETIME NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_commission_passes_rule FOREIGN KEY (rule_id) REFERENCES commission_rules(id),
+    KEY idx_commission_passes_actor (service, actor_id, ends_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE courier_orders
+    ADD COLUMN commission INT NULL AFTER refunded_amount,
+    ADD COLUMN commission_rule_id BIGINT UNSIGNED NULL AFTER commission,
+    ADD COLUMN commission_pass_id BIGINT UNSIGNED NULL AFTER commission_rule_id;
@@ -0,0 +1,11 @@
+CREATE TABLE order_chat_messages (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    service VARCHAR(16) NOT NULL,
+    order_id BIGINT UNSIGNED NOT NULL,
+    sender_role VARCHAR(16) NOT NULL,
+    sender_id BIGINT UNSIGNED NOT NULL,
+    body VARCHAR(1000) NOT NULL,
+    preset VARCHAR(32) NULL,
+    created_at DATETIME NOT NULL,
+    KEY idx_order_chat_messages_order (service, order_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE courier_order_points DROP COLUMN leg_polyline;
@@ -0,0 +1 @@
+ALTER TABLE courier_order_points ADD COLUMN leg_polyline MEDIUMTEXT NULL AFTER comment;
@@ -0,0 +1,16 @@
+CREATE TABLE sos_alerts (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    service VARCHAR(16) NOT NULL,
+    order_id BIGINT UNSIGNED NOT NULL,
+    reporter_role VARCHAR(16) NOT NULL,
+    reporter_id BIGINT UNSIGNED NOT NULL,
+    city VARCHAR(64) NOT NULL,
+    lon DOUBLE NULL,
+    lat DOUBLE NULL,
+    message VARCHAR(500) NULL,
+    created_at DATETIME NOT NULL,
+    resolved_at DATETIME NULL,
+    resolved_by BIGINT UNSIGNED NULL,
+    KEY idx_sos_alerts_open (resolved_at, created_at),
+    KEY idx_sos_alerts_order (service, order_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
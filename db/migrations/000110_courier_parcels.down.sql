@@ -0,0 +1,8 @@
+ALTER TABLE couriers DROP COLUMN vehicle_type;
+
+ALTER TABLE courier_orders
+    DROP COLUMN parcel_height_cm,
+    DROP COLUMN parcel_width_cm,
+    DROP COLUMN parcel_length_cm,
+    DROP COLUMN parcel_weight_kg,
+    DROP COLUMN parcel_class;
@@ -0,0 +1,11 @@
+ALTER TABLE courier_orders
+    ADD COLUMN parcel_class ENUM('documents','small','medium','large','oversize') NOT NULL DEFAULT 'small' AFTER comment,
+    ADD COLUMN parcel_weight_kg DECIMAL(6,2) NOT NULL DEFAULT 0 AFTER parcel_class,
+    ADD COLUMN parcel_length_cm INT NOT NULL DEFAULT 0 AFTER parcel_weight_kg,
+    ADD COLUMN parcel_width_cm INT NOT NULL DEFAULT 0 AFTER parcel_length_cm,
+    ADD COLUMN parcel_height_cm INT NOT NULL DEFAULT 0 AFTER parcel_width_cm;
+
+-- Existing couriers keep receiving everything but oversize until they declare the vehicle.
+ALTER TABLE couriers
+    ADD COLUMN vehicle_type ENUM('foot','bike','car','van') NOT NULL DEFAULT 'foot' AFTER phone;
+UPDATE couriers SET vehicle_type = 'car';
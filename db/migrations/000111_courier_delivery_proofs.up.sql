@@ -0,0 +1,20 @@
+CREATE TABLE courier_delivery_proofs (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    order_id BIGINT UNSIGNED NOT NULL,
+    point_id BIGINT UNSIGNED NOT NULL,
+    pin CHAR(4) NOT NULL,
+    pin_attempts INT NOT NULL DEFAULT 0,
+    method ENUM('pin','photo_signature') NULL,
+    photo_path VARCHAR(255) NULL,
+    signature_path VARCHAR(255) NULL,
+    lat DOUBLE NULL,
+    lon DOUBLE NULL,
+    courier_id BIGINT UNSIGNED NULL,
+    delivered_at DATETIME NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_courier_delivery_proof_point (point_id),
+    KEY idx_courier_delivery_proofs_order (order_id),
+    CONSTRAINT fk_courier_proofs_order FOREIGN KEY (order_id) REFERENCES courier_orders(id) ON DELETE CASCADE,
+    CONSTRAINT fk_courier_proofs_point FOREIGN KEY (point_id) REFERENCES courier_order_points(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
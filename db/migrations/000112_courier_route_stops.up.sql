@@ -0,0 +1,14 @@
+CREATE TABLE courier_route_stops (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    courier_id BIGINT UNSIGNED NOT NULL,
+    position INT NOT NULL,
+    order_id BIGINT UNSIGNED NOT NULL,
+    point_id BIGINT UNSIGNED NOT NULL,
+    kind ENUM('pickup','dropoff') NOT NULL,
+    eta_seconds INT NOT NULL DEFAULT 0,
+    planned_at DATETIME NOT NULL,
+    UNIQUE KEY uq_courier_route_stop (courier_id, position),
+    KEY idx_courier_route_stops_order (order_id),
+    CONSTRAINT fk_courier_route_stops_courier FOREIGN KEY (courier_id) REFERENCES couriers(id) ON DELETE CASCADE,
+    CONSTRAINT fk_courier_route_stops_order FOREIGN KEY (order_id) REFERENCES courier_orders(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS courier_balance_ledger;
+DROP TABLE IF EXISTS courier_cod_handins;
+ALTER TABLE couriers DROP COLUMN cod_debt;
+ALTER TABLE courier_orders
+    DROP COLUMN cod_collected_at,
+    DROP COLUMN cod_collected,
+    DROP COLUMN cod_amount;
@@ -0,0 +1,36 @@
+ALTER TABLE courier_orders
+    ADD COLUMN cod_amount INT NOT NULL DEFAULT 0 AFTER parcel_height_cm,
+    ADD COLUMN cod_collected INT NULL AFTER cod_amount,
+    ADD COLUMN cod_collected_at DATETIME NULL AFTER cod_collected;
+
+-- Наличные, собранные у получателей и ещё не сданные курьером.
+ALTER TABLE couriers
+    ADD COLUMN cod_debt INT NOT NULL DEFAULT 0 AFTER balance;
+
+CREATE TABLE courier_cod_handins (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    courier_id BIGINT UNSIGNED NOT NULL,
+    amount INT NOT NULL,
+    status ENUM('pending','confirmed','rejected') NOT NULL DEFAULT 'pending',
+    comment VARCHAR(255) NULL,
+    reviewed_at DATETIME NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_courier_cod_handins_courier (courier_id, status),
+    CONSTRAINT fk_courier_cod_handins_courier FOREIGN KEY (courier_id) REFERENCES couriers(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE courier_balance_ledger (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    courier_id BIGINT UNSIGNED NOT NULL,
+    order_id BIGINT UNSIGNED NULL,
+    handin_id BIGINT UNSIGNED NULL,
+    kind ENUM('cod_collected','cod_handin','cod_payout_deduction') NOT NULL,
+    balance_delta INT NOT NULL DEFAULT 0,
+    debt_delta INT NOT NULL DEFAULT 0,
+    balance_after INT NOT NULL,
+    debt_after INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_courier_balance_ledger_courier (courier_id, id),
+    CONSTRAINT fk_courier_ledger_courier FOREIGN KEY (courier_id) REFERENCES couriers(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,15 @@
+CREATE TABLE courier_tracking_links (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    order_id BIGINT UNSIGNED NOT NULL,
+    point_id BIGINT UNSIGNED NOT NULL,
+    token CHAR(32) NOT NULL,
+    phone VARCHAR(32) NOT NULL,
+    pickup_notified_at DATETIME NULL,
+    approach_notified_at DATETIME NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_courier_tracking_token (token),
+    UNIQUE KEY uq_courier_tracking_point (point_id),
+    KEY idx_courier_tracking_order (order_id),
+    CONSTRAINT fk_courier_tracking_order FOREIGN KEY (order_id) REFERENCES courier_orders(id) ON DELETE CASCADE,
+    CONSTRAINT fk_courier_tracking_point FOREIGN KEY (point_id) REFERENCES courier_order_points(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,10 @@
+ALTER TABLE courier_order_dispatch DROP COLUMN starts_at;
+ALTER TABLE courier_orders
+    DROP KEY idx_courier_orders_reserved,
+    DROP COLUMN late_risk_at,
+    DROP COLUMN reserved_at,
+    DROP COLUMN reserved_courier_id,
+    DROP COLUMN dropoff_to,
+    DROP COLUMN dropoff_from,
+    DROP COLUMN pickup_to,
+    DROP COLUMN pickup_from;
@@ -0,0 +1,13 @@
+ALTER TABLE courier_orders
+    ADD COLUMN pickup_from DATETIME NULL AFTER cod_collected_at,
+    ADD COLUMN pickup_to DATETIME NULL AFTER pickup_from,
+    ADD COLUMN dropoff_from DATETIME NULL AFTER pickup_to,
+    ADD COLUMN dropoff_to DATETIME NULL AFTER dropoff_from,
+    ADD COLUMN reserved_courier_id BIGINT UNSIGNED NULL AFTER dropoff_to,
+    ADD COLUMN reserved_at DATETIME NULL AFTER reserved_courier_id,
+    ADD COLUMN late_risk_at DATETIME NULL AFTER reserved_at,
+    ADD KEY idx_courier_orders_reserved (reserved_courier_id, status);
+
+-- Для заказов ко времени поиск стартует за COURIER_SCHEDULE_LEAD_MINUTES до окна забора.
+ALTER TABLE courier_order_dispatch
+    ADD COLUMN starts_at DATETIME NULL AFTER next_tick_at;
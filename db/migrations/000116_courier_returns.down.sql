@@ -0,0 +1,9 @@
+ALTER TABLE courier_orders
+    DROP KEY idx_courier_orders_returned,
+    DROP COLUMN returned_at,
+    DROP COLUMN return_arrived_at,
+    DROP COLUMN return_fee,
+    DROP COLUMN return_distance_m,
+    DROP COLUMN return_started_at,
+    DROP COLUMN waiting_fee,
+    DROP COLUMN delivery_attempted_at;
@@ -0,0 +1,10 @@
+-- Неудачная доставка: платное ожидание у получателя и возврат посылки отправителю.
+ALTER TABLE courier_orders
+    ADD COLUMN delivery_attempted_at DATETIME NULL AFTER late_risk_at,
+    ADD COLUMN waiting_fee INT NOT NULL DEFAULT 0 AFTER delivery_attempted_at,
+    ADD COLUMN return_started_at DATETIME NULL AFTER waiting_fee,
+    ADD COLUMN return_distance_m INT NOT NULL DEFAULT 0 AFTER return_started_at,
+    ADD COLUMN return_fee INT NOT NULL DEFAULT 0 AFTER return_distance_m,
+    ADD COLUMN return_arrived_at DATETIME NULL AFTER return_fee,
+    ADD COLUMN returned_at DATETIME NULL AFTER return_arrived_at,
+    ADD KEY idx_courier_orders_returned (returned_at);
@@ -0,0 +1,8 @@
+ALTER TABLE courier_orders
+    DROP FOREIGN KEY fk_courier_orders_merchant,
+    DROP KEY uq_courier_orders_external,
+    DROP COLUMN external_id,
+    DROP COLUMN merchant_id;
+DROP TABLE IF EXISTS courier_merchant_webhooks;
+DROP TABLE IF EXISTS courier_merchant_keys;
+DROP TABLE IF EXISTS courier_merchants;
@@ -0,0 +1,55 @@
+-- Магазины, создающие доставки через API; заказы оформляются от имени пользователя магазина.
+CREATE TABLE courier_merchants (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    webhook_url VARCHAR(512) NULL,
+    webhook_secret CHAR(64) NOT NULL,
+    status ENUM('active','blocked') NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_courier_merchants_user (user_id),
+    CONSTRAINT fk_courier_merchants_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Храним только SHA-256 ключа; prefix нужен, чтобы магазин узнал ключ в списке.
+CREATE TABLE courier_merchant_keys (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    merchant_id BIGINT UNSIGNED NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    last_used_at DATETIME NULL,
+    expires_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_courier_merchant_keys_hash (key_hash),
+    KEY idx_courier_merchant_keys_merchant (merchant_id),
+    CONSTRAINT fk_courier_merchant_keys_merchant FOREIGN KEY (merchant_id) REFERENCES courier_merchants(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE courier_merchant_webhooks (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    merchant_id BIGINT UNSIGNED NOT NULL,
+    order_id BIGINT UNSIGNED NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    payload JSON NOT NULL,
+    state ENUM('pending','delivered','failed') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error VARCHAR(512) NULL,
+    delivered_at DATETIME NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_courier_merchant_webhooks_due (state, next_attempt_at),
+    KEY idx_courier_merchant_webhooks_order (order_id, id),
+    CONSTRAINT fk_courier_merchant_webhooks_merchant FOREIGN KEY (merchant_id) REFERENCES courier_merchants(id) ON DELETE CASCADE,
+    CONSTRAINT fk_courier_merchant_webhooks_order FOREIGN KEY (order_id) REFERENCES courier_orders(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE courier_orders
+    ADD COLUMN merchant_id BIGINT UNSIGNED NULL AFTER sender_id,
+    ADD COLUMN external_id VARCHAR(64) NULL AFTER merchant_id,
+    ADD UNIQUE KEY uq_courier_orders_external (merchant_id, external_id),
+    ADD CONSTRAINT fk_courier_orders_merchant FOREIGN KEY (merchant_id) REFERENCES courier_merchants(id) ON DELETE SET NULL;
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS courier_order_claim_photos;
+DROP TABLE IF EXISTS courier_order_claims;
+
+ALTER TABLE courier_orders
+    DROP COLUMN insurance_fee,
+    DROP COLUMN declared_value;
+
+ALTER TABLE couriers
+    DROP COLUMN verification_level;
@@ -0,0 +1,40 @@
+-- Объявленная ценность и страховка заказа; лимит ценности зависит от уровня проверки курьера.
+ALTER TABLE couriers
+    ADD COLUMN verification_level ENUM('basic','verified','trusted') NOT NULL DEFAULT 'basic' AFTER approval_status;
+
+ALTER TABLE courier_orders
+    ADD COLUMN declared_value INT NOT NULL DEFAULT 0 AFTER cod_collected_at,
+    ADD COLUMN insurance_fee INT NOT NULL DEFAULT 0 AFTER declared_value;
+
+-- Претензия по повреждению или утере: одна на заказ, решение и выплату фиксирует админ.
+CREATE TABLE courier_order_claims (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    order_id BIGINT UNSIGNED NOT NULL,
+    sender_id BIGINT UNSIGNED NOT NULL,
+    courier_id BIGINT UNSIGNED NULL,
+    kind ENUM('damage','loss') NOT NULL,
+    description TEXT NOT NULL,
+    amount INT NOT NULL,
+    status ENUM('open','approved','rejected') NOT NULL DEFAULT 'open',
+    payout_amount INT NOT NULL DEFAULT 0,
+    courier_charge INT NOT NULL DEFAULT 0,
+    resolution_note VARCHAR(512) NULL,
+    resolved_at DATETIME NULL,
+    payout_status ENUM('none','pending','paid') NOT NULL DEFAULT 'none',
+    payout_reference VARCHAR(128) NULL,
+    paid_at DATETIME NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_courier_order_claims_order (order_id),
+    KEY idx_courier_order_claims_status (status, created_at),
+    CONSTRAINT fk_courier_order_claims_order FOREIGN KEY (order_id) REFERENCES courier_orders(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE courier_order_claim_photos (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    claim_id BIGINT UNSIGNED NOT NULL,
+    path VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_courier_order_claim_photos_claim (claim_id),
+    CONSTRAINT fk_courier_order_claim_photos_claim FOREIGN KEY (claim_id) REFERENCES courier_order_claims(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
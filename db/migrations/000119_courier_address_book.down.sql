@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS courier_recurring_deliveries;
+DROP TABLE IF EXISTS courier_order_templates;
+DROP TABLE IF EXISTS courier_sender_addresses;
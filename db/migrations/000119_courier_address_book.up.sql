@@ -0,0 +1,51 @@
+-- Адресная книга отправителя: контакты с адресами, которые подставляются в точки маршрута.
+CREATE TABLE courier_sender_addresses (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    sender_id BIGINT UNSIGNED NOT NULL,
+    label VARCHAR(128) NOT NULL,
+    contact_name VARCHAR(255) NULL,
+    phone VARCHAR(32) NULL,
+    address VARCHAR(512) NOT NULL,
+    lat DOUBLE NOT NULL,
+    lon DOUBLE NOT NULL,
+    entrance VARCHAR(32) NULL,
+    apt VARCHAR(32) NULL,
+    floor VARCHAR(32) NULL,
+    intercom VARCHAR(64) NULL,
+    comment VARCHAR(512) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_courier_sender_addresses_sender (sender_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Шаблон заказа хранит тело запроса создания заказа.
+CREATE TABLE courier_order_templates (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    sender_id BIGINT UNSIGNED NOT NULL,
+    name VARCHAR(128) NOT NULL,
+    request JSON NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_courier_order_templates_sender (sender_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Регулярная доставка: по расписанию создаёт заказ из шаблона с окном забора в next_run_at.
+CREATE TABLE courier_recurring_deliveries (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    sender_id BIGINT UNSIGNED NOT NULL,
+    template_id BIGINT UNSIGNED NOT NULL,
+    frequency ENUM('daily','weekly') NOT NULL,
+    weekdays TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    pickup_minute SMALLINT UNSIGNED NOT NULL,
+    window_minutes SMALLINT UNSIGNED NOT NULL,
+    status ENUM('active','paused') NOT NULL DEFAULT 'active',
+    next_run_at DATETIME NOT NULL,
+    last_order_id BIGINT UNSIGNED NULL,
+    last_run_at DATETIME NULL,
+    last_error VARCHAR(255) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_courier_recurring_deliveries_sender (sender_id),
+    KEY idx_courier_recurring_deliveries_due (status, next_run_at),
+    CONSTRAINT fk_courier_recurring_deliveries_template FOREIGN KEY (template_id) REFERENCES courier_order_templates(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
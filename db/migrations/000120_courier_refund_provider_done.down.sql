@@ -0,0 +1,3 @@
+UPDATE courier_order_refunds SET status = 'pending' WHERE status = 'provider_done';
+ALTER TABLE courier_order_refunds
+    MODIFY COLUMN status ENUM('pending','succeeded','failed') NOT NULL DEFAULT 'pending';
@@ -0,0 +1,2 @@
+ALTER TABLE courier_order_refunds
+    MODIFY COLUMN status ENUM('pending','provider_done','succeeded','failed') NOT NULL DEFAULT 'pending';
@@ -0,0 +1,3 @@
+DELETE FROM courier_balance_ledger WHERE kind = 'insurance_charge';
+ALTER TABLE courier_balance_ledger
+    MODIFY COLUMN kind ENUM('cod_collected','cod_handin','cod_payout_deduction') NOT NULL;
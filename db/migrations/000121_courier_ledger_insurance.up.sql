@@ -0,0 +1,2 @@
+ALTER TABLE courier_balance_ledger
+    MODIFY COLUMN kind ENUM('cod_collected','cod_handin','cod_payout_deduction','insurance_charge') NOT NULL;
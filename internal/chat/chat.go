@@ -0,0 +1,165 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Services sharing the order chat.
+const (
+	ServiceTaxi    = "taxi"
+	ServiceCourier = "courier"
+)
+
+// Participant roles.
+const (
+	RolePassenger = "passenger"
+	RoleDriver    = "driver"
+	RoleSender    = "sender"
+	RoleCourier   = "courier"
+)
+
+// WebSocket message types.
+const (
+	TypeMessage = "chat_message"
+	TypeClosed  = "chat_closed"
+	TypeError   = "chat_error"
+)
+
+// MaxLength is the maximum message length in characters.
+const MaxLength = 1000
+
+var (
+	ErrEmptyMessage   = errors.New("message is empty")
+	ErrMessageTooLong = fmt.Errorf("message is longer than %d characters", MaxLength)
+	ErrUnknownPreset  = errors.New("unknown quick reply")
+	ErrChatClosed     = errors.New("chat is closed for this order")
+	ErrNotParticipant = errors.New("not a participant of the order")
+)
+
+// Preset is a quick reply a participant can send with one tap.
+type Preset struct {
+	Code string `json:"code"`
+	Text string `json:"text"`
+}
+
+var presets = map[string][]Preset{
+	RoleDriver: {
+		{Code: "at_entrance", Text: "Я у подъезда"},
+		{Code: "arrived", Text: "Я на месте, жду вас"},
+		{Code: "running_late", Text: "Задерживаюсь на пару минут"},
+		{Code: "cant_find", Text: "Не могу вас найти, уточните, где вы"},
+	},
+	RolePassenger: {
+		{Code: "coming_out", Text: "Уже выхожу"},
+		{Code: "wait_please", Text: "Подождите, пожалуйста, 5 минут"},
+		{Code: "at_pickup", Text: "Я на месте посадки"},
+		{Code: "where_are_you", Text: "Где вы?"},
+	},
+	RoleCourier: {
+		{Code: "at_entrance", Text: "Я у подъезда"},
+		{Code: "arrived", Text: "Я на месте, жду посылку"},
+		{Code: "running_late", Text: "Задерживаюсь на пару минут"},
+		{Code: "cant_find", Text: "Не могу найти адрес, уточните, пожалуйста"},
+	},
+	RoleSender: {
+		{Code: "coming_out", Text: "Уже выхожу"},
+		{Code: "wait_please", Text: "Подождите, пожалуйста, 5 минут"},
+		{Code: "parcel_ready", Text: "Посылка готова"},
+		{Code: "where_are_you", Text: "Где вы?"},
+	},
+}
+
+// Presets returns quick replies available to the role.
+func Presets(role string) []Preset {
+	return append([]Preset(nil), presets[role]...)
+}
+
+// Compose validates a message and returns its body. A preset without text is sent as the preset text.
+func Compose(role, text, preset string) (string, error) {
+	body := strings.TrimSpace(text)
+	preset = strings.TrimSpace(preset)
+	if preset != "" {
+		found := false
+		for _, p := range presets[role] {
+			if p.Code == preset {
+				found = true
+				if body == "" {
+					body = p.Text
+				}
+				break
+			}
+		}
+		if !found {
+			return "", ErrUnknownPreset
+		}
+	}
+	if body == "" {
+		return "", ErrEmptyMessage
+	}
+	if utf8.RuneCountInString(body) > MaxLength {
+		return "", ErrMessageTooLong
+	}
+	return body, nil
+}
+
+// Message is a persisted chat message of an order.
+type Message struct {
+	ID         int64     `json:"id"`
+	Service    string    `json:"-"`
+	OrderID    int64     `json:"order_id"`
+	SenderRole string    `json:"sender_role"`
+	SenderID   int64     `json:"sender_id"`
+	Body       string    `json:"text"`
+	Preset     string    `json:"preset,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Incoming is a chat message sent by a participant over WebSocket.
+type Incoming struct {
+	Type    string `json:"type"`
+	OrderID int64  `json:"order_id"`
+	Text    string `json:"text"`
+	Preset  string `json:"preset"`
+}
+
+// Event is pushed to participants over WebSocket.
+type Event struct {
+	Type    string   `json:"type"`
+	OrderID int64    `json:"order_id"`
+	Message *Message `json:"message,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Handler accepts chat messages sent over WebSocket.
+type Handler interface {
+	ChatMessage(ctx context.Context, role string, senderID int64, in Incoming) error
+}
+
+// ParseIncoming reports whether a raw WebSocket frame is a chat message and decodes it.
+func ParseIncoming(data []byte) (Incoming, bool) {
+	var in Incoming
+	if err := json.Unmarshal(data, &in); err != nil {
+		return Incoming{}, false
+	}
+	return in, strings.EqualFold(strings.TrimSpace(in.Type), TypeMessage)
+}
+
+// IsClientError reports whether err is caused by the participant's message rather than a failure.
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrEmptyMessage) || errors.Is(err, ErrMessageTooLong) || errors.Is(err, ErrUnknownPreset) ||
+		errors.Is(err, ErrChatClosed) || errors.Is(err, ErrNotParticipant)
+}
+
+// ErrorText returns the error text safe to show to a participant.
+func ErrorText(err error) string {
+	if IsClientError(err) {
+		return err.Error()
+	}
+	return "failed to send message"
+}
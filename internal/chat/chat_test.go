@@ -0,0 +1,43 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompose(t *testing.T) {
+	body, err := Compose(RoleDriver, "", "at_entrance")
+	if err != nil || body != "Я у подъезда" {
+		t.Fatalf("preset body = %q, err %v", body, err)
+	}
+	body, err = Compose(RoleDriver, "  Стою у второго подъезда  ", "at_entrance")
+	if err != nil || body != "Стою у второго подъезда" {
+		t.Fatalf("text must win over preset text, got %q, err %v", body, err)
+	}
+	if _, err = Compose(RolePassenger, "", "at_entrance"); !errors.Is(err, ErrUnknownPreset) {
+		t.Fatalf("driver preset must not be available to passenger, err %v", err)
+	}
+	if _, err = Compose(RoleSender, "   ", ""); !errors.Is(err, ErrEmptyMessage) {
+		t.Fatalf("expected empty message error, got %v", err)
+	}
+	if _, err = Compose(RoleCourier, strings.Repeat("я", MaxLength), ""); err != nil {
+		t.Fatalf("message of max length must pass, got %v", err)
+	}
+	if _, err = Compose(RoleCourier, strings.Repeat("я", MaxLength+1), ""); !errors.Is(err, ErrMessageTooLong) {
+		t.Fatalf("expected too long error, got %v", err)
+	}
+}
+
+func TestParseIncoming(t *testing.T) {
+	in, ok := ParseIncoming([]byte(`{"type":"chat_message","order_id":42,"preset":"coming_out"}`))
+	if !ok || in.OrderID != 42 || in.Preset != "coming_out" {
+		t.Fatalf("unexpected incoming %+v ok=%t", in, ok)
+	}
+	if _, ok = ParseIncoming([]byte(`{"lon":71.4,"lat":51.1,"status":"free"}`)); ok {
+		t.Fatal("location update must not be treated as chat message")
+	}
+	if _, ok = ParseIncoming([]byte(`ping`)); ok {
+		t.Fatal("plain text must not be treated as chat message")
+	}
+}
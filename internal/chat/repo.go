@@ -0,0 +1,55 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Repo stores order chat messages in MySQL.
+type Repo struct {
+	db *sql.DB
+}
+
+// NewRepo builds a chat repo.
+func NewRepo(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+// Create persists a message and returns it with the assigned id.
+func (r *Repo) Create(ctx context.Context, m Message) (Message, error) {
+	var preset interface{}
+	if m.Preset != "" {
+		preset = m.Preset
+	}
+	res, err := r.db.ExecContext(ctx, `INSERT INTO order_chat_messages (service, order_id, sender_role, sender_id, body, preset, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`, m.Service, m.OrderID, m.SenderRole, m.SenderID, m.Body, preset, m.CreatedAt)
+	if err != nil {
+		return Message{}, err
+	}
+	m.ID, err = res.LastInsertId()
+	return m, err
+}
+
+// List returns the chat history of an order, oldest first.
+func (r *Repo) List(ctx context.Context, service string, orderID int64) ([]Message, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, service, order_id, sender_role, sender_id, body, preset, created_at
+        FROM order_chat_messages WHERE service = ? AND order_id = ? ORDER BY id`, service, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]Message, 0)
+	for rows.Next() {
+		var (
+			m      Message
+			preset sql.NullString
+		)
+		if err := rows.Scan(&m.ID, &m.Service, &m.OrderID, &m.SenderRole, &m.SenderID, &m.Body, &preset, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		m.Preset = preset.String
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,68 @@
+package commission
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Store loads rules and shift passes.
+type Store interface {
+	ListActiveRules(ctx context.Context, service string) ([]Rule, error)
+	ActivePass(ctx context.Context, service string, actorID int64, at time.Time) (Pass, error)
+}
+
+// Engine computes commission for completed orders.
+type Engine struct {
+	store Store
+	loc   *time.Location
+}
+
+// NewEngine builds an engine; time windows are evaluated in loc, Asia/Almaty when nil.
+func NewEngine(store Store, loc *time.Location) *Engine {
+	if loc == nil {
+		loc = defaultLocation()
+	}
+	return &Engine{store: store, loc: loc}
+}
+
+func defaultLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Almaty")
+	if err != nil {
+		return time.FixedZone("Asia/Almaty", 5*60*60)
+	}
+	return loc
+}
+
+// Compute returns the commission the actor owes for the order. An active shift pass waives it,
+// otherwise the best matching percent rule applies, falling back to DefaultPercent.
+func (e *Engine) Compute(ctx context.Context, actorID int64, s Subject) (Result, error) {
+	if s.At.IsZero() {
+		s.At = time.Now()
+	}
+	s.At = s.At.In(e.loc)
+	if s.TariffClass == "" {
+		s.TariffClass = DefaultTariffClass
+	}
+
+	if actorID > 0 {
+		pass, err := e.store.ActivePass(ctx, s.Service, actorID, s.At)
+		if err == nil {
+			return Result{RuleID: pass.RuleID, PassID: pass.ID}, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return Result{}, err
+		}
+	}
+
+	rules, err := e.store.ListActiveRules(ctx, s.Service)
+	if err != nil {
+		return Result{}, err
+	}
+	rule, ok := Select(rules, s)
+	if !ok {
+		return Result{Amount: Default(s.Amount)}, nil
+	}
+	return Result{Amount: rule.Apply(s.Amount), RuleID: rule.ID}, nil
+}
@@ -0,0 +1,309 @@
+package commission
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Handler serves admin endpoints for commission rules.
+type Handler struct {
+	Repo *Repo
+}
+
+type ruleRequest struct {
+	Name         string     `json:"name"`
+	Service      string     `json:"service"`
+	City         string     `json:"city"`
+	TariffClass  string     `json:"tariff_class"`
+	Kind         string     `json:"kind"`
+	Percent      float64    `json:"percent"`
+	CapAmount    int        `json:"cap_amount"`
+	PassFee      int        `json:"pass_fee"`
+	PassHours    int        `json:"pass_hours"`
+	NewcomerDays int        `json:"newcomer_days"`
+	StartMinute  *int       `json:"start_minute"`
+	EndMinute    *int       `json:"end_minute"`
+	Weekdays     int        `json:"weekdays"`
+	ValidFrom    *time.Time `json:"valid_from"`
+	ValidTo      *time.Time `json:"valid_to"`
+	Priority     int        `json:"priority"`
+	Active       *bool      `json:"active"`
+}
+
+// RuleResponse is the JSON shape of a rule.
+type RuleResponse struct {
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	Service      string     `json:"service,omitempty"`
+	City         string     `json:"city,omitempty"`
+	TariffClass  string     `json:"tariff_class,omitempty"`
+	Kind         string     `json:"kind"`
+	Percent      float64    `json:"percent"`
+	CapAmount    int        `json:"cap_amount"`
+	PassFee      int        `json:"pass_fee"`
+	PassHours    int        `json:"pass_hours"`
+	NewcomerDays int        `json:"newcomer_days"`
+	StartMinute  *int64     `json:"start_minute,omitempty"`
+	EndMinute    *int64     `json:"end_minute,omitempty"`
+	Weekdays     int        `json:"weekdays"`
+	ValidFrom    *time.Time `json:"valid_from,omitempty"`
+	ValidTo      *time.Time `json:"valid_to,omitempty"`
+	Priority     int        `json:"priority"`
+	Active       bool       `json:"active"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// MakeRuleResponse converts a rule for JSON output.
+func MakeRuleResponse(r Rule) RuleResponse {
+	resp := RuleResponse{
+		ID:           r.ID,
+		Name:         r.Name,
+		Service:      r.Service,
+		City:         r.City,
+		TariffClass:  r.TariffClass,
+		Kind:         r.Kind,
+		Percent:      r.Percent,
+		CapAmount:    r.CapAmount,
+		PassFee:      r.PassFee,
+		PassHours:    r.PassHours,
+		NewcomerDays: r.NewcomerDays,
+		Weekdays:     r.Weekdays,
+		Priority:     r.Priority,
+		Active:       r.Active,
+		CreatedAt:    r.CreatedAt,
+		UpdatedAt:    r.UpdatedAt,
+	}
+	if r.StartMinute.Valid && r.EndMinute.Valid {
+		start, end := r.StartMinute.Int64, r.EndMinute.Int64
+		resp.StartMinute, resp.EndMinute = &start, &end
+	}
+	if r.ValidFrom.Valid {
+		t := r.ValidFrom.Time
+		resp.ValidFrom = &t
+	}
+	if r.ValidTo.Valid {
+		t := r.ValidTo.Time
+		resp.ValidTo = &t
+	}
+	return resp
+}
+
+func (req ruleRequest) toRule() Rule {
+	rule := Rule{
+		Name:         strings.TrimSpace(req.Name),
+		Service:      strings.TrimSpace(req.Service),
+		City:         strings.TrimSpace(req.City),
+		TariffClass:  strings.TrimSpace(req.TariffClass),
+		Kind:         strings.TrimSpace(req.Kind),
+		Percent:      req.Percent,
+		CapAmount:    req.CapAmount,
+		PassFee:      req.PassFee,
+		PassHours:    req.PassHours,
+		NewcomerDays: req.NewcomerDays,
+		Weekdays:     req.Weekdays,
+		Priority:     req.Priority,
+		Active:       true,
+	}
+	if rule.Kind == "" {
+		rule.Kind = KindPercent
+	}
+	if req.Active != nil {
+		rule.Active = *req.Active
+	}
+	if req.StartMinute != nil {
+		rule.StartMinute = sql.NullInt64{Int64: int64(*req.StartMinute), Valid: true}
+	}
+	if req.EndMinute != nil {
+		rule.EndMinute = sql.NullInt64{Int64: int64(*req.EndMinute), Valid: true}
+	}
+	if req.ValidFrom != nil {
+		rule.ValidFrom = sql.NullTime{Time: *req.ValidFrom, Valid: true}
+	}
+	if req.ValidTo != nil {
+		rule.ValidTo = sql.NullTime{Time: *req.ValidTo, Valid: true}
+	}
+	return rule
+}
+
+// Validate checks rule fields before saving.
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if r.Service != "" && r.Service != ServiceTaxi && r.Service != ServiceCourier {
+		return errors.New("service must be taxi, courier or empty")
+	}
+	switch r.Kind {
+	case KindPercent:
+		if r.Percent < 0 || r.Percent > 100 {
+			return errors.New("percent must be between 0 and 100")
+		}
+		if r.CapAmount < 0 {
+			return errors.New("cap_amount must not be negative")
+		}
+	case KindShiftPass:
+		if r.PassFee <= 0 || r.PassHours <= 0 {
+			return errors.New("pass_fee and pass_hours must be positive")
+		}
+		if r.Service == "" {
+			return errors.New("shift pass requires service")
+		}
+	default:
+		return errors.New("kind must be percent or shift_pass")
+	}
+	if r.NewcomerDays < 0 {
+		return errors.New("newcomer_days must not be negative")
+	}
+	if r.StartMinute.Valid != r.EndMinute.Valid {
+		return errors.New("start_minute and end_minute go together")
+	}
+	for _, m := range []sql.NullInt64{r.StartMinute, r.EndMinute} {
+		if m.Valid && (m.Int64 < 0 || m.Int64 >= 24*60) {
+			return errors.New("minutes must be within a day")
+		}
+	}
+	if r.Weekdays < 0 || r.Weekdays > 0x7f {
+		return errors.New("weekdays must be a 7-bit mask")
+	}
+	if r.ValidFrom.Valid && r.ValidTo.Valid && !r.ValidFrom.Time.Before(r.ValidTo.Time) {
+		return errors.New("valid_from must be before valid_to")
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// ListRules returns all rules.
+func (h *Handler) ListRules(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rules, err := h.Repo.ListRules(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load rules")
+		return
+	}
+	out := make([]RuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		out = append(out, MakeRuleResponse(rule))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"rules": out})
+}
+
+// CreateRule adds a rule.
+func (h *Handler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var req ruleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	rule := req.toRule()
+	if err := rule.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id, err := h.Repo.CreateRule(ctx, rule)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create rule")
+		return
+	}
+	h.respondRule(w, ctx, id, http.StatusCreated)
+}
+
+// UpdateRule replaces the rule given by :id.
+func (h *Handler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get(":id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid rule id")
+		return
+	}
+	var req ruleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	rule := req.toRule()
+	rule.ID = id
+	if err := rule.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.Repo.UpdateRule(ctx, rule); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "rule not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to update rule")
+		return
+	}
+	h.respondRule(w, ctx, id, http.StatusOK)
+}
+
+// DeleteRule deactivates the rule given by :id.
+func (h *Handler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get(":id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid rule id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.Repo.DeactivateRule(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "rule not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to deactivate rule")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deactivated"})
+}
+
+func (h *Handler) respondRule(w http.ResponseWriter, ctx context.Context, id int64, status int) {
+	rule, err := h.Repo.GetRule(ctx, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load rule %d", id))
+		return
+	}
+	writeJSON(w, status, MakeRuleResponse(rule))
+}
+
+// PassResponse is the JSON shape of a purchased shift pass.
+type PassResponse struct {
+	ID       int64     `json:"id"`
+	RuleID   int64     `json:"rule_id"`
+	Fee      int       `json:"fee"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// MakePassResponse converts a pass for JSON output.
+func MakePassResponse(p Pass) PassResponse {
+	return PassResponse{ID: p.ID, RuleID: p.RuleID, Fee: p.Fee, StartsAt: p.StartsAt, EndsAt: p.EndsAt}
+}
@@ -0,0 +1,206 @@
+package commission
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrPassActive is returned when the actor already has an unexpired shift pass.
+var ErrPassActive = errors.New("shift pass already active")
+
+// Repo stores commission rules and shift passes in MySQL.
+type Repo struct {
+	db *sql.DB
+}
+
+// NewRepo builds a commission repo.
+func NewRepo(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+const ruleColumns = `id, name, service, city, tariff_class, kind, percent, cap_amount, pass_fee, pass_hours,
+        newcomer_days, start_minute, end_minute, weekdays, valid_from, valid_to, priority, is_active, created_at, updated_at`
+
+func scanRule(row interface{ Scan(...interface{}) error }) (Rule, error) {
+	var (
+		r                          Rule
+		service, city, tariffClass sql.NullString
+	)
+	err := row.Scan(&r.ID, &r.Name, &service, &city, &tariffClass, &r.Kind, &r.Percent, &r.CapAmount, &r.PassFee, &r.PassHours,
+		&r.NewcomerDays, &r.StartMinute, &r.EndMinute, &r.Weekdays, &r.ValidFrom, &r.ValidTo, &r.Priority, &r.Active, &r.CreatedAt, &r.UpdatedAt)
+	r.Service = service.String
+	r.City = city.String
+	r.TariffClass = tariffClass.String
+	return r, err
+}
+
+func (r *Repo) listRules(ctx context.Context, query string, args ...interface{}) ([]Rule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []Rule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// ListActiveRules returns active percent rules for the service, including rules for any service.
+func (r *Repo) ListActiveRules(ctx context.Context, service string) ([]Rule, error) {
+	return r.listRules(ctx, `SELECT `+ruleColumns+` FROM commission_rules
+        WHERE is_active = 1 AND kind = ? AND (service IS NULL OR service = ?)`, KindPercent, service)
+}
+
+// ListPassPlans returns shift pass plans drivers or couriers of the service can buy.
+func (r *Repo) ListPassPlans(ctx context.Context, service string) ([]Rule, error) {
+	return r.listRules(ctx, `SELECT `+ruleColumns+` FROM commission_rules
+        WHERE is_active = 1 AND kind = ? AND (service IS NULL OR service = ?) ORDER BY pass_fee`, KindShiftPass, service)
+}
+
+// ListRules returns all rules for the admin view, newest first.
+func (r *Repo) ListRules(ctx context.Context) ([]Rule, error) {
+	return r.listRules(ctx, `SELECT `+ruleColumns+` FROM commission_rules ORDER BY id DESC`)
+}
+
+// GetRule loads a rule by id.
+func (r *Repo) GetRule(ctx context.Context, id int64) (Rule, error) {
+	return scanRule(r.db.QueryRowContext(ctx, `SELECT `+ruleColumns+` FROM commission_rules WHERE id = ?`, id))
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// CreateRule inserts a rule and returns its id.
+func (r *Repo) CreateRule(ctx context.Context, rule Rule) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO commission_rules (name, service, city, tariff_class, kind, percent, cap_amount,
+        pass_fee, pass_hours, newcomer_days, start_minute, end_minute, weekdays, valid_from, valid_to, priority, is_active)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.Name, nullIfEmpty(rule.Service), nullIfEmpty(rule.City), nullIfEmpty(rule.TariffClass), rule.Kind, rule.Percent, rule.CapAmount,
+		rule.PassFee, rule.PassHours, rule.NewcomerDays, rule.StartMinute, rule.EndMinute, rule.Weekdays, rule.ValidFrom, rule.ValidTo, rule.Priority, rule.Active)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateRule overwrites a rule. Returns sql.ErrNoRows when it does not exist.
+func (r *Repo) UpdateRule(ctx context.Context, rule Rule) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE commission_rules SET name = ?, service = ?, city = ?, tariff_class = ?, kind = ?, percent = ?,
+        cap_amount = ?, pass_fee = ?, pass_hours = ?, newcomer_days = ?, start_minute = ?, end_minute = ?, weekdays = ?, valid_from = ?,
+        valid_to = ?, priority = ?, is_active = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		rule.Name, nullIfEmpty(rule.Service), nullIfEmpty(rule.City), nullIfEmpty(rule.TariffClass), rule.Kind, rule.Percent,
+		rule.CapAmount, rule.PassFee, rule.PassHours, rule.NewcomerDays, rule.StartMinute, rule.EndMinute, rule.Weekdays, rule.ValidFrom,
+		rule.ValidTo, rule.Priority, rule.Active, rule.ID)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+// DeactivateRule switches a rule off; orders keep referencing it.
+func (r *Repo) DeactivateRule(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE commission_rules SET is_active = 0, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+func expectRow(res sql.Result) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+const passColumns = `id, rule_id, service, actor_id, fee, starts_at, ends_at, created_at`
+
+func scanPass(row interface{ Scan(...interface{}) error }) (Pass, error) {
+	var p Pass
+	err := row.Scan(&p.ID, &p.RuleID, &p.Service, &p.ActorID, &p.Fee, &p.StartsAt, &p.EndsAt, &p.CreatedAt)
+	return p, err
+}
+
+// ActivePass returns the actor's shift pass covering at, or sql.ErrNoRows.
+func (r *Repo) ActivePass(ctx context.Context, service string, actorID int64, at time.Time) (Pass, error) {
+	return scanPass(r.db.QueryRowContext(ctx, `SELECT `+passColumns+` FROM commission_passes
+        WHERE service = ? AND actor_id = ? AND starts_at <= ? AND ends_at > ? ORDER BY ends_at DESC LIMIT 1`, service, actorID, at, at))
+}
+
+// ChargeFunc takes the pass fee from the actor's balance inside the purchase transaction.
+type ChargeFunc func(ctx context.Context, tx *sql.Tx, actorID int64, amount int) error
+
+// BuyPass charges the fee and stores the pass in one transaction unless the actor already has one running.
+func (r *Repo) BuyPass(ctx context.Context, p Pass, charge ChargeFunc) (id int64, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var x int
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM commission_passes WHERE service = ? AND actor_id = ? AND ends_at > ? LIMIT 1 FOR UPDATE`,
+		p.Service, p.ActorID, p.StartsAt).Scan(&x)
+	if err == nil {
+		err = ErrPassActive
+		return 0, err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if err = charge(ctx, tx, p.ActorID, p.Fee); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO commission_passes (rule_id, service, actor_id, fee, starts_at, ends_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		p.RuleID, p.Service, p.ActorID, p.Fee, p.StartsAt, p.EndsAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err = res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// ListPasses returns the actor's passes, newest first.
+func (r *Repo) ListPasses(ctx context.Context, service string, actorID int64, limit int) ([]Pass, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+passColumns+` FROM commission_passes WHERE service = ? AND actor_id = ? ORDER BY id DESC LIMIT ?`,
+		service, actorID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var passes []Pass
+	for rows.Next() {
+		p, err := scanPass(rows)
+		if err != nil {
+			return nil, err
+		}
+		passes = append(passes, p)
+	}
+	return passes, rows.Err()
+}
@@ -0,0 +1,230 @@
+// Package commission computes platform commission for taxi and courier orders
+// from rules stored in the database.
+package commission
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Services a rule may target; an empty service matches both.
+const (
+	ServiceTaxi    = "taxi"
+	ServiceCourier = "courier"
+)
+
+// Rule kinds.
+const (
+	// KindPercent takes a percentage of the order price, optionally capped.
+	KindPercent = "percent"
+	// KindShiftPass is a flat-fee plan bought in advance; orders during the pass are commission free.
+	KindShiftPass = "shift_pass"
+)
+
+// DefaultTariffClass is used while orders carry no tariff class of their own.
+const DefaultTariffClass = "standard"
+
+// DefaultPercent applies when no rule matches and to orders completed before the rules engine.
+const DefaultPercent = 10
+
+// Rule is a single commission rule. Empty or zero filters match anything.
+type Rule struct {
+	ID          int64
+	Name        string
+	Service     string
+	City        string
+	TariffClass string
+	Kind        string
+	Percent     float64
+	// CapAmount limits commission per order in tenge; 0 means no cap.
+	CapAmount int
+	// PassFee and PassHours describe a shift pass plan.
+	PassFee   int
+	PassHours int
+	// NewcomerDays restricts the rule to drivers and couriers who joined less than N days ago.
+	NewcomerDays int
+	// StartMinute and EndMinute bound the local time of day, [start, end); the window may cross midnight.
+	StartMinute sql.NullInt64
+	EndMinute   sql.NullInt64
+	// Weekdays is a bitmask, bit 0 is Monday; 0 means every day.
+	Weekdays  int
+	ValidFrom sql.NullTime
+	ValidTo   sql.NullTime
+	Priority  int
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Subject describes the order the commission is computed for.
+type Subject struct {
+	Service     string
+	City        string
+	TariffClass string
+	Amount      int
+	// At is the completion time in local time.
+	At time.Time
+	// JoinedAt is when the driver or courier registered; zero when unknown.
+	JoinedAt time.Time
+}
+
+// Pass is a purchased shift pass.
+type Pass struct {
+	ID        int64
+	RuleID    int64
+	Service   string
+	ActorID   int64
+	Fee       int
+	StartsAt  time.Time
+	EndsAt    time.Time
+	CreatedAt time.Time
+}
+
+// Result is the commission charged for an order and what produced it.
+type Result struct {
+	Amount int
+	// RuleID is 0 when the default percent was applied.
+	RuleID int64
+	// PassID is set when an active shift pass waived the commission.
+	PassID int64
+}
+
+// Matches reports whether the percent rule applies to the subject.
+func (r Rule) Matches(s Subject) bool {
+	if !r.Active || r.Kind != KindPercent {
+		return false
+	}
+	if r.Service != "" && r.Service != s.Service {
+		return false
+	}
+	if r.City != "" && r.City != s.City {
+		return false
+	}
+	if r.TariffClass != "" && r.TariffClass != s.TariffClass {
+		return false
+	}
+	if r.ValidFrom.Valid && s.At.Before(r.ValidFrom.Time) {
+		return false
+	}
+	if r.ValidTo.Valid && !s.At.Before(r.ValidTo.Time) {
+		return false
+	}
+	if r.Weekdays != 0 {
+		bit := (int(s.At.Weekday()) + 6) % 7
+		if r.Weekdays&(1<<bit) == 0 {
+			return false
+		}
+	}
+	if r.StartMinute.Valid && r.EndMinute.Valid && r.StartMinute.Int64 != r.EndMinute.Int64 {
+		minute := int64(s.At.Hour()*60 + s.At.Minute())
+		start, end := r.StartMinute.Int64, r.EndMinute.Int64
+		if start < end {
+			if minute < start || minute >= end {
+				return false
+			}
+		} else if minute < start && minute >= end {
+			return false
+		}
+	}
+	if r.NewcomerDays > 0 {
+		if s.JoinedAt.IsZero() || s.At.Sub(s.JoinedAt) >= time.Duration(r.NewcomerDays)*24*time.Hour {
+			return false
+		}
+	}
+	return true
+}
+
+// specificity counts the filters a rule sets; used to break priority ties.
+func (r Rule) specificity() int {
+	n := 0
+	for _, set := range []bool{
+		r.Service != "", r.City != "", r.TariffClass != "", r.NewcomerDays > 0, r.Weekdays != 0,
+		r.StartMinute.Valid && r.EndMinute.Valid, r.ValidFrom.Valid || r.ValidTo.Valid,
+	} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// Select picks the matching rule with the highest priority, then the most specific, then the newest.
+func Select(rules []Rule, s Subject) (Rule, bool) {
+	var (
+		best  Rule
+		found bool
+	)
+	for _, r := range rules {
+		if !r.Matches(s) {
+			continue
+		}
+		if !found || r.Priority > best.Priority ||
+			(r.Priority == best.Priority && r.specificity() > best.specificity()) ||
+			(r.Priority == best.Priority && r.specificity() == best.specificity() && r.ID > best.ID) {
+			best = r
+			found = true
+		}
+	}
+	return best, found
+}
+
+// Apply computes the commission of a percent rule for the amount, rounding up to a whole tenge.
+func (r Rule) Apply(amount int) int {
+	c := percentOf(amount, r.Percent)
+	if r.CapAmount > 0 && c > r.CapAmount {
+		c = r.CapAmount
+	}
+	return c
+}
+
+// Default returns the commission under DefaultPercent.
+func Default(amount int) int {
+	return percentOf(amount, DefaultPercent)
+}
+
+func percentOf(amount int, percent float64) int {
+	if amount <= 0 || percent <= 0 {
+		return 0
+	}
+	// проценты храним с точностью до сотых, считаем в целых, чтобы не терять тиын на округлении
+	bp := int64(percent*100 + 0.5)
+	return int((int64(amount)*bp + 9999) / 10000)
+}
+
+// Refunded returns the part of the charged commission that falls on a refund of amount,
+// given the total paid and the amount refunded before.
+func Refunded(charged, total, refundedBefore, amount int) int {
+	if charged <= 0 || total <= 0 || amount <= 0 {
+		return 0
+	}
+	if refundedBefore < 0 {
+		refundedBefore = 0
+	}
+	after := refundedBefore + amount
+	if after > total {
+		after = total
+	}
+	share := func(part int) int {
+		return int((int64(charged)*int64(part) + int64(total) - 1) / int64(total))
+	}
+	return share(after) - share(refundedBefore)
+}
+
+// ErrNotPassPlan is returned when a rule cannot be bought as a shift pass for the service.
+var ErrNotPassPlan = errors.New("rule is not a shift pass plan")
+
+// NewPass builds a pass bought from the plan starting at now.
+func (r Rule) NewPass(service string, actorID int64, now time.Time) (Pass, error) {
+	if !r.Active || r.Kind != KindShiftPass || r.PassHours <= 0 || (r.Service != "" && r.Service != service) {
+		return Pass{}, ErrNotPassPlan
+	}
+	return Pass{
+		RuleID:   r.ID,
+		Service:  service,
+		ActorID:  actorID,
+		Fee:      r.PassFee,
+		StartsAt: now,
+		EndsAt:   now.Add(time.Duration(r.PassHours) * time.Hour),
+	}, nil
+}
@@ -0,0 +1,116 @@
+package commission
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+var almaty = time.FixedZone("Asia/Almaty", 5*60*60)
+
+func TestRuleApplyRoundsUpAndCaps(t *testing.T) {
+	r := Rule{Kind: KindPercent, Percent: 12.5}
+	if got := r.Apply(1001); got != 126 {
+		t.Fatalf("expected 126, got %d", got)
+	}
+	r.CapAmount = 100
+	if got := r.Apply(1001); got != 100 {
+		t.Fatalf("expected cap 100, got %d", got)
+	}
+	if got := Default(1500); got != 150 {
+		t.Fatalf("expected default 150, got %d", got)
+	}
+}
+
+func TestSelectPrefersPriorityThenSpecificity(t *testing.T) {
+	at := time.Date(2026, 10, 19, 23, 30, 0, 0, almaty) // понедельник
+	s := Subject{Service: ServiceTaxi, City: "astana", TariffClass: DefaultTariffClass, Amount: 2000, At: at}
+
+	base := Rule{ID: 1, Name: "base", Kind: KindPercent, Percent: 10, Active: true}
+	city := Rule{ID: 2, Name: "city", Kind: KindPercent, Percent: 8, City: "astana", Active: true}
+	night := Rule{ID: 3, Name: "night", Kind: KindPercent, Percent: 5, Active: true, Priority: 1,
+		StartMinute: sql.NullInt64{Int64: 22 * 60, Valid: true}, EndMinute: sql.NullInt64{Int64: 6 * 60, Valid: true}}
+	courier := Rule{ID: 4, Name: "courier", Kind: KindPercent, Percent: 1, Service: ServiceCourier, Active: true, Priority: 10}
+
+	got, ok := Select([]Rule{base, city, night, courier}, s)
+	if !ok || got.ID != night.ID {
+		t.Fatalf("expected night rule across midnight, got %+v ok=%v", got, ok)
+	}
+
+	s.At = time.Date(2026, 10, 19, 12, 0, 0, 0, almaty)
+	got, _ = Select([]Rule{base, city, night, courier}, s)
+	if got.ID != city.ID {
+		t.Fatalf("expected city rule at noon, got %d", got.ID)
+	}
+
+	weekend := city
+	weekend.ID, weekend.Weekdays, weekend.Priority = 5, 1<<5|1<<6, 5
+	got, _ = Select([]Rule{base, city, weekend}, s)
+	if got.ID != city.ID {
+		t.Fatalf("expected weekend rule to be skipped on monday, got %d", got.ID)
+	}
+}
+
+func TestNewcomerRule(t *testing.T) {
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, almaty)
+	promo := Rule{ID: 1, Name: "promo", Kind: KindPercent, Percent: 0, NewcomerDays: 14, Priority: 100, Active: true}
+
+	if !promo.Matches(Subject{At: at, JoinedAt: at.AddDate(0, 0, -3)}) {
+		t.Fatalf("expected promo for driver joined 3 days ago")
+	}
+	if promo.Matches(Subject{At: at, JoinedAt: at.AddDate(0, 0, -20)}) {
+		t.Fatalf("expected no promo for driver joined 20 days ago")
+	}
+	if promo.Matches(Subject{At: at}) {
+		t.Fatalf("expected no promo when join date is unknown")
+	}
+}
+
+func TestRefundedSharesCommission(t *testing.T) {
+	if got := Refunded(200, 2000, 0, 2000); got != 200 {
+		t.Fatalf("full refund must return full commission, got %d", got)
+	}
+	first := Refunded(200, 2000, 0, 500)
+	second := Refunded(200, 2000, 500, 1500)
+	if first != 50 || first+second != 200 {
+		t.Fatalf("partial refunds must add up: %d + %d", first, second)
+	}
+}
+
+type stubStore struct {
+	rules []Rule
+	pass  *Pass
+}
+
+func (s stubStore) ListActiveRules(ctx context.Context, service string) ([]Rule, error) {
+	return s.rules, nil
+}
+
+func (s stubStore) ActivePass(ctx context.Context, service string, actorID int64, at time.Time) (Pass, error) {
+	if s.pass == nil {
+		return Pass{}, sql.ErrNoRows
+	}
+	return *s.pass, nil
+}
+
+func TestEngineCompute(t *testing.T) {
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, almaty)
+	rules := []Rule{{ID: 7, Name: "capped", Kind: KindPercent, Percent: 15, CapAmount: 500, Active: true}}
+
+	res, err := NewEngine(stubStore{rules: rules}, almaty).Compute(context.Background(), 1, Subject{Service: ServiceTaxi, Amount: 5000, At: at})
+	if err != nil || res.Amount != 500 || res.RuleID != 7 {
+		t.Fatalf("expected capped rule, got %+v err=%v", res, err)
+	}
+
+	res, _ = NewEngine(stubStore{}, almaty).Compute(context.Background(), 1, Subject{Service: ServiceTaxi, Amount: 5000, At: at})
+	if res.Amount != 500 || res.RuleID != 0 {
+		t.Fatalf("expected default percent, got %+v", res)
+	}
+
+	pass := &Pass{ID: 3, RuleID: 9}
+	res, _ = NewEngine(stubStore{rules: rules, pass: pass}, almaty).Compute(context.Background(), 1, Subject{Service: ServiceTaxi, Amount: 5000, At: at})
+	if res.Amount != 0 || res.PassID != 3 || res.RuleID != 9 {
+		t.Fatalf("expected shift pass to waive commission, got %+v", res)
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"net/http"
 
+	"naimuBack/internal/chat"
+	"naimuBack/internal/commission"
 	"naimuBack/internal/courier/dispatch"
 	"naimuBack/internal/courier/geo"
 	courierhttp "naimuBack/internal/courier/http"
+	"naimuBack/internal/courier/pricing"
 	"naimuBack/internal/courier/repo"
 	"naimuBack/internal/courier/ws"
 )
@@ -33,6 +36,15 @@ func ensureModule(deps *Deps) (*moduleState, error) {
 		return deps.module, nil
 	}
 
+	insurance := pricing.InsuranceRules{
+		FeePercent: deps.Config.InsuranceFeePercent,
+		MinFee:     deps.Config.InsuranceMinFee,
+		Caps: map[string]int{
+			repo.CourierVerificationBasic:    deps.Config.InsuranceCapBasic,
+			repo.CourierVerificationVerified: deps.Config.InsuranceCapVerified,
+			repo.CourierVerificationTrusted:  deps.Config.InsuranceCapTrusted,
+		},
+	}
 	cfgAdapter := dispatch.ConfigAdapter{
 		PricePerKM:        deps.Config.PricePerKM,
 		MinPrice:          deps.Config.MinPrice,
@@ -43,6 +55,8 @@ func ensureModule(deps *Deps) (*moduleState, error) {
 		OfferTTL:          deps.Config.OfferTTL,
 		SearchTimeout:     deps.Config.SearchTimeout,
 		RegionKey:         deps.Config.RedisCity,
+		BatchCapacity:     deps.Config.BatchCapacity,
+		InsuranceCaps:     insurance.Caps,
 	}
 
 	locator := geo.NewCourierLocator(deps.RDB)
@@ -60,14 +74,43 @@ func ensureModule(deps *Deps) (*moduleState, error) {
 	couriersRepo := repo.NewCouriersRepo(deps.DB)
 	usersRepo := repo.NewUsersRepo(deps.DB)
 	dispatchRepo := repo.NewDispatchRepo(deps.DB)
+	refundsRepo := repo.NewRefundsRepo(deps.DB)
+	merchantsRepo := repo.NewMerchantsRepo(deps.DB)
+	addressBookRepo := repo.NewAddressBookRepo(deps.DB)
+	commissionRepo := commission.NewRepo(deps.DB)
+	commissionEngine := commission.NewEngine(commissionRepo, nil)
+	chatRepo := chat.NewRepo(deps.DB)
 
-	dispatcher := dispatch.New(ordersRepo, dispatchRepo, offersRepo, locator, courierHub, senderHub, deps.Logger, cfgAdapter)
+	dispatcher := dispatch.New(ordersRepo, dispatchRepo, offersRepo, couriersRepo, locator, courierHub, senderHub, deps.Logger, cfgAdapter)
 	httpCfg := courierhttp.Config{
 		PricePerKM:        deps.Config.PricePerKM,
 		MinPrice:          deps.Config.MinPrice,
 		SearchRadiusStart: deps.Config.SearchRadiusStart,
+		RegionKey:         deps.Config.RedisCity,
+		ChatHidePhones:    deps.Config.ChatHidePhones,
+		LiveETARefresh:    deps.Config.LiveETARefresh,
+		BatchCapacity:     deps.Config.BatchCapacity,
+		TrackingBaseURL:   deps.Config.TrackingBaseURL,
+		TrackingApproachM: deps.Config.TrackingApproachM,
+		ScheduleLead:      deps.Config.ScheduleLead,
+		Returns: pricing.ReturnRules{
+			PricePerKM:    deps.Config.ReturnPricePerKM,
+			MinPrice:      deps.Config.ReturnMinPrice,
+			OrderPercent:  deps.Config.ReturnOrderPercent,
+			FreeWait:      deps.Config.RecipientFreeWait,
+			WaitPerMinute: deps.Config.RecipientWaitPerMinute,
+		},
+		MerchantKeyGrace: deps.Config.MerchantKeyGrace,
+		Insurance:        insurance,
+		RecurringLead:    deps.Config.RecurringLead,
 	}
-	server := courierhttp.NewServer(httpCfg, deps.Logger, ordersRepo, offersRepo, couriersRepo, usersRepo, courierHub, senderHub, dispatcher)
+	server := courierhttp.NewServer(httpCfg, deps.Logger, ordersRepo, offersRepo, couriersRepo, usersRepo, refundsRepo, merchantsRepo, addressBookRepo, commissionRepo, commissionEngine, chatRepo, deps.Router, deps.LiveOps, courierHub, senderHub, dispatcher, deps.Refunder, deps.SMS)
+	courierHub.SetChatHandler(server)
+	senderHub.SetChatHandler(server)
+	courierHub.SetLocationHandler(server)
+	dispatcher.SetReservationHandler(server)
+	dispatcher.SetStatusHandler(server)
+	deps.LiveOps.AddSource(server.LiveOpsSource())
 
 	deps.module = &moduleState{
 		locator:      locator,
@@ -97,12 +140,16 @@ func RegisterCourierRoutes(mux *http.ServeMux, deps *Deps) error {
 	return nil
 }
 
-// StartCourierWorkers launches background dispatcher loop for courier orders.
+// StartCourierWorkers launches the dispatcher loop, the late-risk watch of scheduled orders,
+// merchant webhook deliveries and orders of recurring deliveries.
 func StartCourierWorkers(ctx context.Context, deps *Deps) error {
 	module, err := ensureModule(deps)
 	if err != nil {
 		return err
 	}
 	go module.dispatcher.Run(ctx)
+	go module.server.RunLateRiskWatch(ctx)
+	go module.server.RunMerchantWebhooks(ctx)
+	go module.server.RunRecurringDeliveries(ctx)
 	return nil
 }
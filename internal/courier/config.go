@@ -18,6 +18,22 @@ const (
 	defaultSearchRadiusMax   = 5000
 	defaultDispatchTick      = 10 * time.Second
 	defaultRedisCity         = "astana"
+	defaultLiveETARefresh    = 15 * time.Second
+	defaultBatchCapacity     = 3
+	defaultTrackingApproachM = 1000
+	defaultScheduleLead      = 45 * time.Minute
+	defaultReturnPricePerKM  = 120
+	defaultReturnMinPrice    = 400
+	defaultReturnOrderPct    = 50
+	defaultRecipientFreeWait = 10 * time.Minute
+	defaultRecipientWaitFee  = 25
+	defaultMerchantKeyGrace  = 24 * time.Hour
+	defaultInsuranceFeePct   = 1
+	defaultInsuranceMinFee   = 100
+	defaultInsuranceCapBasic = 50000
+	defaultInsuranceCapVerif = 300000
+	defaultInsuranceCapTrust = 1000000
+	defaultRecurringLead     = 3 * time.Hour
 )
 
 // Config holds runtime configuration for the courier module.
@@ -31,6 +47,39 @@ type Config struct {
 	SearchRadiusMax   int
 	DispatchTick      time.Duration
 	RedisCity         string
+	// ChatHidePhones hides phone numbers of the other party: sender and courier talk through the order chat.
+	ChatHidePhones bool
+	// LiveETARefresh limits how often the courier's ETA shown to the sender is re-routed.
+	LiveETARefresh time.Duration
+	// BatchCapacity caps how many active orders a courier may carry at once; 1 disables batching.
+	BatchCapacity int
+	// TrackingBaseURL is the public recipient tracking page; the token is appended to it.
+	// Without it recipients get notifications without a link.
+	TrackingBaseURL string
+	// TrackingApproachM is how close the courier must be to text the recipient "approaching".
+	TrackingApproachM int
+	// ScheduleLead is how long before the pickup window a scheduled order starts dispatching.
+	ScheduleLead time.Duration
+	// Return* price the leg back to the sender after a failed delivery: per km with a minimum,
+	// but never below ReturnOrderPercent of the order price.
+	ReturnPricePerKM   int
+	ReturnMinPrice     int
+	ReturnOrderPercent int
+	// RecipientFreeWait is the free waiting at the recipient; every started minute after it
+	// costs RecipientWaitPerMinute.
+	RecipientFreeWait      time.Duration
+	RecipientWaitPerMinute int
+	// MerchantKeyGrace is how long a rotated merchant API key keeps working.
+	MerchantKeyGrace time.Duration
+	// InsuranceFeePercent of the declared value is charged for cover, at least InsuranceMinFee.
+	InsuranceFeePercent int
+	InsuranceMinFee     int
+	// InsuranceCap* is the highest declared value a basic, verified or trusted courier may carry.
+	InsuranceCapBasic    int
+	InsuranceCapVerified int
+	InsuranceCapTrusted  int
+	// RecurringLead is how long before the pickup a recurring delivery places its order.
+	RecurringLead time.Duration
 }
 
 // LoadConfig reads courier configuration from environment variables and applies defaults.
@@ -45,6 +94,25 @@ func LoadConfig() (Config, error) {
 		SearchRadiusMax:   defaultSearchRadiusMax,
 		DispatchTick:      defaultDispatchTick,
 		RedisCity:         defaultRedisCity,
+		ChatHidePhones:    true,
+		LiveETARefresh:    defaultLiveETARefresh,
+		BatchCapacity:     defaultBatchCapacity,
+		TrackingApproachM: defaultTrackingApproachM,
+		ScheduleLead:      defaultScheduleLead,
+
+		ReturnPricePerKM:       defaultReturnPricePerKM,
+		ReturnMinPrice:         defaultReturnMinPrice,
+		ReturnOrderPercent:     defaultReturnOrderPct,
+		RecipientFreeWait:      defaultRecipientFreeWait,
+		RecipientWaitPerMinute: defaultRecipientWaitFee,
+		MerchantKeyGrace:       defaultMerchantKeyGrace,
+
+		InsuranceFeePercent:  defaultInsuranceFeePct,
+		InsuranceMinFee:      defaultInsuranceMinFee,
+		InsuranceCapBasic:    defaultInsuranceCapBasic,
+		InsuranceCapVerified: defaultInsuranceCapVerif,
+		InsuranceCapTrusted:  defaultInsuranceCapTrust,
+		RecurringLead:        defaultRecurringLead,
 	}
 
 	if v, err := readIntEnv("COURIER_PRICE_PER_KM"); err != nil {
@@ -105,6 +173,22 @@ func LoadConfig() (Config, error) {
 		cfg.RedisCity = strings.ToLower(strings.TrimSpace(v))
 	}
 
+	if v := os.Getenv("COURIER_ORDER_CHAT_HIDE_PHONES"); v != "" {
+		hide, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse COURIER_ORDER_CHAT_HIDE_PHONES: %w", err)
+		}
+		cfg.ChatHidePhones = hide
+	}
+
+	if v := os.Getenv("COURIER_LIVE_ETA_REFRESH_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse COURIER_LIVE_ETA_REFRESH_SECONDS: %w", err)
+		}
+		cfg.LiveETARefresh = time.Duration(secs) * time.Second
+	}
+
 	if v, err := readIntEnv("COURIER_SEARCH_RADIUS_START"); err != nil {
 		return Config{}, fmt.Errorf("parse COURIER_SEARCH_RADIUS_START: %w", err)
 	} else if v != nil {
@@ -117,6 +201,98 @@ func LoadConfig() (Config, error) {
 		cfg.SearchRadiusStart = *v
 	}
 
+	if v, err := readIntEnv("COURIER_BATCH_CAPACITY"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_BATCH_CAPACITY: %w", err)
+	} else if v != nil {
+		cfg.BatchCapacity = *v
+	}
+
+	cfg.TrackingBaseURL = strings.TrimSpace(os.Getenv("COURIER_TRACKING_BASE_URL"))
+
+	if v, err := readIntEnv("COURIER_TRACKING_APPROACH_M"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_TRACKING_APPROACH_M: %w", err)
+	} else if v != nil {
+		cfg.TrackingApproachM = *v
+	}
+
+	if v, err := readIntEnv("COURIER_SCHEDULE_LEAD_MINUTES"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_SCHEDULE_LEAD_MINUTES: %w", err)
+	} else if v != nil {
+		cfg.ScheduleLead = time.Duration(*v) * time.Minute
+	}
+
+	if v, err := readIntEnv("COURIER_RETURN_PRICE_PER_KM"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_RETURN_PRICE_PER_KM: %w", err)
+	} else if v != nil {
+		cfg.ReturnPricePerKM = *v
+	}
+
+	if v, err := readIntEnv("COURIER_RETURN_MIN_PRICE"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_RETURN_MIN_PRICE: %w", err)
+	} else if v != nil {
+		cfg.ReturnMinPrice = *v
+	}
+
+	if v, err := readIntEnv("COURIER_RETURN_ORDER_PERCENT"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_RETURN_ORDER_PERCENT: %w", err)
+	} else if v != nil {
+		cfg.ReturnOrderPercent = *v
+	}
+
+	if v, err := readIntEnv("COURIER_RECIPIENT_FREE_WAIT_MINUTES"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_RECIPIENT_FREE_WAIT_MINUTES: %w", err)
+	} else if v != nil {
+		cfg.RecipientFreeWait = time.Duration(*v) * time.Minute
+	}
+
+	if v, err := readIntEnv("COURIER_RECIPIENT_WAIT_PER_MINUTE"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_RECIPIENT_WAIT_PER_MINUTE: %w", err)
+	} else if v != nil {
+		cfg.RecipientWaitPerMinute = *v
+	}
+
+	if v, err := readIntEnv("COURIER_MERCHANT_KEY_GRACE_HOURS"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_MERCHANT_KEY_GRACE_HOURS: %w", err)
+	} else if v != nil {
+		cfg.MerchantKeyGrace = time.Duration(*v) * time.Hour
+	}
+
+	if v, err := readIntEnv("COURIER_INSURANCE_FEE_PERCENT"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_INSURANCE_FEE_PERCENT: %w", err)
+	} else if v != nil {
+		cfg.InsuranceFeePercent = *v
+	}
+
+	if v, err := readIntEnv("COURIER_INSURANCE_MIN_FEE"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_INSURANCE_MIN_FEE: %w", err)
+	} else if v != nil {
+		cfg.InsuranceMinFee = *v
+	}
+
+	if v, err := readIntEnv("COURIER_INSURANCE_CAP_BASIC"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_INSURANCE_CAP_BASIC: %w", err)
+	} else if v != nil {
+		cfg.InsuranceCapBasic = *v
+	}
+
+	if v, err := readIntEnv("COURIER_INSURANCE_CAP_VERIFIED"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_INSURANCE_CAP_VERIFIED: %w", err)
+	} else if v != nil {
+		cfg.InsuranceCapVerified = *v
+	}
+
+	if v, err := readIntEnv("COURIER_INSURANCE_CAP_TRUSTED"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_INSURANCE_CAP_TRUSTED: %w", err)
+	} else if v != nil {
+		cfg.InsuranceCapTrusted = *v
+	}
+
+	if v, err := readIntEnv("COURIER_RECURRING_LEAD_MINUTES"); err != nil {
+		return Config{}, fmt.Errorf("parse COURIER_RECURRING_LEAD_MINUTES: %w", err)
+	} else if v != nil {
+		cfg.RecurringLead = time.Duration(*v) * time.Minute
+	}
+
 	if cfg.PricePerKM <= 0 {
 		return Config{}, fmt.Errorf("COURIER_PRICE_PER_KM must be positive")
 	}
@@ -144,9 +320,42 @@ func LoadConfig() (Config, error) {
 	if cfg.DispatchTick <= 0 {
 		return Config{}, fmt.Errorf("COURIER_DISPATCH_TICK_SECONDS must be positive")
 	}
+	if cfg.LiveETARefresh <= 0 {
+		return Config{}, fmt.Errorf("COURIER_LIVE_ETA_REFRESH_SECONDS must be positive")
+	}
 	if cfg.SearchRadiusStart <= 0 {
 		return Config{}, fmt.Errorf("COURIER_SEARCH_RADIUS_START must be positive")
 	}
+	if cfg.BatchCapacity <= 0 {
+		return Config{}, fmt.Errorf("COURIER_BATCH_CAPACITY must be positive")
+	}
+	if cfg.TrackingApproachM <= 0 {
+		return Config{}, fmt.Errorf("COURIER_TRACKING_APPROACH_M must be positive")
+	}
+	if cfg.ScheduleLead <= 0 {
+		return Config{}, fmt.Errorf("COURIER_SCHEDULE_LEAD_MINUTES must be positive")
+	}
+	if cfg.ReturnPricePerKM < 0 || cfg.ReturnMinPrice < 0 {
+		return Config{}, fmt.Errorf("COURIER_RETURN_PRICE_PER_KM and COURIER_RETURN_MIN_PRICE must not be negative")
+	}
+	if cfg.ReturnOrderPercent < 0 || cfg.ReturnOrderPercent > 100 {
+		return Config{}, fmt.Errorf("COURIER_RETURN_ORDER_PERCENT must be between 0 and 100")
+	}
+	if cfg.RecipientFreeWait < 0 || cfg.RecipientWaitPerMinute < 0 {
+		return Config{}, fmt.Errorf("COURIER_RECIPIENT_FREE_WAIT_MINUTES and COURIER_RECIPIENT_WAIT_PER_MINUTE must not be negative")
+	}
+	if cfg.MerchantKeyGrace < 0 {
+		return Config{}, fmt.Errorf("COURIER_MERCHANT_KEY_GRACE_HOURS must not be negative")
+	}
+	if cfg.InsuranceFeePercent < 0 || cfg.InsuranceFeePercent > 100 {
+		return Config{}, fmt.Errorf("COURIER_INSURANCE_FEE_PERCENT must be between 0 and 100")
+	}
+	if cfg.InsuranceMinFee < 0 || cfg.InsuranceCapBasic < 0 || cfg.InsuranceCapVerified < 0 || cfg.InsuranceCapTrusted < 0 {
+		return Config{}, fmt.Errorf("COURIER_INSURANCE_MIN_FEE and COURIER_INSURANCE_CAP_* must not be negative")
+	}
+	if cfg.RecurringLead <= 0 || cfg.RecurringLead > 7*24*time.Hour {
+		return Config{}, fmt.Errorf("COURIER_RECURRING_LEAD_MINUTES must be positive and at most a week")
+	}
 
 	return cfg, nil
 }
@@ -7,7 +7,10 @@ import (
 
 	"github.com/redis/go-redis/v9"
 
+	courierhttp "naimuBack/internal/courier/http"
 	"naimuBack/internal/courier/ws"
+	"naimuBack/internal/liveops"
+	taxigeo "naimuBack/internal/taxi/geo"
 )
 
 // Logger is the minimal logging interface required by the courier module.
@@ -25,7 +28,15 @@ type Deps struct {
 	HTTPClient *http.Client
 	CourierHub *ws.CourierHub
 	SenderHub  *ws.SenderHub
-	module     *moduleState
+	// Refunder returns card payments (AirbaPay); refunds are unavailable when nil.
+	Refunder courierhttp.Refunder
+	// SMS sends recipient PINs for proof of delivery; senders pass the PIN on when nil.
+	SMS courierhttp.SMSSender
+	// Router computes the courier's live ETA and route geometry; both are off when nil.
+	Router taxigeo.GeometryRouter
+	// LiveOps is the shared admin live operations map; SOS is unavailable when nil.
+	LiveOps *liveops.Hub
+	module  *moduleState
 }
 
 // Validate ensures that the deps struct contains the essentials before bootstrapping services.
@@ -13,6 +13,9 @@ type ConfigAdapter struct {
 	OfferTTL          time.Duration
 	SearchTimeout     time.Duration
 	RegionKey         string
+	BatchCapacity     int
+	// InsuranceCaps maps courier verification level to the highest declared value it may carry.
+	InsuranceCaps map[string]int
 }
 
 func (c ConfigAdapter) GetPricePerKM() int        { return c.PricePerKM }
@@ -23,6 +26,8 @@ func (c ConfigAdapter) GetSearchRadiusMax() int   { return c.SearchRadiusMax }
 func (c ConfigAdapter) GetDispatchTick() time.Duration {
 	return c.DispatchTick
 }
-func (c ConfigAdapter) GetOfferTTL() time.Duration      { return c.OfferTTL }
-func (c ConfigAdapter) GetSearchTimeout() time.Duration { return c.SearchTimeout }
-func (c ConfigAdapter) GetRegionKey() string            { return c.RegionKey }
+func (c ConfigAdapter) GetOfferTTL() time.Duration       { return c.OfferTTL }
+func (c ConfigAdapter) GetSearchTimeout() time.Duration  { return c.SearchTimeout }
+func (c ConfigAdapter) GetRegionKey() string             { return c.RegionKey }
+func (c ConfigAdapter) GetBatchCapacity() int            { return c.BatchCapacity }
+func (c ConfigAdapter) GetInsuranceCap(level string) int { return c.InsuranceCaps[level] }
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"naimuBack/internal/courier/geo"
+	"naimuBack/internal/courier/parcel"
 	"naimuBack/internal/courier/repo"
 	"naimuBack/internal/courier/ws"
 )
@@ -29,12 +30,16 @@ type Config interface {
 	GetOfferTTL() time.Duration
 	GetSearchTimeout() time.Duration
 	GetRegionKey() string
+	GetBatchCapacity() int
+	GetInsuranceCap(level string) int
 }
 
 // OrdersRepository covers minimal order operations required by dispatcher.
 type OrdersRepository interface {
 	Get(ctx context.Context, id int64) (repo.Order, error)
 	UpdateStatusCAS(ctx context.Context, orderID int64, fromStatus, toStatus string) error
+	AssignCourierCAS(ctx context.Context, orderID, courierID int64, current, next string, limit repo.CourierLimit) error
+	ActiveLoads(ctx context.Context, courierIDs []int64) (map[int64]repo.CourierLoad, error)
 }
 
 // DispatchRepository encapsulates courier dispatch state persistence.
@@ -51,6 +56,13 @@ type OffersRepository interface {
 	CreateOffer(ctx context.Context, orderID, courierID int64, price int) error
 }
 
+// CouriersRepository resolves courier vehicle types for parcel matching and verification
+// levels for declared value caps.
+type CouriersRepository interface {
+	VehicleTypes(ctx context.Context, ids []int64) (map[int64]string, error)
+	VerificationLevels(ctx context.Context, ids []int64) (map[int64]string, error)
+}
+
 // CourierNotifier dispatches offers to couriers over WebSocket.
 type CourierNotifier interface {
 	SendOffer(courierID int64, payload ws.CourierOfferPayload)
@@ -61,8 +73,19 @@ type SenderNotifier interface {
 	PushOrderEvent(senderID int64, event ws.SenderEvent)
 }
 
+// ReservationHandler is told when a reserved scheduled order is handed to its courier.
+type ReservationHandler interface {
+	ReservedOrderAssigned(orderID, courierID int64)
+}
+
+// StatusHandler is told about status changes the dispatcher makes on its own, such as a search timeout.
+type StatusHandler interface {
+	OrderStatusChanged(orderID int64)
+}
+
 type courierLocator interface {
 	Nearby(ctx context.Context, lon, lat float64, radiusMeters float64, limit int, city string) ([]geo.NearbyCourier, error)
+	NearbyBusy(ctx context.Context, lon, lat float64, radiusMeters float64, limit int, city string) ([]geo.NearbyCourier, error)
 }
 
 // Dispatcher implements periodic courier matching against nearby executors.
@@ -70,11 +93,15 @@ type Dispatcher struct {
 	orders    OrdersRepository
 	dispatch  DispatchRepository
 	offers    OffersRepository
+	couriers  CouriersRepository
 	locator   courierLocator
 	courierWS CourierNotifier
 	senderWS  SenderNotifier
 	logger    Logger
 	cfg       Config
+
+	reservations ReservationHandler
+	statuses     StatusHandler
 }
 
 // New constructs a dispatcher instance.
@@ -82,6 +109,7 @@ func New(
 	orders OrdersRepository,
 	dispatch DispatchRepository,
 	offers OffersRepository,
+	couriers CouriersRepository,
 	locator courierLocator,
 	courierWS CourierNotifier,
 	senderWS SenderNotifier, // 👈 добавили
@@ -92,6 +120,7 @@ func New(
 		orders:    orders,
 		dispatch:  dispatch,
 		offers:    offers,
+		couriers:  couriers,
 		locator:   locator,
 		courierWS: courierWS,
 		senderWS:  senderWS, // 👈 добавили
@@ -100,6 +129,16 @@ func New(
 	}
 }
 
+// SetReservationHandler attaches the listener of reserved order assignments.
+func (d *Dispatcher) SetReservationHandler(handler ReservationHandler) {
+	d.reservations = handler
+}
+
+// SetStatusHandler attaches the listener of dispatcher-made status changes.
+func (d *Dispatcher) SetStatusHandler(handler StatusHandler) {
+	d.statuses = handler
+}
+
 // Run launches the dispatcher loop until the context is cancelled.
 func (d *Dispatcher) Run(ctx context.Context) {
 	ticker := time.NewTicker(d.cfg.GetDispatchTick())
@@ -152,8 +191,17 @@ func (d *Dispatcher) processRecord(ctx context.Context, rec repo.DispatchRecord,
 		return d.dispatch.Finish(ctx, rec.OrderID)
 	}
 
+	// Заказ ко времени, забронированный курьером, сразу отдаём ему без поиска
+	if order.Schedule.ReservedCourierID.Valid {
+		return d.assignReserved(ctx, order, order.Schedule.ReservedCourierID.Int64)
+	}
+
 	// 2) Таймаут поиска — CAS → "not_found" + пуш отправителю (как в такси)
-	if timeout := d.cfg.GetSearchTimeout(); timeout > 0 && now.Sub(rec.CreatedAt) >= timeout {
+	searchStart := rec.CreatedAt
+	if rec.StartsAt.Valid && rec.StartsAt.Time.After(searchStart) {
+		searchStart = rec.StartsAt.Time
+	}
+	if timeout := d.cfg.GetSearchTimeout(); timeout > 0 && now.Sub(searchStart) >= timeout {
 		d.logger.Infof("courier dispatch: order %d timed out after %s → mark not_found", rec.OrderID, timeout)
 
 		if err := d.orders.UpdateStatusCAS(ctx, order.ID, repo.StatusNew, "not_found"); err != nil {
@@ -161,12 +209,17 @@ func (d *Dispatcher) processRecord(ctx context.Context, rec repo.DispatchRecord,
 			if !errors.Is(err, sql.ErrNoRows) {
 				return err
 			}
-		} else if d.senderWS != nil {
-			d.senderWS.PushOrderEvent(order.SenderID, ws.SenderEvent{
-				Type:    "order_status",
-				OrderID: order.ID,
-				Status:  "not_found",
-			})
+		} else {
+			if d.senderWS != nil {
+				d.senderWS.PushOrderEvent(order.SenderID, ws.SenderEvent{
+					Type:    "order_status",
+					OrderID: order.ID,
+					Status:  "not_found",
+				})
+			}
+			if d.statuses != nil {
+				d.statuses.OrderStatusChanged(order.ID)
+			}
 		}
 
 		// завершаем диспатч
@@ -185,6 +238,16 @@ func (d *Dispatcher) processRecord(ctx context.Context, rec repo.DispatchRecord,
 		d.logger.Errorf("courier dispatch: Nearby failed: %v", err)
 		return err
 	}
+	if d.cfg.GetBatchCapacity() > 1 {
+		// Занятые курьеры тоже подходят, если заказ помещается в их текущий батч.
+		busy, err := d.locator.NearbyBusy(ctx, origin.Lon, origin.Lat, float64(rec.RadiusM), 20, cityKey)
+		if err != nil {
+			d.logger.Errorf("courier dispatch: NearbyBusy failed: %v", err)
+		} else {
+			drivers = appendUniqueCouriers(drivers, busy)
+		}
+	}
+	drivers = d.filterCandidates(ctx, order, drivers)
 
 	// TTL только в payload (как у такси), без изменения репозитория
 	ttlSeconds := int(d.cfg.GetOfferTTL().Seconds())
@@ -200,6 +263,9 @@ func (d *Dispatcher) processRecord(ctx context.Context, rec repo.DispatchRecord,
 		EtaSeconds:       order.EtaSeconds,
 		ExpiresInSec:     ttlSeconds,
 		Points:           makeRoutePoints(order.Points),
+		Parcel:           makeParcel(order.Parcel),
+		CODAmount:        order.COD.Amount,
+		DeclaredValue:    order.Insurance.DeclaredValue,
 	}
 
 	// 4) Создание и отправка офферов (как в такси), но через твой имеющийся CreateOffer
@@ -300,6 +366,27 @@ func (d *Dispatcher) processRecord(ctx context.Context, rec repo.DispatchRecord,
 	return nil
 }
 
+// assignReserved hands a scheduled order to the courier who reserved it once its dispatch is due.
+func (d *Dispatcher) assignReserved(ctx context.Context, order repo.Order, courierID int64) error {
+	// бронь уже закрепила заказ за курьером, поэтому лимит пачки здесь не применяется
+	err := d.orders.AssignCourierCAS(ctx, order.ID, courierID, repo.StatusNew, repo.StatusAccepted, repo.CourierLimit{})
+	if errors.Is(err, repo.ErrNotFound) {
+		d.logger.Infof("courier dispatch: reserved order %d changed status before assignment → finish", order.ID)
+		return d.dispatch.Finish(ctx, order.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if err := d.dispatch.Finish(ctx, order.ID); err != nil {
+		return err
+	}
+	d.logger.Infof("✅ courier dispatch: reserved order %d assigned to courier %d", order.ID, courierID)
+	if d.reservations != nil {
+		d.reservations.ReservedOrderAssigned(order.ID, courierID)
+	}
+	return nil
+}
+
 // TriggerImmediate schedules an order for immediate processing.
 func (d *Dispatcher) TriggerImmediate(ctx context.Context, orderID int64) error {
 	return d.dispatch.TriggerImmediate(ctx, orderID, time.Now())
@@ -308,7 +395,92 @@ func (d *Dispatcher) TriggerImmediate(ctx context.Context, orderID int64) error
 func makeRoutePoints(points []repo.OrderPoint) []ws.CourierRoutePoint {
 	res := make([]ws.CourierRoutePoint, 0, len(points))
 	for _, p := range points {
-		res = append(res, ws.CourierRoutePoint{Seq: p.Seq, Address: p.Address, Lon: p.Lon, Lat: p.Lat})
+		res = append(res, ws.CourierRoutePoint{Seq: p.Seq, Address: p.Address, Lon: p.Lon, Lat: p.Lat, LegPolyline: p.LegPolyline.String})
 	}
 	return res
 }
+
+// filterCandidates drops couriers whose vehicle cannot carry the parcel class, those whose
+// verification level does not cover the declared value and those whose current batch is full
+// by order count or by weight.
+func (d *Dispatcher) filterCandidates(ctx context.Context, order repo.Order, nearby []geo.NearbyCourier) []geo.NearbyCourier {
+	if len(nearby) == 0 {
+		return nearby
+	}
+	ids := make([]int64, len(nearby))
+	for i, c := range nearby {
+		ids[i] = c.ID
+	}
+	var vehicles map[int64]string
+	if d.couriers != nil {
+		var err error
+		if vehicles, err = d.couriers.VehicleTypes(ctx, ids); err != nil {
+			d.logger.Errorf("courier dispatch: load vehicle types for order %d failed: %v", order.ID, err)
+			vehicles = nil
+		}
+	}
+	var levels map[int64]string
+	if d.couriers != nil && order.Insurance.DeclaredValue > 0 {
+		var err error
+		if levels, err = d.couriers.VerificationLevels(ctx, ids); err != nil {
+			// без уровней не рискуем: застрахованный заказ ждёт следующего тика
+			d.logger.Errorf("courier dispatch: load verification levels for order %d failed: %v", order.ID, err)
+			return nearby[:0]
+		}
+	}
+	loads, err := d.orders.ActiveLoads(ctx, ids)
+	if err != nil {
+		d.logger.Errorf("courier dispatch: load courier loads for order %d failed: %v", order.ID, err)
+		loads = nil
+	}
+	capacity := d.cfg.GetBatchCapacity()
+	if capacity <= 0 {
+		capacity = 1
+	}
+
+	fit := nearby[:0]
+	for _, c := range nearby {
+		vehicle, known := vehicles[c.ID]
+		if vehicles != nil && !parcel.Carries(vehicle, order.Parcel.Class) {
+			continue
+		}
+		if levels != nil && order.Insurance.DeclaredValue > d.cfg.GetInsuranceCap(levels[c.ID]) {
+			continue
+		}
+		if load, ok := loads[c.ID]; ok {
+			if load.Orders >= capacity {
+				continue
+			}
+			if known && load.WeightKg+order.Parcel.WeightKg > parcel.MaxLoadKg(vehicle) {
+				continue
+			}
+		}
+		fit = append(fit, c)
+	}
+	return fit
+}
+
+// appendUniqueCouriers adds extra couriers that are not in the list yet, keeping its order.
+func appendUniqueCouriers(list, extra []geo.NearbyCourier) []geo.NearbyCourier {
+	seen := make(map[int64]bool, len(list))
+	for _, c := range list {
+		seen[c.ID] = true
+	}
+	for _, c := range extra {
+		if !seen[c.ID] {
+			seen[c.ID] = true
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+func makeParcel(p parcel.Parcel) *ws.CourierParcel {
+	return &ws.CourierParcel{
+		Class:    p.Class,
+		WeightKg: p.WeightKg,
+		LengthCm: p.LengthCm,
+		WidthCm:  p.WidthCm,
+		HeightCm: p.HeightCm,
+	}
+}
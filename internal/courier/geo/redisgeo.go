@@ -111,7 +111,15 @@ func (l *CourierLocator) GoOffline(ctx context.Context, courierID int64, city st
 
 // Nearby returns couriers within radius sorted by distance.
 func (l *CourierLocator) Nearby(ctx context.Context, lon, lat float64, radiusMeters float64, limit int, city string) ([]NearbyCourier, error) {
-	key := redisKey(city, "free")
+	return l.nearby(ctx, redisKey(city, "free"), lon, lat, radiusMeters, limit)
+}
+
+// NearbyBusy returns busy couriers within radius; they may still take a batched order.
+func (l *CourierLocator) NearbyBusy(ctx context.Context, lon, lat float64, radiusMeters float64, limit int, city string) ([]NearbyCourier, error) {
+	return l.nearby(ctx, redisKey(city, "busy"), lon, lat, radiusMeters, limit)
+}
+
+func (l *CourierLocator) nearby(ctx context.Context, key string, lon, lat float64, radiusMeters float64, limit int) ([]NearbyCourier, error) {
 	res, err := l.rdb.GeoSearchLocation(ctx, key, &redis.GeoSearchLocationQuery{
 		GeoSearchQuery: redis.GeoSearchQuery{
 			Longitude:  lon,
@@ -0,0 +1,791 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/courier/recurring"
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+const (
+	recurringPollInterval = time.Minute
+	recurringBatchSize    = 50
+	// defaultRecurringWindow — длина окна забора, если отправитель её не указал.
+	defaultRecurringWindow = 60
+	maxRecurringWindow     = 12 * 60
+)
+
+type savedAddressInput struct {
+	Label       string  `json:"label"`
+	ContactName *string `json:"contact_name"`
+	Phone       *string `json:"phone"`
+	Address     string  `json:"address"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	Entrance    *string `json:"entrance"`
+	Apt         *string `json:"apt"`
+	Floor       *string `json:"floor"`
+	Intercom    *string `json:"intercom"`
+	Comment     *string `json:"comment"`
+}
+
+type savedAddressResponse struct {
+	ID          int64     `json:"id"`
+	Label       string    `json:"label"`
+	ContactName *string   `json:"contact_name,omitempty"`
+	Phone       *string   `json:"phone,omitempty"`
+	Address     string    `json:"address"`
+	Lat         float64   `json:"lat"`
+	Lon         float64   `json:"lon"`
+	Entrance    *string   `json:"entrance,omitempty"`
+	Apt         *string   `json:"apt,omitempty"`
+	Floor       *string   `json:"floor,omitempty"`
+	Intercom    *string   `json:"intercom,omitempty"`
+	Comment     *string   `json:"comment,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type templateInput struct {
+	Name  string             `json:"name"`
+	Order createOrderRequest `json:"order"`
+}
+
+type templateResponse struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Order     json.RawMessage `json:"order"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type recurringInput struct {
+	TemplateID int64    `json:"template_id"`
+	Frequency  string   `json:"frequency"`
+	Weekdays   []string `json:"weekdays"`
+	// Time — время забора "HH:MM" по Алматы.
+	Time          string `json:"time"`
+	WindowMinutes int    `json:"window_minutes"`
+}
+
+type recurringResponse struct {
+	ID            int64      `json:"id"`
+	TemplateID    int64      `json:"template_id"`
+	Frequency     string     `json:"frequency"`
+	Weekdays      []string   `json:"weekdays,omitempty"`
+	Time          string     `json:"time"`
+	WindowMinutes int        `json:"window_minutes"`
+	Status        string     `json:"status"`
+	NextPickupAt  *time.Time `json:"next_pickup_at,omitempty"`
+	LastOrderID   *int64     `json:"last_order_id,omitempty"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	LastError     *string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func makeSavedAddressResponse(a repo.SavedAddress) savedAddressResponse {
+	return savedAddressResponse{
+		ID:          a.ID,
+		Label:       a.Label,
+		ContactName: nullToPtr(a.ContactName),
+		Phone:       nullToPtr(a.Phone),
+		Address:     a.Address,
+		Lat:         a.Lat,
+		Lon:         a.Lon,
+		Entrance:    nullToPtr(a.Entrance),
+		Apt:         nullToPtr(a.Apt),
+		Floor:       nullToPtr(a.Floor),
+		Intercom:    nullToPtr(a.Intercom),
+		Comment:     nullToPtr(a.Comment),
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+}
+
+func makeTemplateResponse(t repo.OrderTemplate) templateResponse {
+	return templateResponse{ID: t.ID, Name: t.Name, Order: json.RawMessage(t.Request), CreatedAt: t.CreatedAt, UpdatedAt: t.UpdatedAt}
+}
+
+func makeRecurringResponse(d repo.RecurringDelivery) recurringResponse {
+	resp := recurringResponse{
+		ID:            d.ID,
+		TemplateID:    d.TemplateID,
+		Frequency:     d.Frequency,
+		Time:          recurring.FormatClock(d.PickupMinute),
+		WindowMinutes: d.WindowMinutes,
+		Status:        d.Status,
+		LastError:     nullToPtr(d.LastError),
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+	}
+	if d.Frequency == recurring.Weekly {
+		resp.Weekdays = recurring.WeekdayNames(d.Weekdays)
+	}
+	if d.Status == repo.RecurringActive {
+		next := timeutil.InAlmaty(d.NextRunAt)
+		resp.NextPickupAt = &next
+	}
+	if d.LastOrderID.Valid {
+		id := d.LastOrderID.Int64
+		resp.LastOrderID = &id
+	}
+	if d.LastRunAt.Valid {
+		at := d.LastRunAt.Time
+		resp.LastRunAt = &at
+	}
+	return resp
+}
+
+func (in savedAddressInput) toSavedAddress(senderID int64) (repo.SavedAddress, error) {
+	a := repo.SavedAddress{
+		SenderID:    senderID,
+		Label:       strings.TrimSpace(in.Label),
+		ContactName: nullableString(in.ContactName),
+		Phone:       nullableString(in.Phone),
+		Address:     strings.TrimSpace(in.Address),
+		Lat:         in.Lat,
+		Lon:         in.Lon,
+		Entrance:    nullableString(in.Entrance),
+		Apt:         nullableString(in.Apt),
+		Floor:       nullableString(in.Floor),
+		Intercom:    nullableString(in.Intercom),
+		Comment:     nullableString(in.Comment),
+	}
+	switch {
+	case a.Label == "":
+		return a, errors.New("label is required")
+	case len(a.Label) > 128:
+		return a, errors.New("label must be at most 128 characters")
+	case a.Address == "":
+		return a, errors.New("address is required")
+	case a.Lat == 0 && a.Lon == 0:
+		return a, errors.New("coordinates are required")
+	}
+	return a, nil
+}
+
+// resolveSavedAddresses fills route points that refer to the address book. Fields set on the point
+// win over the saved ones, so a template can keep the contact but change e.g. the comment.
+func (s *Server) resolveSavedAddresses(ctx context.Context, senderID int64, points []orderPointInput) ([]orderPointInput, error) {
+	var ids []int64
+	for _, p := range points {
+		if p.AddressID != nil {
+			ids = append(ids, *p.AddressID)
+		}
+	}
+	if len(ids) == 0 {
+		return points, nil
+	}
+	if s.addressBook == nil {
+		return nil, orderInputErrorf("address book is not available")
+	}
+	saved, err := s.addressBook.AddressesByID(ctx, senderID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make([]orderPointInput, len(points))
+	for idx, p := range points {
+		resolved[idx] = p
+		if p.AddressID == nil {
+			continue
+		}
+		a, ok := saved[*p.AddressID]
+		if !ok {
+			return nil, orderInputErrorf("route point %d: saved address %d not found", idx, *p.AddressID)
+		}
+		if strings.TrimSpace(p.Address) == "" {
+			resolved[idx].Address = a.Address
+			resolved[idx].Lat, resolved[idx].Lon = a.Lat, a.Lon
+		}
+		fill := func(dst **string, src sql.NullString) {
+			if nullableString(*dst).Valid {
+				return
+			}
+			*dst = nullToPtr(src)
+		}
+		fill(&resolved[idx].Entrance, a.Entrance)
+		fill(&resolved[idx].Apt, a.Apt)
+		fill(&resolved[idx].Floor, a.Floor)
+		fill(&resolved[idx].Intercom, a.Intercom)
+		fill(&resolved[idx].Phone, a.Phone)
+		fill(&resolved[idx].Comment, a.Comment)
+	}
+	return resolved, nil
+}
+
+func (s *Server) handleAddresses(w http.ResponseWriter, r *http.Request) {
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		items, err := s.addressBook.ListAddresses(ctx, senderID)
+		if err != nil {
+			s.logger.Errorf("courier: list addresses failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to list addresses")
+			return
+		}
+		resp := make([]savedAddressResponse, 0, len(items))
+		for _, a := range items {
+			resp = append(resp, makeSavedAddressResponse(a))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"addresses": resp})
+	case http.MethodPost:
+		var in savedAddressInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		a, err := in.toSavedAddress(senderID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		id, err := s.addressBook.CreateAddress(ctx, a)
+		if err != nil {
+			s.logger.Errorf("courier: create address failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to save address")
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"id": id})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleAddressRoutes(w http.ResponseWriter, r *http.Request) {
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	id, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/courier/addresses/"), "/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid address id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodPut:
+		var in savedAddressInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		a, err := in.toSavedAddress(senderID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		a.ID = id
+		err = s.addressBook.UpdateAddress(ctx, a)
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "address not found")
+			return
+		}
+		if err != nil {
+			s.logger.Errorf("courier: update address %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to save address")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": id})
+	case http.MethodDelete:
+		err := s.addressBook.DeleteAddress(ctx, senderID, id)
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "address not found")
+			return
+		}
+		if err != nil {
+			s.logger.Errorf("courier: delete address %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to delete address")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// templateRequest validates the order of a template and returns it as stored. Windows and the
+// external id belong to a single order and are dropped; saved addresses stay referenced so later
+// edits of the address book reach the next orders.
+func (s *Server) templateRequest(ctx context.Context, senderID int64, in templateInput) (string, []byte, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return "", nil, orderInputErrorf("name is required")
+	}
+	if len(name) > 128 {
+		return "", nil, orderInputErrorf("name must be at most 128 characters")
+	}
+	req := in.Order
+	req.PickupWindow, req.DropoffWindow, req.ExternalID = nil, nil, nil
+	if _, err := s.buildOrder(ctx, senderID, req, timeutil.Now()); err != nil {
+		return "", nil, err
+	}
+	body, err := json.Marshal(req)
+	return name, body, err
+}
+
+func (s *Server) writeOrderInputError(w http.ResponseWriter, err error, action string) {
+	var inputErr orderInputError
+	if errors.As(err, &inputErr) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.logger.Errorf("courier: %s failed: %v", action, err)
+	writeError(w, http.StatusInternalServerError, "failed to "+action)
+}
+
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		items, err := s.addressBook.ListTemplates(ctx, senderID)
+		if err != nil {
+			s.logger.Errorf("courier: list templates failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to list templates")
+			return
+		}
+		resp := make([]templateResponse, 0, len(items))
+		for _, t := range items {
+			resp = append(resp, makeTemplateResponse(t))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"templates": resp})
+	case http.MethodPost:
+		var in templateInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		name, body, err := s.templateRequest(ctx, senderID, in)
+		if err != nil {
+			s.writeOrderInputError(w, err, "save template")
+			return
+		}
+		id, err := s.addressBook.CreateTemplate(ctx, repo.OrderTemplate{SenderID: senderID, Name: name, Request: body})
+		if err != nil {
+			s.logger.Errorf("courier: create template failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to save template")
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"id": id})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleTemplateRoutes(w http.ResponseWriter, r *http.Request) {
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/courier/templates/"), "/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		t, err := s.addressBook.GetTemplate(ctx, senderID, id)
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "template not found")
+			return
+		}
+		if err != nil {
+			s.logger.Errorf("courier: load template %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to load template")
+			return
+		}
+		writeJSON(w, http.StatusOK, makeTemplateResponse(t))
+	case len(parts) == 1 && r.Method == http.MethodPut:
+		var in templateInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		name, body, err := s.templateRequest(ctx, senderID, in)
+		if err != nil {
+			s.writeOrderInputError(w, err, "save template")
+			return
+		}
+		err = s.addressBook.UpdateTemplate(ctx, repo.OrderTemplate{ID: id, SenderID: senderID, Name: name, Request: body})
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "template not found")
+			return
+		}
+		if err != nil {
+			s.logger.Errorf("courier: update template %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to save template")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": id})
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		err := s.addressBook.DeleteTemplate(ctx, senderID, id)
+		switch {
+		case errors.Is(err, repo.ErrNotFound):
+			writeError(w, http.StatusNotFound, "template not found")
+		case errors.Is(err, repo.ErrTemplateInUse):
+			writeError(w, http.StatusConflict, "template is used by recurring deliveries")
+		case err != nil:
+			s.logger.Errorf("courier: delete template %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to delete template")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case len(parts) == 2 && parts[1] == "order" && r.Method == http.MethodPost:
+		s.orderFromTemplate(w, r, senderID, id)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// orderFromTemplate places an order from the template; the body may add windows for this order.
+func (s *Server) orderFromTemplate(w http.ResponseWriter, r *http.Request, senderID, templateID int64) {
+	var in struct {
+		PickupWindow  *windowInput `json:"pickup_window"`
+		DropoffWindow *windowInput `json:"dropoff_window"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	ctx = withSenderActor(ctx, senderID)
+
+	t, err := s.addressBook.GetTemplate(ctx, senderID, templateID)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "template not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load template %d failed: %v", templateID, err)
+		writeError(w, http.StatusInternalServerError, "failed to create order")
+		return
+	}
+	var req createOrderRequest
+	if err := json.Unmarshal(t.Request, &req); err != nil {
+		s.logger.Errorf("courier: decode template %d failed: %v", templateID, err)
+		writeError(w, http.StatusInternalServerError, "failed to create order")
+		return
+	}
+	req.PickupWindow, req.DropoffWindow = in.PickupWindow, in.DropoffWindow
+
+	order, err := s.buildOrder(ctx, senderID, req, timeutil.Now())
+	if err != nil {
+		s.writeOrderInputError(w, err, "create order")
+		return
+	}
+	orderID, dispatchAt, err := s.placeOrder(ctx, order)
+	if err != nil {
+		s.logger.Errorf("courier: create order from template %d failed: %v", templateID, err)
+		writeError(w, http.StatusInternalServerError, "failed to create order")
+		return
+	}
+	writeJSON(w, http.StatusCreated, createdOrderResponse(orderID, order, dispatchAt))
+	s.emitOrderEvent(ctx, orderID, orderEventTypeCreated, originSender)
+	s.sendRecipientPINs(orderID)
+}
+
+func recurringRule(d repo.RecurringDelivery) recurring.Rule {
+	return recurring.Rule{Frequency: d.Frequency, Weekdays: d.Weekdays, Minute: d.PickupMinute}
+}
+
+// toRecurring validates the schedule; the first pickup is the next one after now.
+func (in recurringInput) toRecurring(senderID int64, now time.Time) (repo.RecurringDelivery, error) {
+	d := repo.RecurringDelivery{
+		SenderID:      senderID,
+		TemplateID:    in.TemplateID,
+		Frequency:     strings.ToLower(strings.TrimSpace(in.Frequency)),
+		WindowMinutes: in.WindowMinutes,
+		Status:        repo.RecurringActive,
+	}
+	if d.TemplateID <= 0 {
+		return d, errors.New("template_id is required")
+	}
+	var err error
+	if d.PickupMinute, err = recurring.ParseClock(in.Time); err != nil {
+		return d, err
+	}
+	if d.Frequency == recurring.Weekly {
+		if d.Weekdays, err = recurring.ParseWeekdays(in.Weekdays); err != nil {
+			return d, err
+		}
+	}
+	if d.WindowMinutes == 0 {
+		d.WindowMinutes = defaultRecurringWindow
+	}
+	if d.WindowMinutes < int(minWindowLength/time.Minute) || d.WindowMinutes > maxRecurringWindow {
+		return d, errors.New("window_minutes must be between 15 and 720")
+	}
+	rule := recurringRule(d)
+	if err := rule.Validate(); err != nil {
+		return d, err
+	}
+	d.NextRunAt = rule.Next(timeutil.InAlmaty(now))
+	return d, nil
+}
+
+func (s *Server) handleRecurring(w http.ResponseWriter, r *http.Request) {
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		items, err := s.addressBook.ListRecurring(ctx, senderID)
+		if err != nil {
+			s.logger.Errorf("courier: list recurring deliveries failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to list recurring deliveries")
+			return
+		}
+		resp := make([]recurringResponse, 0, len(items))
+		for _, d := range items {
+			resp = append(resp, makeRecurringResponse(d))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"recurring": resp})
+	case http.MethodPost:
+		var in recurringInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		d, err := in.toRecurring(senderID, timeutil.Now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		id, err := s.addressBook.CreateRecurring(ctx, d)
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, http.StatusBadRequest, "template not found")
+			return
+		}
+		if err != nil {
+			s.logger.Errorf("courier: create recurring delivery failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to save recurring delivery")
+			return
+		}
+		created, err := s.addressBook.GetRecurring(ctx, senderID, id)
+		if err != nil {
+			s.logger.Errorf("courier: load recurring delivery %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to load recurring delivery")
+			return
+		}
+		writeJSON(w, http.StatusCreated, makeRecurringResponse(created))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleRecurringRoutes(w http.ResponseWriter, r *http.Request) {
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/courier/recurring/"), "/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid recurring delivery id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	current, err := s.addressBook.GetRecurring(ctx, senderID, id)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "recurring delivery not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load recurring delivery %d failed: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "failed to load recurring delivery")
+		return
+	}
+
+	now := timeutil.Now()
+	next := current
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, makeRecurringResponse(current))
+		return
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		if err := s.addressBook.DeleteRecurring(ctx, senderID, id); err != nil && !errors.Is(err, repo.ErrNotFound) {
+			s.logger.Errorf("courier: delete recurring delivery %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to delete recurring delivery")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case len(parts) == 1 && r.Method == http.MethodPut:
+		var in recurringInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if next, err = in.toRecurring(senderID, now); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		next.ID, next.Status = id, current.Status
+	case len(parts) == 2 && parts[1] == "pause" && r.Method == http.MethodPost:
+		next.Status = repo.RecurringPaused
+	case len(parts) == 2 && parts[1] == "resume" && r.Method == http.MethodPost:
+		if current.Status == repo.RecurringActive {
+			writeJSON(w, http.StatusOK, makeRecurringResponse(current))
+			return
+		}
+		// Пропущенные на паузе забора не догоняются: продолжаем со следующего.
+		next.Status = repo.RecurringActive
+		next.NextRunAt = recurringRule(current).Next(now)
+	case len(parts) == 2 && parts[1] == "skip" && r.Method == http.MethodPost:
+		if current.Status != repo.RecurringActive {
+			writeError(w, http.StatusConflict, "recurring delivery is paused")
+			return
+		}
+		next.NextRunAt = recurringRule(current).Next(timeutil.InAlmaty(current.NextRunAt))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	err = s.addressBook.UpdateRecurring(ctx, next)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "recurring delivery or template not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: update recurring delivery %d failed: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "failed to save recurring delivery")
+		return
+	}
+	updated, err := s.addressBook.GetRecurring(ctx, senderID, id)
+	if err != nil {
+		s.logger.Errorf("courier: load recurring delivery %d failed: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "failed to load recurring delivery")
+		return
+	}
+	writeJSON(w, http.StatusOK, makeRecurringResponse(updated))
+}
+
+// RunRecurringDeliveries places orders of recurring deliveries RecurringLead before their pickup
+// until ctx is cancelled.
+func (s *Server) RunRecurringDeliveries(ctx context.Context) {
+	if s.addressBook == nil {
+		return
+	}
+	ticker := time.NewTicker(recurringPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runDueRecurring(ctx)
+		}
+	}
+}
+
+func (s *Server) runDueRecurring(ctx context.Context) {
+	now := timeutil.Now()
+	due, err := s.addressBook.DueRecurring(ctx, now.Add(s.cfg.RecurringLead), recurringBatchSize)
+	if err != nil {
+		s.logger.Errorf("courier: load due recurring deliveries failed: %v", err)
+		return
+	}
+	for _, d := range due {
+		occurrence := timeutil.InAlmaty(d.NextRunAt)
+		rule := recurringRule(d)
+		next := rule.Next(occurrence)
+		if !next.After(now) {
+			next = rule.Next(now)
+		}
+		claimed, err := s.addressBook.ClaimRecurringRun(ctx, d.ID, d.NextRunAt, next, now)
+		if err != nil {
+			s.logger.Errorf("courier: claim recurring delivery %d failed: %v", d.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		var (
+			orderID sql.NullInt64
+			reason  sql.NullString
+		)
+		if id, err := s.placeRecurringOrder(ctx, d, occurrence, now); err != nil {
+			s.logger.Errorf("courier: recurring delivery %d order failed: %v", d.ID, err)
+			reason = sql.NullString{String: err.Error(), Valid: true}
+		} else {
+			orderID = sql.NullInt64{Int64: id, Valid: true}
+		}
+		if err := s.addressBook.RecordRecurringResult(ctx, d.ID, orderID, reason); err != nil {
+			s.logger.Errorf("courier: record recurring delivery %d result failed: %v", d.ID, err)
+		}
+	}
+}
+
+// placeRecurringOrder creates the order of one pickup with its window starting at the occurrence.
+func (s *Server) placeRecurringOrder(ctx context.Context, d repo.RecurringDelivery, occurrence, now time.Time) (int64, error) {
+	if !occurrence.After(now) {
+		return 0, errors.New("pickup time passed before the order was placed")
+	}
+	t, err := s.addressBook.GetTemplate(ctx, d.SenderID, d.TemplateID)
+	if err != nil {
+		return 0, err
+	}
+	var req createOrderRequest
+	if err := json.Unmarshal(t.Request, &req); err != nil {
+		return 0, err
+	}
+	to := occurrence.Add(time.Duration(d.WindowMinutes) * time.Minute)
+	req.PickupWindow = &windowInput{From: &occurrence, To: &to}
+	req.DropoffWindow, req.ExternalID = nil, nil
+
+	ctx = withSenderActor(ctx, d.SenderID)
+	order, err := s.buildOrder(ctx, d.SenderID, req, now)
+	if err != nil {
+		return 0, err
+	}
+	orderID, _, err := s.placeOrder(ctx, order)
+	if err != nil {
+		return 0, err
+	}
+	s.emitOrderEvent(ctx, orderID, orderEventTypeCreated, originUnknown)
+	s.sendRecipientPINs(orderID)
+	return orderID, nil
+}
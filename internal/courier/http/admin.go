@@ -139,6 +139,12 @@ func (s *Server) handleAdminCourierActions(w http.ResponseWriter, r *http.Reques
 		s.handleAdminCourierBan(w, r, id)
 	case "approval":
 		s.handleAdminCourierApproval(w, r, id)
+	case "verification":
+		s.handleAdminCourierVerification(w, r, id)
+	case "cod":
+		s.handleAdminCourierCOD(w, r, id)
+	case "cod_deduction":
+		s.handleAdminCourierCODDeduction(w, r, id)
 	default:
 		w.WriteHeader(http.StatusNotFound)
 	}
@@ -0,0 +1,290 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"naimuBack/internal/courier/lifecycle"
+	"naimuBack/internal/courier/parcel"
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/courier/routeplan"
+	taxigeo "naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+var errCourierAtCapacity = repo.ErrCourierAtCapacity
+
+type routeStopResponse struct {
+	Position   int     `json:"position"`
+	OrderID    int64   `json:"order_id"`
+	PointID    int64   `json:"point_id"`
+	Kind       string  `json:"kind"`
+	Address    string  `json:"address"`
+	Lat        float64 `json:"lat"`
+	Lon        float64 `json:"lon"`
+	Entrance   *string `json:"entrance,omitempty"`
+	Apt        *string `json:"apt,omitempty"`
+	Floor      *string `json:"floor,omitempty"`
+	Comment    *string `json:"comment,omitempty"`
+	EtaSeconds int     `json:"eta_s"`
+}
+
+// courierCanTake checks the vehicle against the parcel and the courier's batch capacity:
+// the number of active orders and their combined weight. It is a fast precheck; the assignment
+// repeats the capacity check inside its transaction with the returned limit.
+func (s *Server) courierCanTake(ctx context.Context, courierID int64, order repo.Order) (repo.CourierLimit, error) {
+	if err := s.courierCarries(ctx, courierID, order); err != nil {
+		return repo.CourierLimit{}, err
+	}
+	limit, err := s.courierLimit(ctx, courierID)
+	if err != nil {
+		return repo.CourierLimit{}, err
+	}
+	loads, err := s.orders.ActiveLoads(ctx, []int64{courierID})
+	if err != nil {
+		return repo.CourierLimit{}, err
+	}
+	if !limit.Fits(loads[courierID], order.Parcel.WeightKg) {
+		return repo.CourierLimit{}, errCourierAtCapacity
+	}
+	return limit, nil
+}
+
+// courierLimit returns the batch limit of the courier: BatchCapacity orders and the vehicle's max load.
+func (s *Server) courierLimit(ctx context.Context, courierID int64) (repo.CourierLimit, error) {
+	limit := repo.CourierLimit{MaxOrders: s.cfg.BatchCapacity}
+	if limit.MaxOrders <= 0 {
+		limit.MaxOrders = 1
+	}
+	if s.couriers != nil {
+		courier, err := s.couriers.Get(ctx, courierID)
+		if err != nil {
+			return repo.CourierLimit{}, err
+		}
+		limit.MaxWeightKg = parcel.MaxLoadKg(courier.VehicleType)
+	}
+	return limit, nil
+}
+
+// checkCourierCapacity writes 409 when the courier cannot take the order on top of the current batch.
+// The returned limit is passed to the assignment so the check is repeated under row locks.
+func (s *Server) checkCourierCapacity(ctx context.Context, w http.ResponseWriter, orderID, courierID int64) (repo.CourierLimit, bool) {
+	order, err := s.orders.Get(ctx, orderID)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "order not found")
+		return repo.CourierLimit{}, false
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load order %d for capacity check failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return repo.CourierLimit{}, false
+	}
+	limit, err := s.courierCanTake(ctx, courierID, order)
+	if err != nil {
+		if errors.Is(err, errCourierAtCapacity) || errors.Is(err, errVehicleCannotCarry) || errors.Is(err, errDeclaredValueOverCap) {
+			writeError(w, http.StatusConflict, err.Error())
+			return repo.CourierLimit{}, false
+		}
+		s.logger.Errorf("courier: check capacity of courier %d failed: %v", courierID, err)
+		writeError(w, http.StatusInternalServerError, "failed to check courier capacity")
+		return repo.CourierLimit{}, false
+	}
+	return limit, true
+}
+
+// openRouteStops lists what is left to visit: pickups of orders not yet picked up,
+// drop-offs without delivery proof and the way back for returned parcels.
+func (s *Server) openRouteStops(ctx context.Context, orders []repo.Order) ([]routeplan.Stop, map[int64]repo.OrderPoint, error) {
+	stops := make([]routeplan.Stop, 0)
+	points := make(map[int64]repo.OrderPoint)
+	for _, o := range orders {
+		if len(o.Points) == 0 {
+			continue
+		}
+		proofs, err := s.orders.ListDeliveryProofs(ctx, o.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		delivered := make(map[int64]bool, len(proofs))
+		for _, p := range proofs {
+			delivered[p.PointID] = p.DeliveredAt.Valid
+		}
+		if o.Status == lifecycle.StatusReturning {
+			if !o.Return.ArrivedAt.Valid {
+				p := o.Points[0]
+				stops = append(stops, routeplan.Stop{OrderID: o.ID, PointID: p.ID, Kind: routeplan.KindReturn, Lon: p.Lon, Lat: p.Lat})
+				points[p.ID] = p
+			}
+			continue
+		}
+		for i, p := range o.Points {
+			kind := routeplan.KindDropoff
+			if i == 0 {
+				if o.Status != lifecycle.StatusAccepted && o.Status != lifecycle.StatusWaitingFree {
+					continue
+				}
+				kind = routeplan.KindPickup
+			} else if delivered[p.ID] {
+				continue
+			}
+			stops = append(stops, routeplan.Stop{OrderID: o.ID, PointID: p.ID, Kind: kind, Lon: p.Lon, Lat: p.Lat})
+			points[p.ID] = p
+		}
+	}
+	return stops, points, nil
+}
+
+// planCourierRoute optimises the combined route over all active orders and stores it.
+func (s *Server) planCourierRoute(ctx context.Context, courierID int64, stops []routeplan.Stop) ([]repo.RouteStop, error) {
+	if len(stops) == 0 {
+		return []repo.RouteStop{}, s.orders.ReplaceRoutePlan(ctx, courierID, nil, timeutil.Now())
+	}
+	startLon, startLat := stops[0].Lon, stops[0].Lat
+	if s.cHub != nil {
+		if pos, ok := s.cHub.Position(courierID); ok {
+			startLon, startLat = pos.Lon, pos.Lat
+		}
+	}
+	var router taxigeo.Router
+	if s.router != nil {
+		router = s.router
+	}
+	planned := routeplan.Plan(ctx, router, startLon, startLat, stops)
+
+	now := timeutil.Now()
+	plan := make([]repo.RouteStop, 0, len(planned))
+	for i, p := range planned {
+		plan = append(plan, repo.RouteStop{Position: i, OrderID: p.OrderID, PointID: p.PointID, Kind: p.Kind, EtaSeconds: p.EtaSeconds, PlannedAt: now})
+	}
+	if err := s.orders.ReplaceRoutePlan(ctx, courierID, plan, now); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// replanCourierRoute rebuilds the route after the courier took one more order and pushes it.
+func (s *Server) replanCourierRoute(courierID int64) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		orders, err := s.orders.ListActiveByCourier(ctx, courierID)
+		if err != nil {
+			s.logger.Errorf("courier: load active orders of courier %d for route failed: %v", courierID, err)
+			return
+		}
+		stops, points, err := s.openRouteStops(ctx, orders)
+		if err != nil {
+			s.logger.Errorf("courier: collect route stops of courier %d failed: %v", courierID, err)
+			return
+		}
+		plan, err := s.planCourierRoute(ctx, courierID, stops)
+		if err != nil {
+			s.logger.Errorf("courier: plan route of courier %d failed: %v", courierID, err)
+			return
+		}
+		s.flagLateOrders(ctx, orders, plan)
+		if s.cHub != nil {
+			s.cHub.Push(courierID, map[string]interface{}{
+				"type":  "route_updated",
+				"stops": makeRouteStopResponses(plan, points),
+			})
+		}
+	}()
+}
+
+// handleCourierRoute returns the combined route over every active order of the courier.
+// A stored plan is reused while it still covers all open stops; ?refresh=1 re-optimises.
+func (s *Server) handleCourierRoute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	courierID, err := parseAuthID(r, "X-Courier-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing courier id")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	orders, err := s.orders.ListActiveByCourier(ctx, courierID)
+	if err != nil {
+		s.logger.Errorf("courier: load active orders of courier %d failed: %v", courierID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load active orders")
+		return
+	}
+	stops, points, err := s.openRouteStops(ctx, orders)
+	if err != nil {
+		s.logger.Errorf("courier: collect route stops of courier %d failed: %v", courierID, err)
+		writeError(w, http.StatusInternalServerError, "failed to build route")
+		return
+	}
+
+	var plan []repo.RouteStop
+	if r.URL.Query().Get("refresh") == "" {
+		stored, err := s.orders.RoutePlan(ctx, courierID)
+		if err != nil {
+			s.logger.Errorf("courier: load route plan of courier %d failed: %v", courierID, err)
+		} else {
+			plan = coveringPlan(stored, stops)
+		}
+	}
+	if plan == nil {
+		if plan, err = s.planCourierRoute(ctx, courierID, stops); err != nil {
+			s.logger.Errorf("courier: plan route of courier %d failed: %v", courierID, err)
+			writeError(w, http.StatusInternalServerError, "failed to plan route")
+			return
+		}
+	}
+
+	views := make([]orderResponse, 0, len(orders))
+	for _, o := range orders {
+		views = append(views, s.orderView(o))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"orders": views,
+		"stops":  makeRouteStopResponses(plan, points),
+	})
+}
+
+// coveringPlan keeps stored stops that are still open; nil when some open stop is missing.
+func coveringPlan(stored []repo.RouteStop, open []routeplan.Stop) []repo.RouteStop {
+	isOpen := make(map[int64]bool, len(open))
+	for _, s := range open {
+		isOpen[s.PointID] = true
+	}
+	plan := make([]repo.RouteStop, 0, len(open))
+	for _, s := range stored {
+		if isOpen[s.PointID] {
+			plan = append(plan, s)
+		}
+	}
+	if len(plan) != len(open) {
+		return nil
+	}
+	return plan
+}
+
+func makeRouteStopResponses(plan []repo.RouteStop, points map[int64]repo.OrderPoint) []routeStopResponse {
+	resp := make([]routeStopResponse, 0, len(plan))
+	for i, stop := range plan {
+		p := points[stop.PointID]
+		resp = append(resp, routeStopResponse{
+			Position:   i,
+			OrderID:    stop.OrderID,
+			PointID:    stop.PointID,
+			Kind:       stop.Kind,
+			Address:    p.Address,
+			Lat:        p.Lat,
+			Lon:        p.Lon,
+			Entrance:   nullToPtr(p.Entrance),
+			Apt:        nullToPtr(p.Apt),
+			Floor:      nullToPtr(p.Floor),
+			Comment:    nullToPtr(p.Comment),
+			EtaSeconds: stop.EtaSeconds,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,196 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"naimuBack/internal/chat"
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+type chatResponse struct {
+	OrderID  int64          `json:"order_id"`
+	Open     bool           `json:"open"`
+	Presets  []chat.Preset  `json:"presets"`
+	Messages []chat.Message `json:"messages"`
+}
+
+type chatMessageRequest struct {
+	Text   string `json:"text"`
+	Preset string `json:"preset"`
+}
+
+// chatOpen — чат доступен с момента назначения курьера до завершения доставки или возврата.
+func chatOpen(status string) bool {
+	switch status {
+	case repo.StatusAccepted, repo.StatusWaitingFree, repo.StatusInProgress,
+		repo.StatusDeliveryAttempted, repo.StatusWaitingRecipient, repo.StatusReturning:
+		return true
+	}
+	return false
+}
+
+func chatFinished(status string) bool {
+	switch status {
+	case repo.StatusCompleted, repo.StatusClosed, repo.StatusCanceledBySender, repo.StatusCanceledByCourier, repo.StatusCanceledNoShow,
+		repo.StatusReturned:
+		return true
+	}
+	return false
+}
+
+func chatParticipant(order repo.Order, role string, actorID int64) bool {
+	switch role {
+	case chat.RoleSender:
+		return order.SenderID == actorID
+	case chat.RoleCourier:
+		return order.CourierID.Valid && order.CourierID.Int64 == actorID
+	}
+	return false
+}
+
+// orderView is the order as seen by its sender or courier: phones are hidden when they talk through the chat.
+func (s *Server) orderView(o repo.Order) orderResponse {
+	resp := makeOrderResponse(o)
+	if s.cfg.ChatHidePhones {
+		if resp.Sender != nil {
+			resp.Sender.Phone = ""
+		}
+		if resp.Courier != nil {
+			resp.Courier.Phone = ""
+		}
+	}
+	return resp
+}
+
+func (s *Server) pushChatEvent(order repo.Order, evt chat.Event) {
+	if s.sHub != nil {
+		s.sHub.Push(order.SenderID, evt)
+	}
+	if s.cHub != nil && order.CourierID.Valid {
+		s.cHub.Push(order.CourierID.Int64, evt)
+	}
+}
+
+func (s *Server) postChatMessage(ctx context.Context, orderID int64, role string, actorID int64, text, preset string) (chat.Message, error) {
+	order, err := s.orders.Get(ctx, orderID)
+	if err != nil {
+		return chat.Message{}, err
+	}
+	if !chatParticipant(order, role, actorID) {
+		return chat.Message{}, chat.ErrNotParticipant
+	}
+	if !chatOpen(order.Status) {
+		return chat.Message{}, chat.ErrChatClosed
+	}
+	body, err := chat.Compose(role, text, preset)
+	if err != nil {
+		return chat.Message{}, err
+	}
+	msg, err := s.chatRepo.Create(ctx, chat.Message{
+		Service:    chat.ServiceCourier,
+		OrderID:    order.ID,
+		SenderRole: role,
+		SenderID:   actorID,
+		Body:       body,
+		Preset:     strings.TrimSpace(preset),
+		CreatedAt:  timeutil.Now(),
+	})
+	if err != nil {
+		return chat.Message{}, err
+	}
+	s.pushChatEvent(order, chat.Event{Type: chat.TypeMessage, OrderID: order.ID, Message: &msg})
+	return msg, nil
+}
+
+// ChatMessage handles chat messages sent over sender and courier WebSocket.
+func (s *Server) ChatMessage(ctx context.Context, role string, senderID int64, in chat.Incoming) error {
+	_, err := s.postChatMessage(ctx, in.OrderID, role, senderID, in.Text, in.Preset)
+	if errors.Is(err, repo.ErrNotFound) {
+		return chat.ErrNotParticipant
+	}
+	if err != nil && !chat.IsClientError(err) {
+		s.logger.Errorf("courier: chat order %d message from %s %d failed: %v", in.OrderID, role, senderID, err)
+	}
+	return err
+}
+
+// closeOrderChat tells both parties the chat is closed once the order is finished.
+func (s *Server) closeOrderChat(order repo.Order) {
+	if !chatFinished(order.Status) || !order.CourierID.Valid {
+		return
+	}
+	s.pushChatEvent(order, chat.Event{Type: chat.TypeClosed, OrderID: order.ID})
+}
+
+func (s *Server) handleOrderChat(w http.ResponseWriter, r *http.Request, orderID int64, role string) {
+	header := "X-Sender-ID"
+	if role == chat.RoleCourier {
+		header = "X-Courier-ID"
+	}
+	actorID, err := parseAuthID(r, header)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing "+role+" id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		order, err := s.orders.Get(ctx, orderID)
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "order not found")
+			return
+		}
+		if err != nil {
+			s.logger.Errorf("courier: load order %d for chat failed: %v", orderID, err)
+			writeError(w, http.StatusInternalServerError, "failed to load order")
+			return
+		}
+		if !chatParticipant(order, role, actorID) {
+			writeError(w, http.StatusForbidden, chat.ErrNotParticipant.Error())
+			return
+		}
+		messages, err := s.chatRepo.List(ctx, chat.ServiceCourier, orderID)
+		if err != nil {
+			s.logger.Errorf("courier: list chat of order %d failed: %v", orderID, err)
+			writeError(w, http.StatusInternalServerError, "failed to load messages")
+			return
+		}
+		writeJSON(w, http.StatusOK, chatResponse{
+			OrderID:  orderID,
+			Open:     chatOpen(order.Status),
+			Presets:  chat.Presets(role),
+			Messages: messages,
+		})
+	case http.MethodPost:
+		var req chatMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		msg, err := s.postChatMessage(ctx, orderID, role, actorID, req.Text, req.Preset)
+		switch {
+		case err == nil:
+			writeJSON(w, http.StatusCreated, msg)
+		case errors.Is(err, repo.ErrNotFound):
+			writeError(w, http.StatusNotFound, "order not found")
+		case errors.Is(err, chat.ErrNotParticipant):
+			writeError(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, chat.ErrChatClosed):
+			writeError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, chat.ErrEmptyMessage), errors.Is(err, chat.ErrMessageTooLong), errors.Is(err, chat.ErrUnknownPreset):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			s.logger.Errorf("courier: chat order %d message from %s %d failed: %v", orderID, role, actorID, err)
+			writeError(w, http.StatusInternalServerError, "failed to send message")
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
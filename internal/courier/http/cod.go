@@ -0,0 +1,521 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/courier/lifecycle"
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+type codResponse struct {
+	Amount      int        `json:"amount"`
+	Collected   *int       `json:"collected,omitempty"`
+	CollectedAt *time.Time `json:"collected_at,omitempty"`
+}
+
+func makeCODResponse(c repo.COD) *codResponse {
+	if c.Amount <= 0 {
+		return nil
+	}
+	resp := &codResponse{Amount: c.Amount}
+	if c.Collected.Valid {
+		v := int(c.Collected.Int64)
+		resp.Collected = &v
+	}
+	if c.CollectedAt.Valid {
+		v := c.CollectedAt.Time
+		resp.CollectedAt = &v
+	}
+	return resp
+}
+
+type ledgerEntryResponse struct {
+	ID           int64     `json:"id"`
+	Kind         string    `json:"kind"`
+	OrderID      *int64    `json:"order_id,omitempty"`
+	HandInID     *int64    `json:"handin_id,omitempty"`
+	BalanceDelta int       `json:"balance_delta"`
+	DebtDelta    int       `json:"debt_delta"`
+	BalanceAfter int       `json:"balance_after"`
+	DebtAfter    int       `json:"debt_after"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func makeLedgerEntryResponse(e repo.LedgerEntry) ledgerEntryResponse {
+	resp := ledgerEntryResponse{
+		ID:           e.ID,
+		Kind:         e.Kind,
+		BalanceDelta: e.BalanceDelta,
+		DebtDelta:    e.DebtDelta,
+		BalanceAfter: e.BalanceAfter,
+		DebtAfter:    e.DebtAfter,
+		CreatedAt:    e.CreatedAt,
+	}
+	if e.OrderID.Valid {
+		v := e.OrderID.Int64
+		resp.OrderID = &v
+	}
+	if e.HandInID.Valid {
+		v := e.HandInID.Int64
+		resp.HandInID = &v
+	}
+	return resp
+}
+
+type handInResponse struct {
+	ID         int64      `json:"id"`
+	CourierID  int64      `json:"courier_id"`
+	Amount     int        `json:"amount"`
+	Status     string     `json:"status"`
+	Comment    *string    `json:"comment,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func makeHandInResponse(h repo.CODHandIn) handInResponse {
+	resp := handInResponse{
+		ID:        h.ID,
+		CourierID: h.CourierID,
+		Amount:    h.Amount,
+		Status:    h.Status,
+		Comment:   nullToPtr(h.Comment),
+		CreatedAt: h.CreatedAt,
+	}
+	if h.ReviewedAt.Valid {
+		v := h.ReviewedAt.Time
+		resp.ReviewedAt = &v
+	}
+	return resp
+}
+
+// requireCODConfirmed blocks completion until the courier reports the collected cash.
+func (s *Server) requireCODConfirmed(ctx context.Context, w http.ResponseWriter, orderID int64) bool {
+	order, err := s.orders.Get(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "order not found")
+			return false
+		}
+		s.logger.Errorf("courier: load order %d for cod check failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return false
+	}
+	if order.COD.Amount > 0 && !order.COD.Collected.Valid {
+		writeError(w, http.StatusConflict, "cash on delivery collection must be confirmed")
+		return false
+	}
+	return true
+}
+
+// handleOrderCODCollect — курьер подтверждает, сколько наличных взял у получателя.
+func (s *Server) handleOrderCODCollect(w http.ResponseWriter, r *http.Request, orderID int64) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	courierID, err := parseAuthID(r, "X-Courier-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing courier id")
+		return
+	}
+	var req struct {
+		Collected *int `json:"collected"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.Collected == nil || *req.Collected < 0 {
+		writeError(w, http.StatusBadRequest, "collected amount is required")
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	ctx = withCourierActor(ctx, courierID)
+
+	order, err := s.orders.Get(ctx, orderID)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load order %d for cod failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	if !order.CourierID.Valid || order.CourierID.Int64 != courierID {
+		writeError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if order.Status != lifecycle.StatusInProgress {
+		writeError(w, http.StatusConflict, "order is not in progress")
+		return
+	}
+
+	cod, err := s.orders.ConfirmCODCollected(ctx, orderID, courierID, *req.Collected, timeutil.Now())
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		writeError(w, http.StatusNotFound, "order not found")
+		return
+	case errors.Is(err, repo.ErrNoCOD):
+		writeError(w, http.StatusConflict, "order has no cash on delivery")
+		return
+	case errors.Is(err, repo.ErrCODAlreadyCollected):
+		writeError(w, http.StatusConflict, "cash on delivery already confirmed")
+		return
+	case err != nil:
+		s.logger.Errorf("courier: confirm cod for order %d failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to confirm cash on delivery")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"cod": makeCODResponse(cod)})
+	s.emitOrderEvent(ctx, orderID, orderEventTypeUpdated, originCourier)
+}
+
+// handleCourierCOD shows the courier's cash debt, recent ledger movements and hand-ins.
+func (s *Server) handleCourierCOD(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	courierID, err := parseAuthID(r, "X-Courier-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing courier id")
+		return
+	}
+	s.writeCODAccount(w, r, courierID)
+}
+
+func (s *Server) writeCODAccount(w http.ResponseWriter, r *http.Request, courierID int64) {
+	limit, offset, err := parsePaging(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	acc, err := s.couriers.CODAccount(ctx, courierID)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "courier not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load cod account of courier %d failed: %v", courierID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load cod account")
+		return
+	}
+	entries, err := s.couriers.ListLedger(ctx, courierID, limit, offset)
+	if err != nil {
+		s.logger.Errorf("courier: list ledger of courier %d failed: %v", courierID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load ledger")
+		return
+	}
+	handIns, err := s.couriers.ListCODHandIns(ctx, courierID, "", limit, 0)
+	if err != nil {
+		s.logger.Errorf("courier: list hand-ins of courier %d failed: %v", courierID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load hand-ins")
+		return
+	}
+
+	ledger := make([]ledgerEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		ledger = append(ledger, makeLedgerEntryResponse(e))
+	}
+	handInsResp := make([]handInResponse, 0, len(handIns))
+	for _, h := range handIns {
+		handInsResp = append(handInsResp, makeHandInResponse(h))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"balance":         acc.Balance,
+		"cod_debt":        acc.Debt,
+		"pending_handins": acc.PendingHandIns,
+		"ledger":          ledger,
+		"handins":         handInsResp,
+	})
+}
+
+// handleCourierCODHandIns — курьер заявляет сдачу наличных в офис.
+func (s *Server) handleCourierCODHandIns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	courierID, err := parseAuthID(r, "X-Courier-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing courier id")
+		return
+	}
+	var req struct {
+		Amount  int     `json:"amount"`
+		Comment *string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.Amount <= 0 {
+		writeError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	h, err := s.couriers.CreateCODHandIn(ctx, courierID, req.Amount, nullableString(req.Comment))
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		writeError(w, http.StatusNotFound, "courier not found")
+		return
+	case errors.Is(err, repo.ErrHandInExceedsDebt):
+		writeError(w, http.StatusConflict, "amount exceeds cash on delivery debt")
+		return
+	case err != nil:
+		s.logger.Errorf("courier: create hand-in for courier %d failed: %v", courierID, err)
+		writeError(w, http.StatusInternalServerError, "failed to create hand-in")
+		return
+	}
+	writeJSON(w, http.StatusCreated, makeHandInResponse(h))
+}
+
+// handleAdminCODHandIns lists hand-ins, by default the pending ones awaiting confirmation.
+func (s *Server) handleAdminCODHandIns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	limit, offset, err := parsePaging(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = repo.HandInStatusPending
+	case "all":
+		status = ""
+	case repo.HandInStatusPending, repo.HandInStatusConfirmed, repo.HandInStatusRejected:
+	default:
+		writeError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+	var courierID int64
+	if v := strings.TrimSpace(r.URL.Query().Get("courier_id")); v != "" {
+		if courierID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid courier_id")
+			return
+		}
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	handIns, err := s.couriers.ListCODHandIns(ctx, courierID, status, limit, offset)
+	if err != nil {
+		s.logger.Errorf("courier: list hand-ins failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to list hand-ins")
+		return
+	}
+	resp := make([]handInResponse, 0, len(handIns))
+	for _, h := range handIns {
+		resp = append(resp, makeHandInResponse(h))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"handins": resp})
+}
+
+// handleAdminCODHandInRoutes handles /admin/courier/cod/handins/{id}/confirm|reject.
+func (s *Server) handleAdminCODHandInRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/courier/cod/handins/"), "/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || (parts[1] != "confirm" && parts[1] != "reject") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid hand-in id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	h, err := s.couriers.ReviewCODHandIn(ctx, id, parts[1] == "confirm", timeutil.Now())
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		writeError(w, http.StatusNotFound, "hand-in not found")
+		return
+	case errors.Is(err, repo.ErrHandInReviewed):
+		writeError(w, http.StatusConflict, "hand-in already reviewed")
+		return
+	case errors.Is(err, repo.ErrHandInExceedsDebt):
+		writeError(w, http.StatusConflict, "hand-in exceeds current cod debt")
+		return
+	case err != nil:
+		s.logger.Errorf("courier: review hand-in %d failed: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "failed to review hand-in")
+		return
+	}
+	if s.cHub != nil {
+		s.cHub.Push(h.CourierID, map[string]interface{}{
+			"type":      "cod_handin_reviewed",
+			"handin_id": h.ID,
+			"status":    h.Status,
+			"amount":    h.Amount,
+		})
+	}
+	writeJSON(w, http.StatusOK, makeHandInResponse(h))
+}
+
+func (s *Server) handleAdminCourierCOD(w http.ResponseWriter, r *http.Request, courierID int64) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	s.writeCODAccount(w, r, courierID)
+}
+
+// handleAdminCourierCODDeduction settles the cash debt from the courier's payout balance.
+func (s *Server) handleAdminCourierCODDeduction(w http.ResponseWriter, r *http.Request, courierID int64) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Amount int `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.Amount < 0 {
+		writeError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	entry, err := s.couriers.DeductCODFromBalance(ctx, courierID, req.Amount)
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		writeError(w, http.StatusNotFound, "courier not found")
+		return
+	case errors.Is(err, repo.ErrNothingToSettle):
+		writeError(w, http.StatusConflict, "nothing to settle")
+		return
+	case errors.Is(err, repo.ErrInsufficientBalance):
+		writeError(w, http.StatusConflict, "insufficient balance")
+		return
+	case err != nil:
+		s.logger.Errorf("courier: deduct cod of courier %d failed: %v", courierID, err)
+		writeError(w, http.StatusInternalServerError, "failed to deduct cod")
+		return
+	}
+	writeJSON(w, http.StatusOK, makeLedgerEntryResponse(entry))
+}
+
+type codReportRow struct {
+	OrderID     int64      `json:"order_id"`
+	Status      string     `json:"status"`
+	CourierID   *int64     `json:"courier_id,omitempty"`
+	Amount      int        `json:"amount"`
+	Collected   *int       `json:"collected,omitempty"`
+	CollectedAt *time.Time `json:"collected_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// handleSenderCODReport — отчёт отправителя по наложенным платежам за период (по умолчанию 30 дней).
+func (s *Server) handleSenderCODReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	now := timeutil.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -30)
+	if v := strings.TrimSpace(r.URL.Query().Get("from")); v != "" {
+		if from, err = time.ParseInLocation("2006-01-02", v, now.Location()); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid from date")
+			return
+		}
+	}
+	if v := strings.TrimSpace(r.URL.Query().Get("to")); v != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid to date")
+			return
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		writeError(w, http.StatusBadRequest, "to date must be on or after from date")
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	rows, err := s.orders.SenderCODReport(ctx, senderID, from, to)
+	if err != nil {
+		s.logger.Errorf("courier: cod report for sender %d failed: %v", senderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to build cod report")
+		return
+	}
+
+	var expected, collected, shortfall, awaiting int
+	items := make([]codReportRow, 0, len(rows))
+	for _, row := range rows {
+		item := codReportRow{OrderID: row.OrderID, Status: row.Status, Amount: row.Amount, CreatedAt: row.CreatedAt}
+		if row.CourierID.Valid {
+			v := row.CourierID.Int64
+			item.CourierID = &v
+		}
+		expected += row.Amount
+		if row.Collected.Valid {
+			v := int(row.Collected.Int64)
+			item.Collected = &v
+			collected += v
+			if v < row.Amount {
+				shortfall += row.Amount - v
+			}
+		} else {
+			switch row.Status {
+			case repo.StatusNew, repo.StatusAccepted, repo.StatusWaitingFree, repo.StatusInProgress,
+				repo.StatusDeliveryAttempted, repo.StatusWaitingRecipient:
+				awaiting++
+			}
+		}
+		if row.CollectedAt.Valid {
+			v := row.CollectedAt.Time
+			item.CollectedAt = &v
+		}
+		items = append(items, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":  from.Format("2006-01-02"),
+		"to":    to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"items": items,
+		"totals": map[string]int{
+			"orders":    len(items),
+			"expected":  expected,
+			"collected": collected,
+			"shortfall": shortfall,
+			"awaiting":  awaiting,
+		},
+	})
+}
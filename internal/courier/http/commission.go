@@ -0,0 +1,185 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"naimuBack/internal/commission"
+	"naimuBack/internal/courier/repo"
+)
+
+// settleCommission считает и удерживает комиссию курьера после завершения заказа.
+// Повторный вызов безопасен: комиссия фиксируется на заказе один раз.
+func (s *Server) settleCommission(ctx context.Context, orderID int64) {
+	order, err := s.orders.Get(ctx, orderID)
+	if err != nil {
+		s.logger.Errorf("courier: load order %d for commission failed: %v", orderID, err)
+		return
+	}
+	if !order.CourierID.Valid || order.Commission.Valid {
+		return
+	}
+	courierID := order.CourierID.Int64
+
+	// Платное ожидание и обратный путь входят в оборот курьера наравне с ценой заказа.
+	amount := order.ClientPrice + order.Return.Surcharge()
+	charge := repo.OrderCommission{Amount: commission.Default(amount), InsuranceFee: order.Insurance.Fee}
+	if s.commission != nil {
+		subject := s.commissionSubject(ctx, order, amount)
+		res, err := s.commission.Compute(ctx, courierID, subject)
+		if err != nil {
+			s.logger.Errorf("courier: compute commission for order %d failed: %v", orderID, err)
+		} else {
+			charge.Amount = res.Amount
+			if res.RuleID > 0 {
+				charge.RuleID = sql.NullInt64{Int64: res.RuleID, Valid: true}
+			}
+			if res.PassID > 0 {
+				charge.PassID = sql.NullInt64{Int64: res.PassID, Valid: true}
+			}
+		}
+	}
+
+	if _, err := s.orders.RecordCommission(ctx, orderID, courierID, charge); err != nil {
+		s.logger.Errorf("courier: record commission for order %d failed: %v", orderID, err)
+	}
+}
+
+// commissionSubject описывает заказ для правил комиссии: город отправителя и тип транспорта курьера
+// как класс тарифа; если их не узнать, действуют регион сервиса и класс по умолчанию.
+func (s *Server) commissionSubject(ctx context.Context, order repo.Order, amount int) commission.Subject {
+	subject := commission.Subject{
+		Service:     commission.ServiceCourier,
+		City:        s.cfg.RegionKey,
+		TariffClass: commission.DefaultTariffClass,
+		Amount:      amount,
+		At:          time.Now(),
+	}
+	if order.Courier != nil {
+		subject.JoinedAt = order.Courier.CreatedAt
+	}
+	if order.CourierID.Valid && s.couriers != nil {
+		vehicles, err := s.couriers.VehicleTypes(ctx, []int64{order.CourierID.Int64})
+		if err != nil {
+			s.logger.Errorf("courier: vehicle of courier %d for commission failed: %v", order.CourierID.Int64, err)
+		} else if vehicle := strings.ToLower(strings.TrimSpace(vehicles[order.CourierID.Int64])); vehicle != "" {
+			subject.TariffClass = vehicle
+		}
+	}
+	if order.Sender.CityID.Valid && s.users != nil {
+		name, err := s.users.CityName(ctx, order.Sender.CityID.Int64)
+		switch {
+		case err == nil && strings.TrimSpace(name) != "":
+			subject.City = strings.ToLower(strings.TrimSpace(name))
+		case err != nil && !errors.Is(err, sql.ErrNoRows):
+			s.logger.Errorf("courier: city %d for commission failed: %v", order.Sender.CityID.Int64, err)
+		}
+	}
+	return subject
+}
+
+// recordedCommission returns the commission charged for the order; orders completed before
+// the rules engine fall back to the default percent.
+func recordedCommission(order repo.Order) int {
+	if order.Commission.Valid {
+		return int(order.Commission.Int64)
+	}
+	return commission.Default(order.ClientPrice + order.Return.Surcharge())
+}
+
+// handleCourierCommissionPasses: GET — тарифы смены и активный пропуск, POST — покупка пропуска с баланса.
+func (s *Server) handleCourierCommissionPasses(w http.ResponseWriter, r *http.Request) {
+	courierID, err := parseAuthID(r, "X-Courier-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing courier id")
+		return
+	}
+	if s.commissionRepo == nil {
+		writeError(w, http.StatusServiceUnavailable, "commission rules disabled")
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		plans, err := s.commissionRepo.ListPassPlans(ctx, commission.ServiceCourier)
+		if err != nil {
+			s.logger.Errorf("courier: list pass plans failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load plans")
+			return
+		}
+		planResp := make([]commission.RuleResponse, 0, len(plans))
+		for _, p := range plans {
+			planResp = append(planResp, commission.MakeRuleResponse(p))
+		}
+		resp := map[string]interface{}{"plans": planResp, "active": nil}
+		active, err := s.commissionRepo.ActivePass(ctx, commission.ServiceCourier, courierID, time.Now())
+		if err == nil {
+			resp["active"] = commission.MakePassResponse(active)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Errorf("courier: active pass failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load pass")
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case http.MethodPost:
+		var req struct {
+			RuleID int64 `json:"rule_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if req.RuleID <= 0 {
+			writeError(w, http.StatusBadRequest, "rule_id is required")
+			return
+		}
+		if _, err := s.couriers.Get(ctx, courierID); err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "courier not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to load courier")
+			return
+		}
+		plan, err := s.commissionRepo.GetRule(ctx, req.RuleID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusNotFound, "plan not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to load plan")
+			return
+		}
+		pass, err := plan.NewPass(commission.ServiceCourier, courierID, time.Now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "rule is not a shift pass plan")
+			return
+		}
+
+		id, err := s.commissionRepo.BuyPass(ctx, pass, repo.WithdrawBalanceTx)
+		if err != nil {
+			switch {
+			case errors.Is(err, repo.ErrInsufficientBalance):
+				writeError(w, http.StatusConflict, "insufficient balance")
+			case errors.Is(err, commission.ErrPassActive):
+				writeError(w, http.StatusConflict, "shift pass already active")
+			default:
+				s.logger.Errorf("courier: buy shift pass failed: %v", err)
+				writeError(w, http.StatusInternalServerError, "failed to create pass")
+			}
+			return
+		}
+		pass.ID = id
+		writeJSON(w, http.StatusCreated, commission.MakePassResponse(pass))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
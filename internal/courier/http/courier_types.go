@@ -18,13 +18,16 @@ type courierResponse struct {
 	IDCardFront string    `json:"id_card_front"`
 	IDCardBack  string    `json:"id_card_back"`
 	Phone       string    `json:"phone"`
+	VehicleType string    `json:"vehicle_type"`
 	Rating      *float64  `json:"rating,omitempty"`
 	Balance     int       `json:"balance"`
 	Status      string    `json:"status"`
 	Approval    string    `json:"approval_status"`
-	IsBanned    bool      `json:"is_banned"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	// Verification пуст у курьера, загруженного вместе с заказом.
+	Verification string    `json:"verification_level,omitempty"`
+	IsBanned     bool      `json:"is_banned"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 type courierStatsResponse struct {
@@ -57,24 +60,26 @@ func makeCourierResponse(c repo.Courier) courierResponse {
 		rating = &v
 	}
 	return courierResponse{
-		ID:          c.ID,
-		UserID:      c.UserID,
-		FirstName:   c.FirstName,
-		LastName:    c.LastName,
-		MiddleName:  middleName,
-		Photo:       c.Photo,
-		IIN:         c.IIN,
-		DateOfBirth: c.BirthDate,
-		IDCardFront: c.IDCardFront,
-		IDCardBack:  c.IDCardBack,
-		Phone:       c.Phone,
-		Rating:      rating,
-		Balance:     c.Balance,
-		Status:      c.Status,
-		Approval:    c.ApprovalStatus,
-		IsBanned:    c.IsBanned,
-		CreatedAt:   c.CreatedAt,
-		UpdatedAt:   c.UpdatedAt,
+		ID:           c.ID,
+		UserID:       c.UserID,
+		FirstName:    c.FirstName,
+		LastName:     c.LastName,
+		MiddleName:   middleName,
+		Photo:        c.Photo,
+		IIN:          c.IIN,
+		DateOfBirth:  c.BirthDate,
+		IDCardFront:  c.IDCardFront,
+		IDCardBack:   c.IDCardBack,
+		Phone:        c.Phone,
+		VehicleType:  c.VehicleType,
+		Rating:       rating,
+		Balance:      c.Balance,
+		Status:       c.Status,
+		Approval:     c.ApprovalStatus,
+		Verification: c.VerificationLevel,
+		IsBanned:     c.IsBanned,
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
 	}
 }
 
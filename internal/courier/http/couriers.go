@@ -16,6 +16,7 @@ import (
 	"strings"
 	"time"
 
+	"naimuBack/internal/courier/parcel"
 	"naimuBack/internal/courier/repo"
 )
 
@@ -87,6 +88,7 @@ func (s *Server) handleCourierUpsert(w http.ResponseWriter, r *http.Request) {
 		phone            string
 		rating           *float64
 		status           *string
+		vehicleType      string
 	)
 
 	if isMultipart {
@@ -118,6 +120,7 @@ func (s *Server) handleCourierUpsert(w http.ResponseWriter, r *http.Request) {
 		if v := strings.TrimSpace(r.FormValue("status")); v != "" {
 			status = &[]string{v}[0]
 		}
+		vehicleType = r.FormValue("vehicle_type")
 
 		// Загружаемые файлы: courier_photo, id_card_front, id_card_back
 		// — если не пришёл файл, поле остаётся пустым (при апдейте можешь не присылать)
@@ -160,6 +163,7 @@ func (s *Server) handleCourierUpsert(w http.ResponseWriter, r *http.Request) {
 			Phone        string   `json:"phone"`
 			Rating       *float64 `json:"rating"`
 			Status       *string  `json:"status"`
+			VehicleType  string   `json:"vehicle_type"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 			writeError(w, http.StatusBadRequest, "invalid json")
@@ -177,6 +181,7 @@ func (s *Server) handleCourierUpsert(w http.ResponseWriter, r *http.Request) {
 		phone = strings.TrimSpace(payload.Phone)
 		rating = payload.Rating
 		status = payload.Status
+		vehicleType = payload.VehicleType
 	}
 
 	// Валидация
@@ -207,6 +212,18 @@ func (s *Server) handleCourierUpsert(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Пустой vehicle_type при обновлении оставляет прежний транспорт.
+	if strings.TrimSpace(vehicleType) != "" {
+		v, ok := parcel.NormalizeVehicle(vehicleType)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid vehicle_type")
+			return
+		}
+		vehicleType = v
+	} else {
+		vehicleType = ""
+	}
+
 	var middle sql.NullString
 	if middleName != nil && strings.TrimSpace(*middleName) != "" {
 		middle = sql.NullString{String: strings.TrimSpace(*middleName), Valid: true}
@@ -232,6 +249,7 @@ func (s *Server) handleCourierUpsert(w http.ResponseWriter, r *http.Request) {
 		IDCardFront: idCardFrontPath,
 		IDCardBack:  idCardBackPath,
 		Phone:       phone,
+		VehicleType: vehicleType,
 		Rating:      ratingNF,
 		Status:      stat,
 	}
@@ -437,8 +455,7 @@ func (s *Server) handleCourierStats(w http.ResponseWriter, r *http.Request, cour
 		dayMap := make(map[string]*courierDayStatsResponse)
 		for _, order := range orders {
 			orderResp := makeOrderResponse(order)
-			commission := calculateCourierCommission(order.ClientPrice)
-			netProfit := order.ClientPrice - commission
+			netProfit := order.ClientPrice - recordedCommission(order)
 
 			resp.TotalAmount += order.ClientPrice
 			resp.NetProfit += netProfit
@@ -475,12 +492,3 @@ func (s *Server) handleCourierStats(w http.ResponseWriter, r *http.Request, cour
 
 	writeJSON(w, http.StatusOK, resp)
 }
-
-const courierCommissionPercent = 10
-
-func calculateCourierCommission(amount int) int {
-	if amount <= 0 || courierCommissionPercent <= 0 {
-		return 0
-	}
-	return (amount*courierCommissionPercent + 99) / 100
-}
@@ -0,0 +1,180 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/courier/ws"
+	taxigeo "naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+// liveTripRecheck — как часто перечитываем активный заказ курьера, чтобы заметить смену статуса.
+const liveTripRecheck = 10 * time.Second
+
+const (
+	etaTargetPickup  = "pickup"
+	etaTargetDropoff = "dropoff"
+	etaTargetReturn  = "return"
+)
+
+type liveTrip struct {
+	orderID  int64
+	senderID int64
+	status   string
+	points   []repo.OrderPoint
+	// path — сохранённая геометрия маршрута заказа, по ней определяем съезд с маршрута.
+	path      []taxigeo.Point
+	checkedAt time.Time
+}
+
+// target: до первой точки, пока курьер едет забирать посылку или везёт её обратно, и до последней во время доставки.
+func (t liveTrip) target() (string, float64, float64, bool) {
+	if len(t.points) == 0 {
+		return "", 0, 0, false
+	}
+	switch t.status {
+	case repo.StatusAccepted:
+		return etaTargetPickup, t.points[0].Lon, t.points[0].Lat, true
+	case repo.StatusInProgress:
+		last := t.points[len(t.points)-1]
+		return etaTargetDropoff, last.Lon, last.Lat, true
+	case repo.StatusReturning:
+		return etaTargetReturn, t.points[0].Lon, t.points[0].Lat, true
+	}
+	return "", 0, 0, false
+}
+
+// liveETA caches active orders of couriers and throttles routing requests.
+type liveETA struct {
+	tracker *taxigeo.ETATracker
+
+	mu       sync.Mutex
+	trips    map[int64]liveTrip
+	busy     map[int64]bool
+	offRoute map[int64]bool
+}
+
+func newLiveETA(router taxigeo.Router, refresh time.Duration) *liveETA {
+	return &liveETA{
+		tracker:  taxigeo.NewETATracker(router, refresh),
+		trips:    make(map[int64]liveTrip),
+		busy:     make(map[int64]bool),
+		offRoute: make(map[int64]bool),
+	}
+}
+
+func (l *liveETA) begin(courierID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.busy[courierID] {
+		return false
+	}
+	l.busy[courierID] = true
+	return true
+}
+
+func (l *liveETA) end(courierID int64) {
+	l.mu.Lock()
+	delete(l.busy, courierID)
+	l.mu.Unlock()
+}
+
+func (l *liveETA) cached(courierID int64, now time.Time) (liveTrip, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	trip, ok := l.trips[courierID]
+	if !ok || now.Sub(trip.checkedAt) >= liveTripRecheck {
+		return liveTrip{}, false
+	}
+	return trip, true
+}
+
+func (l *liveETA) remember(courierID int64, trip liveTrip) {
+	l.mu.Lock()
+	prev, ok := l.trips[courierID]
+	l.trips[courierID] = trip
+	l.mu.Unlock()
+	if ok && prev.orderID != 0 && prev.orderID != trip.orderID {
+		l.tracker.Forget(prev.orderID)
+	}
+}
+
+// markOffRoute stores whether the courier has left the route and reports a change.
+func (l *liveETA) markOffRoute(courierID int64, off bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changed := l.offRoute[courierID] != off
+	if off {
+		l.offRoute[courierID] = true
+	} else {
+		delete(l.offRoute, courierID)
+	}
+	return changed
+}
+
+// CourierLocation implements ws.LocationHandler: recomputes the ETA and pushes it to the sender
+// and to recipients watching their tracking links.
+func (s *Server) CourierLocation(courierID int64, lon, lat float64) {
+	if s.tracking != nil && s.tHub != nil {
+		s.watchRecipients(courierID, lon, lat)
+	}
+	if s.eta == nil || !s.eta.begin(courierID) {
+		return
+	}
+	go func() {
+		defer s.eta.end(courierID)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		s.pushCourierETA(ctx, courierID, lon, lat)
+	}()
+}
+
+func (s *Server) pushCourierETA(ctx context.Context, courierID int64, lon, lat float64) {
+	now := timeutil.Now()
+	trip, ok := s.eta.cached(courierID, now)
+	if !ok {
+		order, err := s.orders.ActiveByCourier(ctx, courierID)
+		switch {
+		case errors.Is(err, repo.ErrNotFound):
+			trip = liveTrip{checkedAt: now}
+		case err != nil:
+			s.logger.Errorf("courier: eta load active order of courier %d failed: %v", courierID, err)
+			return
+		default:
+			trip = liveTrip{orderID: order.ID, senderID: order.SenderID, status: order.Status, points: order.Points, path: orderRoutePath(order.Points), checkedAt: now}
+		}
+		s.eta.remember(courierID, trip)
+	}
+	target, toLon, toLat, ok := trip.target()
+	if !ok || s.sHub == nil {
+		return
+	}
+	offRoute := target == etaTargetDropoff && taxigeo.OffRoute(lon, lat, trip.path, offRouteToleranceM)
+	if s.eta.markOffRoute(courierID, offRoute) && offRoute {
+		// курьер съехал с маршрута — прежний ETA неверен, перестраиваем сразу
+		s.eta.tracker.Forget(trip.orderID)
+	}
+	eta, err := s.eta.tracker.Estimate(ctx, trip.orderID, lon, lat, toLon, toLat, now)
+	if err != nil {
+		s.logger.Errorf("courier: eta route for order %d failed: %v", trip.orderID, err)
+		return
+	}
+	s.sHub.PushOrderEvent(trip.senderID, ws.SenderEvent{
+		Type:      "courier_eta",
+		OrderID:   trip.orderID,
+		Status:    trip.status,
+		CourierID: courierID,
+		ETA: &ws.SenderETA{
+			Target:     target,
+			CourierLon: lon,
+			CourierLat: lat,
+			DistanceM:  eta.DistanceM,
+			EtaSeconds: eta.EtaSeconds,
+			OffRoute:   offRoute,
+		},
+	})
+}
@@ -69,7 +69,7 @@ func (s *Server) emitOrder(ctx context.Context, order repo.Order, eventType stri
 		return
 	}
 
-	resp := makeOrderResponse(order)
+	resp := s.orderView(order)
 	evt := orderEvent{Type: resolveOrderEventType(eventType, order), Order: resp}
 
 	// ... блок сборки evt.Courier / evt.Sender оставляем как есть ...
@@ -95,6 +95,11 @@ func (s *Server) emitOrder(ctx context.Context, order repo.Order, eventType stri
 			s.cHub.Broadcast(evt)
 		}
 	}
+	s.closeOrderChat(order)
+	if s.tracking != nil && s.tHub != nil {
+		s.trackOrderEvent(order)
+	}
+	s.queueMerchantWebhook(ctx, order)
 }
 
 func (s *Server) emitOfferEvent(ctx context.Context, orderID, courierID int64, status string, price *int, origin eventOrigin) {
@@ -211,6 +216,10 @@ func (s *Server) buildCourierEventInfo(ctx context.Context, courierID int64) (*c
 		return nil, err
 	}
 	payload := courierEventInfo{Profile: makeCourierResponse(courier), User: makeUserResponse(user)}
+	if s.cfg.ChatHidePhones {
+		payload.Profile.Phone = ""
+		payload.User.Phone = ""
+	}
 	return &payload, nil
 }
 
@@ -223,6 +232,9 @@ func (s *Server) buildUserEventInfo(ctx context.Context, userID int64) (*userRes
 		return nil, err
 	}
 	resp := makeUserResponse(user)
+	if s.cfg.ChatHidePhones {
+		resp.Phone = ""
+	}
 	return &resp, nil
 }
 
@@ -237,6 +249,12 @@ func resolveOrderEventType(eventType string, order repo.Order) string {
 		return "order_assigned"
 	case repo.StatusCompleted, repo.StatusClosed:
 		return "order_completed"
+	case repo.StatusDeliveryAttempted, repo.StatusWaitingRecipient:
+		return "order_delivery_failed"
+	case repo.StatusReturning:
+		return "order_returning"
+	case repo.StatusReturned:
+		return "order_returned"
 	default:
 		return "order_status"
 	}
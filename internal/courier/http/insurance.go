@@ -0,0 +1,430 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/courier/ws"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+const maxClaimPhotos = 10
+
+type insuranceResponse struct {
+	DeclaredValue int `json:"declared_value"`
+	Fee           int `json:"fee"`
+}
+
+func makeInsuranceResponse(in repo.Insurance) *insuranceResponse {
+	if in.DeclaredValue <= 0 {
+		return nil
+	}
+	return &insuranceResponse{DeclaredValue: in.DeclaredValue, Fee: in.Fee}
+}
+
+// validateDeclaredValue rejects values no courier is allowed to carry.
+func (s *Server) validateDeclaredValue(value int) error {
+	if value < 0 {
+		return errors.New("declared_value must not be negative")
+	}
+	if max := s.cfg.Insurance.MaxCap(); value > max {
+		return fmt.Errorf("declared_value must be <= %d", max)
+	}
+	return nil
+}
+
+type claimResponse struct {
+	ID              int64      `json:"id"`
+	OrderID         int64      `json:"order_id"`
+	SenderID        int64      `json:"sender_id"`
+	CourierID       *int64     `json:"courier_id,omitempty"`
+	Kind            string     `json:"kind"`
+	Description     string     `json:"description"`
+	Amount          int        `json:"amount"`
+	Status          string     `json:"status"`
+	PayoutAmount    int        `json:"payout_amount"`
+	CourierCharge   int        `json:"courier_charge"`
+	ResolutionNote  *string    `json:"resolution_note,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	PayoutStatus    string     `json:"payout_status"`
+	PayoutReference *string    `json:"payout_reference,omitempty"`
+	PaidAt          *time.Time `json:"paid_at,omitempty"`
+	Photos          []string   `json:"photos,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func makeClaimResponse(c repo.Claim) claimResponse {
+	resp := claimResponse{
+		ID:              c.ID,
+		OrderID:         c.OrderID,
+		SenderID:        c.SenderID,
+		Kind:            c.Kind,
+		Description:     c.Description,
+		Amount:          c.Amount,
+		Status:          c.Status,
+		PayoutAmount:    c.PayoutAmount,
+		CourierCharge:   c.CourierCharge,
+		ResolutionNote:  nullToPtr(c.ResolutionNote),
+		ResolvedAt:      nullTimeToPtr(c.ResolvedAt),
+		PayoutStatus:    c.PayoutStatus,
+		PayoutReference: nullToPtr(c.PayoutReference),
+		PaidAt:          nullTimeToPtr(c.PaidAt),
+		Photos:          c.Photos,
+		CreatedAt:       c.CreatedAt,
+	}
+	if c.CourierID.Valid {
+		v := c.CourierID.Int64
+		resp.CourierID = &v
+	}
+	return resp
+}
+
+// handleOrderClaim: GET — претензия по заказу, POST — подать претензию (multipart с фото "photos").
+func (s *Server) handleOrderClaim(w http.ResponseWriter, r *http.Request, orderID int64) {
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+		claim, err := s.orders.ClaimByOrder(ctx, orderID)
+		if err == nil && claim.SenderID != senderID {
+			err = repo.ErrNotFound
+		}
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "claim not found")
+			return
+		}
+		if err != nil {
+			s.logger.Errorf("courier: load claim of order %d failed: %v", orderID, err)
+			writeError(w, http.StatusInternalServerError, "failed to load claim")
+			return
+		}
+		writeJSON(w, http.StatusOK, makeClaimResponse(claim))
+	case http.MethodPost:
+		s.createClaim(w, r, orderID, senderID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createClaim(w http.ResponseWriter, r *http.Request, orderID, senderID int64) {
+	if err := r.ParseMultipartForm(50 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid multipart form")
+		return
+	}
+	claim := repo.Claim{
+		OrderID:     orderID,
+		SenderID:    senderID,
+		Kind:        strings.ToLower(strings.TrimSpace(r.FormValue("kind"))),
+		Description: strings.TrimSpace(r.FormValue("description")),
+	}
+	if claim.Kind != repo.ClaimKindDamage && claim.Kind != repo.ClaimKindLoss {
+		writeError(w, http.StatusBadRequest, "kind must be damage or loss")
+		return
+	}
+	if claim.Description == "" {
+		writeError(w, http.StatusBadRequest, "description is required")
+		return
+	}
+	amount, err := strconv.Atoi(strings.TrimSpace(r.FormValue("amount")))
+	if err != nil || amount <= 0 {
+		writeError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	claim.Amount = amount
+	photos := r.MultipartForm.File["photos"]
+	if claim.Kind == repo.ClaimKindDamage && len(photos) == 0 {
+		writeError(w, http.StatusBadRequest, "photos of the damage are required")
+		return
+	}
+	if len(photos) > maxClaimPhotos {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d photos allowed", maxClaimPhotos))
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	ctx = withSenderActor(ctx, senderID)
+
+	order, err := s.orders.Get(ctx, orderID)
+	if err == nil && order.SenderID != senderID {
+		err = repo.ErrNotFound
+	}
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load order %d for claim failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	if order.Insurance.DeclaredValue <= 0 {
+		writeError(w, http.StatusConflict, "order is not insured")
+		return
+	}
+
+	for _, h := range photos {
+		f, err := h.Open()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to read photo")
+			return
+		}
+		path, err := saveUploadedFile(f, h, "claims")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to save photo")
+			return
+		}
+		claim.Photos = append(claim.Photos, path)
+	}
+
+	id, err := s.orders.CreateClaim(ctx, claim)
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		writeError(w, http.StatusNotFound, "order not found")
+		return
+	case errors.Is(err, repo.ErrClaimNotAllowed):
+		writeError(w, http.StatusConflict, "claims are accepted after delivery or return")
+		return
+	case errors.Is(err, repo.ErrOrderNotInsured):
+		writeError(w, http.StatusConflict, "order is not insured")
+		return
+	case errors.Is(err, repo.ErrClaimAmount):
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("amount must be <= %d", order.Insurance.DeclaredValue))
+		return
+	case errors.Is(err, repo.ErrClaimExists):
+		writeError(w, http.StatusConflict, "claim already filed")
+		return
+	case err != nil:
+		s.logger.Errorf("courier: create claim for order %d failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to create claim")
+		return
+	}
+
+	saved, err := s.orders.GetClaim(ctx, id)
+	if err != nil {
+		s.logger.Errorf("courier: load claim %d failed: %v", id, err)
+		writeJSON(w, http.StatusCreated, map[string]int64{"id": id})
+		return
+	}
+	writeJSON(w, http.StatusCreated, makeClaimResponse(saved))
+}
+
+func (s *Server) handleAdminClaims(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	switch status {
+	case "", repo.ClaimOpen, repo.ClaimApproved, repo.ClaimRejected:
+	default:
+		writeError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+	limit, offset, err := parsePaging(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	claims, err := s.orders.ListClaims(ctx, status, limit, offset)
+	if err != nil {
+		s.logger.Errorf("courier: list claims failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to list claims")
+		return
+	}
+	items := make([]claimResponse, 0, len(claims))
+	for _, c := range claims {
+		items = append(items, makeClaimResponse(c))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"claims": items, "limit": limit, "offset": offset})
+}
+
+// handleAdminClaimRoutes: GET {id}, POST {id}/resolve, POST {id}/paid.
+func (s *Server) handleAdminClaimRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/courier/claims/"), "/")
+	parts := strings.Split(path, "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid claim id")
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+		claim, err := s.orders.GetClaim(ctx, id)
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "claim not found")
+			return
+		}
+		if err != nil {
+			s.logger.Errorf("courier: load claim %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to load claim")
+			return
+		}
+		writeJSON(w, http.StatusOK, makeClaimResponse(claim))
+	case len(parts) == 2 && parts[1] == "resolve" && r.Method == http.MethodPost:
+		s.resolveClaim(w, r, id)
+	case len(parts) == 2 && parts[1] == "paid" && r.Method == http.MethodPost:
+		s.markClaimPaid(w, r, id)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// resolveClaim approves a payout, part of which may be charged to the courier, or rejects the claim.
+func (s *Server) resolveClaim(w http.ResponseWriter, r *http.Request, id int64) {
+	var req struct {
+		Decision      string  `json:"decision"`
+		PayoutAmount  int     `json:"payout_amount"`
+		CourierCharge int     `json:"courier_charge"`
+		Note          *string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	res := repo.ClaimResolution{
+		PayoutAmount:  req.PayoutAmount,
+		CourierCharge: req.CourierCharge,
+		Note:          nullableString(req.Note),
+	}
+	switch strings.ToLower(strings.TrimSpace(req.Decision)) {
+	case "approve":
+		res.Approve = true
+	case "reject":
+		if !res.Note.Valid {
+			writeError(w, http.StatusBadRequest, "note is required to reject")
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "decision must be approve or reject")
+		return
+	}
+	if len(res.Note.String) > 512 {
+		writeError(w, http.StatusBadRequest, "note must be at most 512 characters")
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	switch err := s.orders.ResolveClaim(ctx, id, res, timeutil.Now()); {
+	case errors.Is(err, repo.ErrNotFound):
+		writeError(w, http.StatusNotFound, "claim not found")
+		return
+	case errors.Is(err, repo.ErrClaimState):
+		writeError(w, http.StatusConflict, "claim already resolved")
+		return
+	case errors.Is(err, repo.ErrClaimAmount):
+		writeError(w, http.StatusBadRequest, "payout must be positive and within the claim, courier charge within the payout")
+		return
+	case err != nil:
+		s.logger.Errorf("courier: resolve claim %d failed: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "failed to resolve claim")
+		return
+	}
+
+	claim, err := s.orders.GetClaim(ctx, id)
+	if err != nil {
+		s.logger.Errorf("courier: load claim %d failed: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "failed to load claim")
+		return
+	}
+	if s.sHub != nil {
+		event := ws.SenderEvent{Type: "claim_resolved", OrderID: claim.OrderID, Status: claim.Status, Price: claim.PayoutAmount}
+		if claim.ResolutionNote.Valid {
+			event.Message = claim.ResolutionNote.String
+		}
+		s.sHub.PushOrderEvent(claim.SenderID, event)
+	}
+	writeJSON(w, http.StatusOK, makeClaimResponse(claim))
+}
+
+// markClaimPaid records the bank transfer of an approved payout to the sender.
+func (s *Server) markClaimPaid(w http.ResponseWriter, r *http.Request, id int64) {
+	var req struct {
+		Reference string `json:"reference"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	req.Reference = strings.TrimSpace(req.Reference)
+	if req.Reference == "" || len(req.Reference) > 128 {
+		writeError(w, http.StatusBadRequest, "reference is required")
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	switch err := s.orders.MarkClaimPaid(ctx, id, req.Reference, timeutil.Now()); {
+	case errors.Is(err, repo.ErrNotFound):
+		writeError(w, http.StatusNotFound, "claim not found")
+		return
+	case errors.Is(err, repo.ErrClaimState):
+		writeError(w, http.StatusConflict, "claim has no pending payout")
+		return
+	case err != nil:
+		s.logger.Errorf("courier: mark claim %d paid failed: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "failed to update claim")
+		return
+	}
+	claim, err := s.orders.GetClaim(ctx, id)
+	if err != nil {
+		s.logger.Errorf("courier: load claim %d failed: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "failed to load claim")
+		return
+	}
+	writeJSON(w, http.StatusOK, makeClaimResponse(claim))
+}
+
+func (s *Server) handleAdminCourierVerification(w http.ResponseWriter, r *http.Request, courierID int64) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var payload struct {
+		Level string `json:"level"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	level := strings.TrimSpace(strings.ToLower(payload.Level))
+	switch level {
+	case repo.CourierVerificationBasic, repo.CourierVerificationVerified, repo.CourierVerificationTrusted:
+	default:
+		writeError(w, http.StatusBadRequest, "invalid verification level")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	if err := s.couriers.UpdateVerificationLevel(ctx, courierID, level); err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "courier not found")
+			return
+		}
+		s.logger.Errorf("courier: admin verification failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to update courier")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"verification_level":   level,
+		"declared_value_limit": s.cfg.Insurance.Cap(level),
+	})
+}
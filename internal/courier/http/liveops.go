@@ -0,0 +1,237 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"naimuBack/internal/chat"
+	"naimuBack/internal/courier/lifecycle"
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/liveops"
+)
+
+// liveOpsSource отдаёт курьерку на карту оперативного мониторинга.
+type liveOpsSource struct {
+	s *Server
+}
+
+// LiveOpsSource returns the courier feed for the admin live operations map.
+func (s *Server) LiveOpsSource() liveops.Source {
+	return liveOpsSource{s: s}
+}
+
+func (src liveOpsSource) Service() string { return liveops.ServiceCourier }
+
+func (src liveOpsSource) Collect(ctx context.Context, now time.Time) ([]liveops.Agent, []liveops.Order, error) {
+	s := src.s
+	agents := make([]liveops.Agent, 0)
+	if s.cHub != nil {
+		for _, c := range s.cHub.OnlineCouriers() {
+			if !c.HasPosition {
+				continue
+			}
+			agents = append(agents, liveops.Agent{
+				Service: liveops.ServiceCourier,
+				ID:      c.ID,
+				City:    c.City,
+				Status:  c.Status,
+				Lon:     c.Position.Lon,
+				Lat:     c.Position.Lat,
+			})
+		}
+	}
+
+	live, err := s.orders.ListLive(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	orders := make([]liveops.Order, 0, len(live))
+	for _, o := range live {
+		if o.SearchStartsAt.Valid && o.SearchStartsAt.Time.After(now) {
+			// заказ ко времени ещё не ищет курьера — на карте ему делать нечего
+			continue
+		}
+		item := liveops.Order{
+			Service:     liveops.ServiceCourier,
+			ID:          o.ID,
+			City:        s.cfg.RegionKey,
+			Status:      o.Status,
+			Phase:       courierLivePhase(o.Status),
+			CustomerID:  o.SenderID,
+			Price:       o.ClientPrice,
+			CreatedAt:   o.CreatedAt,
+			StatusSince: o.UpdatedAt,
+		}
+		if len(o.Points) > 0 {
+			first, last := o.Points[0], o.Points[len(o.Points)-1]
+			item.FromLon, item.FromLat = first.Lon, first.Lat
+			item.ToLon, item.ToLat = last.Lon, last.Lat
+		}
+		if o.CourierID.Valid {
+			item.AgentID = o.CourierID.Int64
+		}
+		if o.RadiusM.Valid {
+			item.RadiusM = int(o.RadiusM.Int64)
+		}
+		if o.SearchStartsAt.Valid && o.SearchStartsAt.Time.After(item.CreatedAt) {
+			item.CreatedAt = o.SearchStartsAt.Time
+		}
+		if o.Schedule.LateRiskAt.Valid {
+			at := o.Schedule.LateRiskAt.Time
+			item.LateRiskAt = &at
+		}
+		orders = append(orders, item)
+	}
+	return agents, orders, nil
+}
+
+func courierLivePhase(status string) string {
+	switch status {
+	case repo.StatusNew:
+		return liveops.PhaseSearching
+	case repo.StatusAccepted:
+		return liveops.PhaseToPickup
+	case repo.StatusWaitingFree, repo.StatusDeliveryAttempted, repo.StatusWaitingRecipient:
+		return liveops.PhaseWaiting
+	default:
+		return liveops.PhaseInTrip
+	}
+}
+
+// Assign — ручное назначение курьера оператором на заказ в поиске.
+func (src liveOpsSource) Assign(ctx context.Context, orderID, courierID int64) error {
+	s := src.s
+	order, err := s.orders.Get(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return liveops.ErrOrderNotFound
+		}
+		return err
+	}
+	if order.Status != repo.StatusNew {
+		return liveops.ErrNotAssignable
+	}
+	courier, err := s.couriers.Get(ctx, courierID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return liveops.ErrAgentUnavailable
+		}
+		return err
+	}
+	if courier.IsBanned || courier.ApprovalStatus != repo.CourierApprovalApproved {
+		return liveops.ErrAgentUnavailable
+	}
+	limit, err := s.courierCanTake(ctx, courierID, order)
+	if err != nil {
+		switch {
+		case errors.Is(err, errCourierAtCapacity):
+			return liveops.ErrAgentBusy
+		case errors.Is(err, errVehicleCannotCarry), errors.Is(err, errDeclaredValueOverCap):
+			return liveops.ErrAgentUnavailable
+		}
+		return err
+	}
+
+	if err := s.orders.AssignCourierCAS(ctx, orderID, courierID, repo.StatusNew, lifecycle.StatusAccepted, limit); err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return liveops.ErrNotAssignable
+		}
+		if errors.Is(err, repo.ErrCourierAtCapacity) {
+			return liveops.ErrAgentBusy
+		}
+		return err
+	}
+
+	if order, err := s.orders.Get(ctx, orderID); err != nil {
+		s.logger.Errorf("courier: load order %d after dispatcher assign failed: %v", orderID, err)
+	} else {
+		s.emitOrder(ctx, order, orderEventTypeUpdated, originUnknown)
+	}
+	if s.cHub != nil {
+		s.cHub.Broadcast(map[string]any{
+			"type":       "order_assigned",
+			"order_id":   orderID,
+			"courier_id": courierID,
+			"by":         "dispatcher",
+		})
+	}
+	s.replanCourierRoute(courierID)
+	return nil
+}
+
+type sosRequest struct {
+	Message string   `json:"message"`
+	Lon     *float64 `json:"lon"`
+	Lat     *float64 `json:"lat"`
+}
+
+// handleOrderSOS: POST — тревожная кнопка отправителя или курьера, сигнал сразу уходит операторам.
+func (s *Server) handleOrderSOS(w http.ResponseWriter, r *http.Request, orderID int64, role string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	header := "X-Sender-ID"
+	if role == chat.RoleCourier {
+		header = "X-Courier-ID"
+	}
+	actorID, err := parseAuthID(r, header)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing "+role+" id")
+		return
+	}
+	if s.liveOps == nil {
+		writeError(w, http.StatusServiceUnavailable, "sos is unavailable")
+		return
+	}
+
+	var req sosRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	order, err := s.orders.Get(ctx, orderID)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load order %d for sos failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	if !chatParticipant(order, role, actorID) {
+		writeError(w, http.StatusForbidden, chat.ErrNotParticipant.Error())
+		return
+	}
+
+	alert := liveops.SOS{
+		Service:      liveops.ServiceCourier,
+		OrderID:      orderID,
+		ReporterRole: role,
+		ReporterID:   actorID,
+		City:         s.cfg.RegionKey,
+		Message:      req.Message,
+		CreatedAt:    time.Now(),
+	}
+	if req.Lon != nil && req.Lat != nil {
+		alert.Lon = sql.NullFloat64{Float64: *req.Lon, Valid: true}
+		alert.Lat = sql.NullFloat64{Float64: *req.Lat, Valid: true}
+	}
+	saved, err := s.liveOps.RaiseSOS(ctx, alert)
+	if err != nil {
+		s.logger.Errorf("courier: sos on order %d by %s %d failed: %v", orderID, role, actorID, err)
+		writeError(w, http.StatusInternalServerError, "failed to raise sos")
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"sos_id": saved.ID, "status": "received"})
+}
@@ -0,0 +1,296 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+const ctxMerchantKey ctxKey = "merchant"
+
+func merchantFromContext(ctx context.Context) (repo.Merchant, bool) {
+	m, ok := ctx.Value(ctxMerchantKey).(repo.Merchant)
+	return m, ok
+}
+
+type merchantTrackingLink struct {
+	Seq int    `json:"seq"`
+	URL string `json:"url"`
+}
+
+type merchantOrderResponse struct {
+	orderResponse
+	Tracking []merchantTrackingLink `json:"tracking,omitempty"`
+}
+
+// authenticateMerchant resolves the API key and hands the request over as the merchant's sender account.
+func (s *Server) authenticateMerchant(w http.ResponseWriter, r *http.Request, scope string) (*http.Request, repo.Merchant, bool) {
+	raw := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if raw == "" {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			raw = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+	}
+	if !strings.HasPrefix(raw, merchantKeyPrefix) {
+		writeError(w, http.StatusUnauthorized, "missing api key")
+		return nil, repo.Merchant{}, false
+	}
+	key, m, err := s.merchants.Authenticate(r.Context(), hashMerchantKey(raw), timeutil.Now())
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusUnauthorized, "invalid api key")
+		return nil, repo.Merchant{}, false
+	}
+	if err != nil {
+		s.logger.Errorf("courier: authenticate merchant key failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to authenticate")
+		return nil, repo.Merchant{}, false
+	}
+	if m.Status != repo.MerchantActive {
+		writeError(w, http.StatusForbidden, "merchant is blocked")
+		return nil, repo.Merchant{}, false
+	}
+	if !key.Allows(scope) {
+		writeError(w, http.StatusForbidden, "api key lacks scope "+scope)
+		return nil, repo.Merchant{}, false
+	}
+	// Заголовки выставляем сами: общие обработчики доверяют X-Sender-ID.
+	r = r.WithContext(context.WithValue(r.Context(), ctxMerchantKey, m))
+	r.Header.Set("X-Sender-ID", strconv.FormatInt(m.UserID, 10))
+	r.Header.Del("X-Courier-ID")
+	return r, m, true
+}
+
+// handleMerchantAPI mirrors the sender API for shops: quote, orders, cancellation, tracking, invoices.
+func (s *Server) handleMerchantAPI(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/merchant/courier/"), "/")
+	parts := strings.Split(path, "/")
+
+	var (
+		scope   string
+		handler func(http.ResponseWriter, *http.Request, repo.Merchant)
+	)
+	switch {
+	case len(parts) == 1 && parts[0] == "quote" && r.Method == http.MethodPost:
+		scope = repo.ScopeQuotes
+		handler = func(w http.ResponseWriter, r *http.Request, _ repo.Merchant) { s.handleQuote(w, r) }
+	case len(parts) == 1 && parts[0] == "orders" && r.Method == http.MethodPost:
+		scope = repo.ScopeOrdersWrite
+		handler = func(w http.ResponseWriter, r *http.Request, _ repo.Merchant) { s.handleCreateOrder(w, r) }
+	case len(parts) == 1 && parts[0] == "orders" && r.Method == http.MethodGet:
+		scope, handler = repo.ScopeOrdersRead, s.handleMerchantOrders
+	case len(parts) == 2 && parts[0] == "orders" && r.Method == http.MethodGet:
+		scope = repo.ScopeOrdersRead
+		handler = func(w http.ResponseWriter, r *http.Request, m repo.Merchant) {
+			if order, ok := s.loadMerchantOrder(w, r, m, parts[1]); ok {
+				writeJSON(w, http.StatusOK, map[string]interface{}{"order": s.merchantOrderView(r.Context(), order, true)})
+			}
+		}
+	case len(parts) == 3 && parts[0] == "orders" && parts[2] == "cancel" && r.Method == http.MethodPost:
+		scope = repo.ScopeOrdersWrite
+		handler = func(w http.ResponseWriter, r *http.Request, m repo.Merchant) {
+			if order, ok := s.loadMerchantOrder(w, r, m, parts[1]); ok {
+				s.handleCancelOrder(w, r, order.ID)
+			}
+		}
+	case len(parts) == 2 && parts[0] == "invoices" && r.Method == http.MethodGet:
+		scope = repo.ScopeBilling
+		handler = func(w http.ResponseWriter, r *http.Request, m repo.Merchant) {
+			s.handleMerchantInvoice(w, r, m, parts[1])
+		}
+	case len(parts) == 1 && parts[0] == "webhooks" && r.Method == http.MethodGet:
+		scope, handler = repo.ScopeOrdersRead, s.handleMerchantWebhookLog
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	r, m, ok := s.authenticateMerchant(w, r, scope)
+	if !ok {
+		return
+	}
+	handler(w, r, m)
+}
+
+// loadMerchantOrder finds the merchant's order by our id or by its own id prefixed with "ext:".
+func (s *Server) loadMerchantOrder(w http.ResponseWriter, r *http.Request, m repo.Merchant, ref string) (repo.Order, bool) {
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	var (
+		orderID int64
+		err     error
+	)
+	if ext, ok := strings.CutPrefix(ref, "ext:"); ok {
+		orderID, err = s.orders.MerchantOrderID(ctx, m.ID, ext)
+	} else if orderID, err = strconv.ParseInt(ref, 10, 64); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid order id")
+		return repo.Order{}, false
+	}
+	var order repo.Order
+	if err == nil {
+		order, err = s.orders.Get(ctx, orderID)
+	}
+	if err == nil && (!order.MerchantID.Valid || order.MerchantID.Int64 != m.ID) {
+		err = repo.ErrNotFound
+	}
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "order not found")
+		return repo.Order{}, false
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load order %s of merchant %d failed: %v", ref, m.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return repo.Order{}, false
+	}
+	return order, true
+}
+
+func (s *Server) merchantOrderView(ctx context.Context, order repo.Order, withTracking bool) merchantOrderResponse {
+	resp := merchantOrderResponse{orderResponse: s.orderView(order)}
+	if !withTracking {
+		return resp
+	}
+	stops, err := s.orders.TrackingStopsByOrder(ctx, order.ID)
+	if err != nil {
+		s.logger.Errorf("courier: load tracking links of order %d failed: %v", order.ID, err)
+		return resp
+	}
+	for _, stop := range stops {
+		if link := s.trackingURL(stop.Token); link != "" {
+			resp.Tracking = append(resp.Tracking, merchantTrackingLink{Seq: stop.Seq, URL: link})
+		}
+	}
+	return resp
+}
+
+// parseDateRange reads from/to (YYYY-MM-DD, inclusive) and defaults to the last 30 days.
+func parseDateRange(r *http.Request) (time.Time, time.Time, error) {
+	now := timeutil.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -30)
+	if v := strings.TrimSpace(r.URL.Query().Get("from")); v != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid from date")
+		}
+		from = parsed
+	}
+	if v := strings.TrimSpace(r.URL.Query().Get("to")); v != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid to date")
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("to date must be on or after from date")
+	}
+	return from, to, nil
+}
+
+// handleMerchantOrders — журнал доставок магазина за период.
+func (s *Server) handleMerchantOrders(w http.ResponseWriter, r *http.Request, m repo.Merchant) {
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, offset, err := parsePaging(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	orders, err := s.orders.ListByMerchant(ctx, m.ID, from, to, limit, offset)
+	if err != nil {
+		s.logger.Errorf("courier: list orders of merchant %d failed: %v", m.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to list orders")
+		return
+	}
+	items := make([]merchantOrderResponse, 0, len(orders))
+	for _, o := range orders {
+		items = append(items, s.merchantOrderView(ctx, o, false))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":   from.Format("2006-01-02"),
+		"to":     to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"orders": items,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+type invoiceLineResponse struct {
+	OrderID     int64     `json:"order_id"`
+	ExternalID  *string   `json:"external_id,omitempty"`
+	Status      string    `json:"status"`
+	ClientPrice int       `json:"client_price"`
+	Surcharge   int       `json:"surcharge"`
+	Insurance   int       `json:"insurance_fee"`
+	Total       int       `json:"total"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// handleMerchantInvoice — счёт за месяц: доставленные и возвращённые заказы с доплатами за ожидание и возврат.
+func (s *Server) handleMerchantInvoice(w http.ResponseWriter, r *http.Request, m repo.Merchant, period string) {
+	loc := timeutil.Now().Location()
+	from, err := time.ParseInLocation("2006-01", period, loc)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "period must be YYYY-MM")
+		return
+	}
+	to := from.AddDate(0, 1, 0)
+
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	lines, err := s.orders.MerchantInvoice(ctx, m.ID, from, to)
+	if err != nil {
+		s.logger.Errorf("courier: invoice of merchant %d for %s failed: %v", m.ID, period, err)
+		writeError(w, http.StatusInternalServerError, "failed to build invoice")
+		return
+	}
+
+	var delivered, returned, amount, surcharges, insurance int
+	items := make([]invoiceLineResponse, 0, len(lines))
+	for _, line := range lines {
+		if line.Status == repo.StatusReturned {
+			returned++
+		} else {
+			delivered++
+		}
+		amount += line.ClientPrice
+		surcharges += line.Surcharge
+		insurance += line.InsuranceFee
+		items = append(items, invoiceLineResponse{
+			OrderID:     line.OrderID,
+			ExternalID:  nullToPtr(line.ExternalID),
+			Status:      line.Status,
+			ClientPrice: line.ClientPrice,
+			Surcharge:   line.Surcharge,
+			Insurance:   line.InsuranceFee,
+			Total:       line.ClientPrice + line.Surcharge + line.InsuranceFee,
+			CreatedAt:   line.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"merchant_id": m.ID,
+		"period":      from.Format("2006-01"),
+		"lines":       items,
+		"totals": map[string]int{
+			"orders":     len(items),
+			"delivered":  delivered,
+			"returned":   returned,
+			"amount":     amount,
+			"surcharges": surcharges,
+			"insurance":  insurance,
+			"total":      amount + surcharges + insurance,
+		},
+	})
+}
@@ -0,0 +1,479 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+// merchantKeyPrefix marks merchant API keys so they are easy to spot in logs and secret scanners.
+const merchantKeyPrefix = "nmk_"
+
+type merchantResponse struct {
+	ID            int64     `json:"id"`
+	UserID        int64     `json:"user_id"`
+	Name          string    `json:"name"`
+	WebhookURL    *string   `json:"webhook_url"`
+	WebhookSecret string    `json:"webhook_secret,omitempty"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func makeMerchantResponse(m repo.Merchant) merchantResponse {
+	return merchantResponse{
+		ID:         m.ID,
+		UserID:     m.UserID,
+		Name:       m.Name,
+		WebhookURL: nullToPtr(m.WebhookURL),
+		Status:     m.Status,
+		CreatedAt:  m.CreatedAt,
+		UpdatedAt:  m.UpdatedAt,
+	}
+}
+
+type merchantKeyResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// Key is returned once, right after the key is issued.
+	Key string `json:"key,omitempty"`
+}
+
+func makeMerchantKeyResponse(k repo.MerchantKey) merchantKeyResponse {
+	return merchantKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		LastUsedAt: nullTimeToPtr(k.LastUsedAt),
+		ExpiresAt:  nullTimeToPtr(k.ExpiresAt),
+		RevokedAt:  nullTimeToPtr(k.RevokedAt),
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+// secretHex, в отличие от randomHex, не откатывается на время — для ключей и секретов.
+func secretHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// newMerchantKey returns the plaintext key, its display prefix and the hash we store.
+func newMerchantKey() (key, prefix, hash string, err error) {
+	id, err := secretHex(4)
+	if err != nil {
+		return "", "", "", err
+	}
+	secret, err := secretHex(24)
+	if err != nil {
+		return "", "", "", err
+	}
+	prefix = merchantKeyPrefix + id
+	key = prefix + "_" + secret
+	return key, prefix, hashMerchantKey(key), nil
+}
+
+func hashMerchantKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseWebhookURL accepts only https urls whose host resolves to public addresses; the dialer
+// repeats the address check on every delivery, since DNS may change after the url is saved.
+func parseWebhookURL(ctx context.Context, raw *string) (sql.NullString, error) {
+	value := nullableString(raw)
+	if !value.Valid {
+		return value, nil
+	}
+	u, err := url.Parse(value.String)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return sql.NullString{}, errors.New("webhook_url must be an absolute https url")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		if !publicIP(ip) {
+			return sql.NullString{}, errWebhookAddress
+		}
+		return value, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return sql.NullString{}, errors.New("webhook_url host does not resolve")
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return sql.NullString{}, errWebhookAddress
+		}
+	}
+	return value, nil
+}
+
+func parseScopes(raw []string) ([]string, error) {
+	if len(raw) == 0 {
+		return append([]string(nil), repo.MerchantScopes...), nil
+	}
+	known := stringSet(repo.MerchantScopes)
+	seen := make(map[string]bool, len(raw))
+	scopes := make([]string, 0, len(raw))
+	for _, s := range raw {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if _, ok := known[s]; !ok {
+			return nil, errors.New("unknown scope " + s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, nil
+}
+
+func stringSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, v := range items {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+// handleMerchantAccount — кабинет магазина в приложении отправителя: GET профиль, POST регистрация, PUT изменение.
+func (s *Server) handleMerchantAccount(w http.ResponseWriter, r *http.Request) {
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		m, ok := s.loadSenderMerchant(w, r, senderID)
+		if !ok {
+			return
+		}
+		resp := makeMerchantResponse(m)
+		resp.WebhookSecret = m.WebhookSecret
+		writeJSON(w, http.StatusOK, resp)
+	case http.MethodPost, http.MethodPut:
+		var req struct {
+			Name       string  `json:"name"`
+			WebhookURL *string `json:"webhook_url"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			writeError(w, http.StatusBadRequest, "name is required")
+			return
+		}
+		webhookURL, err := parseWebhookURL(r.Context(), req.WebhookURL)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var m repo.Merchant
+		if r.Method == http.MethodPost {
+			secret, err := secretHex(32)
+			if err != nil {
+				s.logger.Errorf("courier: generate webhook secret failed: %v", err)
+				writeError(w, http.StatusInternalServerError, "failed to register merchant")
+				return
+			}
+			m, err = s.merchants.Create(ctx, repo.Merchant{UserID: senderID, Name: name, WebhookURL: webhookURL, WebhookSecret: secret})
+			if errors.Is(err, repo.ErrMerchantExists) {
+				writeError(w, http.StatusConflict, "merchant already registered")
+				return
+			}
+			if err != nil {
+				s.logger.Errorf("courier: register merchant for user %d failed: %v", senderID, err)
+				writeError(w, http.StatusInternalServerError, "failed to register merchant")
+				return
+			}
+		} else {
+			current, ok := s.loadSenderMerchant(w, r, senderID)
+			if !ok {
+				return
+			}
+			if err := s.merchants.Update(ctx, current.ID, name, webhookURL); err != nil {
+				s.logger.Errorf("courier: update merchant %d failed: %v", current.ID, err)
+				writeError(w, http.StatusInternalServerError, "failed to update merchant")
+				return
+			}
+			if m, err = s.merchants.Get(ctx, current.ID); err != nil {
+				s.logger.Errorf("courier: reload merchant %d failed: %v", current.ID, err)
+				writeError(w, http.StatusInternalServerError, "failed to load merchant")
+				return
+			}
+		}
+		resp := makeMerchantResponse(m)
+		resp.WebhookSecret = m.WebhookSecret
+		status := http.StatusOK
+		if r.Method == http.MethodPost {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, resp)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMerchantAccountRoutes serves /merchant/keys, /merchant/keys/{id}[/rotate] and /merchant/webhook-secret.
+func (s *Server) handleMerchantAccountRoutes(w http.ResponseWriter, r *http.Request) {
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/courier/merchant/"), "/")
+	parts := strings.Split(path, "/")
+	m, ok := s.loadSenderMerchant(w, r, senderID)
+	if !ok {
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] == "webhook-secret":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleRotateWebhookSecret(w, r, m)
+	case len(parts) == 1 && parts[0] == "keys":
+		switch r.Method {
+		case http.MethodGet:
+			s.handleListMerchantKeys(w, r, m)
+		case http.MethodPost:
+			s.handleCreateMerchantKey(w, r, m)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	case len(parts) >= 2 && len(parts) <= 3 && parts[0] == "keys":
+		keyID, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid key id")
+			return
+		}
+		switch {
+		case len(parts) == 2 && r.Method == http.MethodDelete:
+			s.handleRevokeMerchantKey(w, r, m, keyID)
+		case len(parts) == 3 && parts[2] == "rotate" && r.Method == http.MethodPost:
+			s.handleRotateMerchantKey(w, r, m, keyID)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) loadSenderMerchant(w http.ResponseWriter, r *http.Request, senderID int64) (repo.Merchant, bool) {
+	m, err := s.merchants.GetByUser(r.Context(), senderID)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "merchant not registered")
+		return repo.Merchant{}, false
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load merchant of user %d failed: %v", senderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load merchant")
+		return repo.Merchant{}, false
+	}
+	return m, true
+}
+
+func (s *Server) handleRotateWebhookSecret(w http.ResponseWriter, r *http.Request, m repo.Merchant) {
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	secret, err := secretHex(32)
+	if err == nil {
+		err = s.merchants.SetWebhookSecret(ctx, m.ID, secret)
+	}
+	if err != nil {
+		s.logger.Errorf("courier: rotate webhook secret of merchant %d failed: %v", m.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to rotate webhook secret")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"webhook_secret": secret})
+}
+
+func (s *Server) handleListMerchantKeys(w http.ResponseWriter, r *http.Request, m repo.Merchant) {
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	keys, err := s.merchants.ListKeys(ctx, m.ID)
+	if err != nil {
+		s.logger.Errorf("courier: list keys of merchant %d failed: %v", m.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to list keys")
+		return
+	}
+	items := make([]merchantKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, makeMerchantKeyResponse(k))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": items})
+}
+
+func (s *Server) handleCreateMerchantKey(w http.ResponseWriter, r *http.Request, m repo.Merchant) {
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 64 {
+		writeError(w, http.StatusBadRequest, "name must be 1-64 characters")
+		return
+	}
+	scopes, err := parseScopes(req.Scopes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	plain, prefix, hash, err := newMerchantKey()
+	if err != nil {
+		s.logger.Errorf("courier: generate merchant key failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to issue key")
+		return
+	}
+	key, err := s.merchants.CreateKey(ctx, repo.MerchantKey{MerchantID: m.ID, Name: name, Prefix: prefix, Scopes: scopes}, hash)
+	if err != nil {
+		s.logger.Errorf("courier: store key of merchant %d failed: %v", m.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to issue key")
+		return
+	}
+	resp := makeMerchantKeyResponse(key)
+	resp.Key = plain
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) handleRotateMerchantKey(w http.ResponseWriter, r *http.Request, m repo.Merchant, keyID int64) {
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	plain, prefix, hash, err := newMerchantKey()
+	if err != nil {
+		s.logger.Errorf("courier: generate merchant key failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to rotate key")
+		return
+	}
+	graceUntil := timeutil.Now().Add(s.cfg.MerchantKeyGrace)
+	key, err := s.merchants.RotateKey(ctx, m.ID, keyID, repo.MerchantKey{Prefix: prefix}, hash, graceUntil)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: rotate key %d of merchant %d failed: %v", keyID, m.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to rotate key")
+		return
+	}
+	resp := makeMerchantKeyResponse(key)
+	resp.Key = plain
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"key": resp, "previous_expires_at": graceUntil})
+}
+
+func (s *Server) handleRevokeMerchantKey(w http.ResponseWriter, r *http.Request, m repo.Merchant, keyID int64) {
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	err := s.merchants.RevokeKey(ctx, m.ID, keyID, timeutil.Now())
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: revoke key %d of merchant %d failed: %v", keyID, m.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to revoke key")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminMerchants lists merchant accounts.
+func (s *Server) handleAdminMerchants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	limit, offset, err := parsePaging(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	merchants, err := s.merchants.List(ctx, limit, offset)
+	if err != nil {
+		s.logger.Errorf("courier: list merchants failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to list merchants")
+		return
+	}
+	items := make([]merchantResponse, 0, len(merchants))
+	for _, m := range merchants {
+		items = append(items, makeMerchantResponse(m))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"merchants": items, "limit": limit, "offset": offset})
+}
+
+// handleAdminMerchantRoutes serves POST /admin/courier/merchants/{id}/block and /unblock.
+func (s *Server) handleAdminMerchantRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/courier/merchants/"), "/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || (parts[1] != "block" && parts[1] != "unblock") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid merchant id")
+		return
+	}
+	status := repo.MerchantActive
+	if parts[1] == "block" {
+		status = repo.MerchantBlocked
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	err = s.merchants.SetStatus(ctx, id, status)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "merchant not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: set merchant %d status failed: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "failed to update merchant")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": status})
+}
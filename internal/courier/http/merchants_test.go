@@ -0,0 +1,186 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"naimuBack/internal/courier/repo"
+)
+
+func TestNewMerchantKey(t *testing.T) {
+	key, prefix, hash, err := newMerchantKey()
+	if err != nil {
+		t.Fatalf("new key: %v", err)
+	}
+	if !strings.HasPrefix(key, prefix+"_") || !strings.HasPrefix(prefix, merchantKeyPrefix) {
+		t.Fatalf("unexpected key %q with prefix %q", key, prefix)
+	}
+	if hash != hashMerchantKey(key) || len(hash) != 64 {
+		t.Fatalf("unexpected hash %q", hash)
+	}
+	if strings.Contains(hash, key) || hashMerchantKey(key+"x") == hash {
+		t.Fatalf("hash must depend on the whole key")
+	}
+	other, _, _, err := newMerchantKey()
+	if err != nil || other == key {
+		t.Fatalf("expected distinct keys, got %q twice (%v)", key, err)
+	}
+}
+
+func TestMerchantKeyScopes(t *testing.T) {
+	all, err := parseScopes(nil)
+	if err != nil || len(all) != len(repo.MerchantScopes) {
+		t.Fatalf("expected all scopes by default, got %v (%v)", all, err)
+	}
+	scopes, err := parseScopes([]string{" Orders:Read ", "orders:read", "quotes"})
+	if err != nil {
+		t.Fatalf("parse scopes: %v", err)
+	}
+	key := repo.MerchantKey{Scopes: scopes}
+	cases := []struct {
+		scope string
+		want  bool
+	}{
+		{repo.ScopeOrdersRead, true},
+		{repo.ScopeQuotes, true},
+		{repo.ScopeOrdersWrite, false},
+		{repo.ScopeBilling, false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		if got := key.Allows(tc.scope); got != tc.want {
+			t.Fatalf("scope %q: expected %v got %v", tc.scope, tc.want, got)
+		}
+	}
+	if len(scopes) != 2 {
+		t.Fatalf("expected duplicates dropped, got %v", scopes)
+	}
+	if _, err := parseScopes([]string{"admin"}); err == nil {
+		t.Fatalf("expected unknown scope to be rejected")
+	}
+}
+
+func TestSignWebhook(t *testing.T) {
+	got := signWebhook("secret", "1700000000", []byte(`{"order_id":1}`))
+	if want := "7e642bb2d66db7c3e9958a307b1e8950f2166d2aee35c076c6dfb34d1a3ded78"; got != want {
+		t.Fatalf("expected %s got %s", want, got)
+	}
+	if signWebhook("other", "1700000000", []byte(`{"order_id":1}`)) == got {
+		t.Fatalf("signature must depend on the secret")
+	}
+	if signWebhook("secret", "1700000001", []byte(`{"order_id":1}`)) == got {
+		t.Fatalf("signature must depend on the timestamp")
+	}
+}
+
+func TestParseWebhookURL(t *testing.T) {
+	cases := []struct {
+		name string
+		url  string
+		ok   bool
+	}{
+		{"public ip", "https://93.184.216.34/hooks", true},
+		{"public ip with port", "https://93.184.216.34:8443/hooks", true},
+		{"plain http", "http://93.184.216.34/hooks", false},
+		{"relative", "/hooks", false},
+		{"loopback", "https://127.0.0.1/hooks", false},
+		{"localhost", "https://localhost/hooks", false},
+		{"ipv6 loopback", "https://[::1]/hooks", false},
+		{"private", "https://10.0.0.5/hooks", false},
+		{"private 192", "https://192.168.1.10/hooks", false},
+		{"metadata", "https://169.254.169.254/latest/meta-data", false},
+		{"unspecified", "https://0.0.0.0/hooks", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := tc.url
+			got, err := parseWebhookURL(context.Background(), &raw)
+			if tc.ok && (err != nil || got.String != tc.url) {
+				t.Fatalf("expected %q accepted, got %v", tc.url, err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected %q rejected", tc.url)
+			}
+		})
+	}
+
+	if got, err := parseWebhookURL(context.Background(), nil); err != nil || got.Valid {
+		t.Fatalf("expected empty url to clear the webhook, got %v %v", got, err)
+	}
+}
+
+func TestWebhookDialControl(t *testing.T) {
+	cases := []struct {
+		address string
+		ok      bool
+	}{
+		{"93.184.216.34:443", true},
+		{"127.0.0.1:443", false},
+		{"10.1.2.3:443", false},
+		{"172.16.0.1:443", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:443", false},
+		{"[fd00::1]:443", false},
+		{"0.0.0.0:443", false},
+	}
+
+	for _, tc := range cases {
+		err := webhookDialControl("tcp", tc.address, nil)
+		if tc.ok != (err == nil) {
+			t.Fatalf("%s: unexpected result %v", tc.address, err)
+		}
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	var gotSig, gotTS string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig, gotTS = r.Header.Get("X-Webhook-Signature"), r.Header.Get("X-Webhook-Timestamp")
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+			return
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("internal secret"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	hook := repo.MerchantWebhook{ID: 1, Event: merchantWebhookEvent, Secret: "secret", Payload: []byte(`{"order_id":1}`)}
+	s := testServer()
+
+	// тестовый сервер слушает loopback — боевой клиент туда не подключится
+	s.webhookClient = newWebhookClient()
+	hook.URL = srv.URL + "/ok"
+	if err := s.postWebhook(context.Background(), hook); !errors.Is(err, errWebhookAddress) {
+		t.Fatalf("expected loopback to be refused, got %v", err)
+	}
+
+	s.webhookClient = newWebhookClient()
+	s.webhookClient.Transport = nil
+	if err := s.postWebhook(context.Background(), hook); err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	if gotSig != signWebhook(hook.Secret, gotTS, hook.Payload) {
+		t.Fatalf("unexpected signature %q", gotSig)
+	}
+
+	hook.URL = srv.URL + "/fail"
+	err := s.postWebhook(context.Background(), hook)
+	if err == nil || strings.Contains(err.Error(), "internal secret") || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("expected status-only error, got %v", err)
+	}
+
+	hook.URL = srv.URL + "/redirect"
+	if err := s.postWebhook(context.Background(), hook); err == nil || !strings.Contains(err.Error(), "302") {
+		t.Fatalf("expected redirect not to be followed, got %v", err)
+	}
+}
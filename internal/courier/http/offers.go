@@ -45,6 +45,18 @@ func (s *Server) handleOfferPrice(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 	ctx = withCourierActor(ctx, courierID)
 
+	if order, err := s.orders.Get(ctx, req.OrderID); err == nil {
+		if _, err := s.courierCanTake(ctx, courierID, order); err != nil {
+			if errors.Is(err, errVehicleCannotCarry) || errors.Is(err, errDeclaredValueOverCap) || errors.Is(err, errCourierAtCapacity) {
+				writeError(w, http.StatusConflict, err.Error())
+				return
+			}
+			if s.logger != nil {
+				s.logger.Errorf("courier: check capacity for order %d failed: %v", req.OrderID, err)
+			}
+		}
+	}
+
 	if err := s.offers.Upsert(ctx, req.OrderID, courierID, req.Price); err != nil {
 		if s.logger != nil {
 			s.logger.Errorf("courier: upsert offer failed: %v", err)
@@ -105,6 +117,11 @@ func (s *Server) handleOfferAccept(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 	ctx = withSenderActor(ctx, senderID)
 
+	limit, ok := s.checkCourierCapacity(ctx, w, req.OrderID, req.CourierID)
+	if !ok {
+		return
+	}
+
 	// 1) Обновляем оффер
 	if err := s.offers.UpdateStatus(ctx, req.OrderID, req.CourierID, repo.OfferStatusAccepted); err != nil {
 		if errors.Is(err, repo.ErrNotFound) {
@@ -119,7 +136,7 @@ func (s *Server) handleOfferAccept(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 2) Назначаем курьера заказу
-	if err := s.orders.AssignCourier(ctx, req.OrderID, req.CourierID, lifecycle.StatusAccepted); err != nil {
+	if err := s.orders.AssignCourier(ctx, req.OrderID, req.CourierID, lifecycle.StatusAccepted, limit); err != nil {
 		if errors.Is(err, repo.ErrNotFound) {
 			writeError(w, http.StatusNotFound, "order not found")
 			return
@@ -161,6 +178,7 @@ func (s *Server) handleOfferAccept(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	s.replanCourierRoute(req.CourierID)
 	writeJSON(w, http.StatusOK, map[string]string{"status": repo.StatusAccepted})
 }
 
@@ -255,6 +273,10 @@ func (s *Server) handleOfferRespond(w http.ResponseWriter, r *http.Request) {
 	var respStatus string
 
 	if decision == "accept" {
+		limit, ok := s.checkCourierCapacity(ctx, w, req.OrderID, req.CourierID)
+		if !ok {
+			return
+		}
 		// 1) Приняли оффер
 		if err := s.offers.UpdateStatus(ctx, req.OrderID, req.CourierID, repo.OfferStatusAccepted); err != nil {
 			if errors.Is(err, repo.ErrNotFound) {
@@ -268,7 +290,7 @@ func (s *Server) handleOfferRespond(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		// 2) Назначили курьера заказу
-		if err := s.orders.AssignCourier(ctx, req.OrderID, req.CourierID, lifecycle.StatusAccepted); err != nil {
+		if err := s.orders.AssignCourier(ctx, req.OrderID, req.CourierID, lifecycle.StatusAccepted, limit); err != nil {
 			if errors.Is(err, repo.ErrNotFound) {
 				writeError(w, http.StatusNotFound, "order not found")
 				return
@@ -305,6 +327,7 @@ func (s *Server) handleOfferRespond(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		s.replanCourierRoute(req.CourierID)
 		respStatus = string(repo.StatusAccepted)
 	} else {
 		// decline
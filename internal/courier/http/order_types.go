@@ -16,6 +16,8 @@ type orderPointInput struct {
 	Intercom *string `json:"intercom"`
 	Phone    *string `json:"phone"`
 	Comment  *string `json:"comment"`
+	// AddressID подставляет адрес из адресной книги; заполненные поля точки его перекрывают.
+	AddressID *int64 `json:"address_id,omitempty"`
 }
 
 type orderPointResponse struct {
@@ -29,11 +31,14 @@ type orderPointResponse struct {
 	Phone    *string `json:"phone"`
 	Comment  *string `json:"comment"`
 	Seq      int     `json:"seq"`
+	// LegPolyline — геометрия участка до следующей точки.
+	LegPolyline string `json:"leg_polyline,omitempty"`
 }
 
 type orderResponse struct {
 	ID               int64                `json:"id"`
 	SenderID         int64                `json:"sender_id"`
+	ExternalID       *string              `json:"external_id,omitempty"`
 	CourierID        *int64               `json:"courier_id"`
 	DistanceM        int                  `json:"distance_m"`
 	EtaSeconds       int                  `json:"eta_s"`
@@ -42,6 +47,11 @@ type orderResponse struct {
 	PaymentMethod    string               `json:"payment_method"`
 	Status           string               `json:"status"`
 	Comment          *string              `json:"comment"`
+	Parcel           *parcelResponse      `json:"parcel,omitempty"`
+	COD              *codResponse         `json:"cod,omitempty"`
+	Insurance        *insuranceResponse   `json:"insurance,omitempty"`
+	Schedule         *scheduleResponse    `json:"schedule,omitempty"`
+	Return           *returnResponse      `json:"return,omitempty"`
 	CreatedAt        time.Time            `json:"created_at"`
 	UpdatedAt        time.Time            `json:"updated_at"`
 	RoutePoints      []orderPointResponse `json:"route_points"`
@@ -63,16 +73,17 @@ func makeOrderResponse(o repo.Order) orderResponse {
 	points := make([]orderPointResponse, 0, len(o.Points))
 	for _, p := range o.Points {
 		points = append(points, orderPointResponse{
-			Address:  p.Address,
-			Lat:      p.Lat,
-			Lon:      p.Lon,
-			Entrance: nullToPtr(p.Entrance),
-			Apt:      nullToPtr(p.Apt),
-			Floor:    nullToPtr(p.Floor),
-			Intercom: nullToPtr(p.Intercom),
-			Phone:    nullToPtr(p.Phone),
-			Comment:  nullToPtr(p.Comment),
-			Seq:      p.Seq,
+			Address:     p.Address,
+			Lat:         p.Lat,
+			Lon:         p.Lon,
+			Entrance:    nullToPtr(p.Entrance),
+			Apt:         nullToPtr(p.Apt),
+			Floor:       nullToPtr(p.Floor),
+			Intercom:    nullToPtr(p.Intercom),
+			Phone:       nullToPtr(p.Phone),
+			Comment:     nullToPtr(p.Comment),
+			Seq:         p.Seq,
+			LegPolyline: p.LegPolyline.String,
 		})
 	}
 	var sender *userResponse
@@ -88,6 +99,7 @@ func makeOrderResponse(o repo.Order) orderResponse {
 	return orderResponse{
 		ID:               o.ID,
 		SenderID:         o.SenderID,
+		ExternalID:       nullToPtr(o.ExternalID),
 		CourierID:        courierID,
 		DistanceM:        o.DistanceM,
 		EtaSeconds:       o.EtaSeconds,
@@ -96,6 +108,11 @@ func makeOrderResponse(o repo.Order) orderResponse {
 		PaymentMethod:    o.PaymentMethod,
 		Status:           o.Status,
 		Comment:          comment,
+		Parcel:           makeParcelResponse(o.Parcel),
+		COD:              makeCODResponse(o.COD),
+		Insurance:        makeInsuranceResponse(o.Insurance),
+		Schedule:         makeScheduleResponse(o.Schedule),
+		Return:           makeReturnResponse(o.Return),
 		CreatedAt:        o.CreatedAt,
 		UpdatedAt:        o.UpdatedAt,
 		RoutePoints:      points,
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"naimuBack/internal/chat"
 	"naimuBack/internal/courier/lifecycle"
 	"naimuBack/internal/courier/pricing"
 	"naimuBack/internal/courier/repo"
@@ -29,6 +30,26 @@ func (s *Server) handleOrders(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// createOrderRequest is the body of order creation; templates store it for repeat orders.
+type createOrderRequest struct {
+	DistanceM     int               `json:"distance_m"`
+	EtaSeconds    int               `json:"eta_s"`
+	ClientPrice   int               `json:"client_price"`
+	PaymentMethod string            `json:"payment_method"`
+	Comment       *string           `json:"comment"`
+	RoutePoints   []orderPointInput `json:"route_points"`
+	Parcel        *parcelInput      `json:"parcel"`
+	// CODAmount — сумма, которую курьер заберёт у получателя за товар.
+	CODAmount int `json:"cod_amount"`
+	// DeclaredValue — объявленная ценность; ненулевая включает страховку за отдельную плату.
+	DeclaredValue int `json:"declared_value"`
+	// Окна ко времени; без них заказ уходит в поиск сразу.
+	PickupWindow  *windowInput `json:"pickup_window,omitempty"`
+	DropoffWindow *windowInput `json:"dropoff_window,omitempty"`
+	// ExternalID — номер заказа в системе магазина, только для merchant API.
+	ExternalID *string `json:"external_id,omitempty"`
+}
+
 func (s *Server) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
 	senderID, err := parseAuthID(r, "X-Sender-ID")
 	if err != nil {
@@ -36,41 +57,100 @@ func (s *Server) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		DistanceM     int               `json:"distance_m"`
-		EtaSeconds    int               `json:"eta_s"`
-		ClientPrice   int               `json:"client_price"`
-		PaymentMethod string            `json:"payment_method"`
-		Comment       *string           `json:"comment"`
-		RoutePoints   []orderPointInput `json:"route_points"`
-	}
+	var req createOrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid json")
 		return
 	}
 
-	if len(req.RoutePoints) < 2 {
-		writeError(w, http.StatusBadRequest, "at least two route points required")
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	ctx = withSenderActor(ctx, senderID)
+
+	order, err := s.buildOrder(ctx, senderID, req, timeutil.Now())
+	if err != nil {
+		var inputErr orderInputError
+		if errors.As(err, &inputErr) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.logger.Errorf("courier: build order failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to create order")
 		return
 	}
-	if req.ClientPrice < s.cfg.MinPrice {
-		writeError(w, http.StatusBadRequest, fmt.Sprintf("client price must be >= %d", s.cfg.MinPrice))
+	merchant, fromMerchant := merchantFromContext(r.Context())
+	if fromMerchant {
+		order.MerchantID = sql.NullInt64{Int64: merchant.ID, Valid: true}
+		order.ExternalID = nullableString(req.ExternalID)
+		if len(order.ExternalID.String) > 64 {
+			writeError(w, http.StatusBadRequest, "external_id must be at most 64 characters")
+			return
+		}
+	}
+
+	orderID, dispatchAt, err := s.placeOrder(ctx, order)
+	if errors.Is(err, repo.ErrDuplicateExternalID) {
+		existing, _ := s.orders.MerchantOrderID(ctx, merchant.ID, order.ExternalID.String)
+		writeJSON(w, http.StatusConflict, map[string]interface{}{"error": "external_id already used", "order_id": existing})
 		return
 	}
-	if req.PaymentMethod != "cash" && req.PaymentMethod != "online" {
-		writeError(w, http.StatusBadRequest, "invalid payment method")
+	if err != nil {
+		s.logger.Errorf("courier: create order failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to create order")
 		return
 	}
 
-	points := make([]repo.OrderPoint, 0, len(req.RoutePoints))
-	for idx, p := range req.RoutePoints {
+	writeJSON(w, http.StatusCreated, createdOrderResponse(orderID, order, dispatchAt))
+	s.emitOrderEvent(ctx, orderID, orderEventTypeCreated, originSender)
+	s.sendRecipientPINs(orderID)
+}
+
+// orderInputError marks validation failures of an order request.
+type orderInputError struct{ msg string }
+
+func (e orderInputError) Error() string { return e.msg }
+
+func orderInputErrorf(format string, args ...interface{}) error {
+	return orderInputError{msg: fmt.Sprintf(format, args...)}
+}
+
+// buildOrder validates the request, fills saved addresses and prices the order without storing it.
+func (s *Server) buildOrder(ctx context.Context, senderID int64, req createOrderRequest, now time.Time) (repo.Order, error) {
+	if len(req.RoutePoints) < 2 {
+		return repo.Order{}, orderInputErrorf("at least two route points required")
+	}
+	if req.ClientPrice < s.cfg.MinPrice {
+		return repo.Order{}, orderInputErrorf("client price must be >= %d", s.cfg.MinPrice)
+	}
+	if req.PaymentMethod != "cash" && req.PaymentMethod != "online" {
+		return repo.Order{}, orderInputErrorf("invalid payment method")
+	}
+	parcelInfo, err := req.Parcel.toParcel()
+	if err != nil {
+		return repo.Order{}, orderInputErrorf("%s", err.Error())
+	}
+	if req.CODAmount < 0 {
+		return repo.Order{}, orderInputErrorf("cod_amount must not be negative")
+	}
+	if err := s.validateDeclaredValue(req.DeclaredValue); err != nil {
+		return repo.Order{}, orderInputErrorf("%s", err.Error())
+	}
+	schedule, err := parseSchedule(req.PickupWindow, req.DropoffWindow, now)
+	if err != nil {
+		return repo.Order{}, orderInputErrorf("%s", err.Error())
+	}
+
+	inputs, err := s.resolveSavedAddresses(ctx, senderID, req.RoutePoints)
+	if err != nil {
+		return repo.Order{}, err
+	}
+	points := make([]repo.OrderPoint, 0, len(inputs))
+	for idx, p := range inputs {
 		if strings.TrimSpace(p.Address) == "" {
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("route point %d missing address", idx))
-			return
+			return repo.Order{}, orderInputErrorf("route point %d missing address", idx)
 		}
 		if p.Lat == 0 && p.Lon == 0 {
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("route point %d missing coordinates", idx))
-			return
+			return repo.Order{}, orderInputErrorf("route point %d missing coordinates", idx)
 		}
 		points = append(points, repo.OrderPoint{
 			Seq:      idx,
@@ -86,46 +166,70 @@ func (s *Server) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	recommended := pricing.Recommended(req.DistanceM, s.cfg.PricePerKM, s.cfg.MinPrice)
 	order := repo.Order{
 		SenderID:         senderID,
 		DistanceM:        req.DistanceM,
 		EtaSeconds:       req.EtaSeconds,
-		RecommendedPrice: recommended,
+		RecommendedPrice: pricing.RecommendedParcel(req.DistanceM, s.cfg.PricePerKM, s.cfg.MinPrice, parcelInfo),
 		ClientPrice:      req.ClientPrice,
 		PaymentMethod:    req.PaymentMethod,
+		Parcel:           parcelInfo,
+		COD:              repo.COD{Amount: req.CODAmount},
+		Insurance:        repo.Insurance{DeclaredValue: req.DeclaredValue, Fee: s.cfg.Insurance.Fee(req.DeclaredValue)},
+		Schedule:         schedule,
 		Points:           points,
 	}
 	if req.Comment != nil && strings.TrimSpace(*req.Comment) != "" {
 		order.Comment = sql.NullString{String: strings.TrimSpace(*req.Comment), Valid: true}
 	}
+	return order, nil
+}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-	ctx = withSenderActor(ctx, senderID)
+// placeOrder stores the order with its dispatch record and starts the search when it is due.
+// It returns when the search starts.
+func (s *Server) placeOrder(ctx context.Context, order repo.Order) (int64, time.Time, error) {
+	polylines := s.routePolylines(ctx, orderRoutePoints(order.Points))
+	for i := range order.Points {
+		order.Points[i].LegPolyline = legPolyline(polylines, i)
+	}
 
-	dispatchRec := repo.DispatchRecord{RadiusM: s.cfg.GetSearchRadiusStart(), NextTickAt: timeutil.Now(), State: "searching"}
+	now := timeutil.Now()
+	dispatchRec := repo.DispatchRecord{RadiusM: s.cfg.GetSearchRadiusStart(), NextTickAt: now, State: "searching"}
+	if order.Schedule.Scheduled() {
+		start := scheduleDispatchStart(order.Schedule, order.EtaSeconds, s.cfg.ScheduleLead, now)
+		dispatchRec.NextTickAt = start
+		dispatchRec.StartsAt = sql.NullTime{Time: start, Valid: true}
+	}
 
 	orderID, err := s.orders.CreateWithDispatch(ctx, order, dispatchRec)
 	if err != nil {
-		s.logger.Errorf("courier: create order failed: %v", err)
-		writeError(w, http.StatusInternalServerError, "failed to create order")
-		return
+		return 0, time.Time{}, err
 	}
-
-	if s.dispatcher != nil {
+	if s.dispatcher != nil && !dispatchRec.NextTickAt.After(now) {
 		if err := s.dispatcher.TriggerImmediate(context.Background(), orderID); err != nil {
 			s.logger.Errorf("courier: trigger dispatch failed: %v", err)
 		}
 	}
+	return orderID, dispatchRec.NextTickAt, nil
+}
 
+func createdOrderResponse(orderID int64, order repo.Order, dispatchAt time.Time) map[string]interface{} {
 	resp := map[string]interface{}{
 		"order_id":          orderID,
-		"recommended_price": recommended,
+		"recommended_price": order.RecommendedPrice,
+		"parcel":            makeParcelResponse(order.Parcel),
 		"status":            repo.StatusNew,
 	}
-	writeJSON(w, http.StatusCreated, resp)
-	s.emitOrderEvent(ctx, orderID, orderEventTypeCreated, originSender)
+	if order.Schedule.Scheduled() {
+		resp["dispatch_at"] = dispatchAt
+	}
+	if order.ExternalID.Valid {
+		resp["external_id"] = order.ExternalID.String
+	}
+	if order.Insurance.DeclaredValue > 0 {
+		resp["insurance"] = makeInsuranceResponse(order.Insurance)
+	}
+	return resp
 }
 
 func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request) {
@@ -159,7 +263,7 @@ func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request) {
 
 	resp := make([]orderResponse, 0, len(orders))
 	for _, o := range orders {
-		resp = append(resp, makeOrderResponse(o))
+		resp = append(resp, s.orderView(o))
 	}
 	writeJSON(w, http.StatusOK, map[string]interface{}{"orders": resp})
 }
@@ -196,7 +300,7 @@ func (s *Server) handleActiveOrder(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "failed to load active order")
 		return
 	}
-	writeJSON(w, http.StatusOK, makeOrderResponse(order))
+	writeJSON(w, http.StatusOK, s.orderView(order))
 }
 
 func (s *Server) handleCourierOrders(w http.ResponseWriter, r *http.Request) {
@@ -225,7 +329,7 @@ func (s *Server) handleCourierOrders(w http.ResponseWriter, r *http.Request) {
 	}
 	resp := make([]orderResponse, 0, len(orders))
 	for _, o := range orders {
-		resp = append(resp, makeOrderResponse(o))
+		resp = append(resp, s.orderView(o))
 	}
 	writeJSON(w, http.StatusOK, map[string]interface{}{"orders": resp})
 }
@@ -254,11 +358,23 @@ func (s *Server) handleCourierOrdersSubroutes(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	if len(parts) == 2 && parts[1] == "chat" {
+		s.handleOrderChat(w, r, id, chat.RoleCourier)
 		return
 	}
-
+	if len(parts) == 2 && parts[1] == "sos" {
+		s.handleOrderSOS(w, r, id, chat.RoleCourier)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "cod" {
+		s.handleOrderCODCollect(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "reserve" {
+		s.handleOrderReservation(w, r, id)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
 }
 
 func (s *Server) handleCourierActiveOrder(w http.ResponseWriter, r *http.Request) {
@@ -284,7 +400,18 @@ func (s *Server) handleCourierActiveOrder(w http.ResponseWriter, r *http.Request
 		writeError(w, http.StatusInternalServerError, "failed to load active order")
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]interface{}{"order": makeOrderResponse(order)})
+	// При батчинге активных заказов может быть несколько; "order" оставлен для старых клиентов.
+	active, err := s.orders.ListActiveByCourier(ctx, courierID)
+	if err != nil {
+		s.logger.Errorf("courier: list active courier orders failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load active orders")
+		return
+	}
+	views := make([]orderResponse, 0, len(active))
+	for _, o := range active {
+		views = append(views, s.orderView(o))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"order": s.orderView(order), "orders": views})
 }
 
 func (s *Server) handleOrderSubroutes(w http.ResponseWriter, r *http.Request) {
@@ -311,6 +438,23 @@ func (s *Server) handleOrderSubroutes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(parts) == 2 && parts[1] == "chat" {
+		s.handleOrderChat(w, r, id, chat.RoleSender)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "sos" {
+		s.handleOrderSOS(w, r, id, chat.RoleSender)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "proofs" {
+		s.handleOrderProofs(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "claim" {
+		s.handleOrderClaim(w, r, id)
+		return
+	}
+
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
@@ -345,12 +489,38 @@ func (s *Server) handleOrderSubroutes(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		w.WriteHeader(http.StatusNotFound)
+	case "points":
+		if len(parts) == 4 && parts[3] == "deliver" {
+			pointID, err := strconv.ParseInt(parts[2], 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid point id")
+				return
+			}
+			s.handlePointDeliver(w, r, id, pointID)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
 	case "pause":
 		s.handleLifecyclePause(w, r, id)
 	case "resume":
 		s.handleLifecycleResume(w, r, id)
 	case "no-show":
 		s.handleLifecycleUpdate(w, r, id, lifecycle.StatusCanceledNoShow)
+	case "delivery":
+		if len(parts) == 3 {
+			s.handleOrderDelivery(w, r, id, parts[2])
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case "return":
+		switch len(parts) {
+		case 2:
+			s.handleOrderReturn(w, r, id, "")
+		case 3:
+			s.handleOrderReturn(w, r, id, parts[2])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
 	default:
 		w.WriteHeader(http.StatusNotFound)
 	}
@@ -395,7 +565,7 @@ func (s *Server) handleReprice(w http.ResponseWriter, r *http.Request, orderID i
 		return
 	}
 	s.emitOrder(ctx, order, orderEventTypeUpdated, originSender)
-	writeJSON(w, http.StatusOK, map[string]interface{}{"order": makeOrderResponse(order)})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"order": s.orderView(order)})
 }
 
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request, orderID int64) {
@@ -477,7 +647,7 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request, orderID in
 		// Пытаемся отдать актуальный заказ в WS и клиенту
 		if updated, err := s.orders.Get(ctx, orderID); err == nil {
 			s.emitOrder(ctx, updated, orderEventTypeUpdated, origin)
-			writeJSON(w, http.StatusOK, map[string]interface{}{"order": makeOrderResponse(updated)})
+			writeJSON(w, http.StatusOK, map[string]interface{}{"order": s.orderView(updated)})
 			return
 		} else {
 			// fallback: эмитим по id, если не удалось перечитать заказ
@@ -508,6 +678,15 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request, orderID in
 		writeError(w, http.StatusConflict, "invalid status transition")
 		return
 	}
+	// Недоставка и возврат считают платное ожидание и обратный путь — только через свои действия.
+	if deliveryFlowStatus(status) {
+		writeError(w, http.StatusConflict, "use delivery and return actions for this status")
+		return
+	}
+
+	if status == lifecycle.StatusCompleted && (!s.requireDeliveryProofs(ctx, w, orderID) || !s.requireCODConfirmed(ctx, w, orderID)) {
+		return
+	}
 
 	// Обновляем статус
 	if err := s.orders.UpdateStatusWithNote(ctx, orderID, status, req.Note); err != nil {
@@ -521,11 +700,14 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request, orderID in
 		writeError(w, http.StatusInternalServerError, "failed to update status")
 		return
 	}
+	if status == lifecycle.StatusCompleted {
+		s.settleCommission(ctx, orderID)
+	}
 
 	// Публикуем событие и отвечаем клиенту
 	if updated, err := s.orders.Get(ctx, orderID); err == nil {
 		s.emitOrder(ctx, updated, orderEventTypeUpdated, origin)
-		writeJSON(w, http.StatusOK, map[string]interface{}{"order": makeOrderResponse(updated)})
+		writeJSON(w, http.StatusOK, map[string]interface{}{"order": s.orderView(updated)})
 		return
 	} else {
 		if s.logger != nil {
@@ -723,6 +905,9 @@ func (s *Server) handleLifecycleWaypointNext(w http.ResponseWriter, r *http.Requ
 		writeError(w, http.StatusConflict, "order not ready for next waypoint")
 		return
 	}
+	if !s.requireDeliveryProofs(ctx, w, orderID) || !s.requireCODConfirmed(ctx, w, orderID) {
+		return
+	}
 	if err := s.orders.UpdateStatus(ctx, orderID, lifecycle.StatusCompleted, sql.NullString{}); err != nil {
 		if errors.Is(err, repo.ErrNotFound) {
 			writeError(w, http.StatusConflict, "order status changed")
@@ -732,6 +917,7 @@ func (s *Server) handleLifecycleWaypointNext(w http.ResponseWriter, r *http.Requ
 		writeError(w, http.StatusInternalServerError, "failed to advance order")
 		return
 	}
+	s.settleCommission(ctx, orderID)
 	s.emitOrderEvent(ctx, orderID, orderEventTypeUpdated, originCourier)
 	writeJSON(w, http.StatusOK, map[string]string{"status": lifecycle.StatusCompleted})
 }
@@ -803,7 +989,7 @@ func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request, orderID
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{"order": makeOrderResponse(order)})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"order": s.orderView(order)})
 }
 
 func (s *Server) handleCancelOrder(w http.ResponseWriter, r *http.Request, orderID int64) {
@@ -898,6 +1084,10 @@ func (s *Server) handleLifecycleUpdate(w http.ResponseWriter, r *http.Request, o
 	ctx = withCourierActor(ctx, courierID)
 	ctx = withSenderActor(ctx, senderID)
 
+	if status == lifecycle.StatusCompleted && (!s.requireDeliveryProofs(ctx, w, orderID) || !s.requireCODConfirmed(ctx, w, orderID)) {
+		return
+	}
+
 	if err := s.orders.UpdateStatus(ctx, orderID, status, sql.NullString{}); err != nil {
 		if errors.Is(err, repo.ErrNotFound) {
 			writeError(w, http.StatusNotFound, "order not found")
@@ -907,6 +1097,9 @@ func (s *Server) handleLifecycleUpdate(w http.ResponseWriter, r *http.Request, o
 		writeError(w, http.StatusConflict, err.Error())
 		return
 	}
+	if status == lifecycle.StatusCompleted {
+		s.settleCommission(ctx, orderID)
+	}
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": status})
 	s.emitOrderEvent(ctx, orderID, orderEventTypeUpdated, origin)
@@ -0,0 +1,177 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"naimuBack/internal/courier/parcel"
+	"naimuBack/internal/courier/pricing"
+	"naimuBack/internal/courier/repo"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+
+func testServer() *Server {
+	return &Server{
+		cfg: Config{
+			PricePerKM: 100,
+			MinPrice:   500,
+			Insurance: pricing.InsuranceRules{
+				FeePercent: 1,
+				MinFee:     100,
+				Caps:       map[string]int{repo.CourierVerificationBasic: 50000},
+			},
+		},
+		logger: nopLogger{},
+	}
+}
+
+func validOrderRequest() createOrderRequest {
+	comment := "  позвонить заранее "
+	return createOrderRequest{
+		DistanceM:     3000,
+		EtaSeconds:    600,
+		ClientPrice:   900,
+		PaymentMethod: "cash",
+		Comment:       &comment,
+		RoutePoints: []orderPointInput{
+			{Address: " Кенесары 1 ", Lat: 51.13, Lon: 71.41},
+			{Address: "Туран 20", Lat: 51.12, Lon: 71.43},
+		},
+	}
+}
+
+func TestBuildOrder(t *testing.T) {
+	s := testServer()
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	order, err := s.buildOrder(context.Background(), 7, validOrderRequest(), now)
+	if err != nil {
+		t.Fatalf("build order: %v", err)
+	}
+	if order.SenderID != 7 || order.ClientPrice != 900 || order.PaymentMethod != "cash" {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+	if want := pricing.RecommendedParcel(3000, 100, 500, parcel.Parcel{Class: parcel.ClassSmall}); order.RecommendedPrice != want {
+		t.Fatalf("expected recommended price %d got %d", want, order.RecommendedPrice)
+	}
+	if len(order.Points) != 2 || order.Points[0].Address != "Кенесары 1" || order.Points[1].Seq != 1 {
+		t.Fatalf("unexpected points: %+v", order.Points)
+	}
+	if !order.Comment.Valid || order.Comment.String != "позвонить заранее" {
+		t.Fatalf("unexpected comment: %+v", order.Comment)
+	}
+	if order.Schedule.Scheduled() || order.Insurance.DeclaredValue != 0 {
+		t.Fatalf("expected unscheduled uninsured order: %+v", order)
+	}
+
+	req := validOrderRequest()
+	req.DeclaredValue = 20000
+	from, to := now.Add(2*time.Hour), now.Add(3*time.Hour)
+	req.PickupWindow = &windowInput{From: &from, To: &to}
+	order, err = s.buildOrder(context.Background(), 7, req, now)
+	if err != nil {
+		t.Fatalf("build insured order: %v", err)
+	}
+	if order.Insurance.Fee != 200 || !order.Schedule.Pickup.Set() {
+		t.Fatalf("expected fee 200 and pickup window, got %+v %+v", order.Insurance, order.Schedule)
+	}
+}
+
+func TestBuildOrderRejects(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	addressID := int64(5)
+	cases := []struct {
+		name   string
+		mutate func(*createOrderRequest)
+		msg    string
+	}{
+		{"one point", func(r *createOrderRequest) { r.RoutePoints = r.RoutePoints[:1] }, "at least two route points required"},
+		{"low price", func(r *createOrderRequest) { r.ClientPrice = 100 }, "client price must be >= 500"},
+		{"payment", func(r *createOrderRequest) { r.PaymentMethod = "card" }, "invalid payment method"},
+		{"cod", func(r *createOrderRequest) { r.CODAmount = -1 }, "cod_amount must not be negative"},
+		{"declared over cap", func(r *createOrderRequest) { r.DeclaredValue = 60000 }, "declared_value must be <= 50000"},
+		{"missing address", func(r *createOrderRequest) { r.RoutePoints[1].Address = " " }, "route point 1 missing address"},
+		{"missing coordinates", func(r *createOrderRequest) { r.RoutePoints[0].Lat, r.RoutePoints[0].Lon = 0, 0 }, "route point 0 missing coordinates"},
+		{"window in the past", func(r *createOrderRequest) { r.PickupWindow = &windowInput{From: &past, To: &now} }, "pickup_window must start in the future"},
+		{"saved address without book", func(r *createOrderRequest) { r.RoutePoints[0].AddressID = &addressID }, "address book is not available"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := validOrderRequest()
+			tc.mutate(&req)
+			_, err := testServer().buildOrder(context.Background(), 7, req, now)
+			var inputErr orderInputError
+			if !errors.As(err, &inputErr) {
+				t.Fatalf("expected orderInputError, got %v", err)
+			}
+			if err.Error() != tc.msg {
+				t.Fatalf("expected %q got %q", tc.msg, err.Error())
+			}
+		})
+	}
+}
+
+func TestHandleCreateOrderRejectsBeforeStoring(t *testing.T) {
+	cases := []struct {
+		name   string
+		sender string
+		body   string
+		status int
+		msg    string
+	}{
+		{"no sender", "", `{}`, http.StatusUnauthorized, "missing sender id"},
+		{"bad json", "7", `{`, http.StatusBadRequest, "invalid json"},
+		{"invalid order", "7", `{"client_price":900,"payment_method":"cash","route_points":[{"address":"a","lat":1,"lon":1}]}`, http.StatusBadRequest, "at least two route points required"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/courier/orders", strings.NewReader(tc.body))
+			if tc.sender != "" {
+				r.Header.Set("X-Sender-ID", tc.sender)
+			}
+			w := httptest.NewRecorder()
+			testServer().handleOrders(w, r)
+			if w.Code != tc.status {
+				t.Fatalf("expected status %d got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			var body map[string]string
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if body["error"] != tc.msg {
+				t.Fatalf("expected %q got %q", tc.msg, body["error"])
+			}
+		})
+	}
+}
+
+func TestCreatedOrderResponse(t *testing.T) {
+	order := repo.Order{RecommendedPrice: 800, Parcel: parcel.Parcel{Class: parcel.ClassSmall}}
+	resp := createdOrderResponse(42, order, time.Time{})
+	if resp["order_id"] != int64(42) || resp["status"] != repo.StatusNew || resp["recommended_price"] != 800 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	for _, key := range []string{"dispatch_at", "external_id", "insurance"} {
+		if _, ok := resp[key]; ok {
+			t.Fatalf("unexpected %s in plain order response", key)
+		}
+	}
+
+	order.Insurance = repo.Insurance{DeclaredValue: 10000, Fee: 100}
+	if _, ok := createdOrderResponse(42, order, time.Time{})["insurance"]; !ok {
+		t.Fatalf("expected insurance in insured order response")
+	}
+}
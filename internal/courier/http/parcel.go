@@ -0,0 +1,82 @@
+package http
+
+import (
+	"context"
+	"errors"
+
+	"naimuBack/internal/courier/parcel"
+	"naimuBack/internal/courier/repo"
+)
+
+var (
+	errVehicleCannotCarry   = errors.New("courier vehicle cannot carry this parcel")
+	errDeclaredValueOverCap = errors.New("declared value exceeds courier verification limit")
+)
+
+type parcelInput struct {
+	Class    string  `json:"class"`
+	WeightKg float64 `json:"weight_kg"`
+	LengthCm int     `json:"length_cm"`
+	WidthCm  int     `json:"width_cm"`
+	HeightCm int     `json:"height_cm"`
+}
+
+type parcelResponse struct {
+	Class    string  `json:"class"`
+	WeightKg float64 `json:"weight_kg"`
+	LengthCm int     `json:"length_cm"`
+	WidthCm  int     `json:"width_cm"`
+	HeightCm int     `json:"height_cm"`
+}
+
+// toParcel validates the declared parcel. Without a class the smallest fitting one is
+// picked from weight and dimensions; without anything the parcel is small.
+func (in *parcelInput) toParcel() (parcel.Parcel, error) {
+	if in == nil {
+		return parcel.Parcel{Class: parcel.ClassSmall}, nil
+	}
+	p := parcel.Parcel{WeightKg: in.WeightKg, LengthCm: in.LengthCm, WidthCm: in.WidthCm, HeightCm: in.HeightCm}
+	if in.Class == "" {
+		class, ok := parcel.Classify(p.WeightKg, p.LengthCm, p.WidthCm, p.HeightCm)
+		if !ok {
+			return parcel.Parcel{}, parcel.ErrTooLarge
+		}
+		p.Class = class
+		return p, nil
+	}
+	class, ok := parcel.NormalizeClass(in.Class)
+	if !ok {
+		return parcel.Parcel{}, parcel.ErrUnknownClass
+	}
+	p.Class = class
+	if err := p.Validate(); err != nil {
+		return parcel.Parcel{}, err
+	}
+	return p, nil
+}
+
+func makeParcelResponse(p parcel.Parcel) *parcelResponse {
+	if p.Class == "" {
+		return nil
+	}
+	return &parcelResponse{Class: p.Class, WeightKg: p.WeightKg, LengthCm: p.LengthCm, WidthCm: p.WidthCm, HeightCm: p.HeightCm}
+}
+
+// courierCarries checks that the courier's vehicle fits the order's parcel class and that
+// the courier's verification level covers its declared value.
+func (s *Server) courierCarries(ctx context.Context, courierID int64, order repo.Order) error {
+	if s.couriers == nil {
+		return nil
+	}
+	courier, err := s.couriers.Get(ctx, courierID)
+	if err != nil {
+		return err
+	}
+	if !parcel.Carries(courier.VehicleType, order.Parcel.Class) {
+		return errVehicleCannotCarry
+	}
+	if !s.cfg.Insurance.Covers(courier.VerificationLevel, order.Insurance.DeclaredValue) {
+		return errDeclaredValueOverCap
+	}
+	return nil
+}
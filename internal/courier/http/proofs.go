@@ -0,0 +1,284 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/courier/lifecycle"
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+// SMSSender delivers recipient PINs; without it the sender passes the PIN on.
+type SMSSender interface {
+	Send(ctx context.Context, phone, message string) error
+}
+
+type deliveryProofResponse struct {
+	PointID       int64      `json:"point_id"`
+	Seq           int        `json:"seq"`
+	Address       string     `json:"address"`
+	PIN           string     `json:"pin,omitempty"`
+	PINAttempts   int        `json:"pin_attempts"`
+	Delivered     bool       `json:"delivered"`
+	Method        string     `json:"method,omitempty"`
+	PhotoPath     string     `json:"photo,omitempty"`
+	SignaturePath string     `json:"signature,omitempty"`
+	Lat           *float64   `json:"lat,omitempty"`
+	Lon           *float64   `json:"lon,omitempty"`
+	CourierID     *int64     `json:"courier_id,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+// makeDeliveryProofResponse hides the PIN unless showPIN is set and the point is still open.
+func makeDeliveryProofResponse(p repo.DeliveryProof, showPIN bool) deliveryProofResponse {
+	resp := deliveryProofResponse{
+		PointID:       p.PointID,
+		Seq:           p.Seq,
+		Address:       p.Address,
+		PINAttempts:   p.PINAttempts,
+		Delivered:     p.DeliveredAt.Valid,
+		Method:        p.Method.String,
+		PhotoPath:     p.PhotoPath.String,
+		SignaturePath: p.SignaturePath.String,
+	}
+	if showPIN && !p.DeliveredAt.Valid {
+		resp.PIN = p.PIN
+	}
+	if p.Lat.Valid && p.Lon.Valid {
+		lat, lon := p.Lat.Float64, p.Lon.Float64
+		resp.Lat, resp.Lon = &lat, &lon
+	}
+	if p.CourierID.Valid {
+		v := p.CourierID.Int64
+		resp.CourierID = &v
+	}
+	if p.DeliveredAt.Valid {
+		v := p.DeliveredAt.Time
+		resp.DeliveredAt = &v
+	}
+	return resp
+}
+
+// sendRecipientPINs texts each recipient the PIN of their drop-off.
+func (s *Server) sendRecipientPINs(orderID int64) {
+	if s.sms == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		proofs, err := s.orders.ListDeliveryProofs(ctx, orderID)
+		if err != nil {
+			s.logger.Errorf("courier: load recipient pins for order %d failed: %v", orderID, err)
+			return
+		}
+		for _, p := range proofs {
+			phone := strings.TrimSpace(p.Phone.String)
+			if phone == "" || p.DeliveredAt.Valid {
+				continue
+			}
+			msg := fmt.Sprintf("Код для получения посылки: %s. Назовите его курьеру при вручении.", p.PIN)
+			if err := s.sms.Send(ctx, phone, msg); err != nil {
+				s.logger.Errorf("courier: send recipient pin for order %d point %d failed: %v", orderID, p.PointID, err)
+			}
+		}
+	}()
+}
+
+// requireDeliveryProofs blocks completion while some drop-off has no evidence.
+func (s *Server) requireDeliveryProofs(ctx context.Context, w http.ResponseWriter, orderID int64) bool {
+	pending, err := s.orders.PendingDeliveryProofs(ctx, orderID)
+	if err != nil {
+		s.logger.Errorf("courier: count pending delivery proofs for order %d failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to check delivery proofs")
+		return false
+	}
+	if pending > 0 {
+		writeError(w, http.StatusConflict, fmt.Sprintf("delivery proof required for %d drop-off(s)", pending))
+		return false
+	}
+	return true
+}
+
+// handlePointDeliver finishes a drop-off with the recipient PIN or a photo plus signature.
+func (s *Server) handlePointDeliver(w http.ResponseWriter, r *http.Request, orderID int64, pointID int64) {
+	courierID, err := parseAuthID(r, "X-Courier-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing courier id")
+		return
+	}
+
+	var (
+		ev          repo.DeliveryEvidence
+		hasPhoto    bool
+		hasSign     bool
+		isMultipart = strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "multipart/form-data")
+	)
+	if isMultipart {
+		if err := r.ParseMultipartForm(20 << 20); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid multipart form")
+			return
+		}
+		ev.PIN = strings.TrimSpace(r.FormValue("pin"))
+		ev.Lat, _ = strconv.ParseFloat(strings.TrimSpace(r.FormValue("lat")), 64)
+		ev.Lon, _ = strconv.ParseFloat(strings.TrimSpace(r.FormValue("lon")), 64)
+		if f, _, err := r.FormFile("photo"); err == nil {
+			f.Close()
+			hasPhoto = true
+		}
+		if f, _, err := r.FormFile("signature"); err == nil {
+			f.Close()
+			hasSign = true
+		}
+	} else {
+		var req struct {
+			PIN string  `json:"pin"`
+			Lat float64 `json:"lat"`
+			Lon float64 `json:"lon"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		ev.PIN, ev.Lat, ev.Lon = strings.TrimSpace(req.PIN), req.Lat, req.Lon
+	}
+	if ev.Lat == 0 && ev.Lon == 0 {
+		writeError(w, http.StatusBadRequest, "lat and lon are required")
+		return
+	}
+	if ev.PIN == "" && (!hasPhoto || !hasSign) {
+		writeError(w, http.StatusBadRequest, "pin or photo with signature is required")
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	ctx = withCourierActor(ctx, courierID)
+
+	order, err := s.orders.Get(ctx, orderID)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load order for delivery proof failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	if !order.CourierID.Valid || order.CourierID.Int64 != courierID {
+		writeError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if order.Status != lifecycle.StatusInProgress {
+		writeError(w, http.StatusConflict, "order is not in progress")
+		return
+	}
+
+	// PIN проверяем без файлов; фото и подпись сохраняем только когда PIN не прислали.
+	if ev.PIN == "" {
+		f, h, _ := r.FormFile("photo")
+		if ev.PhotoPath, err = saveUploadedFile(f, h, "proofs"); err != nil {
+			writeError(w, http.StatusBadRequest, "failed to save photo")
+			return
+		}
+		f, h, _ = r.FormFile("signature")
+		if ev.SignaturePath, err = saveUploadedFile(f, h, "proofs"); err != nil {
+			writeError(w, http.StatusBadRequest, "failed to save signature")
+			return
+		}
+	}
+	ev.CourierID = courierID
+	ev.At = timeutil.Now()
+
+	proof, err := s.orders.ConfirmDelivery(ctx, orderID, pointID, ev)
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		writeError(w, http.StatusNotFound, "drop-off point not found")
+		return
+	case errors.Is(err, repo.ErrPointDelivered):
+		writeError(w, http.StatusConflict, "point already delivered")
+		return
+	case errors.Is(err, repo.ErrPINMismatch):
+		writeError(w, http.StatusBadRequest, "invalid pin")
+		return
+	case errors.Is(err, repo.ErrPINLocked):
+		writeError(w, http.StatusConflict, "pin attempts exhausted, use photo and signature")
+		return
+	case err != nil:
+		s.logger.Errorf("courier: confirm delivery order=%d point=%d failed: %v", orderID, pointID, err)
+		writeError(w, http.StatusInternalServerError, "failed to store delivery proof")
+		return
+	}
+
+	pending, err := s.orders.PendingDeliveryProofs(ctx, orderID)
+	if err != nil {
+		s.logger.Errorf("courier: count pending delivery proofs for order %d failed: %v", orderID, err)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"proof":   makeDeliveryProofResponse(proof, false),
+		"pending": pending,
+	})
+	s.emitOrderEvent(ctx, orderID, orderEventTypeUpdated, originCourier)
+}
+
+// handleOrderProofs shows the sender PINs of open drop-offs and the collected evidence.
+func (s *Server) handleOrderProofs(w http.ResponseWriter, r *http.Request, orderID int64) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	order, err := s.orders.Get(ctx, orderID)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load order for proofs failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	if order.SenderID != senderID {
+		writeError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	s.writeOrderProofs(ctx, w, orderID)
+}
+
+func (s *Server) handleAdminCourierOrderProofs(w http.ResponseWriter, r *http.Request, orderID int64) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	s.writeOrderProofs(ctx, w, orderID)
+}
+
+func (s *Server) writeOrderProofs(ctx context.Context, w http.ResponseWriter, orderID int64) {
+	proofs, err := s.orders.ListDeliveryProofs(ctx, orderID)
+	if err != nil {
+		s.logger.Errorf("courier: list delivery proofs for order %d failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load delivery proofs")
+		return
+	}
+	resp := make([]deliveryProofResponse, 0, len(proofs))
+	for _, p := range proofs {
+		resp = append(resp, makeDeliveryProofResponse(p, true))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"proofs": resp})
+}
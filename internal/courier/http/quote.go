@@ -1,10 +1,14 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"naimuBack/internal/courier/pricing"
+	"naimuBack/internal/courier/repo"
+	taxigeo "naimuBack/internal/taxi/geo"
 )
 
 func (s *Server) handleQuote(w http.ResponseWriter, r *http.Request) {
@@ -14,15 +18,61 @@ func (s *Server) handleQuote(w http.ResponseWriter, r *http.Request) {
 	}
 	var req struct {
 		DistanceM int `json:"distance_m"`
+		// Points — необязательные точки маршрута; по ним возвращаем участки с геометрией.
+		Points []taxigeo.Point `json:"points"`
+		// Parcel — класс и размеры посылки; без него считаем как small.
+		Parcel *parcelInput `json:"parcel"`
+		// DeclaredValue — объявленная ценность; по ней считаем страховку.
+		DeclaredValue int `json:"declared_value"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid json")
 		return
 	}
+	parcelInfo, err := req.Parcel.toParcel()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var legs []routeLegResponse
+	if len(req.Points) >= 2 && s.router != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		total := 0
+		for i, leg := range s.routeLegs(ctx, req.Points) {
+			resp := routeLegResponse{FromSeq: i, ToSeq: i + 1, DistanceM: leg.DistanceM, EtaSeconds: leg.EtaSeconds}
+			if len(leg.Path) > 0 {
+				resp.Polyline = leg.Polyline()
+			}
+			legs = append(legs, resp)
+			total += leg.DistanceM
+		}
+		if req.DistanceM <= 0 {
+			req.DistanceM = total
+		}
+	}
 	if req.DistanceM <= 0 {
 		writeError(w, http.StatusBadRequest, "distance_m must be positive")
 		return
 	}
-	recommended := pricing.Recommended(req.DistanceM, s.cfg.PricePerKM, s.cfg.MinPrice)
-	writeJSON(w, http.StatusOK, map[string]int{"recommended_price": recommended})
+	recommended := pricing.RecommendedParcel(req.DistanceM, s.cfg.PricePerKM, s.cfg.MinPrice, parcelInfo)
+	if err := s.validateDeclaredValue(req.DeclaredValue); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Points == nil && req.Parcel == nil && req.DeclaredValue == 0 {
+		writeJSON(w, http.StatusOK, map[string]int{"recommended_price": recommended})
+		return
+	}
+	resp := map[string]interface{}{
+		"recommended_price": recommended,
+		"distance_m":        req.DistanceM,
+		"parcel":            makeParcelResponse(parcelInfo),
+		"legs":              legs,
+	}
+	if req.DeclaredValue > 0 {
+		resp["insurance"] = makeInsuranceResponse(repo.Insurance{DeclaredValue: req.DeclaredValue, Fee: s.cfg.Insurance.Fee(req.DeclaredValue)})
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
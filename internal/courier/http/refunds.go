@@ -0,0 +1,261 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/commission"
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/courier/ws"
+)
+
+// Refunder returns card payments through the payment provider; requests with the same
+// idempotency key are refunded once.
+type Refunder interface {
+	Return(ctx context.Context, extID string, amount *float64, idempotencyKey string) error
+}
+
+type refundResponse struct {
+	ID                int64     `json:"id"`
+	OrderID           int64     `json:"order_id"`
+	Amount            int       `json:"amount"`
+	Reason            *string   `json:"reason,omitempty"`
+	IdempotencyKey    string    `json:"idempotency_key"`
+	ProviderExtID     string    `json:"ext_id"`
+	ChargeCourier     bool      `json:"charge_courier"`
+	CourierAdjustment int       `json:"courier_adjustment"`
+	Status            string    `json:"status"`
+	Error             *string   `json:"error,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+func makeRefundResponse(rf repo.Refund) refundResponse {
+	return refundResponse{
+		ID:                rf.ID,
+		OrderID:           rf.OrderID,
+		Amount:            rf.Amount,
+		Reason:            nullToPtr(rf.Reason),
+		IdempotencyKey:    rf.IdempotencyKey,
+		ProviderExtID:     rf.ProviderExtID,
+		ChargeCourier:     rf.ChargeCourier,
+		CourierAdjustment: rf.CourierAdjustment,
+		Status:            rf.Status,
+		Error:             nullToPtr(rf.Error),
+		CreatedAt:         rf.CreatedAt,
+	}
+}
+
+// refundSteps решает, что делать после Begin. Ожидающий возврат (новый или оборвавшийся до
+// ответа провайдера) снова отправляется провайдеру, а возврат, деньги по которому провайдер
+// уже вернул, только допроводится у нас.
+func refundSteps(rf repo.Refund) (callProvider, complete bool) {
+	switch rf.Status {
+	case repo.RefundStatusPending:
+		return true, true
+	case repo.RefundStatusProviderDone:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// refundProviderKey не меняется между повторами одного возврата, поэтому провайдер
+// не вернёт деньги дважды.
+func refundProviderKey(rf repo.Refund) string {
+	return "courier-refund-" + strconv.FormatInt(rf.ID, 10)
+}
+
+// refundCourierAdjustment: курьер теряет возвращённую часть стоимости за вычетом удержанной с неё комиссии.
+func refundCourierAdjustment(charged, paid, refundedBefore, amount int) int {
+	if amount <= 0 || paid-refundedBefore <= 0 {
+		return 0
+	}
+	if amount > paid-refundedBefore {
+		amount = paid - refundedBefore
+	}
+	commissionBack := commission.Refunded(charged, paid, refundedBefore, amount)
+	return -(amount - commissionBack)
+}
+
+func (s *Server) handleAdminCourierOrderRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/courier/orders/"), "/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	orderID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid order id")
+		return
+	}
+	switch parts[1] {
+	case "refunds":
+		s.handleAdminCourierOrderRefunds(w, r, orderID)
+	case "proofs":
+		s.handleAdminCourierOrderProofs(w, r, orderID)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) handleAdminCourierOrderRefunds(w http.ResponseWriter, r *http.Request, orderID int64) {
+	if s.refunds == nil {
+		http.Error(w, "refunds repository unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+		refunds, err := s.refunds.ListByOrder(ctx, orderID)
+		if err != nil {
+			s.logger.Errorf("courier: list refunds failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to list refunds")
+			return
+		}
+		resp := make([]refundResponse, 0, len(refunds))
+		for _, rf := range refunds {
+			resp = append(resp, makeRefundResponse(rf))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"refunds": resp})
+	case http.MethodPost:
+		s.createRefund(w, r, orderID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createRefund(w http.ResponseWriter, r *http.Request, orderID int64) {
+	var req struct {
+		Amount         int     `json:"amount"`
+		Reason         *string `json:"reason"`
+		IdempotencyKey string  `json:"idempotency_key"`
+		ExtID          string  `json:"ext_id"`
+		ChargeCourier  *bool   `json:"charge_courier"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if key == "" {
+		key = strings.TrimSpace(req.IdempotencyKey)
+	}
+	if key == "" || len(key) > 64 {
+		writeError(w, http.StatusBadRequest, "idempotency key required")
+		return
+	}
+	if req.Amount < 0 {
+		writeError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	if s.refunder == nil {
+		writeError(w, http.StatusServiceUnavailable, "refunds unavailable")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	order, err := s.orders.Get(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "order not found")
+			return
+		}
+		s.logger.Errorf("courier: load order %d failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+
+	chargeCourier := true
+	if req.ChargeCourier != nil {
+		chargeCourier = *req.ChargeCourier
+	}
+	refund, _, err := s.refunds.Begin(ctx, repo.Refund{
+		OrderID:        order.ID,
+		Amount:         req.Amount,
+		Reason:         nullableString(req.Reason),
+		IdempotencyKey: key,
+		ProviderExtID:  strings.TrimSpace(req.ExtID),
+		ChargeCourier:  chargeCourier,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrNotFound):
+			writeError(w, http.StatusNotFound, "order not found")
+		case errors.Is(err, repo.ErrOrderNotRefundable):
+			writeError(w, http.StatusConflict, "order is not a completed card payment")
+		case errors.Is(err, repo.ErrRefundExceedsPayment):
+			writeError(w, http.StatusConflict, "refund exceeds paid amount")
+		case errors.Is(err, repo.ErrRefundExtIDRequired):
+			writeError(w, http.StatusBadRequest, "ext_id required")
+		default:
+			s.logger.Errorf("courier: begin refund for order %d failed: %v", order.ID, err)
+			writeError(w, http.StatusInternalServerError, "refund failed")
+		}
+		return
+	}
+	callProvider, complete := refundSteps(refund)
+	if !complete {
+		writeJSON(w, http.StatusOK, makeRefundResponse(refund))
+		return
+	}
+
+	if callProvider {
+		var amountPtr *float64
+		if refund.RefundedBefore > 0 || refund.Amount < refund.PaidAmount {
+			amount := float64(refund.Amount)
+			amountPtr = &amount
+		}
+		if err := s.refunder.Return(ctx, refund.ProviderExtID, amountPtr, refundProviderKey(refund)); err != nil {
+			s.logger.Errorf("courier: refund %d for order %d failed: %v", refund.ID, order.ID, err)
+			if ferr := s.refunds.Fail(ctx, refund.ID, err.Error()); ferr != nil {
+				s.logger.Errorf("courier: mark refund %d failed: %v", refund.ID, ferr)
+			}
+			writeError(w, http.StatusBadGateway, "payment provider refund failed")
+			return
+		}
+		if err := s.refunds.MarkProviderDone(ctx, refund.ID); err != nil {
+			// повтор снова обратится к провайдеру с тем же ключом, двойного возврата не будет
+			s.logger.Errorf("courier: mark refund %d provider done: %v", refund.ID, err)
+			writeError(w, http.StatusInternalServerError, "refund state update failed")
+			return
+		}
+	}
+
+	courierID := int64(0)
+	adjustment := 0
+	if refund.ChargeCourier && order.CourierID.Valid {
+		courierID = order.CourierID.Int64
+		adjustment = refundCourierAdjustment(recordedCommission(order), refund.PaidAmount, refund.RefundedBefore, refund.Amount)
+	}
+	if err := s.refunds.Complete(ctx, refund.ID, courierID, adjustment); err != nil {
+		// деньги у провайдера уже возвращены — повтор с тем же ключом допроведёт возврат
+		s.logger.Errorf("courier: complete refund %d for order %d failed: %v", refund.ID, order.ID, err)
+		writeError(w, http.StatusInternalServerError, "refund state update failed")
+		return
+	}
+
+	if s.sHub != nil {
+		event := ws.SenderEvent{Type: "order_refunded", OrderID: order.ID, Price: refund.Amount}
+		if refund.Reason.Valid {
+			event.Message = refund.Reason.String
+		}
+		s.sHub.PushOrderEvent(order.SenderID, event)
+	}
+
+	saved, err := s.refunds.Get(ctx, refund.ID)
+	if err != nil {
+		s.logger.Errorf("courier: load refund %d failed: %v", refund.ID, err)
+		saved = refund
+	}
+	writeJSON(w, http.StatusOK, makeRefundResponse(saved))
+}
@@ -0,0 +1,298 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"naimuBack/internal/courier/lifecycle"
+	"naimuBack/internal/courier/repo"
+	taxigeo "naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+type returnResponse struct {
+	AttemptedAt *time.Time `json:"attempted_at,omitempty"`
+	WaitingFee  int        `json:"waiting_fee"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	DistanceM   int        `json:"distance_m,omitempty"`
+	Fee         int        `json:"fee"`
+	ArrivedAt   *time.Time `json:"arrived_at,omitempty"`
+	ReturnedAt  *time.Time `json:"returned_at,omitempty"`
+	Surcharge   int        `json:"surcharge"`
+}
+
+func makeReturnResponse(ret repo.Return) *returnResponse {
+	if !ret.AttemptedAt.Valid {
+		return nil
+	}
+	return &returnResponse{
+		AttemptedAt: nullTimeToPtr(ret.AttemptedAt),
+		WaitingFee:  ret.WaitingFee,
+		StartedAt:   nullTimeToPtr(ret.StartedAt),
+		DistanceM:   ret.DistanceM,
+		Fee:         ret.Fee,
+		ArrivedAt:   nullTimeToPtr(ret.ArrivedAt),
+		ReturnedAt:  nullTimeToPtr(ret.ReturnedAt),
+		Surcharge:   ret.Surcharge(),
+	}
+}
+
+// deliveryFlowStatus reports statuses that carry fees and must go through the delivery/return actions.
+func deliveryFlowStatus(status string) bool {
+	switch status {
+	case lifecycle.StatusDeliveryAttempted, lifecycle.StatusWaitingRecipient, lifecycle.StatusReturning, lifecycle.StatusReturned:
+		return true
+	}
+	return false
+}
+
+// handleOrderDelivery — действия курьера у получателя: attempt, wait, resume.
+func (s *Server) handleOrderDelivery(w http.ResponseWriter, r *http.Request, orderID int64, action string) {
+	courierID, err := parseAuthID(r, "X-Courier-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing courier id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	ctx = withCourierActor(ctx, courierID)
+	now := timeutil.Now()
+
+	switch action {
+	case "attempt":
+		err = s.orders.MarkDeliveryAttempted(ctx, orderID, courierID, now)
+	case "wait":
+		order, ok := s.loadCourierOrder(ctx, w, orderID, courierID)
+		if !ok {
+			return
+		}
+		if order.Status == lifecycle.StatusDeliveryAttempted && order.Return.AttemptedAt.Valid {
+			if left := s.cfg.Returns.FreeWait - now.Sub(order.Return.AttemptedAt.Time); left > 0 {
+				writeJSON(w, http.StatusConflict, map[string]interface{}{
+					"error":          "free waiting is not over yet",
+					"seconds_left":   int(left.Seconds()),
+					"free_wait_secs": int(s.cfg.Returns.FreeWait.Seconds()),
+				})
+				return
+			}
+		}
+		err = s.orders.StartRecipientWaiting(ctx, orderID, courierID, now)
+	case "resume":
+		order, ok := s.loadCourierOrder(ctx, w, orderID, courierID)
+		if !ok {
+			return
+		}
+		err = s.orders.ResumeDelivery(ctx, orderID, courierID, s.waitingFee(order, now), now)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !s.deliveryStepDone(w, err, action) {
+		return
+	}
+	s.respondOrderStep(ctx, w, orderID, originCourier)
+}
+
+// handleOrderReturn — обратный путь: курьер везёт посылку назад, отмечает прибытие, отправитель подтверждает.
+func (s *Server) handleOrderReturn(w http.ResponseWriter, r *http.Request, orderID int64, action string) {
+	if action == "confirm" {
+		s.handleReturnConfirm(w, r, orderID)
+		return
+	}
+	courierID, err := parseAuthID(r, "X-Courier-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing courier id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	ctx = withCourierActor(ctx, courierID)
+	now := timeutil.Now()
+
+	switch action {
+	case "":
+		order, ok := s.loadCourierOrder(ctx, w, orderID, courierID)
+		if !ok {
+			return
+		}
+		distance := s.returnDistance(ctx, order)
+		fee := s.cfg.Returns.ReturnPrice(distance, order.ClientPrice)
+		err = s.orders.StartReturn(ctx, orderID, courierID, s.waitingFee(order, now), distance, fee, now)
+	case "arrive":
+		err = s.orders.MarkReturnArrived(ctx, orderID, courierID, now)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !s.deliveryStepDone(w, err, strings.TrimSpace("return "+action)) {
+		return
+	}
+	s.respondOrderStep(ctx, w, orderID, originCourier)
+}
+
+func (s *Server) handleReturnConfirm(w http.ResponseWriter, r *http.Request, orderID int64) {
+	senderID, err := parseAuthID(r, "X-Sender-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing sender id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	ctx = withSenderActor(ctx, senderID)
+
+	err = s.orders.ConfirmReturned(ctx, orderID, senderID, timeutil.Now())
+	if !s.deliveryStepDone(w, err, "return confirm") {
+		return
+	}
+	// Курьер отработал и доставку, и возврат — комиссия берётся с полной суммы.
+	s.settleCommission(ctx, orderID)
+	s.respondOrderStep(ctx, w, orderID, originSender)
+}
+
+func (s *Server) loadCourierOrder(ctx context.Context, w http.ResponseWriter, orderID, courierID int64) (repo.Order, bool) {
+	order, err := s.orders.Get(ctx, orderID)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "order not found")
+		return repo.Order{}, false
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load order %d failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return repo.Order{}, false
+	}
+	if !order.CourierID.Valid || order.CourierID.Int64 != courierID {
+		writeError(w, http.StatusForbidden, "forbidden")
+		return repo.Order{}, false
+	}
+	return order, true
+}
+
+// waitingFee prices the paid part of the wait; nothing is charged unless the courier switched to paid waiting.
+func (s *Server) waitingFee(order repo.Order, now time.Time) int {
+	if order.Status != lifecycle.StatusWaitingRecipient || !order.Return.AttemptedAt.Valid {
+		return 0
+	}
+	return s.cfg.Returns.WaitingPrice(now.Sub(order.Return.AttemptedAt.Time))
+}
+
+// returnDistance measures the way from the courier (or the last drop-off) back to the pickup point,
+// by road when a router is configured.
+func (s *Server) returnDistance(ctx context.Context, order repo.Order) int {
+	if len(order.Points) < 2 {
+		return 0
+	}
+	last, to := order.Points[len(order.Points)-1], order.Points[0]
+	fromLon, fromLat := last.Lon, last.Lat
+	if s.cHub != nil && order.CourierID.Valid {
+		if pos, ok := s.cHub.Position(order.CourierID.Int64); ok {
+			fromLon, fromLat = pos.Lon, pos.Lat
+		}
+	}
+	if s.router != nil {
+		if dist, _, err := s.router.RouteMatrix(ctx, fromLon, fromLat, to.Lon, to.Lat); err == nil && dist > 0 {
+			return dist
+		} else if err != nil {
+			s.logger.Errorf("courier: route return of order %d failed: %v", order.ID, err)
+		}
+	}
+	return int(taxigeo.DistanceMeters(fromLon, fromLat, to.Lon, to.Lat))
+}
+
+func (s *Server) deliveryStepDone(w http.ResponseWriter, err error, action string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repo.ErrNotFound):
+		writeError(w, http.StatusNotFound, "order not found")
+	case errors.Is(err, repo.ErrDeliveryState):
+		writeError(w, http.StatusConflict, "order status does not allow "+action)
+	default:
+		s.logger.Errorf("courier: delivery %s failed: %v", action, err)
+		writeError(w, http.StatusInternalServerError, "failed to update order")
+	}
+	return false
+}
+
+func (s *Server) respondOrderStep(ctx context.Context, w http.ResponseWriter, orderID int64, origin eventOrigin) {
+	order, err := s.orders.Get(ctx, orderID)
+	if err != nil {
+		s.logger.Errorf("courier: reload order %d failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	s.emitOrder(ctx, order, orderEventTypeUpdated, origin)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"order": s.orderView(order)})
+}
+
+type returnReportRow struct {
+	OrderID     int64           `json:"order_id"`
+	SenderID    int64           `json:"sender_id"`
+	CourierID   *int64          `json:"courier_id,omitempty"`
+	Status      string          `json:"status"`
+	ClientPrice int             `json:"client_price"`
+	Return      *returnResponse `json:"return"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// handleAdminReturnsReport — недоставки и возвраты за период по дате попытки вручения (по умолчанию 30 дней).
+func (s *Server) handleAdminReturnsReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	rows, err := s.orders.ReturnsReport(ctx, from, to)
+	if err != nil {
+		s.logger.Errorf("courier: returns report failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to build returns report")
+		return
+	}
+
+	var returning, returned, waitingFees, returnFees int
+	items := make([]returnReportRow, 0, len(rows))
+	for _, row := range rows {
+		item := returnReportRow{
+			OrderID:     row.OrderID,
+			SenderID:    row.SenderID,
+			Status:      row.Status,
+			ClientPrice: row.ClientPrice,
+			Return:      makeReturnResponse(row.Return),
+			CreatedAt:   row.CreatedAt,
+		}
+		if row.CourierID.Valid {
+			v := row.CourierID.Int64
+			item.CourierID = &v
+		}
+		switch row.Status {
+		case repo.StatusReturning:
+			returning++
+		case repo.StatusReturned:
+			returned++
+		}
+		waitingFees += row.Return.WaitingFee
+		returnFees += row.Return.Fee
+		items = append(items, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":  from.Format("2006-01-02"),
+		"to":    to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"items": items,
+		"totals": map[string]int{
+			"orders":       len(items),
+			"returning":    returning,
+			"returned":     returned,
+			"waiting_fees": waitingFees,
+			"return_fees":  returnFees,
+		},
+	})
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"naimuBack/internal/courier/repo"
+	taxigeo "naimuBack/internal/taxi/geo"
+)
+
+// offRouteToleranceM — насколько курьер может отклониться от построенного маршрута, прежде чем ETA перестраивается.
+const offRouteToleranceM = 150.0
+
+type routeLegResponse struct {
+	FromSeq    int    `json:"from_seq"`
+	ToSeq      int    `json:"to_seq"`
+	DistanceM  int    `json:"distance_m"`
+	EtaSeconds int    `json:"eta_s"`
+	Polyline   string `json:"polyline,omitempty"`
+}
+
+// routeLegs fetches each leg between consecutive points concurrently.
+// A failed leg stays empty: the apps fall back to a straight line.
+func (s *Server) routeLegs(ctx context.Context, points []taxigeo.Point) []taxigeo.RouteLeg {
+	if s.router == nil || len(points) < 2 {
+		return nil
+	}
+	legs := make([]taxigeo.RouteLeg, len(points)-1)
+	var wg sync.WaitGroup
+	for i := 0; i < len(points)-1; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			leg, err := s.router.RouteGeometry(ctx, points[i].Lon, points[i].Lat, points[i+1].Lon, points[i+1].Lat)
+			if err != nil {
+				s.logger.Errorf("courier: route geometry of leg %d failed: %v", i, err)
+				return
+			}
+			legs[i] = leg
+		}(i)
+	}
+	wg.Wait()
+	return legs
+}
+
+func (s *Server) routePolylines(ctx context.Context, points []taxigeo.Point) []string {
+	legs := s.routeLegs(ctx, points)
+	if legs == nil {
+		return nil
+	}
+	polylines := make([]string, len(legs))
+	for i, leg := range legs {
+		if len(leg.Path) > 0 {
+			polylines[i] = leg.Polyline()
+		}
+	}
+	return polylines
+}
+
+func orderRoutePoints(points []repo.OrderPoint) []taxigeo.Point {
+	res := make([]taxigeo.Point, 0, len(points))
+	for _, p := range points {
+		res = append(res, taxigeo.Point{Lon: p.Lon, Lat: p.Lat})
+	}
+	return res
+}
+
+// orderRoutePath joins stored leg geometries of the order into one path for off-route checks.
+func orderRoutePath(points []repo.OrderPoint) []taxigeo.Point {
+	var path []taxigeo.Point
+	for _, p := range points {
+		if !p.LegPolyline.Valid || p.LegPolyline.String == "" {
+			continue
+		}
+		leg, err := taxigeo.DecodePolyline(p.LegPolyline.String)
+		if err != nil {
+			continue
+		}
+		path = append(path, leg...)
+	}
+	return path
+}
+
+func legPolyline(polylines []string, i int) sql.NullString {
+	if i < len(polylines) && polylines[i] != "" {
+		return sql.NullString{String: polylines[i], Valid: true}
+	}
+	return sql.NullString{}
+}
@@ -0,0 +1,395 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"naimuBack/internal/courier/parcel"
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/courier/routeplan"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+const (
+	// maxScheduleAhead limits how far in advance an order may be placed.
+	maxScheduleAhead = 14 * 24 * time.Hour
+	// minWindowLength keeps windows wide enough to be planned at all.
+	minWindowLength = 15 * time.Minute
+
+	lateRiskCheckInterval = time.Minute
+	// lateRiskPlanMaxAge is how long a stored route plan is trusted by the late-risk watch.
+	lateRiskPlanMaxAge = 3 * time.Minute
+)
+
+type windowInput struct {
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+}
+
+type windowResponse struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type scheduleResponse struct {
+	PickupWindow      *windowResponse `json:"pickup_window,omitempty"`
+	DropoffWindow     *windowResponse `json:"dropoff_window,omitempty"`
+	ReservedCourierID *int64          `json:"reserved_courier_id,omitempty"`
+	ReservedAt        *time.Time      `json:"reserved_at,omitempty"`
+	LateRisk          bool            `json:"late_risk"`
+	LateRiskAt        *time.Time      `json:"late_risk_at,omitempty"`
+}
+
+func (in *windowInput) toWindow(name string, now time.Time) (repo.TimeWindow, error) {
+	if in == nil {
+		return repo.TimeWindow{}, nil
+	}
+	if in.From == nil || in.To == nil {
+		return repo.TimeWindow{}, fmt.Errorf("%s requires from and to", name)
+	}
+	from, to := *in.From, *in.To
+	if !from.After(now) {
+		return repo.TimeWindow{}, fmt.Errorf("%s must start in the future", name)
+	}
+	if to.Sub(from) < minWindowLength {
+		return repo.TimeWindow{}, fmt.Errorf("%s must be at least %d minutes long", name, int(minWindowLength/time.Minute))
+	}
+	if to.Sub(now) > maxScheduleAhead {
+		return repo.TimeWindow{}, fmt.Errorf("%s must end within %d days", name, int(maxScheduleAhead/(24*time.Hour)))
+	}
+	return repo.TimeWindow{
+		From: sql.NullTime{Time: from, Valid: true},
+		To:   sql.NullTime{Time: to, Valid: true},
+	}, nil
+}
+
+// parseSchedule validates the pickup and drop-off windows of a new order; both are optional.
+func parseSchedule(pickup, dropoff *windowInput, now time.Time) (repo.Schedule, error) {
+	var (
+		sched repo.Schedule
+		err   error
+	)
+	if sched.Pickup, err = pickup.toWindow("pickup_window", now); err != nil {
+		return repo.Schedule{}, err
+	}
+	if sched.Dropoff, err = dropoff.toWindow("dropoff_window", now); err != nil {
+		return repo.Schedule{}, err
+	}
+	if sched.Pickup.Set() && sched.Dropoff.Set() && !sched.Dropoff.To.Time.After(sched.Pickup.From.Time) {
+		return repo.Schedule{}, errors.New("dropoff_window must end after pickup_window starts")
+	}
+	return sched, nil
+}
+
+// scheduleDispatchStart is when searching for a courier begins: the lead time before the
+// pickup window, or before the moment the courier must leave to make the drop-off window.
+func scheduleDispatchStart(sched repo.Schedule, etaSeconds int, lead time.Duration, now time.Time) time.Time {
+	var anchor time.Time
+	switch {
+	case sched.Pickup.Set():
+		anchor = sched.Pickup.From.Time
+	case sched.Dropoff.Set():
+		anchor = sched.Dropoff.From.Time.Add(-time.Duration(etaSeconds) * time.Second)
+	default:
+		return now
+	}
+	start := anchor.Add(-lead)
+	if start.Before(now) {
+		return now
+	}
+	return start
+}
+
+func makeWindowResponse(w repo.TimeWindow) *windowResponse {
+	if !w.Set() {
+		return nil
+	}
+	return &windowResponse{From: w.From.Time, To: w.To.Time}
+}
+
+func makeScheduleResponse(sched repo.Schedule) *scheduleResponse {
+	if !sched.Scheduled() {
+		return nil
+	}
+	resp := &scheduleResponse{
+		PickupWindow:  makeWindowResponse(sched.Pickup),
+		DropoffWindow: makeWindowResponse(sched.Dropoff),
+		LateRisk:      sched.LateRiskAt.Valid,
+	}
+	if sched.ReservedCourierID.Valid {
+		v := sched.ReservedCourierID.Int64
+		resp.ReservedCourierID = &v
+	}
+	if sched.ReservedAt.Valid {
+		v := sched.ReservedAt.Time
+		resp.ReservedAt = &v
+	}
+	if sched.LateRiskAt.Valid {
+		v := sched.LateRiskAt.Time
+		resp.LateRiskAt = &v
+	}
+	return resp
+}
+
+// handleReservableOrders lists scheduled orders couriers can reserve before dispatch starts.
+func (s *Server) handleReservableOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if _, err := parseAuthID(r, "X-Courier-ID"); err != nil {
+		writeError(w, http.StatusUnauthorized, "missing courier id")
+		return
+	}
+	limit, offset, err := parsePaging(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	orders, err := s.orders.ListReservable(ctx, timeutil.Now(), limit, offset)
+	if err != nil {
+		s.logger.Errorf("courier: list reservable orders failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to list scheduled orders")
+		return
+	}
+	resp := make([]orderResponse, 0, len(orders))
+	for _, o := range orders {
+		resp = append(resp, s.orderView(o))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"orders": resp})
+}
+
+// handleCourierReservations lists the courier's reserved orders that are not dispatched yet.
+func (s *Server) handleCourierReservations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	courierID, err := parseAuthID(r, "X-Courier-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing courier id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+
+	orders, err := s.orders.ListReservedByCourier(ctx, courierID)
+	if err != nil {
+		s.logger.Errorf("courier: list reservations of courier %d failed: %v", courierID, err)
+		writeError(w, http.StatusInternalServerError, "failed to list reservations")
+		return
+	}
+	resp := make([]orderResponse, 0, len(orders))
+	for _, o := range orders {
+		resp = append(resp, s.orderView(o))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"orders": resp})
+}
+
+// handleOrderReservation: POST reserves a scheduled order, DELETE gives it back.
+// The reserved order is assigned to the courier when its dispatch starts.
+func (s *Server) handleOrderReservation(w http.ResponseWriter, r *http.Request, orderID int64) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	courierID, err := parseAuthID(r, "X-Courier-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing courier id")
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	ctx = withCourierActor(ctx, courierID)
+
+	if r.Method == http.MethodDelete {
+		if err := s.orders.ReleaseReservation(ctx, orderID, courierID); err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "reservation not found")
+				return
+			}
+			s.logger.Errorf("courier: release reservation of order %d failed: %v", orderID, err)
+			writeError(w, http.StatusInternalServerError, "failed to release reservation")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		s.emitOrderEvent(ctx, orderID, orderEventTypeUpdated, originCourier)
+		return
+	}
+
+	order, err := s.orders.Get(ctx, orderID)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load order %d for reservation failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	if s.couriers != nil {
+		courier, err := s.couriers.Get(ctx, courierID)
+		if err != nil {
+			s.logger.Errorf("courier: load courier %d for reservation failed: %v", courierID, err)
+			writeError(w, http.StatusInternalServerError, "failed to load courier")
+			return
+		}
+		if courier.IsBanned || courier.ApprovalStatus != repo.CourierApprovalApproved {
+			writeError(w, http.StatusForbidden, "courier is not allowed to take orders")
+			return
+		}
+		if !parcel.Carries(courier.VehicleType, order.Parcel.Class) {
+			writeError(w, http.StatusConflict, errVehicleCannotCarry.Error())
+			return
+		}
+		if !s.cfg.Insurance.Covers(courier.VerificationLevel, order.Insurance.DeclaredValue) {
+			writeError(w, http.StatusConflict, errDeclaredValueOverCap.Error())
+			return
+		}
+	}
+
+	switch err := s.orders.ReserveScheduled(ctx, orderID, courierID, timeutil.Now()); {
+	case errors.Is(err, repo.ErrNotFound):
+		writeError(w, http.StatusNotFound, "order not found")
+		return
+	case errors.Is(err, repo.ErrNotScheduled):
+		writeError(w, http.StatusBadRequest, "order is not scheduled")
+		return
+	case errors.Is(err, repo.ErrAlreadyReserved), errors.Is(err, repo.ErrReservationClosed):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		s.logger.Errorf("courier: reserve order %d failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to reserve order")
+		return
+	}
+
+	if order, err = s.orders.Get(ctx, orderID); err != nil {
+		s.logger.Errorf("courier: reload reserved order %d failed: %v", orderID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.orderView(order))
+	s.emitOrder(detachContext(ctx), order, orderEventTypeUpdated, originCourier)
+}
+
+// ReservedOrderAssigned implements dispatch.ReservationHandler.
+func (s *Server) ReservedOrderAssigned(orderID, courierID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	order, err := s.orders.Get(ctx, orderID)
+	if err != nil {
+		s.logger.Errorf("courier: load reserved order %d after assignment failed: %v", orderID, err)
+		return
+	}
+	s.emitOrder(ctx, order, orderEventTypeUpdated, originUnknown)
+	s.replanCourierRoute(courierID)
+}
+
+// RunLateRiskWatch periodically checks planned routes of couriers carrying scheduled orders.
+func (s *Server) RunLateRiskWatch(ctx context.Context) {
+	ticker := time.NewTicker(lateRiskCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkLateRisks(ctx)
+		}
+	}
+}
+
+func (s *Server) checkLateRisks(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 45*time.Second)
+	defer cancel()
+	courierIDs, err := s.orders.CouriersWithScheduledOrders(ctx)
+	if err != nil {
+		s.logger.Errorf("courier: list couriers with scheduled orders failed: %v", err)
+		return
+	}
+	now := timeutil.Now()
+	for _, courierID := range courierIDs {
+		orders, err := s.orders.ListActiveByCourier(ctx, courierID)
+		if err != nil {
+			s.logger.Errorf("courier: load active orders of courier %d failed: %v", courierID, err)
+			continue
+		}
+		stops, _, err := s.openRouteStops(ctx, orders)
+		if err != nil {
+			s.logger.Errorf("courier: collect route stops of courier %d failed: %v", courierID, err)
+			continue
+		}
+		var plan []repo.RouteStop
+		if stored, err := s.orders.RoutePlan(ctx, courierID); err == nil && len(stored) > 0 && now.Sub(stored[0].PlannedAt) < lateRiskPlanMaxAge {
+			plan = coveringPlan(stored, stops)
+		}
+		if plan == nil {
+			if plan, err = s.planCourierRoute(ctx, courierID, stops); err != nil {
+				s.logger.Errorf("courier: plan route of courier %d failed: %v", courierID, err)
+				continue
+			}
+		}
+		s.flagLateOrders(ctx, orders, plan)
+	}
+}
+
+// flagLateOrders marks scheduled orders whose planned pickup or drop-off falls after the
+// end of its window.
+func (s *Server) flagLateOrders(ctx context.Context, orders []repo.Order, plan []repo.RouteStop) {
+	byID := make(map[int64]repo.Order, len(orders))
+	for _, o := range orders {
+		if o.Schedule.Scheduled() && !o.Schedule.LateRiskAt.Valid {
+			byID[o.ID] = o
+		}
+	}
+	for _, stop := range plan {
+		order, ok := byID[stop.OrderID]
+		if !ok || stop.Kind == routeplan.KindReturn {
+			continue
+		}
+		window := order.Schedule.Dropoff
+		if stop.Kind == routeplan.KindPickup {
+			window = order.Schedule.Pickup
+		}
+		if !window.Set() {
+			continue
+		}
+		arrival := stop.PlannedAt.Add(time.Duration(stop.EtaSeconds) * time.Second)
+		if arrival.After(window.To.Time) {
+			delete(byID, order.ID)
+			s.raiseLateRisk(ctx, order, stop.Kind, arrival, window)
+		}
+	}
+}
+
+// raiseLateRisk flags the order once, tells the sender and leaves the alert to live ops.
+func (s *Server) raiseLateRisk(ctx context.Context, order repo.Order, kind string, arrival time.Time, window repo.TimeWindow) {
+	marked, err := s.orders.MarkLateRisk(ctx, order.ID, timeutil.Now())
+	if err != nil {
+		s.logger.Errorf("courier: mark late risk of order %d failed: %v", order.ID, err)
+		return
+	}
+	if !marked {
+		return
+	}
+	s.logger.Infof("courier: order %d at risk of missing %s window (expected %s, window ends %s)",
+		order.ID, kind, arrival.Format(time.RFC3339), window.To.Time.Format(time.RFC3339))
+	if s.sHub != nil {
+		s.sHub.Push(order.SenderID, map[string]interface{}{
+			"type":        "order_late_risk",
+			"order_id":    order.ID,
+			"stop":        kind,
+			"expected_at": arrival,
+			"window":      makeWindowResponse(window),
+		})
+	}
+	s.emitOrderEvent(ctx, order.ID, orderEventTypeUpdated, originUnknown)
+}
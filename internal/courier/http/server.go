@@ -2,10 +2,16 @@ package http
 
 import (
 	"net/http"
+	"time"
 
+	"naimuBack/internal/chat"
+	"naimuBack/internal/commission"
 	"naimuBack/internal/courier/dispatch"
+	"naimuBack/internal/courier/pricing"
 	"naimuBack/internal/courier/repo"
 	"naimuBack/internal/courier/ws"
+	"naimuBack/internal/liveops"
+	taxigeo "naimuBack/internal/taxi/geo"
 )
 
 // Config is the subset of runtime configuration required by the HTTP handlers.
@@ -13,6 +19,27 @@ type Config struct {
 	PricePerKM        int
 	MinPrice          int
 	SearchRadiusStart int
+	// RegionKey is the city the commission rules are matched against.
+	RegionKey string
+	// ChatHidePhones hides the other party's phone in sender and courier views.
+	ChatHidePhones bool
+	// LiveETARefresh limits how often the courier's ETA is re-routed.
+	LiveETARefresh time.Duration
+	// BatchCapacity caps active orders per courier.
+	BatchCapacity int
+	// TrackingBaseURL and TrackingApproachM drive recipient tracking links and notifications.
+	TrackingBaseURL   string
+	TrackingApproachM int
+	// ScheduleLead moves dispatch of scheduled orders ahead of their pickup window.
+	ScheduleLead time.Duration
+	// Returns prices paid waiting at the recipient and the return leg.
+	Returns pricing.ReturnRules
+	// MerchantKeyGrace keeps a rotated merchant API key working while the shop deploys the new one.
+	MerchantKeyGrace time.Duration
+	// Insurance prices declared value cover and caps it by courier verification level.
+	Insurance pricing.InsuranceRules
+	// RecurringLead is how long before the pickup a recurring delivery places its order.
+	RecurringLead time.Duration
 }
 
 func (c Config) GetSearchRadiusStart() int { return c.SearchRadiusStart }
@@ -25,20 +52,46 @@ type Logger interface {
 
 // Server provides HTTP handlers for the courier domain.
 type Server struct {
-	cfg        Config
-	logger     Logger
-	orders     *repo.OrdersRepo
-	offers     *repo.OffersRepo
-	couriers   *repo.CouriersRepo
-	users      *repo.UsersRepo
-	cHub       *ws.CourierHub
-	sHub       *ws.SenderHub
-	dispatcher *dispatch.Dispatcher
+	cfg       Config
+	logger    Logger
+	orders    *repo.OrdersRepo
+	offers    *repo.OffersRepo
+	couriers  *repo.CouriersRepo
+	users     *repo.UsersRepo
+	refunds   *repo.RefundsRepo
+	merchants *repo.MerchantsRepo
+	// addressBook holds saved addresses, templates and recurring deliveries of senders.
+	addressBook *repo.AddressBookRepo
+	cHub        *ws.CourierHub
+	sHub        *ws.SenderHub
+	dispatcher  *dispatch.Dispatcher
+	refunder    Refunder
+	sms         SMSSender
+	// trackingLinks resolves recipient tracking links; it is the orders repository.
+	trackingLinks trackingStore
+
+	commissionRepo *commission.Repo
+	commission     *commission.Engine
+	chatRepo       *chat.Repo
+	eta            *liveETA
+	tracking       *recipientTracking
+	tHub           *ws.TrackingHub
+	router         taxigeo.GeometryRouter
+	liveOps        *liveops.Hub
+	webhookClient  *http.Client
 }
 
 // NewServer constructs a Server instance.
-func NewServer(cfg Config, logger Logger, orders *repo.OrdersRepo, offers *repo.OffersRepo, couriers *repo.CouriersRepo, users *repo.UsersRepo, cHub *ws.CourierHub, sHub *ws.SenderHub, dispatcher *dispatch.Dispatcher) *Server {
-	return &Server{cfg: cfg, logger: logger, orders: orders, offers: offers, couriers: couriers, users: users, cHub: cHub, sHub: sHub, dispatcher: dispatcher}
+func NewServer(cfg Config, logger Logger, orders *repo.OrdersRepo, offers *repo.OffersRepo, couriers *repo.CouriersRepo, users *repo.UsersRepo, refunds *repo.RefundsRepo, merchants *repo.MerchantsRepo, addressBook *repo.AddressBookRepo, commissionRepo *commission.Repo, commissionEngine *commission.Engine, chatRepo *chat.Repo, router taxigeo.GeometryRouter, liveOps *liveops.Hub, cHub *ws.CourierHub, sHub *ws.SenderHub, dispatcher *dispatch.Dispatcher, refunder Refunder, sms SMSSender) *Server {
+	s := &Server{cfg: cfg, logger: logger, orders: orders, offers: offers, couriers: couriers, users: users, refunds: refunds, merchants: merchants, addressBook: addressBook, cHub: cHub, sHub: sHub, dispatcher: dispatcher, refunder: refunder, sms: sms, trackingLinks: orders, commissionRepo: commissionRepo, commission: commissionEngine, chatRepo: chatRepo, liveOps: liveOps}
+	if router != nil {
+		s.router = router
+		s.eta = newLiveETA(router, cfg.LiveETARefresh)
+	}
+	s.tracking = newRecipientTracking(s.router, cfg.LiveETARefresh)
+	s.tHub = ws.NewTrackingHub(logger)
+	s.webhookClient = newWebhookClient()
+	return s
 }
 
 // Register mounts courier routes on the mux.
@@ -49,6 +102,12 @@ func (s *Server) Register(mux *http.ServeMux) {
 	mux.HandleFunc("/api/v1/courier/my/orders", s.handleCourierOrders)
 	mux.HandleFunc("/api/v1/courier/my/orders/", s.handleCourierOrdersSubroutes)
 	mux.HandleFunc("/api/v1/courier/my/orders/active", s.handleCourierActiveOrder)
+	mux.HandleFunc("/api/v1/courier/my/route", s.handleCourierRoute)
+	mux.HandleFunc("/api/v1/courier/my/reservations", s.handleCourierReservations)
+	mux.HandleFunc("/api/v1/courier/scheduled", s.handleReservableOrders)
+	mux.HandleFunc("/api/v1/courier/my/cod", s.handleCourierCOD)
+	mux.HandleFunc("/api/v1/courier/my/cod/handins", s.handleCourierCODHandIns)
+	mux.HandleFunc("/api/v1/courier/cod/report", s.handleSenderCODReport)
 	mux.HandleFunc("/api/v1/courier/route/quote", s.handleQuote)
 	mux.HandleFunc("/api/v1/courier/offers/price", s.handleOfferPrice)
 	mux.HandleFunc("/api/v1/courier/offers/accept", s.handleOfferAccept)
@@ -56,14 +115,34 @@ func (s *Server) Register(mux *http.ServeMux) {
 	mux.HandleFunc("/api/v1/courier/offers/respond", s.handleOfferRespond)
 	mux.HandleFunc("/api/v1/courier/balance/deposit", s.handleCourierBalanceDeposit)
 	mux.HandleFunc("/api/v1/courier/balance/withdraw", s.handleCourierBalanceWithdraw)
+	mux.HandleFunc("/api/v1/courier/commission/passes", s.handleCourierCommissionPasses)
 	mux.HandleFunc("/api/v1/courier/orders/stats", s.handleAdminCourierOrdersStats)
 	mux.HandleFunc("/api/v1/admin/courier/orders/stats", s.handleAdminCourierOrdersStats)
 	mux.HandleFunc("/api/v1/admin/courier/orders", s.handleAdminCourierOrders)
+	mux.HandleFunc("/api/v1/admin/courier/orders/", s.handleAdminCourierOrderRoutes)
 	mux.HandleFunc("/api/v1/admin/courier/couriers", s.handleAdminCouriers)
 	mux.HandleFunc("/api/v1/admin/courier/couriers/stats", s.handleAdminCouriersStats)
 	mux.HandleFunc("/api/v1/admin/courier/couriers/", s.handleAdminCourierActions)
+	mux.HandleFunc("/api/v1/admin/courier/cod/handins", s.handleAdminCODHandIns)
+	mux.HandleFunc("/api/v1/admin/courier/cod/handins/", s.handleAdminCODHandInRoutes)
+	mux.HandleFunc("/api/v1/admin/courier/returns", s.handleAdminReturnsReport)
+	mux.HandleFunc("/api/v1/admin/courier/claims", s.handleAdminClaims)
+	mux.HandleFunc("/api/v1/admin/courier/claims/", s.handleAdminClaimRoutes)
+	mux.HandleFunc("/api/v1/admin/courier/merchants", s.handleAdminMerchants)
+	mux.HandleFunc("/api/v1/admin/courier/merchants/", s.handleAdminMerchantRoutes)
+	mux.HandleFunc("/api/v1/courier/merchant", s.handleMerchantAccount)
+	mux.HandleFunc("/api/v1/courier/merchant/", s.handleMerchantAccountRoutes)
+	mux.HandleFunc("/api/v1/merchant/courier/", s.handleMerchantAPI)
+	mux.HandleFunc("/api/v1/courier/addresses", s.handleAddresses)
+	mux.HandleFunc("/api/v1/courier/addresses/", s.handleAddressRoutes)
+	mux.HandleFunc("/api/v1/courier/templates", s.handleTemplates)
+	mux.HandleFunc("/api/v1/courier/templates/", s.handleTemplateRoutes)
+	mux.HandleFunc("/api/v1/courier/recurring", s.handleRecurring)
+	mux.HandleFunc("/api/v1/courier/recurring/", s.handleRecurringRoutes)
 	mux.HandleFunc("/api/v1/couriers", s.handleCourierUpsert)
 	mux.HandleFunc("/api/v1/courier/", s.handleCourierProfileRoutes)
 	mux.HandleFunc("/ws/courier", s.handleCourierWS)
 	mux.HandleFunc("/ws/sender", s.handleSenderWS)
+	mux.HandleFunc("/api/v1/courier/track/", s.handleRecipientTracking)
+	mux.HandleFunc("/ws/courier/track", s.handleRecipientTrackingWS)
 }
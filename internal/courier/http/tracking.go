@@ -0,0 +1,370 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"naimuBack/internal/courier/lifecycle"
+	"naimuBack/internal/courier/repo"
+	taxigeo "naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+// trackingLinkTTL keeps a link open for a while after delivery so the recipient can check the outcome.
+const trackingLinkTTL = 24 * time.Hour
+
+// trackingStore is the part of the orders repository behind recipient tracking links.
+type trackingStore interface {
+	TrackingByToken(ctx context.Context, token string) (repo.TrackingStop, error)
+	TrackingStopsByOrder(ctx context.Context, orderID int64) ([]repo.TrackingStop, error)
+	ActiveTrackingStops(ctx context.Context, courierID int64) ([]repo.TrackingStop, error)
+	MarkTrackingNotified(ctx context.Context, linkID int64, kind string, at time.Time) (bool, error)
+}
+
+// recipientTracking caches tracked drop-offs of each courier and throttles routing for their ETA.
+type recipientTracking struct {
+	// tracker is nil without a routing provider; then recipients see no ETA.
+	tracker *taxigeo.ETATracker
+
+	mu    sync.Mutex
+	busy  map[int64]bool
+	stops map[int64]trackedStops
+}
+
+type trackedStops struct {
+	items     []repo.TrackingStop
+	checkedAt time.Time
+}
+
+func newRecipientTracking(router taxigeo.Router, refresh time.Duration) *recipientTracking {
+	t := &recipientTracking{
+		busy:  make(map[int64]bool),
+		stops: make(map[int64]trackedStops),
+	}
+	if router != nil {
+		t.tracker = taxigeo.NewETATracker(router, refresh)
+	}
+	return t
+}
+
+func (t *recipientTracking) begin(courierID int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.busy[courierID] {
+		return false
+	}
+	t.busy[courierID] = true
+	return true
+}
+
+func (t *recipientTracking) end(courierID int64) {
+	t.mu.Lock()
+	delete(t.busy, courierID)
+	t.mu.Unlock()
+}
+
+func (t *recipientTracking) cached(courierID int64, now time.Time) ([]repo.TrackingStop, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.stops[courierID]
+	if !ok || now.Sub(entry.checkedAt) >= liveTripRecheck {
+		return nil, false
+	}
+	return entry.items, true
+}
+
+func (t *recipientTracking) remember(courierID int64, items []repo.TrackingStop, now time.Time) {
+	t.mu.Lock()
+	t.stops[courierID] = trackedStops{items: items, checkedAt: now}
+	t.mu.Unlock()
+}
+
+// forget drops the cache after an order event so a new status is seen on the next position.
+func (t *recipientTracking) forget(courierID int64) {
+	t.mu.Lock()
+	delete(t.stops, courierID)
+	t.mu.Unlock()
+}
+
+func (t *recipientTracking) estimate(ctx context.Context, stop repo.TrackingStop, lon, lat float64, now time.Time) (taxigeo.ETA, bool) {
+	if t.tracker == nil {
+		return taxigeo.ETA{}, false
+	}
+	eta, err := t.tracker.Estimate(ctx, stop.PointID, lon, lat, stop.Lon, stop.Lat, now)
+	if err != nil {
+		return taxigeo.ETA{}, false
+	}
+	return eta, true
+}
+
+type trackingCourierResponse struct {
+	FirstName   string `json:"first_name"`
+	Photo       string `json:"photo,omitempty"`
+	VehicleType string `json:"vehicle_type,omitempty"`
+}
+
+type trackingPositionResponse struct {
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type trackingResponse struct {
+	Type       string                    `json:"type"`
+	OrderID    int64                     `json:"order_id"`
+	Status     string                    `json:"status"`
+	Address    string                    `json:"address"`
+	Lat        float64                   `json:"lat"`
+	Lon        float64                   `json:"lon"`
+	Delivered  bool                      `json:"delivered"`
+	Courier    *trackingCourierResponse  `json:"courier,omitempty"`
+	Position   *trackingPositionResponse `json:"position,omitempty"`
+	DistanceM  *int                      `json:"distance_m,omitempty"`
+	EtaSeconds *int                      `json:"eta_s,omitempty"`
+}
+
+// trackingLive reports whether the recipient should see the courier on the map.
+func trackingLive(stop repo.TrackingStop) bool {
+	if stop.Delivered || !stop.CourierID.Valid {
+		return false
+	}
+	switch stop.OrderStatus {
+	case lifecycle.StatusAccepted, lifecycle.StatusWaitingFree, lifecycle.StatusInProgress,
+		lifecycle.StatusDeliveryAttempted, lifecycle.StatusWaitingRecipient:
+		return true
+	}
+	return false
+}
+
+// trackingLinkClosed explains why the link no longer opens: links of canceled orders are
+// revoked, the rest expire trackingLinkTTL after the drop-off. Empty means the link is open.
+func trackingLinkClosed(stop repo.TrackingStop, now time.Time) string {
+	switch stop.OrderStatus {
+	case lifecycle.StatusCanceledBySender, lifecycle.StatusCanceledByCourier, lifecycle.StatusCanceledNoShow:
+		return "tracking link revoked"
+	}
+	if stop.DeliveredAt.Valid && now.Sub(stop.DeliveredAt.Time) > trackingLinkTTL {
+		return "tracking link expired"
+	}
+	return ""
+}
+
+func (s *Server) trackingURL(token string) string {
+	base := strings.TrimRight(strings.TrimSpace(s.cfg.TrackingBaseURL), "/")
+	if base == "" {
+		return ""
+	}
+	return base + "/" + token
+}
+
+// trackingSnapshot builds the public view of one drop-off. Only the courier's first name,
+// photo and vehicle are exposed; the ETA is counted while the parcel is on its way.
+func (s *Server) trackingSnapshot(ctx context.Context, stop repo.TrackingStop) trackingResponse {
+	resp := trackingResponse{
+		Type:      "tracking",
+		OrderID:   stop.OrderID,
+		Status:    stop.OrderStatus,
+		Address:   stop.Address,
+		Lat:       stop.Lat,
+		Lon:       stop.Lon,
+		Delivered: stop.Delivered,
+	}
+	if !trackingLive(stop) {
+		return resp
+	}
+	courierID := stop.CourierID.Int64
+	if s.couriers != nil {
+		if c, err := s.couriers.Get(ctx, courierID); err == nil {
+			resp.Courier = &trackingCourierResponse{FirstName: c.FirstName, Photo: c.Photo, VehicleType: c.VehicleType}
+		}
+	}
+	if s.cHub == nil {
+		return resp
+	}
+	pos, ok := s.cHub.Position(courierID)
+	if !ok {
+		return resp
+	}
+	resp.Position = &trackingPositionResponse{Lat: pos.Lat, Lon: pos.Lon, UpdatedAt: pos.UpdatedAt}
+	if stop.OrderStatus == lifecycle.StatusInProgress {
+		if eta, ok := s.tracking.estimate(ctx, stop, pos.Lon, pos.Lat, timeutil.Now()); ok {
+			resp.DistanceM, resp.EtaSeconds = &eta.DistanceM, &eta.EtaSeconds
+		}
+	}
+	return resp
+}
+
+func parseTrackingToken(raw string) (string, bool) {
+	token := strings.ToLower(strings.Trim(strings.TrimSpace(raw), "/"))
+	if len(token) != 32 {
+		return "", false
+	}
+	for _, c := range token {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return "", false
+		}
+	}
+	return token, true
+}
+
+func (s *Server) loadTrackingStop(w http.ResponseWriter, r *http.Request, raw string) (repo.TrackingStop, bool) {
+	token, ok := parseTrackingToken(raw)
+	if !ok {
+		writeError(w, http.StatusNotFound, "tracking link not found")
+		return repo.TrackingStop{}, false
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	stop, err := s.trackingLinks.TrackingByToken(ctx, token)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "tracking link not found")
+		return repo.TrackingStop{}, false
+	}
+	if err != nil {
+		s.logger.Errorf("courier: load tracking link failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load tracking")
+		return repo.TrackingStop{}, false
+	}
+	if msg := trackingLinkClosed(stop, timeutil.Now()); msg != "" {
+		writeError(w, http.StatusGone, msg)
+		return repo.TrackingStop{}, false
+	}
+	return stop, true
+}
+
+// handleRecipientTracking is the public read-only view behind the recipient's link.
+func (s *Server) handleRecipientTracking(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	stop, ok := s.loadTrackingStop(w, r, strings.TrimPrefix(r.URL.Path, "/api/v1/courier/track/"))
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	writeJSON(w, http.StatusOK, s.trackingSnapshot(ctx, stop))
+}
+
+// handleRecipientTrackingWS streams snapshots and courier positions for ?token=.
+func (s *Server) handleRecipientTrackingWS(w http.ResponseWriter, r *http.Request) {
+	stop, ok := s.loadTrackingStop(w, r, r.URL.Query().Get("token"))
+	if !ok {
+		return
+	}
+	s.tHub.ServeWS(w, r, stop.Token)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		s.tHub.Push(stop.Token, s.trackingSnapshot(ctx, stop))
+	}()
+}
+
+// watchRecipients runs on every courier position: pushes it to recipients watching their
+// links and texts those the courier is approaching.
+func (s *Server) watchRecipients(courierID int64, lon, lat float64) {
+	if !s.tracking.begin(courierID) {
+		return
+	}
+	go func() {
+		defer s.tracking.end(courierID)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		now := timeutil.Now()
+		stops, ok := s.tracking.cached(courierID, now)
+		if !ok {
+			var err error
+			if stops, err = s.trackingLinks.ActiveTrackingStops(ctx, courierID); err != nil {
+				s.logger.Errorf("courier: load tracking stops of courier %d failed: %v", courierID, err)
+				return
+			}
+			s.tracking.remember(courierID, stops, now)
+		}
+
+		for i := range stops {
+			stop := &stops[i]
+			if !trackingLive(*stop) {
+				continue
+			}
+			inProgress := stop.OrderStatus == lifecycle.StatusInProgress
+			if inProgress && !stop.ApproachNotified && taxigeo.DistanceMeters(lon, lat, stop.Lon, stop.Lat) <= float64(s.cfg.TrackingApproachM) {
+				// флаг в кеше ставим сразу, чтобы не дёргать БД на каждой координате
+				stop.ApproachNotified = true
+				s.notifyRecipient(ctx, *stop, repo.TrackingNotifyApproach)
+			}
+			if !s.tHub.HasViewers(stop.Token) {
+				continue
+			}
+			update := trackingResponse{
+				Type:     "courier_position",
+				OrderID:  stop.OrderID,
+				Status:   stop.OrderStatus,
+				Address:  stop.Address,
+				Lat:      stop.Lat,
+				Lon:      stop.Lon,
+				Position: &trackingPositionResponse{Lat: lat, Lon: lon, UpdatedAt: now},
+			}
+			if inProgress {
+				if eta, ok := s.tracking.estimate(ctx, *stop, lon, lat, now); ok {
+					update.DistanceM, update.EtaSeconds = &eta.DistanceM, &eta.EtaSeconds
+				}
+			}
+			s.tHub.Push(stop.Token, update)
+		}
+	}()
+}
+
+// trackOrderEvent mirrors order changes to recipients and texts them once the parcel is picked up.
+func (s *Server) trackOrderEvent(order repo.Order) {
+	if order.CourierID.Valid {
+		s.tracking.forget(order.CourierID.Int64)
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		stops, err := s.trackingLinks.TrackingStopsByOrder(ctx, order.ID)
+		if err != nil {
+			s.logger.Errorf("courier: load tracking stops of order %d failed: %v", order.ID, err)
+			return
+		}
+		for _, stop := range stops {
+			if order.Status == lifecycle.StatusInProgress && !stop.PickupNotified && !stop.Delivered {
+				s.notifyRecipient(ctx, stop, repo.TrackingNotifyPickup)
+			}
+			if s.tHub.HasViewers(stop.Token) {
+				s.tHub.Push(stop.Token, s.trackingSnapshot(ctx, stop))
+			}
+		}
+	}()
+}
+
+func (s *Server) notifyRecipient(ctx context.Context, stop repo.TrackingStop, kind string) {
+	if s.sms == nil {
+		return
+	}
+	marked, err := s.trackingLinks.MarkTrackingNotified(ctx, stop.LinkID, kind, timeutil.Now())
+	if err != nil {
+		s.logger.Errorf("courier: mark %s notification for point %d failed: %v", kind, stop.PointID, err)
+		return
+	}
+	if !marked {
+		return
+	}
+	msg := "Курьер забрал вашу посылку и уже в пути."
+	if kind == repo.TrackingNotifyApproach {
+		msg = "Курьер подъезжает к вам, подготовьтесь к получению посылки."
+	}
+	if link := s.trackingURL(stop.Token); link != "" {
+		msg = fmt.Sprintf("%s Отслеживать: %s", msg, link)
+	}
+	if err := s.sms.Send(ctx, stop.Phone, msg); err != nil {
+		s.logger.Errorf("courier: send %s notification for point %d failed: %v", kind, stop.PointID, err)
+	}
+}
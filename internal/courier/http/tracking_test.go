@@ -0,0 +1,233 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"naimuBack/internal/courier/lifecycle"
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+const testTrackingToken = "0123456789abcdef0123456789abcdef"
+
+type fakeTrackingStore struct {
+	mu       sync.Mutex
+	stops    map[string]repo.TrackingStop
+	lookups  int
+	notified map[trackingNotice]bool
+}
+
+type trackingNotice struct {
+	linkID int64
+	kind   string
+}
+
+func (f *fakeTrackingStore) TrackingByToken(_ context.Context, token string) (repo.TrackingStop, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lookups++
+	stop, ok := f.stops[token]
+	if !ok {
+		return repo.TrackingStop{}, repo.ErrNotFound
+	}
+	return stop, nil
+}
+
+func (f *fakeTrackingStore) TrackingStopsByOrder(context.Context, int64) ([]repo.TrackingStop, error) {
+	return nil, nil
+}
+
+func (f *fakeTrackingStore) ActiveTrackingStops(context.Context, int64) ([]repo.TrackingStop, error) {
+	return nil, nil
+}
+
+func (f *fakeTrackingStore) MarkTrackingNotified(_ context.Context, linkID int64, kind string, _ time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.notified == nil {
+		f.notified = make(map[trackingNotice]bool)
+	}
+	key := trackingNotice{linkID: linkID, kind: kind}
+	if f.notified[key] {
+		return false, nil
+	}
+	f.notified[key] = true
+	return true, nil
+}
+
+type fakeSMS struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (f *fakeSMS) Send(_ context.Context, phone, message string) error {
+	f.mu.Lock()
+	f.sent = append(f.sent, phone+": "+message)
+	f.mu.Unlock()
+	return nil
+}
+
+func trackingStop(status string) repo.TrackingStop {
+	return repo.TrackingStop{
+		LinkID:      1,
+		Token:       testTrackingToken,
+		Phone:       "+77010000000",
+		OrderID:     7,
+		PointID:     11,
+		Seq:         1,
+		Address:     "Абая 10",
+		OrderStatus: status,
+	}
+}
+
+func TestHandleRecipientTracking(t *testing.T) {
+	now := timeutil.Now()
+	delivered := func(ago time.Duration) repo.TrackingStop {
+		stop := trackingStop(lifecycle.StatusCompleted)
+		stop.Delivered = true
+		stop.DeliveredAt = sql.NullTime{Time: now.Add(-ago), Valid: true}
+		return stop
+	}
+	cases := []struct {
+		name    string
+		path    string
+		stop    *repo.TrackingStop
+		status  int
+		message string
+		lookups int
+	}{
+		{name: "open link", path: testTrackingToken, stop: ptrStop(trackingStop(lifecycle.StatusInProgress)), status: http.StatusOK, lookups: 1},
+		{name: "upper case token", path: strings.ToUpper(testTrackingToken), stop: ptrStop(trackingStop(lifecycle.StatusAccepted)), status: http.StatusOK, lookups: 1},
+		{name: "malformed token", path: "not-a-token", status: http.StatusNotFound, message: "tracking link not found"},
+		{name: "unknown token", path: testTrackingToken, status: http.StatusNotFound, message: "tracking link not found", lookups: 1},
+		{name: "canceled order", path: testTrackingToken, stop: ptrStop(trackingStop(lifecycle.StatusCanceledBySender)), status: http.StatusGone, message: "tracking link revoked", lookups: 1},
+		{name: "recently delivered", path: testTrackingToken, stop: ptrStop(delivered(time.Hour)), status: http.StatusOK, lookups: 1},
+		{name: "delivered long ago", path: testTrackingToken, stop: ptrStop(delivered(trackingLinkTTL + time.Minute)), status: http.StatusGone, message: "tracking link expired", lookups: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &fakeTrackingStore{stops: map[string]repo.TrackingStop{}}
+			if tc.stop != nil {
+				store.stops[testTrackingToken] = *tc.stop
+			}
+			s := testServer()
+			s.trackingLinks = store
+
+			rec := httptest.NewRecorder()
+			s.handleRecipientTracking(rec, httptest.NewRequest(http.MethodGet, "/api/v1/courier/track/"+tc.path, nil))
+			if rec.Code != tc.status {
+				t.Fatalf("expected %d got %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+			if store.lookups != tc.lookups {
+				t.Fatalf("expected %d lookups got %d", tc.lookups, store.lookups)
+			}
+			if tc.status != http.StatusOK {
+				var body map[string]string
+				if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+					t.Fatalf("decode error: %v", err)
+				}
+				if body["error"] != tc.message {
+					t.Fatalf("expected %q got %q", tc.message, body["error"])
+				}
+				return
+			}
+			var resp trackingResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode snapshot: %v", err)
+			}
+			if resp.OrderID != tc.stop.OrderID || resp.Status != tc.stop.OrderStatus || resp.Delivered != tc.stop.Delivered {
+				t.Fatalf("unexpected snapshot %+v", resp)
+			}
+			// без курьера получатель не видит ни позиции, ни ETA
+			if resp.Courier != nil || resp.Position != nil || resp.EtaSeconds != nil {
+				t.Fatalf("snapshot leaks courier data: %+v", resp)
+			}
+		})
+	}
+}
+
+func ptrStop(stop repo.TrackingStop) *repo.TrackingStop {
+	return &stop
+}
+
+func TestHandleRecipientTrackingWSRejectsClosedLinks(t *testing.T) {
+	store := &fakeTrackingStore{stops: map[string]repo.TrackingStop{
+		testTrackingToken: trackingStop(lifecycle.StatusCanceledByCourier),
+	}}
+	s := testServer()
+	s.trackingLinks = store
+
+	rec := httptest.NewRecorder()
+	s.handleRecipientTrackingWS(rec, httptest.NewRequest(http.MethodGet, "/ws/courier/track?token="+testTrackingToken, nil))
+	if rec.Code != http.StatusGone {
+		t.Fatalf("expected %d got %d", http.StatusGone, rec.Code)
+	}
+}
+
+func TestNotifyRecipientOnce(t *testing.T) {
+	store := &fakeTrackingStore{}
+	sms := &fakeSMS{}
+	s := testServer()
+	s.cfg.TrackingBaseURL = "https://naimu.kz/track/"
+	s.trackingLinks = store
+	s.sms = sms
+
+	stop := trackingStop(lifecycle.StatusInProgress)
+	ctx := context.Background()
+	s.notifyRecipient(ctx, stop, repo.TrackingNotifyPickup)
+	s.notifyRecipient(ctx, stop, repo.TrackingNotifyPickup)
+	if len(sms.sent) != 1 {
+		t.Fatalf("expected 1 sms got %d", len(sms.sent))
+	}
+	if !strings.Contains(sms.sent[0], "https://naimu.kz/track/"+testTrackingToken) {
+		t.Fatalf("sms has no tracking link: %q", sms.sent[0])
+	}
+
+	// подъезд — отдельное уведомление, тоже одноразовое
+	s.notifyRecipient(ctx, stop, repo.TrackingNotifyApproach)
+	s.notifyRecipient(ctx, stop, repo.TrackingNotifyApproach)
+	if len(sms.sent) != 2 {
+		t.Fatalf("expected 2 sms got %d", len(sms.sent))
+	}
+	if !strings.HasPrefix(sms.sent[1], stop.Phone+": Курьер подъезжает") {
+		t.Fatalf("unexpected approach sms %q", sms.sent[1])
+	}
+}
+
+func TestTrackingLinkClosed(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name        string
+		status      string
+		deliveredAt time.Time
+		want        string
+	}{
+		{name: "in progress", status: lifecycle.StatusInProgress},
+		{name: "returning", status: lifecycle.StatusReturning},
+		{name: "canceled by sender", status: lifecycle.StatusCanceledBySender, want: "tracking link revoked"},
+		{name: "canceled by courier", status: lifecycle.StatusCanceledByCourier, want: "tracking link revoked"},
+		{name: "no show", status: lifecycle.StatusCanceledNoShow, want: "tracking link revoked"},
+		{name: "delivered within ttl", status: lifecycle.StatusInProgress, deliveredAt: now.Add(-trackingLinkTTL)},
+		{name: "delivered past ttl", status: lifecycle.StatusCompleted, deliveredAt: now.Add(-trackingLinkTTL - time.Second), want: "tracking link expired"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stop := trackingStop(tc.status)
+			if !tc.deliveredAt.IsZero() {
+				stop.Delivered = true
+				stop.DeliveredAt = sql.NullTime{Time: tc.deliveredAt, Valid: true}
+			}
+			if got := trackingLinkClosed(stop, now); got != tc.want {
+				t.Fatalf("expected %q got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"naimuBack/internal/courier/repo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+const (
+	merchantWebhookEvent = "order.status_changed"
+
+	webhookTimeout      = 10 * time.Second
+	webhookPollInterval = 15 * time.Second
+	webhookBatchSize    = 50
+	webhookLease        = time.Minute
+)
+
+// errWebhookAddress is returned for webhook hosts in loopback, private or link-local networks.
+var errWebhookAddress = errors.New("webhook_url must point to a public address")
+
+// webhookBackoff — пауза перед каждой следующей попыткой; после последней доставка считается проваленной.
+var webhookBackoff = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	12 * time.Hour,
+}
+
+type merchantWebhookPayload struct {
+	Event      string                `json:"event"`
+	OrderID    int64                 `json:"order_id"`
+	ExternalID *string               `json:"external_id,omitempty"`
+	Status     string                `json:"status"`
+	OccurredAt time.Time             `json:"occurred_at"`
+	Order      merchantOrderResponse `json:"order"`
+}
+
+type merchantWebhookResponse struct {
+	ID          int64           `json:"id"`
+	OrderID     int64           `json:"order_id"`
+	Event       string          `json:"event"`
+	Status      string          `json:"status"`
+	State       string          `json:"state"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt *time.Time      `json:"next_attempt_at,omitempty"`
+	LastError   *string         `json:"last_error,omitempty"`
+	DeliveredAt *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+// OrderStatusChanged queues the merchant webhook for status changes made by the dispatcher.
+func (s *Server) OrderStatusChanged(orderID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	order, err := s.orders.Get(ctx, orderID)
+	if err != nil {
+		s.logger.Errorf("courier: load order %d for merchant webhook failed: %v", orderID, err)
+		return
+	}
+	s.queueMerchantWebhook(ctx, order)
+}
+
+// queueMerchantWebhook stores a status notification for merchant orders; repeated events with the same status are dropped.
+func (s *Server) queueMerchantWebhook(ctx context.Context, order repo.Order) {
+	if s.merchants == nil || !order.MerchantID.Valid {
+		return
+	}
+	m, err := s.merchants.Get(ctx, order.MerchantID.Int64)
+	if err != nil {
+		s.logger.Errorf("courier: load merchant %d for webhook failed: %v", order.MerchantID.Int64, err)
+		return
+	}
+	if !m.WebhookURL.Valid {
+		return
+	}
+	now := timeutil.Now()
+	payload, err := json.Marshal(merchantWebhookPayload{
+		Event:      merchantWebhookEvent,
+		OrderID:    order.ID,
+		ExternalID: nullToPtr(order.ExternalID),
+		Status:     order.Status,
+		OccurredAt: now,
+		Order:      s.merchantOrderView(ctx, order, false),
+	})
+	if err != nil {
+		s.logger.Errorf("courier: encode webhook of order %d failed: %v", order.ID, err)
+		return
+	}
+	if _, err := s.merchants.QueueWebhook(ctx, repo.MerchantWebhook{
+		MerchantID:    m.ID,
+		OrderID:       order.ID,
+		Event:         merchantWebhookEvent,
+		Status:        order.Status,
+		Payload:       payload,
+		NextAttemptAt: now,
+	}); err != nil {
+		s.logger.Errorf("courier: queue webhook of order %d failed: %v", order.ID, err)
+	}
+}
+
+// RunMerchantWebhooks delivers queued merchant webhooks until ctx is done.
+func (s *Server) RunMerchantWebhooks(ctx context.Context) {
+	if s.merchants == nil {
+		return
+	}
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.deliverDueWebhooks(ctx)
+		}
+	}
+}
+
+func (s *Server) deliverDueWebhooks(ctx context.Context) {
+	now := timeutil.Now()
+	hooks, err := s.merchants.DueWebhooks(ctx, now, webhookBatchSize)
+	if err != nil {
+		s.logger.Errorf("courier: load due webhooks failed: %v", err)
+		return
+	}
+	for _, hook := range hooks {
+		claimed, err := s.merchants.ClaimWebhook(ctx, hook.ID, now, now.Add(webhookLease))
+		if err != nil {
+			s.logger.Errorf("courier: claim webhook %d failed: %v", hook.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		s.deliverWebhook(ctx, hook)
+	}
+}
+
+func (s *Server) deliverWebhook(ctx context.Context, hook repo.MerchantWebhook) {
+	err := s.postWebhook(ctx, hook)
+	now := timeutil.Now()
+	if err == nil {
+		if err := s.merchants.MarkWebhookDelivered(ctx, hook.ID, now); err != nil {
+			s.logger.Errorf("courier: mark webhook %d delivered failed: %v", hook.ID, err)
+		}
+		return
+	}
+
+	var next time.Time
+	if hook.Attempts < len(webhookBackoff) {
+		next = now.Add(webhookBackoff[hook.Attempts])
+	} else {
+		s.logger.Errorf("courier: webhook %d of order %d to merchant %d failed for good: %v", hook.ID, hook.OrderID, hook.MerchantID, err)
+	}
+	if err := s.merchants.MarkWebhookAttemptFailed(ctx, hook.ID, err.Error(), next); err != nil {
+		s.logger.Errorf("courier: record webhook %d failure failed: %v", hook.ID, err)
+	}
+}
+
+// publicIP reports whether the address is reachable from the internet, so a merchant cannot aim
+// webhooks at our own services or the cloud metadata endpoint.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// webhookDialControl runs on the resolved address right before connecting.
+func webhookDialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return errWebhookAddress
+	}
+	return nil
+}
+
+// newWebhookClient connects only to public addresses and does not follow redirects.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: webhookDialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// через прокси адрес назначения проверить нельзя
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// signWebhook returns hex HMAC-SHA256 of "<timestamp>.<body>" with the merchant's secret.
+func signWebhook(secret, ts string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// postWebhook sends the payload signed as HMAC-SHA256 of "<timestamp>.<body>" with the merchant's secret.
+func (s *Server) postWebhook(ctx context.Context, hook repo.MerchantWebhook) error {
+	if hook.URL == "" {
+		return fmt.Errorf("webhook url is not set")
+	}
+	ts := strconv.FormatInt(timeutil.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(hook.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", strconv.FormatInt(hook.ID, 10))
+	req.Header.Set("X-Webhook-Event", hook.Event)
+	req.Header.Set("X-Webhook-Timestamp", ts)
+	req.Header.Set("X-Webhook-Signature", signWebhook(hook.Secret, ts, hook.Payload))
+
+	resp, err := s.webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	// тело ответа не сохраняем: last_error виден мерчанту
+	return fmt.Errorf("merchant responded %d", resp.StatusCode)
+}
+
+// handleMerchantWebhookLog lists webhook deliveries so the shop can see what reached it.
+func (s *Server) handleMerchantWebhookLog(w http.ResponseWriter, r *http.Request, m repo.Merchant) {
+	limit, offset, err := parsePaging(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := contextWithTimeout(r)
+	defer cancel()
+	hooks, err := s.merchants.ListWebhooks(ctx, m.ID, limit, offset)
+	if err != nil {
+		s.logger.Errorf("courier: list webhooks of merchant %d failed: %v", m.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to list webhooks")
+		return
+	}
+	items := make([]merchantWebhookResponse, 0, len(hooks))
+	for _, h := range hooks {
+		item := merchantWebhookResponse{
+			ID:          h.ID,
+			OrderID:     h.OrderID,
+			Event:       h.Event,
+			Status:      h.Status,
+			State:       h.State,
+			Attempts:    h.Attempts,
+			LastError:   nullToPtr(h.LastError),
+			DeliveredAt: nullTimeToPtr(h.DeliveredAt),
+			CreatedAt:   h.CreatedAt,
+			Payload:     json.RawMessage(h.Payload),
+		}
+		if h.State == repo.WebhookPending {
+			next := h.NextAttemptAt
+			item.NextAttempt = &next
+		}
+		items = append(items, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": items, "limit": limit, "offset": offset})
+}
@@ -12,6 +12,13 @@ const (
 	StatusCanceledBySender  = "canceled_by_sender"
 	StatusCanceledByCourier = "canceled_by_courier"
 	StatusCanceledNoShow    = "canceled_no_show"
+
+	// Недоставка: курьер на месте, получатель не отвечает; ожидание сначала бесплатное,
+	// затем платное, после чего посылка едет обратно отправителю.
+	StatusDeliveryAttempted = "delivery_attempted"
+	StatusWaitingRecipient  = "waiting_recipient"
+	StatusReturning         = "returning"
+	StatusReturned          = "returned"
 )
 
 var transitions = map[string]map[string]struct{}{
@@ -37,6 +44,20 @@ var transitions = map[string]map[string]struct{}{
 		StatusCompleted:         {},
 		StatusCanceledByCourier: {},
 		StatusCanceledBySender:  {},
+		StatusDeliveryAttempted: {},
+	},
+	// Получатель нашёлся — заказ снова в пути и завершается обычным образом, с подтверждениями.
+	StatusDeliveryAttempted: {
+		StatusWaitingRecipient: {},
+		StatusInProgress:       {},
+		StatusReturning:        {},
+	},
+	StatusWaitingRecipient: {
+		StatusInProgress: {},
+		StatusReturning:  {},
+	},
+	StatusReturning: {
+		StatusReturned: {},
 	},
 	StatusCompleted: {
 		StatusClosed: {},
@@ -0,0 +1,49 @@
+package lifecycle
+
+import "testing"
+
+func TestCanTransitionFailedDelivery(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusInProgress, StatusDeliveryAttempted, true},
+		{StatusDeliveryAttempted, StatusWaitingRecipient, true},
+		{StatusDeliveryAttempted, StatusReturning, true},
+		{StatusDeliveryAttempted, StatusInProgress, true},
+		{StatusWaitingRecipient, StatusReturning, true},
+		{StatusWaitingRecipient, StatusInProgress, true},
+		{StatusReturning, StatusReturned, true},
+		{StatusWaitingRecipient, StatusWaitingRecipient, true},
+
+		// доставка считается завершённой только через in_progress с подтверждениями
+		{StatusDeliveryAttempted, StatusCompleted, false},
+		{StatusWaitingRecipient, StatusCompleted, false},
+		{StatusWaitingRecipient, StatusDeliveryAttempted, false},
+		{StatusReturning, StatusInProgress, false},
+		{StatusReturning, StatusCompleted, false},
+		{StatusReturning, StatusCanceledBySender, false},
+		{StatusReturned, StatusReturning, false},
+		{StatusReturned, StatusClosed, false},
+		{StatusAccepted, StatusDeliveryAttempted, false},
+		{StatusWaitingFree, StatusReturning, false},
+		{StatusNew, StatusWaitingRecipient, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.from+"->"+tc.to, func(t *testing.T) {
+			if got := CanTransition(tc.from, tc.to); got != tc.want {
+				t.Fatalf("expected %v got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFailedDeliveryPath(t *testing.T) {
+	path := []string{StatusNew, StatusAccepted, StatusInProgress, StatusDeliveryAttempted, StatusWaitingRecipient, StatusReturning, StatusReturned}
+	for i := 1; i < len(path); i++ {
+		if !CanTransition(path[i-1], path[i]) {
+			t.Fatalf("expected %s -> %s to be allowed", path[i-1], path[i])
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package parcel
+
+import (
+	"errors"
+	"strings"
+)
+
+// Parcel classes declared by the sender.
+const (
+	ClassDocuments = "documents"
+	ClassSmall     = "small"
+	ClassMedium    = "medium"
+	ClassLarge     = "large"
+	ClassOversize  = "oversize"
+)
+
+// Courier vehicle types.
+const (
+	VehicleFoot = "foot"
+	VehicleBike = "bike"
+	VehicleCar  = "car"
+	VehicleVan  = "van"
+)
+
+var (
+	// ErrUnknownClass is returned for a class outside the list above.
+	ErrUnknownClass = errors.New("unknown parcel class")
+	// ErrInvalidSize is returned for negative weight or dimensions.
+	ErrInvalidSize = errors.New("invalid parcel size")
+	// ErrTooHeavy is returned when the weight exceeds the class limit.
+	ErrTooHeavy = errors.New("parcel is too heavy for its class")
+	// ErrTooLarge is returned when a side exceeds the class limit.
+	ErrTooLarge = errors.New("parcel is too large for its class")
+)
+
+// Limits — предельный вес и длина наибольшей стороны для класса.
+type Limits struct {
+	MaxWeightKg float64
+	MaxSideCm   int
+}
+
+// classes lists classes from the smallest to the largest.
+var classes = []string{ClassDocuments, ClassSmall, ClassMedium, ClassLarge, ClassOversize}
+
+var classLimits = map[string]Limits{
+	ClassDocuments: {MaxWeightKg: 0.5, MaxSideCm: 40},
+	ClassSmall:     {MaxWeightKg: 5, MaxSideCm: 40},
+	ClassMedium:    {MaxWeightKg: 15, MaxSideCm: 60},
+	ClassLarge:     {MaxWeightKg: 30, MaxSideCm: 120},
+	ClassOversize:  {MaxWeightKg: 200, MaxSideCm: 300},
+}
+
+// vehicleRank orders vehicle types by what they can carry.
+var vehicleRank = map[string]int{
+	VehicleFoot: 0,
+	VehicleBike: 1,
+	VehicleCar:  2,
+	VehicleVan:  3,
+}
+
+// minVehicle is the smallest vehicle type allowed to take a class.
+var minVehicle = map[string]string{
+	ClassDocuments: VehicleFoot,
+	ClassSmall:     VehicleFoot,
+	ClassMedium:    VehicleBike,
+	ClassLarge:     VehicleCar,
+	ClassOversize:  VehicleVan,
+}
+
+// vehicleMaxLoadKg is the total weight a courier may carry across batched orders.
+var vehicleMaxLoadKg = map[string]float64{
+	VehicleFoot: 8,
+	VehicleBike: 20,
+	VehicleCar:  150,
+	VehicleVan:  600,
+}
+
+// Parcel describes what is being delivered. Zero weight or dimensions mean "not declared".
+type Parcel struct {
+	Class    string
+	WeightKg float64
+	LengthCm int
+	WidthCm  int
+	HeightCm int
+}
+
+// NormalizeClass lowercases the class; the empty string falls back to small.
+func NormalizeClass(class string) (string, bool) {
+	class = strings.ToLower(strings.TrimSpace(class))
+	if class == "" {
+		return ClassSmall, true
+	}
+	_, ok := classLimits[class]
+	return class, ok
+}
+
+// NormalizeVehicle lowercases the vehicle type and reports whether it is known.
+func NormalizeVehicle(vehicle string) (string, bool) {
+	vehicle = strings.ToLower(strings.TrimSpace(vehicle))
+	_, ok := vehicleRank[vehicle]
+	return vehicle, ok
+}
+
+// LimitsFor returns the limits of a class.
+func LimitsFor(class string) (Limits, bool) {
+	l, ok := classLimits[class]
+	return l, ok
+}
+
+func (p Parcel) maxSide() int {
+	side := p.LengthCm
+	if p.WidthCm > side {
+		side = p.WidthCm
+	}
+	if p.HeightCm > side {
+		side = p.HeightCm
+	}
+	return side
+}
+
+// Validate checks the declared size against the class limits.
+func (p Parcel) Validate() error {
+	limits, ok := classLimits[p.Class]
+	if !ok {
+		return ErrUnknownClass
+	}
+	if p.WeightKg < 0 || p.LengthCm < 0 || p.WidthCm < 0 || p.HeightCm < 0 {
+		return ErrInvalidSize
+	}
+	if p.WeightKg > limits.MaxWeightKg {
+		return ErrTooHeavy
+	}
+	if p.maxSide() > limits.MaxSideCm {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// Classify picks the smallest class that fits the weight and dimensions.
+func Classify(weightKg float64, lengthCm, widthCm, heightCm int) (string, bool) {
+	p := Parcel{WeightKg: weightKg, LengthCm: lengthCm, WidthCm: widthCm, HeightCm: heightCm}
+	for _, class := range classes {
+		p.Class = class
+		if p.Validate() == nil {
+			return class, true
+		}
+	}
+	return "", false
+}
+
+// Carries reports whether a courier on the vehicle may take a parcel of the class.
+// Unknown vehicles are treated as on foot.
+func Carries(vehicle, class string) bool {
+	need, ok := minVehicle[class]
+	if !ok {
+		need = minVehicle[ClassSmall]
+	}
+	return vehicleRank[vehicle] >= vehicleRank[need]
+}
+
+// MaxLoadKg returns the combined weight limit of the vehicle; unknown vehicles count as on foot.
+func MaxLoadKg(vehicle string) float64 {
+	if load, ok := vehicleMaxLoadKg[vehicle]; ok {
+		return load
+	}
+	return vehicleMaxLoadKg[VehicleFoot]
+}
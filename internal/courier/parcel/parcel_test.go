@@ -0,0 +1,112 @@
+package parcel
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		parcel Parcel
+		want   error
+	}{
+		{"undeclared size", Parcel{Class: ClassSmall}, nil},
+		{"documents at limits", Parcel{Class: ClassDocuments, WeightKg: 0.5, LengthCm: 40}, nil},
+		{"documents too heavy", Parcel{Class: ClassDocuments, WeightKg: 0.6}, ErrTooHeavy},
+		{"small too long", Parcel{Class: ClassSmall, WeightKg: 1, LengthCm: 20, WidthCm: 41, HeightCm: 10}, ErrTooLarge},
+		{"medium fits", Parcel{Class: ClassMedium, WeightKg: 15, LengthCm: 60, WidthCm: 40, HeightCm: 40}, nil},
+		{"large too heavy", Parcel{Class: ClassLarge, WeightKg: 30.5}, ErrTooHeavy},
+		{"negative weight", Parcel{Class: ClassSmall, WeightKg: -1}, ErrInvalidSize},
+		{"negative side", Parcel{Class: ClassLarge, HeightCm: -5}, ErrInvalidSize},
+		{"unknown class", Parcel{Class: "pallet"}, ErrUnknownClass},
+		{"empty class", Parcel{}, ErrUnknownClass},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.parcel.Validate(); !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+				t.Fatalf("expected %v got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		name      string
+		weightKg  float64
+		length    int
+		width     int
+		height    int
+		wantClass string
+		wantOK    bool
+	}{
+		{"nothing declared", 0, 0, 0, 0, ClassDocuments, true},
+		{"envelope", 0.2, 30, 20, 1, ClassDocuments, true},
+		{"light but bulky for documents", 0.3, 45, 10, 10, ClassMedium, true},
+		{"shoe box", 2, 35, 25, 15, ClassSmall, true},
+		{"weight decides", 12, 30, 30, 30, ClassMedium, true},
+		{"long side decides", 3, 100, 20, 20, ClassLarge, true},
+		{"fridge", 80, 180, 70, 70, ClassOversize, true},
+		{"beyond oversize", 250, 100, 100, 100, "", false},
+		{"negative size", -1, 10, 10, 10, "", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			class, ok := Classify(tc.weightKg, tc.length, tc.width, tc.height)
+			if class != tc.wantClass || ok != tc.wantOK {
+				t.Fatalf("expected %q/%v got %q/%v", tc.wantClass, tc.wantOK, class, ok)
+			}
+		})
+	}
+}
+
+func TestCarries(t *testing.T) {
+	cases := []struct {
+		vehicle string
+		class   string
+		want    bool
+	}{
+		{VehicleFoot, ClassDocuments, true},
+		{VehicleFoot, ClassSmall, true},
+		{VehicleFoot, ClassMedium, false},
+		{VehicleBike, ClassMedium, true},
+		{VehicleBike, ClassLarge, false},
+		{VehicleCar, ClassLarge, true},
+		{VehicleCar, ClassOversize, false},
+		{VehicleVan, ClassOversize, true},
+		{VehicleVan, ClassDocuments, true},
+		{"scooter", ClassSmall, true},
+		{"scooter", ClassMedium, false},
+		{VehicleFoot, "pallet", true},
+		{VehicleFoot, "", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.vehicle+"/"+tc.class, func(t *testing.T) {
+			if got := Carries(tc.vehicle, tc.class); got != tc.want {
+				t.Fatalf("expected %v got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if class, ok := NormalizeClass(" Medium "); !ok || class != ClassMedium {
+		t.Fatalf("expected medium, got %q/%v", class, ok)
+	}
+	if class, ok := NormalizeClass(""); !ok || class != ClassSmall {
+		t.Fatalf("expected empty class to fall back to small, got %q/%v", class, ok)
+	}
+	if _, ok := NormalizeClass("pallet"); ok {
+		t.Fatalf("expected unknown class to be rejected")
+	}
+	if vehicle, ok := NormalizeVehicle("VAN"); !ok || vehicle != VehicleVan {
+		t.Fatalf("expected van, got %q/%v", vehicle, ok)
+	}
+	if MaxLoadKg("scooter") != MaxLoadKg(VehicleFoot) {
+		t.Fatalf("expected unknown vehicle to carry as on foot")
+	}
+}
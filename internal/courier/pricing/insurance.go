@@ -0,0 +1,43 @@
+package pricing
+
+// InsuranceRules prices declared value cover and limits it by courier verification level.
+type InsuranceRules struct {
+	// FeePercent of the declared value is charged, but never less than MinFee.
+	FeePercent int
+	MinFee     int
+	// Caps is the highest declared value a courier of the level may carry; unknown levels carry none.
+	Caps map[string]int
+}
+
+// Fee returns the insurance fee for the declared value; uninsured orders cost nothing.
+func (r InsuranceRules) Fee(declaredValue int) int {
+	if declaredValue <= 0 {
+		return 0
+	}
+	fee := (declaredValue*r.FeePercent + 99) / 100
+	if fee < r.MinFee {
+		fee = r.MinFee
+	}
+	return fee
+}
+
+// Cap is the declared value limit for the verification level.
+func (r InsuranceRules) Cap(level string) int {
+	return r.Caps[level]
+}
+
+// MaxCap is the largest declared value any courier may carry.
+func (r InsuranceRules) MaxCap() int {
+	max := 0
+	for _, c := range r.Caps {
+		if c > max {
+			max = c
+		}
+	}
+	return max
+}
+
+// Covers reports whether a courier of the level may carry the declared value.
+func (r InsuranceRules) Covers(level string, declaredValue int) bool {
+	return declaredValue <= 0 || declaredValue <= r.Cap(level)
+}
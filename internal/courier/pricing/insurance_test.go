@@ -0,0 +1,70 @@
+package pricing
+
+import "testing"
+
+var testInsurance = InsuranceRules{
+	FeePercent: 1,
+	MinFee:     100,
+	Caps:       map[string]int{"basic": 50000, "verified": 300000},
+}
+
+func TestInsuranceFee(t *testing.T) {
+	cases := []struct {
+		name     string
+		rules    InsuranceRules
+		declared int
+		want     int
+	}{
+		{"uninsured", testInsurance, 0, 0},
+		{"negative value", testInsurance, -500, 0},
+		{"min fee floor", testInsurance, 5000, 100},
+		{"exact percent", testInsurance, 20000, 200},
+		{"percent rounds up", testInsurance, 15050, 151},
+		{"one tenge over", testInsurance, 20001, 201},
+		{"no min fee", InsuranceRules{FeePercent: 2}, 30, 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.rules.Fee(tc.declared); got != tc.want {
+				t.Fatalf("expected %d got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestInsuranceCovers(t *testing.T) {
+	cases := []struct {
+		name     string
+		level    string
+		declared int
+		want     bool
+	}{
+		{"uninsured any level", "", 0, true},
+		{"within cap", "basic", 50000, true},
+		{"over cap", "basic", 50001, false},
+		{"higher level", "verified", 300000, true},
+		{"unknown level", "unverified", 1, false},
+		{"unknown level uninsured", "unverified", 0, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := testInsurance.Covers(tc.level, tc.declared); got != tc.want {
+				t.Fatalf("expected %v got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestInsuranceMaxCap(t *testing.T) {
+	if got := testInsurance.MaxCap(); got != 300000 {
+		t.Fatalf("expected %d got %d", 300000, got)
+	}
+	if got := (InsuranceRules{}).MaxCap(); got != 0 {
+		t.Fatalf("expected %d got %d", 0, got)
+	}
+	if got := testInsurance.Cap("unknown"); got != 0 {
+		t.Fatalf("expected %d got %d", 0, got)
+	}
+}
@@ -0,0 +1,42 @@
+package pricing
+
+import (
+	"math"
+
+	"naimuBack/internal/courier/parcel"
+)
+
+// ParcelRate — тариф класса посылки: множитель цены за км и фиксированная надбавка.
+type ParcelRate struct {
+	KMFactor  float64
+	Surcharge int
+}
+
+// ParcelRates holds per-class rates; classes missing here are priced as small.
+var ParcelRates = map[string]ParcelRate{
+	parcel.ClassDocuments: {KMFactor: 0.9, Surcharge: 0},
+	parcel.ClassSmall:     {KMFactor: 1, Surcharge: 0},
+	parcel.ClassMedium:    {KMFactor: 1.2, Surcharge: 300},
+	parcel.ClassLarge:     {KMFactor: 1.5, Surcharge: 800},
+	parcel.ClassOversize:  {KMFactor: 2, Surcharge: 2000},
+}
+
+// Heavy parcels pay extra for every started kilogram above HeavyFromKg.
+const (
+	HeavyFromKg     = 10
+	HeavyPerKgPrice = 50
+)
+
+// RecommendedParcel prices a delivery with the class rate, class surcharge and weight surcharge.
+func RecommendedParcel(distanceMeters, pricePerKM, minPrice int, p parcel.Parcel) int {
+	rate, ok := ParcelRates[p.Class]
+	if !ok {
+		rate = ParcelRates[parcel.ClassSmall]
+	}
+	perKM := int(math.Round(float64(pricePerKM) * rate.KMFactor))
+	price := Recommended(distanceMeters, perKM, minPrice) + rate.Surcharge
+	if p.WeightKg > HeavyFromKg {
+		price += int(math.Ceil(p.WeightKg-HeavyFromKg)) * HeavyPerKgPrice
+	}
+	return price
+}
@@ -0,0 +1,35 @@
+package pricing
+
+import (
+	"testing"
+
+	"naimuBack/internal/courier/parcel"
+)
+
+func TestRecommendedParcel(t *testing.T) {
+	cases := []struct {
+		name     string
+		distance int
+		parcel   parcel.Parcel
+		want     int
+	}{
+		{"small", 10000, parcel.Parcel{Class: parcel.ClassSmall}, 1000},
+		{"documents discount", 10000, parcel.Parcel{Class: parcel.ClassDocuments}, 900},
+		{"medium rate and surcharge", 10000, parcel.Parcel{Class: parcel.ClassMedium}, 1500},
+		{"large heavy", 10000, parcel.Parcel{Class: parcel.ClassLarge, WeightKg: 25}, 3050},
+		{"oversize below min price", 2000, parcel.Parcel{Class: parcel.ClassOversize}, 2500},
+		{"unknown class priced as small", 10000, parcel.Parcel{Class: "pallet"}, 1000},
+		{"weight at threshold", 10000, parcel.Parcel{Class: parcel.ClassMedium, WeightKg: 10}, 1500},
+		{"started kilogram above threshold", 10000, parcel.Parcel{Class: parcel.ClassMedium, WeightKg: 10.2}, 1550},
+		{"zero distance", 0, parcel.Parcel{Class: parcel.ClassSmall}, 500},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := RecommendedParcel(tc.distance, 100, 500, tc.parcel)
+			if got != tc.want {
+				t.Fatalf("expected %d got %d", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package pricing
+
+import (
+	"math"
+	"time"
+)
+
+// ReturnRules prices a failed delivery: paid waiting at the recipient and the leg back to the sender.
+type ReturnRules struct {
+	// PricePerKM and MinPrice price the return leg by its distance.
+	PricePerKM int
+	MinPrice   int
+	// OrderPercent is the floor of the return price as a share of the original order price.
+	OrderPercent int
+	// FreeWait is how long the courier waits at the recipient for free.
+	FreeWait time.Duration
+	// WaitPerMinute is charged for every started minute after FreeWait.
+	WaitPerMinute int
+}
+
+// ReturnPrice is the larger of the distance price and the share of the order price.
+func (r ReturnRules) ReturnPrice(distanceMeters, orderPrice int) int {
+	price := Recommended(distanceMeters, r.PricePerKM, r.MinPrice)
+	if share := orderPrice * r.OrderPercent / 100; share > price {
+		price = share
+	}
+	return price
+}
+
+// WaitingPrice charges the waiting beyond the free period.
+func (r ReturnRules) WaitingPrice(waited time.Duration) int {
+	paid := waited - r.FreeWait
+	if paid <= 0 || r.WaitPerMinute <= 0 {
+		return 0
+	}
+	return int(math.Ceil(paid.Minutes())) * r.WaitPerMinute
+}
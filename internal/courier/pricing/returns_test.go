@@ -0,0 +1,62 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+)
+
+var testReturnRules = ReturnRules{
+	PricePerKM:    120,
+	MinPrice:      400,
+	OrderPercent:  50,
+	FreeWait:      10 * time.Minute,
+	WaitPerMinute: 25,
+}
+
+func TestReturnPrice(t *testing.T) {
+	cases := []struct {
+		name       string
+		distance   int
+		orderPrice int
+		want       int
+	}{
+		{"distance price", 10000, 1000, 1200},
+		{"min price", 1000, 600, 400},
+		{"order share wins", 5000, 3000, 1500},
+		{"zero distance", 0, 0, 400},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := testReturnRules.ReturnPrice(tc.distance, tc.orderPrice); got != tc.want {
+				t.Fatalf("expected %d got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWaitingPrice(t *testing.T) {
+	cases := []struct {
+		name   string
+		rules  ReturnRules
+		waited time.Duration
+		want   int
+	}{
+		{"within free wait", testReturnRules, 9 * time.Minute, 0},
+		{"exactly free wait", testReturnRules, 10 * time.Minute, 0},
+		{"started minute is charged", testReturnRules, 10*time.Minute + time.Second, 25},
+		{"whole minutes", testReturnRules, 13 * time.Minute, 75},
+		{"partial minute rounds up", testReturnRules, 13*time.Minute + 30*time.Second, 100},
+		{"no free wait", ReturnRules{WaitPerMinute: 10}, 90 * time.Second, 20},
+		{"waiting not priced", ReturnRules{FreeWait: time.Minute}, time.Hour, 0},
+		{"negative duration", testReturnRules, -time.Minute, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.rules.WaitingPrice(tc.waited); got != tc.want {
+				t.Fatalf("expected %d got %d", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Package recurring computes pickup times of repeating courier deliveries.
+package recurring
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Frequencies of a recurring delivery.
+const (
+	Daily  = "daily"
+	Weekly = "weekly"
+)
+
+var weekdayNames = [7]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Rule repeats a pickup at a fixed local time every day or on chosen weekdays.
+type Rule struct {
+	Frequency string
+	// Weekdays is a bit set of time.Weekday values; only weekly rules use it.
+	Weekdays uint8
+	// Minute is the pickup time in minutes after local midnight.
+	Minute int
+}
+
+// Validate checks that the rule produces pickups.
+func (r Rule) Validate() error {
+	switch r.Frequency {
+	case Daily:
+	case Weekly:
+		if r.Weekdays&0x7f == 0 {
+			return errors.New("weekly schedule needs at least one weekday")
+		}
+	default:
+		return errors.New("frequency must be daily or weekly")
+	}
+	if r.Minute < 0 || r.Minute >= 24*60 {
+		return errors.New("time must be within a day")
+	}
+	return nil
+}
+
+// Next returns the first pickup strictly after t, in t's location.
+func (r Rule) Next(t time.Time) time.Time {
+	y, m, d := t.Date()
+	for i := 0; i <= 7; i++ {
+		at := time.Date(y, m, d+i, r.Minute/60, r.Minute%60, 0, 0, t.Location())
+		if !at.After(t) {
+			continue
+		}
+		if r.Frequency == Daily || r.Weekdays&(1<<uint(at.Weekday())) != 0 {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+// ParseWeekdays turns names such as "mon" or "friday" into a weekday bit set.
+func ParseWeekdays(names []string) (uint8, error) {
+	var set uint8
+	for _, name := range names {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if len(key) > 3 {
+			key = key[:3]
+		}
+		found := false
+		for i, wd := range weekdayNames {
+			if wd == key {
+				set |= 1 << uint(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown weekday %q", name)
+		}
+	}
+	return set, nil
+}
+
+// WeekdayNames lists the weekdays of the set starting from Monday.
+func WeekdayNames(set uint8) []string {
+	names := make([]string, 0, 7)
+	for i := 1; i <= 7; i++ {
+		wd := i % 7
+		if set&(1<<uint(wd)) != 0 {
+			names = append(names, weekdayNames[wd])
+		}
+	}
+	return names
+}
+
+// ParseClock reads "HH:MM" into minutes after midnight.
+func ParseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(v))
+	if err != nil {
+		return 0, errors.New("time must be HH:MM")
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// FormatClock renders minutes after midnight as "HH:MM".
+func FormatClock(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
@@ -0,0 +1,142 @@
+package recurring
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var almaty = time.FixedZone("Asia/Almaty", 5*60*60)
+
+func at(day, hour, minute int) time.Time {
+	// 2026-03-02 — понедельник.
+	return time.Date(2026, 3, day, hour, minute, 0, 0, almaty)
+}
+
+func TestRuleValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{"daily", Rule{Frequency: Daily, Minute: 9 * 60}, false},
+		{"weekly single day", Rule{Frequency: Weekly, Weekdays: 1 << uint(time.Friday), Minute: 0}, false},
+		{"weekly without days", Rule{Frequency: Weekly, Minute: 60}, true},
+		{"weekly only outside bits", Rule{Frequency: Weekly, Weekdays: 0x80, Minute: 60}, true},
+		{"unknown frequency", Rule{Frequency: "monthly", Minute: 60}, true},
+		{"last minute of day", Rule{Frequency: Daily, Minute: 1439}, false},
+		{"minute past the day", Rule{Frequency: Daily, Minute: 1440}, true},
+		{"negative minute", Rule{Frequency: Daily, Minute: -1}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.rule.Validate()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRuleNext(t *testing.T) {
+	weekdays := func(days ...time.Weekday) uint8 {
+		var set uint8
+		for _, d := range days {
+			set |= 1 << uint(d)
+		}
+		return set
+	}
+	cases := []struct {
+		name string
+		rule Rule
+		from time.Time
+		want time.Time
+	}{
+		{"daily later today", Rule{Frequency: Daily, Minute: 18 * 60}, at(2, 9, 0), at(2, 18, 0)},
+		{"daily time already passed", Rule{Frequency: Daily, Minute: 9 * 60}, at(2, 10, 0), at(3, 9, 0)},
+		{"daily exactly at pickup", Rule{Frequency: Daily, Minute: 9 * 60}, at(2, 9, 0), at(3, 9, 0)},
+		{"weekly same day later", Rule{Frequency: Weekly, Weekdays: weekdays(time.Monday), Minute: 12 * 60}, at(2, 9, 0), at(2, 12, 0)},
+		{"weekly single day a week ahead", Rule{Frequency: Weekly, Weekdays: weekdays(time.Monday), Minute: 9 * 60}, at(2, 10, 0), at(9, 9, 0)},
+		{"weekly wraps to sunday", Rule{Frequency: Weekly, Weekdays: weekdays(time.Sunday), Minute: 8 * 60}, at(6, 20, 0), at(8, 8, 0)},
+		{"weekly wraps past month end", Rule{Frequency: Weekly, Weekdays: weekdays(time.Tuesday), Minute: 8 * 60}, at(31, 9, 0), time.Date(2026, 4, 7, 8, 0, 0, 0, almaty)},
+		{"weekly picks nearest day", Rule{Frequency: Weekly, Weekdays: weekdays(time.Monday, time.Thursday), Minute: 7 * 60}, at(2, 8, 0), at(5, 7, 0)},
+		{"last minute of day", Rule{Frequency: Daily, Minute: 1439}, at(2, 23, 59), at(3, 23, 59)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.rule.Next(tc.from)
+			if !got.Equal(tc.want) {
+				t.Fatalf("expected %v got %v", tc.want, got)
+			}
+			if got.Location() != almaty {
+				t.Fatalf("expected location of the input, got %v", got.Location())
+			}
+		})
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      []string
+		want    uint8
+		wantErr bool
+	}{
+		{"short names", []string{"mon", "fri"}, 1<<1 | 1<<5, false},
+		{"uppercase and full names", []string{"MONDAY", " Sunday "}, 1<<1 | 1<<0, false},
+		{"duplicates", []string{"tue", "Tuesday"}, 1 << 2, false},
+		{"empty list", nil, 0, false},
+		{"unknown name", []string{"mon", "funday"}, 0, true},
+		{"too short", []string{"mo"}, 0, true},
+		{"empty name", []string{""}, 0, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseWeekdays(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v got %v", tc.wantErr, err)
+			}
+			if !tc.wantErr && got != tc.want {
+				t.Fatalf("expected %07b got %07b", tc.want, got)
+			}
+		})
+	}
+
+	if names := WeekdayNames(1<<0 | 1<<1 | 1<<6); len(names) != 3 || names[0] != "mon" || names[1] != "sat" || names[2] != "sun" {
+		t.Fatalf("expected week starting from monday, got %v", names)
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"00:00", 0, false},
+		{"09:30", 570, false},
+		{" 23:59 ", 1439, false},
+		{"24:00", 0, true},
+		{"9:5", 0, true},
+		{"12:60", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseClock(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v got %v", tc.wantErr, err)
+			}
+			if !tc.wantErr && got != tc.want {
+				t.Fatalf("expected %d got %d", tc.want, got)
+			}
+			if !tc.wantErr && FormatClock(got) != strings.TrimSpace(tc.in) {
+				t.Fatalf("FormatClock(%d) = %q", got, FormatClock(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Recurring delivery statuses.
+const (
+	RecurringActive = "active"
+	RecurringPaused = "paused"
+)
+
+// ErrTemplateInUse indicates that recurring deliveries still create orders from the template.
+var ErrTemplateInUse = errors.New("courier: template is used by recurring deliveries")
+
+// SavedAddress is a contact with its address from the sender's address book.
+type SavedAddress struct {
+	ID          int64
+	SenderID    int64
+	Label       string
+	ContactName sql.NullString
+	Phone       sql.NullString
+	Address     string
+	Lat         float64
+	Lon         float64
+	Entrance    sql.NullString
+	Apt         sql.NullString
+	Floor       sql.NullString
+	Intercom    sql.NullString
+	Comment     sql.NullString
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// OrderTemplate keeps an order request to place it again; route points may refer to saved addresses.
+type OrderTemplate struct {
+	ID        int64
+	SenderID  int64
+	Name      string
+	Request   []byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RecurringDelivery places an order from the template before every pickup of its schedule.
+type RecurringDelivery struct {
+	ID         int64
+	SenderID   int64
+	TemplateID int64
+	Frequency  string
+	Weekdays   uint8
+	// PickupMinute is the pickup time in minutes after local midnight; the pickup window lasts WindowMinutes.
+	PickupMinute  int
+	WindowMinutes int
+	Status        string
+	// NextRunAt is the next pickup time, not the moment the order is placed.
+	NextRunAt   time.Time
+	LastOrderID sql.NullInt64
+	LastRunAt   sql.NullTime
+	LastError   sql.NullString
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// AddressBookRepo persists saved addresses, order templates and recurring deliveries of senders.
+type AddressBookRepo struct {
+	db *sql.DB
+}
+
+// NewAddressBookRepo constructs an AddressBookRepo.
+func NewAddressBookRepo(db *sql.DB) *AddressBookRepo {
+	return &AddressBookRepo{db: db}
+}
+
+const savedAddressColumns = `id, sender_id, label, contact_name, phone, address, lat, lon, entrance, apt, floor, intercom, comment, created_at, updated_at`
+
+func scanSavedAddress(row interface{ Scan(...interface{}) error }) (SavedAddress, error) {
+	var a SavedAddress
+	err := row.Scan(&a.ID, &a.SenderID, &a.Label, &a.ContactName, &a.Phone, &a.Address, &a.Lat, &a.Lon,
+		&a.Entrance, &a.Apt, &a.Floor, &a.Intercom, &a.Comment, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+// ListAddresses returns the sender's address book ordered by label.
+func (r *AddressBookRepo) ListAddresses(ctx context.Context, senderID int64) ([]SavedAddress, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+savedAddressColumns+` FROM courier_sender_addresses WHERE sender_id = ? ORDER BY label, id`, senderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []SavedAddress
+	for rows.Next() {
+		a, err := scanSavedAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, a)
+	}
+	return items, rows.Err()
+}
+
+// AddressesByID returns the sender's saved addresses among ids; foreign and unknown ids are left out.
+func (r *AddressBookRepo) AddressesByID(ctx context.Context, senderID int64, ids []int64) (map[int64]SavedAddress, error) {
+	found := make(map[int64]SavedAddress, len(ids))
+	if len(ids) == 0 {
+		return found, nil
+	}
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, senderID)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`SELECT `+savedAddressColumns+` FROM courier_sender_addresses WHERE sender_id = ? AND id IN (%s)`, placeholders(len(ids))), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a, err := scanSavedAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		found[a.ID] = a
+	}
+	return found, rows.Err()
+}
+
+// CreateAddress saves a new address of the sender.
+func (r *AddressBookRepo) CreateAddress(ctx context.Context, a SavedAddress) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO courier_sender_addresses (sender_id, label, contact_name, phone, address, lat, lon, entrance, apt, floor, intercom, comment)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.SenderID, a.Label, a.ContactName, a.Phone, a.Address, a.Lat, a.Lon, a.Entrance, a.Apt, a.Floor, a.Intercom, a.Comment)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateAddress replaces the saved address of the sender.
+func (r *AddressBookRepo) UpdateAddress(ctx context.Context, a SavedAddress) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE courier_sender_addresses SET label = ?, contact_name = ?, phone = ?, address = ?, lat = ?, lon = ?,
+        entrance = ?, apt = ?, floor = ?, intercom = ?, comment = ? WHERE id = ? AND sender_id = ?`,
+		a.Label, a.ContactName, a.Phone, a.Address, a.Lat, a.Lon, a.Entrance, a.Apt, a.Floor, a.Intercom, a.Comment, a.ID, a.SenderID)
+	return r.expectUpdated(ctx, res, err, `SELECT COUNT(*) FROM courier_sender_addresses WHERE id = ? AND sender_id = ?`, a.ID, a.SenderID)
+}
+
+// DeleteAddress removes the saved address of the sender.
+func (r *AddressBookRepo) DeleteAddress(ctx context.Context, senderID, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM courier_sender_addresses WHERE id = ? AND sender_id = ?`, id, senderID)
+	return expectAffected(res, err)
+}
+
+// ListTemplates returns the sender's order templates ordered by name.
+func (r *AddressBookRepo) ListTemplates(ctx context.Context, senderID int64) ([]OrderTemplate, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, sender_id, name, request, created_at, updated_at FROM courier_order_templates WHERE sender_id = ? ORDER BY name, id`, senderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []OrderTemplate
+	for rows.Next() {
+		var t OrderTemplate
+		if err := rows.Scan(&t.ID, &t.SenderID, &t.Name, &t.Request, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, t)
+	}
+	return items, rows.Err()
+}
+
+// GetTemplate loads the sender's template.
+func (r *AddressBookRepo) GetTemplate(ctx context.Context, senderID, id int64) (OrderTemplate, error) {
+	var t OrderTemplate
+	err := r.db.QueryRowContext(ctx, `SELECT id, sender_id, name, request, created_at, updated_at FROM courier_order_templates WHERE id = ? AND sender_id = ?`, id, senderID).
+		Scan(&t.ID, &t.SenderID, &t.Name, &t.Request, &t.CreatedAt, &t.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return OrderTemplate{}, ErrNotFound
+	}
+	return t, err
+}
+
+// CreateTemplate saves a new order template.
+func (r *AddressBookRepo) CreateTemplate(ctx context.Context, t OrderTemplate) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO courier_order_templates (sender_id, name, request) VALUES (?, ?, ?)`, t.SenderID, t.Name, t.Request)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateTemplate replaces the template; recurring deliveries pick the change up from their next order.
+func (r *AddressBookRepo) UpdateTemplate(ctx context.Context, t OrderTemplate) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE courier_order_templates SET name = ?, request = ? WHERE id = ? AND sender_id = ?`, t.Name, t.Request, t.ID, t.SenderID)
+	return r.expectUpdated(ctx, res, err, `SELECT COUNT(*) FROM courier_order_templates WHERE id = ? AND sender_id = ?`, t.ID, t.SenderID)
+}
+
+// DeleteTemplate removes the template unless recurring deliveries use it.
+func (r *AddressBookRepo) DeleteTemplate(ctx context.Context, senderID, id int64) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var used int
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM courier_recurring_deliveries WHERE template_id = ? FOR UPDATE`, id).Scan(&used); err != nil {
+		return err
+	}
+	if used > 0 {
+		err = ErrTemplateInUse
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM courier_order_templates WHERE id = ? AND sender_id = ?`, id, senderID)
+	if err = expectAffected(res, err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const recurringColumns = `id, sender_id, template_id, frequency, weekdays, pickup_minute, window_minutes, status, next_run_at,
+        last_order_id, last_run_at, last_error, created_at, updated_at`
+
+func scanRecurring(row interface{ Scan(...interface{}) error }) (RecurringDelivery, error) {
+	var d RecurringDelivery
+	err := row.Scan(&d.ID, &d.SenderID, &d.TemplateID, &d.Frequency, &d.Weekdays, &d.PickupMinute, &d.WindowMinutes, &d.Status, &d.NextRunAt,
+		&d.LastOrderID, &d.LastRunAt, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+	return d, err
+}
+
+func (r *AddressBookRepo) queryRecurring(ctx context.Context, query string, args ...interface{}) ([]RecurringDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+recurringColumns+` FROM courier_recurring_deliveries `+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []RecurringDelivery
+	for rows.Next() {
+		d, err := scanRecurring(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, d)
+	}
+	return items, rows.Err()
+}
+
+// ListRecurring returns the sender's recurring deliveries.
+func (r *AddressBookRepo) ListRecurring(ctx context.Context, senderID int64) ([]RecurringDelivery, error) {
+	return r.queryRecurring(ctx, `WHERE sender_id = ? ORDER BY id`, senderID)
+}
+
+// GetRecurring loads the sender's recurring delivery.
+func (r *AddressBookRepo) GetRecurring(ctx context.Context, senderID, id int64) (RecurringDelivery, error) {
+	d, err := scanRecurring(r.db.QueryRowContext(ctx, `SELECT `+recurringColumns+` FROM courier_recurring_deliveries WHERE id = ? AND sender_id = ?`, id, senderID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return RecurringDelivery{}, ErrNotFound
+	}
+	return d, err
+}
+
+// CreateRecurring saves a recurring delivery of the sender's template.
+func (r *AddressBookRepo) CreateRecurring(ctx context.Context, d RecurringDelivery) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO courier_recurring_deliveries (sender_id, template_id, frequency, weekdays, pickup_minute, window_minutes, status, next_run_at)
+        SELECT ?, id, ?, ?, ?, ?, ?, ? FROM courier_order_templates WHERE id = ? AND sender_id = ?`,
+		d.SenderID, d.Frequency, d.Weekdays, d.PickupMinute, d.WindowMinutes, d.Status, d.NextRunAt, d.TemplateID, d.SenderID)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrNotFound
+	}
+	return res.LastInsertId()
+}
+
+// UpdateRecurring replaces the schedule, template, status and next pickup of the recurring delivery.
+func (r *AddressBookRepo) UpdateRecurring(ctx context.Context, d RecurringDelivery) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE courier_recurring_deliveries rd
+        JOIN courier_order_templates t ON t.id = ? AND t.sender_id = rd.sender_id
+        SET rd.template_id = t.id, rd.frequency = ?, rd.weekdays = ?, rd.pickup_minute = ?, rd.window_minutes = ?, rd.status = ?, rd.next_run_at = ?
+        WHERE rd.id = ? AND rd.sender_id = ?`,
+		d.TemplateID, d.Frequency, d.Weekdays, d.PickupMinute, d.WindowMinutes, d.Status, d.NextRunAt, d.ID, d.SenderID)
+	return r.expectUpdated(ctx, res, err, `SELECT COUNT(*) FROM courier_recurring_deliveries rd
+        JOIN courier_order_templates t ON t.id = ? AND t.sender_id = rd.sender_id WHERE rd.id = ? AND rd.sender_id = ?`, d.TemplateID, d.ID, d.SenderID)
+}
+
+// DeleteRecurring stops and removes the recurring delivery; orders already placed stay.
+func (r *AddressBookRepo) DeleteRecurring(ctx context.Context, senderID, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM courier_recurring_deliveries WHERE id = ? AND sender_id = ?`, id, senderID)
+	return expectAffected(res, err)
+}
+
+// DueRecurring returns active recurring deliveries whose next pickup is not later than until.
+func (r *AddressBookRepo) DueRecurring(ctx context.Context, until time.Time, limit int) ([]RecurringDelivery, error) {
+	return r.queryRecurring(ctx, `WHERE status = 'active' AND next_run_at <= ? ORDER BY next_run_at LIMIT ?`, until, limit)
+}
+
+// ClaimRecurringRun moves the delivery from the pickup at occurrence to next. Only one worker wins
+// the pickup; the others get false.
+func (r *AddressBookRepo) ClaimRecurringRun(ctx context.Context, id int64, occurrence, next, now time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE courier_recurring_deliveries SET next_run_at = ?, last_run_at = ?
+        WHERE id = ? AND status = 'active' AND next_run_at = ?`, next, now, id, occurrence)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RecordRecurringResult stores the order placed for the last pickup or the reason it was not placed.
+func (r *AddressBookRepo) RecordRecurringResult(ctx context.Context, id int64, orderID sql.NullInt64, reason sql.NullString) error {
+	if len(reason.String) > 255 {
+		reason.String = reason.String[:255]
+	}
+	_, err := r.db.ExecContext(ctx, `UPDATE courier_recurring_deliveries SET last_order_id = COALESCE(?, last_order_id), last_error = ? WHERE id = ?`,
+		orderID, reason, id)
+	return err
+}
+
+func expectAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// expectUpdated is expectAffected for updates: MySQL does not count rows left unchanged, so those
+// are looked up with the exists query before reporting ErrNotFound.
+func (r *AddressBookRepo) expectUpdated(ctx context.Context, res sql.Result, err error, exists string, args ...interface{}) error {
+	if err = expectAffected(res, err); !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	var n int
+	if err := r.db.QueryRowContext(ctx, exists, args...).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
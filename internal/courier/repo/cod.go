@@ -0,0 +1,440 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"naimuBack/internal/taxi/timeutil"
+)
+
+// Ledger entry kinds.
+const (
+	LedgerKindCODCollected       = "cod_collected"
+	LedgerKindCODHandIn          = "cod_handin"
+	LedgerKindCODPayoutDeduction = "cod_payout_deduction"
+	LedgerKindInsuranceCharge    = "insurance_charge"
+)
+
+// Hand-in statuses.
+const (
+	HandInStatusPending   = "pending"
+	HandInStatusConfirmed = "confirmed"
+	HandInStatusRejected  = "rejected"
+)
+
+var (
+	// ErrNoCOD is returned when the order has no cash-on-delivery amount.
+	ErrNoCOD = errors.New("courier: order has no cash on delivery")
+	// ErrCODAlreadyCollected is returned on a repeated collection confirmation.
+	ErrCODAlreadyCollected = errors.New("courier: cash on delivery already confirmed")
+	// ErrHandInExceedsDebt is returned when the courier hands in more than owed.
+	ErrHandInExceedsDebt = errors.New("courier: hand-in exceeds cod debt")
+	// ErrHandInReviewed is returned when the hand-in was already confirmed or rejected.
+	ErrHandInReviewed = errors.New("courier: hand-in already reviewed")
+	// ErrNothingToSettle is returned when there is no debt or no balance to deduct from.
+	ErrNothingToSettle = errors.New("courier: nothing to settle")
+)
+
+// COD — наложенный платёж: сколько взять с получателя и сколько курьер подтвердил.
+type COD struct {
+	Amount      int
+	Collected   sql.NullInt64
+	CollectedAt sql.NullTime
+}
+
+// CODAccount is the courier's balance together with cash owed to the service.
+type CODAccount struct {
+	Balance        int
+	Debt           int
+	PendingHandIns int
+}
+
+// LedgerEntry — движение по балансу или долгу курьера.
+type LedgerEntry struct {
+	ID           int64
+	CourierID    int64
+	OrderID      sql.NullInt64
+	HandInID     sql.NullInt64
+	Kind         string
+	BalanceDelta int
+	DebtDelta    int
+	BalanceAfter int
+	DebtAfter    int
+	CreatedAt    time.Time
+}
+
+// CODHandIn is cash brought by the courier to the office, confirmed by an admin.
+type CODHandIn struct {
+	ID         int64
+	CourierID  int64
+	Amount     int
+	Status     string
+	Comment    sql.NullString
+	ReviewedAt sql.NullTime
+	CreatedAt  time.Time
+}
+
+// CODReportRow is one cash-on-delivery order in the sender report.
+type CODReportRow struct {
+	OrderID     int64
+	Status      string
+	CourierID   sql.NullInt64
+	Amount      int
+	Collected   sql.NullInt64
+	CollectedAt sql.NullTime
+	CreatedAt   time.Time
+}
+
+func lockCourierAccount(ctx context.Context, tx *sql.Tx, courierID int64) (balance, debt int, err error) {
+	err = tx.QueryRowContext(ctx, `SELECT balance, cod_debt FROM couriers WHERE id = ? FOR UPDATE`, courierID).Scan(&balance, &debt)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	return balance, debt, err
+}
+
+// applyLedgerEntry changes balance and debt of a locked courier row and records the movement.
+func applyLedgerEntry(ctx context.Context, tx *sql.Tx, e *LedgerEntry) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE couriers SET balance = ?, cod_debt = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		e.BalanceAfter, e.DebtAfter, e.CourierID); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO courier_balance_ledger (courier_id, order_id, handin_id, kind, balance_delta, debt_delta, balance_after, debt_after)
+        VALUES (?,?,?,?,?,?,?,?)`,
+		e.CourierID, e.OrderID, e.HandInID, e.Kind, e.BalanceDelta, e.DebtDelta, e.BalanceAfter, e.DebtAfter)
+	if err != nil {
+		return err
+	}
+	e.ID, err = res.LastInsertId()
+	return err
+}
+
+// codCollectedEntry adds cash taken from the recipient to the courier's debt.
+func codCollectedEntry(courierID, orderID int64, amount, balance, debt int) LedgerEntry {
+	return LedgerEntry{
+		CourierID:    courierID,
+		OrderID:      sql.NullInt64{Int64: orderID, Valid: true},
+		Kind:         LedgerKindCODCollected,
+		DebtDelta:    amount,
+		BalanceAfter: balance,
+		DebtAfter:    debt + amount,
+	}
+}
+
+// codHandInEntry reduces the debt by cash the courier brought to the office.
+func codHandInEntry(h CODHandIn, balance, debt int) (LedgerEntry, error) {
+	if h.Amount > debt {
+		return LedgerEntry{}, ErrHandInExceedsDebt
+	}
+	return LedgerEntry{
+		CourierID:    h.CourierID,
+		HandInID:     sql.NullInt64{Int64: h.ID, Valid: true},
+		Kind:         LedgerKindCODHandIn,
+		DebtDelta:    -h.Amount,
+		BalanceAfter: balance,
+		DebtAfter:    debt - h.Amount,
+	}, nil
+}
+
+// codDeductionEntry settles the debt from the balance. Amount <= 0 takes as much as both allow.
+func codDeductionEntry(courierID int64, amount, balance, debt int) (LedgerEntry, error) {
+	if amount <= 0 {
+		amount = debt
+		if balance < amount {
+			amount = balance
+		}
+	}
+	if amount <= 0 || amount > debt {
+		return LedgerEntry{}, ErrNothingToSettle
+	}
+	if amount > balance {
+		return LedgerEntry{}, ErrInsufficientBalance
+	}
+	return LedgerEntry{
+		CourierID:    courierID,
+		Kind:         LedgerKindCODPayoutDeduction,
+		BalanceDelta: -amount,
+		DebtDelta:    -amount,
+		BalanceAfter: balance - amount,
+		DebtAfter:    debt - amount,
+	}, nil
+}
+
+// ConfirmCODCollected records how much cash the courier took from the recipient and adds it
+// to the courier's debt.
+func (r *OrdersRepo) ConfirmCODCollected(ctx context.Context, orderID, courierID int64, amount int, at time.Time) (cod COD, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return COD{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var assigned sql.NullInt64
+	err = tx.QueryRowContext(ctx, `SELECT courier_id, cod_amount, cod_collected FROM courier_orders WHERE id = ? FOR UPDATE`, orderID).
+		Scan(&assigned, &cod.Amount, &cod.Collected)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return COD{}, err
+	}
+	if !assigned.Valid || assigned.Int64 != courierID {
+		err = ErrNotFound
+		return COD{}, err
+	}
+	if cod.Amount <= 0 {
+		err = ErrNoCOD
+		return COD{}, err
+	}
+	if cod.Collected.Valid {
+		err = ErrCODAlreadyCollected
+		return COD{}, err
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE courier_orders SET cod_collected = ?, cod_collected_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		amount, at, orderID); err != nil {
+		return COD{}, err
+	}
+	if amount > 0 {
+		balance, debt, lockErr := lockCourierAccount(ctx, tx, courierID)
+		if lockErr != nil {
+			err = lockErr
+			return COD{}, err
+		}
+		entry := codCollectedEntry(courierID, orderID, amount, balance, debt)
+		if err = applyLedgerEntry(ctx, tx, &entry); err != nil {
+			return COD{}, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return COD{}, err
+	}
+	cod.Collected = sql.NullInt64{Int64: int64(amount), Valid: true}
+	cod.CollectedAt = sql.NullTime{Time: at, Valid: true}
+	return cod, nil
+}
+
+// SenderCODReport lists cash-on-delivery orders of the sender created within [from, to).
+func (r *OrdersRepo) SenderCODReport(ctx context.Context, senderID int64, from, to time.Time) ([]CODReportRow, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, status, courier_id, cod_amount, cod_collected, cod_collected_at, created_at
+        FROM courier_orders
+        WHERE sender_id = ? AND cod_amount > 0 AND created_at >= ? AND created_at < ?
+        ORDER BY created_at DESC`, senderID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]CODReportRow, 0)
+	for rows.Next() {
+		var row CODReportRow
+		if err := rows.Scan(&row.OrderID, &row.Status, &row.CourierID, &row.Amount, &row.Collected, &row.CollectedAt, &row.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, row)
+	}
+	return items, rows.Err()
+}
+
+// CODAccount returns the courier's balance, cash debt and the sum of pending hand-ins.
+func (r *CouriersRepo) CODAccount(ctx context.Context, courierID int64) (CODAccount, error) {
+	var acc CODAccount
+	err := r.db.QueryRowContext(ctx, `SELECT c.balance, c.cod_debt,
+            COALESCE((SELECT SUM(h.amount) FROM courier_cod_handins h WHERE h.courier_id = c.id AND h.status = 'pending'), 0)
+        FROM couriers c WHERE c.id = ?`, courierID).Scan(&acc.Balance, &acc.Debt, &acc.PendingHandIns)
+	if errors.Is(err, sql.ErrNoRows) {
+		return CODAccount{}, ErrNotFound
+	}
+	return acc, err
+}
+
+// ListLedger returns balance and debt movements of the courier, newest first.
+func (r *CouriersRepo) ListLedger(ctx context.Context, courierID int64, limit, offset int) ([]LedgerEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, courier_id, order_id, handin_id, kind, balance_delta, debt_delta, balance_after, debt_after, created_at
+        FROM courier_balance_ledger WHERE courier_id = ? ORDER BY id DESC LIMIT ? OFFSET ?`, courierID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]LedgerEntry, 0)
+	for rows.Next() {
+		var e LedgerEntry
+		if err := rows.Scan(&e.ID, &e.CourierID, &e.OrderID, &e.HandInID, &e.Kind, &e.BalanceDelta, &e.DebtDelta, &e.BalanceAfter, &e.DebtAfter, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+	}
+	return items, rows.Err()
+}
+
+const handInColumns = `id, courier_id, amount, status, comment, reviewed_at, created_at`
+
+func scanHandIn(row interface{ Scan(...interface{}) error }) (CODHandIn, error) {
+	var h CODHandIn
+	err := row.Scan(&h.ID, &h.CourierID, &h.Amount, &h.Status, &h.Comment, &h.ReviewedAt, &h.CreatedAt)
+	return h, err
+}
+
+// CreateCODHandIn registers cash the courier is handing in; the debt changes only after
+// an admin confirms it.
+func (r *CouriersRepo) CreateCODHandIn(ctx context.Context, courierID int64, amount int, comment sql.NullString) (h CODHandIn, err error) {
+	if amount <= 0 {
+		return CODHandIn{}, errors.New("amount must be positive")
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return CODHandIn{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, debt, err := lockCourierAccount(ctx, tx, courierID)
+	if err != nil {
+		return CODHandIn{}, err
+	}
+	var pending int
+	if err = tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount), 0) FROM courier_cod_handins WHERE courier_id = ? AND status = 'pending'`, courierID).Scan(&pending); err != nil {
+		return CODHandIn{}, err
+	}
+	if pending+amount > debt {
+		err = ErrHandInExceedsDebt
+		return CODHandIn{}, err
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO courier_cod_handins (courier_id, amount, comment) VALUES (?,?,?)`, courierID, amount, comment)
+	if err != nil {
+		return CODHandIn{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return CODHandIn{}, err
+	}
+	if h, err = scanHandIn(tx.QueryRowContext(ctx, `SELECT `+handInColumns+` FROM courier_cod_handins WHERE id = ?`, id)); err != nil {
+		return CODHandIn{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return CODHandIn{}, err
+	}
+	return h, nil
+}
+
+// ListCODHandIns returns hand-ins, newest first. Zero courierID or empty status means any.
+func (r *CouriersRepo) ListCODHandIns(ctx context.Context, courierID int64, status string, limit, offset int) ([]CODHandIn, error) {
+	query := `SELECT ` + handInColumns + ` FROM courier_cod_handins WHERE 1=1`
+	args := make([]interface{}, 0, 4)
+	if courierID > 0 {
+		query += ` AND courier_id = ?`
+		args = append(args, courierID)
+	}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]CODHandIn, 0)
+	for rows.Next() {
+		h, err := scanHandIn(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, h)
+	}
+	return items, rows.Err()
+}
+
+// ReviewCODHandIn confirms or rejects a pending hand-in. Confirmation reduces the debt.
+func (r *CouriersRepo) ReviewCODHandIn(ctx context.Context, handInID int64, confirm bool, at time.Time) (h CODHandIn, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return CODHandIn{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	h, err = scanHandIn(tx.QueryRowContext(ctx, `SELECT `+handInColumns+` FROM courier_cod_handins WHERE id = ? FOR UPDATE`, handInID))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return CODHandIn{}, err
+	}
+	if h.Status != HandInStatusPending {
+		err = ErrHandInReviewed
+		return CODHandIn{}, err
+	}
+
+	h.Status = HandInStatusRejected
+	if confirm {
+		h.Status = HandInStatusConfirmed
+		balance, debt, lockErr := lockCourierAccount(ctx, tx, h.CourierID)
+		if lockErr != nil {
+			err = lockErr
+			return CODHandIn{}, err
+		}
+		var entry LedgerEntry
+		if entry, err = codHandInEntry(h, balance, debt); err != nil {
+			return CODHandIn{}, err
+		}
+		if err = applyLedgerEntry(ctx, tx, &entry); err != nil {
+			return CODHandIn{}, err
+		}
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE courier_cod_handins SET status = ?, reviewed_at = ? WHERE id = ?`, h.Status, at, h.ID); err != nil {
+		return CODHandIn{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return CODHandIn{}, err
+	}
+	h.ReviewedAt = sql.NullTime{Time: at, Valid: true}
+	return h, nil
+}
+
+// DeductCODFromBalance settles the debt from the courier's earnings. Amount <= 0 takes as much
+// as both the balance and the debt allow.
+func (r *CouriersRepo) DeductCODFromBalance(ctx context.Context, courierID int64, amount int) (entry LedgerEntry, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return LedgerEntry{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	balance, debt, err := lockCourierAccount(ctx, tx, courierID)
+	if err != nil {
+		return LedgerEntry{}, err
+	}
+	if entry, err = codDeductionEntry(courierID, amount, balance, debt); err != nil {
+		return LedgerEntry{}, err
+	}
+	if err = applyLedgerEntry(ctx, tx, &entry); err != nil {
+		return LedgerEntry{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return LedgerEntry{}, err
+	}
+	entry.CreatedAt = timeutil.Now()
+	return entry, nil
+}
@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCODLedgerSequence(t *testing.T) {
+	// курьер заработал 3000, взял 5000 наложенным платежом, сдал 2000 в офис, остаток удержан из баланса
+	balance, debt := 3000, 0
+
+	collected := codCollectedEntry(7, 11, 5000, balance, debt)
+	if collected.DebtDelta != 5000 || collected.BalanceDelta != 0 || collected.DebtAfter != 5000 || collected.BalanceAfter != 3000 {
+		t.Fatalf("unexpected collect entry %+v", collected)
+	}
+	if !collected.OrderID.Valid || collected.OrderID.Int64 != 11 || collected.Kind != LedgerKindCODCollected {
+		t.Fatalf("unexpected collect entry %+v", collected)
+	}
+	balance, debt = collected.BalanceAfter, collected.DebtAfter
+
+	handIn, err := codHandInEntry(CODHandIn{ID: 3, CourierID: 7, Amount: 2000}, balance, debt)
+	if err != nil {
+		t.Fatalf("hand-in: %v", err)
+	}
+	if handIn.DebtDelta != -2000 || handIn.BalanceDelta != 0 || handIn.DebtAfter != 3000 || handIn.BalanceAfter != 3000 {
+		t.Fatalf("unexpected hand-in entry %+v", handIn)
+	}
+	if !handIn.HandInID.Valid || handIn.HandInID.Int64 != 3 || handIn.Kind != LedgerKindCODHandIn {
+		t.Fatalf("unexpected hand-in entry %+v", handIn)
+	}
+	balance, debt = handIn.BalanceAfter, handIn.DebtAfter
+
+	deduction, err := codDeductionEntry(7, 0, balance, debt)
+	if err != nil {
+		t.Fatalf("deduction: %v", err)
+	}
+	if deduction.BalanceDelta != -3000 || deduction.DebtDelta != -3000 || deduction.BalanceAfter != 0 || deduction.DebtAfter != 0 {
+		t.Fatalf("unexpected deduction entry %+v", deduction)
+	}
+	if deduction.Kind != LedgerKindCODPayoutDeduction {
+		t.Fatalf("unexpected deduction kind %q", deduction.Kind)
+	}
+}
+
+func TestCODHandInEntryExceedsDebt(t *testing.T) {
+	if _, err := codHandInEntry(CODHandIn{Amount: 1001}, 0, 1000); !errors.Is(err, ErrHandInExceedsDebt) {
+		t.Fatalf("expected ErrHandInExceedsDebt got %v", err)
+	}
+}
+
+func TestCODDeductionEntry(t *testing.T) {
+	cases := []struct {
+		name          string
+		amount        int
+		balance, debt int
+		wantAmount    int
+		wantErr       error
+	}{
+		{"full debt", 0, 5000, 2000, 2000, nil},
+		{"limited by balance", 0, 1500, 2000, 1500, nil},
+		{"explicit amount", 500, 5000, 2000, 500, nil},
+		{"no debt", 0, 5000, 0, 0, ErrNothingToSettle},
+		{"no balance", 0, 0, 2000, 0, ErrNothingToSettle},
+		{"negative balance", 0, -300, 2000, 0, ErrNothingToSettle},
+		{"more than debt", 2500, 5000, 2000, 0, ErrNothingToSettle},
+		{"more than balance", 1500, 1000, 2000, 0, ErrInsufficientBalance},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry, err := codDeductionEntry(7, tc.amount, tc.balance, tc.debt)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error %v got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if entry.BalanceDelta != -tc.wantAmount || entry.DebtDelta != -tc.wantAmount {
+				t.Fatalf("expected %d got %+v", tc.wantAmount, entry)
+			}
+			if entry.BalanceAfter != tc.balance-tc.wantAmount || entry.DebtAfter != tc.debt-tc.wantAmount {
+				t.Fatalf("unexpected totals %+v", entry)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -18,6 +19,11 @@ const (
 	CourierApprovalPending  = "pending"
 	CourierApprovalApproved = "approved"
 	CourierApprovalRejected = "rejected"
+
+	// Уровень проверки курьера ограничивает объявленную ценность заказов, которые он может везти.
+	CourierVerificationBasic    = "basic"
+	CourierVerificationVerified = "verified"
+	CourierVerificationTrusted  = "trusted"
 )
 
 // Courier represents a courier profile record.
@@ -33,13 +39,16 @@ type Courier struct {
 	IDCardFront    string
 	IDCardBack     string
 	Phone          string
+	VehicleType    string
 	Rating         sql.NullFloat64
 	Balance        int
 	Status         string
 	ApprovalStatus string
-	IsBanned       bool
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	// VerificationLevel is one of CourierVerification*.
+	VerificationLevel string
+	IsBanned          bool
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
 }
 
 // CouriersStats aggregates counters for couriers grouped by status.
@@ -62,10 +71,15 @@ func NewCouriersRepo(db *sql.DB) *CouriersRepo {
 
 // Upsert creates or updates courier profile by user identifier.
 func (r *CouriersRepo) Upsert(ctx context.Context, c Courier) (int64, error) {
+	// Пустой тип транспорта при обновлении оставляет прежний.
+	var vehicle interface{}
+	if c.VehicleType != "" {
+		vehicle = c.VehicleType
+	}
 	res, err := r.db.ExecContext(ctx, `INSERT INTO couriers (
         user_id, first_name, last_name, middle_name, courier_photo, iin, date_of_birth,
-        id_card_front, id_card_back, phone, rating, status
-    ) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)
+        id_card_front, id_card_back, phone, vehicle_type, rating, status
+    ) VALUES (?,?,?,?,?,?,?,?,?,?,COALESCE(?, 'foot'),?,?)
     ON DUPLICATE KEY UPDATE
         first_name = VALUES(first_name),
         last_name = VALUES(last_name),
@@ -76,12 +90,13 @@ func (r *CouriersRepo) Upsert(ctx context.Context, c Courier) (int64, error) {
         id_card_front = VALUES(id_card_front),
         id_card_back = VALUES(id_card_back),
         phone = VALUES(phone),
+        vehicle_type = COALESCE(?, vehicle_type),
         rating = VALUES(rating),
         status = VALUES(status),
         updated_at = CURRENT_TIMESTAMP,
         id = LAST_INSERT_ID(id)`,
 		c.UserID, c.FirstName, c.LastName, nullOrString(c.MiddleName), c.Photo, c.IIN, c.BirthDate,
-		c.IDCardFront, c.IDCardBack, c.Phone, nullFloat64(c.Rating), c.Status,
+		c.IDCardFront, c.IDCardBack, c.Phone, vehicle, nullFloat64(c.Rating), c.Status, vehicle,
 	)
 	if err != nil {
 		return 0, err
@@ -97,12 +112,12 @@ func (r *CouriersRepo) Upsert(ctx context.Context, c Courier) (int64, error) {
 func (r *CouriersRepo) Get(ctx context.Context, id int64) (Courier, error) {
 	var c Courier
 	row := r.db.QueryRowContext(ctx, `SELECT id, user_id, first_name, last_name, middle_name,
-        courier_photo, iin, date_of_birth, id_card_front, id_card_back, phone, rating, balance,
-        status, approval_status, is_banned, created_at, updated_at
+        courier_photo, iin, date_of_birth, id_card_front, id_card_back, phone, vehicle_type, rating, balance,
+        status, approval_status, verification_level, is_banned, created_at, updated_at
         FROM couriers WHERE id = ?`, id)
 	err := row.Scan(&c.ID, &c.UserID, &c.FirstName, &c.LastName, &c.MiddleName,
-		&c.Photo, &c.IIN, &c.BirthDate, &c.IDCardFront, &c.IDCardBack, &c.Phone, &c.Rating, &c.Balance,
-		&c.Status, &c.ApprovalStatus, &c.IsBanned, &c.CreatedAt, &c.UpdatedAt)
+		&c.Photo, &c.IIN, &c.BirthDate, &c.IDCardFront, &c.IDCardBack, &c.Phone, &c.VehicleType, &c.Rating, &c.Balance,
+		&c.Status, &c.ApprovalStatus, &c.VerificationLevel, &c.IsBanned, &c.CreatedAt, &c.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Courier{}, ErrNotFound
@@ -115,8 +130,8 @@ func (r *CouriersRepo) Get(ctx context.Context, id int64) (Courier, error) {
 // List returns couriers with pagination.
 func (r *CouriersRepo) List(ctx context.Context, limit, offset int) ([]Courier, error) {
 	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, first_name, last_name, middle_name,
-        courier_photo, iin, date_of_birth, id_card_front, id_card_back, phone, rating, balance,
-        status, approval_status, is_banned, created_at, updated_at
+        courier_photo, iin, date_of_birth, id_card_front, id_card_back, phone, vehicle_type, rating, balance,
+        status, approval_status, verification_level, is_banned, created_at, updated_at
         FROM couriers ORDER BY id DESC LIMIT ? OFFSET ?`, limit, offset)
 	if err != nil {
 		return nil, err
@@ -127,8 +142,8 @@ func (r *CouriersRepo) List(ctx context.Context, limit, offset int) ([]Courier,
 	for rows.Next() {
 		var c Courier
 		if err := rows.Scan(&c.ID, &c.UserID, &c.FirstName, &c.LastName, &c.MiddleName,
-			&c.Photo, &c.IIN, &c.BirthDate, &c.IDCardFront, &c.IDCardBack, &c.Phone, &c.Rating, &c.Balance,
-			&c.Status, &c.ApprovalStatus, &c.IsBanned, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			&c.Photo, &c.IIN, &c.BirthDate, &c.IDCardFront, &c.IDCardBack, &c.Phone, &c.VehicleType, &c.Rating, &c.Balance,
+			&c.Status, &c.ApprovalStatus, &c.VerificationLevel, &c.IsBanned, &c.CreatedAt, &c.UpdatedAt); err != nil {
 			return nil, err
 		}
 		couriers = append(couriers, c)
@@ -139,6 +154,62 @@ func (r *CouriersRepo) List(ctx context.Context, limit, offset int) ([]Courier,
 	return couriers, nil
 }
 
+// VehicleTypes returns the vehicle type of each known courier from ids.
+func (r *CouriersRepo) VehicleTypes(ctx context.Context, ids []int64) (map[int64]string, error) {
+	types := make(map[int64]string, len(ids))
+	if len(ids) == 0 {
+		return types, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`SELECT id, vehicle_type FROM couriers WHERE id IN (%s)`, placeholders(len(ids))), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id      int64
+			vehicle string
+		)
+		if err := rows.Scan(&id, &vehicle); err != nil {
+			return nil, err
+		}
+		types[id] = vehicle
+	}
+	return types, rows.Err()
+}
+
+// VerificationLevels returns the verification level of each known courier from ids.
+func (r *CouriersRepo) VerificationLevels(ctx context.Context, ids []int64) (map[int64]string, error) {
+	levels := make(map[int64]string, len(ids))
+	if len(ids) == 0 {
+		return levels, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`SELECT id, verification_level FROM couriers WHERE id IN (%s)`, placeholders(len(ids))), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id    int64
+			level string
+		)
+		if err := rows.Scan(&id, &level); err != nil {
+			return nil, err
+		}
+		levels[id] = level
+	}
+	return levels, rows.Err()
+}
+
 // Stats aggregates courier counts by status.
 func (r *CouriersRepo) Stats(ctx context.Context) (CouriersStats, error) {
 	row := r.db.QueryRowContext(ctx, `
@@ -188,6 +259,22 @@ func (r *CouriersRepo) UpdateApprovalStatus(ctx context.Context, id int64, appro
 	return nil
 }
 
+// UpdateVerificationLevel changes courier verification level.
+func (r *CouriersRepo) UpdateVerificationLevel(ctx context.Context, id int64, level string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE couriers SET verification_level = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, level, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // UpdateBan toggles courier ban flag.
 func (r *CouriersRepo) UpdateBan(ctx context.Context, id int64, banned bool) error {
 	var value int
@@ -224,6 +311,15 @@ func (r *CouriersRepo) WithdrawBalance(ctx context.Context, courierID int64, amo
 	return r.adjustBalance(ctx, courierID, -amount, false)
 }
 
+// WithdrawBalanceTx decreases the courier's balance within tx, never below zero.
+func WithdrawBalanceTx(ctx context.Context, tx *sql.Tx, courierID int64, amount int) error {
+	if amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	_, err := adjustBalanceTx(ctx, tx, courierID, -amount, false)
+	return err
+}
+
 func (r *CouriersRepo) adjustBalance(ctx context.Context, courierID int64, delta int, allowNegative bool) (int, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -235,8 +331,19 @@ func (r *CouriersRepo) adjustBalance(ctx context.Context, courierID int64, delta
 		}
 	}()
 
+	newBalance, err := adjustBalanceTx(ctx, tx, courierID, delta, allowNegative)
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return newBalance, nil
+}
+
+func adjustBalanceTx(ctx context.Context, tx *sql.Tx, courierID int64, delta int, allowNegative bool) (int, error) {
 	var balance int
-	if err = tx.QueryRowContext(ctx, `SELECT balance FROM couriers WHERE id = ? FOR UPDATE`, courierID).Scan(&balance); err != nil {
+	if err := tx.QueryRowContext(ctx, `SELECT balance FROM couriers WHERE id = ? FOR UPDATE`, courierID).Scan(&balance); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrNotFound
 		}
@@ -246,10 +353,7 @@ func (r *CouriersRepo) adjustBalance(ctx context.Context, courierID int64, delta
 	if !allowNegative && newBalance < 0 {
 		return 0, ErrInsufficientBalance
 	}
-	if _, err = tx.ExecContext(ctx, `UPDATE couriers SET balance = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, newBalance, courierID); err != nil {
-		return 0, err
-	}
-	if err = tx.Commit(); err != nil {
+	if _, err := tx.ExecContext(ctx, `UPDATE couriers SET balance = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, newBalance, courierID); err != nil {
 		return 0, err
 	}
 	return newBalance, nil
@@ -1,6 +1,16 @@
 package repo
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
 
 // ErrNotFound indicates missing entities in the courier repositories.
 var ErrNotFound = errors.New("courier: not found")
+
+// isDuplicateKey reports a unique key violation.
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
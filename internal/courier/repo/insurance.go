@@ -0,0 +1,304 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Claim kinds, statuses and payout states.
+const (
+	ClaimKindDamage = "damage"
+	ClaimKindLoss   = "loss"
+
+	ClaimOpen     = "open"
+	ClaimApproved = "approved"
+	ClaimRejected = "rejected"
+
+	ClaimPayoutNone    = "none"
+	ClaimPayoutPending = "pending"
+	ClaimPayoutPaid    = "paid"
+)
+
+var (
+	// ErrOrderNotInsured indicates that the order has no declared value.
+	ErrOrderNotInsured = errors.New("courier: order is not insured")
+	// ErrClaimNotAllowed indicates that the order is not finished or belongs to another sender.
+	ErrClaimNotAllowed = errors.New("courier: claim is not allowed for the order")
+	// ErrClaimExists indicates that the order already has a claim.
+	ErrClaimExists = errors.New("courier: claim already filed")
+	// ErrClaimAmount indicates that the claimed or paid amount is over the allowed limit.
+	ErrClaimAmount = errors.New("courier: claim amount exceeds the limit")
+	// ErrClaimState indicates that the claim is not in the state the action needs.
+	ErrClaimState = errors.New("courier: claim is not in a suitable state")
+)
+
+// Insurance — объявленная ценность посылки и оплаченная за неё страховка.
+type Insurance struct {
+	DeclaredValue int
+	Fee           int
+}
+
+// Claim is a damage or loss claim of the sender against an insured order.
+type Claim struct {
+	ID              int64
+	OrderID         int64
+	SenderID        int64
+	CourierID       sql.NullInt64
+	Kind            string
+	Description     string
+	Amount          int
+	Status          string
+	PayoutAmount    int
+	CourierCharge   int
+	ResolutionNote  sql.NullString
+	ResolvedAt      sql.NullTime
+	PayoutStatus    string
+	PayoutReference sql.NullString
+	PaidAt          sql.NullTime
+	CreatedAt       time.Time
+	Photos          []string
+}
+
+// ClaimResolution is the admin decision on a claim.
+type ClaimResolution struct {
+	Approve bool
+	// PayoutAmount goes to the sender, CourierCharge of it is taken from the courier's balance.
+	PayoutAmount  int
+	CourierCharge int
+	Note          sql.NullString
+}
+
+const claimColumns = `id, order_id, sender_id, courier_id, kind, description, amount, status, payout_amount, courier_charge,
+        resolution_note, resolved_at, payout_status, payout_reference, paid_at, created_at`
+
+func scanClaim(row interface{ Scan(...interface{}) error }) (Claim, error) {
+	var c Claim
+	err := row.Scan(&c.ID, &c.OrderID, &c.SenderID, &c.CourierID, &c.Kind, &c.Description, &c.Amount, &c.Status, &c.PayoutAmount,
+		&c.CourierCharge, &c.ResolutionNote, &c.ResolvedAt, &c.PayoutStatus, &c.PayoutReference, &c.PaidAt, &c.CreatedAt)
+	return c, err
+}
+
+// claimableStatuses — по заказу можно заявить претензию, только когда посылка вручена или вернулась.
+var claimableStatuses = statusSet([]string{StatusCompleted, StatusClosed, StatusReturned})
+
+// CreateClaim files the sender's claim with its photos. The amount may not exceed the declared value.
+func (r *OrdersRepo) CreateClaim(ctx context.Context, claim Claim) (id int64, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var (
+		senderID  int64
+		courierID sql.NullInt64
+		status    string
+		declared  int
+	)
+	err = tx.QueryRowContext(ctx, `SELECT sender_id, courier_id, status, declared_value FROM courier_orders WHERE id = ? FOR UPDATE`, claim.OrderID).
+		Scan(&senderID, &courierID, &status, &declared)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+		return 0, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := claimableStatuses[status]; senderID != claim.SenderID || !ok {
+		err = ErrClaimNotAllowed
+		return 0, err
+	}
+	if declared <= 0 {
+		err = ErrOrderNotInsured
+		return 0, err
+	}
+	if claim.Amount <= 0 || claim.Amount > declared {
+		err = ErrClaimAmount
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO courier_order_claims (order_id, sender_id, courier_id, kind, description, amount) VALUES (?, ?, ?, ?, ?, ?)`,
+		claim.OrderID, claim.SenderID, courierID, claim.Kind, claim.Description, claim.Amount)
+	if err != nil {
+		if isDuplicateKey(err) {
+			err = ErrClaimExists
+		}
+		return 0, err
+	}
+	if id, err = res.LastInsertId(); err != nil {
+		return 0, err
+	}
+	for _, path := range claim.Photos {
+		if _, err = tx.ExecContext(ctx, `INSERT INTO courier_order_claim_photos (claim_id, path) VALUES (?, ?)`, id, path); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// GetClaim loads the claim with its photos.
+func (r *OrdersRepo) GetClaim(ctx context.Context, id int64) (Claim, error) {
+	return r.loadClaim(ctx, `SELECT `+claimColumns+` FROM courier_order_claims WHERE id = ?`, id)
+}
+
+// ClaimByOrder loads the claim of the order with its photos.
+func (r *OrdersRepo) ClaimByOrder(ctx context.Context, orderID int64) (Claim, error) {
+	return r.loadClaim(ctx, `SELECT `+claimColumns+` FROM courier_order_claims WHERE order_id = ?`, orderID)
+}
+
+func (r *OrdersRepo) loadClaim(ctx context.Context, query string, arg int64) (Claim, error) {
+	c, err := scanClaim(r.db.QueryRowContext(ctx, query, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Claim{}, ErrNotFound
+	}
+	if err != nil {
+		return Claim{}, err
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT path FROM courier_order_claim_photos WHERE claim_id = ? ORDER BY id`, c.ID)
+	if err != nil {
+		return Claim{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return Claim{}, err
+		}
+		c.Photos = append(c.Photos, path)
+	}
+	return c, rows.Err()
+}
+
+// ListClaims returns claims newest first, optionally of one status; photos are not loaded.
+func (r *OrdersRepo) ListClaims(ctx context.Context, status string, limit, offset int) ([]Claim, error) {
+	query := `SELECT ` + claimColumns + ` FROM courier_order_claims`
+	args := []interface{}{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []Claim
+	for rows.Next() {
+		c, err := scanClaim(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+// ResolveClaim approves or rejects an open claim. Approval charges the courier's balance at once,
+// even below zero; the payout to the sender stays pending until MarkClaimPaid.
+func (r *OrdersRepo) ResolveClaim(ctx context.Context, id int64, res ClaimResolution, now time.Time) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var (
+		status    string
+		amount    int
+		orderID   int64
+		courierID sql.NullInt64
+	)
+	err = tx.QueryRowContext(ctx, `SELECT status, amount, order_id, courier_id FROM courier_order_claims WHERE id = ? FOR UPDATE`, id).Scan(&status, &amount, &orderID, &courierID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if status != ClaimOpen {
+		err = ErrClaimState
+		return err
+	}
+
+	if !res.Approve {
+		_, err = tx.ExecContext(ctx, `UPDATE courier_order_claims SET status = 'rejected', resolution_note = ?, resolved_at = ? WHERE id = ?`, res.Note, now, id)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if res.PayoutAmount <= 0 || res.PayoutAmount > amount || res.CourierCharge < 0 || res.CourierCharge > res.PayoutAmount {
+		err = ErrClaimAmount
+		return err
+	}
+	if res.CourierCharge > 0 && !courierID.Valid {
+		err = fmt.Errorf("%w: order has no courier to charge", ErrClaimAmount)
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE courier_order_claims SET status = 'approved', payout_amount = ?, courier_charge = ?, resolution_note = ?,
+        resolved_at = ?, payout_status = 'pending' WHERE id = ?`, res.PayoutAmount, res.CourierCharge, res.Note, now, id)
+	if err != nil {
+		return err
+	}
+	if res.CourierCharge > 0 {
+		balance, debt, lockErr := lockCourierAccount(ctx, tx, courierID.Int64)
+		if lockErr != nil {
+			err = lockErr
+			return err
+		}
+		entry := insuranceChargeEntry(courierID.Int64, orderID, res.CourierCharge, balance, debt)
+		if err = applyLedgerEntry(ctx, tx, &entry); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// insuranceChargeEntry takes the approved claim share from the courier's balance, even below zero.
+func insuranceChargeEntry(courierID, orderID int64, charge, balance, debt int) LedgerEntry {
+	return LedgerEntry{
+		CourierID:    courierID,
+		OrderID:      sql.NullInt64{Int64: orderID, Valid: true},
+		Kind:         LedgerKindInsuranceCharge,
+		BalanceDelta: -charge,
+		BalanceAfter: balance - charge,
+		DebtAfter:    debt,
+	}
+}
+
+// MarkClaimPaid records that the approved payout reached the sender.
+func (r *OrdersRepo) MarkClaimPaid(ctx context.Context, id int64, reference string, now time.Time) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE courier_order_claims SET payout_status = 'paid', payout_reference = ?, paid_at = ?
+        WHERE id = ? AND status = 'approved' AND payout_status = 'pending'`, reference, now, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		if _, err := r.GetClaim(ctx, id); err != nil {
+			return err
+		}
+		return ErrClaimState
+	}
+	return nil
+}
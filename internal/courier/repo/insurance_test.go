@@ -0,0 +1,31 @@
+package repo
+
+import "testing"
+
+func TestInsuranceChargeEntry(t *testing.T) {
+	cases := []struct {
+		name          string
+		charge        int
+		balance, debt int
+		balanceAfter  int
+	}{
+		{"covered by balance", 3000, 5000, 700, 2000},
+		{"goes below zero", 3000, 1000, 0, -2000},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := insuranceChargeEntry(7, 11, tc.charge, tc.balance, tc.debt)
+			if e.Kind != LedgerKindInsuranceCharge || e.CourierID != 7 || !e.OrderID.Valid || e.OrderID.Int64 != 11 {
+				t.Fatalf("unexpected entry %+v", e)
+			}
+			if e.BalanceDelta != -tc.charge || e.BalanceAfter != tc.balanceAfter {
+				t.Fatalf("expected balance %d got %+v", tc.balanceAfter, e)
+			}
+			// долг по наложенным платежам удержание не трогает
+			if e.DebtDelta != 0 || e.DebtAfter != tc.debt {
+				t.Fatalf("expected debt %d untouched got %+v", tc.debt, e)
+			}
+		})
+	}
+}
@@ -0,0 +1,491 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Merchant statuses.
+const (
+	MerchantActive  = "active"
+	MerchantBlocked = "blocked"
+)
+
+// Merchant API key scopes.
+const (
+	ScopeQuotes      = "quotes"
+	ScopeOrdersRead  = "orders:read"
+	ScopeOrdersWrite = "orders:write"
+	ScopeBilling     = "billing"
+)
+
+// MerchantScopes lists every scope a key may carry.
+var MerchantScopes = []string{ScopeQuotes, ScopeOrdersRead, ScopeOrdersWrite, ScopeBilling}
+
+// Webhook delivery states.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+var (
+	// ErrMerchantExists is returned when the user already has a merchant account.
+	ErrMerchantExists = errors.New("courier: merchant already registered")
+	// ErrDuplicateExternalID is returned when the merchant reuses its order id.
+	ErrDuplicateExternalID = errors.New("courier: external order id already used")
+)
+
+// Merchant — магазин, создающий доставки через API от имени своего пользователя.
+type Merchant struct {
+	ID            int64
+	UserID        int64
+	Name          string
+	WebhookURL    sql.NullString
+	WebhookSecret string
+	Status        string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// MerchantKey is an API key; only its hash is stored.
+type MerchantKey struct {
+	ID         int64
+	MerchantID int64
+	Name       string
+	Prefix     string
+	Scopes     []string
+	LastUsedAt sql.NullTime
+	ExpiresAt  sql.NullTime
+	RevokedAt  sql.NullTime
+	CreatedAt  time.Time
+}
+
+// Allows reports whether the key carries the scope.
+func (k MerchantKey) Allows(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// MerchantWebhook is a queued status notification for the merchant's URL.
+type MerchantWebhook struct {
+	ID            int64
+	MerchantID    int64
+	OrderID       int64
+	Event         string
+	Status        string
+	Payload       []byte
+	State         string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+	DeliveredAt   sql.NullTime
+	CreatedAt     time.Time
+	// URL and Secret are filled for due deliveries only.
+	URL    string
+	Secret string
+}
+
+// InvoiceLine is one billable order of the merchant.
+type InvoiceLine struct {
+	OrderID     int64
+	ExternalID  sql.NullString
+	Status      string
+	ClientPrice int
+	Surcharge   int
+	// InsuranceFee is billed for orders with a declared value.
+	InsuranceFee int
+	CreatedAt    time.Time
+}
+
+// MerchantsRepo persists merchant accounts, API keys and webhook deliveries.
+type MerchantsRepo struct {
+	db *sql.DB
+}
+
+// NewMerchantsRepo constructs a MerchantsRepo.
+func NewMerchantsRepo(db *sql.DB) *MerchantsRepo {
+	return &MerchantsRepo{db: db}
+}
+
+const merchantSelect = `SELECT id, user_id, name, webhook_url, webhook_secret, status, created_at, updated_at FROM courier_merchants`
+
+func scanMerchant(row interface{ Scan(...interface{}) error }) (Merchant, error) {
+	var m Merchant
+	err := row.Scan(&m.ID, &m.UserID, &m.Name, &m.WebhookURL, &m.WebhookSecret, &m.Status, &m.CreatedAt, &m.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Merchant{}, ErrNotFound
+	}
+	return m, err
+}
+
+// Create registers a merchant account for the user.
+func (r *MerchantsRepo) Create(ctx context.Context, m Merchant) (Merchant, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO courier_merchants (user_id, name, webhook_url, webhook_secret, status) VALUES (?,?,?,?,?)`,
+		m.UserID, m.Name, nullOrString(m.WebhookURL), m.WebhookSecret, MerchantActive)
+	if isDuplicateKey(err) {
+		return Merchant{}, ErrMerchantExists
+	}
+	if err != nil {
+		return Merchant{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Merchant{}, err
+	}
+	return r.Get(ctx, id)
+}
+
+// Get returns the merchant by id.
+func (r *MerchantsRepo) Get(ctx context.Context, id int64) (Merchant, error) {
+	return scanMerchant(r.db.QueryRowContext(ctx, merchantSelect+` WHERE id = ?`, id))
+}
+
+// GetByUser returns the merchant account of the user.
+func (r *MerchantsRepo) GetByUser(ctx context.Context, userID int64) (Merchant, error) {
+	return scanMerchant(r.db.QueryRowContext(ctx, merchantSelect+` WHERE user_id = ?`, userID))
+}
+
+// List returns merchants, newest first.
+func (r *MerchantsRepo) List(ctx context.Context, limit, offset int) ([]Merchant, error) {
+	rows, err := r.db.QueryContext(ctx, merchantSelect+` ORDER BY id DESC LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]Merchant, 0)
+	for rows.Next() {
+		m, err := scanMerchant(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, m)
+	}
+	return items, rows.Err()
+}
+
+// Update changes the merchant name and webhook URL.
+func (r *MerchantsRepo) Update(ctx context.Context, id int64, name string, webhookURL sql.NullString) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE courier_merchants SET name = ?, webhook_url = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		name, nullOrString(webhookURL), id)
+	return err
+}
+
+// SetWebhookSecret replaces the webhook signing secret.
+func (r *MerchantsRepo) SetWebhookSecret(ctx context.Context, id int64, secret string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE courier_merchants SET webhook_secret = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, secret, id)
+	return err
+}
+
+// SetStatus blocks or re-activates the merchant.
+func (r *MerchantsRepo) SetStatus(ctx context.Context, id int64, status string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE courier_merchants SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, status, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const merchantKeySelect = `SELECT k.id, k.merchant_id, k.name, k.prefix, k.scopes, k.last_used_at, k.expires_at, k.revoked_at, k.created_at FROM courier_merchant_keys k`
+
+func scanMerchantKey(row interface{ Scan(...interface{}) error }) (MerchantKey, error) {
+	var (
+		k      MerchantKey
+		scopes string
+	)
+	err := row.Scan(&k.ID, &k.MerchantID, &k.Name, &k.Prefix, &scopes, &k.LastUsedAt, &k.ExpiresAt, &k.RevokedAt, &k.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return MerchantKey{}, ErrNotFound
+	}
+	if scopes != "" {
+		k.Scopes = strings.Split(scopes, ",")
+	}
+	return k, err
+}
+
+func insertMerchantKey(ctx context.Context, exec interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+}, key MerchantKey, hash string) (int64, error) {
+	res, err := exec.ExecContext(ctx, `INSERT INTO courier_merchant_keys (merchant_id, name, prefix, key_hash, scopes) VALUES (?,?,?,?,?)`,
+		key.MerchantID, key.Name, key.Prefix, hash, strings.Join(key.Scopes, ","))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// CreateKey stores a new API key by its hash.
+func (r *MerchantsRepo) CreateKey(ctx context.Context, key MerchantKey, hash string) (MerchantKey, error) {
+	id, err := insertMerchantKey(ctx, r.db, key, hash)
+	if err != nil {
+		return MerchantKey{}, err
+	}
+	return r.getKey(ctx, key.MerchantID, id)
+}
+
+func (r *MerchantsRepo) getKey(ctx context.Context, merchantID, keyID int64) (MerchantKey, error) {
+	return scanMerchantKey(r.db.QueryRowContext(ctx, merchantKeySelect+` WHERE k.id = ? AND k.merchant_id = ?`, keyID, merchantID))
+}
+
+// ListKeys returns the merchant's keys, newest first, revoked ones included.
+func (r *MerchantsRepo) ListKeys(ctx context.Context, merchantID int64) ([]MerchantKey, error) {
+	rows, err := r.db.QueryContext(ctx, merchantKeySelect+` WHERE k.merchant_id = ? ORDER BY k.id DESC`, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]MerchantKey, 0)
+	for rows.Next() {
+		k, err := scanMerchantKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, k)
+	}
+	return items, rows.Err()
+}
+
+// RotateKey issues a replacement with the same name and scopes; the old key keeps working until graceUntil.
+func (r *MerchantsRepo) RotateKey(ctx context.Context, merchantID, keyID int64, next MerchantKey, hash string, graceUntil time.Time) (rotated MerchantKey, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return MerchantKey{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	old, err := scanMerchantKey(tx.QueryRowContext(ctx, merchantKeySelect+` WHERE k.id = ? AND k.merchant_id = ? AND k.revoked_at IS NULL FOR UPDATE`, keyID, merchantID))
+	if err != nil {
+		return MerchantKey{}, err
+	}
+	if old.ExpiresAt.Valid && old.ExpiresAt.Time.Before(graceUntil) {
+		graceUntil = old.ExpiresAt.Time
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE courier_merchant_keys SET expires_at = ? WHERE id = ?`, graceUntil, keyID); err != nil {
+		return MerchantKey{}, err
+	}
+	next.MerchantID, next.Name, next.Scopes = merchantID, old.Name, old.Scopes
+	id, err := insertMerchantKey(ctx, tx, next, hash)
+	if err != nil {
+		return MerchantKey{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return MerchantKey{}, err
+	}
+	return r.getKey(ctx, merchantID, id)
+}
+
+// RevokeKey disables the key at once.
+func (r *MerchantsRepo) RevokeKey(ctx context.Context, merchantID, keyID int64, at time.Time) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE courier_merchant_keys SET revoked_at = ? WHERE id = ? AND merchant_id = ? AND revoked_at IS NULL`, at, keyID, merchantID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Authenticate resolves a key hash into a usable key and its merchant.
+func (r *MerchantsRepo) Authenticate(ctx context.Context, hash string, now time.Time) (MerchantKey, Merchant, error) {
+	key, err := scanMerchantKey(r.db.QueryRowContext(ctx, merchantKeySelect+`
+        WHERE k.key_hash = ? AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > ?)`, hash, now))
+	if err != nil {
+		return MerchantKey{}, Merchant{}, err
+	}
+	merchant, err := r.Get(ctx, key.MerchantID)
+	if err != nil {
+		return MerchantKey{}, Merchant{}, err
+	}
+	if _, err := r.db.ExecContext(ctx, `UPDATE courier_merchant_keys SET last_used_at = ? WHERE id = ?`, now, key.ID); err != nil {
+		return MerchantKey{}, Merchant{}, err
+	}
+	return key, merchant, nil
+}
+
+// QueueWebhook enqueues a status notification unless the last one for the order carried the same status.
+func (r *MerchantsRepo) QueueWebhook(ctx context.Context, hook MerchantWebhook) (queued bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Блокируем заказ, чтобы параллельные события не поставили один статус дважды.
+	var orderID int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM courier_orders WHERE id = ? FOR UPDATE`, hook.OrderID).Scan(&orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	var last string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM courier_merchant_webhooks WHERE order_id = ? ORDER BY id DESC LIMIT 1`, hook.OrderID).Scan(&last)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = nil
+	case err != nil:
+		return false, err
+	case last == hook.Status:
+		return false, tx.Commit()
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO courier_merchant_webhooks (merchant_id, order_id, event, status, payload, next_attempt_at) VALUES (?,?,?,?,?,?)`,
+		hook.MerchantID, hook.OrderID, hook.Event, hook.Status, hook.Payload, hook.NextAttemptAt); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// DueWebhooks returns pending deliveries whose attempt time has come, oldest first.
+func (r *MerchantsRepo) DueWebhooks(ctx context.Context, now time.Time, limit int) ([]MerchantWebhook, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT w.id, w.merchant_id, w.order_id, w.event, w.status, w.payload, w.state, w.attempts,
+            w.next_attempt_at, w.last_error, w.delivered_at, w.created_at, COALESCE(m.webhook_url, ''), m.webhook_secret
+        FROM courier_merchant_webhooks w
+        JOIN courier_merchants m ON m.id = w.merchant_id
+        WHERE w.state = ? AND w.next_attempt_at <= ?
+        ORDER BY w.next_attempt_at, w.id
+        LIMIT ?`, WebhookPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]MerchantWebhook, 0)
+	for rows.Next() {
+		var h MerchantWebhook
+		if err := rows.Scan(&h.ID, &h.MerchantID, &h.OrderID, &h.Event, &h.Status, &h.Payload, &h.State, &h.Attempts,
+			&h.NextAttemptAt, &h.LastError, &h.DeliveredAt, &h.CreatedAt, &h.URL, &h.Secret); err != nil {
+			return nil, err
+		}
+		items = append(items, h)
+	}
+	return items, rows.Err()
+}
+
+// ClaimWebhook pushes the next attempt of a due delivery forward so that other instances skip it.
+func (r *MerchantsRepo) ClaimWebhook(ctx context.Context, id int64, now, leaseUntil time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE courier_merchant_webhooks SET next_attempt_at = ? WHERE id = ? AND state = ? AND next_attempt_at <= ?`,
+		leaseUntil, id, WebhookPending, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// MarkWebhookDelivered records a successful delivery.
+func (r *MerchantsRepo) MarkWebhookDelivered(ctx context.Context, id int64, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE courier_merchant_webhooks SET state = ?, attempts = attempts + 1, last_error = NULL, delivered_at = ? WHERE id = ?`,
+		WebhookDelivered, at, id)
+	return err
+}
+
+// MarkWebhookAttemptFailed records a failed attempt; zero nextAttempt gives up on the delivery.
+func (r *MerchantsRepo) MarkWebhookAttemptFailed(ctx context.Context, id int64, reason string, nextAttempt time.Time) error {
+	if len(reason) > 512 {
+		reason = reason[:512]
+	}
+	if nextAttempt.IsZero() {
+		_, err := r.db.ExecContext(ctx, `UPDATE courier_merchant_webhooks SET state = ?, attempts = attempts + 1, last_error = ? WHERE id = ?`,
+			WebhookFailed, reason, id)
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `UPDATE courier_merchant_webhooks SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?`,
+		reason, nextAttempt, id)
+	return err
+}
+
+// ListWebhooks returns the merchant's webhook deliveries, newest first.
+func (r *MerchantsRepo) ListWebhooks(ctx context.Context, merchantID int64, limit, offset int) ([]MerchantWebhook, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, merchant_id, order_id, event, status, payload, state, attempts,
+            next_attempt_at, last_error, delivered_at, created_at
+        FROM courier_merchant_webhooks WHERE merchant_id = ? ORDER BY id DESC LIMIT ? OFFSET ?`, merchantID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]MerchantWebhook, 0)
+	for rows.Next() {
+		var h MerchantWebhook
+		if err := rows.Scan(&h.ID, &h.MerchantID, &h.OrderID, &h.Event, &h.Status, &h.Payload, &h.State, &h.Attempts,
+			&h.NextAttemptAt, &h.LastError, &h.DeliveredAt, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, h)
+	}
+	return items, rows.Err()
+}
+
+// ListByMerchant returns the merchant's orders created within [from, to), newest first.
+func (r *OrdersRepo) ListByMerchant(ctx context.Context, merchantID int64, from, to time.Time, limit, offset int) ([]Order, error) {
+	suffix := `
+	WHERE o.merchant_id = ? AND o.created_at >= ? AND o.created_at < ?
+	ORDER BY o.created_at DESC
+	LIMIT ? OFFSET ?`
+	return r.listOrdersWithRelations(ctx, suffix, merchantID, from, to, limit, offset)
+}
+
+// MerchantOrderID resolves the merchant's own order id.
+func (r *OrdersRepo) MerchantOrderID(ctx context.Context, merchantID int64, externalID string) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM courier_orders WHERE merchant_id = ? AND external_id = ?`, merchantID, externalID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return id, err
+}
+
+// MerchantInvoice lists delivered and returned orders of the merchant placed within [from, to).
+func (r *OrdersRepo) MerchantInvoice(ctx context.Context, merchantID int64, from, to time.Time) ([]InvoiceLine, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, external_id, status, client_price, waiting_fee + return_fee, insurance_fee, created_at
+        FROM courier_orders
+        WHERE merchant_id = ? AND created_at >= ? AND created_at < ? AND status IN (?,?,?)
+        ORDER BY created_at`, merchantID, from, to, StatusCompleted, StatusClosed, StatusReturned)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]InvoiceLine, 0)
+	for rows.Next() {
+		var line InvoiceLine
+		if err := rows.Scan(&line.OrderID, &line.ExternalID, &line.Status, &line.ClientPrice, &line.Surcharge, &line.InsuranceFee, &line.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, line)
+	}
+	return items, rows.Err()
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"naimuBack/internal/courier/lifecycle"
+	"naimuBack/internal/courier/parcel"
 )
 
 var (
@@ -18,6 +19,9 @@ var (
 		StatusAccepted,
 		StatusWaitingFree,
 		StatusInProgress,
+		StatusDeliveryAttempted,
+		StatusWaitingRecipient,
+		StatusReturning,
 	}
 	completedStatuses = []string{
 		StatusCompleted,
@@ -28,9 +32,13 @@ var (
 		StatusCanceledByCourier,
 		StatusCanceledNoShow,
 	}
+	returnedStatuses = []string{
+		StatusReturned,
+	}
 	activeStatusSet    = statusSet(activeStatuses)
 	completedStatusSet = statusSet(completedStatuses)
 	canceledStatusSet  = statusSet(canceledStatuses)
+	returnedStatusSet  = statusSet(returnedStatuses)
 )
 
 var (
@@ -50,12 +58,19 @@ const (
 	StatusCanceledBySender  = lifecycle.StatusCanceledBySender
 	StatusCanceledByCourier = lifecycle.StatusCanceledByCourier
 	StatusCanceledNoShow    = lifecycle.StatusCanceledNoShow
+	StatusDeliveryAttempted = lifecycle.StatusDeliveryAttempted
+	StatusWaitingRecipient  = lifecycle.StatusWaitingRecipient
+	StatusReturning         = lifecycle.StatusReturning
+	StatusReturned          = lifecycle.StatusReturned
 )
 
 // Order represents a courier order together with its route points.
 type Order struct {
-	ID               int64
-	SenderID         int64
+	ID       int64
+	SenderID int64
+	// MerchantID and ExternalID are set for orders created through the merchant API.
+	MerchantID       sql.NullInt64
+	ExternalID       sql.NullString
 	CourierID        sql.NullInt64
 	DistanceM        int
 	EtaSeconds       int
@@ -64,28 +79,48 @@ type Order struct {
 	PaymentMethod    string
 	Status           string
 	Comment          sql.NullString
+	Parcel           parcel.Parcel
+	COD              COD
+	Insurance        Insurance
+	Schedule         Schedule
+	Return           Return
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
 	Points           []OrderPoint
 	Sender           User
 	Courier          *Courier
+	// Commission is recorded on completion together with the rule or shift pass that produced it.
+	Commission       sql.NullInt64
+	CommissionRuleID sql.NullInt64
+	CommissionPassID sql.NullInt64
+}
+
+// OrderCommission is the commission charged to the courier on completion.
+type OrderCommission struct {
+	Amount int
+	RuleID sql.NullInt64
+	PassID sql.NullInt64
+	// InsuranceFee is withheld from the courier together with the commission but is not part of it.
+	InsuranceFee int
 }
 
 // OrderPoint describes a delivery waypoint.
 type OrderPoint struct {
-	ID        int64
-	OrderID   int64
-	Seq       int
-	Address   string
-	Lat       float64
-	Lon       float64
-	Entrance  sql.NullString
-	Apt       sql.NullString
-	Floor     sql.NullString
-	Intercom  sql.NullString
-	Phone     sql.NullString
-	Comment   sql.NullString
-	CreatedAt time.Time
+	ID       int64
+	OrderID  int64
+	Seq      int
+	Address  string
+	Lat      float64
+	Lon      float64
+	Entrance sql.NullString
+	Apt      sql.NullString
+	Floor    sql.NullString
+	Intercom sql.NullString
+	Phone    sql.NullString
+	Comment  sql.NullString
+	// LegPolyline is the encoded route geometry from this point to the next one.
+	LegPolyline sql.NullString
+	CreatedAt   time.Time
 }
 
 // StatusHistoryEntry captures a lifecycle change for auditing.
@@ -102,8 +137,10 @@ type DispatchRecord struct {
 	OrderID    int64
 	RadiusM    int
 	NextTickAt time.Time
-	State      string
-	CreatedAt  time.Time
+	// StartsAt is when searching actually begins; set for scheduled orders only.
+	StartsAt  sql.NullTime
+	State     string
+	CreatedAt time.Time
 }
 
 // CourierReview holds feedback exchanged between sender and courier.
@@ -122,6 +159,7 @@ type OrdersStats struct {
 	Active    int `json:"active_orders"`
 	Completed int `json:"completed_orders"`
 	Canceled  int `json:"canceled_orders"`
+	Returned  int `json:"returned_orders"`
 }
 
 // CourierOrderStats aggregates orders metrics per courier profile.
@@ -130,6 +168,7 @@ type CourierOrderStats struct {
 	Active    int `json:"active_orders"`
 	Completed int `json:"completed_orders"`
 	Canceled  int `json:"canceled_orders"`
+	Returned  int `json:"returned_orders"`
 }
 
 // OrdersRepo provides persistence for courier orders and their points.
@@ -141,6 +180,8 @@ const ordersWithRelationsSelect = `
 SELECT
         o.id,
         o.sender_id,
+        o.merchant_id,
+        o.external_id,
         o.courier_id,
         o.distance_m,
         o.eta_seconds,
@@ -149,6 +190,30 @@ SELECT
         o.payment_method,
         o.status,
         o.comment,
+        o.parcel_class,
+        o.parcel_weight_kg,
+        o.parcel_length_cm,
+        o.parcel_width_cm,
+        o.parcel_height_cm,
+        o.cod_amount,
+        o.cod_collected,
+        o.cod_collected_at,
+        o.declared_value,
+        o.insurance_fee,
+        o.pickup_from,
+        o.pickup_to,
+        o.dropoff_from,
+        o.dropoff_to,
+        o.reserved_courier_id,
+        o.reserved_at,
+        o.late_risk_at,
+        o.delivery_attempted_at,
+        o.waiting_fee,
+        o.return_started_at,
+        o.return_distance_m,
+        o.return_fee,
+        o.return_arrived_at,
+        o.returned_at,
         o.created_at,
         o.updated_at,
         s.id,
@@ -210,6 +275,9 @@ func (r *OrdersRepo) create(ctx context.Context, order Order, dispatch *Dispatch
 	if len(order.Points) < 2 {
 		return 0, fmt.Errorf("order must contain at least two points")
 	}
+	if order.Parcel.Class == "" {
+		order.Parcel.Class = parcel.ClassSmall
+	}
 	tx, beginErr := r.db.BeginTx(ctx, nil)
 	if beginErr != nil {
 		return 0, beginErr
@@ -220,10 +288,18 @@ func (r *OrdersRepo) create(ctx context.Context, order Order, dispatch *Dispatch
 		}
 	}()
 
-	res, execErr := tx.ExecContext(ctx, `INSERT INTO courier_orders (sender_id, distance_m, eta_seconds, recommended_price, client_price, payment_method, status, comment) VALUES (?,?,?,?,?,?,?,?)`,
-		order.SenderID, order.DistanceM, order.EtaSeconds, order.RecommendedPrice, order.ClientPrice, order.PaymentMethod, StatusNew, nullOrString(order.Comment))
+	res, execErr := tx.ExecContext(ctx, `INSERT INTO courier_orders (sender_id, merchant_id, external_id, distance_m, eta_seconds, recommended_price, client_price, payment_method, status, comment,
+        parcel_class, parcel_weight_kg, parcel_length_cm, parcel_width_cm, parcel_height_cm, cod_amount,
+        declared_value, insurance_fee, pickup_from, pickup_to, dropoff_from, dropoff_to) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		order.SenderID, order.MerchantID, nullOrString(order.ExternalID), order.DistanceM, order.EtaSeconds, order.RecommendedPrice, order.ClientPrice, order.PaymentMethod, StatusNew, nullOrString(order.Comment),
+		order.Parcel.Class, order.Parcel.WeightKg, order.Parcel.LengthCm, order.Parcel.WidthCm, order.Parcel.HeightCm, order.COD.Amount,
+		order.Insurance.DeclaredValue, order.Insurance.Fee,
+		order.Schedule.Pickup.From, order.Schedule.Pickup.To, order.Schedule.Dropoff.From, order.Schedule.Dropoff.To)
 	if execErr != nil {
 		err = execErr
+		if order.ExternalID.Valid && isDuplicateKey(execErr) {
+			err = ErrDuplicateExternalID
+		}
 		return 0, err
 	}
 	orderID, err = res.LastInsertId()
@@ -234,6 +310,12 @@ func (r *OrdersRepo) create(ctx context.Context, order Order, dispatch *Dispatch
 	if err = insertOrderPoints(ctx, tx, orderID, order.Points); err != nil {
 		return 0, err
 	}
+	if err = insertRecipientPINs(ctx, tx, orderID); err != nil {
+		return 0, err
+	}
+	if err = insertTrackingLinks(ctx, tx, orderID); err != nil {
+		return 0, err
+	}
 
 	if err = insertStatusHistory(ctx, tx, StatusHistoryEntry{OrderID: orderID, Status: StatusNew}); err != nil {
 		return 0, err
@@ -277,6 +359,8 @@ func (r *OrdersRepo) Get(ctx context.Context, id int64) (Order, error) {
         SELECT
                 o.id,
                 o.sender_id,
+                o.merchant_id,
+                o.external_id,
                 o.courier_id,
                 o.distance_m,
                 o.eta_seconds,
@@ -285,8 +369,35 @@ func (r *OrdersRepo) Get(ctx context.Context, id int64) (Order, error) {
                 o.payment_method,
                 o.status,
                 o.comment,
+                o.parcel_class,
+                o.parcel_weight_kg,
+                o.parcel_length_cm,
+                o.parcel_width_cm,
+                o.parcel_height_cm,
+                o.cod_amount,
+                o.cod_collected,
+                o.cod_collected_at,
+                o.declared_value,
+                o.insurance_fee,
+                o.pickup_from,
+                o.pickup_to,
+                o.dropoff_from,
+                o.dropoff_to,
+                o.reserved_courier_id,
+                o.reserved_at,
+                o.late_risk_at,
+                o.delivery_attempted_at,
+                o.waiting_fee,
+                o.return_started_at,
+                o.return_distance_m,
+                o.return_fee,
+                o.return_arrived_at,
+                o.returned_at,
                 o.created_at,
                 o.updated_at,
+                o.commission,
+                o.commission_rule_id,
+                o.commission_pass_id,
                 s.id,
                 s.name,
                 s.surname,
@@ -329,6 +440,8 @@ func (r *OrdersRepo) Get(ctx context.Context, id int64) (Order, error) {
 	err := row.Scan(
 		&o.ID,
 		&o.SenderID,
+		&o.MerchantID,
+		&o.ExternalID,
 		&o.CourierID,
 		&o.DistanceM,
 		&o.EtaSeconds,
@@ -337,8 +450,35 @@ func (r *OrdersRepo) Get(ctx context.Context, id int64) (Order, error) {
 		&o.PaymentMethod,
 		&o.Status,
 		&o.Comment,
+		&o.Parcel.Class,
+		&o.Parcel.WeightKg,
+		&o.Parcel.LengthCm,
+		&o.Parcel.WidthCm,
+		&o.Parcel.HeightCm,
+		&o.COD.Amount,
+		&o.COD.Collected,
+		&o.COD.CollectedAt,
+		&o.Insurance.DeclaredValue,
+		&o.Insurance.Fee,
+		&o.Schedule.Pickup.From,
+		&o.Schedule.Pickup.To,
+		&o.Schedule.Dropoff.From,
+		&o.Schedule.Dropoff.To,
+		&o.Schedule.ReservedCourierID,
+		&o.Schedule.ReservedAt,
+		&o.Schedule.LateRiskAt,
+		&o.Return.AttemptedAt,
+		&o.Return.WaitingFee,
+		&o.Return.StartedAt,
+		&o.Return.DistanceM,
+		&o.Return.Fee,
+		&o.Return.ArrivedAt,
+		&o.Return.ReturnedAt,
 		&o.CreatedAt,
 		&o.UpdatedAt,
+		&o.Commission,
+		&o.CommissionRuleID,
+		&o.CommissionPassID,
 		&o.Sender.ID,
 		&o.Sender.Name,
 		&o.Sender.Surname,
@@ -562,7 +702,7 @@ func (r *OrdersRepo) ListCompletedByCourierBetween(ctx context.Context, courierI
 	}
 	args = append(args, from, to)
 
-	query := fmt.Sprintf(`SELECT id, sender_id, courier_id, distance_m, eta_seconds, recommended_price, client_price, payment_method, status, comment, created_at, updated_at FROM courier_orders WHERE courier_id = ? AND status IN (%s) AND updated_at >= ? AND updated_at < ? ORDER BY updated_at ASC`, placeholders(len(completedStatuses)))
+	query := fmt.Sprintf(`SELECT id, sender_id, courier_id, distance_m, eta_seconds, recommended_price, client_price, payment_method, status, comment, created_at, updated_at, commission FROM courier_orders WHERE courier_id = ? AND status IN (%s) AND updated_at >= ? AND updated_at < ? ORDER BY updated_at ASC`, placeholders(len(completedStatuses)))
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -621,8 +761,43 @@ func (r *OrdersRepo) UpdateStatus(ctx context.Context, orderID int64, nextStatus
 	return tx.Commit()
 }
 
+// RecordCommission stores the commission of a completed order once and deducts it from the courier balance.
+// Returns false when the commission was already recorded.
+func (r *OrdersRepo) RecordCommission(ctx context.Context, orderID, courierID int64, charge OrderCommission) (recorded bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `UPDATE courier_orders SET commission = ?, commission_rule_id = ?, commission_pass_id = ?
+        WHERE id = ? AND courier_id = ? AND commission IS NULL`, charge.Amount, charge.RuleID, charge.PassID, orderID, courierID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, tx.Commit()
+	}
+
+	if withheld := charge.Amount + charge.InsuranceFee; withheld > 0 {
+		if _, err = tx.ExecContext(ctx, `UPDATE couriers SET balance = balance - ? WHERE id = ?`, withheld, courierID); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
 // AssignCourier links a courier to the order and optionally moves it to a new status.
-func (r *OrdersRepo) AssignCourier(ctx context.Context, orderID, courierID int64, nextStatus string) error {
+// The courier's batch limit is rechecked under the order and courier row locks.
+func (r *OrdersRepo) AssignCourier(ctx context.Context, orderID, courierID int64, nextStatus string, limit CourierLimit) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -633,8 +808,11 @@ func (r *OrdersRepo) AssignCourier(ctx context.Context, orderID, courierID int64
 		}
 	}()
 
-	var current string
-	if err = tx.QueryRowContext(ctx, `SELECT status FROM courier_orders WHERE id = ? FOR UPDATE`, orderID).Scan(&current); err != nil {
+	var (
+		current  string
+		weightKg float64
+	)
+	if err = tx.QueryRowContext(ctx, `SELECT status, parcel_weight_kg FROM courier_orders WHERE id = ? FOR UPDATE`, orderID).Scan(&current, &weightKg); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return ErrNotFound
 		}
@@ -648,6 +826,9 @@ func (r *OrdersRepo) AssignCourier(ctx context.Context, orderID, courierID int64
 		}
 		statusChanged = true
 	}
+	if err = checkCourierLimitTx(ctx, tx, courierID, orderID, weightKg, limit); err != nil {
+		return err
+	}
 
 	if statusChanged {
 		if _, err = tx.ExecContext(ctx, `UPDATE courier_orders SET courier_id = ?, status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, courierID, nextStatus, orderID); err != nil {
@@ -665,6 +846,41 @@ func (r *OrdersRepo) AssignCourier(ctx context.Context, orderID, courierID int64
 	return tx.Commit()
 }
 
+// AssignCourierCAS assigns a courier only if the order is still in the expected status
+// and, when limit is set, the courier still has room for it.
+func (r *OrdersRepo) AssignCourierCAS(ctx context.Context, orderID, courierID int64, current, next string, limit CourierLimit) error {
+	if !lifecycle.CanTransition(current, next) {
+		return fmt.Errorf("invalid status transition from %s to %s", current, next)
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var weightKg float64
+	if err = tx.QueryRowContext(ctx, `SELECT parcel_weight_kg FROM courier_orders WHERE id = ? AND status = ? FOR UPDATE`, orderID, current).Scan(&weightKg); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if err = checkCourierLimitTx(ctx, tx, courierID, orderID, weightKg, limit); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE courier_orders SET courier_id = ?, status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, courierID, next, orderID); err != nil {
+		return err
+	}
+	if err = insertStatusHistory(ctx, tx, StatusHistoryEntry{OrderID: orderID, Status: next, Note: sql.NullString{String: "assigned by dispatcher", Valid: true}}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func scanOrdersWithRelations(rows *sql.Rows) ([]Order, error) {
 	var orders []Order
 	for rows.Next() {
@@ -691,6 +907,8 @@ func scanOrdersWithRelations(rows *sql.Rows) ([]Order, error) {
 		if err := rows.Scan(
 			&o.ID,
 			&o.SenderID,
+			&o.MerchantID,
+			&o.ExternalID,
 			&o.CourierID,
 			&o.DistanceM,
 			&o.EtaSeconds,
@@ -699,6 +917,30 @@ func scanOrdersWithRelations(rows *sql.Rows) ([]Order, error) {
 			&o.PaymentMethod,
 			&o.Status,
 			&o.Comment,
+			&o.Parcel.Class,
+			&o.Parcel.WeightKg,
+			&o.Parcel.LengthCm,
+			&o.Parcel.WidthCm,
+			&o.Parcel.HeightCm,
+			&o.COD.Amount,
+			&o.COD.Collected,
+			&o.COD.CollectedAt,
+			&o.Insurance.DeclaredValue,
+			&o.Insurance.Fee,
+			&o.Schedule.Pickup.From,
+			&o.Schedule.Pickup.To,
+			&o.Schedule.Dropoff.From,
+			&o.Schedule.Dropoff.To,
+			&o.Schedule.ReservedCourierID,
+			&o.Schedule.ReservedAt,
+			&o.Schedule.LateRiskAt,
+			&o.Return.AttemptedAt,
+			&o.Return.WaitingFee,
+			&o.Return.StartedAt,
+			&o.Return.DistanceM,
+			&o.Return.Fee,
+			&o.Return.ArrivedAt,
+			&o.Return.ReturnedAt,
 			&o.CreatedAt,
 			&o.UpdatedAt,
 			&o.Sender.ID,
@@ -786,7 +1028,7 @@ func (r *OrdersRepo) scanOrders(rows *sql.Rows) ([]Order, error) {
 	var orders []Order
 	for rows.Next() {
 		var o Order
-		if err := rows.Scan(&o.ID, &o.SenderID, &o.CourierID, &o.DistanceM, &o.EtaSeconds, &o.RecommendedPrice, &o.ClientPrice, &o.PaymentMethod, &o.Status, &o.Comment, &o.CreatedAt, &o.UpdatedAt); err != nil {
+		if err := rows.Scan(&o.ID, &o.SenderID, &o.CourierID, &o.DistanceM, &o.EtaSeconds, &o.RecommendedPrice, &o.ClientPrice, &o.PaymentMethod, &o.Status, &o.Comment, &o.CreatedAt, &o.UpdatedAt, &o.Commission); err != nil {
 			return nil, err
 		}
 		orders = append(orders, o)
@@ -948,7 +1190,7 @@ func (r *OrdersRepo) attachPoints(ctx context.Context, orders []Order) error {
 		orderIndex[o.ID] = i
 	}
 
-	query := fmt.Sprintf(`SELECT id, order_id, seq, address, lat, lon, entrance, apt, floor, intercom, phone, comment, created_at FROM courier_order_points WHERE order_id IN (%s) ORDER BY order_id, seq`, strings.Join(ids, ","))
+	query := fmt.Sprintf(`SELECT id, order_id, seq, address, lat, lon, entrance, apt, floor, intercom, phone, comment, leg_polyline, created_at FROM courier_order_points WHERE order_id IN (%s) ORDER BY order_id, seq`, strings.Join(ids, ","))
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return err
@@ -957,7 +1199,7 @@ func (r *OrdersRepo) attachPoints(ctx context.Context, orders []Order) error {
 
 	for rows.Next() {
 		var p OrderPoint
-		if err := rows.Scan(&p.ID, &p.OrderID, &p.Seq, &p.Address, &p.Lat, &p.Lon, &p.Entrance, &p.Apt, &p.Floor, &p.Intercom, &p.Phone, &p.Comment, &p.CreatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.OrderID, &p.Seq, &p.Address, &p.Lat, &p.Lon, &p.Entrance, &p.Apt, &p.Floor, &p.Intercom, &p.Phone, &p.Comment, &p.LegPolyline, &p.CreatedAt); err != nil {
 			return err
 		}
 		if idx, ok := orderIndex[p.OrderID]; ok {
@@ -968,7 +1210,7 @@ func (r *OrdersRepo) attachPoints(ctx context.Context, orders []Order) error {
 }
 
 func (r *OrdersRepo) fetchPoints(ctx context.Context, orderID int64) ([]OrderPoint, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, order_id, seq, address, lat, lon, entrance, apt, floor, intercom, phone, comment, created_at FROM courier_order_points WHERE order_id = ? ORDER BY seq`, orderID)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, order_id, seq, address, lat, lon, entrance, apt, floor, intercom, phone, comment, leg_polyline, created_at FROM courier_order_points WHERE order_id = ? ORDER BY seq`, orderID)
 	if err != nil {
 		return nil, err
 	}
@@ -977,7 +1219,7 @@ func (r *OrdersRepo) fetchPoints(ctx context.Context, orderID int64) ([]OrderPoi
 	var points []OrderPoint
 	for rows.Next() {
 		var p OrderPoint
-		if err := rows.Scan(&p.ID, &p.OrderID, &p.Seq, &p.Address, &p.Lat, &p.Lon, &p.Entrance, &p.Apt, &p.Floor, &p.Intercom, &p.Phone, &p.Comment, &p.CreatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.OrderID, &p.Seq, &p.Address, &p.Lat, &p.Lon, &p.Entrance, &p.Apt, &p.Floor, &p.Intercom, &p.Phone, &p.Comment, &p.LegPolyline, &p.CreatedAt); err != nil {
 			return nil, err
 		}
 		points = append(points, p)
@@ -989,14 +1231,14 @@ func (r *OrdersRepo) fetchPoints(ctx context.Context, orderID int64) ([]OrderPoi
 }
 
 func insertOrderPoints(ctx context.Context, tx *sql.Tx, orderID int64, points []OrderPoint) error {
-	stmt, err := tx.PrepareContext(ctx, `INSERT INTO courier_order_points (order_id, seq, address, lat, lon, entrance, apt, floor, intercom, phone, comment) VALUES (?,?,?,?,?,?,?,?,?,?,?)`)
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO courier_order_points (order_id, seq, address, lat, lon, entrance, apt, floor, intercom, phone, comment, leg_polyline) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
 	for _, p := range points {
-		if _, err := stmt.ExecContext(ctx, orderID, p.Seq, p.Address, p.Lat, p.Lon, nullOrString(p.Entrance), nullOrString(p.Apt), nullOrString(p.Floor), nullOrString(p.Intercom), nullOrString(p.Phone), nullOrString(p.Comment)); err != nil {
+		if _, err := stmt.ExecContext(ctx, orderID, p.Seq, p.Address, p.Lat, p.Lon, nullOrString(p.Entrance), nullOrString(p.Apt), nullOrString(p.Floor), nullOrString(p.Intercom), nullOrString(p.Phone), nullOrString(p.Comment), p.LegPolyline); err != nil {
 			return err
 		}
 	}
@@ -1015,8 +1257,8 @@ func insertDispatchRecord(ctx context.Context, tx *sql.Tx, orderID int64, dispat
 	if dispatch.State == "" {
 		dispatch.State = StatusNew
 	}
-	_, err := tx.ExecContext(ctx, `INSERT INTO courier_order_dispatch (order_id, radius_m, next_tick_at, state) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE radius_m=VALUES(radius_m), next_tick_at=VALUES(next_tick_at), state=VALUES(state)`,
-		orderID, dispatch.RadiusM, dispatch.NextTickAt, dispatch.State)
+	_, err := tx.ExecContext(ctx, `INSERT INTO courier_order_dispatch (order_id, radius_m, next_tick_at, starts_at, state) VALUES (?,?,?,?,?) ON DUPLICATE KEY UPDATE radius_m=VALUES(radius_m), next_tick_at=VALUES(next_tick_at), starts_at=VALUES(starts_at), state=VALUES(state)`,
+		orderID, dispatch.RadiusM, dispatch.NextTickAt, dispatch.StartsAt, dispatch.State)
 	return err
 }
 
@@ -1032,7 +1274,7 @@ func NewDispatchRepo(db *sql.DB) *DispatchRepo {
 
 // ListDue returns dispatch records ready for processing.
 func (r *DispatchRepo) ListDue(ctx context.Context, now time.Time) ([]DispatchRecord, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, order_id, radius_m, next_tick_at, state, created_at FROM courier_order_dispatch WHERE state = 'searching' AND next_tick_at <= ?`, now)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, order_id, radius_m, next_tick_at, starts_at, state, created_at FROM courier_order_dispatch WHERE state = 'searching' AND next_tick_at <= ?`, now)
 	if err != nil {
 		return nil, err
 	}
@@ -1041,7 +1283,7 @@ func (r *DispatchRepo) ListDue(ctx context.Context, now time.Time) ([]DispatchRe
 	var items []DispatchRecord
 	for rows.Next() {
 		var rec DispatchRecord
-		if err := rows.Scan(&rec.ID, &rec.OrderID, &rec.RadiusM, &rec.NextTickAt, &rec.State, &rec.CreatedAt); err != nil {
+		if err := rows.Scan(&rec.ID, &rec.OrderID, &rec.RadiusM, &rec.NextTickAt, &rec.StartsAt, &rec.State, &rec.CreatedAt); err != nil {
 			return nil, err
 		}
 		items = append(items, rec)
@@ -1138,6 +1380,63 @@ func (r *OrdersRepo) ListAll(ctx context.Context, limit, offset int) ([]Order, e
 	return orders, nil
 }
 
+// LiveOrder is an active order together with its current search radius.
+type LiveOrder struct {
+	Order
+	// RadiusM is set while the dispatcher is still searching for a courier.
+	RadiusM sql.NullInt64
+	// SearchStartsAt is when searching of a scheduled order begins.
+	SearchStartsAt sql.NullTime
+}
+
+// ListLive returns every active order with route points for the operations map, oldest first.
+func (r *OrdersRepo) ListLive(ctx context.Context) ([]LiveOrder, error) {
+	args := make([]interface{}, 0, len(activeStatuses))
+	for _, st := range activeStatuses {
+		args = append(args, st)
+	}
+	suffix := fmt.Sprintf(`
+		WHERE o.status IN (%s)
+		ORDER BY o.created_at`, placeholders(len(activeStatuses)))
+	orders, err := r.listOrdersWithRelations(ctx, suffix, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT order_id, radius_m, starts_at FROM courier_order_dispatch WHERE state = 'searching'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	radii := make(map[int64]int64)
+	starts := make(map[int64]sql.NullTime)
+	for rows.Next() {
+		var (
+			orderID, radius int64
+			startsAt        sql.NullTime
+		)
+		if err := rows.Scan(&orderID, &radius, &startsAt); err != nil {
+			return nil, err
+		}
+		radii[orderID] = radius
+		starts[orderID] = startsAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	live := make([]LiveOrder, 0, len(orders))
+	for _, o := range orders {
+		item := LiveOrder{Order: o}
+		if radius, ok := radii[o.ID]; ok {
+			item.RadiusM = sql.NullInt64{Int64: radius, Valid: true}
+		}
+		item.SearchStartsAt = starts[o.ID]
+		live = append(live, item)
+	}
+	return live, nil
+}
+
 // Stats returns aggregated counts across courier orders.
 func (r *OrdersRepo) Stats(ctx context.Context) (OrdersStats, error) {
 	rows, err := r.db.QueryContext(ctx, `SELECT status, COUNT(*) FROM courier_orders GROUP BY status`)
@@ -1165,6 +1464,9 @@ func (r *OrdersRepo) Stats(ctx context.Context) (OrdersStats, error) {
 		if _, ok := canceledStatusSet[status]; ok {
 			stats.Canceled += count
 		}
+		if _, ok := returnedStatusSet[status]; ok {
+			stats.Returned += count
+		}
 	}
 	if err := rows.Err(); err != nil {
 		return OrdersStats{}, err
@@ -1242,6 +1544,9 @@ func (r *OrdersRepo) CourierStats(ctx context.Context, courierID int64) (Courier
 		if _, ok := canceledStatusSet[status]; ok {
 			stats.Canceled += count
 		}
+		if _, ok := returnedStatusSet[status]; ok {
+			stats.Returned += count
+		}
 	}
 	if err := rows.Err(); err != nil {
 		return CourierOrderStats{}, err
@@ -0,0 +1,231 @@
+package repo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Delivery proof methods.
+const (
+	ProofMethodPIN            = "pin"
+	ProofMethodPhotoSignature = "photo_signature"
+)
+
+// MaxPINAttempts — после стольких неверных PIN точку можно закрыть только фото с подписью.
+const MaxPINAttempts = 5
+
+var (
+	// ErrPointDelivered is returned when the drop-off already has evidence.
+	ErrPointDelivered = errors.New("point already delivered")
+	// ErrPINMismatch is returned for a wrong recipient PIN.
+	ErrPINMismatch = errors.New("invalid recipient pin")
+	// ErrPINLocked is returned once the PIN attempts are exhausted.
+	ErrPINLocked = errors.New("recipient pin attempts exhausted")
+)
+
+// DeliveryProof — PIN получателя и доказательства вручения по точке выдачи.
+type DeliveryProof struct {
+	ID            int64
+	OrderID       int64
+	PointID       int64
+	Seq           int
+	Address       string
+	Phone         sql.NullString
+	PIN           string
+	PINAttempts   int
+	Method        sql.NullString
+	PhotoPath     sql.NullString
+	SignaturePath sql.NullString
+	Lat           sql.NullFloat64
+	Lon           sql.NullFloat64
+	CourierID     sql.NullInt64
+	DeliveredAt   sql.NullTime
+	CreatedAt     time.Time
+}
+
+// DeliveryEvidence is what the courier submits when finishing a drop-off.
+type DeliveryEvidence struct {
+	CourierID     int64
+	PIN           string
+	PhotoPath     string
+	SignaturePath string
+	Lat           float64
+	Lon           float64
+	At            time.Time
+}
+
+const deliveryProofSelect = `SELECT p.id, p.order_id, p.point_id, pt.seq, pt.address, pt.phone, p.pin, p.pin_attempts,
+        p.method, p.photo_path, p.signature_path, p.lat, p.lon, p.courier_id, p.delivered_at, p.created_at
+    FROM courier_delivery_proofs p
+    JOIN courier_order_points pt ON pt.id = p.point_id`
+
+func scanDeliveryProof(row interface{ Scan(...interface{}) error }) (DeliveryProof, error) {
+	var p DeliveryProof
+	err := row.Scan(&p.ID, &p.OrderID, &p.PointID, &p.Seq, &p.Address, &p.Phone, &p.PIN, &p.PINAttempts,
+		&p.Method, &p.PhotoPath, &p.SignaturePath, &p.Lat, &p.Lon, &p.CourierID, &p.DeliveredAt, &p.CreatedAt)
+	return p, err
+}
+
+// insertRecipientPINs issues a one-time PIN for every drop-off (seq > 0) of the order.
+func insertRecipientPINs(ctx context.Context, tx *sql.Tx, orderID int64) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM courier_order_points WHERE order_id = ? AND seq > 0 ORDER BY seq`, orderID)
+	if err != nil {
+		return err
+	}
+	var pointIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		pointIDs = append(pointIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, pointID := range pointIDs {
+		pin, err := newRecipientPIN()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO courier_delivery_proofs (order_id, point_id, pin) VALUES (?,?,?)`, orderID, pointID, pin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newRecipientPIN() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(10000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%04d", n.Int64()), nil
+}
+
+// ListDeliveryProofs returns PINs and evidence for every drop-off of the order.
+func (r *OrdersRepo) ListDeliveryProofs(ctx context.Context, orderID int64) ([]DeliveryProof, error) {
+	rows, err := r.db.QueryContext(ctx, deliveryProofSelect+` WHERE p.order_id = ? ORDER BY pt.seq`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	proofs := make([]DeliveryProof, 0)
+	for rows.Next() {
+		p, err := scanDeliveryProof(rows)
+		if err != nil {
+			return nil, err
+		}
+		proofs = append(proofs, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return proofs, nil
+}
+
+// PendingDeliveryProofs counts drop-offs of the order that are not delivered yet.
+func (r *OrdersRepo) PendingDeliveryProofs(ctx context.Context, orderID int64) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM courier_delivery_proofs WHERE order_id = ? AND delivered_at IS NULL`, orderID).Scan(&n)
+	return n, err
+}
+
+// ConfirmDelivery stores evidence for a drop-off. With a PIN the code is checked and every
+// wrong attempt is counted; otherwise both photo and signature paths are required.
+func (r *OrdersRepo) ConfirmDelivery(ctx context.Context, orderID, pointID int64, ev DeliveryEvidence) (proof DeliveryProof, err error) {
+	method := ProofMethodPIN
+	if ev.PIN == "" {
+		if ev.PhotoPath == "" || ev.SignaturePath == "" {
+			return DeliveryProof{}, fmt.Errorf("photo and signature are required without pin")
+		}
+		method = ProofMethodPhotoSignature
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return DeliveryProof{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	proof, err = scanDeliveryProof(tx.QueryRowContext(ctx, deliveryProofSelect+` WHERE p.order_id = ? AND p.point_id = ? FOR UPDATE`, orderID, pointID))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return DeliveryProof{}, err
+	}
+	if proof.DeliveredAt.Valid {
+		err = ErrPointDelivered
+		return DeliveryProof{}, err
+	}
+
+	if method == ProofMethodPIN {
+		if pinErr := checkPIN(proof, ev.PIN); pinErr != nil {
+			if !errors.Is(pinErr, ErrPINMismatch) {
+				err = pinErr
+				return DeliveryProof{}, err
+			}
+			// Неудачная попытка фиксируется даже при отказе.
+			if _, err = tx.ExecContext(ctx, `UPDATE courier_delivery_proofs SET pin_attempts = pin_attempts + 1 WHERE id = ?`, proof.ID); err != nil {
+				return DeliveryProof{}, err
+			}
+			if err = tx.Commit(); err != nil {
+				return DeliveryProof{}, err
+			}
+			return DeliveryProof{}, ErrPINMismatch
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE courier_delivery_proofs
+        SET method = ?, photo_path = ?, signature_path = ?, lat = ?, lon = ?, courier_id = ?, delivered_at = ?
+        WHERE id = ?`,
+		method, nullIfEmpty(ev.PhotoPath), nullIfEmpty(ev.SignaturePath), ev.Lat, ev.Lon, ev.CourierID, ev.At, proof.ID); err != nil {
+		return DeliveryProof{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return DeliveryProof{}, err
+	}
+
+	proof.Method = sql.NullString{String: method, Valid: true}
+	proof.PhotoPath = sql.NullString{String: ev.PhotoPath, Valid: ev.PhotoPath != ""}
+	proof.SignaturePath = sql.NullString{String: ev.SignaturePath, Valid: ev.SignaturePath != ""}
+	proof.Lat = sql.NullFloat64{Float64: ev.Lat, Valid: true}
+	proof.Lon = sql.NullFloat64{Float64: ev.Lon, Valid: true}
+	proof.CourierID = sql.NullInt64{Int64: ev.CourierID, Valid: true}
+	proof.DeliveredAt = sql.NullTime{Time: ev.At, Valid: true}
+	return proof, nil
+}
+
+// checkPIN compares the recipient PIN. ErrPINMismatch means the attempt has to be counted;
+// once MaxPINAttempts are used even the right PIN is rejected with ErrPINLocked.
+func checkPIN(proof DeliveryProof, pin string) error {
+	if proof.PINAttempts >= MaxPINAttempts {
+		return ErrPINLocked
+	}
+	if subtle.ConstantTimeCompare([]byte(proof.PIN), []byte(pin)) != 1 {
+		return ErrPINMismatch
+	}
+	return nil
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
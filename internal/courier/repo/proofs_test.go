@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckPIN(t *testing.T) {
+	cases := []struct {
+		name     string
+		attempts int
+		pin      string
+		want     error
+	}{
+		{"correct pin", 0, "4821", nil},
+		{"correct pin after wrong attempts", MaxPINAttempts - 1, "4821", nil},
+		{"wrong pin", 0, "0000", ErrPINMismatch},
+		{"locked rejects wrong pin", MaxPINAttempts, "0000", ErrPINLocked},
+		{"locked rejects correct pin", MaxPINAttempts, "4821", ErrPINLocked},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkPIN(DeliveryProof{PIN: "4821", PINAttempts: tc.attempts}, tc.pin)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+// Пять неверных PIN подряд блокируют точку: каждая ошибка засчитывается, как в ConfirmDelivery.
+func TestCheckPINLockout(t *testing.T) {
+	proof := DeliveryProof{PIN: "4821"}
+	for i := 1; i <= MaxPINAttempts; i++ {
+		if err := checkPIN(proof, "1111"); !errors.Is(err, ErrPINMismatch) {
+			t.Fatalf("attempt %d: expected %v got %v", i, ErrPINMismatch, err)
+		}
+		proof.PINAttempts++
+	}
+	if proof.PINAttempts != 5 {
+		t.Fatalf("expected 5 counted attempts got %d", proof.PINAttempts)
+	}
+	if err := checkPIN(proof, "4821"); !errors.Is(err, ErrPINLocked) {
+		t.Fatalf("expected %v after lockout got %v", ErrPINLocked, err)
+	}
+}
@@ -0,0 +1,244 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Refund statuses.
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+	// RefundStatusProviderDone: провайдер вернул деньги, но возврат ещё не проведён у нас.
+	RefundStatusProviderDone = "provider_done"
+)
+
+var (
+	// ErrOrderNotRefundable indicates that the order was not paid online or is not finished yet.
+	ErrOrderNotRefundable = errors.New("courier: order not refundable")
+	// ErrRefundExceedsPayment indicates that refunds would exceed the paid amount.
+	ErrRefundExceedsPayment = errors.New("courier: refund exceeds paid amount")
+	// ErrRefundExtIDRequired indicates that the provider payment id is unknown for the order.
+	ErrRefundExtIDRequired = errors.New("courier: provider payment id required")
+)
+
+// Refund describes a full or partial refund of a courier order card payment.
+type Refund struct {
+	ID                int64
+	OrderID           int64
+	Amount            int
+	Reason            sql.NullString
+	IdempotencyKey    string
+	ProviderExtID     string
+	ChargeCourier     bool
+	CourierAdjustment int
+	Status            string
+	Error             sql.NullString
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+
+	// Состояние оплаты заказа на момент создания возврата.
+	PaidAmount     int
+	RefundedBefore int
+}
+
+// RefundsRepo persists courier order refunds.
+type RefundsRepo struct {
+	db *sql.DB
+}
+
+// NewRefundsRepo constructs a RefundsRepo.
+func NewRefundsRepo(db *sql.DB) *RefundsRepo {
+	return &RefundsRepo{db: db}
+}
+
+const refundColumns = `id, order_id, amount, reason, idempotency_key, provider_ext_id, charge_courier, courier_adjustment, status, error, created_at, updated_at`
+
+func scanRefund(row interface{ Scan(...interface{}) error }) (Refund, error) {
+	var rf Refund
+	err := row.Scan(&rf.ID, &rf.OrderID, &rf.Amount, &rf.Reason, &rf.IdempotencyKey, &rf.ProviderExtID, &rf.ChargeCourier,
+		&rf.CourierAdjustment, &rf.Status, &rf.Error, &rf.CreatedAt, &rf.UpdatedAt)
+	return rf, err
+}
+
+// Begin registers a pending refund. Amount <= 0 means full remaining amount. Repeated calls with the
+// same idempotency key return the existing refund (created=false); failed refunds are retried.
+// Pending and provider_done refunds are returned as is — the caller resumes them.
+func (r *RefundsRepo) Begin(ctx context.Context, refund Refund) (result Refund, created bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Refund{}, false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var (
+		price, refunded int
+		method, status  string
+	)
+	err = tx.QueryRowContext(ctx, `SELECT client_price, refunded_amount, payment_method, status FROM courier_orders WHERE id = ? FOR UPDATE`, refund.OrderID).
+		Scan(&price, &refunded, &method, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+		return Refund{}, false, err
+	}
+	if err != nil {
+		return Refund{}, false, err
+	}
+	if method != "online" || (status != StatusCompleted && status != StatusClosed) {
+		err = ErrOrderNotRefundable
+		return Refund{}, false, err
+	}
+
+	existing, err := scanRefund(tx.QueryRowContext(ctx, `SELECT `+refundColumns+` FROM courier_order_refunds WHERE order_id = ? AND idempotency_key = ? FOR UPDATE`,
+		refund.OrderID, refund.IdempotencyKey))
+	switch {
+	case err == nil && existing.Status != RefundStatusFailed:
+		existing.PaidAmount, existing.RefundedBefore = price, refunded
+		if err = tx.Commit(); err != nil {
+			return Refund{}, false, err
+		}
+		return existing, false, nil
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return Refund{}, false, err
+	}
+	retry := err == nil
+	err = nil
+
+	if refund.ProviderExtID == "" {
+		var extID sql.NullString
+		if err = tx.QueryRowContext(ctx, `SELECT provider_ext_id FROM courier_order_refunds WHERE order_id = ? ORDER BY id DESC LIMIT 1`, refund.OrderID).Scan(&extID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return Refund{}, false, err
+		}
+		err = nil
+		if !extID.Valid || extID.String == "" {
+			err = ErrRefundExtIDRequired
+			return Refund{}, false, err
+		}
+		refund.ProviderExtID = extID.String
+	}
+
+	var pending int
+	if err = tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount), 0) FROM courier_order_refunds WHERE order_id = ? AND status IN ('pending','provider_done')`, refund.OrderID).Scan(&pending); err != nil {
+		return Refund{}, false, err
+	}
+	remaining := price - refunded - pending
+	if refund.Amount <= 0 {
+		refund.Amount = remaining
+	}
+	if refund.Amount <= 0 || refund.Amount > remaining {
+		err = ErrRefundExceedsPayment
+		return Refund{}, false, err
+	}
+
+	refund.Status = RefundStatusPending
+	if retry {
+		refund.ID = existing.ID
+		_, err = tx.ExecContext(ctx, `UPDATE courier_order_refunds SET amount = ?, reason = ?, provider_ext_id = ?, charge_courier = ?, status = 'pending', error = NULL WHERE id = ?`,
+			refund.Amount, refund.Reason, refund.ProviderExtID, refund.ChargeCourier, refund.ID)
+		if err != nil {
+			return Refund{}, false, err
+		}
+	} else {
+		var res sql.Result
+		res, err = tx.ExecContext(ctx, `INSERT INTO courier_order_refunds (order_id, amount, reason, idempotency_key, provider_ext_id, charge_courier) VALUES (?, ?, ?, ?, ?, ?)`,
+			refund.OrderID, refund.Amount, refund.Reason, refund.IdempotencyKey, refund.ProviderExtID, refund.ChargeCourier)
+		if err != nil {
+			return Refund{}, false, err
+		}
+		if refund.ID, err = res.LastInsertId(); err != nil {
+			return Refund{}, false, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return Refund{}, false, err
+	}
+	refund.PaidAmount, refund.RefundedBefore = price, refunded
+	return refund, true, nil
+}
+
+// Complete marks refund succeeded, increases refunded amount of the order and adjusts courier balance.
+func (r *RefundsRepo) Complete(ctx context.Context, refundID, courierID int64, courierAdjustment int) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var (
+		orderID int64
+		amount  int
+	)
+	if err = tx.QueryRowContext(ctx, `SELECT order_id, amount FROM courier_order_refunds WHERE id = ? AND status IN ('pending','provider_done') FOR UPDATE`, refundID).Scan(&orderID, &amount); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNotFound
+		}
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE courier_order_refunds SET status = 'succeeded', courier_adjustment = ? WHERE id = ?`, courierAdjustment, refundID); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE courier_orders SET refunded_amount = refunded_amount + ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, amount, orderID); err != nil {
+		return err
+	}
+	if courierAdjustment != 0 && courierID > 0 {
+		if _, err = tx.ExecContext(ctx, `UPDATE couriers SET balance = balance + ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, courierAdjustment, courierID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// MarkProviderDone records that the provider has returned the money, so a replay completes the refund
+// instead of calling the provider again.
+func (r *RefundsRepo) MarkProviderDone(ctx context.Context, refundID int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE courier_order_refunds SET status = 'provider_done' WHERE id = ? AND status = 'pending'`, refundID)
+	return err
+}
+
+// Fail marks pending refund as failed.
+func (r *RefundsRepo) Fail(ctx context.Context, refundID int64, reason string) error {
+	if len(reason) > 255 {
+		reason = reason[:255]
+	}
+	_, err := r.db.ExecContext(ctx, `UPDATE courier_order_refunds SET status = 'failed', error = ? WHERE id = ? AND status = 'pending'`, reason, refundID)
+	return err
+}
+
+// Get loads refund by id.
+func (r *RefundsRepo) Get(ctx context.Context, id int64) (Refund, error) {
+	rf, err := scanRefund(r.db.QueryRowContext(ctx, `SELECT `+refundColumns+` FROM courier_order_refunds WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Refund{}, ErrNotFound
+	}
+	return rf, err
+}
+
+// ListByOrder returns refunds for the order, newest first.
+func (r *RefundsRepo) ListByOrder(ctx context.Context, orderID int64) ([]Refund, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+refundColumns+` FROM courier_order_refunds WHERE order_id = ? ORDER BY id DESC`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []Refund
+	for rows.Next() {
+		rf, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, rf)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,208 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDeliveryState is returned when the order is not at the failed-delivery step the action needs.
+var ErrDeliveryState = errors.New("courier: order is not in a suitable delivery state")
+
+// Return — неудачная доставка: попытка вручения, платное ожидание и обратный путь к отправителю.
+type Return struct {
+	AttemptedAt sql.NullTime
+	WaitingFee  int
+	StartedAt   sql.NullTime
+	DistanceM   int
+	Fee         int
+	ArrivedAt   sql.NullTime
+	ReturnedAt  sql.NullTime
+}
+
+// Surcharge is what the sender owes on top of the order price.
+func (r Return) Surcharge() int { return r.WaitingFee + r.Fee }
+
+// ReturnReportRow is one returned or returning order in the admin report.
+type ReturnReportRow struct {
+	OrderID     int64
+	SenderID    int64
+	CourierID   sql.NullInt64
+	Status      string
+	ClientPrice int
+	Return      Return
+	CreatedAt   time.Time
+}
+
+// lockDeliveryStep locks the order of the courier and checks that it is in one of the allowed statuses.
+func lockDeliveryStep(ctx context.Context, tx *sql.Tx, orderID, courierID int64, allowed ...string) (string, error) {
+	var (
+		status string
+		owner  sql.NullInt64
+	)
+	err := tx.QueryRowContext(ctx, `SELECT status, courier_id FROM courier_orders WHERE id = ? FOR UPDATE`, orderID).Scan(&status, &owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !owner.Valid || owner.Int64 != courierID {
+		return "", ErrNotFound
+	}
+	for _, st := range allowed {
+		if st == status {
+			return status, nil
+		}
+	}
+	return status, ErrDeliveryState
+}
+
+// moveDeliveryStep runs fn on a locked order in one of the allowed statuses, switches it to next and records history.
+func (r *OrdersRepo) moveDeliveryStep(ctx context.Context, orderID, courierID int64, next string, at time.Time, allowed []string, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = lockDeliveryStep(ctx, tx, orderID, courierID, allowed...); err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE courier_orders SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, next, orderID); err != nil {
+		return err
+	}
+	if err = insertStatusHistory(ctx, tx, StatusHistoryEntry{OrderID: orderID, Status: next, CreatedAt: at}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MarkDeliveryAttempted records that the courier reached the recipient and could not hand the parcel over.
+func (r *OrdersRepo) MarkDeliveryAttempted(ctx context.Context, orderID, courierID int64, at time.Time) error {
+	return r.moveDeliveryStep(ctx, orderID, courierID, StatusDeliveryAttempted, at, []string{StatusInProgress}, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `UPDATE courier_orders SET delivery_attempted_at = ? WHERE id = ?`, at, orderID)
+		return err
+	})
+}
+
+// StartRecipientWaiting switches the order to paid waiting at the recipient.
+func (r *OrdersRepo) StartRecipientWaiting(ctx context.Context, orderID, courierID int64, at time.Time) error {
+	return r.moveDeliveryStep(ctx, orderID, courierID, StatusWaitingRecipient, at, []string{StatusDeliveryAttempted}, func(*sql.Tx) error {
+		return nil
+	})
+}
+
+// ResumeDelivery puts the order back in progress once the recipient shows up, adding the waiting fee.
+func (r *OrdersRepo) ResumeDelivery(ctx context.Context, orderID, courierID int64, waitingFee int, at time.Time) error {
+	allowed := []string{StatusDeliveryAttempted, StatusWaitingRecipient}
+	return r.moveDeliveryStep(ctx, orderID, courierID, StatusInProgress, at, allowed, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `UPDATE courier_orders SET waiting_fee = waiting_fee + ? WHERE id = ?`, waitingFee, orderID)
+		return err
+	})
+}
+
+// StartReturn sends the parcel back to the sender with the waiting fee and the priced return leg.
+func (r *OrdersRepo) StartReturn(ctx context.Context, orderID, courierID int64, waitingFee, distanceM, fee int, at time.Time) error {
+	allowed := []string{StatusDeliveryAttempted, StatusWaitingRecipient}
+	return r.moveDeliveryStep(ctx, orderID, courierID, StatusReturning, at, allowed, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `UPDATE courier_orders
+            SET waiting_fee = waiting_fee + ?, return_started_at = ?, return_distance_m = ?, return_fee = ?
+            WHERE id = ?`, waitingFee, at, distanceM, fee, orderID)
+		return err
+	})
+}
+
+// MarkReturnArrived records that the courier brought the parcel back; the sender still has to confirm it.
+func (r *OrdersRepo) MarkReturnArrived(ctx context.Context, orderID, courierID int64, at time.Time) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = lockDeliveryStep(ctx, tx, orderID, courierID, StatusReturning); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE courier_orders SET return_arrived_at = COALESCE(return_arrived_at, ?), updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		at, orderID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ConfirmReturned closes the return once the sender has the parcel back.
+func (r *OrdersRepo) ConfirmReturned(ctx context.Context, orderID, senderID int64, at time.Time) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var (
+		status string
+		owner  int64
+	)
+	err = tx.QueryRowContext(ctx, `SELECT status, sender_id FROM courier_orders WHERE id = ? FOR UPDATE`, orderID).Scan(&status, &owner)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && owner != senderID) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != StatusReturning {
+		return ErrDeliveryState
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE courier_orders
+        SET status = ?, return_arrived_at = COALESCE(return_arrived_at, ?), returned_at = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE id = ?`, StatusReturned, at, at, orderID); err != nil {
+		return err
+	}
+	if err = insertStatusHistory(ctx, tx, StatusHistoryEntry{OrderID: orderID, Status: StatusReturned, CreatedAt: at}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReturnsReport lists orders with a failed delivery attempted within [from, to), newest first.
+func (r *OrdersRepo) ReturnsReport(ctx context.Context, from, to time.Time) ([]ReturnReportRow, error) {
+	query := fmt.Sprintf(`SELECT id, sender_id, courier_id, status, client_price,
+            delivery_attempted_at, waiting_fee, return_started_at, return_distance_m, return_fee, return_arrived_at, returned_at, created_at
+        FROM courier_orders
+        WHERE delivery_attempted_at >= ? AND delivery_attempted_at < ? AND status IN (%s)
+        ORDER BY delivery_attempted_at DESC`, placeholders(4))
+	rows, err := r.db.QueryContext(ctx, query, from, to, StatusDeliveryAttempted, StatusWaitingRecipient, StatusReturning, StatusReturned)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]ReturnReportRow, 0)
+	for rows.Next() {
+		var row ReturnReportRow
+		if err := rows.Scan(&row.OrderID, &row.SenderID, &row.CourierID, &row.Status, &row.ClientPrice,
+			&row.Return.AttemptedAt, &row.Return.WaitingFee, &row.Return.StartedAt, &row.Return.DistanceM,
+			&row.Return.Fee, &row.Return.ArrivedAt, &row.Return.ReturnedAt, &row.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, row)
+	}
+	return items, rows.Err()
+}
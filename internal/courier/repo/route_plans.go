@@ -0,0 +1,176 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCourierAtCapacity is returned when the assignment would overflow the courier's batch.
+var ErrCourierAtCapacity = errors.New("courier has no free capacity for this order")
+
+// CourierLoad — сколько активных заказов и какой общий вес сейчас везёт курьер.
+type CourierLoad struct {
+	Orders   int
+	WeightKg float64
+}
+
+// CourierLimit caps the courier's batch on assignment; zero fields disable the check.
+type CourierLimit struct {
+	MaxOrders   int
+	MaxWeightKg float64
+}
+
+// Fits reports whether one more order of weightKg fits on top of the current load.
+func (l CourierLimit) Fits(load CourierLoad, weightKg float64) bool {
+	if l.MaxOrders > 0 && load.Orders >= l.MaxOrders {
+		return false
+	}
+	if l.MaxWeightKg > 0 && load.WeightKg+weightKg > l.MaxWeightKg {
+		return false
+	}
+	return true
+}
+
+// checkCourierLimitTx блокирует строку курьера, чтобы параллельные назначения одного курьера
+// выстраивались в очередь, и внутри транзакции перечитывает его текущую загрузку.
+func checkCourierLimitTx(ctx context.Context, tx *sql.Tx, courierID, orderID int64, weightKg float64, limit CourierLimit) error {
+	if limit == (CourierLimit{}) {
+		return nil
+	}
+	var id int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM couriers WHERE id = ? FOR UPDATE`, courierID).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	args := make([]interface{}, 0, len(activeStatuses)+2)
+	args = append(args, courierID, orderID)
+	for _, st := range activeStatuses {
+		args = append(args, st)
+	}
+	var load CourierLoad
+	query := fmt.Sprintf(`SELECT COUNT(*), COALESCE(SUM(parcel_weight_kg), 0)
+        FROM courier_orders
+        WHERE courier_id = ? AND id <> ? AND status IN (%s)`, placeholders(len(activeStatuses)))
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&load.Orders, &load.WeightKg); err != nil {
+		return err
+	}
+	if !limit.Fits(load, weightKg) {
+		return ErrCourierAtCapacity
+	}
+	return nil
+}
+
+// RouteStop is one stop of the courier's combined route over batched orders.
+type RouteStop struct {
+	Position   int
+	OrderID    int64
+	PointID    int64
+	Kind       string
+	EtaSeconds int
+	PlannedAt  time.Time
+}
+
+// ActiveLoads returns the active order count and parcel weight per courier; couriers
+// without active orders are absent from the map.
+func (r *OrdersRepo) ActiveLoads(ctx context.Context, courierIDs []int64) (map[int64]CourierLoad, error) {
+	loads := make(map[int64]CourierLoad, len(courierIDs))
+	if len(courierIDs) == 0 {
+		return loads, nil
+	}
+	args := make([]interface{}, 0, len(courierIDs)+len(activeStatuses))
+	for _, id := range courierIDs {
+		args = append(args, id)
+	}
+	for _, st := range activeStatuses {
+		args = append(args, st)
+	}
+	query := fmt.Sprintf(`SELECT courier_id, COUNT(*), COALESCE(SUM(parcel_weight_kg), 0)
+        FROM courier_orders
+        WHERE courier_id IN (%s) AND status IN (%s)
+        GROUP BY courier_id`, placeholders(len(courierIDs)), placeholders(len(activeStatuses)))
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id   int64
+			load CourierLoad
+		)
+		if err := rows.Scan(&id, &load.Orders, &load.WeightKg); err != nil {
+			return nil, err
+		}
+		loads[id] = load
+	}
+	return loads, rows.Err()
+}
+
+// ListActiveByCourier returns every active order of the courier, oldest first.
+func (r *OrdersRepo) ListActiveByCourier(ctx context.Context, courierID int64) ([]Order, error) {
+	args := make([]interface{}, 0, len(activeStatuses)+1)
+	args = append(args, courierID)
+	for _, st := range activeStatuses {
+		args = append(args, st)
+	}
+	suffix := fmt.Sprintf(`
+	WHERE o.courier_id = ? AND o.status IN (%s)
+	ORDER BY o.created_at ASC`, placeholders(len(activeStatuses)))
+	orders, err := r.listOrdersWithRelations(ctx, suffix, args...)
+	if err != nil {
+		return nil, err
+	}
+	if orders == nil {
+		orders = []Order{}
+	}
+	return orders, nil
+}
+
+// ReplaceRoutePlan stores a freshly optimised route of the courier instead of the previous one.
+func (r *OrdersRepo) ReplaceRoutePlan(ctx context.Context, courierID int64, stops []RouteStop, plannedAt time.Time) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM courier_route_stops WHERE courier_id = ?`, courierID); err != nil {
+		return err
+	}
+	for i, s := range stops {
+		if _, err = tx.ExecContext(ctx, `INSERT INTO courier_route_stops (courier_id, position, order_id, point_id, kind, eta_seconds, planned_at)
+            VALUES (?,?,?,?,?,?,?)`, courierID, i, s.OrderID, s.PointID, s.Kind, s.EtaSeconds, plannedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RoutePlan returns the stored route of the courier in visiting order.
+func (r *OrdersRepo) RoutePlan(ctx context.Context, courierID int64) ([]RouteStop, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT position, order_id, point_id, kind, eta_seconds, planned_at
+        FROM courier_route_stops WHERE courier_id = ? ORDER BY position`, courierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stops := make([]RouteStop, 0)
+	for rows.Next() {
+		var s RouteStop
+		if err := rows.Scan(&s.Position, &s.OrderID, &s.PointID, &s.Kind, &s.EtaSeconds, &s.PlannedAt); err != nil {
+			return nil, err
+		}
+		stops = append(stops, s)
+	}
+	return stops, rows.Err()
+}
@@ -0,0 +1,27 @@
+package repo
+
+import "testing"
+
+func TestCourierLimitFits(t *testing.T) {
+	limit := CourierLimit{MaxOrders: 3, MaxWeightKg: 20}
+	cases := []struct {
+		name     string
+		limit    CourierLimit
+		load     CourierLoad
+		weightKg float64
+		want     bool
+	}{
+		{"empty courier", limit, CourierLoad{}, 5, true},
+		{"room left", limit, CourierLoad{Orders: 2, WeightKg: 10}, 10, true},
+		{"orders at capacity", limit, CourierLoad{Orders: 3, WeightKg: 1}, 1, false},
+		{"too heavy on top of the batch", limit, CourierLoad{Orders: 1, WeightKg: 15}, 6, false},
+		{"no limit", CourierLimit{}, CourierLoad{Orders: 10, WeightKg: 500}, 100, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.limit.Fits(tc.load, tc.weightKg); got != tc.want {
+				t.Fatalf("expected %v got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotScheduled is returned when the order has no delivery windows.
+	ErrNotScheduled = errors.New("courier: order is not scheduled")
+	// ErrAlreadyReserved is returned when another courier has reserved the order.
+	ErrAlreadyReserved = errors.New("courier: order already reserved")
+	// ErrReservationClosed is returned once dispatch of the order has started.
+	ErrReservationClosed = errors.New("courier: order is no longer open for reservation")
+)
+
+// TimeWindow is a [From, To] interval; both ends are set or neither.
+type TimeWindow struct {
+	From sql.NullTime
+	To   sql.NullTime
+}
+
+// Set reports whether the window is given.
+func (w TimeWindow) Set() bool { return w.From.Valid && w.To.Valid }
+
+// Schedule — окна забора и выдачи заказа ко времени, бронь курьера и отметка о риске опоздания.
+type Schedule struct {
+	Pickup            TimeWindow
+	Dropoff           TimeWindow
+	ReservedCourierID sql.NullInt64
+	ReservedAt        sql.NullTime
+	LateRiskAt        sql.NullTime
+}
+
+// Scheduled reports whether the order is placed for a later time.
+func (s Schedule) Scheduled() bool { return s.Pickup.Set() || s.Dropoff.Set() }
+
+// ReserveScheduled books a scheduled order for the courier before its dispatch starts.
+// Reserving an order twice by the same courier is a no-op.
+func (r *OrdersRepo) ReserveScheduled(ctx context.Context, orderID, courierID int64, now time.Time) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var (
+		status                  string
+		pickupFrom, dropoffFrom sql.NullTime
+		reservedBy              sql.NullInt64
+		startsAt                sql.NullTime
+		dispatchState           sql.NullString
+	)
+	err = tx.QueryRowContext(ctx, `SELECT o.status, o.pickup_from, o.dropoff_from, o.reserved_courier_id, d.starts_at, d.state
+        FROM courier_orders o
+        LEFT JOIN courier_order_dispatch d ON d.order_id = o.id
+        WHERE o.id = ? FOR UPDATE`, orderID).Scan(&status, &pickupFrom, &dropoffFrom, &reservedBy, &startsAt, &dispatchState)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case !pickupFrom.Valid && !dropoffFrom.Valid:
+		return ErrNotScheduled
+	case reservedBy.Valid && reservedBy.Int64 == courierID:
+		return tx.Commit()
+	case reservedBy.Valid:
+		return ErrAlreadyReserved
+	case status != StatusNew || dispatchState.String != "searching" || !startsAt.Valid || !startsAt.Time.After(now):
+		return ErrReservationClosed
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE courier_orders SET reserved_courier_id = ?, reserved_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		courierID, now, orderID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReleaseReservation drops the courier's reservation while the order still waits for dispatch.
+func (r *OrdersRepo) ReleaseReservation(ctx context.Context, orderID, courierID int64) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE courier_orders SET reserved_courier_id = NULL, reserved_at = NULL, updated_at = CURRENT_TIMESTAMP
+        WHERE id = ? AND reserved_courier_id = ? AND status = ?`, orderID, courierID, StatusNew)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListReservable returns scheduled orders nobody has reserved yet, soonest dispatch first.
+func (r *OrdersRepo) ListReservable(ctx context.Context, now time.Time, limit, offset int) ([]Order, error) {
+	suffix := `
+	JOIN courier_order_dispatch d ON d.order_id = o.id
+	WHERE o.status = ? AND o.reserved_courier_id IS NULL AND d.state = 'searching' AND d.starts_at > ?
+	ORDER BY d.starts_at
+	LIMIT ? OFFSET ?`
+	return r.listOrdersWithRelations(ctx, suffix, StatusNew, now, limit, offset)
+}
+
+// ListReservedByCourier returns orders the courier has reserved and that are not dispatched yet.
+func (r *OrdersRepo) ListReservedByCourier(ctx context.Context, courierID int64) ([]Order, error) {
+	suffix := `
+	WHERE o.reserved_courier_id = ? AND o.status = ?
+	ORDER BY COALESCE(o.pickup_from, o.dropoff_from)`
+	return r.listOrdersWithRelations(ctx, suffix, courierID, StatusNew)
+}
+
+// CouriersWithScheduledOrders lists couriers carrying scheduled orders not flagged as late yet.
+func (r *OrdersRepo) CouriersWithScheduledOrders(ctx context.Context) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT courier_id FROM courier_orders
+        WHERE courier_id IS NOT NULL AND status IN (?,?,?) AND late_risk_at IS NULL
+          AND (pickup_to IS NOT NULL OR dropoff_to IS NOT NULL)`,
+		StatusAccepted, StatusWaitingFree, StatusInProgress)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MarkLateRisk flags the order as unlikely to meet its window; false means it was flagged before.
+func (r *OrdersRepo) MarkLateRisk(ctx context.Context, orderID int64, at time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE courier_orders SET late_risk_at = ? WHERE id = ? AND late_risk_at IS NULL`, at, orderID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
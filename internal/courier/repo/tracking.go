@@ -0,0 +1,163 @@
+package repo
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Recipient notification kinds.
+const (
+	TrackingNotifyPickup   = "pickup"
+	TrackingNotifyApproach = "approach"
+)
+
+// TrackingStop — ссылка отслеживания получателя вместе с точкой и состоянием заказа.
+type TrackingStop struct {
+	LinkID           int64
+	Token            string
+	Phone            string
+	OrderID          int64
+	PointID          int64
+	Seq              int
+	Address          string
+	Lat              float64
+	Lon              float64
+	OrderStatus      string
+	CourierID        sql.NullInt64
+	Delivered        bool
+	DeliveredAt      sql.NullTime
+	PickupNotified   bool
+	ApproachNotified bool
+}
+
+const trackingStopSelect = `SELECT l.id, l.token, l.phone, l.order_id, l.point_id, pt.seq, pt.address, pt.lat, pt.lon,
+        o.status, o.courier_id, p.delivered_at, l.pickup_notified_at IS NOT NULL, l.approach_notified_at IS NOT NULL
+    FROM courier_tracking_links l
+    JOIN courier_order_points pt ON pt.id = l.point_id
+    JOIN courier_orders o ON o.id = l.order_id
+    LEFT JOIN courier_delivery_proofs p ON p.point_id = l.point_id`
+
+func scanTrackingStop(row interface{ Scan(...interface{}) error }) (TrackingStop, error) {
+	var t TrackingStop
+	err := row.Scan(&t.LinkID, &t.Token, &t.Phone, &t.OrderID, &t.PointID, &t.Seq, &t.Address, &t.Lat, &t.Lon,
+		&t.OrderStatus, &t.CourierID, &t.DeliveredAt, &t.PickupNotified, &t.ApproachNotified)
+	t.Delivered = t.DeliveredAt.Valid
+	return t, err
+}
+
+// insertTrackingLinks issues a tracking token for every drop-off that has a recipient phone.
+func insertTrackingLinks(ctx context.Context, tx *sql.Tx, orderID int64) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, phone FROM courier_order_points WHERE order_id = ? AND seq > 0 ORDER BY seq`, orderID)
+	if err != nil {
+		return err
+	}
+	type target struct {
+		pointID int64
+		phone   string
+	}
+	var targets []target
+	for rows.Next() {
+		var (
+			id    int64
+			phone sql.NullString
+		)
+		if err := rows.Scan(&id, &phone); err != nil {
+			rows.Close()
+			return err
+		}
+		if p := strings.TrimSpace(phone.String); p != "" {
+			targets = append(targets, target{pointID: id, phone: p})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		token, err := newTrackingToken()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO courier_tracking_links (order_id, point_id, token, phone) VALUES (?,?,?,?)`,
+			orderID, t.pointID, token, t.phone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTrackingToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// TrackingByToken resolves a public tracking token.
+func (r *OrdersRepo) TrackingByToken(ctx context.Context, token string) (TrackingStop, error) {
+	t, err := scanTrackingStop(r.db.QueryRowContext(ctx, trackingStopSelect+` WHERE l.token = ?`, token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return TrackingStop{}, ErrNotFound
+	}
+	return t, err
+}
+
+// TrackingStopsByOrder returns tracking links of the order in drop-off order.
+func (r *OrdersRepo) TrackingStopsByOrder(ctx context.Context, orderID int64) ([]TrackingStop, error) {
+	return r.listTrackingStops(ctx, ` WHERE l.order_id = ? ORDER BY pt.seq`, orderID)
+}
+
+// ActiveTrackingStops returns tracking links of every active order of the courier.
+func (r *OrdersRepo) ActiveTrackingStops(ctx context.Context, courierID int64) ([]TrackingStop, error) {
+	args := make([]interface{}, 0, len(activeStatuses)+1)
+	args = append(args, courierID)
+	for _, st := range activeStatuses {
+		args = append(args, st)
+	}
+	return r.listTrackingStops(ctx, fmt.Sprintf(` WHERE o.courier_id = ? AND o.status IN (%s) ORDER BY l.order_id, pt.seq`,
+		placeholders(len(activeStatuses))), args...)
+}
+
+func (r *OrdersRepo) listTrackingStops(ctx context.Context, suffix string, args ...interface{}) ([]TrackingStop, error) {
+	rows, err := r.db.QueryContext(ctx, trackingStopSelect+suffix, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stops := make([]TrackingStop, 0)
+	for rows.Next() {
+		t, err := scanTrackingStop(rows)
+		if err != nil {
+			return nil, err
+		}
+		stops = append(stops, t)
+	}
+	return stops, rows.Err()
+}
+
+// MarkTrackingNotified records that the recipient got the notification; false means it was
+// already sent, so concurrent callers notify only once.
+func (r *OrdersRepo) MarkTrackingNotified(ctx context.Context, linkID int64, kind string, at time.Time) (bool, error) {
+	column := "pickup_notified_at"
+	if kind == TrackingNotifyApproach {
+		column = "approach_notified_at"
+	}
+	res, err := r.db.ExecContext(ctx, `UPDATE courier_tracking_links SET `+column+` = ? WHERE id = ? AND `+column+` IS NULL`, at, linkID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -90,3 +90,10 @@ func (r *UsersRepo) Get(ctx context.Context, id int64) (User, error) {
 	}
 	return u, nil
 }
+
+// CityName returns the name of the city from the cities directory.
+func (r *UsersRepo) CityName(ctx context.Context, cityID int64) (string, error) {
+	var name string
+	err := r.db.QueryRowContext(ctx, `SELECT name FROM cities WHERE id = ?`, cityID).Scan(&name)
+	return name, err
+}
@@ -0,0 +1,228 @@
+package routeplan
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	taxigeo "naimuBack/internal/taxi/geo"
+)
+
+// Stop kinds.
+const (
+	KindPickup  = "pickup"
+	KindDropoff = "dropoff"
+	// KindReturn is the way back to the sender's pickup point after a failed delivery.
+	KindReturn = "return"
+)
+
+// MaxRoutedStops limits how many stops get a full ETA matrix from the routing provider;
+// bigger batches are ordered by straight-line estimates to keep the number of calls sane.
+const MaxRoutedStops = 6
+
+// estimateSpeedMps is the average city speed used when the router is unavailable.
+const estimateSpeedMps = 7.0
+
+// routerConcurrency caps parallel RouteMatrix calls while building the matrix.
+const routerConcurrency = 4
+
+// routedNeighbours — сколько ближайших (по прямой) точек маршрутизируется из каждого узла.
+// Дальние переходы оптимизатор почти не выбирает, поэтому для них хватает оценки, а число
+// вызовов роутера ограничено 2*(MaxRoutedStops+1) вместо полного квадрата.
+const routedNeighbours = 2
+
+// Stop — точка объединённого маршрута курьера: забор или выдача по заказу.
+type Stop struct {
+	OrderID int64
+	PointID int64
+	Kind    string
+	Lon     float64
+	Lat     float64
+}
+
+// PlannedStop is a stop in visiting order with the cumulative ETA from the courier's position.
+type PlannedStop struct {
+	Stop
+	EtaSeconds int
+}
+
+// Plan orders stops starting from the courier's position. Drop-offs of an order always come
+// after its pickup when the pickup is still in the list.
+func Plan(ctx context.Context, router taxigeo.Router, startLon, startLat float64, stops []Stop) []PlannedStop {
+	if len(stops) == 0 {
+		return nil
+	}
+	cost := BuildMatrix(ctx, router, startLon, startLat, stops)
+	order := Optimize(cost, stops)
+
+	planned := make([]PlannedStop, 0, len(order))
+	prev, eta := 0, 0
+	for _, idx := range order {
+		eta += cost[prev][idx+1]
+		planned = append(planned, PlannedStop{Stop: stops[idx], EtaSeconds: eta})
+		prev = idx + 1
+	}
+	return planned
+}
+
+// BuildMatrix returns travel seconds between nodes; node 0 is the start, node i+1 is stops[i].
+func BuildMatrix(ctx context.Context, router taxigeo.Router, startLon, startLat float64, stops []Stop) [][]int {
+	n := len(stops) + 1
+	lons := make([]float64, n)
+	lats := make([]float64, n)
+	lons[0], lats[0] = startLon, startLat
+	for i, s := range stops {
+		lons[i+1], lats[i+1] = s.Lon, s.Lat
+	}
+
+	cost := make([][]int, n)
+	for i := range cost {
+		cost[i] = make([]int, n)
+		for j := range cost[i] {
+			if i != j {
+				cost[i][j] = estimateSeconds(lons[i], lats[i], lons[j], lats[j])
+			}
+		}
+	}
+	if router == nil || len(stops) > MaxRoutedStops {
+		return cost
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, routerConcurrency)
+	for i := 0; i < n; i++ {
+		for _, j := range nearestNodes(cost[i], i, routedNeighbours) {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i, j int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if _, secs, err := router.RouteMatrix(ctx, lons[i], lats[i], lons[j], lats[j]); err == nil && secs > 0 {
+					cost[i][j] = secs
+				}
+			}(i, j)
+		}
+	}
+	wg.Wait()
+	return cost
+}
+
+// nearestNodes returns up to k stop nodes closest to node from by the estimated row.
+// В старт никто не возвращается — узел 0 не рассматривается.
+func nearestNodes(row []int, from, k int) []int {
+	nodes := make([]int, 0, len(row))
+	for j := 1; j < len(row); j++ {
+		if j != from {
+			nodes = append(nodes, j)
+		}
+	}
+	sort.SliceStable(nodes, func(a, b int) bool { return row[nodes[a]] < row[nodes[b]] })
+	if len(nodes) > k {
+		nodes = nodes[:k]
+	}
+	return nodes
+}
+
+// Optimize returns indexes of stops in visiting order: nearest feasible neighbour first,
+// then single-stop relocations while they shorten the route and keep pickups first.
+func Optimize(cost [][]int, stops []Stop) []int {
+	pickupOf := make(map[int64]int)
+	for i, s := range stops {
+		if s.Kind == KindPickup {
+			pickupOf[s.OrderID] = i
+		}
+	}
+	feasible := func(seq []int) bool {
+		seen := make(map[int]bool, len(seq))
+		for _, idx := range seq {
+			s := stops[idx]
+			if s.Kind == KindDropoff {
+				if p, ok := pickupOf[s.OrderID]; ok && !seen[p] {
+					return false
+				}
+			}
+			seen[idx] = true
+		}
+		return true
+	}
+	total := func(seq []int) int {
+		sum, prev := 0, 0
+		for _, idx := range seq {
+			sum += cost[prev][idx+1]
+			prev = idx + 1
+		}
+		return sum
+	}
+
+	// Жадный старт: ближайшая допустимая точка.
+	seq := make([]int, 0, len(stops))
+	visited := make([]bool, len(stops))
+	prev := 0
+	for len(seq) < len(stops) {
+		best := -1
+		for i, s := range stops {
+			if visited[i] {
+				continue
+			}
+			if s.Kind == KindDropoff {
+				if p, ok := pickupOf[s.OrderID]; ok && !visited[p] {
+					continue
+				}
+			}
+			if best < 0 || cost[prev][i+1] < cost[prev][best+1] {
+				best = i
+			}
+		}
+		visited[best] = true
+		seq = append(seq, best)
+		prev = best + 1
+	}
+
+	bestCost := total(seq)
+	candidate := make([]int, len(seq))
+	for pass := 0; pass < 50; pass++ {
+		improved := false
+		for from := range seq {
+			for to := range seq {
+				if from == to {
+					continue
+				}
+				relocate(candidate, seq, from, to)
+				if c := total(candidate); c < bestCost && feasible(candidate) {
+					copy(seq, candidate)
+					bestCost = c
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return seq
+}
+
+// relocate writes src into dst with the element at from moved to position to.
+func relocate(dst, src []int, from, to int) {
+	moved := src[from]
+	k := 0
+	for i, v := range src {
+		if i == from {
+			continue
+		}
+		if k == to {
+			dst[k] = moved
+			k++
+		}
+		dst[k] = v
+		k++
+	}
+	if k == to {
+		dst[k] = moved
+	}
+}
+
+func estimateSeconds(lon1, lat1, lon2, lat2 float64) int {
+	return int(math.Round(taxigeo.DistanceMeters(lon1, lat1, lon2, lat2) / estimateSpeedMps))
+}
@@ -0,0 +1,174 @@
+package routeplan
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type stubRouter struct {
+	mu    sync.Mutex
+	calls int
+	secs  int
+	err   error
+}
+
+func (r *stubRouter) RouteMatrix(ctx context.Context, fromLon, fromLat, toLon, toLat float64) (int, int, error) {
+	r.mu.Lock()
+	r.calls++
+	r.mu.Unlock()
+	return 0, r.secs, r.err
+}
+
+func TestRelocate(t *testing.T) {
+	src := []int{0, 1, 2, 3}
+	cases := []struct {
+		name     string
+		from, to int
+		want     []int
+	}{
+		{"to front", 2, 0, []int{2, 0, 1, 3}},
+		{"to end", 0, 3, []int{1, 2, 3, 0}},
+		{"last to end", 3, 3, []int{0, 1, 2, 3}},
+		{"forward", 1, 2, []int{0, 2, 1, 3}},
+		{"backward", 3, 1, []int{0, 3, 1, 2}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := make([]int, len(src))
+			relocate(dst, src, tc.from, tc.to)
+			if !reflect.DeepEqual(dst, tc.want) {
+				t.Fatalf("expected %v got %v", tc.want, dst)
+			}
+		})
+	}
+}
+
+// lineCost — узлы на прямой: стоимость равна разнице координат.
+func lineCost(pos []int) [][]int {
+	cost := make([][]int, len(pos))
+	for i := range pos {
+		cost[i] = make([]int, len(pos))
+		for j := range pos {
+			d := pos[i] - pos[j]
+			if d < 0 {
+				d = -d
+			}
+			cost[i][j] = d
+		}
+	}
+	return cost
+}
+
+func TestOptimizeKeepsPickupBeforeDropoff(t *testing.T) {
+	cases := []struct {
+		name  string
+		pos   []int // node 0 is the start
+		stops []Stop
+		want  []int
+	}{
+		{
+			name:  "dropoff nearer than its pickup",
+			pos:   []int{0, 10, 1},
+			stops: []Stop{{OrderID: 1, Kind: KindPickup}, {OrderID: 1, Kind: KindDropoff}},
+			want:  []int{0, 1},
+		},
+		{
+			name: "two orders interleaved",
+			pos:  []int{0, 5, 1, 2, 6},
+			stops: []Stop{
+				{OrderID: 1, Kind: KindPickup},
+				{OrderID: 1, Kind: KindDropoff},
+				{OrderID: 2, Kind: KindPickup},
+				{OrderID: 2, Kind: KindDropoff},
+			},
+			want: []int{2, 0, 3, 1},
+		},
+		{
+			name:  "picked up order delivers by distance",
+			pos:   []int{0, 8, 3},
+			stops: []Stop{{OrderID: 1, Kind: KindDropoff}, {OrderID: 2, Kind: KindDropoff}},
+			want:  []int{1, 0},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Optimize(lineCost(tc.pos), tc.stops)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected %v got %v", tc.want, got)
+			}
+			seen := make(map[int64]bool)
+			for _, idx := range got {
+				s := tc.stops[idx]
+				if s.Kind == KindPickup {
+					seen[s.OrderID] = true
+				}
+				if s.Kind == KindDropoff && !seen[s.OrderID] {
+					for _, other := range tc.stops {
+						if other.OrderID == s.OrderID && other.Kind == KindPickup {
+							t.Fatalf("drop-off of order %d before its pickup: %v", s.OrderID, got)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBuildMatrix(t *testing.T) {
+	few := []Stop{{Lon: 71.41, Lat: 51.13}, {Lon: 71.43, Lat: 51.14}}
+	many := make([]Stop, MaxRoutedStops+1)
+	for i := range many {
+		many[i] = Stop{Lon: 71.40 + float64(i)*0.01, Lat: 51.13}
+	}
+	full := many[1 : MaxRoutedStops+1]
+
+	cases := []struct {
+		name      string
+		router    *stubRouter
+		stops     []Stop
+		wantCalls int
+		routed    bool
+	}{
+		{"routed", &stubRouter{secs: 777}, few, 4, true},
+		{"router error keeps estimates", &stubRouter{err: errors.New("down")}, few, 4, false},
+		{"only nearest legs routed", &stubRouter{secs: 777}, full, (MaxRoutedStops + 1) * routedNeighbours, true},
+		{"too many stops", &stubRouter{secs: 777}, many, 0, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cost := BuildMatrix(context.Background(), tc.router, 71.40, 51.13, tc.stops)
+			if tc.router.calls != tc.wantCalls {
+				t.Fatalf("expected %d router calls got %d", tc.wantCalls, tc.router.calls)
+			}
+			if got := cost[0][1] == 777; got != tc.routed {
+				t.Fatalf("expected routed=%v, cost[0][1]=%d", tc.routed, cost[0][1])
+			}
+			if cost[1][0] != estimateSeconds(tc.stops[0].Lon, tc.stops[0].Lat, 71.40, 51.13) {
+				t.Fatalf("way back to the start must stay estimated, got %d", cost[1][0])
+			}
+		})
+	}
+}
+
+func TestPlanCumulativeETA(t *testing.T) {
+	stops := []Stop{
+		{OrderID: 1, Kind: KindPickup, Lon: 71.41, Lat: 51.13},
+		{OrderID: 1, Kind: KindDropoff, Lon: 71.42, Lat: 51.13},
+	}
+	planned := Plan(context.Background(), &stubRouter{secs: 60}, 71.40, 51.13, stops)
+	if len(planned) != 2 || planned[0].Kind != KindPickup {
+		t.Fatalf("unexpected plan: %+v", planned)
+	}
+	if planned[0].EtaSeconds != 60 || planned[1].EtaSeconds != 120 {
+		t.Fatalf("expected cumulative eta 60/120, got %d/%d", planned[0].EtaSeconds, planned[1].EtaSeconds)
+	}
+	if Plan(context.Background(), nil, 0, 0, nil) != nil {
+		t.Fatalf("expected empty plan for no stops")
+	}
+}
@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"naimuBack/internal/chat"
+)
+
+// SetChatHandler attaches order chat processing for messages sent by couriers.
+func (h *CourierHub) SetChatHandler(handler chat.Handler) {
+	h.mu.Lock()
+	h.chatHandler = handler
+	h.mu.Unlock()
+}
+
+func (h *CourierHub) handleChatMessage(courierID int64, in chat.Incoming) {
+	h.mu.RLock()
+	handler := h.chatHandler
+	h.mu.RUnlock()
+	if err := dispatchChat(handler, chat.RoleCourier, courierID, in); err != nil {
+		h.Push(courierID, chat.Event{Type: chat.TypeError, OrderID: in.OrderID, Error: chat.ErrorText(err)})
+	}
+}
+
+// SetChatHandler attaches order chat processing for messages sent by senders.
+func (h *SenderHub) SetChatHandler(handler chat.Handler) {
+	h.baseHub.setChatHandler(chat.RoleSender, handler)
+}
+
+func (h *baseHub) setChatHandler(role string, handler chat.Handler) {
+	h.mu.Lock()
+	h.role = role
+	h.chatHandler = handler
+	h.mu.Unlock()
+}
+
+func (h *baseHub) handleChatMessage(id int64, in chat.Incoming) {
+	h.mu.RLock()
+	role, handler := h.role, h.chatHandler
+	h.mu.RUnlock()
+	if role == "" {
+		return
+	}
+	if err := dispatchChat(handler, role, id, in); err != nil {
+		h.push(id, chat.Event{Type: chat.TypeError, OrderID: in.OrderID, Error: chat.ErrorText(err)})
+	}
+}
+
+func dispatchChat(handler chat.Handler, role string, senderID int64, in chat.Incoming) error {
+	if handler == nil {
+		return chat.ErrChatClosed
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return handler.ChatMessage(ctx, role, senderID, in)
+}
@@ -0,0 +1,23 @@
+package ws
+
+// LocationHandler receives courier positions to keep the live ETA of the current order up to date.
+// Implementations must not block: it is called from the courier read loop.
+type LocationHandler interface {
+	CourierLocation(courierID int64, lon, lat float64)
+}
+
+// SetLocationHandler attaches live ETA tracking.
+func (h *CourierHub) SetLocationHandler(handler LocationHandler) {
+	h.mu.Lock()
+	h.location = handler
+	h.mu.Unlock()
+}
+
+func (h *CourierHub) notifyLocation(courierID int64, lon, lat float64) {
+	h.mu.RLock()
+	handler := h.location
+	h.mu.RUnlock()
+	if handler != nil {
+		handler.CourierLocation(courierID, lon, lat)
+	}
+}
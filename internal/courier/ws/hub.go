@@ -13,6 +13,7 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"naimuBack/internal/chat"
 	"naimuBack/internal/courier/geo"
 )
 
@@ -38,6 +39,8 @@ type CourierRoutePoint struct {
 	Address string  `json:"address"`
 	Lon     float64 `json:"lon"`
 	Lat     float64 `json:"lat"`
+	// LegPolyline — геометрия участка до следующей точки.
+	LegPolyline string `json:"leg_polyline,omitempty"`
 }
 
 // CourierOfferPayload represents an order offer delivered to a courier.
@@ -50,6 +53,20 @@ type CourierOfferPayload struct {
 	EtaSeconds       int                 `json:"eta_s"`
 	ExpiresInSec     int                 `json:"expires_in"`
 	Points           []CourierRoutePoint `json:"points"`
+	Parcel           *CourierParcel      `json:"parcel,omitempty"`
+	// CODAmount — наличные, которые нужно взять с получателя.
+	CODAmount int `json:"cod_amount,omitempty"`
+	// DeclaredValue — объявленная ценность застрахованной посылки.
+	DeclaredValue int `json:"declared_value,omitempty"`
+}
+
+// CourierParcel — класс, вес и габариты посылки в оффере.
+type CourierParcel struct {
+	Class    string  `json:"class"`
+	WeightKg float64 `json:"weight_kg,omitempty"`
+	LengthCm int     `json:"length_cm,omitempty"`
+	WidthCm  int     `json:"width_cm,omitempty"`
+	HeightCm int     `json:"height_cm,omitempty"`
 }
 
 type courierOfferClosedPayload struct {
@@ -72,6 +89,18 @@ type SenderEvent struct {
 	CourierID int64  `json:"courier_id,omitempty"` // если событие связано с конкретным курьером
 	Price     int    `json:"price,omitempty"`      // если хотим передать цену
 	// при необходимости позже можно добавить вложенные объекты (например Courier)
+	ETA *SenderETA `json:"eta,omitempty"` // позиция курьера и время до забора или доставки (type "courier_eta")
+}
+
+// SenderETA is the live position of the courier and the time left to the target point.
+type SenderETA struct {
+	Target     string  `json:"target"`
+	CourierLon float64 `json:"courier_lon"`
+	CourierLat float64 `json:"courier_lat"`
+	DistanceM  int     `json:"distance_m"`
+	EtaSeconds int     `json:"eta_s"`
+	// OffRoute — курьер отклонился от построенного маршрута.
+	OffRoute bool `json:"off_route,omitempty"`
 }
 
 /* =========================
@@ -89,6 +118,11 @@ type CourierHub struct {
 	locks      map[int64]*sync.Mutex
 	cities     map[int64]string
 	lastStatus map[int64]string
+
+	chatHandler chat.Handler
+	location    LocationHandler
+
+	positions map[int64]CourierPosition
 }
 
 // NewCourierHub constructs courier hub.
@@ -101,6 +135,7 @@ func NewCourierHub(locator *geo.CourierLocator, logger Logger) *CourierHub {
 		locks:      make(map[int64]*sync.Mutex),
 		cities:     make(map[int64]string),
 		lastStatus: make(map[int64]string),
+		positions:  make(map[int64]CourierPosition),
 	}
 }
 
@@ -207,6 +242,11 @@ func (h *CourierHub) readLoop(id int64, conn *websocket.Conn, city string) {
 			continue
 		}
 
+		if in, ok := chat.ParseIncoming([]byte(trimmed)); ok {
+			h.handleChatMessage(id, in)
+			continue
+		}
+
 		dec := json.NewDecoder(strings.NewReader(trimmed))
 		dec.UseNumber()
 		var raw payloadRaw
@@ -277,6 +317,9 @@ func (h *CourierHub) readLoop(id int64, conn *websocket.Conn, city string) {
 			}
 		}
 		cancel()
+
+		h.rememberPosition(id, lon, lat)
+		h.notifyLocation(id, lon, lat)
 	}
 }
 
@@ -290,6 +333,7 @@ func (h *CourierHub) closeConn(id int64, conn *websocket.Conn) {
 		city = h.cities[id]
 		delete(h.cities, id)
 		delete(h.lastStatus, id)
+		delete(h.positions, id)
 	}
 	h.mu.Unlock()
 	if city != "" {
@@ -446,6 +490,10 @@ type baseHub struct {
 	mu    sync.RWMutex
 	conns map[int64]*websocket.Conn
 	locks map[int64]*sync.Mutex
+
+	// role and chatHandler are set for hubs whose clients can write to the order chat.
+	role        string
+	chatHandler chat.Handler
 }
 
 func newBaseHub(name, param string, logger Logger) *baseHub {
@@ -537,6 +585,10 @@ func (h *baseHub) readLoop(id int64, conn *websocket.Conn) {
 			trimmed := strings.TrimSpace(string(message))
 			if strings.EqualFold(trimmed, "ping") {
 				_ = conn.WriteMessage(websocket.TextMessage, []byte("pong"))
+				continue
+			}
+			if in, ok := chat.ParseIncoming([]byte(trimmed)); ok {
+				h.handleChatMessage(id, in)
 			}
 		}
 	}
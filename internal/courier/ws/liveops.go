@@ -0,0 +1,56 @@
+package ws
+
+import "time"
+
+// CourierPosition is the last coordinate received from the courier socket.
+type CourierPosition struct {
+	Lon       float64
+	Lat       float64
+	UpdatedAt time.Time
+}
+
+// OnlineCourier describes a connected courier for the live operations map.
+type OnlineCourier struct {
+	ID     int64
+	City   string
+	Status string
+	// HasPosition is false until the courier sends the first coordinates.
+	HasPosition bool
+	Position    CourierPosition
+}
+
+func (h *CourierHub) rememberPosition(courierID int64, lon, lat float64) {
+	h.mu.Lock()
+	h.positions[courierID] = CourierPosition{Lon: lon, Lat: lat, UpdatedAt: time.Now()}
+	h.mu.Unlock()
+}
+
+// OnlineCouriers returns every connected courier with the last known position.
+func (h *CourierHub) OnlineCouriers() []OnlineCourier {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	couriers := make([]OnlineCourier, 0, len(h.conns))
+	for id := range h.conns {
+		status := h.lastStatus[id]
+		if status == "" {
+			status = "free"
+		}
+		pos, ok := h.positions[id]
+		couriers = append(couriers, OnlineCourier{
+			ID:          id,
+			City:        h.cities[id],
+			Status:      status,
+			HasPosition: ok,
+			Position:    pos,
+		})
+	}
+	return couriers
+}
+
+// Position returns the last coordinate of the courier.
+func (h *CourierHub) Position(courierID int64) (CourierPosition, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	pos, ok := h.positions[courierID]
+	return pos, ok
+}
@@ -0,0 +1,151 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxTrackingViewers caps sockets per tracking link; the link may be forwarded around.
+const maxTrackingViewers = 5
+
+// TrackingHub serves read-only sockets of recipients keyed by the tracking link token.
+type TrackingHub struct {
+	logger   Logger
+	upgrader websocket.Upgrader
+
+	mu      sync.RWMutex
+	viewers map[string]map[*websocket.Conn]*sync.Mutex
+}
+
+// NewTrackingHub constructs a recipient tracking hub.
+func NewTrackingHub(logger Logger) *TrackingHub {
+	return &TrackingHub{
+		logger: logger,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		viewers: make(map[string]map[*websocket.Conn]*sync.Mutex),
+	}
+}
+
+// ServeWS upgrades the request for an already validated token.
+func (h *TrackingHub) ServeWS(w http.ResponseWriter, r *http.Request, token string) {
+	h.mu.RLock()
+	full := len(h.viewers[token]) >= maxTrackingViewers
+	h.mu.RUnlock()
+	if full {
+		http.Error(w, "too many viewers", http.StatusTooManyRequests)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		if h.logger != nil {
+			h.logger.Errorf("courier tracking ws upgrade failed: %v", err)
+		}
+		return
+	}
+	h.mu.Lock()
+	if h.viewers[token] == nil {
+		h.viewers[token] = make(map[*websocket.Conn]*sync.Mutex)
+	}
+	h.viewers[token][conn] = &sync.Mutex{}
+	h.mu.Unlock()
+
+	go h.pingLoop(token, conn)
+	go h.readLoop(token, conn)
+}
+
+// HasViewers reports whether somebody watches the link right now.
+func (h *TrackingHub) HasViewers(token string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.viewers[token]) > 0
+}
+
+// Push sends payload to every viewer of the link.
+func (h *TrackingHub) Push(token string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		if h.logger != nil {
+			h.logger.Errorf("courier tracking marshal failed: %v", err)
+		}
+		return
+	}
+	h.mu.RLock()
+	conns := make([]*websocket.Conn, 0, len(h.viewers[token]))
+	for conn := range h.viewers[token] {
+		conns = append(conns, conn)
+	}
+	h.mu.RUnlock()
+	for _, conn := range conns {
+		h.write(token, conn, func(c *websocket.Conn) error {
+			return c.WriteMessage(websocket.TextMessage, data)
+		})
+	}
+}
+
+func (h *TrackingHub) write(token string, conn *websocket.Conn, fn func(*websocket.Conn) error) {
+	h.mu.RLock()
+	mu := h.viewers[token][conn]
+	h.mu.RUnlock()
+	if mu == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := fn(conn); err != nil {
+		h.closeConn(token, conn)
+	}
+}
+
+func (h *TrackingHub) pingLoop(token string, conn *websocket.Conn) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.mu.RLock()
+		_, alive := h.viewers[token][conn]
+		h.mu.RUnlock()
+		if !alive {
+			return
+		}
+		h.write(token, conn, func(c *websocket.Conn) error {
+			return c.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+		})
+	}
+}
+
+// readLoop only keeps the connection alive: recipients never send anything meaningful.
+func (h *TrackingHub) readLoop(token string, conn *websocket.Conn) {
+	defer h.closeConn(token, conn)
+
+	conn.SetReadLimit(1 << 10)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+	}
+}
+
+func (h *TrackingHub) closeConn(token string, conn *websocket.Conn) {
+	_ = conn.Close()
+	h.mu.Lock()
+	if conns, ok := h.viewers[token]; ok {
+		delete(conns, conn)
+		if len(conns) == 0 {
+			delete(h.viewers, token)
+		}
+	}
+	h.mu.Unlock()
+}
@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func (h *TrackingHub) viewerCount(token string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.viewers[token])
+}
+
+func waitViewers(t *testing.T, h *TrackingHub, token string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for h.viewerCount(token) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d viewers got %d", want, h.viewerCount(token))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTrackingHubViewerCap(t *testing.T) {
+	const token = "0123456789abcdef0123456789abcdef"
+	h := NewTrackingHub(nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeWS(w, r, r.URL.Query().Get("token"))
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	conns := make([]*websocket.Conn, 0, maxTrackingViewers)
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+	for i := 0; i < maxTrackingViewers; i++ {
+		c, _, err := websocket.DefaultDialer.Dial(url+"?token="+token, nil)
+		if err != nil {
+			t.Fatalf("viewer %d: dial failed: %v", i+1, err)
+		}
+		conns = append(conns, c)
+		waitViewers(t, h, token, i+1)
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(url+"?token="+token, nil)
+	if err == nil {
+		t.Fatalf("expected viewer over the cap to be rejected")
+	}
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected %d got %v", http.StatusTooManyRequests, resp)
+	}
+
+	// лимит считается по ссылке, другая ссылка открывается
+	other, _, err := websocket.DefaultDialer.Dial(url+"?token=ffffffffffffffffffffffffffffffff", nil)
+	if err != nil {
+		t.Fatalf("other link: dial failed: %v", err)
+	}
+	conns = append(conns, other)
+
+	// закрытый сокет освобождает место
+	conns[0].Close()
+	waitViewers(t, h, token, maxTrackingViewers-1)
+	c, _, err := websocket.DefaultDialer.Dial(url+"?token="+token, nil)
+	if err != nil {
+		t.Fatalf("dial after a viewer left failed: %v", err)
+	}
+	conns = append(conns, c)
+}
+
+func TestTrackingHubPush(t *testing.T) {
+	const token = "0123456789abcdef0123456789abcdef"
+	h := NewTrackingHub(nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeWS(w, r, token)
+	}))
+	defer srv.Close()
+
+	if h.HasViewers(token) {
+		t.Fatalf("expected no viewers before dial")
+	}
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer c.Close()
+	waitViewers(t, h, token, 1)
+
+	h.Push(token, map[string]string{"type": "tracking"})
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(data) != `{"type":"tracking"}` {
+		t.Fatalf("unexpected payload %s", data)
+	}
+}
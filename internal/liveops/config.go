@@ -0,0 +1,59 @@
+package liveops
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPushInterval   = 5 * time.Second
+	defaultSearchingAlert = 5 * time.Minute
+	defaultWaitingAlert   = 10 * time.Minute
+)
+
+// Config holds live operations settings.
+type Config struct {
+	// PushInterval — как часто операторам рассылается снимок карты.
+	PushInterval time.Duration
+	Thresholds   Thresholds
+}
+
+// LoadConfig reads live operations settings from environment variables and applies defaults.
+func LoadConfig() (Config, error) {
+	cfg := Config{
+		PushInterval: defaultPushInterval,
+		Thresholds: Thresholds{
+			Searching: defaultSearchingAlert,
+			Waiting:   defaultWaitingAlert,
+		},
+	}
+
+	for _, item := range []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"LIVEOPS_PUSH_SECONDS", &cfg.PushInterval},
+		{"LIVEOPS_SEARCH_ALERT_SECONDS", &cfg.Thresholds.Searching},
+		{"LIVEOPS_WAIT_ALERT_SECONDS", &cfg.Thresholds.Waiting},
+	} {
+		v := os.Getenv(item.env)
+		if v == "" {
+			continue
+		}
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse %s: %w", item.env, err)
+		}
+		*item.dst = time.Duration(secs) * time.Second
+	}
+
+	if cfg.PushInterval <= 0 {
+		return Config{}, fmt.Errorf("LIVEOPS_PUSH_SECONDS must be positive")
+	}
+	if cfg.Thresholds.Searching < 0 || cfg.Thresholds.Waiting < 0 {
+		return Config{}, fmt.Errorf("liveops alert thresholds must not be negative")
+	}
+	return cfg, nil
+}
@@ -0,0 +1,379 @@
+package liveops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait  = 20 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = 30 * time.Second
+)
+
+// WebSocket message types.
+const (
+	TypeSnapshot     = "snapshot"
+	TypeSOS          = "sos"
+	TypeAssignResult = "assign_result"
+	TypeSOSResolved  = "sos_resolved"
+	TypeError        = "error"
+)
+
+// Logger is the logging interface used by the hub.
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// inbound is a command sent by an operator.
+type inbound struct {
+	Type    string `json:"type"`
+	City    string `json:"city"`
+	Service string `json:"service"`
+	OrderID int64  `json:"order_id"`
+	AgentID int64  `json:"agent_id"`
+	SOSID   int64  `json:"sos_id"`
+}
+
+type assignResult struct {
+	Type    string `json:"type"`
+	Service string `json:"service"`
+	OrderID int64  `json:"order_id"`
+	AgentID int64  `json:"agent_id"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+type operator struct {
+	conn    *websocket.Conn
+	adminID int64
+	wmu     sync.Mutex
+	city    string
+}
+
+// Hub streams the live operations map to admin WebSocket clients.
+type Hub struct {
+	upgrader websocket.Upgrader
+	logger   Logger
+	sos      *SOSRepo
+	cfg      Config
+
+	mu        sync.RWMutex
+	sources   map[string]Source
+	operators map[*operator]struct{}
+}
+
+// NewHub builds the live operations hub; sos may be nil when SOS alerts are not stored.
+func NewHub(cfg Config, sos *SOSRepo, logger Logger) *Hub {
+	return &Hub{
+		upgrader:  websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+		logger:    logger,
+		sos:       sos,
+		cfg:       cfg,
+		sources:   make(map[string]Source),
+		operators: make(map[*operator]struct{}),
+	}
+}
+
+// AddSource registers a service on the map.
+func (h *Hub) AddSource(src Source) {
+	if h == nil || src == nil {
+		return
+	}
+	h.mu.Lock()
+	h.sources[src.Service()] = src
+	h.mu.Unlock()
+}
+
+// Snapshot collects agents, orders and alerts of every service.
+func (h *Hub) Snapshot(ctx context.Context, now time.Time) Snapshot {
+	h.mu.RLock()
+	sources := make([]Source, 0, len(h.sources))
+	for _, src := range h.sources {
+		sources = append(sources, src)
+	}
+	h.mu.RUnlock()
+
+	snap := Snapshot{Type: TypeSnapshot, At: now, Agents: make([]Agent, 0), Orders: make([]Order, 0)}
+	for _, src := range sources {
+		agents, orders, err := src.Collect(ctx, now)
+		if err != nil {
+			h.logger.Errorf("liveops: collect %s: %v", src.Service(), err)
+			continue
+		}
+		snap.Agents = append(snap.Agents, agents...)
+		snap.Orders = append(snap.Orders, orders...)
+	}
+	snap.Alerts = OrderAlerts(snap.Orders, h.cfg.Thresholds, now)
+	if h.sos != nil {
+		open, err := h.sos.ListOpen(ctx)
+		if err != nil {
+			h.logger.Errorf("liveops: list sos: %v", err)
+		}
+		for _, s := range open {
+			snap.Alerts = append(snap.Alerts, s.Alert(now))
+		}
+		sortAlerts(snap.Alerts)
+	}
+	return snap
+}
+
+// Run pushes snapshots to connected operators until ctx is done.
+func (h *Hub) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg.PushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Push(ctx)
+		}
+	}
+}
+
+// Push sends a fresh snapshot to every operator.
+func (h *Hub) Push(ctx context.Context) {
+	ops := h.operatorList()
+	if len(ops) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	snap := h.Snapshot(ctx, time.Now())
+	cancel()
+	for _, op := range ops {
+		h.send(op, snap.Filter(h.operatorCity(op)))
+	}
+}
+
+// RaiseSOS stores an SOS and alerts operators immediately.
+func (h *Hub) RaiseSOS(ctx context.Context, s SOS) (SOS, error) {
+	if h.sos == nil {
+		return SOS{}, errors.New("sos alerts are not configured")
+	}
+	s.City = NormalizeCity(s.City)
+	s.Message = TrimSOSMessage(s.Message)
+	saved, err := h.sos.Create(ctx, s)
+	if err != nil {
+		return SOS{}, err
+	}
+	h.logger.Infof("liveops: sos %d raised by %s %d on %s order %d", saved.ID, saved.ReporterRole, saved.ReporterID, saved.Service, saved.OrderID)
+
+	msg := struct {
+		Type  string `json:"type"`
+		Alert Alert  `json:"alert"`
+	}{Type: TypeSOS, Alert: saved.Alert(saved.CreatedAt)}
+	for _, op := range h.operatorList() {
+		if city := h.operatorCity(op); city == "" || city == saved.City {
+			h.send(op, msg)
+		}
+	}
+	return saved, nil
+}
+
+// Assign manually assigns a driver or courier to a searching order.
+func (h *Hub) Assign(ctx context.Context, service string, orderID, agentID int64) error {
+	h.mu.RLock()
+	src, ok := h.sources[service]
+	h.mu.RUnlock()
+	if !ok {
+		return ErrUnknownService
+	}
+	return src.Assign(ctx, orderID, agentID)
+}
+
+// ServeWS upgrades an admin connection; X-Admin-ID is set by the auth middleware, ?city= narrows the map.
+func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
+	adminID, err := strconv.ParseInt(r.Header.Get("X-Admin-ID"), 10, 64)
+	if err != nil || adminID <= 0 {
+		http.Error(w, "missing admin id", http.StatusUnauthorized)
+		return
+	}
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.logger.Errorf("liveops ws upgrade failed: %v", err)
+		return
+	}
+
+	op := &operator{conn: conn, adminID: adminID, city: NormalizeCity(r.URL.Query().Get("city"))}
+	h.mu.Lock()
+	h.operators[op] = struct{}{}
+	h.mu.Unlock()
+	h.logger.Infof("liveops: admin %d connected (city=%s)", adminID, op.city)
+
+	go h.pingLoop(op)
+	go h.sendSnapshot(op)
+	h.readLoop(op)
+}
+
+func (h *Hub) readLoop(op *operator) {
+	defer h.closeOperator(op)
+
+	op.conn.SetReadLimit(4 << 10)
+	op.conn.SetReadDeadline(time.Now().Add(pongWait))
+	op.conn.SetPongHandler(func(string) error {
+		op.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, data, err := op.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		op.conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		var msg inbound
+		if err := json.Unmarshal(data, &msg); err != nil {
+			h.sendError(op, "invalid message")
+			continue
+		}
+		switch msg.Type {
+		case "filter":
+			h.mu.Lock()
+			op.city = NormalizeCity(msg.City)
+			h.mu.Unlock()
+			go h.sendSnapshot(op)
+		case "assign":
+			h.handleAssign(op, msg)
+		case "resolve_sos":
+			h.handleResolve(op, msg)
+		default:
+			h.sendError(op, "unknown message type")
+		}
+	}
+}
+
+func (h *Hub) handleAssign(op *operator, msg inbound) {
+	res := assignResult{Type: TypeAssignResult, Service: msg.Service, OrderID: msg.OrderID, AgentID: msg.AgentID}
+	if msg.OrderID <= 0 || msg.AgentID <= 0 {
+		res.Error = "order_id and agent_id are required"
+		h.send(op, res)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err := h.Assign(ctx, msg.Service, msg.OrderID, msg.AgentID)
+	cancel()
+	if err != nil {
+		h.logger.Errorf("liveops: admin %d assign %s order %d to %d: %v", op.adminID, msg.Service, msg.OrderID, msg.AgentID, err)
+		res.Error = assignErrorText(err)
+		h.send(op, res)
+		return
+	}
+	h.logger.Infof("liveops: admin %d assigned %s order %d to %d", op.adminID, msg.Service, msg.OrderID, msg.AgentID)
+	res.OK = true
+	h.send(op, res)
+	go h.Push(context.Background())
+}
+
+func assignErrorText(err error) string {
+	for _, known := range []error{ErrUnknownService, ErrOrderNotFound, ErrNotAssignable, ErrAgentUnavailable, ErrAgentBusy} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	return "assign failed"
+}
+
+func (h *Hub) handleResolve(op *operator, msg inbound) {
+	if h.sos == nil || msg.SOSID <= 0 {
+		h.sendError(op, "sos_id is required")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err := h.sos.Resolve(ctx, msg.SOSID, op.adminID, time.Now())
+	cancel()
+	if err != nil {
+		if errors.Is(err, ErrSOSNotFound) {
+			h.sendError(op, err.Error())
+			return
+		}
+		h.logger.Errorf("liveops: resolve sos %d: %v", msg.SOSID, err)
+		h.sendError(op, "failed to resolve sos")
+		return
+	}
+	h.logger.Infof("liveops: admin %d resolved sos %d", op.adminID, msg.SOSID)
+	h.send(op, map[string]interface{}{"type": TypeSOSResolved, "sos_id": msg.SOSID})
+	go h.Push(context.Background())
+}
+
+func (h *Hub) sendSnapshot(op *operator) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	snap := h.Snapshot(ctx, time.Now())
+	cancel()
+	h.send(op, snap.Filter(h.operatorCity(op)))
+}
+
+func (h *Hub) sendError(op *operator, msg string) {
+	h.send(op, map[string]string{"type": TypeError, "message": msg})
+}
+
+func (h *Hub) pingLoop(op *operator) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !h.connected(op) {
+			return
+		}
+		op.wmu.Lock()
+		err := op.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+		op.wmu.Unlock()
+		if err != nil {
+			h.closeOperator(op)
+			return
+		}
+	}
+}
+
+func (h *Hub) send(op *operator, v interface{}) {
+	op.wmu.Lock()
+	defer op.wmu.Unlock()
+	op.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := op.conn.WriteJSON(v); err != nil {
+		h.logger.Errorf("liveops: write to admin %d failed: %v", op.adminID, err)
+		go h.closeOperator(op)
+	}
+}
+
+func (h *Hub) operatorList() []*operator {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ops := make([]*operator, 0, len(h.operators))
+	for op := range h.operators {
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+func (h *Hub) operatorCity(op *operator) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return op.city
+}
+
+func (h *Hub) connected(op *operator) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.operators[op]
+	return ok
+}
+
+func (h *Hub) closeOperator(op *operator) {
+	h.mu.Lock()
+	_, ok := h.operators[op]
+	delete(h.operators, op)
+	h.mu.Unlock()
+	_ = op.conn.Close()
+	if ok {
+		h.logger.Infof("liveops: admin %d disconnected", op.adminID)
+	}
+}
@@ -0,0 +1,196 @@
+package liveops
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Services shown on the live operations map.
+const (
+	ServiceTaxi    = "taxi"
+	ServiceCourier = "courier"
+)
+
+// Order phases as seen by the operator.
+const (
+	PhaseSearching = "searching"
+	PhaseToPickup  = "to_pickup"
+	PhaseWaiting   = "waiting"
+	PhaseInTrip    = "in_trip"
+)
+
+// Alert kinds.
+const (
+	AlertStuckSearching = "stuck_searching"
+	AlertLongWait       = "long_wait"
+	AlertLateRisk       = "late_risk"
+	AlertSOS            = "sos"
+)
+
+var (
+	ErrUnknownService   = errors.New("unknown service")
+	ErrOrderNotFound    = errors.New("order not found")
+	ErrNotAssignable    = errors.New("order is not searching for a performer")
+	ErrAgentUnavailable = errors.New("performer is not available")
+	ErrAgentBusy        = errors.New("performer already has an active order")
+)
+
+// Agent is an online driver or courier.
+type Agent struct {
+	Service string  `json:"service"`
+	ID      int64   `json:"id"`
+	City    string  `json:"city"`
+	Status  string  `json:"status"`
+	Lon     float64 `json:"lon"`
+	Lat     float64 `json:"lat"`
+	// Resting — водитель на обязательном отдыхе, офферы ему не уходят.
+	Resting bool `json:"resting,omitempty"`
+}
+
+// Order is an active order with its current phase.
+type Order struct {
+	Service    string  `json:"service"`
+	ID         int64   `json:"id"`
+	City       string  `json:"city"`
+	Status     string  `json:"status"`
+	Phase      string  `json:"phase"`
+	CustomerID int64   `json:"customer_id"`
+	AgentID    int64   `json:"agent_id,omitempty"`
+	FromLon    float64 `json:"from_lon"`
+	FromLat    float64 `json:"from_lat"`
+	ToLon      float64 `json:"to_lon"`
+	ToLat      float64 `json:"to_lat"`
+	Price      int     `json:"price"`
+	// RadiusM — текущий радиус поиска для заказов в поиске.
+	RadiusM     int       `json:"radius_m,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	StatusSince time.Time `json:"status_since"`
+	// LateRiskAt — когда заказ ко времени признан не успевающим в своё окно.
+	LateRiskAt *time.Time `json:"late_risk_at,omitempty"`
+}
+
+// Alert is something an operator has to look at.
+type Alert struct {
+	Kind    string    `json:"kind"`
+	Service string    `json:"service"`
+	OrderID int64     `json:"order_id"`
+	City    string    `json:"city"`
+	Since   time.Time `json:"since"`
+	Seconds int       `json:"seconds"`
+	SOSID   int64     `json:"sos_id,omitempty"`
+	Role    string    `json:"role,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// Snapshot is the state pushed to operators.
+type Snapshot struct {
+	Type   string    `json:"type"`
+	City   string    `json:"city,omitempty"`
+	At     time.Time `json:"at"`
+	Agents []Agent   `json:"agents"`
+	Orders []Order   `json:"orders"`
+	Alerts []Alert   `json:"alerts"`
+}
+
+// Thresholds define when an order is considered stuck.
+type Thresholds struct {
+	Searching time.Duration
+	Waiting   time.Duration
+}
+
+// Source feeds one service into the live map and performs manual assignment.
+type Source interface {
+	Service() string
+	Collect(ctx context.Context, now time.Time) ([]Agent, []Order, error)
+	Assign(ctx context.Context, orderID, agentID int64) error
+}
+
+// OrderAlerts returns alerts for orders searching or waiting longer than the thresholds
+// and for scheduled orders at risk of missing their window.
+func OrderAlerts(orders []Order, th Thresholds, now time.Time) []Alert {
+	alerts := make([]Alert, 0)
+	for _, o := range orders {
+		if o.LateRiskAt != nil {
+			alerts = append(alerts, Alert{
+				Kind:    AlertLateRisk,
+				Service: o.Service,
+				OrderID: o.ID,
+				City:    o.City,
+				Since:   *o.LateRiskAt,
+				Seconds: int(now.Sub(*o.LateRiskAt) / time.Second),
+			})
+		}
+		var (
+			kind  string
+			since time.Time
+			limit time.Duration
+		)
+		switch o.Phase {
+		case PhaseSearching:
+			kind, since, limit = AlertStuckSearching, o.CreatedAt, th.Searching
+		case PhaseWaiting:
+			kind, since, limit = AlertLongWait, o.StatusSince, th.Waiting
+		default:
+			continue
+		}
+		if limit <= 0 || since.IsZero() {
+			continue
+		}
+		if elapsed := now.Sub(since); elapsed >= limit {
+			alerts = append(alerts, Alert{
+				Kind:    kind,
+				Service: o.Service,
+				OrderID: o.ID,
+				City:    o.City,
+				Since:   since,
+				Seconds: int(elapsed / time.Second),
+			})
+		}
+	}
+	sortAlerts(alerts)
+	return alerts
+}
+
+// sortAlerts puts SOS first, then the longest-running problems.
+func sortAlerts(alerts []Alert) {
+	sort.SliceStable(alerts, func(i, j int) bool {
+		si, sj := alerts[i].Kind == AlertSOS, alerts[j].Kind == AlertSOS
+		if si != sj {
+			return si
+		}
+		return alerts[i].Seconds > alerts[j].Seconds
+	})
+}
+
+// NormalizeCity lowercases a city key; empty means all cities.
+func NormalizeCity(city string) string {
+	return strings.ToLower(strings.TrimSpace(city))
+}
+
+// Filter returns the part of the snapshot that belongs to the city.
+func (s Snapshot) Filter(city string) Snapshot {
+	city = NormalizeCity(city)
+	if city == "" {
+		return s
+	}
+	out := Snapshot{Type: s.Type, City: city, At: s.At, Agents: make([]Agent, 0), Orders: make([]Order, 0), Alerts: make([]Alert, 0)}
+	for _, a := range s.Agents {
+		if NormalizeCity(a.City) == city {
+			out.Agents = append(out.Agents, a)
+		}
+	}
+	for _, o := range s.Orders {
+		if NormalizeCity(o.City) == city {
+			out.Orders = append(out.Orders, o)
+		}
+	}
+	for _, a := range s.Alerts {
+		if NormalizeCity(a.City) == city {
+			out.Alerts = append(out.Alerts, a)
+		}
+	}
+	return out
+}
@@ -0,0 +1,153 @@
+package liveops
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+
+type stubSource struct {
+	service  string
+	agents   []Agent
+	orders   []Order
+	assigned map[int64]int64
+}
+
+func (s *stubSource) Service() string { return s.service }
+
+func (s *stubSource) Collect(context.Context, time.Time) ([]Agent, []Order, error) {
+	return s.agents, s.orders, nil
+}
+
+func (s *stubSource) Assign(_ context.Context, orderID, agentID int64) error {
+	for _, o := range s.orders {
+		if o.ID != orderID {
+			continue
+		}
+		if o.Phase != PhaseSearching {
+			return ErrNotAssignable
+		}
+		s.assigned[orderID] = agentID
+		return nil
+	}
+	return ErrOrderNotFound
+}
+
+func TestOrderAlerts(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	th := Thresholds{Searching: 5 * time.Minute, Waiting: 10 * time.Minute}
+	orders := []Order{
+		{Service: ServiceTaxi, ID: 1, City: "astana", Phase: PhaseSearching, CreatedAt: now.Add(-6 * time.Minute)},
+		{Service: ServiceTaxi, ID: 2, City: "astana", Phase: PhaseSearching, CreatedAt: now.Add(-time.Minute)},
+		{Service: ServiceCourier, ID: 3, City: "almaty", Phase: PhaseWaiting, CreatedAt: now.Add(-time.Hour), StatusSince: now.Add(-15 * time.Minute)},
+		{Service: ServiceCourier, ID: 4, City: "almaty", Phase: PhaseWaiting, CreatedAt: now.Add(-time.Hour), StatusSince: now.Add(-2 * time.Minute)},
+		{Service: ServiceTaxi, ID: 5, City: "astana", Phase: PhaseInTrip, CreatedAt: now.Add(-time.Hour), StatusSince: now.Add(-time.Hour)},
+	}
+
+	alerts := OrderAlerts(orders, th, now)
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %d: %+v", len(alerts), alerts)
+	}
+	if alerts[0].Kind != AlertLongWait || alerts[0].OrderID != 3 || alerts[0].Seconds != 900 {
+		t.Fatalf("unexpected first alert: %+v", alerts[0])
+	}
+	if alerts[1].Kind != AlertStuckSearching || alerts[1].OrderID != 1 || alerts[1].Seconds != 360 {
+		t.Fatalf("unexpected second alert: %+v", alerts[1])
+	}
+
+	if got := OrderAlerts(orders, Thresholds{}, now); len(got) != 0 {
+		t.Fatalf("zero thresholds must disable alerts, got %+v", got)
+	}
+}
+
+func TestOrderAlertsLateRisk(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	flagged := now.Add(-3 * time.Minute)
+	orders := []Order{
+		{Service: ServiceCourier, ID: 7, City: "astana", Phase: PhaseToPickup, CreatedAt: now.Add(-time.Hour), LateRiskAt: &flagged},
+		{Service: ServiceCourier, ID: 8, City: "astana", Phase: PhaseToPickup, CreatedAt: now.Add(-time.Hour)},
+	}
+
+	alerts := OrderAlerts(orders, Thresholds{}, now)
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d: %+v", len(alerts), alerts)
+	}
+	if alerts[0].Kind != AlertLateRisk || alerts[0].OrderID != 7 || alerts[0].Seconds != 180 {
+		t.Fatalf("unexpected alert: %+v", alerts[0])
+	}
+}
+
+func TestSnapshotFilter(t *testing.T) {
+	snap := Snapshot{
+		Type:   TypeSnapshot,
+		Agents: []Agent{{ID: 1, City: "astana"}, {ID: 2, City: "Almaty"}},
+		Orders: []Order{{ID: 10, City: "almaty"}, {ID: 11, City: "astana"}},
+		Alerts: []Alert{{Kind: AlertSOS, OrderID: 10, City: "almaty"}},
+	}
+
+	all := snap.Filter("")
+	if len(all.Agents) != 2 || len(all.Orders) != 2 || len(all.Alerts) != 1 {
+		t.Fatalf("empty city must keep everything: %+v", all)
+	}
+
+	almaty := snap.Filter(" ALMATY ")
+	if almaty.City != "almaty" {
+		t.Fatalf("expected normalized city, got %q", almaty.City)
+	}
+	if len(almaty.Agents) != 1 || almaty.Agents[0].ID != 2 {
+		t.Fatalf("unexpected agents: %+v", almaty.Agents)
+	}
+	if len(almaty.Orders) != 1 || almaty.Orders[0].ID != 10 {
+		t.Fatalf("unexpected orders: %+v", almaty.Orders)
+	}
+	if len(almaty.Alerts) != 1 {
+		t.Fatalf("unexpected alerts: %+v", almaty.Alerts)
+	}
+}
+
+func TestHubSnapshotAndAssign(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	taxi := &stubSource{
+		service:  ServiceTaxi,
+		agents:   []Agent{{Service: ServiceTaxi, ID: 7, City: "astana", Status: "free"}},
+		orders:   []Order{{Service: ServiceTaxi, ID: 1, City: "astana", Phase: PhaseSearching, CreatedAt: now.Add(-10 * time.Minute)}},
+		assigned: make(map[int64]int64),
+	}
+	courier := &stubSource{
+		service:  ServiceCourier,
+		orders:   []Order{{Service: ServiceCourier, ID: 2, City: "astana", Phase: PhaseInTrip, CreatedAt: now}},
+		assigned: make(map[int64]int64),
+	}
+
+	cfg := Config{PushInterval: time.Second, Thresholds: Thresholds{Searching: 5 * time.Minute, Waiting: 10 * time.Minute}}
+	hub := NewHub(cfg, nil, nopLogger{})
+	hub.AddSource(taxi)
+	hub.AddSource(courier)
+
+	snap := hub.Snapshot(context.Background(), now)
+	if len(snap.Agents) != 1 || len(snap.Orders) != 2 {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+	if len(snap.Alerts) != 1 || snap.Alerts[0].OrderID != 1 {
+		t.Fatalf("expected stuck search alert, got %+v", snap.Alerts)
+	}
+
+	if err := hub.Assign(context.Background(), ServiceTaxi, 1, 7); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if taxi.assigned[1] != 7 {
+		t.Fatalf("order was not assigned: %+v", taxi.assigned)
+	}
+	if err := hub.Assign(context.Background(), ServiceCourier, 2, 7); !errors.Is(err, ErrNotAssignable) {
+		t.Fatalf("expected ErrNotAssignable, got %v", err)
+	}
+	if err := hub.Assign(context.Background(), "cargo", 1, 7); !errors.Is(err, ErrUnknownService) {
+		t.Fatalf("expected ErrUnknownService, got %v", err)
+	}
+}
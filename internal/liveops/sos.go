@@ -0,0 +1,119 @@
+package liveops
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxSOSMessage is the maximum length of an SOS comment in characters.
+const MaxSOSMessage = 500
+
+// ErrSOSNotFound is returned when the alert does not exist or is already resolved.
+var ErrSOSNotFound = errors.New("sos alert not found")
+
+// SOS is an emergency signal raised by a participant of an order.
+type SOS struct {
+	ID           int64
+	Service      string
+	OrderID      int64
+	ReporterRole string
+	ReporterID   int64
+	City         string
+	Lon          sql.NullFloat64
+	Lat          sql.NullFloat64
+	Message      string
+	CreatedAt    time.Time
+}
+
+// Alert converts the SOS into an operator alert.
+func (s SOS) Alert(now time.Time) Alert {
+	return Alert{
+		Kind:    AlertSOS,
+		Service: s.Service,
+		OrderID: s.OrderID,
+		City:    s.City,
+		Since:   s.CreatedAt,
+		Seconds: int(now.Sub(s.CreatedAt) / time.Second),
+		SOSID:   s.ID,
+		Role:    s.ReporterRole,
+		Message: s.Message,
+	}
+}
+
+// TrimSOSMessage trims the comment and cuts it to MaxSOSMessage characters.
+func TrimSOSMessage(msg string) string {
+	msg = strings.TrimSpace(msg)
+	if utf8.RuneCountInString(msg) <= MaxSOSMessage {
+		return msg
+	}
+	return string([]rune(msg)[:MaxSOSMessage])
+}
+
+// SOSRepo stores SOS alerts in MySQL.
+type SOSRepo struct {
+	db *sql.DB
+}
+
+// NewSOSRepo builds an SOS repo.
+func NewSOSRepo(db *sql.DB) *SOSRepo {
+	return &SOSRepo{db: db}
+}
+
+// Create persists an alert and returns it with the assigned id.
+func (r *SOSRepo) Create(ctx context.Context, s SOS) (SOS, error) {
+	var msg interface{}
+	if s.Message != "" {
+		msg = s.Message
+	}
+	res, err := r.db.ExecContext(ctx, `INSERT INTO sos_alerts (service, order_id, reporter_role, reporter_id, city, lon, lat, message, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, s.Service, s.OrderID, s.ReporterRole, s.ReporterID, s.City, s.Lon, s.Lat, msg, s.CreatedAt)
+	if err != nil {
+		return SOS{}, err
+	}
+	s.ID, err = res.LastInsertId()
+	return s, err
+}
+
+// ListOpen returns unresolved alerts, oldest first.
+func (r *SOSRepo) ListOpen(ctx context.Context) ([]SOS, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, service, order_id, reporter_role, reporter_id, city, lon, lat, message, created_at
+        FROM sos_alerts WHERE resolved_at IS NULL ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := make([]SOS, 0)
+	for rows.Next() {
+		var (
+			s   SOS
+			msg sql.NullString
+		)
+		if err := rows.Scan(&s.ID, &s.Service, &s.OrderID, &s.ReporterRole, &s.ReporterID, &s.City, &s.Lon, &s.Lat, &msg, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		s.Message = msg.String
+		alerts = append(alerts, s)
+	}
+	return alerts, rows.Err()
+}
+
+// Resolve closes an open alert on behalf of the operator.
+func (r *SOSRepo) Resolve(ctx context.Context, id, adminID int64, now time.Time) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE sos_alerts SET resolved_at = ?, resolved_by = ? WHERE id = ? AND resolved_at IS NULL`, now, adminID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSOSNotFound
+	}
+	return nil
+}
@@ -295,7 +295,9 @@ func (s *AirbapayService) Charge(ctx context.Context, amount float64) error {
 	return nil
 }
 
-func (s *AirbapayService) Return(ctx context.Context, extID string, amount *float64) error {
+// Return refunds the payment fully or partially; repeated calls with the same idempotency key
+// are refunded once.
+func (s *AirbapayService) Return(ctx context.Context, extID string, amount *float64, idempotencyKey string) error {
 	token, err := s.ensureToken(ctx)
 	if err != nil {
 		return err
@@ -310,6 +312,9 @@ func (s *AirbapayService) Return(ctx context.Context, extID string, amount *floa
 	req, _ := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint.String(), bytes.NewReader(b))
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return err
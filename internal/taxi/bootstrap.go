@@ -5,30 +5,41 @@ import (
 	"net/http"
 	"time"
 
+	"naimuBack/internal/chat"
+	"naimuBack/internal/commission"
 	"naimuBack/internal/taxi/dispatch"
+	"naimuBack/internal/taxi/fatigue"
 	"naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/heatmap"
 	taxihttp "naimuBack/internal/taxi/http"
 	"naimuBack/internal/taxi/pay"
+	"naimuBack/internal/taxi/quests"
 	"naimuBack/internal/taxi/repo"
 	"naimuBack/internal/taxi/timeutil"
 	"naimuBack/internal/taxi/ws"
 )
 
 type moduleState struct {
-	geoClient     *geo.DGISClient
-	locator       *geo.DriverLocator
-	driversRepo   *repo.DriversRepo
-	ordersRepo    *repo.OrdersRepo
-	intercityRepo *repo.IntercityOrdersRepo
-	dispatchRepo  *repo.DispatchRepo
-	offersRepo    *repo.OffersRepo
-	paymentsRepo  *repo.PaymentsRepo
-	driverHub     *ws.DriverHub
-	passengerHub  *ws.PassengerHub
-	dispatcher    *dispatch.Dispatcher
-	server        *taxihttp.Server
-	payClient     *pay.Client
-	cfgAdapter    dispatch.ConfigAdapter
+	geoClient        *geo.DGISClient
+	geocoder         *geo.Geocoder
+	locator          *geo.DriverLocator
+	driversRepo      *repo.DriversRepo
+	ordersRepo       *repo.OrdersRepo
+	intercityRepo    *repo.IntercityOrdersRepo
+	dispatchRepo     *repo.DispatchRepo
+	offersRepo       *repo.OffersRepo
+	paymentsRepo     *repo.PaymentsRepo
+	destinationsRepo *repo.DestinationsRepo
+	chainsRepo       *repo.ChainsRepo
+	questsRepo       *repo.QuestsRepo
+	placesRepo       *repo.PlacesRepo
+	heatmap          *heatmap.Service
+	driverHub        *ws.DriverHub
+	passengerHub     *ws.PassengerHub
+	dispatcher       *dispatch.Dispatcher
+	server           *taxihttp.Server
+	payClient        *pay.Client
+	cfgAdapter       dispatch.ConfigAdapter
 }
 
 func ensureModule(deps *TaxiDeps) (*moduleState, error) {
@@ -49,9 +60,24 @@ func ensureModule(deps *TaxiDeps) (*moduleState, error) {
 		OfferTTL:          deps.Config.OfferTTL,
 		RegionID:          deps.Config.DGISRegionID,
 		SearchTimeout:     deps.Config.SearchTimeout,
+
+		DestinationMaxDetourM: deps.Config.DestinationMaxDetourM,
+
+		ChainMaxETA:        deps.Config.ChainMaxETA,
+		ChainPickupRadiusM: deps.Config.ChainPickupRadiusM,
+		ChainAvgSpeedKPH:   deps.Config.ChainAvgSpeedKPH,
+		ChainDelayGrace:    deps.Config.ChainDelayGrace,
+
+		BlockRatingThreshold: deps.Config.BlockRatingThreshold,
+		ChatHidePhones:       deps.Config.ChatHidePhones,
+		LiveETARefresh:       deps.Config.LiveETARefresh,
+
+		LostItemWindow:    deps.Config.LostItemWindow,
+		LostItemReturnFee: deps.Config.LostItemReturnFee,
 	}
 
 	geoClient := geo.NewDGISClient(deps.HTTPClient, deps.Config.DGISAPIKey, deps.Config.DGISRegionID)
+	geocoder := geo.NewGeocoder(geoClient, deps.RDB, deps.Config.GeocodeCacheTTL)
 	locator := geo.NewDriverLocator(deps.RDB)
 	driverHub := ws.NewDriverHub(locator, deps.Logger)
 	passengerHub := ws.NewPassengerHub(deps.Logger)
@@ -63,26 +89,106 @@ func ensureModule(deps *TaxiDeps) (*moduleState, error) {
 	dispatchRepo := repo.NewDispatchRepo(deps.DB)
 	offersRepo := repo.NewOffersRepo(deps.DB)
 	paymentsRepo := repo.NewPaymentsRepo(deps.DB)
+	destinationsRepo := repo.NewDestinationsRepo(deps.DB)
+	chainsRepo := repo.NewChainsRepo(deps.DB)
+	refundsRepo := repo.NewRefundsRepo(deps.DB)
+	blocksRepo := repo.NewBlocksRepo(deps.DB)
+	commissionRepo := commission.NewRepo(deps.DB)
+	commissionEngine := commission.NewEngine(commissionRepo, timeutil.Location())
+	questsRepo := repo.NewQuestsRepo(deps.DB)
+	questsSvc := quests.New(questsRepo, ordersRepo)
+	chatRepo := chat.NewRepo(deps.DB)
+	placesRepo := repo.NewPlacesRepo(deps.DB)
+	lostItemsRepo := repo.NewLostItemsRepo(deps.DB)
+	fatigueRepo := repo.NewFatigueRepo(deps.DB)
+	fatigueSvc := fatigue.New(fatigueRepo, fatigue.Policy{
+		MaxOnline:  deps.Config.FatigueMaxOnline,
+		Rest:       deps.Config.FatigueRest,
+		WarnBefore: deps.Config.FatigueWarnBefore,
+	})
+	heatmapSvc := heatmap.New(repo.NewHeatmapRepo(deps.DB), heatmap.Config{
+		Precision: deps.Config.HeatmapPrecision,
+		Bucket:    deps.Config.HeatmapBucket,
+		Window:    deps.Config.HeatmapWindow,
+		Settle:    deps.Config.SearchTimeout,
+	})
 
-	dispatcher := dispatch.New(ordersRepo, dispatchRepo, offersRepo, driversRepo, passengersRepo, locator, driverHub, passengerHub, deps.Logger, cfgAdapter)
+	dispatcher := dispatch.New(dispatch.Deps{
+		Orders:       ordersRepo,
+		Dispatch:     dispatchRepo,
+		Offers:       offersRepo,
+		Drivers:      driversRepo,
+		Passengers:   passengersRepo,
+		Destinations: destinationsRepo,
+		Chains:       chainsRepo,
+		Blocks:       blocksRepo,
+		Fatigue:      fatigueSvc,
+		Locator:      locator,
+		Router:       geoClient,
+		DriverWS:     driverHub,
+		PassengerWS:  passengerHub,
+	}, deps.Logger, cfgAdapter)
 	payClient := pay.NewClient(deps.HTTPClient, deps.Config.AirbaPayMerchant, deps.Config.AirbaPaySecret, deps.Config.AirbaPayCallback)
-	server := taxihttp.NewServer(deps.Logger, cfgAdapter, geoClient, driversRepo, ordersRepo, passengersRepo, intercityRepo, offersRepo, paymentsRepo, driverHub, passengerHub, dispatcher, payClient)
+	server := taxihttp.NewServer(taxihttp.ServerDeps{
+		GeoClient:             geoClient,
+		Geocoder:              geocoder,
+		Drivers:               driversRepo,
+		Orders:                ordersRepo,
+		Passengers:            passengersRepo,
+		Intercity:             intercityRepo,
+		Offers:                offersRepo,
+		Payments:              paymentsRepo,
+		Destinations:          destinationsRepo,
+		Chains:                chainsRepo,
+		Refunds:               refundsRepo,
+		Blocks:                blocksRepo,
+		CommissionRepo:        commissionRepo,
+		Commission:            commissionEngine,
+		QuestsRepo:            questsRepo,
+		Quests:                questsSvc,
+		Chat:                  chatRepo,
+		Places:                placesRepo,
+		LostItems:             lostItemsRepo,
+		FatigueRepo:           fatigueRepo,
+		Fatigue:               fatigueSvc,
+		Heatmap:               heatmapSvc,
+		LiveOps:               deps.LiveOps,
+		DriverHub:             driverHub,
+		PassengerHub:          passengerHub,
+		Dispatcher:            dispatcher,
+		PayClient:             payClient,
+		Refunder:              deps.Refunder,
+		DestinationDailyLimit: deps.Config.DestinationDailyLimit,
+	}, deps.Logger, cfgAdapter)
+	driverHub.SetDestinationHandler(server, deps.Config.DestinationArrivalRadiusM)
+	driverHub.SetPresenceHandler(server)
+	driverHub.SetChatHandler(server)
+	driverHub.SetLocationHandler(server)
+	driverHub.SetFatigueGuard(server)
+	passengerHub.SetChatHandler(server)
+	deps.LiveOps.AddSource(server.LiveOpsSource())
 
 	deps.module = &moduleState{
-		geoClient:     geoClient,
-		locator:       locator,
-		driversRepo:   driversRepo,
-		ordersRepo:    ordersRepo,
-		intercityRepo: intercityRepo,
-		dispatchRepo:  dispatchRepo,
-		offersRepo:    offersRepo,
-		paymentsRepo:  paymentsRepo,
-		driverHub:     driverHub,
-		passengerHub:  passengerHub,
-		dispatcher:    dispatcher,
-		server:        server,
-		payClient:     payClient,
-		cfgAdapter:    cfgAdapter,
+		geoClient:        geoClient,
+		geocoder:         geocoder,
+		locator:          locator,
+		driversRepo:      driversRepo,
+		ordersRepo:       ordersRepo,
+		intercityRepo:    intercityRepo,
+		dispatchRepo:     dispatchRepo,
+		offersRepo:       offersRepo,
+		paymentsRepo:     paymentsRepo,
+		destinationsRepo: destinationsRepo,
+		chainsRepo:       chainsRepo,
+		questsRepo:       questsRepo,
+		placesRepo:       placesRepo,
+		heatmap:          heatmapSvc,
+		driverHub:        driverHub,
+		passengerHub:     passengerHub,
+		dispatcher:       dispatcher,
+		server:           server,
+		payClient:        payClient,
+		cfgAdapter:       cfgAdapter,
 	}
 	return deps.module, nil
 }
@@ -105,6 +211,11 @@ func StartTaxiWorkers(ctx context.Context, deps *TaxiDeps) error {
 	}
 	go module.dispatcher.Run(ctx)
 	go module.startOfferCleanup(ctx)
+	go module.startChainWatchdog(ctx)
+	go module.startHeatmap(ctx, deps.Config.HeatmapPush, deps.Logger)
+	go module.startQuests(ctx, deps.Config.QuestTick, deps.Logger)
+	go module.startPlaceSeeding(ctx, deps.Config.GeocodeCacheTTL/2, deps.Logger)
+	go module.startFatigue(ctx, deps.Config.FatigueTick)
 	return nil
 }
 
@@ -120,3 +231,145 @@ func (m *moduleState) startOfferCleanup(ctx context.Context) {
 		}
 	}
 }
+
+// startChainWatchdog возвращает в поиск заказы из цепочек, если текущая поездка затянулась.
+func (m *moduleState) startChainWatchdog(ctx context.Context) {
+	ticker := time.NewTicker(m.cfgAdapter.DispatchTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.server.ReleaseOverdueChains(ctx)
+		}
+	}
+}
+
+// startHeatmap периодически пересчитывает тепловую карту спроса и рассылает её водителям.
+func (m *moduleState) startHeatmap(ctx context.Context, interval time.Duration, logger Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.heatmap.Refresh(ctx, timeutil.Now()); err != nil {
+				logger.Errorf("heatmap refresh failed: %v", err)
+				continue
+			}
+			m.pushHeatmap(m.heatmap.Snapshot())
+		}
+	}
+}
+
+// pushHeatmap рассылает каждому городу свой снимок: центр города — среднее положение его водителей.
+func (m *moduleState) pushHeatmap(snap heatmap.Snapshot) {
+	type cityDrivers struct {
+		ids      []int64
+		lon, lat float64
+		located  int
+	}
+	cities := make(map[string]*cityDrivers)
+	for _, d := range m.driverHub.OnlineDrivers() {
+		c, ok := cities[d.City]
+		if !ok {
+			c = &cityDrivers{}
+			cities[d.City] = c
+		}
+		c.ids = append(c.ids, d.ID)
+		if d.HasPosition {
+			c.lon += d.Position.Lon
+			c.lat += d.Position.Lat
+			c.located++
+		}
+	}
+	for _, c := range cities {
+		if c.located == 0 {
+			// ни один водитель города ещё не прислал координаты
+			continue
+		}
+		center := geo.Point{Lon: c.lon / float64(c.located), Lat: c.lat / float64(c.located)}
+		m.driverHub.SendHeatmap(c.ids, heatmapPayload(snap.ForCity(center)))
+	}
+}
+
+func heatmapPayload(snap heatmap.Snapshot) ws.DriverHeatmapPayload {
+	cells := make([]ws.HeatmapCell, 0, len(snap.Cells))
+	for _, c := range snap.Cells {
+		cells = append(cells, ws.HeatmapCell(c))
+	}
+	return ws.DriverHeatmapPayload{
+		GeneratedAt: snap.GeneratedAt.Format(time.RFC3339),
+		WindowSec:   int(snap.Window.Seconds()),
+		Precision:   snap.Precision,
+		Cells:       cells,
+	}
+}
+
+// startQuests закрывает онлайн-сессии прошлого процесса, затем периодически начисляет награды
+// за завершённые квесты и рассылает водителям прогресс по активным.
+func (m *moduleState) startQuests(ctx context.Context, interval time.Duration, logger Logger) {
+	if err := m.questsRepo.CloseOnlineSessions(ctx, timeutil.Now()); err != nil {
+		logger.Errorf("close stale online sessions failed: %v", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.server.SettleQuests(ctx)
+			m.server.PushQuestProgress(ctx)
+		}
+	}
+}
+
+// startFatigue следит за временем на линии: предупреждает водителей, отправляет на обязательный отдых
+// и возвращает после него.
+func (m *moduleState) startFatigue(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.server.EnforceFatigue(ctx)
+		}
+	}
+}
+
+// startPlaceSeeding прогревает кэш геокодера при старте и обновляет его чаще, чем истекает TTL,
+// чтобы засеянные ключи не пропадали и новые популярные места тоже попадали в кэш.
+func (m *moduleState) startPlaceSeeding(ctx context.Context, interval time.Duration, logger Logger) {
+	m.seedPlaces(ctx, logger)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.seedPlaces(ctx, logger)
+		}
+	}
+}
+
+// seedPlaces прогревает кэш геокодера популярными местами, чтобы расчёт цены до них не ходил в 2GIS.
+func (m *moduleState) seedPlaces(ctx context.Context, logger Logger) {
+	popular, err := m.placesRepo.ListPopular(ctx, "", "", 0)
+	if err != nil {
+		logger.Errorf("places: load popular places failed: %v", err)
+		return
+	}
+	places := make([]geo.Place, 0, len(popular))
+	for _, p := range popular {
+		places = append(places, geo.Place{Name: p.Name, Address: p.Address, Lon: p.Lon, Lat: p.Lat})
+	}
+	if err := m.geocoder.Seed(ctx, places); err != nil {
+		logger.Errorf("places: seed geocoder cache failed: %v", err)
+	}
+}
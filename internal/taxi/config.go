@@ -16,6 +16,36 @@ const (
 	defaultDispatchTick      = 10 * time.Second
 	defaultOfferTTL          = 10 * time.Minute
 	defaultSearchTimeout     = 10 * time.Minute
+
+	defaultDestinationDailyLimit     = 2
+	defaultDestinationMaxDetourM     = 3000
+	defaultDestinationArrivalRadiusM = 300
+
+	defaultChainMaxETA        = 5 * time.Minute
+	defaultChainPickupRadiusM = 1500
+	defaultChainAvgSpeedKPH   = 25
+	defaultChainDelayGrace    = 5 * time.Minute
+
+	defaultHeatmapPrecision = 6
+	defaultHeatmapBucket    = 15 * time.Minute
+	defaultHeatmapWindow    = time.Hour
+	defaultHeatmapPush      = time.Minute
+
+	defaultBlockRatingThreshold = 2
+
+	defaultQuestTick = time.Minute
+
+	defaultGeocodeCacheTTL = 24 * time.Hour
+
+	defaultLiveETARefresh = 15 * time.Second
+
+	defaultFatigueMaxOnline  = 12 * time.Hour
+	defaultFatigueRest       = 6 * time.Hour
+	defaultFatigueWarnBefore = 30 * time.Minute
+	defaultFatigueTick       = time.Minute
+
+	defaultLostItemWindow    = 3 * 24 * time.Hour
+	defaultLostItemReturnFee = 2000
 )
 
 // TaxiConfig holds runtime configuration for the Taxi module.
@@ -33,6 +63,49 @@ type TaxiConfig struct {
 	AirbaPayMerchant  string
 	AirbaPaySecret    string
 	AirbaPayCallback  string
+
+	// DestinationDailyLimit caps how many times per day a driver may enable the destination filter.
+	DestinationDailyLimit     int
+	DestinationMaxDetourM     int
+	DestinationArrivalRadiusM int
+
+	// ChainMaxETA limits how soon a busy driver must finish the current trip to receive a chained offer; 0 disables chaining.
+	ChainMaxETA        time.Duration
+	ChainPickupRadiusM int
+	ChainAvgSpeedKPH   int
+	ChainDelayGrace    time.Duration
+
+	// HeatmapPrecision is the geohash length of demand heatmap cells (6 ≈ 1.2×0.6 km).
+	HeatmapPrecision int
+	HeatmapBucket    time.Duration
+	HeatmapWindow    time.Duration
+	HeatmapPush      time.Duration
+
+	// BlockRatingThreshold automatically blocks the pair when a review rating is at or below it; 0 disables auto-blocking.
+	BlockRatingThreshold int
+
+	// QuestTick — как часто пересчитывается прогресс квестов и начисляются награды за завершённые.
+	QuestTick time.Duration
+
+	// ChatHidePhones hides phone numbers of the other party: passenger and driver talk through the order chat.
+	ChatHidePhones bool
+
+	// GeocodeCacheTTL — сколько живут в Redis результаты геокодинга и подсказок адресов.
+	GeocodeCacheTTL time.Duration
+
+	// LiveETARefresh limits how often the driver's ETA to pickup or destination is re-routed through 2GIS.
+	LiveETARefresh time.Duration
+
+	// FatigueMaxOnline — сколько водитель может быть на линии без перерыва не короче FatigueRest; 0 отключает контроль.
+	FatigueMaxOnline  time.Duration
+	FatigueRest       time.Duration
+	FatigueWarnBefore time.Duration
+	FatigueTick       time.Duration
+
+	// LostItemWindow — сколько после поездки пассажир может заявить о забытой вещи.
+	LostItemWindow time.Duration
+	// LostItemReturnFee is the fixed price of a return trip; the driver keeps all of it.
+	LostItemReturnFee int
 }
 
 // LoadTaxiConfig reads configuration from environment variables and applies defaults.
@@ -46,6 +119,38 @@ func LoadTaxiConfig() (TaxiConfig, error) {
 		DispatchTick:      defaultDispatchTick,
 		OfferTTL:          defaultOfferTTL,
 		SearchTimeout:     defaultSearchTimeout,
+
+		DestinationDailyLimit:     defaultDestinationDailyLimit,
+		DestinationMaxDetourM:     defaultDestinationMaxDetourM,
+		DestinationArrivalRadiusM: defaultDestinationArrivalRadiusM,
+
+		ChainMaxETA:        defaultChainMaxETA,
+		ChainPickupRadiusM: defaultChainPickupRadiusM,
+		ChainAvgSpeedKPH:   defaultChainAvgSpeedKPH,
+		ChainDelayGrace:    defaultChainDelayGrace,
+
+		HeatmapPrecision: defaultHeatmapPrecision,
+		HeatmapBucket:    defaultHeatmapBucket,
+		HeatmapWindow:    defaultHeatmapWindow,
+		HeatmapPush:      defaultHeatmapPush,
+
+		BlockRatingThreshold: defaultBlockRatingThreshold,
+
+		QuestTick: defaultQuestTick,
+
+		ChatHidePhones: true,
+
+		GeocodeCacheTTL: defaultGeocodeCacheTTL,
+
+		LiveETARefresh: defaultLiveETARefresh,
+
+		FatigueMaxOnline:  defaultFatigueMaxOnline,
+		FatigueRest:       defaultFatigueRest,
+		FatigueWarnBefore: defaultFatigueWarnBefore,
+		FatigueTick:       defaultFatigueTick,
+
+		LostItemWindow:    defaultLostItemWindow,
+		LostItemReturnFee: defaultLostItemReturnFee,
 	}
 
 	if v, err := readIntEnv("PRICE_PER_KM"); err != nil {
@@ -102,6 +207,164 @@ func LoadTaxiConfig() (TaxiConfig, error) {
 		cfg.SearchTimeout = time.Duration(secs) * time.Second
 	}
 
+	if v, err := readIntEnv("DESTINATION_DAILY_LIMIT"); err != nil {
+		return TaxiConfig{}, fmt.Errorf("parse DESTINATION_DAILY_LIMIT: %w", err)
+	} else if v != nil {
+		cfg.DestinationDailyLimit = *v
+	}
+
+	if v, err := readIntEnv("DESTINATION_MAX_DETOUR_M"); err != nil {
+		return TaxiConfig{}, fmt.Errorf("parse DESTINATION_MAX_DETOUR_M: %w", err)
+	} else if v != nil {
+		cfg.DestinationMaxDetourM = *v
+	}
+
+	if v, err := readIntEnv("DESTINATION_ARRIVAL_RADIUS_M"); err != nil {
+		return TaxiConfig{}, fmt.Errorf("parse DESTINATION_ARRIVAL_RADIUS_M: %w", err)
+	} else if v != nil {
+		cfg.DestinationArrivalRadiusM = *v
+	}
+
+	if v := os.Getenv("CHAIN_MAX_ETA_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse CHAIN_MAX_ETA_SECONDS: %w", err)
+		}
+		cfg.ChainMaxETA = time.Duration(secs) * time.Second
+	}
+
+	if v, err := readIntEnv("CHAIN_PICKUP_RADIUS_M"); err != nil {
+		return TaxiConfig{}, fmt.Errorf("parse CHAIN_PICKUP_RADIUS_M: %w", err)
+	} else if v != nil {
+		cfg.ChainPickupRadiusM = *v
+	}
+
+	if v, err := readIntEnv("CHAIN_AVG_SPEED_KPH"); err != nil {
+		return TaxiConfig{}, fmt.Errorf("parse CHAIN_AVG_SPEED_KPH: %w", err)
+	} else if v != nil {
+		cfg.ChainAvgSpeedKPH = *v
+	}
+
+	if v := os.Getenv("CHAIN_DELAY_GRACE_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse CHAIN_DELAY_GRACE_SECONDS: %w", err)
+		}
+		cfg.ChainDelayGrace = time.Duration(secs) * time.Second
+	}
+
+	if v, err := readIntEnv("HEATMAP_PRECISION"); err != nil {
+		return TaxiConfig{}, fmt.Errorf("parse HEATMAP_PRECISION: %w", err)
+	} else if v != nil {
+		cfg.HeatmapPrecision = *v
+	}
+
+	if v := os.Getenv("HEATMAP_BUCKET_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse HEATMAP_BUCKET_SECONDS: %w", err)
+		}
+		cfg.HeatmapBucket = time.Duration(secs) * time.Second
+	}
+
+	if v := os.Getenv("HEATMAP_WINDOW_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse HEATMAP_WINDOW_SECONDS: %w", err)
+		}
+		cfg.HeatmapWindow = time.Duration(secs) * time.Second
+	}
+
+	if v := os.Getenv("HEATMAP_PUSH_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse HEATMAP_PUSH_SECONDS: %w", err)
+		}
+		cfg.HeatmapPush = time.Duration(secs) * time.Second
+	}
+
+	if v, err := readIntEnv("BLOCK_RATING_THRESHOLD"); err != nil {
+		return TaxiConfig{}, fmt.Errorf("parse BLOCK_RATING_THRESHOLD: %w", err)
+	} else if v != nil {
+		cfg.BlockRatingThreshold = *v
+	}
+
+	if v := os.Getenv("QUEST_TICK_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse QUEST_TICK_SECONDS: %w", err)
+		}
+		cfg.QuestTick = time.Duration(secs) * time.Second
+	}
+
+	if v := os.Getenv("ORDER_CHAT_HIDE_PHONES"); v != "" {
+		hide, err := strconv.ParseBool(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse ORDER_CHAT_HIDE_PHONES: %w", err)
+		}
+		cfg.ChatHidePhones = hide
+	}
+
+	if v := os.Getenv("GEOCODE_CACHE_TTL_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse GEOCODE_CACHE_TTL_SECONDS: %w", err)
+		}
+		cfg.GeocodeCacheTTL = time.Duration(secs) * time.Second
+	}
+
+	if v := os.Getenv("LIVE_ETA_REFRESH_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse LIVE_ETA_REFRESH_SECONDS: %w", err)
+		}
+		cfg.LiveETARefresh = time.Duration(secs) * time.Second
+	}
+
+	if v := os.Getenv("FATIGUE_MAX_ONLINE_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse FATIGUE_MAX_ONLINE_SECONDS: %w", err)
+		}
+		cfg.FatigueMaxOnline = time.Duration(secs) * time.Second
+	}
+
+	if v := os.Getenv("FATIGUE_REST_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse FATIGUE_REST_SECONDS: %w", err)
+		}
+		cfg.FatigueRest = time.Duration(secs) * time.Second
+	}
+
+	if v := os.Getenv("FATIGUE_WARN_BEFORE_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse FATIGUE_WARN_BEFORE_SECONDS: %w", err)
+		}
+		cfg.FatigueWarnBefore = time.Duration(secs) * time.Second
+	}
+
+	if v := os.Getenv("FATIGUE_TICK_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return TaxiConfig{}, fmt.Errorf("parse FATIGUE_TICK_SECONDS: %w", err)
+		}
+		cfg.FatigueTick = time.Duration(secs) * time.Second
+	}
+
+	if v, err := readIntEnv("LOST_ITEM_WINDOW_DAYS"); err != nil {
+		return TaxiConfig{}, fmt.Errorf("parse LOST_ITEM_WINDOW_DAYS: %w", err)
+	} else if v != nil {
+		cfg.LostItemWindow = time.Duration(*v) * 24 * time.Hour
+	}
+
+	if v, err := readIntEnv("LOST_ITEM_RETURN_FEE"); err != nil {
+		return TaxiConfig{}, fmt.Errorf("parse LOST_ITEM_RETURN_FEE: %w", err)
+	} else if v != nil {
+		cfg.LostItemReturnFee = *v
+	}
+
 	cfg.DGISAPIKey = os.Getenv("DGIS_API_KEY")
 	if cfg.DGISAPIKey == "" {
 		return TaxiConfig{}, fmt.Errorf("DGIS_API_KEY is required")
@@ -126,6 +389,36 @@ func LoadTaxiConfig() (TaxiConfig, error) {
 	if cfg.SearchTimeout <= 0 {
 		return TaxiConfig{}, fmt.Errorf("SEARCH_TIMEOUT_SECONDS must be positive")
 	}
+	if cfg.DestinationDailyLimit < 0 || cfg.DestinationMaxDetourM <= 0 || cfg.DestinationArrivalRadiusM <= 0 {
+		return TaxiConfig{}, fmt.Errorf("destination mode values must be positive")
+	}
+	if cfg.ChainMaxETA < 0 || cfg.ChainPickupRadiusM <= 0 || cfg.ChainAvgSpeedKPH <= 0 || cfg.ChainDelayGrace < 0 {
+		return TaxiConfig{}, fmt.Errorf("chained order values must be positive")
+	}
+	if cfg.HeatmapPrecision < 1 || cfg.HeatmapPrecision > 12 {
+		return TaxiConfig{}, fmt.Errorf("HEATMAP_PRECISION must be between 1 and 12")
+	}
+	if cfg.HeatmapBucket <= 0 || cfg.HeatmapWindow < cfg.HeatmapBucket || cfg.HeatmapPush <= 0 {
+		return TaxiConfig{}, fmt.Errorf("heatmap intervals must be positive and window >= bucket")
+	}
+	if cfg.BlockRatingThreshold < 0 || cfg.BlockRatingThreshold > 5 {
+		return TaxiConfig{}, fmt.Errorf("BLOCK_RATING_THRESHOLD must be between 0 and 5")
+	}
+	if cfg.QuestTick <= 0 {
+		return TaxiConfig{}, fmt.Errorf("QUEST_TICK_SECONDS must be positive")
+	}
+	if cfg.GeocodeCacheTTL <= 0 {
+		return TaxiConfig{}, fmt.Errorf("GEOCODE_CACHE_TTL_SECONDS must be positive")
+	}
+	if cfg.LiveETARefresh <= 0 {
+		return TaxiConfig{}, fmt.Errorf("LIVE_ETA_REFRESH_SECONDS must be positive")
+	}
+	if cfg.FatigueMaxOnline < 0 || cfg.FatigueWarnBefore < 0 || cfg.FatigueTick <= 0 {
+		return TaxiConfig{}, fmt.Errorf("fatigue limits must be non-negative and FATIGUE_TICK_SECONDS positive")
+	}
+	if cfg.FatigueMaxOnline > 0 && cfg.FatigueRest <= 0 {
+		return TaxiConfig{}, fmt.Errorf("FATIGUE_REST_SECONDS must be positive")
+	}
 
 	return cfg, nil
 }
@@ -6,6 +6,9 @@ import (
     "net/http"
 
     "github.com/redis/go-redis/v9"
+
+    "naimuBack/internal/liveops"
+    taxihttp "naimuBack/internal/taxi/http"
 )
 
 // Logger provides minimal logging required by the Taxi module.
@@ -21,6 +24,10 @@ type TaxiDeps struct {
     Logger     Logger
     Config     TaxiConfig
     HTTPClient *http.Client
+    // Refunder returns card payments (AirbaPay); optional, refunds are unavailable without it.
+    Refunder   taxihttp.Refunder
+    // LiveOps is the shared admin live operations map; optional, SOS is unavailable without it.
+    LiveOps    *liveops.Hub
     module     *moduleState
 }
 
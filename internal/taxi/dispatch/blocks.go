@@ -0,0 +1,25 @@
+package dispatch
+
+import "context"
+
+// BlockRepository exposes passenger-driver blocks.
+type BlockRepository interface {
+	BlockedDriverIDs(ctx context.Context, passengerID int64) ([]int64, error)
+}
+
+// blockedDrivers loads drivers that must never be offered the passenger's order.
+func (d *Dispatcher) blockedDrivers(ctx context.Context, passengerID int64) map[int64]struct{} {
+	if d.blocks == nil {
+		return nil
+	}
+	ids, err := d.blocks.BlockedDriverIDs(ctx, passengerID)
+	if err != nil {
+		d.logger.Errorf("dispatch: blocks.BlockedDriverIDs(passenger=%d) failed: %v", passengerID, err)
+		return nil
+	}
+	blocked := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		blocked[id] = struct{}{}
+	}
+	return blocked
+}
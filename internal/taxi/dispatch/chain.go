@@ -0,0 +1,136 @@
+package dispatch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"naimuBack/internal/taxi/fsm"
+	"naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/repo"
+	"naimuBack/internal/taxi/ws"
+)
+
+// ChainRepository tracks queued next orders of drivers finishing a trip.
+type ChainRepository interface {
+	HasPendingByDriver(ctx context.Context, driverID int64) (bool, error)
+}
+
+// ChainETA estimates remaining seconds of the current trip and reports whether the next order
+// can be chained: the trip must be on its final leg, end near the new pickup and finish within maxETA.
+// The remaining leg is routed; without a router or when routing fails it falls back to the straight
+// line at avgSpeedKPH.
+func ChainETA(ctx context.Context, router geo.Router, current, next repo.Order, driverLon, driverLat float64, pickupRadiusM, avgSpeedKPH int, maxETA time.Duration) (int, bool) {
+	if current.Status != fsm.StatusInProgress && current.Status != fsm.StatusAtLastPoint {
+		return 0, false
+	}
+	if avgSpeedKPH <= 0 || maxETA <= 0 {
+		return 0, false
+	}
+	if geo.DistanceMeters(current.ToLon, current.ToLat, next.FromLon, next.FromLat) > float64(pickupRadiusM) {
+		return 0, false
+	}
+	eta := -1
+	if router != nil {
+		if _, duration, err := router.RouteMatrix(ctx, driverLon, driverLat, current.ToLon, current.ToLat); err == nil {
+			eta = duration
+		}
+	}
+	if eta < 0 {
+		remaining := geo.DistanceMeters(driverLon, driverLat, current.ToLon, current.ToLat)
+		eta = int(remaining / (float64(avgSpeedKPH) / 3.6))
+	}
+	if time.Duration(eta)*time.Second > maxETA {
+		return 0, false
+	}
+	return eta, true
+}
+
+// chainReachMeters — радиус поиска занятых водителей: сколько они успеют проехать до конца поездки
+// плюс допустимое расстояние от точки высадки до новой подачи.
+func chainReachMeters(cfg Config) float64 {
+	return cfg.GetChainMaxETA().Seconds()*float64(cfg.GetChainAvgSpeedKPH())/3.6 + float64(cfg.GetChainPickupRadiusM())
+}
+
+// offerChained предлагает заказ водителям, которые вот-вот завершат текущую поездку рядом с подачей.
+func (d *Dispatcher) offerChained(ctx context.Context, order repo.Order, cityKey string, ttl time.Time, passenger *ws.DriverPassenger, blocked map[int64]struct{}) int {
+	if d.chains == nil || d.cfg.GetChainMaxETA() <= 0 {
+		return 0
+	}
+	busy, err := d.locator.NearbyBusy(ctx, order.FromLon, order.FromLat, chainReachMeters(d.cfg), 20, cityKey)
+	if err != nil {
+		d.logger.Errorf("dispatch: NearbyBusy failed: %v", err)
+		return 0
+	}
+
+	candidates := d.loadCandidates(ctx, busy)
+	sent := 0
+	for _, driver := range busy {
+		if driver.ID <= 0 {
+			continue
+		}
+		if _, ok := blocked[driver.ID]; ok {
+			continue
+		}
+		// водитель на пределе онлайна доезжает текущий заказ, но новых не берёт
+		if !d.fatigueAllows(ctx, driver.ID, time.Now()) {
+			continue
+		}
+		pending, err := d.chains.HasPendingByDriver(ctx, driver.ID)
+		if err != nil {
+			d.logger.Errorf("dispatch: chains.HasPendingByDriver(driver=%d) failed: %v", driver.ID, err)
+			continue
+		}
+		if pending {
+			continue
+		}
+
+		currentID, err := d.orders.GetActiveOrderIDByDriver(ctx, driver.ID)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				d.logger.Errorf("dispatch: active order of driver %d failed: %v", driver.ID, err)
+			}
+			continue
+		}
+		current, err := d.orders.Get(ctx, currentID)
+		if err != nil {
+			d.logger.Errorf("dispatch: load current order %d failed: %v", currentID, err)
+			continue
+		}
+		eta, ok := ChainETA(ctx, d.router, current, order, driver.Lon, driver.Lat, d.cfg.GetChainPickupRadiusM(), d.cfg.GetChainAvgSpeedKPH(), d.cfg.GetChainMaxETA())
+		if !ok {
+			continue
+		}
+		vehicle, ok := d.activeVehicle(candidates, driver.ID)
+		if !ok {
+			continue
+		}
+		if !d.destinationAllows(candidates, driver, order) {
+			continue
+		}
+
+		offered, err := d.offers.AlreadyOffered(ctx, order.ID, driver.ID)
+		if err != nil {
+			d.logger.Errorf("dispatch: AlreadyOffered(order=%d,driver=%d) failed: %v", order.ID, driver.ID, err)
+			continue
+		}
+		if offered {
+			continue
+		}
+		if err := d.offers.CreateOffer(ctx, order.ID, driver.ID, ttl); err != nil {
+			d.logger.Errorf("dispatch: CreateOffer(order=%d,driver=%d) failed: %v", order.ID, driver.ID, err)
+			continue
+		}
+
+		payload := d.buildOfferPayload(order, passenger)
+		payload.Chained = true
+		payload.AfterOrderID = current.ID
+		payload.CurrentTripEtaSec = eta
+		payload.Vehicle = vehicle
+		d.driverWS.SendOffer(driver.ID, payload)
+		sent++
+		d.logger.Infof("✅ dispatch: chained offer order=%d → driver=%d (current=%d, eta=%ds)", order.ID, driver.ID, current.ID, eta)
+	}
+	return sent
+}
@@ -0,0 +1,31 @@
+package dispatch
+
+import (
+	"context"
+
+	"naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/repo"
+)
+
+// DestinationRepository exposes active "going home" destinations of drivers.
+type DestinationRepository interface {
+	ActiveByDrivers(ctx context.Context, driverIDs []int64) (map[int64]repo.DriverDestination, error)
+}
+
+// DestinationAllows reports whether the order keeps the driver on the way to the destination.
+// The drop-off must bring the driver closer to the destination and the total detour
+// (driver → pickup → drop-off → destination compared to driver → destination) must not exceed maxDetourM.
+func DestinationAllows(dest repo.DriverDestination, driverLon, driverLat float64, order repo.Order) bool {
+	direct := geo.DistanceMeters(driverLon, driverLat, dest.Lon, dest.Lat)
+	dropToDest := geo.DistanceMeters(order.ToLon, order.ToLat, dest.Lon, dest.Lat)
+	if dropToDest >= direct {
+		return false
+	}
+	if dest.MaxDetourM <= 0 {
+		return true
+	}
+	viaOrder := geo.DistanceMeters(driverLon, driverLat, order.FromLon, order.FromLat) +
+		geo.DistanceMeters(order.FromLon, order.FromLat, order.ToLon, order.ToLat) +
+		dropToDest
+	return viaOrder-direct <= float64(dest.MaxDetourM)
+}
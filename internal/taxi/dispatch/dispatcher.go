@@ -31,12 +31,23 @@ type Config interface {
 	GetOfferTTL() time.Duration
 	GetRegionID() string
 	GetSearchTimeout() time.Duration
+	GetDestinationMaxDetourM() int
+	GetChainMaxETA() time.Duration
+	GetChainPickupRadiusM() int
+	GetChainAvgSpeedKPH() int
+	GetChainDelayGrace() time.Duration
+	GetBlockRatingThreshold() int
+	GetChatHidePhones() bool
+	GetLiveETARefresh() time.Duration
+	GetLostItemWindow() time.Duration
+	GetLostItemReturnFee() int
 }
 
 // Dispatcher performs periodic matching between orders and drivers.
 type OrdersRepository interface {
 	Get(ctx context.Context, id int64) (repo.Order, error)
 	UpdateStatusCAS(ctx context.Context, orderID int64, fromStatus, toStatus string) error
+	GetActiveOrderIDByDriver(ctx context.Context, driverID int64) (int64, error)
 }
 
 type DispatchRepository interface {
@@ -64,29 +75,54 @@ type PassengerRepository interface {
 
 type driverLocator interface {
 	Nearby(ctx context.Context, lon, lat float64, radiusMeters float64, limit int, city string) ([]geo.NearbyDriver, error)
+	NearbyBusy(ctx context.Context, lon, lat float64, radiusMeters float64, limit int, city string) ([]geo.NearbyDriver, error)
 	GoOffline(ctx context.Context, driverID int64, city string) error
 }
 
 type DriversRepository interface {
 	Exists(ctx context.Context, driverID int64) (bool, error)
+	ActiveVehicles(ctx context.Context, driverIDs []int64) (map[int64]repo.Vehicle, error)
 }
 
 type Dispatcher struct {
-	orders      OrdersRepository
-	dispatch    DispatchRepository
-	offers      OffersRepository
-	drivers     DriversRepository
-	passengers  PassengerRepository
-	locator     driverLocator
-	driverWS    DriverNotifier
-	passengerWS PassengerNotifier
-	logger      Logger
-	cfg         Config
+	orders       OrdersRepository
+	dispatch     DispatchRepository
+	offers       OffersRepository
+	drivers      DriversRepository
+	passengers   PassengerRepository
+	destinations DestinationRepository
+	chains       ChainRepository
+	blocks       BlockRepository
+	fatigue      FatigueGuard
+	locator      driverLocator
+	router       geo.Router
+	driverWS     DriverNotifier
+	passengerWS  PassengerNotifier
+	logger       Logger
+	cfg          Config
+}
+
+// Deps groups the repositories and services used by the dispatcher.
+// Drivers, Destinations, Chains, Blocks, Fatigue and Router are optional.
+type Deps struct {
+	Orders       OrdersRepository
+	Dispatch     DispatchRepository
+	Offers       OffersRepository
+	Drivers      DriversRepository
+	Passengers   PassengerRepository
+	Destinations DestinationRepository
+	Chains       ChainRepository
+	Blocks       BlockRepository
+	Fatigue      FatigueGuard
+	Locator      driverLocator
+	Router       geo.Router
+	DriverWS     DriverNotifier
+	PassengerWS  PassengerNotifier
 }
 
 // New creates a dispatcher instance.
-func New(orders OrdersRepository, dispatch DispatchRepository, offers OffersRepository, drivers DriversRepository, passengers PassengerRepository, locator driverLocator, driverWS DriverNotifier, passengerWS PassengerNotifier, logger Logger, cfg Config) *Dispatcher {
-	return &Dispatcher{orders: orders, dispatch: dispatch, offers: offers, drivers: drivers, passengers: passengers, locator: locator, driverWS: driverWS, passengerWS: passengerWS, logger: logger, cfg: cfg}
+func New(deps Deps, logger Logger, cfg Config) *Dispatcher {
+	return &Dispatcher{orders: deps.Orders, dispatch: deps.Dispatch, offers: deps.Offers, drivers: deps.Drivers, passengers: deps.Passengers, destinations: deps.Destinations, chains: deps.Chains, blocks: deps.Blocks, fatigue: deps.Fatigue, locator: deps.Locator, router: deps.Router, driverWS: deps.DriverWS, passengerWS: deps.PassengerWS, logger: logger, cfg: cfg}
 }
 
 // Run starts the dispatcher loop.
@@ -169,15 +205,28 @@ func (d *Dispatcher) processRecord(ctx context.Context, rec repo.DispatchRecord,
 			}
 		} else {
 			payload := mapPassengerToWS(passenger)
+			if d.cfg.GetChatHidePhones() {
+				// пассажир и водитель общаются через чат заказа
+				payload.Phone = ""
+			}
 			passengerPayload = &payload
 		}
 	}
 
+	blocked := d.blockedDrivers(ctx, order.PassengerID)
+	candidates := d.loadCandidates(ctx, drivers)
+
 	for _, driver := range drivers {
 		if driver.ID <= 0 {
 			d.logger.Errorf("dispatch: skip invalid driver id=%d (from locator)", driver.ID)
 			continue
 		}
+		if _, ok := blocked[driver.ID]; ok {
+			continue
+		}
+		if !d.fatigueAllows(ctx, driver.ID, now) {
+			continue
+		}
 
 		if d.drivers != nil {
 			ok, err := d.drivers.Exists(ctx, driver.ID)
@@ -194,6 +243,14 @@ func (d *Dispatcher) processRecord(ctx context.Context, rec repo.DispatchRecord,
 			}
 		}
 
+		vehicle, ok := d.activeVehicle(candidates, driver.ID)
+		if !ok {
+			continue
+		}
+		if !d.destinationAllows(candidates, driver, order) {
+			continue
+		}
+
 		offered, err := d.offers.AlreadyOffered(ctx, order.ID, driver.ID)
 		if err != nil {
 			d.logger.Errorf("dispatch: AlreadyOffered(order=%d,driver=%d) failed: %v", order.ID, driver.ID, err)
@@ -211,34 +268,16 @@ func (d *Dispatcher) processRecord(ctx context.Context, rec repo.DispatchRecord,
 			continue
 		}
 
-		payload := ws.DriverOfferPayload{
-			OrderID:      order.ID,
-			FromLon:      order.FromLon,
-			FromLat:      order.FromLat,
-			ToLon:        order.ToLon,
-			ToLat:        order.ToLat,
-			ClientPrice:  order.ClientPrice,
-			DistanceM:    order.DistanceM,
-			EtaSeconds:   order.EtaSeconds,
-			ExpiresInSec: int(d.cfg.GetOfferTTL().Seconds()),
-			Passenger:    passengerPayload,
-		}
-		if len(order.Addresses) > 0 {
-			route := make([]ws.DriverRoutePoint, 0, len(order.Addresses))
-			for _, addr := range order.Addresses {
-				point := ws.DriverRoutePoint{Lon: addr.Lon, Lat: addr.Lat}
-				if addr.Address.Valid {
-					point.Address = addr.Address.String
-				}
-				route = append(route, point)
-			}
-			payload.Route = route
-		}
+		payload := d.buildOfferPayload(order, passengerPayload)
+		payload.Vehicle = vehicle
 		d.driverWS.SendOffer(driver.ID, payload)
 		sentOffers++
 		d.logger.Infof("✅ dispatch: offer created & sent order=%d → driver=%d (ttl=%s)", order.ID, driver.ID, ttl.Format(time.RFC3339))
 	}
 
+	// Заказы "по цепочке": водители, завершающие поездку рядом с подачей
+	sentOffers += d.offerChained(ctx, order, cityKey, ttl, passengerPayload, blocked)
+
 	// Планирование следующего тика
 	switch {
 	case len(drivers) == 0:
@@ -285,6 +324,98 @@ func (d *Dispatcher) processRecord(ctx context.Context, rec repo.DispatchRecord,
 	return nil
 }
 
+func (d *Dispatcher) buildOfferPayload(order repo.Order, passenger *ws.DriverPassenger) ws.DriverOfferPayload {
+	payload := ws.DriverOfferPayload{
+		OrderID:      order.ID,
+		FromLon:      order.FromLon,
+		FromLat:      order.FromLat,
+		ToLon:        order.ToLon,
+		ToLat:        order.ToLat,
+		ClientPrice:  order.ClientPrice,
+		DistanceM:    order.DistanceM,
+		EtaSeconds:   order.EtaSeconds,
+		ExpiresInSec: int(d.cfg.GetOfferTTL().Seconds()),
+		Passenger:    passenger,
+	}
+	if len(order.Addresses) > 0 {
+		route := make([]ws.DriverRoutePoint, 0, len(order.Addresses))
+		for _, addr := range order.Addresses {
+			point := ws.DriverRoutePoint{Lon: addr.Lon, Lat: addr.Lat, LegPolyline: addr.LegPolyline.String}
+			if addr.Address.Valid {
+				point.Address = addr.Address.String
+			}
+			route = append(route, point)
+		}
+		payload.Route = route
+	}
+	return payload
+}
+
+// candidateData — машины и направления кандидатов тика, загруженные пачкой,
+// а не отдельными запросами на каждого водителя.
+type candidateData struct {
+	vehicles     map[int64]repo.Vehicle
+	destinations map[int64]repo.DriverDestination
+}
+
+func (d *Dispatcher) loadCandidates(ctx context.Context, drivers []geo.NearbyDriver) candidateData {
+	var c candidateData
+	ids := make([]int64, 0, len(drivers))
+	for _, driver := range drivers {
+		if driver.ID > 0 {
+			ids = append(ids, driver.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return c
+	}
+	var err error
+	if d.drivers != nil {
+		if c.vehicles, err = d.drivers.ActiveVehicles(ctx, ids); err != nil {
+			d.logger.Errorf("dispatch: drivers.ActiveVehicles(%d drivers) failed: %v", len(ids), err)
+		}
+	}
+	if d.destinations != nil {
+		if c.destinations, err = d.destinations.ActiveByDrivers(ctx, ids); err != nil {
+			d.logger.Errorf("dispatch: destinations.ActiveByDrivers(%d drivers) failed: %v", len(ids), err)
+		}
+	}
+	return c
+}
+
+// activeVehicle возвращает активную машину водителя для оффера. Водитель без
+// одобренной активной машины предложения не получает.
+func (d *Dispatcher) activeVehicle(c candidateData, driverID int64) (*ws.DriverOfferVehicle, bool) {
+	if d.drivers == nil {
+		return nil, true
+	}
+	v, ok := c.vehicles[driverID]
+	if !ok {
+		return nil, false
+	}
+	return &ws.DriverOfferVehicle{
+		ID:          v.ID,
+		CarModel:    v.CarModel.String,
+		CarColor:    v.CarColor.String,
+		CarNumber:   v.CarNumber,
+		TariffClass: v.TariffClass,
+	}, true
+}
+
+// destinationAllows применяет фильтр "еду домой": водителю с активным направлением
+// предлагаем только заказы, которые приближают его к цели.
+func (d *Dispatcher) destinationAllows(c candidateData, driver geo.NearbyDriver, order repo.Order) bool {
+	dest, ok := c.destinations[driver.ID]
+	if !ok {
+		return true
+	}
+	if !DestinationAllows(dest, driver.Lon, driver.Lat, order) {
+		d.logger.Infof("dispatch: skip driver %d for order %d (destination filter)", driver.ID, order.ID)
+		return false
+	}
+	return true
+}
+
 func mapPassengerToWS(p repo.Passenger) ws.DriverPassenger {
 	result := ws.DriverPassenger{
 		ID:        p.ID,
@@ -354,17 +485,43 @@ type ConfigAdapter struct {
 	OfferTTL          time.Duration
 	RegionID          string
 	SearchTimeout     time.Duration
+
+	DestinationMaxDetourM int
+
+	ChainMaxETA        time.Duration
+	ChainPickupRadiusM int
+	ChainAvgSpeedKPH   int
+	ChainDelayGrace    time.Duration
+
+	BlockRatingThreshold int
+
+	ChatHidePhones bool
+
+	LiveETARefresh time.Duration
+
+	LostItemWindow    time.Duration
+	LostItemReturnFee int
 }
 
-func (c ConfigAdapter) GetPricePerKM() int              { return c.PricePerKM }
-func (c ConfigAdapter) GetMinPrice() int                { return c.MinPrice }
-func (c ConfigAdapter) GetSearchRadiusStart() int       { return c.SearchRadiusStart }
-func (c ConfigAdapter) GetSearchRadiusStep() int        { return c.SearchRadiusStep }
-func (c ConfigAdapter) GetSearchRadiusMax() int         { return c.SearchRadiusMax }
-func (c ConfigAdapter) GetDispatchTick() time.Duration  { return c.DispatchTick }
-func (c ConfigAdapter) GetOfferTTL() time.Duration      { return c.OfferTTL }
-func (c ConfigAdapter) GetRegionID() string             { return c.RegionID }
-func (c ConfigAdapter) GetSearchTimeout() time.Duration { return c.SearchTimeout }
+func (c ConfigAdapter) GetPricePerKM() int                { return c.PricePerKM }
+func (c ConfigAdapter) GetMinPrice() int                  { return c.MinPrice }
+func (c ConfigAdapter) GetSearchRadiusStart() int         { return c.SearchRadiusStart }
+func (c ConfigAdapter) GetSearchRadiusStep() int          { return c.SearchRadiusStep }
+func (c ConfigAdapter) GetSearchRadiusMax() int           { return c.SearchRadiusMax }
+func (c ConfigAdapter) GetDispatchTick() time.Duration    { return c.DispatchTick }
+func (c ConfigAdapter) GetOfferTTL() time.Duration        { return c.OfferTTL }
+func (c ConfigAdapter) GetRegionID() string               { return c.RegionID }
+func (c ConfigAdapter) GetSearchTimeout() time.Duration   { return c.SearchTimeout }
+func (c ConfigAdapter) GetDestinationMaxDetourM() int     { return c.DestinationMaxDetourM }
+func (c ConfigAdapter) GetChainMaxETA() time.Duration     { return c.ChainMaxETA }
+func (c ConfigAdapter) GetChainPickupRadiusM() int        { return c.ChainPickupRadiusM }
+func (c ConfigAdapter) GetChainAvgSpeedKPH() int          { return c.ChainAvgSpeedKPH }
+func (c ConfigAdapter) GetChainDelayGrace() time.Duration { return c.ChainDelayGrace }
+func (c ConfigAdapter) GetBlockRatingThreshold() int      { return c.BlockRatingThreshold }
+func (c ConfigAdapter) GetChatHidePhones() bool           { return c.ChatHidePhones }
+func (c ConfigAdapter) GetLiveETARefresh() time.Duration  { return c.LiveETARefresh }
+func (c ConfigAdapter) GetLostItemWindow() time.Duration  { return c.LostItemWindow }
+func (c ConfigAdapter) GetLostItemReturnFee() int         { return c.LostItemReturnFee }
 
 // RecalculateRecommendedPrice recalculates price based on distance.
 func RecalculateRecommendedPrice(distanceM int, cfg Config) int {
@@ -2,6 +2,8 @@ package dispatch
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -32,6 +34,10 @@ func (s *stubOrders) UpdateStatusCAS(ctx context.Context, orderID int64, fromSta
 	return nil
 }
 
+func (s *stubOrders) GetActiveOrderIDByDriver(ctx context.Context, driverID int64) (int64, error) {
+	return 0, sql.ErrNoRows
+}
+
 type stubDispatch struct {
 	radius   int
 	next     time.Time
@@ -107,6 +113,14 @@ func (s *stubLocator) Nearby(ctx context.Context, lon, lat float64, radiusMeters
 	return s.drivers, nil
 }
 
+func (s *stubLocator) NearbyBusy(ctx context.Context, lon, lat float64, radiusMeters float64, limit int, city string) ([]geo.NearbyDriver, error) {
+	return nil, nil
+}
+
+func (s *stubLocator) GoOffline(ctx context.Context, driverID int64, city string) error {
+	return nil
+}
+
 func TestDispatcherRadiusExpansion(t *testing.T) {
 	locator := &stubLocator{}
 	orders := &stubOrders{order: repo.Order{ID: 1, PassengerID: 10, FromLon: 76.9, FromLat: 43.2, Status: "searching"}}
@@ -128,7 +142,7 @@ func TestDispatcherRadiusExpansion(t *testing.T) {
 		SearchTimeout:     time.Hour,
 	}
 
-	d := New(orders, dispatchRepo, offers, passengers, locator, driverHub, passengerHub, testLogger{}, cfg)
+	d := New(Deps{Orders: orders, Dispatch: dispatchRepo, Offers: offers, Passengers: passengers, Locator: locator, DriverWS: driverHub, PassengerWS: passengerHub}, testLogger{}, cfg)
 
 	now := time.Now()
 	rec := repo.DispatchRecord{OrderID: 1, RadiusM: cfg.SearchRadiusStart, NextTickAt: now, CreatedAt: now}
@@ -168,7 +182,7 @@ func TestDispatcherDuplicatesExpandRadius(t *testing.T) {
 		SearchTimeout:     time.Hour,
 	}
 
-	d := New(orders, dispatchRepo, offers, passengers, locator, driverHub, passengerHub, testLogger{}, cfg)
+	d := New(Deps{Orders: orders, Dispatch: dispatchRepo, Offers: offers, Passengers: passengers, Locator: locator, DriverWS: driverHub, PassengerWS: passengerHub}, testLogger{}, cfg)
 
 	now := time.Now()
 	rec := repo.DispatchRecord{OrderID: 1, RadiusM: cfg.SearchRadiusStart, NextTickAt: now, CreatedAt: now}
@@ -213,7 +227,7 @@ func TestDispatcherTimeoutCancelsOrder(t *testing.T) {
 		SearchTimeout:     timeout,
 	}
 
-	d := New(orders, dispatchRepo, offers, passengers, locator, driverHub, passengerHub, testLogger{}, cfg)
+	d := New(Deps{Orders: orders, Dispatch: dispatchRepo, Offers: offers, Passengers: passengers, Locator: locator, DriverWS: driverHub, PassengerWS: passengerHub}, testLogger{}, cfg)
 
 	now := time.Now()
 	rec := repo.DispatchRecord{OrderID: 1, RadiusM: cfg.SearchRadiusStart, NextTickAt: now, CreatedAt: now.Add(-timeout - time.Minute)}
@@ -233,3 +247,267 @@ func TestDispatcherTimeoutCancelsOrder(t *testing.T) {
 		t.Fatalf("unexpected passenger event: %+v", passengerHub.events[0])
 	}
 }
+
+func TestDestinationAllows(t *testing.T) {
+	// водитель в центре, цель на востоке
+	dest := repo.DriverDestination{Lon: 71.50, Lat: 51.10, MaxDetourM: 3000}
+	driverLon, driverLat := 71.40, 51.10
+
+	along := repo.Order{FromLon: 71.405, FromLat: 51.10, ToLon: 71.48, ToLat: 51.10}
+	if !DestinationAllows(dest, driverLon, driverLat, along) {
+		t.Fatalf("expected order along the way to be allowed")
+	}
+
+	backwards := repo.Order{FromLon: 71.405, FromLat: 51.10, ToLon: 71.30, ToLat: 51.10}
+	if DestinationAllows(dest, driverLon, driverLat, backwards) {
+		t.Fatalf("expected order away from destination to be rejected")
+	}
+
+	detour := repo.Order{FromLon: 71.40, FromLat: 51.15, ToLon: 71.49, ToLat: 51.14}
+	if DestinationAllows(dest, driverLon, driverLat, detour) {
+		t.Fatalf("expected order with large detour to be rejected")
+	}
+}
+
+func TestChainETA(t *testing.T) {
+	current := repo.Order{ID: 1, Status: "in_progress", ToLon: 71.43, ToLat: 51.13}
+	next := repo.Order{ID: 2, FromLon: 71.435, FromLat: 51.13}
+
+	// ~1.4 км до точки высадки при 25 км/ч ≈ 200 с
+	eta, ok := ChainETA(context.Background(), nil, current, next, 71.41, 51.13, 1500, 25, 5*time.Minute)
+	if !ok || eta <= 0 || eta > 300 {
+		t.Fatalf("expected chain with eta<=300s, got eta=%d ok=%v", eta, ok)
+	}
+
+	if _, ok := ChainETA(context.Background(), nil, current, next, 71.30, 51.13, 1500, 25, 5*time.Minute); ok {
+		t.Fatalf("expected driver far from drop-off to be rejected")
+	}
+
+	far := repo.Order{ID: 3, FromLon: 71.50, FromLat: 51.13}
+	if _, ok := ChainETA(context.Background(), nil, current, far, 71.41, 51.13, 1500, 25, 5*time.Minute); ok {
+		t.Fatalf("expected pickup far from drop-off to be rejected")
+	}
+
+	assigned := current
+	assigned.Status = "driver_at_pickup"
+	if _, ok := ChainETA(context.Background(), nil, assigned, next, 71.41, 51.13, 1500, 25, 5*time.Minute); ok {
+		t.Fatalf("expected trip not yet started to be rejected")
+	}
+}
+
+type stubRouter struct {
+	duration int
+	err      error
+}
+
+func (r stubRouter) RouteMatrix(ctx context.Context, fromLon, fromLat, toLon, toLat float64) (int, int, error) {
+	return 0, r.duration, r.err
+}
+
+func TestChainETARouted(t *testing.T) {
+	current := repo.Order{ID: 1, Status: "in_progress", ToLon: 71.43, ToLat: 51.13}
+	next := repo.Order{ID: 2, FromLon: 71.435, FromLat: 51.13}
+
+	eta, ok := ChainETA(context.Background(), stubRouter{duration: 240}, current, next, 71.41, 51.13, 1500, 25, 5*time.Minute)
+	if !ok || eta != 240 {
+		t.Fatalf("expected routed eta=240, got eta=%d ok=%v", eta, ok)
+	}
+
+	// по прямой успевает, но дорога длиннее лимита
+	if _, ok := ChainETA(context.Background(), stubRouter{duration: 400}, current, next, 71.41, 51.13, 1500, 25, 5*time.Minute); ok {
+		t.Fatalf("expected routed eta over the limit to be rejected")
+	}
+
+	eta, ok = ChainETA(context.Background(), stubRouter{err: errors.New("router down")}, current, next, 71.41, 51.13, 1500, 25, 5*time.Minute)
+	if !ok || eta <= 0 || eta > 300 {
+		t.Fatalf("expected straight-line fallback, got eta=%d ok=%v", eta, ok)
+	}
+}
+
+type stubBlocks struct{ ids []int64 }
+
+func (s stubBlocks) BlockedDriverIDs(ctx context.Context, passengerID int64) ([]int64, error) {
+	return s.ids, nil
+}
+
+func TestDispatcherSkipsBlockedDrivers(t *testing.T) {
+	locator := &stubLocator{drivers: []geo.NearbyDriver{{ID: 7}, {ID: 8}}}
+	orders := &stubOrders{order: repo.Order{ID: 1, PassengerID: 10, FromLon: 76.9, FromLat: 43.2, Status: "searching"}}
+	dispatchRepo := &stubDispatch{}
+	driverHub := &stubDriverHub{}
+
+	cfg := ConfigAdapter{
+		SearchRadiusStart: 800,
+		SearchRadiusStep:  400,
+		SearchRadiusMax:   3000,
+		DispatchTick:      time.Minute,
+		OfferTTL:          20 * time.Second,
+		RegionID:          "test",
+		SearchTimeout:     time.Hour,
+	}
+
+	d := New(Deps{Orders: orders, Dispatch: dispatchRepo, Offers: stubOffers{}, Passengers: &stubPassengers{}, Blocks: stubBlocks{ids: []int64{7}}, Locator: locator, DriverWS: driverHub, PassengerWS: &stubPassengerHub{}}, testLogger{}, cfg)
+
+	now := time.Now()
+	rec := repo.DispatchRecord{OrderID: 1, RadiusM: cfg.SearchRadiusStart, NextTickAt: now, CreatedAt: now}
+	if err := d.processRecord(context.Background(), rec, now); err != nil {
+		t.Fatalf("processRecord error: %v", err)
+	}
+	if driverHub.sent != 1 {
+		t.Fatalf("expected only the unblocked driver to get an offer, got %d offers", driverHub.sent)
+	}
+}
+
+type stubFatigue struct {
+	tired map[int64]bool
+}
+
+func (s stubFatigue) OffersAllowed(ctx context.Context, driverID int64, now time.Time) bool {
+	return !s.tired[driverID]
+}
+
+func TestDispatcherSkipsTiredDrivers(t *testing.T) {
+	locator := &stubLocator{drivers: []geo.NearbyDriver{{ID: 7}, {ID: 8}}}
+	orders := &stubOrders{order: repo.Order{ID: 1, PassengerID: 10, FromLon: 76.9, FromLat: 43.2, Status: "searching"}}
+	dispatchRepo := &stubDispatch{}
+	driverHub := &stubDriverHub{}
+
+	cfg := ConfigAdapter{
+		SearchRadiusStart: 800,
+		SearchRadiusStep:  400,
+		SearchRadiusMax:   3000,
+		DispatchTick:      time.Minute,
+		OfferTTL:          20 * time.Second,
+		RegionID:          "test",
+		SearchTimeout:     time.Hour,
+	}
+
+	d := New(Deps{Orders: orders, Dispatch: dispatchRepo, Offers: stubOffers{}, Passengers: &stubPassengers{}, Fatigue: stubFatigue{tired: map[int64]bool{8: true}}, Locator: locator, DriverWS: driverHub, PassengerWS: &stubPassengerHub{}}, testLogger{}, cfg)
+
+	now := time.Now()
+	rec := repo.DispatchRecord{OrderID: 1, RadiusM: cfg.SearchRadiusStart, NextTickAt: now, CreatedAt: now}
+	if err := d.processRecord(context.Background(), rec, now); err != nil {
+		t.Fatalf("processRecord error: %v", err)
+	}
+	if driverHub.sent != 1 {
+		t.Fatalf("expected only the rested driver to get an offer, got %d offers", driverHub.sent)
+	}
+}
+
+type stubVehicles struct {
+	active map[int64]repo.Vehicle
+}
+
+func (s stubVehicles) Exists(ctx context.Context, driverID int64) (bool, error) { return true, nil }
+
+func (s stubVehicles) ActiveVehicles(ctx context.Context, driverIDs []int64) (map[int64]repo.Vehicle, error) {
+	result := make(map[int64]repo.Vehicle)
+	for _, id := range driverIDs {
+		if v, ok := s.active[id]; ok {
+			result[id] = v
+		}
+	}
+	return result, nil
+}
+
+type recordingDriverHub struct {
+	offers map[int64]ws.DriverOfferPayload
+}
+
+func (s *recordingDriverHub) SendOffer(driverID int64, payload ws.DriverOfferPayload) {
+	s.offers[driverID] = payload
+}
+
+func TestDispatcherRequiresActiveVehicle(t *testing.T) {
+	locator := &stubLocator{drivers: []geo.NearbyDriver{{ID: 7}, {ID: 8}}}
+	orders := &stubOrders{order: repo.Order{ID: 1, PassengerID: 10, FromLon: 76.9, FromLat: 43.2, Status: "searching"}}
+	dispatchRepo := &stubDispatch{}
+	driverHub := &recordingDriverHub{offers: map[int64]ws.DriverOfferPayload{}}
+	drivers := stubVehicles{active: map[int64]repo.Vehicle{7: {ID: 70, DriverID: 7, CarNumber: "777AAA01", TariffClass: "comfort"}}}
+
+	cfg := ConfigAdapter{
+		SearchRadiusStart: 800,
+		SearchRadiusStep:  400,
+		SearchRadiusMax:   3000,
+		DispatchTick:      time.Minute,
+		OfferTTL:          20 * time.Second,
+		RegionID:          "test",
+		SearchTimeout:     time.Hour,
+	}
+
+	d := New(Deps{Orders: orders, Dispatch: dispatchRepo, Offers: stubOffers{}, Drivers: drivers, Passengers: &stubPassengers{}, Locator: locator, DriverWS: driverHub, PassengerWS: &stubPassengerHub{}}, testLogger{}, cfg)
+
+	now := time.Now()
+	rec := repo.DispatchRecord{OrderID: 1, RadiusM: cfg.SearchRadiusStart, NextTickAt: now, CreatedAt: now}
+	if err := d.processRecord(context.Background(), rec, now); err != nil {
+		t.Fatalf("processRecord error: %v", err)
+	}
+	if len(driverHub.offers) != 1 {
+		t.Fatalf("expected only the driver with an active vehicle to get an offer, got %d offers", len(driverHub.offers))
+	}
+	offer, ok := driverHub.offers[7]
+	if !ok || offer.Vehicle == nil {
+		t.Fatalf("expected offer for driver 7 with vehicle, got %+v", offer)
+	}
+	if offer.Vehicle.ID != 70 || offer.Vehicle.CarNumber != "777AAA01" || offer.Vehicle.TariffClass != "comfort" {
+		t.Fatalf("unexpected offer vehicle %+v", offer.Vehicle)
+	}
+}
+
+type stubDestinations struct {
+	active map[int64]repo.DriverDestination
+	calls  int
+}
+
+func (s *stubDestinations) ActiveByDrivers(ctx context.Context, driverIDs []int64) (map[int64]repo.DriverDestination, error) {
+	s.calls++
+	result := make(map[int64]repo.DriverDestination)
+	for _, id := range driverIDs {
+		if d, ok := s.active[id]; ok {
+			result[id] = d
+		}
+	}
+	return result, nil
+}
+
+func TestDispatcherLoadsDestinationsOnce(t *testing.T) {
+	locator := &stubLocator{drivers: []geo.NearbyDriver{{ID: 7, Lon: 76.9, Lat: 43.2}, {ID: 8, Lon: 76.9, Lat: 43.2}, {ID: 9, Lon: 76.9, Lat: 43.2}}}
+	// заказ везёт на север, водитель 8 едет домой на юг
+	orders := &stubOrders{order: repo.Order{ID: 1, PassengerID: 10, FromLon: 76.9, FromLat: 43.2, ToLon: 76.9, ToLat: 43.3, Status: "searching"}}
+	dispatchRepo := &stubDispatch{}
+	driverHub := &recordingDriverHub{offers: map[int64]ws.DriverOfferPayload{}}
+	destinations := &stubDestinations{active: map[int64]repo.DriverDestination{
+		8: {DriverID: 8, Lon: 76.9, Lat: 43.1},
+		9: {DriverID: 9, Lon: 76.9, Lat: 43.35},
+	}}
+
+	cfg := ConfigAdapter{
+		SearchRadiusStart: 800,
+		SearchRadiusStep:  400,
+		SearchRadiusMax:   3000,
+		DispatchTick:      time.Minute,
+		OfferTTL:          20 * time.Second,
+		RegionID:          "test",
+		SearchTimeout:     time.Hour,
+	}
+
+	d := New(Deps{Orders: orders, Dispatch: dispatchRepo, Offers: stubOffers{}, Passengers: &stubPassengers{}, Destinations: destinations, Locator: locator, DriverWS: driverHub, PassengerWS: &stubPassengerHub{}}, testLogger{}, cfg)
+
+	now := time.Now()
+	rec := repo.DispatchRecord{OrderID: 1, RadiusM: cfg.SearchRadiusStart, NextTickAt: now, CreatedAt: now}
+	if err := d.processRecord(context.Background(), rec, now); err != nil {
+		t.Fatalf("processRecord error: %v", err)
+	}
+	if destinations.calls != 1 {
+		t.Fatalf("expected one destinations lookup per tick, got %d", destinations.calls)
+	}
+	if _, ok := driverHub.offers[8]; ok {
+		t.Fatalf("driver 8 heading the other way must not get the offer")
+	}
+	if _, ok := driverHub.offers[7]; !ok {
+		t.Fatalf("driver 7 without destination expected to get the offer")
+	}
+	if _, ok := driverHub.offers[9]; !ok {
+		t.Fatalf("driver 9 heading along the order expected to get the offer")
+	}
+}
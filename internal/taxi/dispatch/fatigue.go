@@ -0,0 +1,18 @@
+package dispatch
+
+import (
+	"context"
+	"time"
+)
+
+// FatigueGuard refuses offers to drivers who reached the online time limit or are on a mandatory break.
+type FatigueGuard interface {
+	OffersAllowed(ctx context.Context, driverID int64, now time.Time) bool
+}
+
+func (d *Dispatcher) fatigueAllows(ctx context.Context, driverID int64, now time.Time) bool {
+	if d.fatigue == nil {
+		return true
+	}
+	return d.fatigue.OffersAllowed(ctx, driverID, now)
+}
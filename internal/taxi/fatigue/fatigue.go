@@ -0,0 +1,194 @@
+package fatigue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"naimuBack/internal/taxi/repo"
+)
+
+// Fatigue states of a driver.
+const (
+	StateOK      = "ok"
+	StateWarning = "warning"
+	StateLimit   = "limit"
+	StateResting = "resting"
+)
+
+// statusCacheTTL — сколько диспетчер доверяет закэшированному статусу, не перечитывая сессии.
+const statusCacheTTL = 30 * time.Second
+
+// Policy limits continuous online time: after MaxOnline without a break of at least Rest
+// the driver is taken offline for Rest. MaxOnline <= 0 disables fatigue control.
+type Policy struct {
+	MaxOnline  time.Duration
+	Rest       time.Duration
+	WarnBefore time.Duration
+}
+
+// Repository provides online sessions and stores rest periods.
+type Repository interface {
+	RecentSessions(ctx context.Context, driverID int64, since time.Time) ([]repo.OnlineSession, error)
+	ActiveRest(ctx context.Context, driverID int64, now time.Time) (repo.DriverRest, error)
+	StartRest(ctx context.Context, rest repo.DriverRest) (repo.DriverRest, error)
+}
+
+// Status is the driver's current fatigue state.
+type Status struct {
+	State     string
+	Online    time.Duration
+	Remaining time.Duration
+	RestUntil time.Time
+}
+
+type cachedStatus struct {
+	status    Status
+	checkedAt time.Time
+}
+
+// Service measures continuous online time and imposes mandatory rest.
+type Service struct {
+	repo   Repository
+	policy Policy
+
+	mu     sync.Mutex
+	cache  map[int64]cachedStatus
+	warned map[int64]string
+}
+
+// New builds a fatigue service.
+func New(r Repository, policy Policy) *Service {
+	return &Service{repo: r, policy: policy, cache: make(map[int64]cachedStatus), warned: make(map[int64]string)}
+}
+
+// Enabled reports whether the online time limit is configured.
+func (s *Service) Enabled() bool {
+	return s != nil && s.policy.MaxOnline > 0
+}
+
+// Policy returns the configured limits.
+func (s *Service) Policy() Policy {
+	return s.policy
+}
+
+// ContinuousOnline sums online time since the last break of at least rest.
+// Short disconnects (reconnects, tunnels) do not reset the counter.
+func ContinuousOnline(sessions []repo.OnlineSession, rest time.Duration, now time.Time) time.Duration {
+	var total time.Duration
+	boundary := now
+	for i := len(sessions) - 1; i >= 0; i-- {
+		start, end := sessions[i].StartedAt, now
+		if sessions[i].EndedAt.Valid && sessions[i].EndedAt.Time.Before(now) {
+			end = sessions[i].EndedAt.Time
+		}
+		if end.After(boundary) {
+			// пересекающиеся сессии (переподключение) не считаем дважды
+			end = boundary
+		}
+		if boundary.Sub(end) >= rest {
+			break
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+		if start.Before(boundary) {
+			boundary = start
+		}
+	}
+	return total
+}
+
+// Check computes the driver's fatigue state at now.
+func (s *Service) Check(ctx context.Context, driverID int64, now time.Time) (Status, error) {
+	if !s.Enabled() {
+		return Status{State: StateOK}, nil
+	}
+	rest, err := s.repo.ActiveRest(ctx, driverID, now)
+	switch {
+	case err == nil:
+		st := Status{State: StateResting, Online: time.Duration(rest.OnlineSeconds) * time.Second, RestUntil: rest.RestUntil}
+		s.remember(driverID, st, now)
+		return st, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return Status{}, err
+	}
+
+	// дальше двух полных циклов смотреть незачем: либо найдётся перерыв, либо лимит уже превышен
+	since := now.Add(-2 * (s.policy.MaxOnline + s.policy.Rest))
+	sessions, err := s.repo.RecentSessions(ctx, driverID, since)
+	if err != nil {
+		return Status{}, err
+	}
+	online := ContinuousOnline(sessions, s.policy.Rest, now)
+	st := Status{State: StateOK, Online: online, Remaining: s.policy.MaxOnline - online}
+	switch {
+	case st.Remaining <= 0:
+		st.State, st.Remaining = StateLimit, 0
+	case st.Remaining <= s.policy.WarnBefore:
+		st.State = StateWarning
+	default:
+		s.mu.Lock()
+		delete(s.warned, driverID)
+		s.mu.Unlock()
+	}
+	s.remember(driverID, st, now)
+	return st, nil
+}
+
+// StartRest takes the driver off the line for the configured rest period.
+func (s *Service) StartRest(ctx context.Context, driverID int64, online time.Duration, now time.Time) (repo.DriverRest, error) {
+	rest, err := s.repo.StartRest(ctx, repo.DriverRest{
+		DriverID:      driverID,
+		OnlineSeconds: int64(online / time.Second),
+		StartedAt:     now,
+		RestUntil:     now.Add(s.policy.Rest),
+	})
+	if err != nil {
+		return repo.DriverRest{}, err
+	}
+	s.mu.Lock()
+	delete(s.warned, driverID)
+	s.mu.Unlock()
+	s.remember(driverID, Status{State: StateResting, Online: online, RestUntil: rest.RestUntil}, now)
+	return rest, nil
+}
+
+// MarkWarned reports whether the driver still has to be warned about the state;
+// each state is announced once until the driver rests.
+func (s *Service) MarkWarned(driverID int64, state string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.warned[driverID] == state {
+		return false
+	}
+	s.warned[driverID] = state
+	return true
+}
+
+// OffersAllowed reports whether the driver may receive offers; drivers at the limit or resting may not.
+// Errors are treated as allowed: fatigue control must not stop dispatch.
+func (s *Service) OffersAllowed(ctx context.Context, driverID int64, now time.Time) bool {
+	if !s.Enabled() {
+		return true
+	}
+	s.mu.Lock()
+	cached, ok := s.cache[driverID]
+	s.mu.Unlock()
+	st := cached.status
+	if !ok || now.Sub(cached.checkedAt) >= statusCacheTTL || (st.State == StateResting && !now.Before(st.RestUntil)) {
+		var err error
+		if st, err = s.Check(ctx, driverID, now); err != nil {
+			return true
+		}
+	}
+	return st.State != StateLimit && st.State != StateResting
+}
+
+func (s *Service) remember(driverID int64, st Status, now time.Time) {
+	s.mu.Lock()
+	s.cache[driverID] = cachedStatus{status: st, checkedAt: now}
+	s.mu.Unlock()
+}
@@ -0,0 +1,123 @@
+package fatigue
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"naimuBack/internal/taxi/repo"
+)
+
+type stubRepo struct {
+	sessions []repo.OnlineSession
+	rest     *repo.DriverRest
+	started  []repo.DriverRest
+}
+
+func (s *stubRepo) RecentSessions(ctx context.Context, driverID int64, since time.Time) ([]repo.OnlineSession, error) {
+	return s.sessions, nil
+}
+
+func (s *stubRepo) ActiveRest(ctx context.Context, driverID int64, now time.Time) (repo.DriverRest, error) {
+	if s.rest == nil || !s.rest.RestUntil.After(now) {
+		return repo.DriverRest{}, sql.ErrNoRows
+	}
+	return *s.rest, nil
+}
+
+func (s *stubRepo) StartRest(ctx context.Context, rest repo.DriverRest) (repo.DriverRest, error) {
+	rest.ID = int64(len(s.started) + 1)
+	s.started = append(s.started, rest)
+	s.rest = &rest
+	return rest, nil
+}
+
+var base = time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)
+
+func session(from, to time.Duration) repo.OnlineSession {
+	s := repo.OnlineSession{StartedAt: base.Add(from)}
+	if to > 0 {
+		s.EndedAt = sql.NullTime{Time: base.Add(to), Valid: true}
+	}
+	return s
+}
+
+func TestContinuousOnline(t *testing.T) {
+	rest := 6 * time.Hour
+	now := base.Add(20 * time.Hour)
+
+	// утренняя смена отделена от вечерней отдыхом 7ч — считается только вечерняя
+	sessions := []repo.OnlineSession{
+		session(0, 5*time.Hour),
+		session(12*time.Hour, 15*time.Hour),
+		// переподключение через 10 минут не сбрасывает счётчик
+		session(15*time.Hour+10*time.Minute, 0),
+	}
+	if got, want := ContinuousOnline(sessions, rest, now), 3*time.Hour+4*time.Hour+50*time.Minute; got != want {
+		t.Fatalf("ContinuousOnline = %s, want %s", got, want)
+	}
+
+	// водитель уже отдыхает дольше rest — счётчик обнулён
+	offline := []repo.OnlineSession{session(0, 10*time.Hour)}
+	if got := ContinuousOnline(offline, rest, base.Add(17*time.Hour)); got != 0 {
+		t.Fatalf("ContinuousOnline after rest = %s, want 0", got)
+	}
+
+	// старая сессия осталась незакрытой при переподключении — время не удваивается
+	overlap := []repo.OnlineSession{session(0, 0), session(time.Hour, 0)}
+	if got := ContinuousOnline(overlap, rest, base.Add(2*time.Hour)); got != 2*time.Hour {
+		t.Fatalf("ContinuousOnline overlap = %s, want 2h", got)
+	}
+}
+
+func TestServiceCheckAndRest(t *testing.T) {
+	ctx := context.Background()
+	r := &stubRepo{sessions: []repo.OnlineSession{session(0, 0)}}
+	svc := New(r, Policy{MaxOnline: 10 * time.Hour, Rest: 6 * time.Hour, WarnBefore: 30 * time.Minute})
+
+	st, err := svc.Check(ctx, 7, base.Add(9*time.Hour))
+	if err != nil || st.State != StateOK || st.Remaining != time.Hour {
+		t.Fatalf("status at 9h = %+v, err %v", st, err)
+	}
+	st, _ = svc.Check(ctx, 7, base.Add(9*time.Hour+40*time.Minute))
+	if st.State != StateWarning {
+		t.Fatalf("status at 9h40 = %+v, want warning", st)
+	}
+	if !svc.MarkWarned(7, StateWarning) || svc.MarkWarned(7, StateWarning) {
+		t.Fatalf("driver must be warned exactly once")
+	}
+	if !svc.MarkWarned(7, StateLimit) {
+		t.Fatalf("reaching the limit must be announced separately")
+	}
+
+	limitAt := base.Add(10 * time.Hour)
+	if svc.OffersAllowed(ctx, 7, limitAt) {
+		t.Fatalf("offers allowed at the limit")
+	}
+	st, _ = svc.Check(ctx, 7, limitAt)
+	if st.State != StateLimit {
+		t.Fatalf("status at 10h = %+v, want limit", st)
+	}
+
+	rest, err := svc.StartRest(ctx, 7, st.Online, limitAt)
+	if err != nil || !rest.RestUntil.Equal(limitAt.Add(6*time.Hour)) || rest.OnlineSeconds != 36000 {
+		t.Fatalf("rest = %+v, err %v", rest, err)
+	}
+	if svc.OffersAllowed(ctx, 7, limitAt.Add(time.Hour)) {
+		t.Fatalf("offers allowed during rest")
+	}
+
+	// отдых закончился, водитель снова на линии
+	r.sessions = []repo.OnlineSession{session(0, 10*time.Hour), session(16*time.Hour, 0)}
+	if !svc.OffersAllowed(ctx, 7, limitAt.Add(6*time.Hour+time.Minute)) {
+		t.Fatalf("offers refused after rest")
+	}
+}
+
+func TestServiceDisabled(t *testing.T) {
+	svc := New(&stubRepo{sessions: []repo.OnlineSession{session(0, 0)}}, Policy{})
+	if !svc.OffersAllowed(context.Background(), 7, base.Add(48*time.Hour)) {
+		t.Fatalf("disabled fatigue control must not refuse offers")
+	}
+}
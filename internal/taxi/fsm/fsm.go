@@ -137,7 +137,7 @@ func Apply(ctx context.Context, tx *sql.Tx, orderID int64, fromStatus, toStatus
 	if !CanTransition(fromStatus, toStatus) {
 		return errors.New("invalid status transition")
 	}
-	res, err := tx.ExecContext(ctx, `UPDATE orders SET status = ? WHERE id = ? AND status = ?`, toStatus, orderID, fromStatus)
+	res, err := tx.ExecContext(ctx, `UPDATE orders SET status = ?, completed_at = IF(? = 'completed', CURRENT_TIMESTAMP, completed_at) WHERE id = ? AND status = ?`, toStatus, toStatus, orderID, fromStatus)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,113 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDGISClientSuggest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/3.0/suggests" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("q") != "хан шатыр" || q.Get("key") != "test-api-key" || q.Get("page_size") != "5" {
+			t.Fatalf("unexpected query: %s", r.URL.RawQuery)
+		}
+		if q.Get("location") != "71.430400,51.128200" || q.Get("region_id") != "68" {
+			t.Fatalf("unexpected bias: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"meta":{"code":200},"result":{"items":[
+			{"name":"Хан Шатыр","address_name":"проспект Туран, 37","point":{"lon":71.4036,"lat":51.1325}},
+			{"name":"Без точки","address_name":"нигде"},
+			{"full_name":"Астана, улица Достык, 5","point":{"lon":71.42,"lat":51.13}}
+		]}}`)
+	}))
+	defer server.Close()
+
+	client := NewDGISClient(newTestHTTPClient(t, server), "test-api-key", "68")
+	places, err := client.Suggest(context.Background(), " хан шатыр ", 71.4304, 51.1282, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Place{
+		{Name: "Хан Шатыр", Address: "проспект Туран, 37", Lon: 71.4036, Lat: 51.1325},
+		{Name: "Астана, улица Достык, 5", Address: "Астана, улица Достык, 5", Lon: 71.42, Lat: 51.13},
+	}
+	if len(places) != len(want) {
+		t.Fatalf("unexpected places: %+v", places)
+	}
+	for i := range want {
+		if places[i] != want[i] {
+			t.Fatalf("place %d = %+v, want %+v", i, places[i], want[i])
+		}
+	}
+}
+
+func TestDGISClientReverseGeocode(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    Place
+		wantErr error
+	}{
+		{
+			name: "ok",
+			body: `{"meta":{"code":200},"result":{"items":[{"name":"Кабанбай батыра, 11","address_name":"Кабанбай батыра, 11","point":{"lon":71.41,"lat":51.12}}]}}`,
+			want: Place{Name: "Кабанбай батыра, 11", Address: "Кабанбай батыра, 11", Lon: 71.415, Lat: 51.125},
+		},
+		{
+			name:    "not found",
+			body:    `{"meta":{"code":404},"result":{}}`,
+			wantErr: ErrPlaceNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/3.0/items/geocode" {
+					t.Fatalf("unexpected path: %s", r.URL.Path)
+				}
+				if q := r.URL.Query(); q.Get("lon") != "71.415000" || q.Get("lat") != "51.125000" {
+					t.Fatalf("unexpected query: %s", r.URL.RawQuery)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			client := NewDGISClient(newTestHTTPClient(t, server), "test-api-key", "")
+			place, err := client.ReverseGeocode(context.Background(), 71.415, 51.125)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if place != tt.want {
+				t.Fatalf("place = %+v, want %+v", place, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeocoderCacheKeys(t *testing.T) {
+	if got := geocodeKey("  Аэропорт   Нурсултан  "); got != "geo:geocode:аэропорт нурсултан" {
+		t.Fatalf("geocodeKey = %q", got)
+	}
+	if a, b := suggestKey("Достык", 71.4304, 51.1282, 10), suggestKey("достык ", 71.4281, 51.1309, 10); a != b {
+		t.Fatalf("nearby suggest keys differ: %q vs %q", a, b)
+	}
+	if a, b := reverseKey(71.4304, 51.1282), reverseKey(71.4314, 51.1282); a == b {
+		t.Fatalf("distinct points share reverse key %q", a)
+	}
+}
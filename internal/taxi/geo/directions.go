@@ -0,0 +1,155 @@
+package geo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RouteLeg is the driving route between two consecutive points.
+type RouteLeg struct {
+	DistanceM  int
+	EtaSeconds int
+	Path       []Point
+}
+
+// Polyline returns the leg geometry as an encoded polyline.
+func (l RouteLeg) Polyline() string {
+	return EncodePolyline(l.Path)
+}
+
+// GeometryRouter is a Router that can also return route geometry.
+type GeometryRouter interface {
+	Router
+	RouteGeometry(ctx context.Context, fromLon, fromLat, toLon, toLat float64) (RouteLeg, error)
+}
+
+type directionsPoint struct {
+	Type string  `json:"type"`
+	Lon  float64 `json:"lon"`
+	Lat  float64 `json:"lat"`
+}
+
+// RouteGeometry returns the driving route between two points with its geometry (2GIS Routing API).
+func (c *DGISClient) RouteGeometry(ctx context.Context, fromLon, fromLat, toLon, toLat float64) (RouteLeg, error) {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	payload := struct {
+		Points      []directionsPoint `json:"points"`
+		Transport   string            `json:"transport"`
+		RouteMode   string            `json:"route_mode"`
+		TrafficMode string            `json:"traffic_mode"`
+		Output      string            `json:"output"`
+	}{
+		Points: []directionsPoint{
+			{Type: "stop", Lon: fromLon, Lat: fromLat},
+			{Type: "stop", Lon: toLon, Lat: toLat},
+		},
+		Transport:   "driving",
+		RouteMode:   "fastest",
+		TrafficMode: "jam",
+		Output:      "detailed",
+	}
+	body, err := json.Marshal(&payload)
+	if err != nil {
+		return RouteLeg{}, err
+	}
+
+	q := url.Values{}
+	q.Set("key", c.apiKey)
+	endpoint := fmt.Sprintf("%s/routing/7.0.0/global?%s", routingBaseURL, q.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return RouteLeg{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return RouteLeg{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return RouteLeg{}, errors.New("2gis: route not found (204)")
+	}
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return RouteLeg{}, fmt.Errorf("2gis: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var out struct {
+		Status string `json:"status"`
+		Result []struct {
+			TotalDistance int `json:"total_distance"`
+			TotalDuration int `json:"total_duration"`
+			Maneuvers     []struct {
+				OutcomingPath *struct {
+					Geometry []struct {
+						Selection string `json:"selection"`
+					} `json:"geometry"`
+				} `json:"outcoming_path"`
+			} `json:"maneuvers"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return RouteLeg{}, fmt.Errorf("2gis: decode route: %w", err)
+	}
+	if len(out.Result) == 0 {
+		return RouteLeg{}, fmt.Errorf("2gis: route not found (%s)", out.Status)
+	}
+
+	route := out.Result[0]
+	leg := RouteLeg{DistanceM: route.TotalDistance, EtaSeconds: route.TotalDuration}
+	for _, m := range route.Maneuvers {
+		if m.OutcomingPath == nil {
+			continue
+		}
+		for _, g := range m.OutcomingPath.Geometry {
+			points, err := parseLineString(g.Selection)
+			if err != nil {
+				return RouteLeg{}, err
+			}
+			for _, p := range points {
+				// соседние отрезки делят граничную точку
+				if n := len(leg.Path); n > 0 && leg.Path[n-1] == p {
+					continue
+				}
+				leg.Path = append(leg.Path, p)
+			}
+		}
+	}
+	return leg, nil
+}
+
+// parseLineString parses WKT "LINESTRING(lon lat, lon lat, ...)".
+func parseLineString(wkt string) ([]Point, error) {
+	s := strings.TrimSpace(wkt)
+	open, closing := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if !strings.HasPrefix(strings.ToUpper(s), "LINESTRING") || open < 0 || closing < open {
+		return nil, fmt.Errorf("2gis: unexpected geometry %q", wkt)
+	}
+	var points []Point
+	for _, pair := range strings.Split(s[open+1:closing], ",") {
+		fields := strings.Fields(pair)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("2gis: unexpected geometry point %q", pair)
+		}
+		lon, errLon := strconv.ParseFloat(fields[0], 64)
+		lat, errLat := strconv.ParseFloat(fields[1], 64)
+		if errLon != nil || errLat != nil {
+			return nil, fmt.Errorf("2gis: unexpected geometry point %q", pair)
+		}
+		points = append(points, Point{Lon: lon, Lat: lat})
+	}
+	return points, nil
+}
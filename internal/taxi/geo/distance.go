@@ -0,0 +1,16 @@
+package geo
+
+import "math"
+
+// DistanceMeters returns great-circle distance between two points in meters.
+func DistanceMeters(lon1, lat1, lon2, lat2 float64) float64 {
+	const earthRadius = 6371000.0
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+	lat1Rad := lat1 * math.Pi / 180
+	lat2Rad := lat2 * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1Rad)*math.Cos(lat2Rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+	return earthRadius * c
+}
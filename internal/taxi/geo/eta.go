@@ -0,0 +1,85 @@
+package geo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Router returns driving distance (m) and duration (s) between two points.
+type Router interface {
+	RouteMatrix(ctx context.Context, fromLon, fromLat, toLon, toLat float64) (int, int, error)
+}
+
+// ETA is the remaining distance and time to a target point.
+type ETA struct {
+	DistanceM  int
+	EtaSeconds int
+}
+
+type etaEntry struct {
+	toLon, toLat float64
+	routedAt     time.Time
+	eta          ETA
+}
+
+// ETATracker keeps live ETAs per trip and refreshes them through the router no more often than the interval.
+// Between refreshes the last routed ETA is counted down by the elapsed time.
+type ETATracker struct {
+	router   Router
+	interval time.Duration
+
+	mu      sync.Mutex
+	entries map[int64]etaEntry
+}
+
+// NewETATracker builds a tracker that calls the router at most once per interval for each trip.
+func NewETATracker(router Router, interval time.Duration) *ETATracker {
+	return &ETATracker{router: router, interval: interval, entries: make(map[int64]etaEntry)}
+}
+
+// Estimate returns the ETA of trip key from (lon, lat) to (toLon, toLat).
+// A new target (e.g. pickup → destination) forces a routing request.
+func (t *ETATracker) Estimate(ctx context.Context, key int64, lon, lat, toLon, toLat float64, now time.Time) (ETA, error) {
+	t.mu.Lock()
+	entry, ok := t.entries[key]
+	t.mu.Unlock()
+	sameTarget := ok && entry.toLon == toLon && entry.toLat == toLat
+	if sameTarget && now.Sub(entry.routedAt) < t.interval {
+		return entry.countdown(now), nil
+	}
+
+	distance, duration, err := t.router.RouteMatrix(ctx, lon, lat, toLon, toLat)
+	if err != nil {
+		// провайдер маршрутов недоступен — продолжаем отсчёт от последнего известного ETA
+		if sameTarget {
+			return entry.countdown(now), nil
+		}
+		return ETA{}, err
+	}
+	entry = etaEntry{toLon: toLon, toLat: toLat, routedAt: now, eta: ETA{DistanceM: distance, EtaSeconds: duration}}
+	t.mu.Lock()
+	t.entries[key] = entry
+	t.mu.Unlock()
+	return entry.eta, nil
+}
+
+// Forget drops the trip, e.g. once it is finished.
+func (t *ETATracker) Forget(key int64) {
+	t.mu.Lock()
+	delete(t.entries, key)
+	t.mu.Unlock()
+}
+
+func (e etaEntry) countdown(now time.Time) ETA {
+	elapsed := int(now.Sub(e.routedAt) / time.Second)
+	left := e.eta.EtaSeconds - elapsed
+	if left <= 0 {
+		return ETA{}
+	}
+	distance := e.eta.DistanceM
+	if e.eta.EtaSeconds > 0 {
+		distance = e.eta.DistanceM * left / e.eta.EtaSeconds
+	}
+	return ETA{DistanceM: distance, EtaSeconds: left}
+}
@@ -0,0 +1,80 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type stubRouter struct {
+	calls     int
+	distanceM int
+	duration  int
+	err       error
+}
+
+func (r *stubRouter) RouteMatrix(ctx context.Context, fromLon, fromLat, toLon, toLat float64) (int, int, error) {
+	r.calls++
+	return r.distanceM, r.duration, r.err
+}
+
+func TestETATrackerThrottlesRouting(t *testing.T) {
+	router := &stubRouter{distanceM: 2000, duration: 240}
+	tracker := NewETATracker(router, 30*time.Second)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	eta, err := tracker.Estimate(ctx, 1, 71.40, 51.10, 71.43, 51.12, start)
+	if err != nil || eta != (ETA{DistanceM: 2000, EtaSeconds: 240}) {
+		t.Fatalf("first estimate = %+v, %v", eta, err)
+	}
+
+	eta, err = tracker.Estimate(ctx, 1, 71.41, 51.11, 71.43, 51.12, start.Add(12*time.Second))
+	if err != nil || eta != (ETA{DistanceM: 1900, EtaSeconds: 228}) {
+		t.Fatalf("throttled estimate = %+v, %v", eta, err)
+	}
+	if router.calls != 1 {
+		t.Fatalf("router called %d times, want 1", router.calls)
+	}
+
+	router.duration = 200
+	if _, err := tracker.Estimate(ctx, 1, 71.41, 51.11, 71.43, 51.12, start.Add(31*time.Second)); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if router.calls != 2 {
+		t.Fatalf("router called %d times after interval, want 2", router.calls)
+	}
+
+	// новая цель (точка назначения) маршрутизируется сразу
+	if _, err := tracker.Estimate(ctx, 1, 71.43, 51.12, 71.50, 51.20, start.Add(32*time.Second)); err != nil {
+		t.Fatalf("new target: %v", err)
+	}
+	if router.calls != 3 {
+		t.Fatalf("router called %d times for new target, want 3", router.calls)
+	}
+}
+
+func TestETATrackerRouterFailure(t *testing.T) {
+	router := &stubRouter{distanceM: 1000, duration: 100}
+	tracker := NewETATracker(router, 10*time.Second)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	if _, err := tracker.Estimate(ctx, 7, 0.1, 0.1, 0.2, 0.2, start); err != nil {
+		t.Fatalf("first estimate: %v", err)
+	}
+	router.err = errors.New("unavailable")
+	eta, err := tracker.Estimate(ctx, 7, 0.1, 0.1, 0.2, 0.2, start.Add(40*time.Second))
+	if err != nil || eta.EtaSeconds != 60 {
+		t.Fatalf("fallback estimate = %+v, %v", eta, err)
+	}
+	if eta, _ := tracker.Estimate(ctx, 7, 0.1, 0.1, 0.2, 0.2, start.Add(150*time.Second)); eta != (ETA{}) {
+		t.Fatalf("expired estimate = %+v, want zero", eta)
+	}
+
+	tracker.Forget(7)
+	if _, err := tracker.Estimate(ctx, 7, 0.1, 0.1, 0.2, 0.2, start.Add(160*time.Second)); err == nil {
+		t.Fatalf("expected router error after forget")
+	}
+}
@@ -0,0 +1,137 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Geocoder caches 2GIS geocoding, suggestions and reverse lookups in Redis.
+type Geocoder struct {
+	client *DGISClient
+	rdb    *redis.Client
+	ttl    time.Duration
+}
+
+// NewGeocoder wraps the 2GIS client with a Redis cache; entries live for ttl.
+func NewGeocoder(client *DGISClient, rdb *redis.Client, ttl time.Duration) *Geocoder {
+	return &Geocoder{client: client, rdb: rdb, ttl: ttl}
+}
+
+func normalizeQuery(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
+
+func geocodeKey(query string) string {
+	return "geo:geocode:" + normalizeQuery(query)
+}
+
+// suggestKey округляет точку до ~1 км, чтобы соседние пользователи делили кэш.
+func suggestKey(query string, lon, lat float64, limit int) string {
+	return fmt.Sprintf("geo:suggest:%.2f:%.2f:%d:%s", lon, lat, limit, normalizeQuery(query))
+}
+
+// reverseKey округляет точку до ~10 м.
+func reverseKey(lon, lat float64) string {
+	return fmt.Sprintf("geo:reverse:%.4f:%.4f", lon, lat)
+}
+
+func (g *Geocoder) load(ctx context.Context, key string, dst interface{}) bool {
+	if g.rdb == nil {
+		return false
+	}
+	data, err := g.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, dst) == nil
+}
+
+func (g *Geocoder) store(ctx context.Context, key string, v interface{}) {
+	if g.rdb == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	_ = g.rdb.Set(ctx, key, data, g.ttl).Err()
+}
+
+// Geocode returns coordinates for the address (lon, lat), using the cache first.
+func (g *Geocoder) Geocode(ctx context.Context, query string) (float64, float64, error) {
+	if lon, lat, ok := tryParseLonLat(query); ok {
+		return lon, lat, nil
+	}
+	key := geocodeKey(query)
+	var cached Place
+	if g.load(ctx, key, &cached) {
+		return cached.Lon, cached.Lat, nil
+	}
+	lon, lat, err := g.client.Geocode(ctx, query)
+	if err != nil {
+		return 0, 0, err
+	}
+	g.store(ctx, key, Place{Address: strings.TrimSpace(query), Lon: lon, Lat: lat})
+	return lon, lat, nil
+}
+
+// Suggest returns cached address suggestions for the query near the point.
+func (g *Geocoder) Suggest(ctx context.Context, query string, lon, lat float64, limit int) ([]Place, error) {
+	key := suggestKey(query, lon, lat, limit)
+	var cached []Place
+	if g.load(ctx, key, &cached) {
+		return cached, nil
+	}
+	places, err := g.client.Suggest(ctx, query, lon, lat, limit)
+	if err != nil {
+		return nil, err
+	}
+	g.store(ctx, key, places)
+	return places, nil
+}
+
+// ReverseGeocode returns the cached address of the point.
+func (g *Geocoder) ReverseGeocode(ctx context.Context, lon, lat float64) (Place, error) {
+	key := reverseKey(lon, lat)
+	var cached Place
+	if g.load(ctx, key, &cached) {
+		cached.Lon, cached.Lat = lon, lat
+		return cached, nil
+	}
+	place, err := g.client.ReverseGeocode(ctx, lon, lat)
+	if err != nil {
+		return Place{}, err
+	}
+	g.store(ctx, key, place)
+	return place, nil
+}
+
+// Seed pre-fills the geocoding cache with known places, so quotes to them never hit 2GIS.
+func (g *Geocoder) Seed(ctx context.Context, places []Place) error {
+	if g.rdb == nil {
+		return errors.New("geocoder: redis is not configured")
+	}
+	if len(places) == 0 {
+		return nil
+	}
+	pipe := g.rdb.Pipeline()
+	for _, p := range places {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		for _, q := range []string{p.Name, p.Address} {
+			if strings.TrimSpace(q) != "" {
+				pipe.Set(ctx, geocodeKey(q), data, g.ttl)
+			}
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,79 @@
+package geo
+
+import "strings"
+
+const geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// EncodeGeohash returns the geohash of the point with the given precision (number of characters).
+func EncodeGeohash(lat, lon float64, precision int) string {
+	if precision <= 0 {
+		precision = 6
+	}
+	latMin, latMax := -90.0, 90.0
+	lonMin, lonMax := -180.0, 180.0
+
+	var sb strings.Builder
+	sb.Grow(precision)
+	bit, ch := 0, 0
+	even := true
+	for sb.Len() < precision {
+		if even {
+			mid := (lonMin + lonMax) / 2
+			if lon >= mid {
+				ch = ch<<1 | 1
+				lonMin = mid
+			} else {
+				ch <<= 1
+				lonMax = mid
+			}
+		} else {
+			mid := (latMin + latMax) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				latMin = mid
+			} else {
+				ch <<= 1
+				latMax = mid
+			}
+		}
+		even = !even
+		bit++
+		if bit == 5 {
+			sb.WriteByte(geohashBase32[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return sb.String()
+}
+
+// DecodeGeohash returns the center of the geohash cell. ok is false for invalid hashes.
+func DecodeGeohash(hash string) (lat, lon float64, ok bool) {
+	latMin, latMax := -90.0, 90.0
+	lonMin, lonMax := -180.0, 180.0
+	even := true
+	for i := 0; i < len(hash); i++ {
+		idx := strings.IndexByte(geohashBase32, hash[i])
+		if idx < 0 {
+			return 0, 0, false
+		}
+		for mask := 16; mask > 0; mask >>= 1 {
+			if even {
+				mid := (lonMin + lonMax) / 2
+				if idx&mask != 0 {
+					lonMin = mid
+				} else {
+					lonMax = mid
+				}
+			} else {
+				mid := (latMin + latMax) / 2
+				if idx&mask != 0 {
+					latMin = mid
+				} else {
+					latMax = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return (latMin + latMax) / 2, (lonMin + lonMax) / 2, len(hash) > 0
+}
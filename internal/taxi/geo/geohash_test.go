@@ -0,0 +1,25 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeohashRoundTrip(t *testing.T) {
+	// эталонное значение из спецификации geohash
+	if got := EncodeGeohash(57.64911, 10.40744, 11); got != "u4pruydqqvj" {
+		t.Fatalf("unexpected geohash: %s", got)
+	}
+
+	lat, lon, ok := DecodeGeohash("txwtvx")
+	if !ok {
+		t.Fatalf("expected valid geohash")
+	}
+	if math.Abs(lat-43.238949) > 0.01 || math.Abs(lon-76.889709) > 0.01 {
+		t.Fatalf("decoded center too far: %f,%f", lat, lon)
+	}
+
+	if _, _, ok := DecodeGeohash("txw!"); ok {
+		t.Fatalf("expected invalid geohash")
+	}
+}
@@ -0,0 +1,162 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrPlaceNotFound is returned when 2GIS has nothing for the point or query.
+var ErrPlaceNotFound = errors.New("place not found")
+
+// Place is an address suggestion or a reverse geocoding result.
+type Place struct {
+	Name    string  `json:"name"`
+	Address string  `json:"address"`
+	Lon     float64 `json:"lon"`
+	Lat     float64 `json:"lat"`
+}
+
+// cityCenters biases suggestions to the user's city when the client did not send its position.
+var cityCenters = map[string][2]float64{
+	"astana":    {71.4304, 51.1282},
+	"almaty":    {76.9286, 43.2567},
+	"shymkent":  {69.5901, 42.3417},
+	"karaganda": {73.1022, 49.8047},
+	"aktobe":    {57.1667, 50.2839},
+	"pavlodar":  {76.9674, 52.2873},
+	"atyrau":    {51.9238, 47.0945},
+	"kostanay":  {63.6246, 53.2198},
+}
+
+// CityCenter returns the center of a known city (lon, lat).
+func CityCenter(city string) (float64, float64, bool) {
+	c, ok := cityCenters[strings.ToLower(strings.TrimSpace(city))]
+	return c[0], c[1], ok
+}
+
+type catalogItem struct {
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	AddressName string `json:"address_name"`
+	Point       *struct {
+		Lon float64 `json:"lon"`
+		Lat float64 `json:"lat"`
+	} `json:"point"`
+}
+
+func (it catalogItem) place() (Place, bool) {
+	if it.Point == nil || (it.Point.Lon == 0 && it.Point.Lat == 0) {
+		return Place{}, false
+	}
+	p := Place{Name: strings.TrimSpace(it.Name), Address: strings.TrimSpace(it.AddressName), Lon: it.Point.Lon, Lat: it.Point.Lat}
+	if p.Address == "" {
+		p.Address = strings.TrimSpace(it.FullName)
+	}
+	if p.Name == "" {
+		p.Name = p.Address
+	}
+	if p.Address == "" {
+		p.Address = p.Name
+	}
+	return p, p.Name != ""
+}
+
+func (c *DGISClient) catalogItems(ctx context.Context, path string, params url.Values) ([]catalogItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, 7*time.Second)
+	defer cancel()
+
+	params.Set("key", c.apiKey)
+	params.Set("fields", "items.point,items.full_name,items.address_name")
+	params.Set("locale", "ru_KZ")
+	endpoint := fmt.Sprintf("%s%s?%s", catalogBaseURL, path, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("2gis: http %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	var payload struct {
+		Meta struct {
+			Code int `json:"code"`
+		} `json:"meta"`
+		Result struct {
+			Items []catalogItem `json:"items"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("2gis: decode: %w", err)
+	}
+	if payload.Meta.Code == http.StatusNotFound {
+		return nil, nil
+	}
+	return payload.Result.Items, nil
+}
+
+// Suggest returns address suggestions for a partially typed query near the given point.
+func (c *DGISClient) Suggest(ctx context.Context, query string, lon, lat float64, limit int) ([]Place, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("suggest: empty query")
+	}
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("type", "building,street,branch,attraction,station,adm_div.place")
+	params.Set("page_size", strconv.Itoa(limit))
+	if lon != 0 || lat != 0 {
+		params.Set("location", fmt.Sprintf("%f,%f", lon, lat))
+		params.Set("sort_point", fmt.Sprintf("%f,%f", lon, lat))
+	}
+	if c.regionID != "" {
+		params.Set("region_id", c.regionID)
+	}
+	items, err := c.catalogItems(ctx, "/3.0/suggests", params)
+	if err != nil {
+		return nil, fmt.Errorf("suggest: %w", err)
+	}
+	places := make([]Place, 0, len(items))
+	for _, it := range items {
+		if p, ok := it.place(); ok {
+			places = append(places, p)
+		}
+	}
+	return places, nil
+}
+
+// ReverseGeocode returns the nearest address for the point.
+func (c *DGISClient) ReverseGeocode(ctx context.Context, lon, lat float64) (Place, error) {
+	params := url.Values{}
+	params.Set("lon", strconv.FormatFloat(lon, 'f', 6, 64))
+	params.Set("lat", strconv.FormatFloat(lat, 'f', 6, 64))
+	params.Set("type", "building,street,adm_div.place")
+	items, err := c.catalogItems(ctx, "/3.0/items/geocode", params)
+	if err != nil {
+		return Place{}, fmt.Errorf("reverse geocode: %w", err)
+	}
+	for _, it := range items {
+		if p, ok := it.place(); ok {
+			// клиенту нужен адрес именно той точки, которую он выбрал на карте
+			p.Lon, p.Lat = lon, lat
+			return p, nil
+		}
+	}
+	return Place{}, ErrPlaceNotFound
+}
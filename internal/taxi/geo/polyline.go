@@ -0,0 +1,125 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// Point is a WGS84 coordinate.
+type Point struct {
+	Lon float64 `json:"lon"`
+	Lat float64 `json:"lat"`
+}
+
+const polylineFactor = 1e5
+
+// EncodePolyline encodes the path in the Google encoded polyline format (precision 5, lat before lon).
+func EncodePolyline(path []Point) string {
+	var b strings.Builder
+	var prevLat, prevLon int64
+	for _, p := range path {
+		lat := int64(math.Round(p.Lat * polylineFactor))
+		lon := int64(math.Round(p.Lon * polylineFactor))
+		encodePolylineValue(&b, lat-prevLat)
+		encodePolylineValue(&b, lon-prevLon)
+		prevLat, prevLon = lat, lon
+	}
+	return b.String()
+}
+
+func encodePolylineValue(b *strings.Builder, v int64) {
+	u := v << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b.WriteByte(byte((0x20 | (u & 0x1f)) + 63))
+		u >>= 5
+	}
+	b.WriteByte(byte(u + 63))
+}
+
+// DecodePolyline decodes a Google encoded polyline (precision 5).
+func DecodePolyline(s string) ([]Point, error) {
+	var (
+		path     []Point
+		lat, lon int64
+		i        int
+	)
+	next := func() (int64, error) {
+		var result int64
+		var shift uint
+		for {
+			if i >= len(s) {
+				return 0, errors.New("polyline: truncated")
+			}
+			c := int64(s[i]) - 63
+			i++
+			if c < 0 || shift > 60 {
+				return 0, errors.New("polyline: invalid character")
+			}
+			result |= (c & 0x1f) << shift
+			shift += 5
+			if c < 0x20 {
+				break
+			}
+		}
+		if result&1 != 0 {
+			return ^(result >> 1), nil
+		}
+		return result >> 1, nil
+	}
+	for i < len(s) {
+		dLat, err := next()
+		if err != nil {
+			return nil, err
+		}
+		dLon, err := next()
+		if err != nil {
+			return nil, err
+		}
+		lat += dLat
+		lon += dLon
+		path = append(path, Point{Lon: float64(lon) / polylineFactor, Lat: float64(lat) / polylineFactor})
+	}
+	return path, nil
+}
+
+// DistanceToPath returns the distance in meters from the point to the nearest segment of the path.
+// Used for off-route detection; an empty path yields +Inf.
+func DistanceToPath(lon, lat float64, path []Point) float64 {
+	switch len(path) {
+	case 0:
+		return math.Inf(1)
+	case 1:
+		return DistanceMeters(lon, lat, path[0].Lon, path[0].Lat)
+	}
+	// локальная равнопромежуточная проекция: на отрезках маршрута погрешность пренебрежимо мала
+	kx := 111320 * math.Cos(lat*math.Pi/180)
+	const ky = 110540.0
+	best := math.Inf(1)
+	for i := 0; i < len(path)-1; i++ {
+		ax, ay := (path[i].Lon-lon)*kx, (path[i].Lat-lat)*ky
+		bx, by := (path[i+1].Lon-lon)*kx, (path[i+1].Lat-lat)*ky
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if l := dx*dx + dy*dy; l > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+		}
+		px, py := ax+t*dx, ay+t*dy
+		if d := math.Hypot(px, py); d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+// OffRoute reports whether the point is farther than toleranceM from the decoded route.
+// An empty route is never considered left.
+func OffRoute(lon, lat float64, path []Point, toleranceM float64) bool {
+	if len(path) == 0 {
+		return false
+	}
+	return DistanceToPath(lon, lat, path) > toleranceM
+}
@@ -0,0 +1,97 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPolylineRoundTrip(t *testing.T) {
+	// пример из описания формата Google
+	path := []Point{{Lon: -120.2, Lat: 38.5}, {Lon: -120.95, Lat: 40.7}, {Lon: -126.453, Lat: 43.252}}
+	encoded := EncodePolyline(path)
+	if encoded != "_p~iF~ps|U_ulLnnqC_mqNvxq`@" {
+		t.Fatalf("EncodePolyline = %q", encoded)
+	}
+	decoded, err := DecodePolyline(encoded)
+	if err != nil {
+		t.Fatalf("DecodePolyline: %v", err)
+	}
+	if len(decoded) != len(path) {
+		t.Fatalf("decoded %d points, want %d", len(decoded), len(path))
+	}
+	for i := range path {
+		if math.Abs(decoded[i].Lon-path[i].Lon) > 1e-5 || math.Abs(decoded[i].Lat-path[i].Lat) > 1e-5 {
+			t.Fatalf("point %d = %+v, want %+v", i, decoded[i], path[i])
+		}
+	}
+	if _, err := DecodePolyline("_p~iF~ps|U_ulL"); err == nil {
+		t.Fatalf("expected error for truncated polyline")
+	}
+}
+
+func TestOffRoute(t *testing.T) {
+	route, err := DecodePolyline(EncodePolyline([]Point{{Lon: 71.4000, Lat: 51.1000}, {Lon: 71.4200, Lat: 51.1000}, {Lon: 71.4200, Lat: 51.1100}}))
+	if err != nil {
+		t.Fatalf("decode route: %v", err)
+	}
+
+	// ~35 м севернее первого отрезка
+	if OffRoute(71.4100, 51.1003, route, 100) {
+		t.Fatalf("point near the route reported off-route")
+	}
+	// ~550 м южнее
+	if !OffRoute(71.4100, 51.0950, route, 100) {
+		t.Fatalf("point far from the route not reported off-route")
+	}
+	if OffRoute(71.5, 51.5, nil, 100) {
+		t.Fatalf("empty route must never be left")
+	}
+}
+
+func TestDGISClientRouteGeometry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/routing/7.0.0/global" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var payload struct {
+			Points []directionsPoint `json:"points"`
+			Output string            `json:"output"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		if len(payload.Points) != 2 || payload.Output != "detailed" {
+			t.Fatalf("unexpected payload: %+v", payload)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":"OK","result":[{"total_distance":1500,"total_duration":300,"maneuvers":[
+			{"outcoming_path":{"geometry":[{"selection":"LINESTRING(71.40 51.10, 71.41 51.10)"}]}},
+			{"outcoming_path":{"geometry":[{"selection":"LINESTRING(71.41 51.10, 71.41 51.11)"}]}},
+			{"comment":"finish"}
+		]}]}`)
+	}))
+	defer server.Close()
+
+	client := NewDGISClient(newTestHTTPClient(t, server), "test-api-key", "")
+	leg, err := client.RouteGeometry(context.Background(), 71.40, 51.10, 71.41, 51.11)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if leg.DistanceM != 1500 || leg.EtaSeconds != 300 {
+		t.Fatalf("unexpected totals: %+v", leg)
+	}
+	want := []Point{{Lon: 71.40, Lat: 51.10}, {Lon: 71.41, Lat: 51.10}, {Lon: 71.41, Lat: 51.11}}
+	if len(leg.Path) != len(want) {
+		t.Fatalf("path = %+v", leg.Path)
+	}
+	for i := range want {
+		if leg.Path[i] != want[i] {
+			t.Fatalf("path[%d] = %+v, want %+v", i, leg.Path[i], want[i])
+		}
+	}
+}
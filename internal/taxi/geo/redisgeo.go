@@ -151,7 +151,16 @@ func (l *DriverLocator) GoOffline(ctx context.Context, driverID int64, city stri
 
 // Nearby returns drivers within radius sorted by distance (ascending).
 func (l *DriverLocator) Nearby(ctx context.Context, lon, lat float64, radiusMeters float64, limit int, city string) ([]NearbyDriver, error) {
-	key := redisKey(city, "free")
+	return l.nearbyByStatus(ctx, lon, lat, radiusMeters, limit, city, "free")
+}
+
+// NearbyBusy returns drivers currently on a trip within radius sorted by distance (ascending).
+func (l *DriverLocator) NearbyBusy(ctx context.Context, lon, lat float64, radiusMeters float64, limit int, city string) ([]NearbyDriver, error) {
+	return l.nearbyByStatus(ctx, lon, lat, radiusMeters, limit, city, "busy")
+}
+
+func (l *DriverLocator) nearbyByStatus(ctx context.Context, lon, lat float64, radiusMeters float64, limit int, city, status string) ([]NearbyDriver, error) {
+	key := redisKey(city, status)
 
 	res, err := l.rdb.GeoSearchLocation(ctx, key, &redis.GeoSearchLocationQuery{
 		GeoSearchQuery: redis.GeoSearchQuery{
@@ -167,7 +176,6 @@ func (l *DriverLocator) Nearby(ctx context.Context, lon, lat float64, radiusMete
 	}).Result()
 	if err != nil {
 		if err == redis.Nil {
-			fmt.Println("redis Nearby: no free drivers found")
 			return nil, nil
 		}
 		return nil, err
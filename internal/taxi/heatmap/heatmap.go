@@ -0,0 +1,209 @@
+package heatmap
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/repo"
+)
+
+const (
+	// maxPushCells ограничивает размер пуша водителям — отправляем самые "горячие" ячейки.
+	maxPushCells = 300
+	// cityRadiusM — радиус вокруг центра города; ячейки дальше в городской снимок не попадают.
+	cityRadiusM = 50000.0
+)
+
+// Cell describes demand in a single geohash cell.
+type Cell struct {
+	Geohash   string  `json:"geohash"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	Pickups   int     `json:"pickups"`
+	NotFound  int     `json:"not_found"`
+	Searching int     `json:"searching"`
+	Intensity float64 `json:"intensity"`
+}
+
+// Snapshot is the sliding-window heatmap built by the last refresh.
+type Snapshot struct {
+	GeneratedAt time.Time
+	Window      time.Duration
+	Precision   int
+	Cells       []Cell
+}
+
+// ForCity keeps the hottest cells around the city center and normalizes intensity within the city,
+// so drivers of a small city still see their own hot spots.
+func (s Snapshot) ForCity(center geo.Point) Snapshot {
+	cells := make([]Cell, 0)
+	for _, c := range s.Cells {
+		if geo.DistanceMeters(center.Lon, center.Lat, c.Lon, c.Lat) <= cityRadiusM {
+			cells = append(cells, c)
+		}
+	}
+	normalize(cells)
+	if len(cells) > maxPushCells {
+		cells = cells[:maxPushCells]
+	}
+	s.Cells = cells
+	return s
+}
+
+// Repository provides raw demand points and stores pre-aggregated buckets.
+type Repository interface {
+	ListDemandPoints(ctx context.Context, from, to time.Time) ([]repo.DemandPoint, error)
+	ListSearchingPoints(ctx context.Context) ([]repo.DemandPoint, error)
+	ReplaceBucket(ctx context.Context, bucketStart time.Time, cells []repo.HeatmapCell) error
+	SumCells(ctx context.Context, from, to time.Time) ([]repo.HeatmapCell, error)
+}
+
+// Config controls heatmap aggregation.
+type Config struct {
+	Precision int
+	Bucket    time.Duration
+	Window    time.Duration
+	// Settle — сколько времени заказ может оставаться в поиске; бакеты за этот период пересчитываются,
+	// чтобы учесть заказы, позже ушедшие в not_found.
+	Settle time.Duration
+}
+
+// Service aggregates order demand into geohash buckets and keeps the latest sliding-window snapshot.
+type Service struct {
+	repo Repository
+	cfg  Config
+
+	mu       sync.RWMutex
+	snapshot Snapshot
+}
+
+// New builds a heatmap service.
+func New(r Repository, cfg Config) *Service {
+	return &Service{repo: r, cfg: cfg}
+}
+
+// Bucketize groups demand points into geohash cells.
+func Bucketize(points []repo.DemandPoint, precision int) []repo.HeatmapCell {
+	index := make(map[string]int)
+	cells := make([]repo.HeatmapCell, 0)
+	for _, p := range points {
+		hash := geo.EncodeGeohash(p.Lat, p.Lon, precision)
+		i, ok := index[hash]
+		if !ok {
+			i = len(cells)
+			index[hash] = i
+			cells = append(cells, repo.HeatmapCell{Geohash: hash})
+		}
+		cells[i].Pickups++
+		if p.NotFound {
+			cells[i].NotFound++
+		}
+	}
+	return cells
+}
+
+// Refresh re-aggregates recent buckets and rebuilds the sliding-window snapshot.
+func (s *Service) Refresh(ctx context.Context, now time.Time) error {
+	current := now.Truncate(s.cfg.Bucket)
+	for start := current.Add(-s.cfg.Settle).Truncate(s.cfg.Bucket); !start.After(current); start = start.Add(s.cfg.Bucket) {
+		points, err := s.repo.ListDemandPoints(ctx, start, start.Add(s.cfg.Bucket))
+		if err != nil {
+			return err
+		}
+		if err := s.repo.ReplaceBucket(ctx, start, Bucketize(points, s.cfg.Precision)); err != nil {
+			return err
+		}
+	}
+
+	cells, err := s.Range(ctx, now.Add(-s.cfg.Window), now)
+	if err != nil {
+		return err
+	}
+	searching, err := s.repo.ListSearchingPoints(ctx)
+	if err != nil {
+		return err
+	}
+	cells = mergeSearching(cells, Bucketize(searching, s.cfg.Precision))
+
+	s.mu.Lock()
+	s.snapshot = Snapshot{
+		GeneratedAt: now,
+		Window:      s.cfg.Window,
+		Precision:   s.cfg.Precision,
+		Cells:       cells,
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Snapshot returns the latest sliding-window heatmap.
+func (s *Service) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshot
+}
+
+// Precision returns geohash length used for cells.
+func (s *Service) Precision() int {
+	return s.cfg.Precision
+}
+
+// Range returns aggregated cells for buckets within [from, to), sorted by intensity.
+func (s *Service) Range(ctx context.Context, from, to time.Time) ([]Cell, error) {
+	items, err := s.repo.SumCells(ctx, from.Truncate(s.cfg.Bucket), to)
+	if err != nil {
+		return nil, err
+	}
+	cells := make([]Cell, 0, len(items))
+	for _, item := range items {
+		lat, lon, ok := geo.DecodeGeohash(item.Geohash)
+		if !ok {
+			continue
+		}
+		cells = append(cells, Cell{Geohash: item.Geohash, Lat: lat, Lon: lon, Pickups: item.Pickups, NotFound: item.NotFound})
+	}
+	normalize(cells)
+	return cells, nil
+}
+
+func mergeSearching(cells []Cell, searching []repo.HeatmapCell) []Cell {
+	index := make(map[string]int, len(cells))
+	for i, c := range cells {
+		index[c.Geohash] = i
+	}
+	for _, item := range searching {
+		if i, ok := index[item.Geohash]; ok {
+			cells[i].Searching = item.Pickups
+			continue
+		}
+		lat, lon, ok := geo.DecodeGeohash(item.Geohash)
+		if !ok {
+			continue
+		}
+		cells = append(cells, Cell{Geohash: item.Geohash, Lat: lat, Lon: lon, Searching: item.Pickups})
+	}
+	normalize(cells)
+	return cells
+}
+
+// normalize считает интенсивность 0..1; неудовлетворённый спрос (not_found, ожидающие) весит вдвое больше.
+func normalize(cells []Cell) {
+	score := func(c Cell) float64 {
+		return float64(c.Pickups + c.NotFound + 2*c.Searching)
+	}
+	maxScore := 0.0
+	for _, c := range cells {
+		if v := score(c); v > maxScore {
+			maxScore = v
+		}
+	}
+	for i := range cells {
+		if maxScore > 0 {
+			cells[i].Intensity = score(cells[i]) / maxScore
+		}
+	}
+	sort.SliceStable(cells, func(i, j int) bool { return cells[i].Intensity > cells[j].Intensity })
+}
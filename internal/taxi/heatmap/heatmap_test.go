@@ -0,0 +1,63 @@
+package heatmap
+
+import (
+	"testing"
+
+	"naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/repo"
+)
+
+func TestBucketize(t *testing.T) {
+	points := []repo.DemandPoint{
+		{Lon: 76.9450, Lat: 43.2380},
+		{Lon: 76.9451, Lat: 43.2381, NotFound: true},
+		{Lon: 76.7000, Lat: 43.3000},
+	}
+	cells := Bucketize(points, 6)
+	if len(cells) != 2 {
+		t.Fatalf("expected 2 cells, got %d", len(cells))
+	}
+	if cells[0].Pickups != 2 || cells[0].NotFound != 1 {
+		t.Fatalf("unexpected first cell: %+v", cells[0])
+	}
+	if cells[1].Pickups != 1 || cells[1].NotFound != 0 {
+		t.Fatalf("unexpected second cell: %+v", cells[1])
+	}
+}
+
+func TestMergeSearchingNormalizes(t *testing.T) {
+	cells := mergeSearching(nil, []repo.HeatmapCell{{Geohash: "txwtvx", Pickups: 3}, {Geohash: "txwtvy", Pickups: 1}})
+	if len(cells) != 2 {
+		t.Fatalf("expected 2 cells, got %d", len(cells))
+	}
+	if cells[0].Geohash != "txwtvx" || cells[0].Intensity != 1 {
+		t.Fatalf("expected hottest cell first, got %+v", cells[0])
+	}
+	if cells[1].Intensity <= 0 || cells[1].Intensity >= 1 {
+		t.Fatalf("unexpected intensity: %+v", cells[1])
+	}
+}
+
+func TestSnapshotForCity(t *testing.T) {
+	snap := Snapshot{Precision: 6, Cells: []Cell{
+		{Geohash: "almaty-hot", Lon: 76.94, Lat: 43.24, Pickups: 2, Intensity: 0.2},
+		{Geohash: "astana-hot", Lon: 71.43, Lat: 51.13, Pickups: 10, Intensity: 1},
+		{Geohash: "almaty-cold", Lon: 76.90, Lat: 43.20, Pickups: 1, Intensity: 0.1},
+	}}
+
+	almaty := snap.ForCity(geo.Point{Lon: 76.92, Lat: 43.22})
+	if len(almaty.Cells) != 2 {
+		t.Fatalf("expected only Almaty cells, got %+v", almaty.Cells)
+	}
+	if almaty.Cells[0].Geohash != "almaty-hot" || almaty.Cells[0].Intensity != 1 {
+		t.Fatalf("expected intensity normalized within the city, got %+v", almaty.Cells[0])
+	}
+	if snap.Cells[0].Intensity != 0.2 {
+		t.Fatalf("city snapshot must not modify the shared one, got %+v", snap.Cells[0])
+	}
+
+	astana := snap.ForCity(geo.Point{Lon: 71.45, Lat: 51.15})
+	if len(astana.Cells) != 1 || astana.Cells[0].Geohash != "astana-hot" {
+		t.Fatalf("expected only Astana cells, got %+v", astana.Cells)
+	}
+}
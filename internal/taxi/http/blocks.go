@@ -0,0 +1,234 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/taxi/repo"
+)
+
+type blockResponse struct {
+	ID          int64     `json:"id"`
+	PassengerID int64     `json:"passenger_id"`
+	DriverID    int64     `json:"driver_id"`
+	Initiator   string    `json:"initiator"`
+	Source      string    `json:"source"`
+	OrderID     *int64    `json:"order_id,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func makeBlockResponse(b repo.Block) blockResponse {
+	resp := blockResponse{
+		ID:          b.ID,
+		PassengerID: b.PassengerID,
+		DriverID:    b.DriverID,
+		Initiator:   b.Initiator,
+		Source:      b.Source,
+		Reason:      nstr(b.Reason),
+		CreatedAt:   b.CreatedAt,
+	}
+	if b.OrderID.Valid {
+		v := b.OrderID.Int64
+		resp.OrderID = &v
+	}
+	return resp
+}
+
+func makeBlockResponses(items []repo.Block) []blockResponse {
+	out := make([]blockResponse, 0, len(items))
+	for _, b := range items {
+		out = append(out, makeBlockResponse(b))
+	}
+	return out
+}
+
+// pairBlocked сообщает, запрещено ли сводить пассажира и водителя. Ошибку репозитория не считаем блокировкой.
+func (s *Server) pairBlocked(ctx context.Context, passengerID, driverID int64) bool {
+	if s.blocksRepo == nil {
+		return false
+	}
+	blocked, err := s.blocksRepo.IsBlocked(ctx, passengerID, driverID)
+	if err != nil {
+		s.logger.Errorf("blocks: check passenger=%d driver=%d failed: %v", passengerID, driverID, err)
+		return false
+	}
+	return blocked
+}
+
+// autoBlockOnRating блокирует пару после отзыва с низкой оценкой.
+func (s *Server) autoBlockOnRating(ctx context.Context, orderID int64, initiator string, rating *float64) {
+	threshold := s.cfg.GetBlockRatingThreshold()
+	if s.blocksRepo == nil || rating == nil || threshold <= 0 || *rating > float64(threshold) {
+		return
+	}
+	order, err := s.ordersRepo.Get(ctx, orderID)
+	if err != nil {
+		s.logger.Errorf("blocks: load order %d failed: %v", orderID, err)
+		return
+	}
+	if !order.DriverID.Valid {
+		return
+	}
+	block := repo.Block{
+		PassengerID: order.PassengerID,
+		DriverID:    order.DriverID.Int64,
+		Initiator:   initiator,
+		Source:      repo.BlockSourceRating,
+		OrderID:     sql.NullInt64{Int64: orderID, Valid: true},
+		Reason:      sql.NullString{String: "rating " + strconv.FormatFloat(*rating, 'f', -1, 64), Valid: true},
+	}
+	if err := s.blocksRepo.Block(ctx, block); err != nil {
+		s.logger.Errorf("blocks: auto block order=%d failed: %v", orderID, err)
+	}
+}
+
+// handlePassengerBlocks: GET список, POST заблокировать водителя, DELETE ?driver_id= разблокировать.
+func (s *Server) handlePassengerBlocks(w http.ResponseWriter, r *http.Request) {
+	passengerID, err := parseAuthID(r, "X-Passenger-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing passenger id")
+		return
+	}
+	s.handleActorBlocks(w, r, repo.BlockInitiatorPassenger, passengerID, "driver_id")
+}
+
+// handleDriverBlocks: GET список, POST заблокировать пассажира, DELETE ?passenger_id= разблокировать.
+func (s *Server) handleDriverBlocks(w http.ResponseWriter, r *http.Request) {
+	driverID, err := parseAuthID(r, "X-Driver-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing driver id")
+		return
+	}
+	s.handleActorBlocks(w, r, repo.BlockInitiatorDriver, driverID, "passenger_id")
+}
+
+func (s *Server) handleActorBlocks(w http.ResponseWriter, r *http.Request, initiator string, actorID int64, targetField string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	pair := func(targetID int64) (int64, int64) {
+		if initiator == repo.BlockInitiatorPassenger {
+			return actorID, targetID
+		}
+		return targetID, actorID
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		items, err := s.blocksRepo.ListByInitiator(ctx, initiator, actorID)
+		if err != nil {
+			s.logger.Errorf("list blocks failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load blocks")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"blocks": makeBlockResponses(items)})
+	case http.MethodPost:
+		var req struct {
+			PassengerID int64  `json:"passenger_id"`
+			DriverID    int64  `json:"driver_id"`
+			OrderID     int64  `json:"order_id"`
+			Reason      string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		targetID := req.DriverID
+		if initiator == repo.BlockInitiatorDriver {
+			targetID = req.PassengerID
+		}
+		if targetID <= 0 {
+			writeError(w, http.StatusBadRequest, targetField+" is required")
+			return
+		}
+		passengerID, driverID := pair(targetID)
+		block := repo.Block{PassengerID: passengerID, DriverID: driverID, Initiator: initiator, Source: repo.BlockSourceManual}
+		if req.OrderID > 0 {
+			block.OrderID = sql.NullInt64{Int64: req.OrderID, Valid: true}
+		}
+		if reason := strings.TrimSpace(req.Reason); reason != "" {
+			block.Reason = sql.NullString{String: reason, Valid: true}
+		}
+		if err := s.blocksRepo.Block(ctx, block); err != nil {
+			s.logger.Errorf("block failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to block")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "blocked"})
+	case http.MethodDelete:
+		targetID, err := strconv.ParseInt(r.URL.Query().Get(targetField), 10, 64)
+		if err != nil || targetID <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid "+targetField)
+			return
+		}
+		passengerID, driverID := pair(targetID)
+		if err := s.blocksRepo.Unblock(ctx, passengerID, driverID, initiator); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusNotFound, "block not found")
+				return
+			}
+			s.logger.Errorf("unblock failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to unblock")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "unblocked"})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminTaxiBlocks lists blocks with optional passenger_id, driver_id and source filters.
+func (s *Server) handleAdminTaxiBlocks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	limit, offset, err := parseLimitOffset(r, 50)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := repo.BlockFilter{Limit: limit, Offset: offset}
+	q := r.URL.Query()
+	if v := q.Get("passenger_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid passenger_id")
+			return
+		}
+		filter.PassengerID = id
+	}
+	if v := q.Get("driver_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid driver_id")
+			return
+		}
+		filter.DriverID = id
+	}
+	if v := strings.TrimSpace(q.Get("source")); v != "" {
+		if v != repo.BlockSourceManual && v != repo.BlockSourceRating {
+			writeError(w, http.StatusBadRequest, "invalid source")
+			return
+		}
+		filter.Source = v
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	items, err := s.blocksRepo.List(ctx, filter)
+	if err != nil {
+		s.logger.Errorf("admin list blocks failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load blocks")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"blocks": makeBlockResponses(items), "limit": limit, "offset": offset})
+}
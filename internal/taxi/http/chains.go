@@ -0,0 +1,170 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"naimuBack/internal/taxi/fsm"
+	"naimuBack/internal/taxi/repo"
+	"naimuBack/internal/taxi/timeutil"
+	"naimuBack/internal/taxi/ws"
+)
+
+// acceptChainedOffer ставит заказ в очередь водителю, который ещё не завершил текущую поездку.
+// Назначение подтверждается только после завершения текущего заказа (см. settleChains).
+func (s *Server) acceptChainedOffer(w http.ResponseWriter, ctx context.Context, order repo.Order, driver repo.Driver, currentOrderID int64) {
+	current, err := s.ordersRepo.Get(ctx, currentOrderID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "active order lookup failed")
+		return
+	}
+	if current.Status != fsm.StatusInProgress && current.Status != fsm.StatusAtLastPoint {
+		writeError(w, http.StatusConflict, "driver has active order")
+		return
+	}
+	if order.Status != fsm.StatusSearching {
+		writeError(w, http.StatusConflict, "order not searching")
+		return
+	}
+	pending, err := s.chainsRepo.HasPendingByDriver(ctx, driver.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "chain lookup failed")
+		return
+	}
+	if pending {
+		writeError(w, http.StatusConflict, "driver already has chained order")
+		return
+	}
+
+	closedDrivers, pricePtr, err := s.offersRepo.AcceptOffer(ctx, order.ID, driver.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusConflict, "offer not available")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "accept failed")
+		return
+	}
+	if pricePtr != nil && *pricePtr > 0 && order.ClientPrice != *pricePtr {
+		if err := s.ordersRepo.UpdatePrice(ctx, order.ID, order.ClientPrice, *pricePtr); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Errorf("update price failed: %v", err)
+		}
+	}
+
+	expected := timeutil.Now().Add(s.cfg.GetChainMaxETA() + s.cfg.GetChainDelayGrace())
+	if _, err := s.chainsRepo.CreatePending(ctx, order.ID, driver.ID, current.ID, expected); err != nil {
+		s.logger.Errorf("chain order %d after %d failed: %v", order.ID, current.ID, err)
+		writeError(w, http.StatusInternalServerError, "chain failed")
+		return
+	}
+
+	driverInfo := s.passengerDriverCard(driver)
+	s.passengerHub.PushOrderEvent(order.PassengerID, ws.PassengerEvent{
+		Type:     "order_chained",
+		OrderID:  order.ID,
+		Status:   fsm.StatusSearching,
+		DriverID: driver.ID,
+		Driver:   &driverInfo,
+		Message:  "driver is finishing previous trip",
+	})
+
+	if len(closedDrivers) > 0 {
+		s.driverHub.NotifyOfferClosed(order.ID, closedDrivers, "accepted_by_other")
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "chained", "after_order_id": current.ID})
+}
+
+// settleChains подтверждает или снимает цепочку после того, как заказ перешёл в финальный статус.
+func (s *Server) settleChains(ctx context.Context, order repo.Order) {
+	if s.chainsRepo == nil {
+		return
+	}
+	switch order.Status {
+	case fsm.StatusCompleted:
+		if order.DriverID.Valid {
+			s.confirmChain(ctx, order.ID, order.DriverID.Int64)
+		}
+	case fsm.StatusCanceled, fsm.StatusCanceledByPassenger, fsm.StatusCanceledByDriver, fsm.StatusNoShow:
+		s.releaseChainsForOrder(ctx, order.ID, "canceled")
+	}
+}
+
+func (s *Server) confirmChain(ctx context.Context, currentOrderID, driverID int64) {
+	chain, err := s.chainsRepo.GetPendingByDriver(ctx, driverID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Errorf("chain lookup for driver %d failed: %v", driverID, err)
+		}
+		return
+	}
+	if chain.CurrentOrderID != currentOrderID {
+		return
+	}
+	if err := s.chainsRepo.Confirm(ctx, chain.ID, chain.OrderID, driverID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return
+		}
+		// заказ успели отменить или база недоступна — цепочка осталась pending, возвращаем заказ в поиск
+		s.logger.Errorf("confirm chain %d (order %d, driver %d) failed: %v", chain.ID, chain.OrderID, driverID, err)
+		s.releaseChain(ctx, chain, "order_unavailable")
+		return
+	}
+
+	next, err := s.ordersRepo.Get(ctx, chain.OrderID)
+	if err != nil {
+		s.logger.Errorf("load chained order %d failed: %v", chain.OrderID, err)
+	} else {
+		s.passengerHub.PushOrderEvent(next.PassengerID, ws.PassengerEvent{Type: "order_assigned", OrderID: next.ID, Status: fsm.StatusAccepted, DriverID: driverID})
+	}
+	s.driverHub.NotifyChain(driverID, ws.DriverChainPayload{OrderID: chain.OrderID, AfterOrderID: currentOrderID, Status: repo.ChainStatusConfirmed})
+}
+
+// releaseChainsForOrder снимает все ожидающие цепочки, где заказ участвует как текущий или следующий.
+func (s *Server) releaseChainsForOrder(ctx context.Context, orderID int64, reason string) {
+	chains, err := s.chainsRepo.ListPendingByOrder(ctx, orderID)
+	if err != nil {
+		s.logger.Errorf("list chains for order %d failed: %v", orderID, err)
+		return
+	}
+	for _, chain := range chains {
+		s.releaseChain(ctx, chain, reason)
+	}
+}
+
+// releaseChain возвращает следующий заказ в обычный поиск.
+func (s *Server) releaseChain(ctx context.Context, chain repo.OrderChain, reason string) {
+	if err := s.chainsRepo.Release(ctx, chain.ID, chain.OrderID, reason, s.cfg.GetSearchRadiusStart(), timeutil.Now()); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Errorf("release chain %d failed: %v", chain.ID, err)
+		}
+		return
+	}
+	s.driverHub.NotifyChain(chain.DriverID, ws.DriverChainPayload{OrderID: chain.OrderID, AfterOrderID: chain.CurrentOrderID, Status: repo.ChainStatusReleased, Reason: reason})
+
+	next, err := s.ordersRepo.Get(ctx, chain.OrderID)
+	if err != nil {
+		s.logger.Errorf("load chained order %d failed: %v", chain.OrderID, err)
+		return
+	}
+	if next.Status == fsm.StatusSearching {
+		s.passengerHub.PushOrderEvent(next.PassengerID, ws.PassengerEvent{Type: "chain_released", OrderID: next.ID, Status: fsm.StatusSearching, Message: reason})
+	}
+}
+
+// ReleaseOverdueChains returns queued orders to search when the current trip is delayed.
+func (s *Server) ReleaseOverdueChains(ctx context.Context) {
+	if s.chainsRepo == nil {
+		return
+	}
+	chains, err := s.chainsRepo.ListOverdue(ctx, timeutil.Now())
+	if err != nil {
+		s.logger.Errorf("list overdue chains failed: %v", err)
+		return
+	}
+	for _, chain := range chains {
+		s.releaseChain(ctx, chain, "delayed")
+	}
+}
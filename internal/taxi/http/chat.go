@@ -0,0 +1,234 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/chat"
+	"naimuBack/internal/taxi/fsm"
+	"naimuBack/internal/taxi/repo"
+	"naimuBack/internal/taxi/timeutil"
+	"naimuBack/internal/taxi/ws"
+)
+
+type chatResponse struct {
+	OrderID  int64          `json:"order_id"`
+	Open     bool           `json:"open"`
+	Presets  []chat.Preset  `json:"presets"`
+	Messages []chat.Message `json:"messages"`
+}
+
+type chatMessageRequest struct {
+	Text   string `json:"text"`
+	Preset string `json:"preset"`
+}
+
+// chatOpen — чат доступен, пока водитель назначен и поездка не завершена.
+func chatOpen(status string) bool {
+	switch status {
+	case fsm.StatusAccepted, fsm.StatusAssigned, fsm.StatusDriverAtPickup, fsm.StatusWaitingFree, fsm.StatusWaitingPaid,
+		fsm.StatusArrived, fsm.StatusPickedUp, fsm.StatusInProgress, fsm.StatusAtLastPoint:
+		return true
+	}
+	return false
+}
+
+func chatFinished(status string) bool {
+	switch status {
+	case fsm.StatusCompleted, fsm.StatusPaid, fsm.StatusClosed, fsm.StatusCanceled, fsm.StatusCanceledByPassenger,
+		fsm.StatusCanceledByDriver, fsm.StatusNoShow, fsm.StatusNotFound:
+		return true
+	}
+	return false
+}
+
+func chatParticipant(order repo.Order, role string, actorID int64) bool {
+	switch role {
+	case chat.RolePassenger:
+		return order.PassengerID == actorID
+	case chat.RoleDriver:
+		return order.DriverID.Valid && order.DriverID.Int64 == actorID
+	}
+	return false
+}
+
+// passengerDriverCard builds the driver card for passenger events without the phone when the chat replaces calls.
+func (s *Server) passengerDriverCard(d repo.Driver) ws.PassengerDriver {
+	card := newPassengerDriver(d)
+	if s.cfg.GetChatHidePhones() {
+		card.Phone = ""
+	}
+	return card
+}
+
+// participantOrderResponse is the order as seen by its passenger or driver.
+func (s *Server) participantOrderResponse(o repo.Order, driver *repo.Driver, passenger *repo.Passenger) orderResponse {
+	resp := newOrderResponse(o, driver, passenger)
+	if s.cfg.GetChatHidePhones() {
+		if resp.Driver != nil {
+			resp.Driver.Phone = ""
+		}
+		if resp.Passenger != nil {
+			resp.Passenger.Phone = ""
+		}
+	}
+	return resp
+}
+
+func (s *Server) pushChatEvent(order repo.Order, evt chat.Event) {
+	s.passengerHub.SendChatEvent(order.PassengerID, evt)
+	if order.DriverID.Valid {
+		s.driverHub.SendChatEvent(order.DriverID.Int64, evt)
+	}
+}
+
+func (s *Server) postChatMessage(ctx context.Context, orderID int64, role string, actorID int64, text, preset string) (chat.Message, error) {
+	order, err := s.ordersRepo.Get(ctx, orderID)
+	if err != nil {
+		return chat.Message{}, err
+	}
+	if !chatParticipant(order, role, actorID) {
+		return chat.Message{}, chat.ErrNotParticipant
+	}
+	if !chatOpen(order.Status) {
+		return chat.Message{}, chat.ErrChatClosed
+	}
+	body, err := chat.Compose(role, text, preset)
+	if err != nil {
+		return chat.Message{}, err
+	}
+	msg, err := s.chatRepo.Create(ctx, chat.Message{
+		Service:    chat.ServiceTaxi,
+		OrderID:    order.ID,
+		SenderRole: role,
+		SenderID:   actorID,
+		Body:       body,
+		Preset:     strings.TrimSpace(preset),
+		CreatedAt:  timeutil.Now(),
+	})
+	if err != nil {
+		return chat.Message{}, err
+	}
+	s.pushChatEvent(order, chat.Event{Type: chat.TypeMessage, OrderID: order.ID, Message: &msg})
+	return msg, nil
+}
+
+// ChatMessage handles chat messages sent over passenger and driver WebSocket.
+func (s *Server) ChatMessage(ctx context.Context, role string, senderID int64, in chat.Incoming) error {
+	_, err := s.postChatMessage(ctx, in.OrderID, role, senderID, in.Text, in.Preset)
+	if errors.Is(err, sql.ErrNoRows) {
+		return chat.ErrNotParticipant
+	}
+	if err != nil && !chat.IsClientError(err) {
+		s.logger.Errorf("chat: order %d message from %s %d failed: %v", in.OrderID, role, senderID, err)
+	}
+	return err
+}
+
+// closeOrderChat tells both parties the chat is closed once the order is finished.
+func (s *Server) closeOrderChat(order repo.Order) {
+	if !chatFinished(order.Status) || !order.DriverID.Valid {
+		return
+	}
+	s.pushChatEvent(order, chat.Event{Type: chat.TypeClosed, OrderID: order.ID})
+}
+
+func (s *Server) handleDriverOrderSubroutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/driver/orders/"), "/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	switch parts[1] {
+	case "chat":
+		s.handleOrderChat(w, r, id, chat.RoleDriver)
+	case "sos":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleOrderSOS(w, r, id, chat.RoleDriver)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) handleOrderChat(w http.ResponseWriter, r *http.Request, orderID int64, role string) {
+	header := "X-Passenger-ID"
+	if role == chat.RoleDriver {
+		header = "X-Driver-ID"
+	}
+	actorID, err := parseAuthID(r, header)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing "+role+" id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		order, err := s.ordersRepo.Get(ctx, orderID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusNotFound, "order not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "fetch order failed")
+			return
+		}
+		if !chatParticipant(order, role, actorID) {
+			writeError(w, http.StatusForbidden, chat.ErrNotParticipant.Error())
+			return
+		}
+		messages, err := s.chatRepo.List(ctx, chat.ServiceTaxi, orderID)
+		if err != nil {
+			s.logger.Errorf("chat: list order %d failed: %v", orderID, err)
+			writeError(w, http.StatusInternalServerError, "list messages failed")
+			return
+		}
+		writeJSON(w, http.StatusOK, chatResponse{
+			OrderID:  orderID,
+			Open:     chatOpen(order.Status),
+			Presets:  chat.Presets(role),
+			Messages: messages,
+		})
+	case http.MethodPost:
+		var req chatMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		msg, err := s.postChatMessage(ctx, orderID, role, actorID, req.Text, req.Preset)
+		switch {
+		case err == nil:
+			writeJSON(w, http.StatusCreated, msg)
+		case errors.Is(err, sql.ErrNoRows):
+			writeError(w, http.StatusNotFound, "order not found")
+		case errors.Is(err, chat.ErrNotParticipant):
+			writeError(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, chat.ErrChatClosed):
+			writeError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, chat.ErrEmptyMessage), errors.Is(err, chat.ErrMessageTooLong), errors.Is(err, chat.ErrUnknownPreset):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			s.logger.Errorf("chat: order %d message from %s %d failed: %v", orderID, role, actorID, err)
+			writeError(w, http.StatusInternalServerError, "send message failed")
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,198 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"naimuBack/internal/commission"
+	"naimuBack/internal/taxi/repo"
+	"naimuBack/internal/taxi/timeutil"
+)
+
+// intercityTariffClass отличает комиссию за объявления межгорода от городских поездок.
+const intercityTariffClass = "intercity"
+
+// completionCommission — комиссия при завершении заказа. Плата за возврат забытой вещи
+// целиком остаётся водителю.
+func (s *Server) completionCommission(ctx context.Context, driverID int64, order repo.Order) repo.OrderCommission {
+	if order.OrderType == repo.OrderTypeLostItemReturn {
+		return repo.OrderCommission{}
+	}
+	return s.driverCommission(ctx, driverID, order.ClientPrice, s.commissionTarget(ctx, driverID))
+}
+
+// commissionTarget — город и класс тарифа, по которым подбирается правило комиссии.
+type commissionTarget struct {
+	City        string
+	TariffClass string
+}
+
+// commissionTarget берёт город текущей или последней онлайн-сессии водителя и класс его активной
+// машины; если их не узнать, действуют регион сервиса и класс по умолчанию.
+func (s *Server) commissionTarget(ctx context.Context, driverID int64) commissionTarget {
+	target := commissionTarget{City: s.cityKey(), TariffClass: commission.DefaultTariffClass}
+	if driverID <= 0 {
+		return target
+	}
+	if city, ok := s.driverHub.City(driverID); ok && city != "" {
+		target.City = city
+	} else if s.questsRepo != nil {
+		city, err := s.questsRepo.DriverCity(ctx, driverID)
+		if err != nil {
+			s.logger.Errorf("commission: driver %d city failed: %v", driverID, err)
+		} else if city != "" {
+			target.City = city
+		}
+	}
+	vehicle, err := s.driversRepo.ActiveVehicle(ctx, driverID)
+	switch {
+	case err == nil && vehicle.TariffClass != "":
+		target.TariffClass = vehicle.TariffClass
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		s.logger.Errorf("commission: driver %d vehicle failed: %v", driverID, err)
+	}
+	return target
+}
+
+// driverCommission считает комиссию водителя по правилам. Ошибка движка не должна блокировать
+// завершение заказа, поэтому в этом случае берём процент по умолчанию.
+func (s *Server) driverCommission(ctx context.Context, driverID int64, amount int, target commissionTarget) repo.OrderCommission {
+	if s.commission == nil {
+		return repo.OrderCommission{Amount: commission.Default(amount)}
+	}
+	subject := commission.Subject{
+		Service:     commission.ServiceTaxi,
+		City:        target.City,
+		TariffClass: target.TariffClass,
+		Amount:      amount,
+		At:          timeutil.Now(),
+	}
+	if driverID > 0 {
+		joined, err := s.driversRepo.JoinedAt(ctx, driverID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Errorf("commission: driver %d join date failed: %v", driverID, err)
+		}
+		subject.JoinedAt = joined
+	}
+	res, err := s.commission.Compute(ctx, driverID, subject)
+	if err != nil {
+		s.logger.Errorf("commission: compute for driver %d failed: %v", driverID, err)
+		return repo.OrderCommission{Amount: commission.Default(amount)}
+	}
+	charge := repo.OrderCommission{Amount: res.Amount}
+	if res.RuleID > 0 {
+		charge.RuleID = sql.NullInt64{Int64: res.RuleID, Valid: true}
+	}
+	if res.PassID > 0 {
+		charge.PassID = sql.NullInt64{Int64: res.PassID, Valid: true}
+	}
+	return charge
+}
+
+// recordedCommission returns the commission charged for the order; orders completed before
+// the rules engine fall back to the default percent.
+func recordedCommission(order repo.Order) int {
+	if order.Commission.Valid {
+		return int(order.Commission.Int64)
+	}
+	return commission.Default(order.ClientPrice)
+}
+
+func (s *Server) cityKey() string {
+	if city := strings.TrimSpace(s.cfg.GetRegionID()); city != "" {
+		return city
+	}
+	return "astana"
+}
+
+// handleDriverCommissionPasses: GET — доступные тарифы смены и активный пропуск, POST — покупка пропуска с баланса.
+func (s *Server) handleDriverCommissionPasses(w http.ResponseWriter, r *http.Request) {
+	driverID, err := parseAuthID(r, "X-Driver-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing driver id")
+		return
+	}
+	if s.commissionRepo == nil {
+		writeError(w, http.StatusServiceUnavailable, "commission rules disabled")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		plans, err := s.commissionRepo.ListPassPlans(ctx, commission.ServiceTaxi)
+		if err != nil {
+			s.logger.Errorf("list pass plans failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load plans")
+			return
+		}
+		planResp := make([]commission.RuleResponse, 0, len(plans))
+		for _, p := range plans {
+			planResp = append(planResp, commission.MakeRuleResponse(p))
+		}
+		resp := map[string]interface{}{"plans": planResp, "active": nil}
+		active, err := s.commissionRepo.ActivePass(ctx, commission.ServiceTaxi, driverID, timeutil.Now())
+		if err == nil {
+			resp["active"] = commission.MakePassResponse(active)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Errorf("active pass failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load pass")
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case http.MethodPost:
+		var req struct {
+			RuleID int64 `json:"rule_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if req.RuleID <= 0 {
+			writeError(w, http.StatusBadRequest, "rule_id is required")
+			return
+		}
+		if _, ok := s.getDriverForAction(w, ctx, driverID); !ok {
+			return
+		}
+		plan, err := s.commissionRepo.GetRule(ctx, req.RuleID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusNotFound, "plan not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to load plan")
+			return
+		}
+		pass, err := plan.NewPass(commission.ServiceTaxi, driverID, timeutil.Now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "rule is not a shift pass plan")
+			return
+		}
+
+		id, err := s.commissionRepo.BuyPass(ctx, pass, repo.WithdrawTx)
+		if err != nil {
+			switch {
+			case errors.Is(err, repo.ErrInsufficientBalance):
+				writeError(w, http.StatusConflict, "insufficient balance")
+			case errors.Is(err, commission.ErrPassActive):
+				writeError(w, http.StatusConflict, "shift pass already active")
+			default:
+				s.logger.Errorf("buy shift pass failed: %v", err)
+				writeError(w, http.StatusInternalServerError, "failed to create pass")
+			}
+			return
+		}
+		pass.ID = id
+		writeJSON(w, http.StatusCreated, commission.MakePassResponse(pass))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,62 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"naimuBack/internal/commission"
+	"naimuBack/internal/taxi/dispatch"
+)
+
+type stubCommissionStore struct {
+	rules []commission.Rule
+}
+
+func (s stubCommissionStore) ListActiveRules(context.Context, string) ([]commission.Rule, error) {
+	return s.rules, nil
+}
+
+func (stubCommissionStore) ActivePass(context.Context, string, int64, time.Time) (commission.Pass, error) {
+	return commission.Pass{}, sql.ErrNoRows
+}
+
+func TestDriverCommissionTargetOverrides(t *testing.T) {
+	rules := []commission.Rule{
+		{ID: 1, Kind: commission.KindPercent, Percent: 10, Active: true},
+		{ID: 2, Kind: commission.KindPercent, City: "almaty", Percent: 5, Active: true},
+		{ID: 3, Kind: commission.KindPercent, TariffClass: "comfort", Percent: 15, Active: true},
+		{ID: 4, Kind: commission.KindPercent, City: "almaty", TariffClass: intercityTariffClass, Percent: 7, Active: true},
+	}
+	s := &Server{commission: commission.NewEngine(stubCommissionStore{rules: rules}, nil)}
+
+	cases := []struct {
+		name   string
+		target commissionTarget
+		rule   int64
+		amount int
+	}{
+		{"default", commissionTarget{City: "astana", TariffClass: commission.DefaultTariffClass}, 1, 1000},
+		{"city", commissionTarget{City: "almaty", TariffClass: commission.DefaultTariffClass}, 2, 500},
+		{"vehicle class", commissionTarget{City: "astana", TariffClass: "comfort"}, 3, 1500},
+		{"city and class", commissionTarget{City: "almaty", TariffClass: intercityTariffClass}, 4, 700},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := s.driverCommission(context.Background(), 0, 10000, tc.target)
+			if got.RuleID.Int64 != tc.rule || got.Amount != tc.amount {
+				t.Fatalf("expected rule %d amount %d got %+v", tc.rule, tc.amount, got)
+			}
+		})
+	}
+}
+
+func TestCommissionTargetWithoutDriver(t *testing.T) {
+	s := &Server{cfg: dispatch.ConfigAdapter{}}
+	got := s.commissionTarget(context.Background(), 0)
+	if got.City != "astana" || got.TariffClass != commission.DefaultTariffClass {
+		t.Fatalf("unexpected target %+v", got)
+	}
+}
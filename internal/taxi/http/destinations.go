@@ -0,0 +1,181 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"naimuBack/internal/taxi/repo"
+	"naimuBack/internal/taxi/timeutil"
+	"naimuBack/internal/taxi/ws"
+)
+
+var errInvalidDestination = errors.New("invalid destination coordinates")
+
+type destinationResponse struct {
+	Lon        float64   `json:"lon"`
+	Lat        float64   `json:"lat"`
+	Address    string    `json:"address,omitempty"`
+	MaxDetourM int       `json:"max_detour_m"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func makeDestinationResponse(d repo.DriverDestination) destinationResponse {
+	return destinationResponse{
+		Lon:        d.Lon,
+		Lat:        d.Lat,
+		Address:    nstr(d.Address),
+		MaxDetourM: d.MaxDetourM,
+		Status:     d.Status,
+		CreatedAt:  d.CreatedAt,
+	}
+}
+
+func destinationToWS(d repo.DriverDestination) *ws.DriverDestination {
+	return &ws.DriverDestination{Lon: d.Lon, Lat: d.Lat, Address: nstr(d.Address), MaxDetourM: d.MaxDetourM}
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = timeutil.InAlmaty(t)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, timeutil.Location())
+}
+
+// handleDriverDestination manages driver's "going home" mode: GET current, POST enable, DELETE disable.
+func (s *Server) handleDriverDestination(w http.ResponseWriter, r *http.Request) {
+	driverID, err := parseAuthID(r, "X-Driver-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing driver id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		dest, err := s.destinationsRepo.GetActive(ctx, driverID)
+		used, cntErr := s.destinationsRepo.CountSince(ctx, driverID, startOfDay(timeutil.Now()))
+		if cntErr != nil {
+			s.logger.Errorf("count destinations failed: %v", cntErr)
+			writeError(w, http.StatusInternalServerError, "failed to load destination")
+			return
+		}
+		resp := map[string]interface{}{
+			"daily_limit": s.destinationDailyLimit,
+			"used_today":  used,
+			"destination": nil,
+		}
+		if err == nil {
+			resp["destination"] = makeDestinationResponse(dest)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Errorf("get destination failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load destination")
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+
+	case http.MethodPost:
+		var req struct {
+			Lon        float64 `json:"lon"`
+			Lat        float64 `json:"lat"`
+			Address    string  `json:"address"`
+			MaxDetourM int     `json:"max_detour_m"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		saved, err := s.SetDestination(ctx, driverID, ws.DriverDestination{Lon: req.Lon, Lat: req.Lat, Address: req.Address, MaxDetourM: req.MaxDetourM})
+		if err != nil {
+			switch {
+			case errors.Is(err, errInvalidDestination):
+				writeError(w, http.StatusBadRequest, err.Error())
+			case errors.Is(err, repo.ErrDestinationLimitReached):
+				writeError(w, http.StatusConflict, err.Error())
+			case errors.Is(err, sql.ErrNoRows):
+				writeError(w, http.StatusNotFound, "driver not found")
+			default:
+				s.logger.Errorf("activate destination failed: %v", err)
+				writeError(w, http.StatusInternalServerError, "failed to activate destination")
+			}
+			return
+		}
+		s.driverHub.UpdateDestination(driverID, saved, "active")
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": repo.DestinationStatusActive, "destination": saved})
+
+	case http.MethodDelete:
+		if err := s.ClearDestination(ctx, driverID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusNotFound, "destination mode is not active")
+				return
+			}
+			s.logger.Errorf("clear destination failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to clear destination")
+			return
+		}
+		s.driverHub.UpdateDestination(driverID, nil, repo.DestinationStatusCanceled)
+		writeJSON(w, http.StatusOK, map[string]string{"status": repo.DestinationStatusCanceled})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// ActiveDestination implements ws.DestinationHandler.
+func (s *Server) ActiveDestination(ctx context.Context, driverID int64) (*ws.DriverDestination, error) {
+	dest, err := s.destinationsRepo.GetActive(ctx, driverID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return destinationToWS(dest), nil
+}
+
+// SetDestination implements ws.DestinationHandler.
+func (s *Server) SetDestination(ctx context.Context, driverID int64, dest ws.DriverDestination) (*ws.DriverDestination, error) {
+	if dest.Lon < -180 || dest.Lon > 180 || dest.Lat < -90 || dest.Lat > 90 || (dest.Lon == 0 && dest.Lat == 0) {
+		return nil, errInvalidDestination
+	}
+	maxDetour := s.cfg.GetDestinationMaxDetourM()
+	if dest.MaxDetourM > 0 && dest.MaxDetourM < maxDetour {
+		maxDetour = dest.MaxDetourM
+	}
+	record := repo.DriverDestination{
+		DriverID:   driverID,
+		Lon:        dest.Lon,
+		Lat:        dest.Lat,
+		MaxDetourM: maxDetour,
+	}
+	if addr := strings.TrimSpace(dest.Address); addr != "" {
+		record.Address = sql.NullString{String: addr, Valid: true}
+	}
+	if _, err := s.destinationsRepo.Activate(ctx, record, s.destinationDailyLimit, startOfDay(timeutil.Now())); err != nil {
+		if errors.Is(err, repo.ErrDestinationLimitReached) {
+			return nil, fmt.Errorf("%w (%d per day)", err, s.destinationDailyLimit)
+		}
+		return nil, err
+	}
+	return destinationToWS(record), nil
+}
+
+// ClearDestination implements ws.DestinationHandler.
+func (s *Server) ClearDestination(ctx context.Context, driverID int64) error {
+	return s.destinationsRepo.Finish(ctx, driverID, repo.DestinationStatusCanceled)
+}
+
+// DestinationArrived implements ws.DestinationHandler.
+func (s *Server) DestinationArrived(ctx context.Context, driverID int64) error {
+	err := s.destinationsRepo.Finish(ctx, driverID, repo.DestinationStatusArrived)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,217 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"naimuBack/internal/taxi/fsm"
+	"naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/repo"
+	"naimuBack/internal/taxi/timeutil"
+	"naimuBack/internal/taxi/ws"
+)
+
+// liveTripRecheck — как часто перечитываем активный заказ водителя, чтобы заметить смену статуса.
+const liveTripRecheck = 10 * time.Second
+
+const (
+	etaTargetPickup      = "pickup"
+	etaTargetDestination = "destination"
+)
+
+type liveTrip struct {
+	orderID     int64
+	passengerID int64
+	status      string
+	fromLon     float64
+	fromLat     float64
+	toLon       float64
+	toLat       float64
+	// path — сохранённая геометрия маршрута заказа, по ней определяем съезд с маршрута.
+	path      []geo.Point
+	checkedAt time.Time
+}
+
+// target returns where the driver is heading in the current status.
+func (t liveTrip) target() (string, float64, float64, bool) {
+	switch t.status {
+	case fsm.StatusAccepted, fsm.StatusAssigned:
+		return etaTargetPickup, t.fromLon, t.fromLat, true
+	case fsm.StatusPickedUp, fsm.StatusInProgress:
+		return etaTargetDestination, t.toLon, t.toLat, true
+	}
+	return "", 0, 0, false
+}
+
+// liveETA caches active trips of drivers and throttles routing requests.
+type liveETA struct {
+	tracker *geo.ETATracker
+
+	mu       sync.Mutex
+	trips    map[int64]liveTrip
+	busy     map[int64]bool
+	offRoute map[int64]bool
+}
+
+func newLiveETA(router geo.Router, refresh time.Duration) *liveETA {
+	return &liveETA{
+		tracker:  geo.NewETATracker(router, refresh),
+		trips:    make(map[int64]liveTrip),
+		busy:     make(map[int64]bool),
+		offRoute: make(map[int64]bool),
+	}
+}
+
+// begin marks the driver as being processed; positions arriving meanwhile are skipped.
+func (l *liveETA) begin(driverID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.busy[driverID] {
+		return false
+	}
+	l.busy[driverID] = true
+	return true
+}
+
+func (l *liveETA) end(driverID int64) {
+	l.mu.Lock()
+	delete(l.busy, driverID)
+	l.mu.Unlock()
+}
+
+func (l *liveETA) cached(driverID int64, now time.Time) (liveTrip, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	trip, ok := l.trips[driverID]
+	if !ok || now.Sub(trip.checkedAt) >= liveTripRecheck {
+		return liveTrip{}, false
+	}
+	return trip, true
+}
+
+func (l *liveETA) remember(driverID int64, trip liveTrip) {
+	l.mu.Lock()
+	prev, ok := l.trips[driverID]
+	l.trips[driverID] = trip
+	l.mu.Unlock()
+	if ok && prev.orderID != 0 && prev.orderID != trip.orderID {
+		l.tracker.Forget(prev.orderID)
+	}
+}
+
+// markOffRoute stores whether the driver has left the route and reports a change.
+func (l *liveETA) markOffRoute(driverID int64, off bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changed := l.offRoute[driverID] != off
+	if off {
+		l.offRoute[driverID] = true
+	} else {
+		delete(l.offRoute, driverID)
+	}
+	return changed
+}
+
+// forget drops the driver's trip once it ends or the driver goes offline.
+func (l *liveETA) forget(driverID int64) {
+	l.mu.Lock()
+	trip, ok := l.trips[driverID]
+	delete(l.trips, driverID)
+	delete(l.offRoute, driverID)
+	l.mu.Unlock()
+	if ok && trip.orderID != 0 {
+		l.tracker.Forget(trip.orderID)
+	}
+}
+
+// endLiveTrip drops the live ETA state of the driver once the order is finished.
+func (s *Server) endLiveTrip(order repo.Order) {
+	if s.eta == nil || !order.DriverID.Valid || !chatFinished(order.Status) {
+		return
+	}
+	s.eta.forget(order.DriverID.Int64)
+}
+
+// DriverLocation implements ws.LocationHandler: recomputes the ETA and pushes it to the passenger.
+func (s *Server) DriverLocation(driverID int64, lon, lat float64) {
+	if s.eta == nil || !s.eta.begin(driverID) {
+		return
+	}
+	go func() {
+		defer s.eta.end(driverID)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		s.pushDriverETA(ctx, driverID, lon, lat)
+	}()
+}
+
+func (s *Server) pushDriverETA(ctx context.Context, driverID int64, lon, lat float64) {
+	now := timeutil.Now()
+	trip, ok := s.eta.cached(driverID, now)
+	if !ok {
+		var err error
+		trip, err = s.loadLiveTrip(ctx, driverID, now)
+		if err != nil {
+			s.logger.Errorf("eta: load active order of driver %d failed: %v", driverID, err)
+			return
+		}
+		s.eta.remember(driverID, trip)
+	}
+	target, toLon, toLat, ok := trip.target()
+	if !ok {
+		return
+	}
+	offRoute := target == etaTargetDestination && geo.OffRoute(lon, lat, trip.path, offRouteToleranceM)
+	if s.eta.markOffRoute(driverID, offRoute) && offRoute {
+		// водитель съехал с маршрута — прежний ETA неверен, перестраиваем сразу
+		s.eta.tracker.Forget(trip.orderID)
+	}
+	eta, err := s.eta.tracker.Estimate(ctx, trip.orderID, lon, lat, toLon, toLat, now)
+	if err != nil {
+		s.logger.Errorf("eta: route for order %d failed: %v", trip.orderID, err)
+		return
+	}
+	s.passengerHub.PushOrderEvent(trip.passengerID, ws.PassengerEvent{
+		Type:     "driver_eta",
+		OrderID:  trip.orderID,
+		Status:   trip.status,
+		DriverID: driverID,
+		ETA: &ws.PassengerETA{
+			Target:     target,
+			DriverLon:  lon,
+			DriverLat:  lat,
+			DistanceM:  eta.DistanceM,
+			EtaSeconds: eta.EtaSeconds,
+			OffRoute:   offRoute,
+		},
+	})
+}
+
+// loadLiveTrip reads the driver's active order; no active order yields an empty trip.
+func (s *Server) loadLiveTrip(ctx context.Context, driverID int64, now time.Time) (liveTrip, error) {
+	orderID, err := s.ordersRepo.GetActiveOrderIDByDriver(ctx, driverID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return liveTrip{checkedAt: now}, nil
+	}
+	if err != nil {
+		return liveTrip{}, err
+	}
+	order, err := s.ordersRepo.Get(ctx, orderID)
+	if err != nil {
+		return liveTrip{}, err
+	}
+	return liveTrip{
+		orderID:     order.ID,
+		passengerID: order.PassengerID,
+		status:      order.Status,
+		fromLon:     order.FromLon,
+		fromLat:     order.FromLat,
+		toLon:       order.ToLon,
+		toLat:       order.ToLat,
+		path:        orderRoutePath(order.Addresses),
+		checkedAt:   now,
+	}, nil
+}
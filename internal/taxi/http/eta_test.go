@@ -0,0 +1,65 @@
+package taxihttp
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"naimuBack/internal/taxi/fsm"
+	"naimuBack/internal/taxi/repo"
+)
+
+func TestEndLiveTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		status string
+		driver bool
+		kept   bool
+	}{
+		{name: "completed", status: fsm.StatusCompleted, driver: true},
+		{name: "canceled by passenger", status: fsm.StatusCanceledByPassenger, driver: true},
+		{name: "no show", status: fsm.StatusNoShow, driver: true},
+		{name: "still in progress", status: fsm.StatusInProgress, driver: true, kept: true},
+		{name: "no driver", status: fsm.StatusCompleted, kept: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Server{eta: newLiveETA(nil, time.Minute)}
+			s.eta.remember(7, liveTrip{orderID: 42, status: fsm.StatusInProgress, checkedAt: time.Now()})
+			s.eta.markOffRoute(7, true)
+
+			order := repo.Order{ID: 42, Status: tc.status}
+			if tc.driver {
+				order.DriverID = sql.NullInt64{Int64: 7, Valid: true}
+			}
+			s.endLiveTrip(order)
+
+			_, trip := s.eta.trips[7]
+			_, off := s.eta.offRoute[7]
+			if trip != tc.kept || off != tc.kept {
+				t.Fatalf("expected kept=%t got trip=%t offRoute=%t", tc.kept, trip, off)
+			}
+		})
+	}
+}
+
+func TestLiveETAForget(t *testing.T) {
+	l := newLiveETA(nil, time.Minute)
+	l.remember(7, liveTrip{orderID: 42, checkedAt: time.Now()})
+	l.remember(8, liveTrip{orderID: 43, checkedAt: time.Now()})
+	l.markOffRoute(7, true)
+
+	// водитель ушёл в офлайн — его записи удаляются, чужие остаются
+	l.forget(7)
+	if _, ok := l.trips[7]; ok {
+		t.Fatalf("trip of the offline driver kept")
+	}
+	if _, ok := l.offRoute[7]; ok {
+		t.Fatalf("off-route flag of the offline driver kept")
+	}
+	if _, ok := l.trips[8]; !ok {
+		t.Fatalf("trip of another driver dropped")
+	}
+	// повторный вызов без записи не должен падать
+	l.forget(7)
+}
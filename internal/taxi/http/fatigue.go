@@ -0,0 +1,247 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/taxi/fatigue"
+	"naimuBack/internal/taxi/timeutil"
+	"naimuBack/internal/taxi/ws"
+)
+
+const (
+	onlineReportDefaultRange = 24 * time.Hour
+	onlineReportMaxRange     = 31 * 24 * time.Hour
+)
+
+type fatigueStatusResponse struct {
+	State            string     `json:"state"`
+	OnlineSeconds    int64      `json:"online_s"`
+	RemainingSeconds int64      `json:"remaining_s"`
+	MaxOnlineSeconds int64      `json:"max_online_s"`
+	RestSeconds      int64      `json:"rest_s"`
+	RestUntil        *time.Time `json:"rest_until,omitempty"`
+}
+
+type onlineReportResponse struct {
+	DriverID      int64     `json:"driver_id"`
+	OnlineSeconds int64     `json:"online_s"`
+	OnlineHours   float64   `json:"online_hours"`
+	Sessions      int       `json:"sessions"`
+	ForcedBreaks  int       `json:"forced_breaks"`
+	LastSeenAt    time.Time `json:"last_seen_at"`
+}
+
+func makeFatiguePayload(st fatigue.Status) ws.DriverFatiguePayload {
+	payload := ws.DriverFatiguePayload{
+		OnlineSeconds:    int64(st.Online / time.Second),
+		RemainingSeconds: int64(st.Remaining / time.Second),
+	}
+	if !st.RestUntil.IsZero() {
+		until := st.RestUntil
+		payload.RestUntil = &until
+	}
+	return payload
+}
+
+// RestUntil implements ws.FatigueGuard.
+func (s *Server) RestUntil(ctx context.Context, driverID int64) (time.Time, bool) {
+	if !s.fatigue.Enabled() {
+		return time.Time{}, false
+	}
+	st, err := s.fatigue.Check(ctx, driverID, timeutil.Now())
+	if err != nil {
+		s.logger.Errorf("fatigue: check driver %d failed: %v", driverID, err)
+		return time.Time{}, false
+	}
+	return st.RestUntil, st.State == fatigue.StateResting
+}
+
+// EnforceFatigue warns connected drivers about the approaching online limit, takes drivers who reached it
+// off the line and returns them once the mandatory rest is over.
+func (s *Server) EnforceFatigue(ctx context.Context) {
+	if !s.fatigue.Enabled() {
+		return
+	}
+	now := timeutil.Now()
+	resting := s.driverHub.RestingDriverIDs()
+	for driverID, until := range resting {
+		if now.Before(until) {
+			continue
+		}
+		st, err := s.fatigue.Check(ctx, driverID, now)
+		if err != nil {
+			s.logger.Errorf("fatigue: check driver %d failed: %v", driverID, err)
+			continue
+		}
+		if st.State != fatigue.StateResting {
+			s.driverHub.EndRest(driverID)
+		}
+	}
+	for _, driverID := range s.driverHub.ConnectedDriverIDs() {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, ok := resting[driverID]; ok {
+			continue
+		}
+		s.enforceDriverFatigue(ctx, driverID, now)
+	}
+}
+
+func (s *Server) enforceDriverFatigue(ctx context.Context, driverID int64, now time.Time) {
+	st, err := s.fatigue.Check(ctx, driverID, now)
+	if err != nil {
+		s.logger.Errorf("fatigue: check driver %d failed: %v", driverID, err)
+		return
+	}
+	payload := makeFatiguePayload(st)
+	switch st.State {
+	case fatigue.StateWarning:
+		if s.fatigue.MarkWarned(driverID, st.State) {
+			s.driverHub.SendFatigueWarning(driverID, payload)
+		}
+	case fatigue.StateLimit:
+		// поездку не прерываем: новые заказы диспетчер уже не предлагает, отдых начнётся после завершения
+		_, err := s.ordersRepo.GetActiveOrderIDByDriver(ctx, driverID)
+		if err == nil {
+			if s.fatigue.MarkWarned(driverID, st.State) {
+				payload.Deferred = true
+				s.driverHub.SendFatigueWarning(driverID, payload)
+			}
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Errorf("fatigue: active order of driver %d failed: %v", driverID, err)
+			return
+		}
+		rest, err := s.fatigue.StartRest(ctx, driverID, st.Online, now)
+		if err != nil {
+			s.logger.Errorf("fatigue: start rest of driver %d failed: %v", driverID, err)
+			return
+		}
+		payload.RestUntil = &rest.RestUntil
+		s.logger.Infof("fatigue: driver %d online %s → offline until %s", driverID, st.Online, rest.RestUntil.Format(time.RFC3339))
+		s.driverHub.ForceOffline(driverID, payload)
+	case fatigue.StateResting:
+		// отдых назначен, а водитель снова на линии (например, переподключился к другому процессу)
+		s.driverHub.ForceOffline(driverID, payload)
+	}
+}
+
+func (s *Server) handleDriverFatigue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	driverID, err := parseAuthID(r, "X-Driver-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing driver id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	st, err := s.fatigue.Check(ctx, driverID, timeutil.Now())
+	if err != nil {
+		s.logger.Errorf("fatigue: check driver %d failed: %v", driverID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load online time")
+		return
+	}
+	policy := s.fatigue.Policy()
+	payload := makeFatiguePayload(st)
+	writeJSON(w, http.StatusOK, fatigueStatusResponse{
+		State:            st.State,
+		OnlineSeconds:    payload.OnlineSeconds,
+		RemainingSeconds: payload.RemainingSeconds,
+		MaxOnlineSeconds: int64(policy.MaxOnline / time.Second),
+		RestSeconds:      int64(policy.Rest / time.Second),
+		RestUntil:        payload.RestUntil,
+	})
+}
+
+func (s *Server) handleAdminTaxiOnlineHours(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	limit, offset, err := parseLimitOffset(r, 50)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := timeutil.Now()
+	to := now
+	if v := strings.TrimSpace(r.URL.Query().Get("to")); v != "" {
+		t, err := parseHeatmapTime(v, true)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid to")
+			return
+		}
+		to = t
+	}
+	from := to.Add(-onlineReportDefaultRange)
+	if v := strings.TrimSpace(r.URL.Query().Get("from")); v != "" {
+		t, err := parseHeatmapTime(v, false)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid from")
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	if to.Sub(from) > onlineReportMaxRange {
+		writeError(w, http.StatusBadRequest, "range too large")
+		return
+	}
+	var driverID int64
+	if v := strings.TrimSpace(r.URL.Query().Get("driver_id")); v != "" {
+		driverID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || driverID <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid driver_id")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	items, err := s.fatigueRepo.OnlineReport(ctx, from, to, now, driverID, limit, offset)
+	if err != nil {
+		s.logger.Errorf("admin online hours report failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load online hours")
+		return
+	}
+	resp := make([]onlineReportResponse, 0, len(items))
+	for _, item := range items {
+		resp = append(resp, onlineReportResponse{
+			DriverID:      item.DriverID,
+			OnlineSeconds: item.OnlineSeconds,
+			OnlineHours:   math.Round(float64(item.OnlineSeconds)/36) / 100,
+			Sessions:      item.Sessions,
+			ForcedBreaks:  item.ForcedBreaks,
+			LastSeenAt:    item.LastSeenAt,
+		})
+	}
+	policy := s.fatigue.Policy()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"drivers":      resp,
+		"from":         from,
+		"to":           to,
+		"max_online_s": int64(policy.MaxOnline / time.Second),
+		"rest_s":       int64(policy.Rest / time.Second),
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
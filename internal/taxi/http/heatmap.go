@@ -0,0 +1,84 @@
+package taxihttp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"naimuBack/internal/taxi/timeutil"
+)
+
+const (
+	heatmapDefaultRange = time.Hour
+	heatmapMaxRange     = 31 * 24 * time.Hour
+)
+
+// parseHeatmapTime принимает RFC3339 либо дату (2006-01-02) во времени Алматы.
+// Для даты в параметре "to" граница сдвигается на конец дня.
+func parseHeatmapTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, timeutil.Location())
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
+func (s *Server) handleAdminTaxiHeatmap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.heatmap == nil {
+		writeError(w, http.StatusServiceUnavailable, "heatmap disabled")
+		return
+	}
+
+	to := timeutil.Now()
+	if v := strings.TrimSpace(r.URL.Query().Get("to")); v != "" {
+		t, err := parseHeatmapTime(v, true)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid to")
+			return
+		}
+		to = t
+	}
+	from := to.Add(-heatmapDefaultRange)
+	if v := strings.TrimSpace(r.URL.Query().Get("from")); v != "" {
+		t, err := parseHeatmapTime(v, false)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid from")
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	if to.Sub(from) > heatmapMaxRange {
+		writeError(w, http.StatusBadRequest, "range too large")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	cells, err := s.heatmap.Range(ctx, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to fetch heatmap")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":      from,
+		"to":        to,
+		"precision": s.heatmap.Precision(),
+		"cells":     cells,
+	})
+}
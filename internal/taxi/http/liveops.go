@@ -0,0 +1,217 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"naimuBack/internal/chat"
+	"naimuBack/internal/liveops"
+	"naimuBack/internal/taxi/fsm"
+	"naimuBack/internal/taxi/repo"
+	"naimuBack/internal/taxi/timeutil"
+	"naimuBack/internal/taxi/ws"
+)
+
+// liveOpsSource отдаёт такси на карту оперативного мониторинга.
+type liveOpsSource struct {
+	s *Server
+}
+
+// LiveOpsSource returns the taxi feed for the admin live operations map.
+func (s *Server) LiveOpsSource() liveops.Source {
+	return liveOpsSource{s: s}
+}
+
+func (src liveOpsSource) Service() string { return liveops.ServiceTaxi }
+
+func (src liveOpsSource) Collect(ctx context.Context, now time.Time) ([]liveops.Agent, []liveops.Order, error) {
+	s := src.s
+	agents := make([]liveops.Agent, 0)
+	for _, d := range s.driverHub.OnlineDrivers() {
+		if !d.HasPosition {
+			continue
+		}
+		agents = append(agents, liveops.Agent{
+			Service: liveops.ServiceTaxi,
+			ID:      d.ID,
+			City:    d.City,
+			Status:  d.Status,
+			Lon:     d.Position.Lon,
+			Lat:     d.Position.Lat,
+			Resting: d.Resting,
+		})
+	}
+
+	live, err := s.ordersRepo.ListLive(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	city := s.cityKey()
+	orders := make([]liveops.Order, 0, len(live))
+	for _, o := range live {
+		item := liveops.Order{
+			Service:     liveops.ServiceTaxi,
+			ID:          o.ID,
+			City:        city,
+			Status:      o.Status,
+			Phase:       taxiLivePhase(o.Status),
+			CustomerID:  o.PassengerID,
+			FromLon:     o.FromLon,
+			FromLat:     o.FromLat,
+			ToLon:       o.ToLon,
+			ToLat:       o.ToLat,
+			Price:       o.ClientPrice,
+			CreatedAt:   o.CreatedAt,
+			StatusSince: o.UpdatedAt,
+		}
+		if o.DriverID.Valid {
+			item.AgentID = o.DriverID.Int64
+		}
+		if o.RadiusM.Valid {
+			item.RadiusM = int(o.RadiusM.Int64)
+		}
+		orders = append(orders, item)
+	}
+	return agents, orders, nil
+}
+
+func taxiLivePhase(status string) string {
+	switch status {
+	case fsm.StatusSearching:
+		return liveops.PhaseSearching
+	case fsm.StatusAccepted, fsm.StatusAssigned:
+		return liveops.PhaseToPickup
+	case fsm.StatusDriverAtPickup, fsm.StatusArrived, fsm.StatusWaitingFree, fsm.StatusWaitingPaid:
+		return liveops.PhaseWaiting
+	default:
+		return liveops.PhaseInTrip
+	}
+}
+
+// manualAssignRefusal — оператор не может обойти правила подбора: нужна активная машина,
+// водитель не на обязательном отдыхе и пара пассажир–водитель не заблокирована.
+func manualAssignRefusal(hasVehicle, resting, blocked bool) error {
+	if !hasVehicle || resting || blocked {
+		return liveops.ErrAgentUnavailable
+	}
+	return nil
+}
+
+// Assign — ручное назначение водителя оператором на заказ в поиске.
+func (src liveOpsSource) Assign(ctx context.Context, orderID, driverID int64) error {
+	s := src.s
+	order, err := s.ordersRepo.Get(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return liveops.ErrOrderNotFound
+		}
+		return err
+	}
+	if order.Status != fsm.StatusSearching {
+		return liveops.ErrNotAssignable
+	}
+	driver, err := s.ensureDriverEligible(ctx, driverID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, errDriverBanned) || errors.Is(err, errDriverNotApproved) {
+			return liveops.ErrAgentUnavailable
+		}
+		return err
+	}
+	_, resting := s.RestUntil(ctx, driverID)
+	if err := manualAssignRefusal(driver.ActiveVehicleID.Valid, resting, s.pairBlocked(ctx, order.PassengerID, driverID)); err != nil {
+		return err
+	}
+
+	if err := s.ordersRepo.AssignDriverCAS(ctx, orderID, driverID, fsm.StatusSearching); err != nil {
+		if errors.Is(err, repo.ErrDriverBusy) {
+			return liveops.ErrAgentBusy
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return liveops.ErrNotAssignable
+		}
+		return err
+	}
+
+	closed, err := s.offersRepo.CloseOffers(ctx, orderID)
+	if err != nil {
+		s.logger.Errorf("liveops: close offers of order %d: %v", orderID, err)
+	}
+	if len(closed) > 0 {
+		s.driverHub.NotifyOfferClosed(orderID, closed, "assigned_by_dispatcher")
+	}
+	s.passengerHub.PushOrderEvent(order.PassengerID, ws.PassengerEvent{Type: "order_assigned", OrderID: order.ID, Status: fsm.StatusAccepted, DriverID: driverID})
+	s.driverHub.NotifyAssignedByDispatcher(driverID, orderID)
+	return nil
+}
+
+type sosRequest struct {
+	Message string   `json:"message"`
+	Lon     *float64 `json:"lon"`
+	Lat     *float64 `json:"lat"`
+}
+
+// handleOrderSOS: POST — тревожная кнопка пассажира или водителя, сигнал сразу уходит операторам.
+func (s *Server) handleOrderSOS(w http.ResponseWriter, r *http.Request, orderID int64, role string) {
+	header := "X-Passenger-ID"
+	if role == chat.RoleDriver {
+		header = "X-Driver-ID"
+	}
+	actorID, err := parseAuthID(r, header)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing "+role+" id")
+		return
+	}
+	if s.liveOps == nil {
+		writeError(w, http.StatusServiceUnavailable, "sos is unavailable")
+		return
+	}
+
+	var req sosRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	order, err := s.ordersRepo.Get(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "order not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	if !chatParticipant(order, role, actorID) {
+		writeError(w, http.StatusForbidden, "not a participant of the order")
+		return
+	}
+
+	alert := liveops.SOS{
+		Service:      liveops.ServiceTaxi,
+		OrderID:      orderID,
+		ReporterRole: role,
+		ReporterID:   actorID,
+		City:         s.cityKey(),
+		Message:      req.Message,
+		CreatedAt:    timeutil.Now(),
+	}
+	if req.Lon != nil && req.Lat != nil {
+		alert.Lon = sql.NullFloat64{Float64: *req.Lon, Valid: true}
+		alert.Lat = sql.NullFloat64{Float64: *req.Lat, Valid: true}
+	}
+	saved, err := s.liveOps.RaiseSOS(ctx, alert)
+	if err != nil {
+		s.logger.Errorf("sos: order %d %s %d: %v", orderID, role, actorID, err)
+		writeError(w, http.StatusInternalServerError, "failed to raise sos")
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"sos_id": saved.ID, "status": "received"})
+}
@@ -0,0 +1,32 @@
+package taxihttp
+
+import (
+	"errors"
+	"testing"
+
+	"naimuBack/internal/liveops"
+)
+
+func TestManualAssignRefusal(t *testing.T) {
+	cases := []struct {
+		name       string
+		hasVehicle bool
+		resting    bool
+		blocked    bool
+		want       error
+	}{
+		{"available", true, false, false, nil},
+		{"no active vehicle", false, false, false, liveops.ErrAgentUnavailable},
+		{"resting", true, true, false, liveops.ErrAgentUnavailable},
+		{"blocked pair", true, false, true, liveops.ErrAgentUnavailable},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := manualAssignRefusal(tc.hasVehicle, tc.resting, tc.blocked)
+			if !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+				t.Fatalf("expected %v got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,514 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"naimuBack/internal/taxi/fsm"
+	"naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/repo"
+	"naimuBack/internal/taxi/timeutil"
+	"naimuBack/internal/taxi/ws"
+)
+
+const maxLostItemTextLen = 500
+
+// lostItemStore is the part of repo.LostItemsRepo the lost item handlers rely on.
+type lostItemStore interface {
+	Create(ctx context.Context, c repo.LostItemCase) (int64, error)
+	Get(ctx context.Context, id int64) (repo.LostItemCase, error)
+	GetByReturnOrder(ctx context.Context, orderID int64) (repo.LostItemCase, error)
+	ListByPassenger(ctx context.Context, passengerID int64) ([]repo.LostItemCase, error)
+	ListByDriver(ctx context.Context, driverID int64) ([]repo.LostItemCase, error)
+	List(ctx context.Context, f repo.LostItemFilter) ([]repo.LostItemCase, error)
+	Reply(ctx context.Context, id, driverID int64, found bool, message string) error
+	Close(ctx context.Context, id int64, now time.Time) error
+	MarkReturned(ctx context.Context, id int64, now time.Time) error
+	ReopenReturn(ctx context.Context, id int64) error
+	CreateReturnOrder(ctx context.Context, caseID int64, order repo.Order) (int64, error)
+}
+
+type lostItemResponse struct {
+	ID            int64      `json:"id"`
+	OrderID       int64      `json:"order_id"`
+	PassengerID   int64      `json:"passenger_id"`
+	DriverID      int64      `json:"driver_id"`
+	Description   string     `json:"description"`
+	Status        string     `json:"status"`
+	DriverReply   string     `json:"driver_reply,omitempty"`
+	ReturnOrderID *int64     `json:"return_order_id,omitempty"`
+	ReturnFee     *int       `json:"return_fee,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+}
+
+func makeLostItemResponse(c repo.LostItemCase) lostItemResponse {
+	resp := lostItemResponse{
+		ID:          c.ID,
+		OrderID:     c.OrderID,
+		PassengerID: c.PassengerID,
+		DriverID:    c.DriverID,
+		Description: c.Description,
+		Status:      c.Status,
+		DriverReply: c.DriverReply.String,
+		CreatedAt:   c.CreatedAt,
+		UpdatedAt:   c.UpdatedAt,
+	}
+	if c.ReturnOrderID.Valid {
+		id := c.ReturnOrderID.Int64
+		resp.ReturnOrderID = &id
+	}
+	if c.ReturnFee.Valid {
+		fee := int(c.ReturnFee.Int64)
+		resp.ReturnFee = &fee
+	}
+	if c.ResolvedAt.Valid {
+		ts := c.ResolvedAt.Time
+		resp.ResolvedAt = &ts
+	}
+	return resp
+}
+
+func makeLostItemResponses(list []repo.LostItemCase) []lostItemResponse {
+	resp := make([]lostItemResponse, 0, len(list))
+	for _, c := range list {
+		resp = append(resp, makeLostItemResponse(c))
+	}
+	return resp
+}
+
+func lostItemClosedOrder(status string) bool {
+	switch status {
+	case fsm.StatusCompleted, fsm.StatusPaid, fsm.StatusClosed:
+		return true
+	}
+	return false
+}
+
+// handleOrderLostItem: POST — пассажир сообщает о забытой в машине вещи после завершённой поездки.
+func (s *Server) handleOrderLostItem(w http.ResponseWriter, r *http.Request, orderID int64) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	passengerID, err := parseAuthID(r, "X-Passenger-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing passenger id")
+		return
+	}
+	var req struct {
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Description == "" || utf8.RuneCountInString(req.Description) > maxLostItemTextLen {
+		writeError(w, http.StatusBadRequest, "description is required and must be at most 500 characters")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	order, err := s.ordersRepo.Get(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "order not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	s.reportLostItem(w, ctx, order, passengerID, req.Description)
+}
+
+// reportLostItem проверяет, что поездка пассажира завершена не раньше окна подачи, и заводит обращение.
+func (s *Server) reportLostItem(w http.ResponseWriter, ctx context.Context, order repo.Order, passengerID int64, description string) {
+	if order.PassengerID != passengerID {
+		writeError(w, http.StatusForbidden, "order belongs to another passenger")
+		return
+	}
+	if !order.DriverID.Valid || order.OrderType == repo.OrderTypeLostItemReturn || !lostItemClosedOrder(order.Status) || !order.CompletedAt.Valid {
+		writeError(w, http.StatusConflict, "lost item can be reported only for a finished trip")
+		return
+	}
+	// окно считаем от завершения поездки: updated_at сдвигается при оплате, оценке и любых правках заказа
+	if timeutil.Now().Sub(order.CompletedAt.Time) > s.cfg.GetLostItemWindow() {
+		writeError(w, http.StatusConflict, "lost item reporting window has expired")
+		return
+	}
+
+	c := repo.LostItemCase{OrderID: order.ID, PassengerID: passengerID, DriverID: order.DriverID.Int64, Description: description}
+	id, err := s.lostItemsRepo.Create(ctx, c)
+	if err != nil {
+		if errors.Is(err, repo.ErrLostItemExists) {
+			writeError(w, http.StatusConflict, "lost item already reported for this order")
+			return
+		}
+		s.logger.Errorf("lost item: create for order %d failed: %v", order.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to report lost item")
+		return
+	}
+	created, err := s.lostItemsRepo.Get(ctx, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load lost item")
+		return
+	}
+	s.driverHub.NotifyLostItem(created.DriverID, ws.DriverLostItemPayload{CaseID: created.ID, OrderID: created.OrderID, Status: created.Status, Description: created.Description})
+	writeJSON(w, http.StatusCreated, makeLostItemResponse(created))
+}
+
+// handlePassengerLostItems: GET — обращения пассажира о забытых вещах.
+func (s *Server) handlePassengerLostItems(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	passengerID, err := parseAuthID(r, "X-Passenger-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing passenger id")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	list, err := s.lostItemsRepo.ListByPassenger(ctx, passengerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load lost items")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"lost_items": makeLostItemResponses(list)})
+}
+
+type lostItemReturnRequest struct {
+	Address       string  `json:"address"`
+	Lon           float64 `json:"lon"`
+	Lat           float64 `json:"lat"`
+	PaymentMethod string  `json:"payment_method"`
+}
+
+// handlePassengerLostItem: POST /{id}/return — заказать возврат вещи, POST /{id}/close — закрыть обращение.
+func (s *Server) handlePassengerLostItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	passengerID, err := parseAuthID(r, "X-Passenger-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing passenger id")
+		return
+	}
+	caseID, action, ok := parseLostItemPath(r.URL.Path, "/api/v1/passenger/lost-items/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	c, err := s.lostItemsRepo.Get(ctx, caseID)
+	if err != nil || c.PassengerID != passengerID {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "lost item not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load lost item")
+		return
+	}
+
+	switch action {
+	case "return":
+		s.createLostItemReturn(w, r, ctx, c)
+	case "close":
+		if err := s.lostItemsRepo.Close(ctx, c.ID, timeutil.Now()); err != nil {
+			if errors.Is(err, repo.ErrLostItemState) {
+				writeError(w, http.StatusConflict, "lost item cannot be closed now")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to close lost item")
+			return
+		}
+		s.driverHub.NotifyLostItem(c.DriverID, ws.DriverLostItemPayload{CaseID: c.ID, OrderID: c.OrderID, Status: repo.LostItemClosed})
+		s.writeLostItem(w, ctx, c.ID)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// createLostItemReturn создаёт поездку возврата, сразу назначенную на водителя: от его текущей
+// позиции (или от точки высадки) до адреса пассажира. Вся стоимость возврата уходит водителю.
+func (s *Server) createLostItemReturn(w http.ResponseWriter, r *http.Request, ctx context.Context, c repo.LostItemCase) {
+	var req lostItemReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	req.Address = strings.TrimSpace(req.Address)
+	if req.Lon < -180 || req.Lon > 180 || req.Lat < -90 || req.Lat > 90 || (req.Lon == 0 && req.Lat == 0) {
+		writeError(w, http.StatusBadRequest, "invalid coordinates")
+		return
+	}
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = "cash"
+	}
+	if req.PaymentMethod != "cash" && req.PaymentMethod != "online" {
+		writeError(w, http.StatusBadRequest, "payment_method must be cash or online")
+		return
+	}
+	if c.Status != repo.LostItemFound {
+		writeError(w, http.StatusConflict, "driver has not confirmed the item yet")
+		return
+	}
+
+	if _, err := s.ensureDriverEligible(ctx, c.DriverID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, errDriverBanned) || errors.Is(err, errDriverNotApproved) {
+			writeError(w, http.StatusConflict, "driver not available")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "driver lookup failed")
+		return
+	}
+	if _, err := s.ordersRepo.GetActiveOrderIDByDriver(ctx, c.DriverID); err == nil {
+		writeError(w, http.StatusConflict, "driver is on another trip, try again later")
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusInternalServerError, "failed to check driver orders")
+		return
+	}
+
+	trip, err := s.ordersRepo.Get(ctx, c.OrderID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	fromLon, fromLat := trip.ToLon, trip.ToLat
+	if pos, ok := s.driverHub.Position(c.DriverID); ok {
+		fromLon, fromLat = pos.Lon, pos.Lat
+	}
+	distance, eta, err := s.geoClient.RouteMatrix(ctx, fromLon, fromLat, req.Lon, req.Lat)
+	if err != nil {
+		s.logger.Errorf("lost item %d: route failed: %v", c.ID, err)
+		distance, eta = int(geo.DistanceMeters(fromLon, fromLat, req.Lon, req.Lat)), 0
+	}
+
+	fee := s.cfg.GetLostItemReturnFee()
+	order := repo.Order{
+		PassengerID:      c.PassengerID,
+		DriverID:         sql.NullInt64{Int64: c.DriverID, Valid: true},
+		FromLon:          fromLon,
+		FromLat:          fromLat,
+		ToLon:            req.Lon,
+		ToLat:            req.Lat,
+		DistanceM:        distance,
+		EtaSeconds:       eta,
+		RecommendedPrice: fee,
+		ClientPrice:      fee,
+		PaymentMethod:    req.PaymentMethod,
+		OrderType:        repo.OrderTypeLostItemReturn,
+		Notes:            sql.NullString{String: "Возврат забытой вещи: " + c.Description, Valid: true},
+	}
+	to := repo.OrderAddress{Seq: 1, Lon: req.Lon, Lat: req.Lat}
+	if req.Address != "" {
+		to.Address = sql.NullString{String: req.Address, Valid: true}
+	}
+	order.Addresses = []repo.OrderAddress{{Seq: 0, Lon: fromLon, Lat: fromLat}, to}
+
+	returnID, err := s.lostItemsRepo.CreateReturnOrder(ctx, c.ID, order)
+	if err != nil {
+		if errors.Is(err, repo.ErrLostItemState) {
+			writeError(w, http.StatusConflict, "return already requested")
+			return
+		}
+		s.logger.Errorf("lost item %d: create return order failed: %v", c.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to create return trip")
+		return
+	}
+
+	s.driverHub.NotifyLostItem(c.DriverID, ws.DriverLostItemPayload{CaseID: c.ID, OrderID: c.OrderID, Status: repo.LostItemReturning, ReturnOrderID: returnID, ReturnFee: fee})
+	s.passengerHub.PushOrderEvent(c.PassengerID, ws.PassengerEvent{Type: "order_assigned", OrderID: returnID, Status: fsm.StatusAccepted, DriverID: c.DriverID, Price: fee})
+	s.writeLostItem(w, ctx, c.ID)
+}
+
+// handleDriverLostItems: GET — обращения по поездкам водителя.
+func (s *Server) handleDriverLostItems(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	driverID, err := parseAuthID(r, "X-Driver-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing driver id")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	list, err := s.lostItemsRepo.ListByDriver(ctx, driverID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load lost items")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"lost_items": makeLostItemResponses(list)})
+}
+
+// handleDriverLostItem: POST /{id}/reply {found, message} — ответ водителя пассажиру.
+func (s *Server) handleDriverLostItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	driverID, err := parseAuthID(r, "X-Driver-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing driver id")
+		return
+	}
+	caseID, action, ok := parseLostItemPath(r.URL.Path, "/api/v1/driver/lost-items/")
+	if !ok || action != "reply" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Found   bool   `json:"found"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if utf8.RuneCountInString(req.Message) > maxLostItemTextLen {
+		writeError(w, http.StatusBadRequest, "message must be at most 500 characters")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := s.lostItemsRepo.Reply(ctx, caseID, driverID, req.Found, req.Message); err != nil {
+		if errors.Is(err, repo.ErrLostItemState) {
+			writeError(w, http.StatusConflict, "lost item not found or already resolved")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to save reply")
+		return
+	}
+	c, err := s.lostItemsRepo.Get(ctx, caseID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load lost item")
+		return
+	}
+	s.passengerHub.PushOrderEvent(c.PassengerID, ws.PassengerEvent{Type: "lost_item_reply", OrderID: c.OrderID, Status: c.Status, Message: req.Message})
+	writeJSON(w, http.StatusOK, makeLostItemResponse(c))
+}
+
+// handleAdminTaxiLostItems: GET ?status=&driver_id=&limit=&offset= — все обращения о забытых вещах.
+func (s *Server) handleAdminTaxiLostItems(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	limit, offset, err := parseLimitOffset(r, 100)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := repo.LostItemFilter{Status: strings.TrimSpace(r.URL.Query().Get("status")), Limit: limit, Offset: offset}
+	if v := r.URL.Query().Get("driver_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid driver_id")
+			return
+		}
+		filter.DriverID = id
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	list, err := s.lostItemsRepo.List(ctx, filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load lost items")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"lost_items": makeLostItemResponses(list), "limit": limit, "offset": offset})
+}
+
+// handleAdminTaxiLostItem: POST /{id}/close — оператор закрывает обращение.
+func (s *Server) handleAdminTaxiLostItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	caseID, action, ok := parseLostItemPath(r.URL.Path, "/api/v1/admin/taxi/lost-items/")
+	if !ok || action != "close" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := s.lostItemsRepo.Close(ctx, caseID, timeutil.Now()); err != nil {
+		if errors.Is(err, repo.ErrLostItemState) {
+			writeError(w, http.StatusConflict, "lost item not found or cannot be closed now")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to close lost item")
+		return
+	}
+	s.writeLostItem(w, ctx, caseID)
+}
+
+func (s *Server) writeLostItem(w http.ResponseWriter, ctx context.Context, id int64) {
+	c, err := s.lostItemsRepo.Get(ctx, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load lost item")
+		return
+	}
+	writeJSON(w, http.StatusOK, makeLostItemResponse(c))
+}
+
+func parseLostItemPath(path, prefix string) (int64, string, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, prefix), "/"), "/")
+	if len(parts) != 2 {
+		return 0, "", false
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, "", false
+	}
+	return id, parts[1], true
+}
+
+// settleLostItemReturn закрывает обращение после поездки возврата или возвращает его
+// в found, если поездку отменили, чтобы пассажир мог заказать возврат снова.
+func (s *Server) settleLostItemReturn(ctx context.Context, order repo.Order) {
+	if s.lostItemsRepo == nil || order.OrderType != repo.OrderTypeLostItemReturn {
+		return
+	}
+	c, err := s.lostItemsRepo.GetByReturnOrder(ctx, order.ID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Errorf("lost item: load case of return order %d failed: %v", order.ID, err)
+		}
+		return
+	}
+	switch order.Status {
+	case fsm.StatusCompleted:
+		err = s.lostItemsRepo.MarkReturned(ctx, c.ID, timeutil.Now())
+	case fsm.StatusCanceled, fsm.StatusCanceledByPassenger, fsm.StatusCanceledByDriver, fsm.StatusNoShow:
+		err = s.lostItemsRepo.ReopenReturn(ctx, c.ID)
+	default:
+		return
+	}
+	if err != nil && !errors.Is(err, repo.ErrLostItemState) {
+		s.logger.Errorf("lost item %d: settle after return order %d failed: %v", c.ID, order.ID, err)
+	}
+}
@@ -0,0 +1,139 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"naimuBack/internal/taxi/dispatch"
+	"naimuBack/internal/taxi/fsm"
+	"naimuBack/internal/taxi/repo"
+	"naimuBack/internal/taxi/timeutil"
+	"naimuBack/internal/taxi/ws"
+)
+
+// fakeLostItems хранит обращения в памяти и, как LostItemsRepo, допускает одно обращение на заказ.
+type fakeLostItems struct {
+	lostItemStore
+	cases map[int64]repo.LostItemCase
+}
+
+func (f *fakeLostItems) Create(_ context.Context, c repo.LostItemCase) (int64, error) {
+	for _, existing := range f.cases {
+		if existing.OrderID == c.OrderID {
+			return 0, repo.ErrLostItemExists
+		}
+	}
+	c.ID = int64(len(f.cases) + 1)
+	c.Status = repo.LostItemOpen
+	f.cases[c.ID] = c
+	return c.ID, nil
+}
+
+func (f *fakeLostItems) Get(_ context.Context, id int64) (repo.LostItemCase, error) {
+	c, ok := f.cases[id]
+	if !ok {
+		return repo.LostItemCase{}, sql.ErrNoRows
+	}
+	return c, nil
+}
+
+func TestReportLostItem(t *testing.T) {
+	const window = 24 * time.Hour
+	now := timeutil.Now()
+	// updated_at свежий у всех заказов: его сдвигают оплата и оценка, окно от него не считается
+	finished := func(completedAgo time.Duration) repo.Order {
+		return repo.Order{
+			ID:          1,
+			PassengerID: 10,
+			DriverID:    sql.NullInt64{Int64: 20, Valid: true},
+			OrderType:   repo.OrderTypeRide,
+			Status:      fsm.StatusClosed,
+			UpdatedAt:   now,
+			CompletedAt: sql.NullTime{Time: now.Add(-completedAgo), Valid: true},
+		}
+	}
+	notCompleted := finished(time.Hour)
+	notCompleted.Status, notCompleted.CompletedAt = fsm.StatusInProgress, sql.NullTime{}
+
+	cases := []struct {
+		name        string
+		order       repo.Order
+		passengerID int64
+		status      int
+	}{
+		{name: "within window", order: finished(time.Hour), passengerID: 10, status: http.StatusCreated},
+		{name: "window expired despite fresh update", order: finished(window + time.Minute), passengerID: 10, status: http.StatusConflict},
+		{name: "another passenger", order: finished(time.Hour), passengerID: 11, status: http.StatusForbidden},
+		{name: "trip not finished", order: notCompleted, passengerID: 10, status: http.StatusConflict},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &fakeLostItems{cases: map[int64]repo.LostItemCase{}}
+			s := &Server{
+				logger:        nopLogger{},
+				cfg:           dispatch.ConfigAdapter{LostItemWindow: window},
+				lostItemsRepo: store,
+				driverHub:     ws.NewDriverHub(nil, nopLogger{}),
+			}
+			w := httptest.NewRecorder()
+			s.reportLostItem(w, context.Background(), tc.order, tc.passengerID, "чёрный зонт")
+			if w.Code != tc.status {
+				t.Fatalf("expected %d got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			created := 0
+			if tc.status == http.StatusCreated {
+				created = 1
+			}
+			if len(store.cases) != created {
+				t.Fatalf("expected %d cases got %d", created, len(store.cases))
+			}
+		})
+	}
+}
+
+func TestReportLostItemDuplicate(t *testing.T) {
+	store := &fakeLostItems{cases: map[int64]repo.LostItemCase{}}
+	s := &Server{
+		logger:        nopLogger{},
+		cfg:           dispatch.ConfigAdapter{LostItemWindow: time.Hour},
+		lostItemsRepo: store,
+		driverHub:     ws.NewDriverHub(nil, nopLogger{}),
+	}
+	order := repo.Order{
+		ID:          1,
+		PassengerID: 10,
+		DriverID:    sql.NullInt64{Int64: 20, Valid: true},
+		OrderType:   repo.OrderTypeRide,
+		Status:      fsm.StatusCompleted,
+		CompletedAt: sql.NullTime{Time: timeutil.Now().Add(-time.Minute), Valid: true},
+	}
+
+	first := httptest.NewRecorder()
+	s.reportLostItem(first, context.Background(), order, 10, "рюкзак")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected %d got %d", http.StatusCreated, first.Code)
+	}
+	second := httptest.NewRecorder()
+	s.reportLostItem(second, context.Background(), order, 10, "рюкзак")
+	if second.Code != http.StatusConflict || !strings.Contains(second.Body.String(), "already reported") {
+		t.Fatalf("expected duplicate conflict, got %d: %s", second.Code, second.Body.String())
+	}
+	if len(store.cases) != 1 {
+		t.Fatalf("expected 1 case got %d", len(store.cases))
+	}
+}
+
+func TestHandleOrderLostItemRequiresPassenger(t *testing.T) {
+	s := &Server{logger: nopLogger{}}
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/orders/1/lost-item", strings.NewReader(`{"description":"зонт"}`))
+	w := httptest.NewRecorder()
+	s.handleOrderLostItem(w, r, 1)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d got %d", http.StatusUnauthorized, w.Code)
+	}
+}
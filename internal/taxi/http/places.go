@@ -0,0 +1,337 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/repo"
+)
+
+const (
+	suggestDefaultLimit = 10
+	suggestMaxLimit     = 20
+	recentAddressLimit  = 10
+)
+
+type placeSuggestion struct {
+	Name    string  `json:"name"`
+	Address string  `json:"address"`
+	Lon     float64 `json:"lon"`
+	Lat     float64 `json:"lat"`
+	Source  string  `json:"source"`
+}
+
+type savedPlaceResponse struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	Title     string    `json:"title"`
+	Address   string    `json:"address"`
+	Lon       float64   `json:"lon"`
+	Lat       float64   `json:"lat"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type recentAddressResponse struct {
+	Address    string    `json:"address"`
+	Lon        float64   `json:"lon"`
+	Lat        float64   `json:"lat"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+type savedPlaceRequest struct {
+	Kind    string  `json:"kind"`
+	Title   string  `json:"title"`
+	Address string  `json:"address"`
+	Lon     float64 `json:"lon"`
+	Lat     float64 `json:"lat"`
+}
+
+func makeSavedPlaceResponse(p repo.SavedPlace) savedPlaceResponse {
+	return savedPlaceResponse{ID: p.ID, Kind: p.Kind, Title: p.Title, Address: p.Address, Lon: p.Lon, Lat: p.Lat, UpdatedAt: p.UpdatedAt}
+}
+
+func (req *savedPlaceRequest) validate() error {
+	req.Kind = strings.ToLower(strings.TrimSpace(req.Kind))
+	req.Title = strings.TrimSpace(req.Title)
+	req.Address = strings.TrimSpace(req.Address)
+	if req.Kind == "" {
+		req.Kind = repo.PlaceKindOther
+	}
+	switch req.Kind {
+	case repo.PlaceKindHome, repo.PlaceKindWork, repo.PlaceKindOther:
+	default:
+		return errors.New("kind must be home, work or other")
+	}
+	if req.Address == "" {
+		return errors.New("address is required")
+	}
+	if req.Lon < -180 || req.Lon > 180 || req.Lat < -90 || req.Lat > 90 || (req.Lon == 0 && req.Lat == 0) {
+		return errors.New("invalid coordinates")
+	}
+	if req.Title == "" {
+		switch req.Kind {
+		case repo.PlaceKindHome:
+			req.Title = "Дом"
+		case repo.PlaceKindWork:
+			req.Title = "Работа"
+		default:
+			req.Title = req.Address
+		}
+	}
+	return nil
+}
+
+// parseBiasPoint returns the point to bias suggestions to: explicit lon/lat, otherwise the city center.
+func parseBiasPoint(r *http.Request) (float64, float64, error) {
+	q := r.URL.Query()
+	if q.Get("lon") != "" || q.Get("lat") != "" {
+		lon, errLon := strconv.ParseFloat(q.Get("lon"), 64)
+		lat, errLat := strconv.ParseFloat(q.Get("lat"), 64)
+		if errLon != nil || errLat != nil {
+			return 0, 0, errors.New("invalid lon/lat")
+		}
+		return lon, lat, nil
+	}
+	if lon, lat, ok := geo.CityCenter(q.Get("city")); ok {
+		return lon, lat, nil
+	}
+	return 0, 0, nil
+}
+
+// handleGeoSuggest: GET ?q=&city=&lon=&lat=&limit= — популярные места города, затем подсказки 2GIS.
+func (s *Server) handleGeoSuggest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	city := strings.ToLower(strings.TrimSpace(q.Get("city")))
+	limit := suggestDefaultLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		if n > suggestMaxLimit {
+			n = suggestMaxLimit
+		}
+		limit = n
+	}
+	lon, lat, err := parseBiasPoint(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	items := make([]placeSuggestion, 0, limit)
+	seen := make(map[string]bool)
+	add := func(p placeSuggestion) {
+		key := strings.ToLower(p.Name + "|" + p.Address)
+		if seen[key] || len(items) >= limit {
+			return
+		}
+		seen[key] = true
+		items = append(items, p)
+	}
+
+	if s.placesRepo != nil && city != "" {
+		popular, err := s.placesRepo.ListPopular(ctx, city, query, limit)
+		if err != nil {
+			s.logger.Errorf("places: list popular city=%s failed: %v", city, err)
+		}
+		for _, p := range popular {
+			add(placeSuggestion{Name: p.Name, Address: p.Address, Lon: p.Lon, Lat: p.Lat, Source: "popular"})
+		}
+	}
+	if query != "" && len(items) < limit {
+		places, err := s.geocoder.Suggest(ctx, query, lon, lat, limit)
+		if err != nil {
+			s.logger.Errorf("places: suggest %q failed: %v", query, err)
+			if len(items) == 0 {
+				writeError(w, http.StatusBadGateway, "suggest failed")
+				return
+			}
+		}
+		for _, p := range places {
+			add(placeSuggestion{Name: p.Name, Address: p.Address, Lon: p.Lon, Lat: p.Lat, Source: "2gis"})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// handleGeoReverse: GET ?lon=&lat= — адрес точки на карте.
+func (s *Server) handleGeoReverse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	lon, errLon := strconv.ParseFloat(q.Get("lon"), 64)
+	lat, errLat := strconv.ParseFloat(q.Get("lat"), 64)
+	if errLon != nil || errLat != nil || lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+		writeError(w, http.StatusBadRequest, "invalid lon/lat")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	place, err := s.geocoder.ReverseGeocode(ctx, lon, lat)
+	if err != nil {
+		if errors.Is(err, geo.ErrPlaceNotFound) {
+			writeError(w, http.StatusNotFound, "address not found")
+			return
+		}
+		s.logger.Errorf("places: reverse geocode %f,%f failed: %v", lon, lat, err)
+		writeError(w, http.StatusBadGateway, "reverse geocode failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, place)
+}
+
+// handlePassengerPlaces: GET сохранённые и недавние адреса, POST сохранить место.
+func (s *Server) handlePassengerPlaces(w http.ResponseWriter, r *http.Request) {
+	passengerID, err := parseAuthID(r, "X-Passenger-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing passenger id")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		saved, err := s.placesRepo.ListPlaces(ctx, passengerID)
+		if err != nil {
+			s.logger.Errorf("places: list passenger=%d failed: %v", passengerID, err)
+			writeError(w, http.StatusInternalServerError, "failed to load places")
+			return
+		}
+		recent, err := s.placesRepo.RecentAddresses(ctx, passengerID, recentAddressLimit)
+		if err != nil {
+			s.logger.Errorf("places: recent passenger=%d failed: %v", passengerID, err)
+			writeError(w, http.StatusInternalServerError, "failed to load places")
+			return
+		}
+		savedResp := make([]savedPlaceResponse, 0, len(saved))
+		for _, p := range saved {
+			savedResp = append(savedResp, makeSavedPlaceResponse(p))
+		}
+		recentResp := make([]recentAddressResponse, 0, len(recent))
+		for _, a := range recent {
+			recentResp = append(recentResp, recentAddressResponse{Address: a.Address, Lon: a.Lon, Lat: a.Lat, LastUsedAt: a.LastUsedAt})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"saved": savedResp, "recent": recentResp})
+	case http.MethodPost:
+		var req savedPlaceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if err := req.validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		id, err := s.placesRepo.SavePlace(ctx, repo.SavedPlace{
+			PassengerID: passengerID,
+			Kind:        req.Kind,
+			Title:       req.Title,
+			Address:     req.Address,
+			Lon:         req.Lon,
+			Lat:         req.Lat,
+		})
+		if err != nil {
+			s.logger.Errorf("places: save passenger=%d failed: %v", passengerID, err)
+			writeError(w, http.StatusInternalServerError, "failed to save place")
+			return
+		}
+		place, err := s.placesRepo.GetPlace(ctx, passengerID, id)
+		if err != nil {
+			s.logger.Errorf("places: load place %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to load place")
+			return
+		}
+		writeJSON(w, http.StatusOK, makeSavedPlaceResponse(place))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePassengerPlace: PUT изменить, DELETE удалить сохранённое место.
+func (s *Server) handlePassengerPlace(w http.ResponseWriter, r *http.Request) {
+	passengerID, err := parseAuthID(r, "X-Passenger-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing passenger id")
+		return
+	}
+	id, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/passenger/places/"), "/"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodPut:
+		current, err := s.placesRepo.GetPlace(ctx, passengerID, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusNotFound, "place not found")
+				return
+			}
+			s.logger.Errorf("places: load place %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to load place")
+			return
+		}
+		var req savedPlaceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		// тип места не меняется: для дома и работы используйте POST
+		req.Kind = current.Kind
+		if err := req.validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		current.Title, current.Address, current.Lon, current.Lat = req.Title, req.Address, req.Lon, req.Lat
+		if err := s.placesRepo.UpdatePlace(ctx, current); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Errorf("places: update place %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to update place")
+			return
+		}
+		place, err := s.placesRepo.GetPlace(ctx, passengerID, id)
+		if err != nil {
+			s.logger.Errorf("places: load place %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to load place")
+			return
+		}
+		writeJSON(w, http.StatusOK, makeSavedPlaceResponse(place))
+	case http.MethodDelete:
+		if err := s.placesRepo.DeletePlace(ctx, passengerID, id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusNotFound, "place not found")
+				return
+			}
+			s.logger.Errorf("places: delete place %d failed: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "failed to delete place")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,401 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/taxi/quests"
+	"naimuBack/internal/taxi/repo"
+	"naimuBack/internal/taxi/timeutil"
+	"naimuBack/internal/taxi/ws"
+)
+
+type questResponse struct {
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Metric      string     `json:"metric"`
+	Target      int        `json:"target"`
+	MinOffers   int        `json:"min_offers,omitempty"`
+	Reward      int        `json:"reward"`
+	Cities      []string   `json:"cities"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      time.Time  `json:"ends_at"`
+	Status      string     `json:"status"`
+	SettledAt   *time.Time `json:"settled_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func makeQuestResponse(q repo.Quest) questResponse {
+	resp := questResponse{
+		ID:          q.ID,
+		Title:       q.Title,
+		Description: nstr(q.Description),
+		Metric:      q.Metric,
+		Target:      q.Target,
+		MinOffers:   q.MinOffers,
+		Reward:      q.Reward,
+		Cities:      q.Cities,
+		StartsAt:    q.StartsAt,
+		EndsAt:      q.EndsAt,
+		Status:      q.Status,
+		CreatedAt:   q.CreatedAt,
+	}
+	if resp.Cities == nil {
+		resp.Cities = []string{}
+	}
+	if q.SettledAt.Valid {
+		t := q.SettledAt.Time
+		resp.SettledAt = &t
+	}
+	return resp
+}
+
+type questEvaluationResponse struct {
+	ID           int64     `json:"id"`
+	DriverID     int64     `json:"driver_id"`
+	Metric       string    `json:"metric"`
+	Value        int       `json:"value"`
+	Target       int       `json:"target"`
+	Achieved     bool      `json:"achieved"`
+	Reward       int       `json:"reward"`
+	BalanceAfter *int64    `json:"balance_after,omitempty"`
+	EvaluatedAt  time.Time `json:"evaluated_at"`
+}
+
+func makeQuestProgress(items []quests.Progress) []ws.DriverQuestProgress {
+	out := make([]ws.DriverQuestProgress, 0, len(items))
+	for _, p := range items {
+		out = append(out, ws.DriverQuestProgress{
+			QuestID:  p.Quest.ID,
+			Title:    p.Quest.Title,
+			Metric:   p.Quest.Metric,
+			Value:    p.Value,
+			Target:   p.Quest.Target,
+			Offers:   p.Offers,
+			Reward:   p.Quest.Reward,
+			Achieved: p.Achieved,
+			EndsAt:   p.Quest.EndsAt,
+		})
+	}
+	return out
+}
+
+type questRequest struct {
+	Title       string    `json:"title"`
+	Description *string   `json:"description"`
+	Metric      string    `json:"metric"`
+	Target      int       `json:"target"`
+	MinOffers   int       `json:"min_offers"`
+	Reward      int       `json:"reward"`
+	Cities      []string  `json:"cities"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+}
+
+func (req questRequest) toQuest() (repo.Quest, error) {
+	q := repo.Quest{
+		Title:     strings.TrimSpace(req.Title),
+		Metric:    strings.TrimSpace(req.Metric),
+		Target:    req.Target,
+		MinOffers: req.MinOffers,
+		Reward:    req.Reward,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+	}
+	for _, c := range req.Cities {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+			q.Cities = append(q.Cities, c)
+		}
+	}
+	if req.Description != nil && strings.TrimSpace(*req.Description) != "" {
+		q.Description = sql.NullString{String: strings.TrimSpace(*req.Description), Valid: true}
+	}
+
+	switch {
+	case q.Title == "":
+		return q, errors.New("title is required")
+	case q.Metric != repo.QuestMetricTrips && q.Metric != repo.QuestMetricOnlineHours && q.Metric != repo.QuestMetricAcceptanceRate:
+		return q, errors.New("metric must be trips, online_hours or acceptance_rate")
+	case q.Target <= 0:
+		return q, errors.New("target must be positive")
+	case q.Metric == repo.QuestMetricAcceptanceRate && q.Target > 100:
+		return q, errors.New("acceptance rate target must be <= 100")
+	case q.MinOffers < 0:
+		return q, errors.New("min_offers must be non-negative")
+	case q.Reward <= 0:
+		return q, errors.New("reward must be positive")
+	case q.StartsAt.IsZero() || q.EndsAt.IsZero() || !q.StartsAt.Before(q.EndsAt):
+		return q, errors.New("starts_at must be before ends_at")
+	case len(strings.Join(q.Cities, ",")) > 255:
+		return q, errors.New("too many cities")
+	}
+	return q, nil
+}
+
+// DriverOnline implements ws.PresenceHandler.
+func (s *Server) DriverOnline(ctx context.Context, driverID int64, city string) error {
+	return s.questsRepo.StartOnlineSession(ctx, driverID, city, timeutil.Now())
+}
+
+// DriverOffline implements ws.PresenceHandler; a disconnected driver also has no live ETA to track.
+func (s *Server) DriverOffline(ctx context.Context, driverID int64) error {
+	if s.eta != nil {
+		s.eta.forget(driverID)
+	}
+	return s.questsRepo.EndOnlineSession(ctx, driverID, timeutil.Now())
+}
+
+// pushQuestProgress отправляет водителю актуальный прогресс по квестам (например, после завершения поездки).
+func (s *Server) pushQuestProgress(ctx context.Context, driverID int64) {
+	if s.quests == nil || driverID <= 0 {
+		return
+	}
+	items, err := s.quests.DriverProgress(ctx, driverID, timeutil.Now())
+	if err != nil {
+		s.logger.Errorf("quests: progress for driver %d failed: %v", driverID, err)
+		return
+	}
+	if len(items) == 0 {
+		return
+	}
+	s.driverHub.SendQuestProgress(driverID, makeQuestProgress(items))
+}
+
+// notifyQuestProgress вызывается в горутине после завершения поездки.
+func (s *Server) notifyQuestProgress(driverID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.pushQuestProgress(ctx, driverID)
+}
+
+// PushQuestProgress refreshes quest progress of every connected driver; online hours and acceptance rate
+// change without order events, so the worker pushes them periodically.
+func (s *Server) PushQuestProgress(ctx context.Context) {
+	for _, driverID := range s.driverHub.ConnectedDriverIDs() {
+		if ctx.Err() != nil {
+			return
+		}
+		s.pushQuestProgress(ctx, driverID)
+	}
+}
+
+// SettleQuests credits rewards of ended quests and notifies drivers about the results.
+func (s *Server) SettleQuests(ctx context.Context) {
+	if s.quests == nil {
+		return
+	}
+	evals, err := s.quests.Settle(ctx, timeutil.Now())
+	if err != nil {
+		s.logger.Errorf("quests: settle failed: %v", err)
+	}
+	for _, e := range evals {
+		payload := ws.DriverQuestResultPayload{QuestID: e.QuestID, Value: e.Value, Target: e.Target, Achieved: e.Achieved, Reward: e.Reward}
+		if e.BalanceAfter.Valid {
+			balance := e.BalanceAfter.Int64
+			payload.Balance = &balance
+		}
+		s.driverHub.SendQuestResult(e.DriverID, payload)
+	}
+}
+
+func (s *Server) handleDriverQuests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	driverID, err := parseAuthID(r, "X-Driver-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing driver id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	items, err := s.quests.DriverProgress(ctx, driverID, timeutil.Now())
+	if err != nil {
+		s.logger.Errorf("quests: progress for driver %d failed: %v", driverID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load quests")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"quests": makeQuestProgress(items)})
+}
+
+func (s *Server) handleAdminTaxiQuests(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		limit, offset, err := parseLimitOffset(r, 50)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		status := strings.TrimSpace(r.URL.Query().Get("status"))
+		if status != "" && status != repo.QuestStatusActive && status != repo.QuestStatusCanceled && status != repo.QuestStatusSettled {
+			writeError(w, http.StatusBadRequest, "invalid status")
+			return
+		}
+		items, err := s.questsRepo.ListQuests(ctx, status, limit, offset)
+		if err != nil {
+			s.logger.Errorf("admin list quests failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load quests")
+			return
+		}
+		resp := make([]questResponse, 0, len(items))
+		for _, q := range items {
+			resp = append(resp, makeQuestResponse(q))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"quests": resp, "limit": limit, "offset": offset})
+	case http.MethodPost:
+		var req questRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		q, err := req.toQuest()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !q.EndsAt.After(timeutil.Now()) {
+			writeError(w, http.StatusBadRequest, "ends_at must be in the future")
+			return
+		}
+		id, err := s.questsRepo.CreateQuest(ctx, q)
+		if err != nil {
+			s.logger.Errorf("admin create quest failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to create quest")
+			return
+		}
+		created, err := s.questsRepo.GetQuest(ctx, id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load quest")
+			return
+		}
+		writeJSON(w, http.StatusCreated, makeQuestResponse(created))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleAdminTaxiQuestRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/taxi/quests/"), "/")
+	parts := strings.Split(path, "/")
+	questID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || questID <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid quest id")
+		return
+	}
+	switch {
+	case len(parts) == 1:
+		s.handleAdminTaxiQuest(w, r, questID)
+	case len(parts) == 2 && parts[1] == "evaluations":
+		s.handleAdminTaxiQuestEvaluations(w, r, questID)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) handleAdminTaxiQuest(w http.ResponseWriter, r *http.Request, questID int64) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req questRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		q, err := req.toQuest()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		q.ID = questID
+		if err := s.questsRepo.UpdateQuest(ctx, q); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusConflict, "quest not found or already closed")
+				return
+			}
+			s.logger.Errorf("admin update quest %d failed: %v", questID, err)
+			writeError(w, http.StatusInternalServerError, "failed to update quest")
+			return
+		}
+	case http.MethodDelete:
+		if err := s.questsRepo.CancelQuest(ctx, questID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusConflict, "quest not found or already closed")
+				return
+			}
+			s.logger.Errorf("admin cancel quest %d failed: %v", questID, err)
+			writeError(w, http.StatusInternalServerError, "failed to cancel quest")
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := s.questsRepo.GetQuest(ctx, questID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "quest not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load quest")
+		return
+	}
+	writeJSON(w, http.StatusOK, makeQuestResponse(q))
+}
+
+func (s *Server) handleAdminTaxiQuestEvaluations(w http.ResponseWriter, r *http.Request, questID int64) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	limit, offset, err := parseLimitOffset(r, 100)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	items, err := s.questsRepo.ListEvaluations(ctx, questID, limit, offset)
+	if err != nil {
+		s.logger.Errorf("admin list quest %d evaluations failed: %v", questID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load evaluations")
+		return
+	}
+	resp := make([]questEvaluationResponse, 0, len(items))
+	for _, e := range items {
+		item := questEvaluationResponse{
+			ID:          e.ID,
+			DriverID:    e.DriverID,
+			Metric:      e.Metric,
+			Value:       e.Value,
+			Target:      e.Target,
+			Achieved:    e.Achieved,
+			Reward:      e.Reward,
+			EvaluatedAt: e.EvaluatedAt,
+		}
+		if e.BalanceAfter.Valid {
+			v := e.BalanceAfter.Int64
+			item.BalanceAfter = &v
+		}
+		resp = append(resp, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"evaluations": resp, "limit": limit, "offset": offset})
+}
@@ -0,0 +1,273 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/commission"
+	"naimuBack/internal/taxi/repo"
+	"naimuBack/internal/taxi/ws"
+)
+
+// Refunder returns card payments through the payment provider; requests with the same
+// idempotency key are refunded once.
+type Refunder interface {
+	Return(ctx context.Context, extID string, amount *float64, idempotencyKey string) error
+}
+
+type refundResponse struct {
+	ID               int64     `json:"id"`
+	OrderID          int64     `json:"order_id"`
+	PaymentID        int64     `json:"payment_id"`
+	Amount           int       `json:"amount"`
+	Reason           string    `json:"reason,omitempty"`
+	IdempotencyKey   string    `json:"idempotency_key"`
+	ChargeDriver     bool      `json:"charge_driver"`
+	DriverAdjustment int       `json:"driver_adjustment"`
+	Status           string    `json:"status"`
+	Error            string    `json:"error,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func makeRefundResponse(rf repo.Refund) refundResponse {
+	return refundResponse{
+		ID:               rf.ID,
+		OrderID:          rf.OrderID,
+		PaymentID:        rf.PaymentID,
+		Amount:           rf.Amount,
+		Reason:           nstr(rf.Reason),
+		IdempotencyKey:   rf.IdempotencyKey,
+		ChargeDriver:     rf.ChargeDriver,
+		DriverAdjustment: rf.DriverAdjustment,
+		Status:           rf.Status,
+		Error:            nstr(rf.Error),
+		CreatedAt:        rf.CreatedAt,
+	}
+}
+
+// refundStore is the part of repo.RefundsRepo the refund flow relies on.
+type refundStore interface {
+	Begin(ctx context.Context, refund repo.Refund) (repo.Refund, bool, error)
+	MarkProviderDone(ctx context.Context, refundID int64) error
+	Complete(ctx context.Context, refundID, driverID int64, driverAdjustment int) error
+	Fail(ctx context.Context, refundID int64, reason string) error
+	Get(ctx context.Context, id int64) (repo.Refund, error)
+	ListByOrder(ctx context.Context, orderID int64) ([]repo.Refund, error)
+}
+
+// refundSteps решает, что делать после Begin. Ожидающий возврат (новый или оборвавшийся до
+// ответа провайдера) снова отправляется провайдеру, а возврат, деньги по которому провайдер
+// уже вернул, только допроводится у нас.
+func refundSteps(rf repo.Refund) (callProvider, complete bool) {
+	switch rf.Status {
+	case repo.RefundStatusPending:
+		return true, true
+	case repo.RefundStatusProviderDone:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// refundProviderKey не меняется между повторами одного возврата, поэтому провайдер
+// не вернёт деньги дважды.
+func refundProviderKey(rf repo.Refund) string {
+	return "taxi-refund-" + strconv.FormatInt(rf.ID, 10)
+}
+
+// refundDriverAdjustment считает изменение баланса водителя: он теряет возвращённую часть тарифа,
+// но удержанная с этой части комиссия ему возвращается.
+func refundDriverAdjustment(charged, paid, refundedBefore, amount int) int {
+	if amount <= 0 || paid-refundedBefore <= 0 {
+		return 0
+	}
+	if amount > paid-refundedBefore {
+		amount = paid - refundedBefore
+	}
+	commissionBack := commission.Refunded(charged, paid, refundedBefore, amount)
+	return -(amount - commissionBack)
+}
+
+func (s *Server) handleAdminTaxiOrderRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/taxi/orders/"), "/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	orderID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid order id")
+		return
+	}
+	switch parts[1] {
+	case "refunds":
+		s.handleAdminTaxiOrderRefunds(w, r, orderID)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) handleAdminTaxiOrderRefunds(w http.ResponseWriter, r *http.Request, orderID int64) {
+	switch r.Method {
+	case http.MethodGet:
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		refunds, err := s.refundsRepo.ListByOrder(ctx, orderID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to list refunds")
+			return
+		}
+		resp := make([]refundResponse, 0, len(refunds))
+		for _, rf := range refunds {
+			resp = append(resp, makeRefundResponse(rf))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"refunds": resp})
+	case http.MethodPost:
+		s.createRefund(w, r, orderID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createRefund(w http.ResponseWriter, r *http.Request, orderID int64) {
+	var req struct {
+		Amount         int    `json:"amount"`
+		Reason         string `json:"reason"`
+		IdempotencyKey string `json:"idempotency_key"`
+		ChargeDriver   *bool  `json:"charge_driver"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if key == "" {
+		key = strings.TrimSpace(req.IdempotencyKey)
+	}
+	if key == "" || len(key) > 64 {
+		writeError(w, http.StatusBadRequest, "idempotency key required")
+		return
+	}
+	if req.Amount < 0 {
+		writeError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	if s.refunder == nil {
+		writeError(w, http.StatusServiceUnavailable, "refunds unavailable")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	order, err := s.ordersRepo.Get(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "order not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "order lookup failed")
+		return
+	}
+	if order.PaymentMethod != "online" {
+		writeError(w, http.StatusConflict, "order was not paid by card")
+		return
+	}
+
+	chargeDriver := true
+	if req.ChargeDriver != nil {
+		chargeDriver = *req.ChargeDriver
+	}
+	refund := repo.Refund{
+		OrderID:        order.ID,
+		Amount:         req.Amount,
+		IdempotencyKey: key,
+		ChargeDriver:   chargeDriver,
+	}
+	if reason := strings.TrimSpace(req.Reason); reason != "" {
+		refund.Reason = sql.NullString{String: reason, Valid: true}
+	}
+
+	refund, _, err = s.refundsRepo.Begin(ctx, refund)
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrPaymentNotRefundable):
+			writeError(w, http.StatusConflict, "no paid payment for order")
+		case errors.Is(err, repo.ErrRefundExceedsPayment):
+			writeError(w, http.StatusConflict, "refund exceeds paid amount")
+		default:
+			writeError(w, http.StatusInternalServerError, "refund failed")
+		}
+		return
+	}
+	s.finishRefund(w, ctx, order, refund)
+}
+
+// finishRefund проводит возврат после Begin. Повтор с тем же ключом продолжает с того шага,
+// на котором остановилась прошлая попытка.
+func (s *Server) finishRefund(w http.ResponseWriter, ctx context.Context, order repo.Order, refund repo.Refund) {
+	callProvider, complete := refundSteps(refund)
+	if !complete {
+		writeJSON(w, http.StatusOK, makeRefundResponse(refund))
+		return
+	}
+
+	if callProvider {
+		amount := float64(refund.Amount)
+		var amountPtr *float64
+		if refund.PaymentRefunded > 0 || refund.Amount < refund.PaymentAmount {
+			amountPtr = &amount
+		}
+		if err := s.refunder.Return(ctx, refund.ProviderTxnID, amountPtr, refundProviderKey(refund)); err != nil {
+			s.logger.Errorf("refund %d for order %d failed: %v", refund.ID, order.ID, err)
+			if ferr := s.refundsRepo.Fail(ctx, refund.ID, err.Error()); ferr != nil {
+				s.logger.Errorf("mark refund %d failed: %v", refund.ID, ferr)
+			}
+			writeError(w, http.StatusBadGateway, "payment provider refund failed")
+			return
+		}
+		if err := s.refundsRepo.MarkProviderDone(ctx, refund.ID); err != nil {
+			// повтор снова обратится к провайдеру с тем же ключом, двойного возврата не будет
+			s.logger.Errorf("mark refund %d provider done: %v", refund.ID, err)
+			writeError(w, http.StatusInternalServerError, "refund state update failed")
+			return
+		}
+	}
+
+	driverID := int64(0)
+	adjustment := 0
+	if refund.ChargeDriver && order.DriverID.Valid {
+		driverID = order.DriverID.Int64
+		adjustment = refundDriverAdjustment(recordedCommission(order), refund.PaymentAmount, refund.PaymentRefunded, refund.Amount)
+	}
+	if err := s.refundsRepo.Complete(ctx, refund.ID, driverID, adjustment); err != nil {
+		// деньги у провайдера уже возвращены — повтор с тем же ключом допроведёт возврат
+		s.logger.Errorf("complete refund %d for order %d failed: %v", refund.ID, order.ID, err)
+		writeError(w, http.StatusInternalServerError, "refund state update failed")
+		return
+	}
+
+	if s.passengerHub != nil {
+		s.passengerHub.PushOrderEvent(order.PassengerID, ws.PassengerEvent{
+			Type:    "order_refunded",
+			OrderID: order.ID,
+			Price:   refund.Amount,
+			Message: nstr(refund.Reason),
+		})
+	}
+
+	saved, err := s.refundsRepo.Get(ctx, refund.ID)
+	if err != nil {
+		s.logger.Errorf("load refund %d failed: %v", refund.ID, err)
+		saved = refund
+	}
+	writeJSON(w, http.StatusOK, makeRefundResponse(saved))
+}
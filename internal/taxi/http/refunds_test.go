@@ -0,0 +1,129 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"naimuBack/internal/taxi/repo"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+
+// fakeRefunds хранит возвраты в памяти и повторяет переходы статусов RefundsRepo.
+type fakeRefunds struct {
+	rows        map[int64]repo.Refund
+	markErr     error
+	completeErr error
+	completed   int
+}
+
+func (f *fakeRefunds) Begin(context.Context, repo.Refund) (repo.Refund, bool, error) {
+	return repo.Refund{}, false, errors.New("not used")
+}
+
+func (f *fakeRefunds) MarkProviderDone(_ context.Context, id int64) error {
+	if f.markErr != nil {
+		return f.markErr
+	}
+	if rf := f.rows[id]; rf.Status == repo.RefundStatusPending {
+		rf.Status = repo.RefundStatusProviderDone
+		f.rows[id] = rf
+	}
+	return nil
+}
+
+func (f *fakeRefunds) Complete(_ context.Context, id, _ int64, adjustment int) error {
+	if f.completeErr != nil {
+		return f.completeErr
+	}
+	rf := f.rows[id]
+	if rf.Status != repo.RefundStatusPending && rf.Status != repo.RefundStatusProviderDone {
+		return sql.ErrNoRows
+	}
+	rf.Status, rf.DriverAdjustment = repo.RefundStatusSucceeded, adjustment
+	f.rows[id] = rf
+	f.completed++
+	return nil
+}
+
+func (f *fakeRefunds) Fail(_ context.Context, id int64, reason string) error {
+	rf := f.rows[id]
+	rf.Status, rf.Error = repo.RefundStatusFailed, sql.NullString{String: reason, Valid: true}
+	f.rows[id] = rf
+	return nil
+}
+
+func (f *fakeRefunds) Get(_ context.Context, id int64) (repo.Refund, error) {
+	return f.rows[id], nil
+}
+
+func (f *fakeRefunds) ListByOrder(context.Context, int64) ([]repo.Refund, error) {
+	return nil, nil
+}
+
+type fakeRefunder struct {
+	keys []string
+}
+
+func (f *fakeRefunder) Return(_ context.Context, _ string, _ *float64, key string) error {
+	f.keys = append(f.keys, key)
+	return nil
+}
+
+func TestFinishRefundReplay(t *testing.T) {
+	store := &fakeRefunds{rows: map[int64]repo.Refund{
+		// строка осталась pending: процесс упал до ответа провайдера
+		5: {ID: 5, OrderID: 1, Amount: 2000, Status: repo.RefundStatusPending, ChargeDriver: true, PaymentAmount: 2000, ProviderTxnID: "txn"},
+	}}
+	refunder := &fakeRefunder{}
+	s := &Server{logger: nopLogger{}, refundsRepo: store, refunder: refunder}
+	order := repo.Order{ID: 1, ClientPrice: 2000}
+	replay := func() int {
+		w := httptest.NewRecorder()
+		s.finishRefund(w, context.Background(), order, store.rows[5])
+		return w.Code
+	}
+
+	// повтор ожидающего возврата снова идёт к провайдеру; сбой при отметке не теряет возврат
+	store.markErr = errors.New("db down")
+	if code := replay(); code != http.StatusInternalServerError {
+		t.Fatalf("expected %d got %d", http.StatusInternalServerError, code)
+	}
+	if store.rows[5].Status != repo.RefundStatusPending || store.completed != 0 {
+		t.Fatalf("expected refund left pending, got %+v", store.rows[5])
+	}
+
+	// провайдер вернул деньги, но завершить возврат у нас не вышло
+	store.markErr, store.completeErr = nil, errors.New("deadlock")
+	if code := replay(); code != http.StatusInternalServerError {
+		t.Fatalf("expected %d got %d", http.StatusInternalServerError, code)
+	}
+	if store.rows[5].Status != repo.RefundStatusProviderDone {
+		t.Fatalf("expected provider_done, got %s", store.rows[5].Status)
+	}
+
+	// повтор с тем же ключом допроводит возврат без нового обращения к провайдеру
+	store.completeErr = nil
+	if code := replay(); code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, code)
+	}
+	if store.rows[5].Status != repo.RefundStatusSucceeded || store.completed != 1 {
+		t.Fatalf("expected refund completed once, got %+v", store.rows[5])
+	}
+
+	// завершённый возврат отдаётся как есть
+	if code := replay(); code != http.StatusOK || store.completed != 1 {
+		t.Fatalf("expected idempotent replay, got %d completed=%d", code, store.completed)
+	}
+
+	if len(refunder.keys) != 2 || refunder.keys[0] != "taxi-refund-5" || refunder.keys[1] != refunder.keys[0] {
+		t.Fatalf("expected two provider calls with one key, got %v", refunder.keys)
+	}
+}
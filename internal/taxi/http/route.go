@@ -0,0 +1,68 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/repo"
+)
+
+// offRouteToleranceM — насколько водитель может отклониться от построенного маршрута, прежде чем ETA перестраивается.
+const offRouteToleranceM = 150.0
+
+type routeLegResponse struct {
+	FromSeq    int    `json:"from_seq"`
+	ToSeq      int    `json:"to_seq"`
+	DistanceM  int    `json:"distance_m"`
+	EtaSeconds int    `json:"eta_s"`
+	Polyline   string `json:"polyline,omitempty"`
+}
+
+// routePolylines fetches the geometry of each leg between consecutive points concurrently.
+// A failed leg keeps an empty polyline: the apps fall back to a straight line.
+func (s *Server) routePolylines(ctx context.Context, points []geo.Point) []string {
+	if len(points) < 2 {
+		return nil
+	}
+	polylines := make([]string, len(points)-1)
+	var wg sync.WaitGroup
+	for i := 0; i < len(points)-1; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			leg, err := s.geoClient.RouteGeometry(ctx, points[i].Lon, points[i].Lat, points[i+1].Lon, points[i+1].Lat)
+			if err != nil {
+				s.logger.Errorf("route: geometry of leg %d failed: %v", i, err)
+				return
+			}
+			polylines[i] = leg.Polyline()
+		}(i)
+	}
+	wg.Wait()
+	return polylines
+}
+
+// orderRoutePath joins stored leg geometries of the order into one path for off-route checks.
+func orderRoutePath(addresses []repo.OrderAddress) []geo.Point {
+	var path []geo.Point
+	for _, addr := range addresses {
+		if !addr.LegPolyline.Valid || addr.LegPolyline.String == "" {
+			continue
+		}
+		leg, err := geo.DecodePolyline(addr.LegPolyline.String)
+		if err != nil {
+			continue
+		}
+		path = append(path, leg...)
+	}
+	return path
+}
+
+func legPolyline(polylines []string, i int) sql.NullString {
+	if i < len(polylines) && polylines[i] != "" {
+		return sql.NullString{String: polylines[i], Valid: true}
+	}
+	return sql.NullString{}
+}
@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -16,11 +15,17 @@ import (
 	"strings"
 	"time"
 
+	"naimuBack/internal/chat"
+	"naimuBack/internal/commission"
+	"naimuBack/internal/liveops"
 	"naimuBack/internal/taxi/dispatch"
+	"naimuBack/internal/taxi/fatigue"
 	"naimuBack/internal/taxi/fsm"
 	"naimuBack/internal/taxi/geo"
+	"naimuBack/internal/taxi/heatmap"
 	"naimuBack/internal/taxi/pay"
 	"naimuBack/internal/taxi/pricing"
+	"naimuBack/internal/taxi/quests"
 	"naimuBack/internal/taxi/repo"
 	"naimuBack/internal/taxi/timeutil"
 	"naimuBack/internal/taxi/ws"
@@ -28,19 +33,39 @@ import (
 
 // Server handles HTTP endpoints for taxi module.
 type Server struct {
-	logger         dispatch.Logger
-	cfg            dispatch.Config
-	geoClient      *geo.DGISClient
-	driversRepo    *repo.DriversRepo
-	ordersRepo     *repo.OrdersRepo
-	passengersRepo *repo.PassengersRepo
-	intercityRepo  *repo.IntercityOrdersRepo
-	offersRepo     *repo.OffersRepo
-	paymentsRepo   *repo.PaymentsRepo
-	driverHub      *ws.DriverHub
-	passengerHub   *ws.PassengerHub
-	dispatcher     *dispatch.Dispatcher
-	payClient      *pay.Client
+	logger           dispatch.Logger
+	cfg              dispatch.Config
+	geoClient        *geo.DGISClient
+	geocoder         *geo.Geocoder
+	driversRepo      *repo.DriversRepo
+	ordersRepo       *repo.OrdersRepo
+	passengersRepo   *repo.PassengersRepo
+	intercityRepo    *repo.IntercityOrdersRepo
+	offersRepo       *repo.OffersRepo
+	paymentsRepo     *repo.PaymentsRepo
+	destinationsRepo *repo.DestinationsRepo
+	chainsRepo       *repo.ChainsRepo
+	refundsRepo      refundStore
+	blocksRepo       *repo.BlocksRepo
+	commissionRepo   *commission.Repo
+	commission       *commission.Engine
+	questsRepo       *repo.QuestsRepo
+	quests           *quests.Service
+	chatRepo         *chat.Repo
+	placesRepo       *repo.PlacesRepo
+	lostItemsRepo    lostItemStore
+	fatigueRepo      *repo.FatigueRepo
+	fatigue          *fatigue.Service
+	heatmap          *heatmap.Service
+	liveOps          *liveops.Hub
+	driverHub        *ws.DriverHub
+	passengerHub     *ws.PassengerHub
+	dispatcher       *dispatch.Dispatcher
+	payClient        *pay.Client
+	refunder         Refunder
+	eta              *liveETA
+
+	destinationDailyLimit int
 }
 
 const (
@@ -50,7 +75,6 @@ const (
 	lifecycleFinishRadiusMeters   = 300.0
 	lifecycleStationarySpeedKPH   = 5.0
 	minDriverBalanceTenge         = 1000
-	driverCommissionPercent       = 10
 )
 
 const lifecycleTelemetryFreshness = 5 * time.Minute
@@ -59,6 +83,7 @@ var (
 	errOrderStatusConflict = errors.New("order status changed")
 	errDriverBanned        = errors.New("driver banned")
 	errDriverNotApproved   = errors.New("driver not approved")
+	errDriverResting       = errors.New("driver resting")
 	errInvalidLimit        = errors.New("invalid limit")
 	errInvalidOffset       = errors.New("invalid offset")
 )
@@ -70,22 +95,78 @@ func nstr(v sql.NullString) string {
 	return ""
 }
 
+// ServerDeps groups the repositories and services used by the HTTP server.
+// Refunder and LiveOps are optional.
+type ServerDeps struct {
+	GeoClient      *geo.DGISClient
+	Geocoder       *geo.Geocoder
+	Drivers        *repo.DriversRepo
+	Orders         *repo.OrdersRepo
+	Passengers     *repo.PassengersRepo
+	Intercity      *repo.IntercityOrdersRepo
+	Offers         *repo.OffersRepo
+	Payments       *repo.PaymentsRepo
+	Destinations   *repo.DestinationsRepo
+	Chains         *repo.ChainsRepo
+	Refunds        *repo.RefundsRepo
+	Blocks         *repo.BlocksRepo
+	CommissionRepo *commission.Repo
+	Commission     *commission.Engine
+	QuestsRepo     *repo.QuestsRepo
+	Quests         *quests.Service
+	Chat           *chat.Repo
+	Places         *repo.PlacesRepo
+	LostItems      *repo.LostItemsRepo
+	FatigueRepo    *repo.FatigueRepo
+	Fatigue        *fatigue.Service
+	Heatmap        *heatmap.Service
+	LiveOps        *liveops.Hub
+	DriverHub      *ws.DriverHub
+	PassengerHub   *ws.PassengerHub
+	Dispatcher     *dispatch.Dispatcher
+	PayClient      *pay.Client
+	Refunder       Refunder
+
+	// DestinationDailyLimit caps how many times per day a driver may enable the destination filter.
+	DestinationDailyLimit int
+}
+
 // NewServer constructs Server.
-func NewServer(logger dispatch.Logger, cfg dispatch.Config, geoClient *geo.DGISClient, drivers *repo.DriversRepo, orders *repo.OrdersRepo, passengers *repo.PassengersRepo, intercity *repo.IntercityOrdersRepo, offers *repo.OffersRepo, payments *repo.PaymentsRepo, driverHub *ws.DriverHub, passengerHub *ws.PassengerHub, dispatcher *dispatch.Dispatcher, payClient *pay.Client) *Server {
+func NewServer(deps ServerDeps, logger dispatch.Logger, cfg dispatch.Config) *Server {
 	return &Server{
-		logger:         logger,
-		cfg:            cfg,
-		geoClient:      geoClient,
-		driversRepo:    drivers,
-		ordersRepo:     orders,
-		passengersRepo: passengers,
-		intercityRepo:  intercity,
-		offersRepo:     offers,
-		paymentsRepo:   payments,
-		driverHub:      driverHub,
-		passengerHub:   passengerHub,
-		dispatcher:     dispatcher,
-		payClient:      payClient,
+		logger:           logger,
+		cfg:              cfg,
+		geoClient:        deps.GeoClient,
+		geocoder:         deps.Geocoder,
+		driversRepo:      deps.Drivers,
+		ordersRepo:       deps.Orders,
+		passengersRepo:   deps.Passengers,
+		intercityRepo:    deps.Intercity,
+		offersRepo:       deps.Offers,
+		paymentsRepo:     deps.Payments,
+		destinationsRepo: deps.Destinations,
+		chainsRepo:       deps.Chains,
+		refundsRepo:      deps.Refunds,
+		blocksRepo:       deps.Blocks,
+		commissionRepo:   deps.CommissionRepo,
+		commission:       deps.Commission,
+		questsRepo:       deps.QuestsRepo,
+		quests:           deps.Quests,
+		chatRepo:         deps.Chat,
+		placesRepo:       deps.Places,
+		lostItemsRepo:    deps.LostItems,
+		fatigueRepo:      deps.FatigueRepo,
+		fatigue:          deps.Fatigue,
+		heatmap:          deps.Heatmap,
+		liveOps:          deps.LiveOps,
+		driverHub:        deps.DriverHub,
+		passengerHub:     deps.PassengerHub,
+		dispatcher:       deps.Dispatcher,
+		payClient:        deps.PayClient,
+		refunder:         deps.Refunder,
+		eta:              newLiveETA(deps.GeoClient, cfg.GetLiveETARefresh()),
+
+		destinationDailyLimit: deps.DestinationDailyLimit,
 	}
 }
 
@@ -162,30 +243,31 @@ func (p driverPayload) validate() string {
 }
 
 type driverResponse struct {
-	ID             int64     `json:"id"`
-	UserID         int64     `json:"user_id"`
-	Name           string    `json:"name"`
-	Surname        string    `json:"surname"`
-	Middlename     string    `json:"middlename,omitempty"`
-	Status         string    `json:"status"`
-	ApprovalStatus string    `json:"approval_status"`
-	IsBanned       bool      `json:"is_banned"`
-	CarModel       string    `json:"car_model,omitempty"`
-	CarColor       string    `json:"car_color,omitempty"`
-	CarNumber      string    `json:"car_number"`
-	TechPassport   string    `json:"tech_passport"`
-	CarPhotoFront  string    `json:"car_photo_front"`
-	CarPhotoBack   string    `json:"car_photo_back"`
-	CarPhotoLeft   string    `json:"car_photo_left"`
-	CarPhotoRight  string    `json:"car_photo_right"`
-	DriverPhoto    string    `json:"driver_photo"`
-	Phone          string    `json:"phone"`
-	IIN            string    `json:"iin"`
-	IDCardFront    string    `json:"id_card_front"`
-	IDCardBack     string    `json:"id_card_back"`
-	Rating         float64   `json:"rating"`
-	Balance        int       `json:"balance"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID              int64     `json:"id"`
+	UserID          int64     `json:"user_id"`
+	Name            string    `json:"name"`
+	Surname         string    `json:"surname"`
+	Middlename      string    `json:"middlename,omitempty"`
+	Status          string    `json:"status"`
+	ApprovalStatus  string    `json:"approval_status"`
+	IsBanned        bool      `json:"is_banned"`
+	CarModel        string    `json:"car_model,omitempty"`
+	CarColor        string    `json:"car_color,omitempty"`
+	CarNumber       string    `json:"car_number"`
+	TechPassport    string    `json:"tech_passport"`
+	CarPhotoFront   string    `json:"car_photo_front"`
+	CarPhotoBack    string    `json:"car_photo_back"`
+	CarPhotoLeft    string    `json:"car_photo_left"`
+	CarPhotoRight   string    `json:"car_photo_right"`
+	ActiveVehicleID *int64    `json:"active_vehicle_id,omitempty"`
+	DriverPhoto     string    `json:"driver_photo"`
+	Phone           string    `json:"phone"`
+	IIN             string    `json:"iin"`
+	IDCardFront     string    `json:"id_card_front"`
+	IDCardBack      string    `json:"id_card_back"`
+	Rating          float64   `json:"rating"`
+	Balance         int       `json:"balance"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 type driverProfileResponse struct {
@@ -246,6 +328,10 @@ func newDriverResponse(d repo.Driver) driverResponse {
 	if d.Middlename.Valid {
 		resp.Middlename = d.Middlename.String
 	}
+	if d.ActiveVehicleID.Valid {
+		vid := d.ActiveVehicleID.Int64
+		resp.ActiveVehicleID = &vid
+	}
 	return resp
 }
 
@@ -266,21 +352,48 @@ func (s *Server) ensureDriverEligible(ctx context.Context, driverID int64) (repo
 func (s *Server) getDriverForAction(w http.ResponseWriter, ctx context.Context, driverID int64) (repo.Driver, bool) {
 	driver, err := s.ensureDriverEligible(ctx, driverID)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			writeError(w, http.StatusUnauthorized, "driver not found")
-		case errors.Is(err, errDriverBanned):
-			writeError(w, http.StatusForbidden, "driver is banned")
-		case errors.Is(err, errDriverNotApproved):
-			writeError(w, http.StatusForbidden, "driver not approved")
-		default:
-			writeError(w, http.StatusInternalServerError, "driver lookup failed")
-		}
+		writeDriverActionError(w, err)
 		return repo.Driver{}, false
 	}
 	return driver, true
 }
 
+// ensureDriverCanTakeOffers — ставки и принятие заказов закрыты, пока водитель на обязательном отдыхе.
+func (s *Server) ensureDriverCanTakeOffers(ctx context.Context, driverID int64) (repo.Driver, error) {
+	driver, err := s.ensureDriverEligible(ctx, driverID)
+	if err != nil {
+		return driver, err
+	}
+	if _, resting := s.RestUntil(ctx, driverID); resting {
+		return driver, errDriverResting
+	}
+	return driver, nil
+}
+
+func (s *Server) getDriverForOffer(w http.ResponseWriter, ctx context.Context, driverID int64) (repo.Driver, bool) {
+	driver, err := s.ensureDriverCanTakeOffers(ctx, driverID)
+	if err != nil {
+		writeDriverActionError(w, err)
+		return repo.Driver{}, false
+	}
+	return driver, true
+}
+
+func writeDriverActionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusUnauthorized, "driver not found")
+	case errors.Is(err, errDriverBanned):
+		writeError(w, http.StatusForbidden, "driver is banned")
+	case errors.Is(err, errDriverNotApproved):
+		writeError(w, http.StatusForbidden, "driver not approved")
+	case errors.Is(err, errDriverResting):
+		writeError(w, http.StatusConflict, "driver resting")
+	default:
+		writeError(w, http.StatusInternalServerError, "driver lookup failed")
+	}
+}
+
 func parseLimitOffset(r *http.Request, defaultLimit int) (int, int, error) {
 	limit := defaultLimit
 	if v := r.URL.Query().Get("limit"); v != "" {
@@ -402,6 +515,9 @@ func newPassengerDriver(d repo.Driver) ws.PassengerDriver {
 	if d.CarPhotoRight != "" {
 		driver.CarPhotoRight = d.CarPhotoRight
 	}
+	if d.ActiveVehicleID.Valid {
+		driver.VehicleID = d.ActiveVehicleID.Int64
+	}
 	return driver
 }
 
@@ -411,6 +527,8 @@ type orderAddressResponse struct {
 	Lon     float64 `json:"lon"`
 	Lat     float64 `json:"lat"`
 	Address string  `json:"address,omitempty"`
+	// LegPolyline — геометрия участка до следующей точки.
+	LegPolyline string `json:"leg_polyline,omitempty"`
 }
 
 type orderResponse struct {
@@ -426,6 +544,7 @@ type orderResponse struct {
 	RecommendedPrice int                    `json:"recommended_price"`
 	ClientPrice      int                    `json:"client_price"`
 	PaymentMethod    string                 `json:"payment_method"`
+	OrderType        string                 `json:"order_type,omitempty"`
 	Status           string                 `json:"status"`
 	Notes            string                 `json:"notes,omitempty"`
 	CreatedAt        time.Time              `json:"created_at"`
@@ -445,6 +564,8 @@ type activeOrderRoutePoint struct {
 	Intercom *string `json:"intercom"`
 	Phone    *string `json:"phone"`
 	Comment  *string `json:"comment"`
+	// LegPolyline — геометрия участка до следующей точки.
+	LegPolyline string `json:"leg_polyline,omitempty"`
 }
 
 type activeOrderPassenger struct {
@@ -528,6 +649,7 @@ func newOrderResponse(o repo.Order, driver *repo.Driver, passenger *repo.Passeng
 		RecommendedPrice: o.RecommendedPrice,
 		ClientPrice:      o.ClientPrice,
 		PaymentMethod:    o.PaymentMethod,
+		OrderType:        o.OrderType,
 		Status:           o.Status,
 		CreatedAt:        o.CreatedAt,
 		UpdatedAt:        o.UpdatedAt,
@@ -547,6 +669,9 @@ func newOrderResponse(o repo.Order, driver *repo.Driver, passenger *repo.Passeng
 			if addr.Address.Valid {
 				addrResp.Address = addr.Address.String
 			}
+			if addr.LegPolyline.Valid {
+				addrResp.LegPolyline = addr.LegPolyline.String
+			}
 			resp.Addresses = append(resp.Addresses, addrResp)
 		}
 	}
@@ -675,9 +800,10 @@ func (s *Server) buildActiveOrderResponse(ctx context.Context, order repo.Order,
 	routePoints := make([]activeOrderRoutePoint, 0, len(order.Addresses))
 	for _, addr := range order.Addresses {
 		rp := activeOrderRoutePoint{
-			Address: addr.Address.String,
-			Lat:     addr.Lat,
-			Lon:     addr.Lon,
+			Address:     addr.Address.String,
+			Lat:         addr.Lat,
+			Lon:         addr.Lon,
+			LegPolyline: addr.LegPolyline.String,
 		}
 		routePoints = append(routePoints, rp)
 	}
@@ -987,18 +1113,43 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/v1/admin/taxi/drivers/", s.handleAdminTaxiDriver)
 	mux.HandleFunc("/api/v1/admin/taxi/orders", s.handleAdminTaxiOrders)
 	mux.HandleFunc("/api/v1/admin/taxi/intercity/orders", s.handleAdminTaxiIntercityOrders)
+	mux.HandleFunc("/api/v1/admin/taxi/orders/", s.handleAdminTaxiOrderRoutes)
+	mux.HandleFunc("/api/v1/admin/taxi/heatmap", s.handleAdminTaxiHeatmap)
+	mux.HandleFunc("/api/v1/admin/taxi/blocks", s.handleAdminTaxiBlocks)
+	mux.HandleFunc("/api/v1/admin/taxi/quests", s.handleAdminTaxiQuests)
+	mux.HandleFunc("/api/v1/admin/taxi/quests/", s.handleAdminTaxiQuestRoutes)
+	mux.HandleFunc("/api/v1/admin/taxi/drivers/online-hours", s.handleAdminTaxiOnlineHours)
+	mux.HandleFunc("/api/v1/admin/taxi/lost-items", s.handleAdminTaxiLostItems)
+	mux.HandleFunc("/api/v1/admin/taxi/lost-items/", s.handleAdminTaxiLostItem)
 
 	mux.HandleFunc("/api/v1/drivers", s.handleDrivers)
 	mux.HandleFunc("/api/v1/drivers/", s.handleDriver)
 	mux.HandleFunc("/api/v1/driver/balance/deposit", s.handleDriverBalanceDeposit)
 	mux.HandleFunc("/api/v1/driver/balance/withdraw", s.handleDriverBalanceWithdraw)
 	mux.HandleFunc("/api/v1/driver/", s.handleDriverInfoRoutes)
+	mux.HandleFunc("/api/v1/driver/destination", s.handleDriverDestination)
+	mux.HandleFunc("/api/v1/driver/blocks", s.handleDriverBlocks)
+	mux.HandleFunc("/api/v1/driver/commission/passes", s.handleDriverCommissionPasses)
+	mux.HandleFunc("/api/v1/driver/quests", s.handleDriverQuests)
+	mux.HandleFunc("/api/v1/driver/fatigue", s.handleDriverFatigue)
+	mux.HandleFunc("/api/v1/driver/vehicles", s.handleDriverVehicles)
+	mux.HandleFunc("/api/v1/driver/vehicles/", s.handleDriverVehicle)
+	mux.HandleFunc("/api/v1/driver/lost-items", s.handleDriverLostItems)
+	mux.HandleFunc("/api/v1/driver/lost-items/", s.handleDriverLostItem)
+	mux.HandleFunc("/api/v1/passenger/blocks", s.handlePassengerBlocks)
+	mux.HandleFunc("/api/v1/passenger/places", s.handlePassengerPlaces)
+	mux.HandleFunc("/api/v1/passenger/places/", s.handlePassengerPlace)
+	mux.HandleFunc("/api/v1/passenger/lost-items", s.handlePassengerLostItems)
+	mux.HandleFunc("/api/v1/passenger/lost-items/", s.handlePassengerLostItem)
+	mux.HandleFunc("/api/v1/geo/suggest", s.handleGeoSuggest)
+	mux.HandleFunc("/api/v1/geo/reverse", s.handleGeoReverse)
 
 	mux.HandleFunc("/api/v1/route/quote", s.handleRouteQuote)
 	mux.HandleFunc("/api/v1/orders", s.handleOrders)
 	mux.HandleFunc("/api/v1/orders/active", s.handlePassengerActiveOrder)
 	mux.HandleFunc("/api/v1/driver/orders", s.handleDriverOrders)
 	mux.HandleFunc("/api/v1/driver/orders/active", s.handleDriverActiveOrder)
+	mux.HandleFunc("/api/v1/driver/orders/", s.handleDriverOrderSubroutes)
 	mux.HandleFunc("/api/v1/orders/", s.handleOrderSubroutes)
 
 	mux.HandleFunc("/api/v1/intercity/orders", s.handleIntercityOrders)
@@ -1174,12 +1325,17 @@ func (s *Server) handleAdminTaxiDriver(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusInternalServerError, "update driver failed")
 			return
 		}
+		if status == "approved" {
+			s.approvePendingVehicles(ctx, id)
+		}
 		driver, err := s.driversRepo.Get(ctx, id)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "fetch driver failed")
 			return
 		}
 		writeJSON(w, http.StatusOK, newDriverResponse(driver))
+	case "vehicles":
+		s.handleAdminDriverVehicles(w, r, id, parts[2:])
 	default:
 		w.WriteHeader(http.StatusNotFound)
 	}
@@ -1546,8 +1702,7 @@ func (s *Server) getDriverStats(w http.ResponseWriter, r *http.Request, id int64
 
 		orderResp := newOrderResponse(order, &driver, passenger)
 
-		commission := calculateCommission(order.ClientPrice)
-		netProfit := order.ClientPrice - commission
+		netProfit := order.ClientPrice - recordedCommission(order)
 		stats.TotalOrders++
 		stats.TotalAmount += order.ClientPrice
 		stats.NetProfit += netProfit
@@ -1779,7 +1934,7 @@ func (s *Server) handleLifecycleArrive(w http.ResponseWriter, r *http.Request, o
 		writeError(w, http.StatusForbidden, "access denied")
 		return
 	}
-	distance := distanceMeters(order.FromLon, order.FromLat, payload.Position.Lon, payload.Position.Lat)
+	distance := geo.DistanceMeters(order.FromLon, order.FromLat, payload.Position.Lon, payload.Position.Lat)
 	if distance > lifecycleArrivalRadiusMeters {
 		writeError(w, http.StatusBadRequest, "driver outside pickup radius")
 		return
@@ -1929,7 +2084,7 @@ func (s *Server) handleLifecycleStart(w http.ResponseWriter, r *http.Request, or
 		return
 	}
 
-	distance := distanceMeters(order.FromLon, order.FromLat, payload.Position.Lon, payload.Position.Lat)
+	distance := geo.DistanceMeters(order.FromLon, order.FromLat, payload.Position.Lon, payload.Position.Lat)
 	if distance > lifecycleStartRadiusMeters {
 		writeError(w, http.StatusBadRequest, "driver outside start radius")
 		return
@@ -2102,7 +2257,7 @@ func (s *Server) handleLifecycleFinish(w http.ResponseWriter, r *http.Request, o
 		return
 	}
 
-	distance := distanceMeters(order.ToLon, order.ToLat, payload.Position.Lon, payload.Position.Lat)
+	distance := geo.DistanceMeters(order.ToLon, order.ToLat, payload.Position.Lon, payload.Position.Lat)
 	if distance > lifecycleFinishRadiusMeters {
 		writeError(w, http.StatusBadRequest, "driver outside finish radius")
 		return
@@ -2127,15 +2282,15 @@ func (s *Server) handleLifecycleFinish(w http.ResponseWriter, r *http.Request, o
 		return
 	}
 
-	// Рассчёт комиссии
-	commission := calculateCommission(order.ClientPrice)
+	// Рассчёт комиссии по правилам
+	charge := s.completionCommission(ctx, driverID, order)
 	updateErr := s.ordersRepo.UpdateStatusWithDriverCharge(
 		ctx,
 		orderID,
 		order.Status, // old
 		newStatus,    // new
 		driverID,
-		commission,
+		charge,
 	)
 
 	if updateErr != nil {
@@ -2152,6 +2307,11 @@ func (s *Server) handleLifecycleFinish(w http.ResponseWriter, r *http.Request, o
 
 	// Уведомляем пассажира
 	s.notifyPassengerStatus(order.PassengerID, order.ID, order.Status)
+	s.settleChains(ctx, order)
+	s.settleLostItemReturn(ctx, order)
+	s.closeOrderChat(order)
+	s.endLiveTrip(order)
+	go s.notifyQuestProgress(driverID)
 
 	// Если онлайн-оплата — создаём платёж (как в handleStatus)
 	if order.PaymentMethod == "online" && s.payClient != nil {
@@ -2206,6 +2366,11 @@ func (s *Server) handleLifecycleConfirmCash(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	s.notifyPassengerStatus(order.PassengerID, order.ID, order.Status)
+	s.settleChains(ctx, order)
+	s.settleLostItemReturn(ctx, order)
+	s.closeOrderChat(order)
+	s.endLiveTrip(order)
+	go s.notifyQuestProgress(driverID)
 	writeJSON(w, http.StatusOK, map[string]string{"status": order.Status})
 }
 
@@ -2266,6 +2431,10 @@ func (s *Server) handleLifecycleCancel(w http.ResponseWriter, r *http.Request, o
 			return
 		}
 		s.notifyPassengerStatus(order.PassengerID, order.ID, order.Status)
+		s.settleChains(ctx, order)
+		s.settleLostItemReturn(ctx, order)
+		s.closeOrderChat(order)
+		s.endLiveTrip(order)
 		writeJSON(w, http.StatusOK, map[string]string{"status": order.Status})
 	default:
 		writeError(w, http.StatusBadRequest, "invalid cancel initiator")
@@ -2325,7 +2494,7 @@ func (s *Server) handleLifecycleNoShow(w http.ResponseWriter, r *http.Request, o
 		writeError(w, http.StatusConflict, "order not in waiting state")
 		return
 	}
-	distance := distanceMeters(order.FromLon, order.FromLat, payload.Position.Lon, payload.Position.Lat)
+	distance := geo.DistanceMeters(order.FromLon, order.FromLat, payload.Position.Lon, payload.Position.Lat)
 	if distance > lifecycleArrivalRadiusMeters {
 		writeError(w, http.StatusBadRequest, "driver outside pickup radius")
 		return
@@ -2340,6 +2509,10 @@ func (s *Server) handleLifecycleNoShow(w http.ResponseWriter, r *http.Request, o
 		return
 	}
 	s.notifyPassengerStatus(order.PassengerID, order.ID, order.Status)
+	s.settleChains(ctx, order)
+	s.settleLostItemReturn(ctx, order)
+	s.closeOrderChat(order)
+	s.endLiveTrip(order)
 	writeJSON(w, http.StatusOK, map[string]string{"status": order.Status})
 }
 
@@ -2446,8 +2619,10 @@ func (s *Server) createDriver(w http.ResponseWriter, r *http.Request) {
 		IDCardBack:    payload.IDCardBack,
 	}
 
-	id, err := s.driversRepo.Create(ctx, driver)
+	// машина из анкеты становится первым авто водителя и проходит модерацию вместе с ним
+	id, err := s.driversRepo.CreateWithVehicle(ctx, driver, vehicleFromDriver(0, driver))
 	if err != nil {
+		s.logger.Errorf("create driver for user %d failed: %v", payload.UserID, err)
 		writeError(w, http.StatusInternalServerError, "create driver failed")
 		return
 	}
@@ -2630,13 +2805,6 @@ func roundDownToStep(n, step int) int {
 	return (n / step) * step
 }
 
-func calculateCommission(amount int) int {
-	if amount <= 0 || driverCommissionPercent <= 0 {
-		return 0
-	}
-	return (amount*driverCommissionPercent + 99) / 100
-}
-
 func (s *Server) handleRouteQuote(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -2695,7 +2863,7 @@ func (s *Server) handleRouteQuote(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if addr != "" {
-			lon, lat, err := s.geoClient.Geocode(ctx, addr)
+			lon, lat, err := s.geocoder.Geocode(ctx, addr)
 			if err != nil {
 				return resolvedPoint{}, err
 			}
@@ -2747,6 +2915,7 @@ func (s *Server) handleRouteQuote(w http.ResponseWriter, r *http.Request) {
 
 	totalDistance := 0
 	totalEta := 0
+	legs := make([]routeLegResponse, 0, len(points)-1)
 	for i := 0; i < len(points)-1; i++ {
 		distance, eta, err := s.geoClient.RouteMatrix(ctx, points[i].lon, points[i].lat, points[i+1].lon, points[i+1].lat)
 		if err != nil {
@@ -2756,6 +2925,14 @@ func (s *Server) handleRouteQuote(w http.ResponseWriter, r *http.Request) {
 		}
 		totalDistance += distance
 		totalEta += eta
+		legs = append(legs, routeLegResponse{FromSeq: i, ToSeq: i + 1, DistanceM: distance, EtaSeconds: eta})
+	}
+	geoPoints := make([]geo.Point, 0, len(points))
+	for _, p := range points {
+		geoPoints = append(geoPoints, geo.Point{Lon: p.lon, Lat: p.lat})
+	}
+	for i, polyline := range s.routePolylines(ctx, geoPoints) {
+		legs[i].Polyline = polyline
 	}
 
 	rec := pricing.Recommended(totalDistance, s.cfg.GetPricePerKM(), s.cfg.GetMinPrice())
@@ -2783,6 +2960,7 @@ func (s *Server) handleRouteQuote(w http.ResponseWriter, r *http.Request) {
 		"eta_s":             totalEta,
 		"recommended_price": rec,
 		"min_price":         s.cfg.GetMinPrice(),
+		"legs":              legs,
 	}
 	if len(points) > 2 {
 		stops := make([]map[string]interface{}, 0, len(points)-2)
@@ -2835,6 +3013,9 @@ func (s *Server) handlePassengerActiveOrder(w http.ResponseWriter, r *http.Reque
 		writeError(w, http.StatusInternalServerError, "build active order response failed")
 		return
 	}
+	if s.cfg.GetChatHidePhones() && resp.Driver != nil {
+		resp.Driver.Phone = ""
+	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
@@ -2884,6 +3065,9 @@ func (s *Server) handleDriverActiveOrder(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusInternalServerError, "build active order response failed")
 		return
 	}
+	if s.cfg.GetChatHidePhones() && resp.Passenger != nil {
+		resp.Passenger.Phone = ""
+	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
@@ -2943,7 +3127,7 @@ func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
-		resp = append(resp, newOrderResponse(order, driver, passenger))
+		resp = append(resp, s.participantOrderResponse(order, driver, passenger))
 	}
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{"orders": resp, "limit": limit, "offset": offset})
@@ -3001,7 +3185,7 @@ func (s *Server) handleListDriverOrders(w http.ResponseWriter, r *http.Request)
 				passenger = &p
 			}
 		}
-		resp = append(resp, newOrderResponse(order, driver, passenger))
+		resp = append(resp, s.participantOrderResponse(order, driver, passenger))
 	}
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{"orders": resp, "limit": limit, "offset": offset})
@@ -3117,9 +3301,15 @@ func (s *Server) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
 		order.Notes = sql.NullString{String: req.Notes, Valid: true}
 	}
 
+	geoPoints := make([]geo.Point, 0, len(points))
+	for _, point := range points {
+		geoPoints = append(geoPoints, geo.Point{Lon: point.lon, Lat: point.lat})
+	}
+	polylines := s.routePolylines(ctx, geoPoints)
+
 	addresses := make([]repo.OrderAddress, 0, len(points))
 	for idx, point := range points {
-		addr := repo.OrderAddress{Seq: idx, Lon: point.lon, Lat: point.lat}
+		addr := repo.OrderAddress{Seq: idx, Lon: point.lon, Lat: point.lat, LegPolyline: legPolyline(polylines, idx)}
 		if point.address != "" {
 			addr.Address = sql.NullString{String: point.address, Valid: true}
 		}
@@ -3193,6 +3383,16 @@ func (s *Server) handleOrderSubroutes(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		s.handleOrderReview(w, r, id)
+	case "chat":
+		s.handleOrderChat(w, r, id, chat.RolePassenger)
+	case "sos":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleOrderSOS(w, r, id, chat.RolePassenger)
+	case "lost-item":
+		s.handleOrderLostItem(w, r, id)
 	default:
 		w.WriteHeader(http.StatusNotFound)
 	}
@@ -3225,7 +3425,7 @@ func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request, orderID
 	if p, err := s.passengersRepo.Get(ctx, order.PassengerID); err == nil {
 		passenger = &p
 	}
-	writeJSON(w, http.StatusOK, newOrderResponse(order, driver, passenger))
+	writeJSON(w, http.StatusOK, s.participantOrderResponse(order, driver, passenger))
 }
 
 func (s *Server) handleReprice(w http.ResponseWriter, r *http.Request, orderID int64) {
@@ -3434,14 +3634,16 @@ func (s *Server) createIntercityOrder(w http.ResponseWriter, r *http.Request) {
 		order.Comment = sql.NullString{String: payload.Comment, Valid: true}
 	}
 
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
 	commission := 0
 	if payload.DriverID > 0 {
-		commission = calculateCommission(order.Price)
+		target := s.commissionTarget(ctx, payload.DriverID)
+		target.TariffClass = intercityTariffClass
+		commission = s.driverCommission(ctx, payload.DriverID, order.Price, target).Amount
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
 	if commission > 0 {
 		if _, err := s.driversRepo.Withdraw(ctx, payload.DriverID, commission); err != nil {
 			switch {
@@ -3604,11 +3806,14 @@ func (s *Server) handleOfferPrice(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	driver, ok := s.getDriverForAction(w, ctx, driverID)
+	driver, ok := s.getDriverForOffer(w, ctx, driverID)
 
 	if !ok {
 		return
 	}
+	if !requireActiveVehicle(w, driver) {
+		return
+	}
 	if driver.Balance < minDriverBalanceTenge {
 		writeError(w, http.StatusForbidden, "insufficient driver balance")
 		return
@@ -3637,6 +3842,10 @@ func (s *Server) handleOfferPrice(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusConflict, "order not searching")
 		return
 	}
+	if s.pairBlocked(ctx, order.PassengerID, driverID) {
+		writeError(w, http.StatusForbidden, "passenger blocked")
+		return
+	}
 
 	if err := s.offersRepo.SetDriverPrice(ctx, req.OrderID, driverID, req.Price); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -3646,7 +3855,7 @@ func (s *Server) handleOfferPrice(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "set price failed")
 		return
 	}
-	driverInfo := newPassengerDriver(driver)
+	driverInfo := s.passengerDriverCard(driver)
 	ev := ws.PassengerEvent{
 		Type:     "offer_price",
 		OrderID:  req.OrderID,
@@ -3715,18 +3924,32 @@ func (s *Server) handleOfferResponse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = s.ensureDriverEligible(ctx, req.DriverID)
+	offerDriver, err := s.ensureDriverEligible(ctx, req.DriverID)
+	if err == nil && !offerDriver.ActiveVehicleID.Valid {
+		err = errDriverNoVehicle
+	}
+	if err == nil && decision == "accept" {
+		if _, resting := s.RestUntil(ctx, req.DriverID); resting {
+			err = errDriverResting
+		}
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			writeError(w, http.StatusNotFound, "driver not found")
-		case errors.Is(err, errDriverBanned), errors.Is(err, errDriverNotApproved):
+		case errors.Is(err, errDriverResting):
+			writeError(w, http.StatusConflict, "driver resting")
+		case errors.Is(err, errDriverBanned), errors.Is(err, errDriverNotApproved), errors.Is(err, errDriverNoVehicle):
 			writeError(w, http.StatusConflict, "driver not available")
 		default:
 			writeError(w, http.StatusInternalServerError, "driver lookup failed")
 		}
 		return
 	}
+	if decision == "accept" && s.pairBlocked(ctx, passengerID, req.DriverID) {
+		writeError(w, http.StatusForbidden, "driver blocked")
+		return
+	}
 
 	switch decision {
 	case "accept":
@@ -3804,10 +4027,13 @@ func (s *Server) handleOfferAccept(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	_, ok := s.getDriverForAction(w, ctx, driverID)
+	driver, ok := s.getDriverForOffer(w, ctx, driverID)
 	if !ok {
 		return
 	}
+	if !requireActiveVehicle(w, driver) {
+		return
+	}
 
 	order, err := s.ordersRepo.Get(ctx, req.OrderID)
 	if err != nil {
@@ -3818,6 +4044,23 @@ func (s *Server) handleOfferAccept(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "order lookup failed")
 		return
 	}
+	if s.pairBlocked(ctx, order.PassengerID, driverID) {
+		writeError(w, http.StatusForbidden, "passenger blocked")
+		return
+	}
+
+	// Водитель ещё везёт предыдущего пассажира — заказ ставится в цепочку
+	if s.chainsRepo != nil {
+		activeOrderID, err := s.ordersRepo.GetActiveOrderIDByDriver(ctx, driverID)
+		if err == nil && activeOrderID != req.OrderID {
+			s.acceptChainedOffer(w, ctx, order, driver, activeOrderID)
+			return
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusInternalServerError, "active order lookup failed")
+			return
+		}
+	}
 
 	closedDrivers, pricePtr, err := s.offersRepo.AcceptOffer(ctx, req.OrderID, driverID)
 	if err != nil {
@@ -3891,7 +4134,7 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request, orderID in
 			writeError(w, http.StatusBadRequest, "location required to complete")
 			return
 		}
-		if distanceMeters(order.ToLon, order.ToLat, *req.Lon, *req.Lat) > 300 {
+		if geo.DistanceMeters(order.ToLon, order.ToLat, *req.Lon, *req.Lat) > 300 {
 			writeError(w, http.StatusBadRequest, "driver location mismatch")
 			return
 		}
@@ -3899,12 +4142,12 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request, orderID in
 
 	var updateErr error
 	if req.Status == fsm.StatusCompleted {
-		commission := calculateCommission(order.ClientPrice)
 		driverID := int64(0)
 		if order.DriverID.Valid {
 			driverID = order.DriverID.Int64
 		}
-		updateErr = s.ordersRepo.UpdateStatusWithDriverCharge(ctx, orderID, order.Status, req.Status, driverID, commission)
+		charge := s.completionCommission(ctx, driverID, order)
+		updateErr = s.ordersRepo.UpdateStatusWithDriverCharge(ctx, orderID, order.Status, req.Status, driverID, charge)
 	} else {
 		updateErr = s.ordersRepo.UpdateStatusCAS(ctx, orderID, order.Status, req.Status)
 	}
@@ -3918,6 +4161,14 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request, orderID in
 	}
 
 	s.passengerHub.PushOrderEvent(order.PassengerID, ws.PassengerEvent{Type: "order_status", OrderID: order.ID, Status: req.Status})
+	order.Status = req.Status
+	s.settleChains(ctx, order)
+	s.settleLostItemReturn(ctx, order)
+	s.closeOrderChat(order)
+	s.endLiveTrip(order)
+	if req.Status == fsm.StatusCompleted && order.DriverID.Valid {
+		go s.notifyQuestProgress(order.DriverID.Int64)
+	}
 
 	if req.Status == "completed" && order.PaymentMethod == "online" && s.payClient != nil {
 		go s.createPayment(orderID, order.ClientPrice)
@@ -3994,6 +4245,7 @@ func (s *Server) handleOrderReview(w http.ResponseWriter, r *http.Request, order
 			}
 			return
 		}
+		s.autoBlockOnRating(ctx, orderID, repo.BlockInitiatorPassenger, ratingValue)
 	case driverIDHeader != "":
 		driverID, err := strconv.ParseInt(driverIDHeader, 10, 64)
 		if err != nil {
@@ -4021,6 +4273,7 @@ func (s *Server) handleOrderReview(w http.ResponseWriter, r *http.Request, order
 			}
 			return
 		}
+		s.autoBlockOnRating(ctx, orderID, repo.BlockInitiatorDriver, ratingValue)
 	default:
 		writeError(w, http.StatusUnauthorized, "missing actor id")
 		return
@@ -4125,6 +4378,12 @@ func (s *Server) handlePassengerCancel(ctx context.Context, w http.ResponseWrite
 		}
 	}
 
+	order.Status = targetStatus
+	s.settleChains(ctx, order)
+	s.settleLostItemReturn(ctx, order)
+	s.closeOrderChat(order)
+	s.endLiveTrip(order)
+
 	writeJSON(w, http.StatusOK, map[string]string{"status": targetStatus})
 }
 
@@ -4135,18 +4394,6 @@ func (s *Server) pushPassengerError(passengerID, orderID int64, message string)
 	s.passengerHub.PushOrderEvent(passengerID, ws.PassengerEvent{Type: "error", OrderID: orderID, Message: message})
 }
 
-func distanceMeters(lon1, lat1, lon2, lat2 float64) float64 {
-	const earthRadius = 6371000.0
-	dLat := (lat2 - lat1) * math.Pi / 180
-	dLon := (lon2 - lon1) * math.Pi / 180
-	lat1Rad := lat1 * math.Pi / 180
-	lat2Rad := lat2 * math.Pi / 180
-
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1Rad)*math.Cos(lat2Rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	return earthRadius * c
-}
-
 func (s *Server) createPayment(orderID int64, amount int) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,44 @@
+package taxihttp
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteDriverActionError(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		msg    string
+	}{
+		{"not found", sql.ErrNoRows, http.StatusUnauthorized, "driver not found"},
+		{"banned", errDriverBanned, http.StatusForbidden, "driver is banned"},
+		{"not approved", errDriverNotApproved, http.StatusForbidden, "driver not approved"},
+		{"resting", errDriverResting, http.StatusConflict, "driver resting"},
+		{"wrapped resting", fmt.Errorf("offer: %w", errDriverResting), http.StatusConflict, "driver resting"},
+		{"other", errors.New("boom"), http.StatusInternalServerError, "driver lookup failed"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeDriverActionError(rec, tc.err)
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d got %d", tc.status, rec.Code)
+			}
+			var body map[string]string
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if body["error"] != tc.msg {
+				t.Fatalf("expected %q got %q", tc.msg, body["error"])
+			}
+		})
+	}
+}
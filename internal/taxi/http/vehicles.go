@@ -0,0 +1,375 @@
+package taxihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"naimuBack/internal/commission"
+	"naimuBack/internal/taxi/repo"
+)
+
+const maxTariffClassLen = 32
+
+var errDriverNoVehicle = errors.New("no approved active vehicle")
+
+type vehicleResponse struct {
+	ID             int64     `json:"id"`
+	DriverID       int64     `json:"driver_id"`
+	CarModel       string    `json:"car_model,omitempty"`
+	CarColor       string    `json:"car_color,omitempty"`
+	CarNumber      string    `json:"car_number"`
+	TechPassport   string    `json:"tech_passport"`
+	CarPhotoFront  string    `json:"car_photo_front"`
+	CarPhotoBack   string    `json:"car_photo_back"`
+	CarPhotoLeft   string    `json:"car_photo_left"`
+	CarPhotoRight  string    `json:"car_photo_right"`
+	TariffClass    string    `json:"tariff_class"`
+	ApprovalStatus string    `json:"approval_status"`
+	IsActive       bool      `json:"is_active"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func makeVehicleResponse(v repo.Vehicle) vehicleResponse {
+	return vehicleResponse{
+		ID:             v.ID,
+		DriverID:       v.DriverID,
+		CarModel:       v.CarModel.String,
+		CarColor:       v.CarColor.String,
+		CarNumber:      v.CarNumber,
+		TechPassport:   v.TechPassport,
+		CarPhotoFront:  v.CarPhotoFront,
+		CarPhotoBack:   v.CarPhotoBack,
+		CarPhotoLeft:   v.CarPhotoLeft,
+		CarPhotoRight:  v.CarPhotoRight,
+		TariffClass:    v.TariffClass,
+		ApprovalStatus: v.ApprovalStatus,
+		IsActive:       v.IsActive,
+		CreatedAt:      v.CreatedAt,
+		UpdatedAt:      v.UpdatedAt,
+	}
+}
+
+func makeVehicleResponses(list []repo.Vehicle) []vehicleResponse {
+	resp := make([]vehicleResponse, 0, len(list))
+	for _, v := range list {
+		resp = append(resp, makeVehicleResponse(v))
+	}
+	return resp
+}
+
+// vehicleFromDriver переносит машину из анкеты водителя в driver_vehicles.
+func vehicleFromDriver(driverID int64, d repo.Driver) repo.Vehicle {
+	return repo.Vehicle{
+		DriverID:      driverID,
+		CarModel:      d.CarModel,
+		CarColor:      d.CarColor,
+		CarNumber:     d.CarNumber,
+		TechPassport:  d.TechPassport,
+		CarPhotoFront: d.CarPhotoFront,
+		CarPhotoBack:  d.CarPhotoBack,
+		CarPhotoLeft:  d.CarPhotoLeft,
+		CarPhotoRight: d.CarPhotoRight,
+		TariffClass:   commission.DefaultTariffClass,
+	}
+}
+
+func normalizeTariffClass(v string) (string, bool) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if len(v) > maxTariffClassLen {
+		return "", false
+	}
+	return v, true
+}
+
+// approvePendingVehicles одобряет машины из анкеты вместе с водителем; первая из них становится активной.
+func (s *Server) approvePendingVehicles(ctx context.Context, driverID int64) {
+	ids, err := s.driversRepo.PendingVehicleIDs(ctx, driverID)
+	if err != nil {
+		s.logger.Errorf("driver %d: list pending vehicles failed: %v", driverID, err)
+		return
+	}
+	for _, id := range ids {
+		if _, err := s.driversRepo.SetVehicleApproval(ctx, driverID, id, repo.VehicleApproved, ""); err != nil {
+			s.logger.Errorf("driver %d: approve vehicle %d failed: %v", driverID, id, err)
+		}
+	}
+}
+
+// requireActiveVehicle пропускает к заказам только водителя с одобренной активной машиной.
+func requireActiveVehicle(w http.ResponseWriter, driver repo.Driver) bool {
+	if !driver.ActiveVehicleID.Valid {
+		writeError(w, http.StatusConflict, errDriverNoVehicle.Error())
+		return false
+	}
+	return true
+}
+
+// handleDriverVehicles: GET — машины водителя, POST — добавить машину на модерацию.
+func (s *Server) handleDriverVehicles(w http.ResponseWriter, r *http.Request) {
+	driverID, err := parseAuthID(r, "X-Driver-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing driver id")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		list, err := s.driversRepo.ListVehicles(ctx, driverID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load vehicles")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"vehicles": makeVehicleResponses(list)})
+	case http.MethodPost:
+		s.createDriverVehicle(w, r, driverID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createDriverVehicle(w http.ResponseWriter, r *http.Request, driverID int64) {
+	var payload driverPayload
+	var tariffClass string
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(50 << 20); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid multipart form")
+			return
+		}
+		if r.MultipartForm != nil {
+			defer r.MultipartForm.RemoveAll()
+		}
+		payload.CarModel = r.FormValue("car_model")
+		payload.CarColor = r.FormValue("car_color")
+		payload.CarNumber = r.FormValue("car_number")
+		tariffClass = r.FormValue("tariff_class")
+
+		assets := []struct {
+			field string
+			alt   string
+			dst   *string
+		}{
+			{"tech_passport", "TechPassport", &payload.TechPassport},
+			{"car_photo_front", "CarPhotoFront", &payload.CarPhotoFront},
+			{"car_photo_back", "CarPhotoBack", &payload.CarPhotoBack},
+			{"car_photo_left", "CarPhotoLeft", &payload.CarPhotoLeft},
+			{"car_photo_right", "CarPhotoRight", &payload.CarPhotoRight},
+		}
+		for _, a := range assets {
+			v, err := saveDriverAsset(r, a.field, a.alt)
+			if err != nil {
+				s.logger.Errorf("failed to save %s: %v", a.field, err)
+				writeError(w, http.StatusInternalServerError, "failed to save "+a.field)
+				return
+			}
+			*a.dst = v
+		}
+	} else {
+		var req struct {
+			driverPayload
+			TariffClass string `json:"tariff_class"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		payload, tariffClass = req.driverPayload, req.TariffClass
+	}
+
+	payload.normalize()
+	switch {
+	case payload.CarNumber == "":
+		writeError(w, http.StatusBadRequest, "car_number is required")
+		return
+	case payload.TechPassport == "":
+		writeError(w, http.StatusBadRequest, "tech_passport is required")
+		return
+	case payload.CarPhotoFront == "" || payload.CarPhotoBack == "" || payload.CarPhotoLeft == "" || payload.CarPhotoRight == "":
+		writeError(w, http.StatusBadRequest, "all car photos are required")
+		return
+	}
+	tariffClass, ok := normalizeTariffClass(tariffClass)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid tariff_class")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	driver, err := s.driversRepo.Get(ctx, driverID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusUnauthorized, "driver not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "driver lookup failed")
+		return
+	}
+	if driver.IsBanned {
+		writeError(w, http.StatusForbidden, "driver is banned")
+		return
+	}
+
+	vehicle := repo.Vehicle{
+		DriverID:      driverID,
+		CarModel:      toNullString(payload.CarModel),
+		CarColor:      toNullString(payload.CarColor),
+		CarNumber:     payload.CarNumber,
+		TechPassport:  payload.TechPassport,
+		CarPhotoFront: payload.CarPhotoFront,
+		CarPhotoBack:  payload.CarPhotoBack,
+		CarPhotoLeft:  payload.CarPhotoLeft,
+		CarPhotoRight: payload.CarPhotoRight,
+		TariffClass:   tariffClass,
+	}
+	id, err := s.driversRepo.CreateVehicle(ctx, vehicle)
+	if err != nil {
+		if errors.Is(err, repo.ErrVehicleExists) {
+			writeError(w, http.StatusConflict, "vehicle with this car_number already exists")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "create vehicle failed")
+		return
+	}
+	created, err := s.driversRepo.GetVehicle(ctx, driverID, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "fetch vehicle failed")
+		return
+	}
+	writeJSON(w, http.StatusCreated, makeVehicleResponse(created))
+}
+
+// handleDriverVehicle: DELETE /{id} — убрать машину, POST /{id}/activate — пересесть на неё.
+func (s *Server) handleDriverVehicle(w http.ResponseWriter, r *http.Request) {
+	driverID, err := parseAuthID(r, "X-Driver-ID")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "missing driver id")
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/driver/vehicles/"), "/")
+	parts := strings.Split(path, "/")
+	vehicleID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || vehicleID <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid vehicle id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		if err := s.driversRepo.DeleteVehicle(ctx, driverID, vehicleID); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				writeError(w, http.StatusNotFound, "vehicle not found")
+			case errors.Is(err, repo.ErrVehicleActive):
+				writeError(w, http.StatusConflict, "active vehicle cannot be deleted")
+			default:
+				writeError(w, http.StatusInternalServerError, "delete vehicle failed")
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[1] == "activate" && r.Method == http.MethodPost:
+		// машину меняем только между заказами
+		if _, err := s.ordersRepo.GetActiveOrderIDByDriver(ctx, driverID); err == nil {
+			writeError(w, http.StatusConflict, "cannot switch vehicle during an active order")
+			return
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusInternalServerError, "failed to check active order")
+			return
+		}
+		if err := s.driversRepo.ActivateVehicle(ctx, driverID, vehicleID); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				writeError(w, http.StatusNotFound, "vehicle not found")
+			case errors.Is(err, repo.ErrVehicleNotApproved):
+				writeError(w, http.StatusConflict, "vehicle is not approved")
+			default:
+				writeError(w, http.StatusInternalServerError, "activate vehicle failed")
+			}
+			return
+		}
+		vehicle, err := s.driversRepo.GetVehicle(ctx, driverID, vehicleID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "fetch vehicle failed")
+			return
+		}
+		writeJSON(w, http.StatusOK, makeVehicleResponse(vehicle))
+	case len(parts) <= 2:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// handleAdminDriverVehicles: GET — машины водителя, POST /{vid}/approval — модерация и класс.
+func (s *Server) handleAdminDriverVehicles(w http.ResponseWriter, r *http.Request, driverID int64, parts []string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	switch {
+	case len(parts) == 0:
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		list, err := s.driversRepo.ListVehicles(ctx, driverID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load vehicles")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"vehicles": makeVehicleResponses(list)})
+	case len(parts) == 2 && parts[1] == "approval":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		vehicleID, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || vehicleID <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid vehicle id")
+			return
+		}
+		var payload struct {
+			Status      string `json:"status"`
+			TariffClass string `json:"tariff_class"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		status := strings.ToLower(strings.TrimSpace(payload.Status))
+		if status != repo.VehicleApproved && status != repo.VehicleRejected {
+			writeError(w, http.StatusBadRequest, "status must be approved or rejected")
+			return
+		}
+		tariffClass, ok := normalizeTariffClass(payload.TariffClass)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid tariff_class")
+			return
+		}
+		vehicle, err := s.driversRepo.SetVehicleApproval(ctx, driverID, vehicleID, status, tariffClass)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusNotFound, "vehicle not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "update vehicle failed")
+			return
+		}
+		writeJSON(w, http.StatusOK, makeVehicleResponse(vehicle))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
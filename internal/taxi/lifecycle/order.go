@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"naimuBack/internal/taxi/fsm"
+	"naimuBack/internal/taxi/geo"
 )
 
 // GeoPoint describes geographic coordinates (WGS84).
@@ -14,20 +15,9 @@ type GeoPoint struct {
 	Lat float64
 }
 
-// DistanceTo returns distance in meters using a haversine approximation.
+// DistanceTo returns great-circle distance in meters.
 func (p GeoPoint) DistanceTo(other GeoPoint) float64 {
-	const earthRadius = 6371000.0
-	lat1 := toRadians(p.Lat)
-	lat2 := toRadians(other.Lat)
-	dLat := lat2 - lat1
-	dLon := toRadians(other.Lon - p.Lon)
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	return earthRadius * c
-}
-
-func toRadians(v float64) float64 {
-	return v * math.Pi / 180
+	return geo.DistanceMeters(p.Lon, p.Lat, other.Lon, other.Lat)
 }
 
 // Telemetry contains driver's current coordinates and metadata.
@@ -0,0 +1,197 @@
+package quests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"naimuBack/internal/taxi/repo"
+)
+
+// Repository stores quests, their evaluations and the activity they are measured on.
+type Repository interface {
+	ListActiveQuests(ctx context.Context, now time.Time) ([]repo.Quest, error)
+	ListDueQuests(ctx context.Context, now time.Time) ([]repo.Quest, error)
+	QuestCandidates(ctx context.Context, q repo.Quest) ([]int64, error)
+	RecordEvaluation(ctx context.Context, e repo.QuestEvaluation) (repo.QuestEvaluation, error)
+	MarkSettled(ctx context.Context, id int64, at time.Time) error
+	OnlineSeconds(ctx context.Context, driverID int64, from, to, now time.Time) (int64, error)
+	OfferStats(ctx context.Context, driverID int64, from, to time.Time) (repo.OfferStats, error)
+	DriverCity(ctx context.Context, driverID int64) (string, error)
+}
+
+// Orders provides completed trips of a driver.
+type Orders interface {
+	ListCompletedByDriverBetween(ctx context.Context, driverID int64, from, to time.Time) ([]repo.Order, error)
+}
+
+// Progress is a driver's current result for a quest.
+type Progress struct {
+	Quest    repo.Quest
+	Value    int
+	Offers   int
+	Achieved bool
+}
+
+// Service measures quest progress and pays rewards when quests end.
+type Service struct {
+	repo   Repository
+	orders Orders
+}
+
+// New builds a quests service.
+func New(r Repository, orders Orders) *Service {
+	return &Service{repo: r, orders: orders}
+}
+
+// Eligible reports whether a driver working in city may take part in the quest.
+func Eligible(q repo.Quest, city string) bool {
+	if len(q.Cities) == 0 {
+		return true
+	}
+	for _, c := range q.Cities {
+		if c == city {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptanceRate returns the share of accepted offers in percent, rounded down.
+func AcceptanceRate(s repo.OfferStats) int {
+	total := s.Accepted + s.Declined + s.Expired
+	if total == 0 {
+		return 0
+	}
+	return s.Accepted * 100 / total
+}
+
+// Measure computes the driver's progress for the quest up to now (or the quest end, whichever is earlier).
+func (s *Service) Measure(ctx context.Context, q repo.Quest, driverID int64, now time.Time) (Progress, error) {
+	p := Progress{Quest: q}
+	to := q.EndsAt
+	if now.Before(to) {
+		to = now
+	}
+	if !to.After(q.StartsAt) {
+		return p, nil
+	}
+
+	switch q.Metric {
+	case repo.QuestMetricTrips:
+		orders, err := s.orders.ListCompletedByDriverBetween(ctx, driverID, q.StartsAt, to)
+		if err != nil {
+			return p, err
+		}
+		p.Value = len(orders)
+		p.Achieved = p.Value >= q.Target
+	case repo.QuestMetricOnlineHours:
+		secs, err := s.repo.OnlineSeconds(ctx, driverID, q.StartsAt, to, now)
+		if err != nil {
+			return p, err
+		}
+		p.Value = int(secs / 3600)
+		p.Achieved = p.Value >= q.Target
+	case repo.QuestMetricAcceptanceRate:
+		stats, err := s.repo.OfferStats(ctx, driverID, q.StartsAt, to)
+		if err != nil {
+			return p, err
+		}
+		p.Offers = stats.Accepted + stats.Declined + stats.Expired
+		p.Value = AcceptanceRate(stats)
+		p.Achieved = p.Offers > 0 && p.Offers >= q.MinOffers && p.Value >= q.Target
+	default:
+		return p, fmt.Errorf("unknown quest metric %q", q.Metric)
+	}
+	return p, nil
+}
+
+// DriverProgress returns progress for every running quest the driver is eligible for.
+func (s *Service) DriverProgress(ctx context.Context, driverID int64, now time.Time) ([]Progress, error) {
+	active, err := s.repo.ListActiveQuests(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(active) == 0 {
+		return nil, nil
+	}
+	city, err := s.repo.DriverCity(ctx, driverID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Progress, 0, len(active))
+	for _, q := range active {
+		if !Eligible(q, city) {
+			continue
+		}
+		p, err := s.Measure(ctx, q, driverID, now)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, p)
+	}
+	return items, nil
+}
+
+// Settle evaluates every ended quest: each candidate gets an audit record, achievers get the reward on balance.
+// A quest is marked settled only after all candidates were processed, so a failed run is resumed on the next tick.
+func (s *Service) Settle(ctx context.Context, now time.Time) ([]repo.QuestEvaluation, error) {
+	due, err := s.repo.ListDueQuests(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var recorded []repo.QuestEvaluation
+	for _, q := range due {
+		evals, err := s.settleQuest(ctx, q, now)
+		recorded = append(recorded, evals...)
+		if err != nil {
+			return recorded, fmt.Errorf("settle quest %d: %w", q.ID, err)
+		}
+	}
+	return recorded, nil
+}
+
+func (s *Service) settleQuest(ctx context.Context, q repo.Quest, now time.Time) ([]repo.QuestEvaluation, error) {
+	candidates, err := s.repo.QuestCandidates(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var recorded []repo.QuestEvaluation
+	for _, driverID := range candidates {
+		if len(q.Cities) > 0 {
+			city, err := s.repo.DriverCity(ctx, driverID)
+			if err != nil {
+				return recorded, err
+			}
+			if !Eligible(q, city) {
+				continue
+			}
+		}
+		p, err := s.Measure(ctx, q, driverID, q.EndsAt)
+		if err != nil {
+			return recorded, err
+		}
+		eval, err := s.repo.RecordEvaluation(ctx, repo.QuestEvaluation{
+			QuestID:     q.ID,
+			DriverID:    driverID,
+			Metric:      q.Metric,
+			Value:       p.Value,
+			Target:      q.Target,
+			Achieved:    p.Achieved,
+			Reward:      q.Reward,
+			EvaluatedAt: now,
+		})
+		if errors.Is(err, repo.ErrQuestEvaluated) {
+			continue
+		}
+		if err != nil {
+			return recorded, err
+		}
+		recorded = append(recorded, eval)
+	}
+	return recorded, s.repo.MarkSettled(ctx, q.ID, now)
+}
@@ -0,0 +1,150 @@
+package quests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"naimuBack/internal/taxi/repo"
+)
+
+type stubRepo struct {
+	active     []repo.Quest
+	due        []repo.Quest
+	candidates []int64
+	evaluated  map[int64]bool
+	recorded   []repo.QuestEvaluation
+	settled    []int64
+	online     map[int64]int64
+	offers     map[int64]repo.OfferStats
+	cities     map[int64]string
+}
+
+func (s *stubRepo) ListActiveQuests(ctx context.Context, now time.Time) ([]repo.Quest, error) {
+	return s.active, nil
+}
+
+func (s *stubRepo) ListDueQuests(ctx context.Context, now time.Time) ([]repo.Quest, error) {
+	return s.due, nil
+}
+
+func (s *stubRepo) QuestCandidates(ctx context.Context, q repo.Quest) ([]int64, error) {
+	return s.candidates, nil
+}
+
+func (s *stubRepo) RecordEvaluation(ctx context.Context, e repo.QuestEvaluation) (repo.QuestEvaluation, error) {
+	if s.evaluated[e.DriverID] {
+		return repo.QuestEvaluation{}, repo.ErrQuestEvaluated
+	}
+	if !e.Achieved {
+		e.Reward = 0
+	}
+	s.recorded = append(s.recorded, e)
+	return e, nil
+}
+
+func (s *stubRepo) MarkSettled(ctx context.Context, id int64, at time.Time) error {
+	s.settled = append(s.settled, id)
+	return nil
+}
+
+func (s *stubRepo) OnlineSeconds(ctx context.Context, driverID int64, from, to, now time.Time) (int64, error) {
+	return s.online[driverID], nil
+}
+
+func (s *stubRepo) OfferStats(ctx context.Context, driverID int64, from, to time.Time) (repo.OfferStats, error) {
+	return s.offers[driverID], nil
+}
+
+func (s *stubRepo) DriverCity(ctx context.Context, driverID int64) (string, error) {
+	return s.cities[driverID], nil
+}
+
+type stubOrders struct {
+	trips map[int64]int
+}
+
+func (s stubOrders) ListCompletedByDriverBetween(ctx context.Context, driverID int64, from, to time.Time) ([]repo.Order, error) {
+	return make([]repo.Order, s.trips[driverID]), nil
+}
+
+func testQuest(metric string, target int) repo.Quest {
+	start := time.Date(2025, 3, 7, 18, 0, 0, 0, time.UTC)
+	return repo.Quest{ID: 1, Metric: metric, Target: target, Reward: 5000, StartsAt: start, EndsAt: start.Add(54 * time.Hour)}
+}
+
+func TestEligible(t *testing.T) {
+	q := testQuest(repo.QuestMetricTrips, 1)
+	if !Eligible(q, "") {
+		t.Fatal("quest without cities must be open for everyone")
+	}
+	q.Cities = []string{"astana", "almaty"}
+	if !Eligible(q, "almaty") || Eligible(q, "shymkent") || Eligible(q, "") {
+		t.Fatal("unexpected city eligibility")
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	r := &stubRepo{
+		online: map[int64]int64{7: 3*3600 + 1800},
+		offers: map[int64]repo.OfferStats{7: {Accepted: 9, Declined: 1, Expired: 2}},
+	}
+	svc := New(r, stubOrders{trips: map[int64]int{7: 20}})
+	ctx := context.Background()
+
+	trips := testQuest(repo.QuestMetricTrips, 20)
+	p, err := svc.Measure(ctx, trips, 7, trips.EndsAt)
+	if err != nil || p.Value != 20 || !p.Achieved {
+		t.Fatalf("trips progress = %+v, err %v", p, err)
+	}
+
+	online := testQuest(repo.QuestMetricOnlineHours, 4)
+	p, err = svc.Measure(ctx, online, 7, online.EndsAt)
+	if err != nil || p.Value != 3 || p.Achieved {
+		t.Fatalf("online progress = %+v, err %v", p, err)
+	}
+
+	rate := testQuest(repo.QuestMetricAcceptanceRate, 75)
+	rate.MinOffers = 10
+	p, err = svc.Measure(ctx, rate, 7, rate.EndsAt)
+	if err != nil || p.Value != 75 || p.Offers != 12 || !p.Achieved {
+		t.Fatalf("acceptance progress = %+v, err %v", p, err)
+	}
+	rate.MinOffers = 20
+	if p, _ = svc.Measure(ctx, rate, 7, rate.EndsAt); p.Achieved {
+		t.Fatal("acceptance rate must require min offers")
+	}
+
+	if p, _ = svc.Measure(ctx, trips, 7, trips.StartsAt.Add(-time.Hour)); p.Value != 0 {
+		t.Fatalf("quest not started yet, got %+v", p)
+	}
+}
+
+func TestSettle(t *testing.T) {
+	q := testQuest(repo.QuestMetricTrips, 20)
+	q.Cities = []string{"astana"}
+	r := &stubRepo{
+		due:        []repo.Quest{q},
+		candidates: []int64{1, 2, 3, 4},
+		evaluated:  map[int64]bool{3: true},
+		cities:     map[int64]string{1: "astana", 2: "astana", 3: "astana", 4: "almaty"},
+	}
+	svc := New(r, stubOrders{trips: map[int64]int{1: 25, 2: 5, 3: 30, 4: 40}})
+
+	recorded, err := svc.Settle(context.Background(), q.EndsAt.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("settle: %v", err)
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("expected 2 evaluations, got %+v", recorded)
+	}
+	if recorded[0].DriverID != 1 || !recorded[0].Achieved || recorded[0].Reward != 5000 {
+		t.Fatalf("unexpected achiever evaluation: %+v", recorded[0])
+	}
+	if recorded[1].DriverID != 2 || recorded[1].Achieved || recorded[1].Reward != 0 || recorded[1].Value != 5 {
+		t.Fatalf("unexpected audit for non-achiever: %+v", recorded[1])
+	}
+	if len(r.settled) != 1 || r.settled[0] != q.ID {
+		t.Fatalf("quest must be marked settled, got %v", r.settled)
+	}
+}
@@ -0,0 +1,176 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Block initiators and sources.
+const (
+	BlockInitiatorPassenger = "passenger"
+	BlockInitiatorDriver    = "driver"
+	BlockInitiatorAdmin     = "admin"
+
+	BlockSourceManual = "manual"
+	BlockSourceRating = "rating"
+)
+
+// Block forbids matching a passenger with a driver. Any block of the pair is enough.
+type Block struct {
+	ID          int64
+	PassengerID int64
+	DriverID    int64
+	Initiator   string
+	Source      string
+	OrderID     sql.NullInt64
+	Reason      sql.NullString
+	CreatedAt   time.Time
+}
+
+// BlockFilter narrows admin block listing.
+type BlockFilter struct {
+	PassengerID int64
+	DriverID    int64
+	Source      string
+	Limit       int
+	Offset      int
+}
+
+// BlocksRepo stores passenger-driver blocks.
+type BlocksRepo struct {
+	db *sql.DB
+}
+
+// NewBlocksRepo builds a blocks repo.
+func NewBlocksRepo(db *sql.DB) *BlocksRepo {
+	return &BlocksRepo{db: db}
+}
+
+const blockColumns = `id, passenger_id, driver_id, initiator, source, order_id, reason, created_at`
+
+func scanBlock(row interface{ Scan(...interface{}) error }) (Block, error) {
+	var b Block
+	err := row.Scan(&b.ID, &b.PassengerID, &b.DriverID, &b.Initiator, &b.Source, &b.OrderID, &b.Reason, &b.CreatedAt)
+	return b, err
+}
+
+// Block creates or refreshes a block of the pair by the initiator. An automatic block never
+// overwrites a manual one: the user's own decision and its reason stay as they were.
+func (r *BlocksRepo) Block(ctx context.Context, b Block) error {
+	if b.Source == "" {
+		b.Source = BlockSourceManual
+	}
+	// MySQL applies the assignments left to right, so source must be updated last.
+	_, err := r.db.ExecContext(ctx, `INSERT INTO taxi_blocks (passenger_id, driver_id, initiator, source, order_id, reason)
+        VALUES (?, ?, ?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE
+            order_id = IF(source = 'manual' AND VALUES(source) <> 'manual', order_id, VALUES(order_id)),
+            reason = IF(source = 'manual' AND VALUES(source) <> 'manual', reason, VALUES(reason)),
+            source = IF(source = 'manual', source, VALUES(source))`,
+		b.PassengerID, b.DriverID, b.Initiator, b.Source, b.OrderID, b.Reason)
+	return err
+}
+
+// Unblock removes the block set by the initiator. Returns sql.ErrNoRows when nothing was removed.
+func (r *BlocksRepo) Unblock(ctx context.Context, passengerID, driverID int64, initiator string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM taxi_blocks WHERE passenger_id = ? AND driver_id = ? AND initiator = ?`, passengerID, driverID, initiator)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// IsBlocked reports whether the passenger and driver must not be matched.
+func (r *BlocksRepo) IsBlocked(ctx context.Context, passengerID, driverID int64) (bool, error) {
+	var x int
+	err := r.db.QueryRowContext(ctx, `SELECT 1 FROM taxi_blocks WHERE passenger_id = ? AND driver_id = ? LIMIT 1`, passengerID, driverID).Scan(&x)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// BlockedDriverIDs returns drivers that must not be offered to the passenger.
+func (r *BlocksRepo) BlockedDriverIDs(ctx context.Context, passengerID int64) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT driver_id FROM taxi_blocks WHERE passenger_id = ?`, passengerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ListByInitiator returns blocks created by a passenger or a driver.
+func (r *BlocksRepo) ListByInitiator(ctx context.Context, initiator string, actorID int64) ([]Block, error) {
+	column := "passenger_id"
+	if initiator == BlockInitiatorDriver {
+		column = "driver_id"
+	}
+	return r.list(ctx, `SELECT `+blockColumns+` FROM taxi_blocks WHERE initiator = ? AND `+column+` = ? ORDER BY id DESC`, initiator, actorID)
+}
+
+// List returns blocks for the admin view.
+func (r *BlocksRepo) List(ctx context.Context, f BlockFilter) ([]Block, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if f.PassengerID > 0 {
+		where = append(where, "passenger_id = ?")
+		args = append(args, f.PassengerID)
+	}
+	if f.DriverID > 0 {
+		where = append(where, "driver_id = ?")
+		args = append(args, f.DriverID)
+	}
+	if f.Source != "" {
+		where = append(where, "source = ?")
+		args = append(args, f.Source)
+	}
+	query := `SELECT ` + blockColumns + ` FROM taxi_blocks`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT %d OFFSET %d", f.Limit, f.Offset)
+	return r.list(ctx, query, args...)
+}
+
+func (r *BlocksRepo) list(ctx context.Context, query string, args ...interface{}) ([]Block, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []Block
+	for rows.Next() {
+		b, err := scanBlock(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, b)
+	}
+	return items, rows.Err()
+}
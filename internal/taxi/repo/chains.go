@@ -0,0 +1,205 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrChainOrderUnavailable indicates that the queued order left search before the chain was confirmed.
+var ErrChainOrderUnavailable = errors.New("chained order is not searching")
+
+// Order chain statuses.
+const (
+	ChainStatusPending   = "pending"
+	ChainStatusConfirmed = "confirmed"
+	ChainStatusReleased  = "released"
+)
+
+// OrderChain links a queued next order to the trip the driver is currently finishing.
+type OrderChain struct {
+	ID               int64
+	OrderID          int64
+	DriverID         int64
+	CurrentOrderID   int64
+	Status           string
+	ReleaseReason    sql.NullString
+	ExpectedFinishAt time.Time
+	CreatedAt        time.Time
+}
+
+// ChainsRepo handles order_chains table.
+type ChainsRepo struct {
+	db *sql.DB
+}
+
+// NewChainsRepo builds a chains repo.
+func NewChainsRepo(db *sql.DB) *ChainsRepo {
+	return &ChainsRepo{db: db}
+}
+
+const chainColumns = `id, order_id, driver_id, current_order_id, status, release_reason, expected_finish_at, created_at`
+
+func scanChain(row interface{ Scan(...interface{}) error }) (OrderChain, error) {
+	var c OrderChain
+	err := row.Scan(&c.ID, &c.OrderID, &c.DriverID, &c.CurrentOrderID, &c.Status, &c.ReleaseReason, &c.ExpectedFinishAt, &c.CreatedAt)
+	return c, err
+}
+
+// CreatePending stores a pending chain and pauses dispatch of the queued order.
+func (r *ChainsRepo) CreatePending(ctx context.Context, orderID, driverID, currentOrderID int64, expectedFinish time.Time) (id int64, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO order_chains (order_id, driver_id, current_order_id, status, expected_finish_at) VALUES (?,?,?, 'pending', ?)`,
+		orderID, driverID, currentOrderID, expectedFinish)
+	if err != nil {
+		return 0, err
+	}
+	id, err = res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE order_dispatch SET state = 'chained' WHERE order_id = ? AND state = 'searching'`, orderID); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// HasPendingByDriver reports whether driver already has a queued next order.
+func (r *ChainsRepo) HasPendingByDriver(ctx context.Context, driverID int64) (bool, error) {
+	var x int
+	err := r.db.QueryRowContext(ctx, `SELECT 1 FROM order_chains WHERE driver_id = ? AND status = 'pending' LIMIT 1`, driverID).Scan(&x)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetPendingByDriver returns the pending chain of the driver.
+func (r *ChainsRepo) GetPendingByDriver(ctx context.Context, driverID int64) (OrderChain, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+chainColumns+` FROM order_chains WHERE driver_id = ? AND status = 'pending' ORDER BY id DESC LIMIT 1`, driverID)
+	return scanChain(row)
+}
+
+// ListPendingByOrder returns pending chains where the order is either the current trip or the queued one.
+func (r *ChainsRepo) ListPendingByOrder(ctx context.Context, orderID int64) ([]OrderChain, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+chainColumns+` FROM order_chains WHERE status = 'pending' AND (current_order_id = ? OR order_id = ?)`, orderID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OrderChain
+	for rows.Next() {
+		c, err := scanChain(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+// ListOverdue returns pending chains whose current trip should have finished before now.
+func (r *ChainsRepo) ListOverdue(ctx context.Context, now time.Time) ([]OrderChain, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+chainColumns+` FROM order_chains WHERE status = 'pending' AND expected_finish_at < ?`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OrderChain
+	for rows.Next() {
+		c, err := scanChain(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+// Confirm marks the chain confirmed and assigns the queued order to the driver in one transaction,
+// so a failed assignment leaves the chain pending instead of stranding the order.
+func (r *ChainsRepo) Confirm(ctx context.Context, chainID, orderID, driverID int64) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `UPDATE order_chains SET status = 'confirmed' WHERE id = ? AND status = 'pending'`, chainID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		err = sql.ErrNoRows
+		return err
+	}
+	res, err = tx.ExecContext(ctx, `UPDATE orders SET driver_id = ?, status = 'accepted', updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = 'searching'`, driverID, orderID)
+	if err != nil {
+		return err
+	}
+	if rows, err = res.RowsAffected(); err != nil {
+		return err
+	}
+	if rows == 0 {
+		err = ErrChainOrderUnavailable
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE order_dispatch SET state = 'assigned' WHERE order_id = ?`, orderID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Release returns the queued order back to normal search.
+func (r *ChainsRepo) Release(ctx context.Context, chainID, orderID int64, reason string, radius int, next time.Time) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `UPDATE order_chains SET status = 'released', release_reason = ? WHERE id = ? AND status = 'pending'`, reason, chainID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		err = sql.ErrNoRows
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE order_dispatch SET state = 'searching', radius_m = ?, next_tick_at = ? WHERE order_id = ? AND state = 'chained'`, radius, next, orderID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
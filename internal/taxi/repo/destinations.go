@@ -0,0 +1,163 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Destination mode statuses.
+const (
+	DestinationStatusActive   = "active"
+	DestinationStatusArrived  = "arrived"
+	DestinationStatusCanceled = "canceled"
+)
+
+// ErrDestinationLimitReached is returned when driver used all destination activations for the day.
+var ErrDestinationLimitReached = errors.New("destination daily limit reached")
+
+// DriverDestination describes driver's "going home" destination filter.
+type DriverDestination struct {
+	ID         int64
+	DriverID   int64
+	Lon        float64
+	Lat        float64
+	Address    sql.NullString
+	MaxDetourM int
+	Status     string
+	CreatedAt  time.Time
+	FinishedAt sql.NullTime
+}
+
+// DestinationsRepo stores driver destination mode activations.
+type DestinationsRepo struct {
+	db *sql.DB
+}
+
+// NewDestinationsRepo builds a destinations repo.
+func NewDestinationsRepo(db *sql.DB) *DestinationsRepo {
+	return &DestinationsRepo{db: db}
+}
+
+// Activate enables destination mode for the driver. Previously active destination is replaced
+// without consuming an extra activation; otherwise activations since dayStart are limited by dailyLimit.
+func (r *DestinationsRepo) Activate(ctx context.Context, dest DriverDestination, dailyLimit int, dayStart time.Time) (id int64, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// блокируем строку водителя, чтобы параллельные активации не обошли лимит
+	var x int
+	if err = tx.QueryRowContext(ctx, `SELECT 1 FROM drivers WHERE id = ? FOR UPDATE`, dest.DriverID).Scan(&x); err != nil {
+		return 0, err
+	}
+
+	var activeID int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM driver_destinations WHERE driver_id = ? AND status = 'active' ORDER BY id DESC LIMIT 1`, dest.DriverID).Scan(&activeID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if activeID > 0 {
+		if _, err = tx.ExecContext(ctx, `UPDATE driver_destinations SET dest_lon = ?, dest_lat = ?, dest_address = ?, max_detour_m = ? WHERE id = ?`,
+			dest.Lon, dest.Lat, dest.Address, dest.MaxDetourM, activeID); err != nil {
+			return 0, err
+		}
+		if err = tx.Commit(); err != nil {
+			return 0, err
+		}
+		return activeID, nil
+	}
+	err = nil
+
+	if dailyLimit > 0 {
+		var used int
+		if err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM driver_destinations WHERE driver_id = ? AND created_at >= ?`, dest.DriverID, dayStart).Scan(&used); err != nil {
+			return 0, err
+		}
+		if used >= dailyLimit {
+			err = ErrDestinationLimitReached
+			return 0, err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO driver_destinations (driver_id, dest_lon, dest_lat, dest_address, max_detour_m, status) VALUES (?,?,?,?,?, 'active')`,
+		dest.DriverID, dest.Lon, dest.Lat, dest.Address, dest.MaxDetourM)
+	if err != nil {
+		return 0, err
+	}
+	id, err = res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetActive returns the active destination of the driver or sql.ErrNoRows.
+func (r *DestinationsRepo) GetActive(ctx context.Context, driverID int64) (DriverDestination, error) {
+	var d DriverDestination
+	err := r.db.QueryRowContext(ctx, `SELECT id, driver_id, dest_lon, dest_lat, dest_address, max_detour_m, status, created_at, finished_at
+        FROM driver_destinations WHERE driver_id = ? AND status = 'active' ORDER BY id DESC LIMIT 1`, driverID).
+		Scan(&d.ID, &d.DriverID, &d.Lon, &d.Lat, &d.Address, &d.MaxDetourM, &d.Status, &d.CreatedAt, &d.FinishedAt)
+	return d, err
+}
+
+// ActiveByDrivers loads active destinations of the given drivers in one query, keyed by driver ID.
+// Drivers without an active destination are absent from the map.
+func (r *DestinationsRepo) ActiveByDrivers(ctx context.Context, driverIDs []int64) (map[int64]DriverDestination, error) {
+	result := make(map[int64]DriverDestination, len(driverIDs))
+	if len(driverIDs) == 0 {
+		return result, nil
+	}
+	args := make([]interface{}, len(driverIDs))
+	for i, id := range driverIDs {
+		args[i] = id
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT id, driver_id, dest_lon, dest_lat, dest_address, max_detour_m, status, created_at, finished_at
+        FROM driver_destinations WHERE status = 'active' AND driver_id IN (`+placeholders(len(driverIDs))+`) ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d DriverDestination
+		if err := rows.Scan(&d.ID, &d.DriverID, &d.Lon, &d.Lat, &d.Address, &d.MaxDetourM, &d.Status, &d.CreatedAt, &d.FinishedAt); err != nil {
+			return nil, err
+		}
+		// при нескольких активных строках побеждает последняя, как в GetActive
+		result[d.DriverID] = d
+	}
+	return result, rows.Err()
+}
+
+// CountSince returns how many activations driver made since the given moment.
+func (r *DestinationsRepo) CountSince(ctx context.Context, driverID int64, since time.Time) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM driver_destinations WHERE driver_id = ? AND created_at >= ?`, driverID, since).Scan(&n)
+	return n, err
+}
+
+// Finish closes the active destination with the provided final status.
+func (r *DestinationsRepo) Finish(ctx context.Context, driverID int64, status string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE driver_destinations SET status = ?, finished_at = CURRENT_TIMESTAMP WHERE driver_id = ? AND status = 'active'`, status, driverID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -26,14 +26,16 @@ type Driver struct {
 	CarPhotoBack   string
 	CarPhotoLeft   string
 	CarPhotoRight  string
-	DriverPhoto    string
-	Phone          string
-	IIN            string
-	IDCardFront    string
-	IDCardBack     string
-	Rating         float64
-	Balance        int
-	UpdatedAt      time.Time
+	// ActiveVehicleID — выбранная водителем машина из driver_vehicles.
+	ActiveVehicleID sql.NullInt64
+	DriverPhoto     string
+	Phone           string
+	IIN             string
+	IDCardFront     string
+	IDCardBack      string
+	Rating          float64
+	Balance         int
+	UpdatedAt       time.Time
 }
 
 // DriversRepo provides CRUD operations for drivers.
@@ -91,9 +93,9 @@ func (r *DriversRepo) Stats(ctx context.Context) (DriversStats, error) {
 	return stats, nil
 }
 
-// Create inserts a new driver record and returns its id.
-func (r *DriversRepo) Create(ctx context.Context, d Driver) (int64, error) {
-	res, err := r.db.ExecContext(ctx, `INSERT INTO drivers (
+// createDriverTx inserts a new driver record and returns its id.
+func createDriverTx(ctx context.Context, tx *sql.Tx, d Driver) (int64, error) {
+	res, err := tx.ExecContext(ctx, `INSERT INTO drivers (
         user_id, status, car_model, car_color, car_number, tech_passport,
         car_photo_front, car_photo_back, car_photo_left, car_photo_right,
         driver_photo, phone, iin, id_card_front, id_card_back
@@ -113,14 +115,14 @@ func (r *DriversRepo) Get(ctx context.Context, id int64) (Driver, error) {
 	var d Driver
 	row := r.db.QueryRowContext(ctx, `SELECT
         d.id, d.user_id, d.status, d.approval_status, d.is_banned, d.car_model, d.car_color, d.car_number, d.tech_passport,
-        d.car_photo_front, d.car_photo_back, d.car_photo_left, d.car_photo_right,
+        d.car_photo_front, d.car_photo_back, d.car_photo_left, d.car_photo_right, d.active_vehicle_id,
         d.driver_photo, d.phone, d.iin, d.id_card_front, d.id_card_back, d.rating, d.balance, d.updated_at,
         u.name, u.surname, COALESCE(u.middlename, ' ')
     FROM drivers d
     JOIN users u ON u.id = d.user_id
     WHERE d.id = ?`, id)
 	err := row.Scan(&d.ID, &d.UserID, &d.Status, &d.ApprovalStatus, &d.IsBanned, &d.CarModel, &d.CarColor, &d.CarNumber, &d.TechPassport,
-		&d.CarPhotoFront, &d.CarPhotoBack, &d.CarPhotoLeft, &d.CarPhotoRight,
+		&d.CarPhotoFront, &d.CarPhotoBack, &d.CarPhotoLeft, &d.CarPhotoRight, &d.ActiveVehicleID,
 		&d.DriverPhoto, &d.Phone, &d.IIN, &d.IDCardFront, &d.IDCardBack, &d.Rating, &d.Balance, &d.UpdatedAt,
 		&d.Name, &d.Surname, &d.Middlename)
 	if err != nil {
@@ -139,7 +141,7 @@ func (r *DriversRepo) List(ctx context.Context, limit, offset int) ([]Driver, er
 	}
 	rows, err := r.db.QueryContext(ctx, `SELECT
         d.id, d.user_id, d.status, d.approval_status, d.is_banned, d.car_model, d.car_color, d.car_number, d.tech_passport,
-        d.car_photo_front, d.car_photo_back, d.car_photo_left, d.car_photo_right,
+        d.car_photo_front, d.car_photo_back, d.car_photo_left, d.car_photo_right, d.active_vehicle_id,
         d.driver_photo, d.phone, d.iin, d.id_card_front, d.id_card_back, d.rating, d.balance, d.updated_at,
         u.name, u.surname, u.middlename
     FROM drivers d
@@ -155,7 +157,7 @@ func (r *DriversRepo) List(ctx context.Context, limit, offset int) ([]Driver, er
 	for rows.Next() {
 		var d Driver
 		if err := rows.Scan(&d.ID, &d.UserID, &d.Status, &d.ApprovalStatus, &d.IsBanned, &d.CarModel, &d.CarColor, &d.CarNumber, &d.TechPassport,
-			&d.CarPhotoFront, &d.CarPhotoBack, &d.CarPhotoLeft, &d.CarPhotoRight,
+			&d.CarPhotoFront, &d.CarPhotoBack, &d.CarPhotoLeft, &d.CarPhotoRight, &d.ActiveVehicleID,
 			&d.DriverPhoto, &d.Phone, &d.IIN, &d.IDCardFront, &d.IDCardBack, &d.Rating, &d.Balance, &d.UpdatedAt,
 			&d.Name, &d.Surname, &d.Middlename); err != nil {
 			return nil, err
@@ -208,6 +210,15 @@ func (r *DriversRepo) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
+// JoinedAt returns when the driver registered; zero time when unknown.
+func (r *DriversRepo) JoinedAt(ctx context.Context, driverID int64) (time.Time, error) {
+	var joined sql.NullTime
+	if err := r.db.QueryRowContext(ctx, `SELECT created_at FROM drivers WHERE id = ?`, driverID).Scan(&joined); err != nil {
+		return time.Time{}, err
+	}
+	return joined.Time, nil
+}
+
 // Deposit increases driver's balance by amount and returns new balance.
 func (r *DriversRepo) Deposit(ctx context.Context, driverID int64, amount int) (int, error) {
 	if amount <= 0 {
@@ -239,6 +250,15 @@ func (r *DriversRepo) Charge(ctx context.Context, driverID int64, amount int) (i
 	return r.adjustBalance(ctx, driverID, -amount, true)
 }
 
+// WithdrawTx deducts amount from the driver's balance within tx, never below zero.
+func WithdrawTx(ctx context.Context, tx *sql.Tx, driverID int64, amount int) error {
+	if amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	_, err := adjustBalanceTx(ctx, tx, driverID, -amount, false)
+	return err
+}
+
 func (r *DriversRepo) adjustBalance(ctx context.Context, driverID int64, delta int, allowNegative bool) (int, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -250,18 +270,26 @@ func (r *DriversRepo) adjustBalance(ctx context.Context, driverID int64, delta i
 		}
 	}()
 
+	newBalance, err := adjustBalanceTx(ctx, tx, driverID, delta, allowNegative)
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return newBalance, nil
+}
+
+func adjustBalanceTx(ctx context.Context, tx *sql.Tx, driverID int64, delta int, allowNegative bool) (int, error) {
 	var balance int
-	if err = tx.QueryRowContext(ctx, `SELECT balance FROM drivers WHERE id = ? FOR UPDATE`, driverID).Scan(&balance); err != nil {
+	if err := tx.QueryRowContext(ctx, `SELECT balance FROM drivers WHERE id = ? FOR UPDATE`, driverID).Scan(&balance); err != nil {
 		return 0, err
 	}
 	newBalance := balance + delta
 	if !allowNegative && newBalance < 0 {
 		return 0, ErrInsufficientBalance
 	}
-	if _, err = tx.ExecContext(ctx, `UPDATE drivers SET balance = ? WHERE id = ?`, newBalance, driverID); err != nil {
-		return 0, err
-	}
-	if err = tx.Commit(); err != nil {
+	if _, err := tx.ExecContext(ctx, `UPDATE drivers SET balance = ? WHERE id = ?`, newBalance, driverID); err != nil {
 		return 0, err
 	}
 	return newBalance, nil
@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// OnlineSession is one websocket presence of a driver; an open session has no EndedAt.
+type OnlineSession struct {
+	StartedAt time.Time
+	EndedAt   sql.NullTime
+}
+
+// DriverRest is a mandatory break imposed when the driver reaches the online time limit.
+type DriverRest struct {
+	ID            int64
+	DriverID      int64
+	OnlineSeconds int64
+	StartedAt     time.Time
+	RestUntil     time.Time
+}
+
+// DriverOnlineReport aggregates a driver's online time over a period.
+type DriverOnlineReport struct {
+	DriverID      int64
+	OnlineSeconds int64
+	Sessions      int
+	ForcedBreaks  int
+	LastSeenAt    time.Time
+}
+
+// FatigueRepo stores mandatory rest periods and reads online sessions for fatigue control.
+type FatigueRepo struct {
+	db *sql.DB
+}
+
+// NewFatigueRepo builds a fatigue repo.
+func NewFatigueRepo(db *sql.DB) *FatigueRepo {
+	return &FatigueRepo{db: db}
+}
+
+// RecentSessions returns the driver's sessions still open or ended after since, oldest first.
+func (r *FatigueRepo) RecentSessions(ctx context.Context, driverID int64, since time.Time) ([]OnlineSession, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT started_at, ended_at FROM driver_online_sessions
+        WHERE driver_id = ? AND (ended_at IS NULL OR ended_at > ?) ORDER BY started_at ASC, id ASC`, driverID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []OnlineSession
+	for rows.Next() {
+		var s OnlineSession
+		if err := rows.Scan(&s.StartedAt, &s.EndedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+	return items, rows.Err()
+}
+
+// ActiveRest returns the driver's rest period that has not ended by now.
+func (r *FatigueRepo) ActiveRest(ctx context.Context, driverID int64, now time.Time) (DriverRest, error) {
+	var rest DriverRest
+	err := r.db.QueryRowContext(ctx, `SELECT id, driver_id, online_seconds, started_at, rest_until FROM driver_rest_periods
+        WHERE driver_id = ? AND rest_until > ? ORDER BY rest_until DESC LIMIT 1`, driverID, now).
+		Scan(&rest.ID, &rest.DriverID, &rest.OnlineSeconds, &rest.StartedAt, &rest.RestUntil)
+	return rest, err
+}
+
+// StartRest records a mandatory rest period.
+func (r *FatigueRepo) StartRest(ctx context.Context, rest DriverRest) (DriverRest, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO driver_rest_periods (driver_id, online_seconds, started_at, rest_until) VALUES (?, ?, ?, ?)`,
+		rest.DriverID, rest.OnlineSeconds, rest.StartedAt, rest.RestUntil)
+	if err != nil {
+		return DriverRest{}, err
+	}
+	rest.ID, err = res.LastInsertId()
+	return rest, err
+}
+
+// OnlineReport sums online time of drivers clipped to [from, to); open sessions count up to now.
+// driverID > 0 limits the report to one driver.
+func (r *FatigueRepo) OnlineReport(ctx context.Context, from, to, now time.Time, driverID int64, limit, offset int) ([]DriverOnlineReport, error) {
+	var (
+		where strings.Builder
+		args  = []interface{}{from, now, to, from, to, now}
+	)
+	where.WriteString("s.started_at < ? AND (s.ended_at IS NULL OR s.ended_at > ?)")
+	args = append(args, to, from)
+	if driverID > 0 {
+		where.WriteString(" AND s.driver_id = ?")
+		args = append(args, driverID)
+	}
+	args = append(args, limit, offset)
+
+	rows, err := r.db.QueryContext(ctx, `SELECT s.driver_id,
+            COALESCE(SUM(GREATEST(0, TIMESTAMPDIFF(SECOND, GREATEST(s.started_at, ?), LEAST(COALESCE(s.ended_at, ?), ?)))), 0) AS online_seconds,
+            COUNT(*),
+            (SELECT COUNT(*) FROM driver_rest_periods p WHERE p.driver_id = s.driver_id AND p.started_at >= ? AND p.started_at < ?),
+            MAX(COALESCE(s.ended_at, ?))
+        FROM driver_online_sessions s
+        WHERE `+where.String()+`
+        GROUP BY s.driver_id
+        ORDER BY online_seconds DESC, s.driver_id ASC
+        LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DriverOnlineReport
+	for rows.Next() {
+		var item DriverOnlineReport
+		if err := rows.Scan(&item.DriverID, &item.OnlineSeconds, &item.Sessions, &item.ForcedBreaks, &item.LastSeenAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DemandPoint is a single order pickup used for heatmap aggregation.
+type DemandPoint struct {
+	Lon      float64
+	Lat      float64
+	NotFound bool
+}
+
+// HeatmapCell holds aggregated demand counters of one geohash cell.
+type HeatmapCell struct {
+	Geohash  string
+	Pickups  int
+	NotFound int
+}
+
+// HeatmapRepo stores pre-aggregated demand buckets.
+type HeatmapRepo struct {
+	db *sql.DB
+}
+
+// NewHeatmapRepo builds a heatmap repo.
+func NewHeatmapRepo(db *sql.DB) *HeatmapRepo {
+	return &HeatmapRepo{db: db}
+}
+
+// ListDemandPoints returns pickups of orders whose search started within [from, to).
+func (r *HeatmapRepo) ListDemandPoints(ctx context.Context, from, to time.Time) ([]DemandPoint, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT o.from_lon, o.from_lat, o.status
+        FROM order_dispatch d
+        JOIN orders o ON o.id = d.order_id
+        WHERE d.created_at >= ? AND d.created_at < ?`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DemandPoint
+	for rows.Next() {
+		var (
+			p      DemandPoint
+			status string
+		)
+		if err := rows.Scan(&p.Lon, &p.Lat, &status); err != nil {
+			return nil, err
+		}
+		p.NotFound = status == "not_found"
+		items = append(items, p)
+	}
+	return items, rows.Err()
+}
+
+// ListSearchingPoints returns pickups of orders that are still waiting for a driver.
+func (r *HeatmapRepo) ListSearchingPoints(ctx context.Context) ([]DemandPoint, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT o.from_lon, o.from_lat
+        FROM order_dispatch d
+        JOIN orders o ON o.id = d.order_id
+        WHERE d.state IN ('searching','chained') AND o.status = 'searching'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DemandPoint
+	for rows.Next() {
+		var p DemandPoint
+		if err := rows.Scan(&p.Lon, &p.Lat); err != nil {
+			return nil, err
+		}
+		items = append(items, p)
+	}
+	return items, rows.Err()
+}
+
+// ReplaceBucket overwrites aggregated cells of a single time bucket.
+func (r *HeatmapRepo) ReplaceBucket(ctx context.Context, bucketStart time.Time, cells []HeatmapCell) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM demand_heatmap WHERE bucket_start = ?`, bucketStart); err != nil {
+		return err
+	}
+	for _, c := range cells {
+		if _, err = tx.ExecContext(ctx, `INSERT INTO demand_heatmap (bucket_start, geohash, pickups, not_found) VALUES (?, ?, ?, ?)`,
+			bucketStart, c.Geohash, c.Pickups, c.NotFound); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SumCells sums aggregated buckets starting within [from, to) per geohash.
+func (r *HeatmapRepo) SumCells(ctx context.Context, from, to time.Time) ([]HeatmapCell, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT geohash, SUM(pickups), SUM(not_found)
+        FROM demand_heatmap
+        WHERE bucket_start >= ? AND bucket_start < ?
+        GROUP BY geohash`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []HeatmapCell
+	for rows.Next() {
+		var c HeatmapCell
+		if err := rows.Scan(&c.Geohash, &c.Pickups, &c.NotFound); err != nil {
+			return nil, err
+		}
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
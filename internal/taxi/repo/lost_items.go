@@ -0,0 +1,255 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Order types.
+const (
+	OrderTypeRide           = "ride"
+	OrderTypeLostItemReturn = "lost_item_return"
+)
+
+// Lost item case statuses.
+const (
+	LostItemOpen      = "open"
+	LostItemFound     = "found"
+	LostItemNotFound  = "not_found"
+	LostItemReturning = "returning"
+	LostItemReturned  = "returned"
+	LostItemClosed    = "closed"
+)
+
+var (
+	// ErrLostItemExists is returned when the order already has a lost item case.
+	ErrLostItemExists = errors.New("lost item case already exists")
+	// ErrLostItemState is returned when the case is not in a state that allows the action.
+	ErrLostItemState = errors.New("lost item case state conflict")
+)
+
+// LostItemCase — обращение пассажира о вещи, забытой в машине после поездки.
+type LostItemCase struct {
+	ID            int64
+	OrderID       int64
+	PassengerID   int64
+	DriverID      int64
+	Description   string
+	Status        string
+	DriverReply   sql.NullString
+	ReturnOrderID sql.NullInt64
+	ReturnFee     sql.NullInt64
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	ResolvedAt    sql.NullTime
+}
+
+// LostItemFilter narrows the admin list of cases.
+type LostItemFilter struct {
+	Status   string
+	DriverID int64
+	Limit    int
+	Offset   int
+}
+
+// LostItemsRepo stores lost item cases and creates return trips.
+type LostItemsRepo struct {
+	db *sql.DB
+}
+
+// NewLostItemsRepo builds a lost items repo.
+func NewLostItemsRepo(db *sql.DB) *LostItemsRepo {
+	return &LostItemsRepo{db: db}
+}
+
+const lostItemColumns = `id, order_id, passenger_id, driver_id, description, status, driver_reply,
+        return_order_id, return_fee, created_at, updated_at, resolved_at`
+
+func scanLostItem(row interface{ Scan(...interface{}) error }) (LostItemCase, error) {
+	var c LostItemCase
+	err := row.Scan(&c.ID, &c.OrderID, &c.PassengerID, &c.DriverID, &c.Description, &c.Status, &c.DriverReply,
+		&c.ReturnOrderID, &c.ReturnFee, &c.CreatedAt, &c.UpdatedAt, &c.ResolvedAt)
+	return c, err
+}
+
+// Create opens a case for the order; one case per order.
+func (r *LostItemsRepo) Create(ctx context.Context, c LostItemCase) (int64, error) {
+	var x int
+	err := r.db.QueryRowContext(ctx, `SELECT 1 FROM lost_item_cases WHERE order_id = ? LIMIT 1`, c.OrderID).Scan(&x)
+	if err == nil {
+		return 0, ErrLostItemExists
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+	res, err := r.db.ExecContext(ctx, `INSERT INTO lost_item_cases (order_id, passenger_id, driver_id, description, status) VALUES (?,?,?,?, 'open')`,
+		c.OrderID, c.PassengerID, c.DriverID, c.Description)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Get returns a case by id.
+func (r *LostItemsRepo) Get(ctx context.Context, id int64) (LostItemCase, error) {
+	return scanLostItem(r.db.QueryRowContext(ctx, `SELECT `+lostItemColumns+` FROM lost_item_cases WHERE id = ?`, id))
+}
+
+// GetByReturnOrder returns the case served by the return trip.
+func (r *LostItemsRepo) GetByReturnOrder(ctx context.Context, orderID int64) (LostItemCase, error) {
+	return scanLostItem(r.db.QueryRowContext(ctx, `SELECT `+lostItemColumns+` FROM lost_item_cases WHERE return_order_id = ?`, orderID))
+}
+
+// ListByPassenger returns cases opened by the passenger, newest first.
+func (r *LostItemsRepo) ListByPassenger(ctx context.Context, passengerID int64) ([]LostItemCase, error) {
+	return r.list(ctx, `SELECT `+lostItemColumns+` FROM lost_item_cases WHERE passenger_id = ? ORDER BY created_at DESC LIMIT 100`, passengerID)
+}
+
+// ListByDriver returns cases about the driver's trips, newest first.
+func (r *LostItemsRepo) ListByDriver(ctx context.Context, driverID int64) ([]LostItemCase, error) {
+	return r.list(ctx, `SELECT `+lostItemColumns+` FROM lost_item_cases WHERE driver_id = ? ORDER BY created_at DESC LIMIT 100`, driverID)
+}
+
+// List returns cases for the admin panel.
+func (r *LostItemsRepo) List(ctx context.Context, f LostItemFilter) ([]LostItemCase, error) {
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	query := `SELECT ` + lostItemColumns + ` FROM lost_item_cases WHERE 1=1`
+	var args []interface{}
+	if f.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, f.Status)
+	}
+	if f.DriverID > 0 {
+		query += ` AND driver_id = ?`
+		args = append(args, f.DriverID)
+	}
+	query += ` ORDER BY created_at DESC LIMIT ? OFFSET ?`
+	args = append(args, f.Limit, f.Offset)
+	return r.list(ctx, query, args...)
+}
+
+func (r *LostItemsRepo) list(ctx context.Context, query string, args ...interface{}) ([]LostItemCase, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cases := make([]LostItemCase, 0)
+	for rows.Next() {
+		c, err := scanLostItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// Reply records the driver's answer on an open case.
+func (r *LostItemsRepo) Reply(ctx context.Context, id, driverID int64, found bool, message string) error {
+	status := LostItemNotFound
+	if found {
+		status = LostItemFound
+	}
+	res, err := r.db.ExecContext(ctx, `UPDATE lost_item_cases SET status = ?, driver_reply = ?
+    WHERE id = ? AND driver_id = ? AND status IN ('open','found','not_found')`, status, message, id, driverID)
+	return expectOneRow(res, err, ErrLostItemState)
+}
+
+// Close finishes the case without a return trip.
+func (r *LostItemsRepo) Close(ctx context.Context, id int64, now time.Time) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE lost_item_cases SET status = 'closed', resolved_at = ?
+    WHERE id = ? AND status IN ('open','found','not_found')`, now, id)
+	return expectOneRow(res, err, ErrLostItemState)
+}
+
+// MarkReturned closes the case once the return trip is completed.
+func (r *LostItemsRepo) MarkReturned(ctx context.Context, id int64, now time.Time) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE lost_item_cases SET status = 'returned', resolved_at = ? WHERE id = ? AND status = 'returning'`, now, id)
+	return expectOneRow(res, err, ErrLostItemState)
+}
+
+// ReopenReturn puts the case back to found when the return trip was canceled.
+func (r *LostItemsRepo) ReopenReturn(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE lost_item_cases SET status = 'found', return_order_id = NULL, return_fee = NULL WHERE id = ? AND status = 'returning'`, id)
+	return expectOneRow(res, err, ErrLostItemState)
+}
+
+// CreateReturnOrder creates the return trip already assigned to the driver and moves the
+// case to returning. The whole fee goes to the driver.
+func (r *LostItemsRepo) CreateReturnOrder(ctx context.Context, caseID int64, order Order) (orderID int64, err error) {
+	if len(order.Addresses) < 2 {
+		return 0, fmt.Errorf("order must contain at least two addresses, got %d", len(order.Addresses))
+	}
+	if !order.DriverID.Valid {
+		return 0, fmt.Errorf("return order requires a driver")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var status string
+	if err = tx.QueryRowContext(ctx, `SELECT status FROM lost_item_cases WHERE id = ? FOR UPDATE`, caseID).Scan(&status); err != nil {
+		return 0, err
+	}
+	if status != LostItemFound {
+		err = ErrLostItemState
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO orders (passenger_id, driver_id, from_lon, from_lat, to_lon, to_lat, distance_m, eta_s,
+        recommended_price, client_price, payment_method, order_type, status, notes) VALUES (?,?,?,?,?,?,?,?,?,?,?, 'lost_item_return', 'accepted', ?)`,
+		order.PassengerID, order.DriverID, order.FromLon, order.FromLat, order.ToLon, order.ToLat, order.DistanceM, order.EtaSeconds,
+		order.RecommendedPrice, order.ClientPrice, order.PaymentMethod, order.Notes)
+	if err != nil {
+		return 0, err
+	}
+	orderID, err = res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err = insertOrderAddresses(ctx, tx, orderID, order.Addresses); err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE lost_item_cases SET status = 'returning', return_order_id = ?, return_fee = ? WHERE id = ?`,
+		orderID, order.ClientPrice, caseID); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return orderID, nil
+}
+
+func expectOneRow(res sql.Result, err error, notFound error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS driver_destinations (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    driver_id BIGINT NOT NULL,
+    dest_lon DOUBLE NOT NULL,
+    dest_lat DOUBLE NOT NULL,
+    dest_address VARCHAR(255) NULL,
+    max_detour_m INT NOT NULL,
+    status ENUM('active','arrived','canceled') NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP NULL,
+    INDEX idx_driver_destinations_driver_status (driver_id, status),
+    INDEX idx_driver_destinations_driver_created (driver_id, created_at),
+    CONSTRAINT fk_driver_destinations_driver FOREIGN KEY (driver_id) REFERENCES drivers(id) ON DELETE CASCADE
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS driver_destinations;
@@ -0,0 +1,27 @@
+-- +migrate Up
+ALTER TABLE order_dispatch
+    MODIFY COLUMN state ENUM('searching','assigned','finished','chained') NOT NULL DEFAULT 'searching';
+
+CREATE TABLE IF NOT EXISTS order_chains (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  order_id BIGINT NOT NULL,
+  driver_id BIGINT NOT NULL,
+  current_order_id BIGINT NOT NULL,
+  status ENUM('pending','confirmed','released') NOT NULL DEFAULT 'pending',
+  release_reason VARCHAR(64) NULL,
+  expected_finish_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  CONSTRAINT fk_chain_order FOREIGN KEY (order_id) REFERENCES orders(id) ON UPDATE CASCADE ON DELETE RESTRICT,
+  CONSTRAINT fk_chain_current_order FOREIGN KEY (current_order_id) REFERENCES orders(id) ON UPDATE CASCADE ON DELETE RESTRICT,
+  CONSTRAINT fk_chain_driver FOREIGN KEY (driver_id) REFERENCES drivers(id) ON UPDATE CASCADE ON DELETE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE INDEX idx_order_chains_driver_status ON order_chains(driver_id, status);
+CREATE INDEX idx_order_chains_current_status ON order_chains(current_order_id, status);
+CREATE INDEX idx_order_chains_order_status ON order_chains(order_id, status);
+
+-- +migrate Down
+DROP TABLE IF EXISTS order_chains;
+UPDATE order_dispatch SET state = 'searching' WHERE state = 'chained';
+ALTER TABLE order_dispatch
+    MODIFY COLUMN state ENUM('searching','assigned','finished') NOT NULL DEFAULT 'searching';
@@ -0,0 +1,13 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS demand_heatmap (
+  bucket_start TIMESTAMP NOT NULL,
+  geohash VARCHAR(12) NOT NULL,
+  pickups INT NOT NULL DEFAULT 0,
+  not_found INT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (bucket_start, geohash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE INDEX idx_demand_heatmap_geohash ON demand_heatmap(geohash);
+
+-- +migrate Down
+DROP TABLE IF EXISTS demand_heatmap;
@@ -0,0 +1,29 @@
+-- +migrate Up
+ALTER TABLE payments
+    MODIFY COLUMN state ENUM('created','authorized','paid','failed','partially_refunded','refunded') NOT NULL DEFAULT 'created',
+    ADD COLUMN refunded_amount INT NOT NULL DEFAULT 0 AFTER amount;
+
+CREATE TABLE IF NOT EXISTS order_refunds (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  order_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  amount INT NOT NULL,
+  reason VARCHAR(255) NULL,
+  idempotency_key VARCHAR(64) NOT NULL,
+  charge_driver TINYINT(1) NOT NULL DEFAULT 1,
+  driver_adjustment INT NOT NULL DEFAULT 0,
+  status ENUM('pending','succeeded','failed') NOT NULL DEFAULT 'pending',
+  error VARCHAR(255) NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  CONSTRAINT fk_refund_order FOREIGN KEY (order_id) REFERENCES orders(id) ON UPDATE CASCADE ON DELETE RESTRICT,
+  CONSTRAINT fk_refund_payment FOREIGN KEY (payment_id) REFERENCES payments(id) ON UPDATE CASCADE ON DELETE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE UNIQUE INDEX ux_order_refunds_key ON order_refunds(order_id, idempotency_key);
+CREATE INDEX idx_order_refunds_payment_status ON order_refunds(payment_id, status);
+
+-- +migrate Down
+DROP TABLE IF EXISTS order_refunds;
+ALTER TABLE payments
+    DROP COLUMN refunded_amount,
+    MODIFY COLUMN state ENUM('created','authorized','paid','failed') NOT NULL DEFAULT 'created';
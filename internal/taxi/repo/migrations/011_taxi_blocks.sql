@@ -0,0 +1,18 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS taxi_blocks (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  passenger_id BIGINT NOT NULL,
+  driver_id BIGINT NOT NULL,
+  initiator ENUM('passenger','driver','admin') NOT NULL,
+  source ENUM('manual','rating') NOT NULL DEFAULT 'manual',
+  order_id BIGINT NULL,
+  reason VARCHAR(255) NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT fk_block_passenger FOREIGN KEY (passenger_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE,
+  CONSTRAINT fk_block_driver FOREIGN KEY (driver_id) REFERENCES drivers(id) ON UPDATE CASCADE ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE UNIQUE INDEX ux_taxi_blocks_pair ON taxi_blocks(passenger_id, driver_id, initiator);
+CREATE INDEX idx_taxi_blocks_driver ON taxi_blocks(driver_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS taxi_blocks;
@@ -0,0 +1,18 @@
+-- +migrate Up
+ALTER TABLE orders
+    ADD COLUMN commission INT NULL AFTER client_price,
+    ADD COLUMN commission_rule_id BIGINT NULL AFTER commission,
+    ADD COLUMN commission_pass_id BIGINT NULL AFTER commission_rule_id;
+
+-- дата регистрации нужна для промо-правил новых водителей; для старых берём дату создания пользователя
+ALTER TABLE drivers
+    ADD COLUMN created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP;
+UPDATE drivers d JOIN users u ON u.id = d.user_id SET d.created_at = u.created_at;
+
+-- +migrate Down
+ALTER TABLE drivers
+    DROP COLUMN created_at;
+ALTER TABLE orders
+    DROP COLUMN commission_pass_id,
+    DROP COLUMN commission_rule_id,
+    DROP COLUMN commission;
@@ -0,0 +1,50 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS driver_online_sessions (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  driver_id BIGINT NOT NULL,
+  city VARCHAR(64) NOT NULL DEFAULT 'default',
+  started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ended_at TIMESTAMP NULL,
+  CONSTRAINT fk_online_session_driver FOREIGN KEY (driver_id) REFERENCES drivers(id) ON UPDATE CASCADE ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE INDEX idx_online_sessions_driver ON driver_online_sessions(driver_id, started_at);
+CREATE INDEX idx_online_sessions_open ON driver_online_sessions(ended_at);
+
+CREATE TABLE IF NOT EXISTS driver_quests (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  title VARCHAR(255) NOT NULL,
+  description TEXT NULL,
+  metric ENUM('trips','online_hours','acceptance_rate') NOT NULL,
+  target INT NOT NULL,
+  min_offers INT NOT NULL DEFAULT 0,
+  reward INT NOT NULL,
+  cities VARCHAR(255) NOT NULL DEFAULT '',
+  starts_at TIMESTAMP NOT NULL,
+  ends_at TIMESTAMP NOT NULL,
+  status ENUM('active','canceled','settled') NOT NULL DEFAULT 'active',
+  settled_at TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE INDEX idx_driver_quests_window ON driver_quests(status, starts_at, ends_at);
+
+CREATE TABLE IF NOT EXISTS driver_quest_evaluations (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  quest_id BIGINT NOT NULL,
+  driver_id BIGINT NOT NULL,
+  metric ENUM('trips','online_hours','acceptance_rate') NOT NULL,
+  value INT NOT NULL,
+  target INT NOT NULL,
+  achieved TINYINT(1) NOT NULL DEFAULT 0,
+  reward INT NOT NULL DEFAULT 0,
+  balance_after INT NULL,
+  evaluated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT fk_quest_eval_quest FOREIGN KEY (quest_id) REFERENCES driver_quests(id) ON UPDATE CASCADE ON DELETE CASCADE,
+  CONSTRAINT fk_quest_eval_driver FOREIGN KEY (driver_id) REFERENCES drivers(id) ON UPDATE CASCADE ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE UNIQUE INDEX ux_quest_eval_driver ON driver_quest_evaluations(quest_id, driver_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS driver_quest_evaluations;
+DROP TABLE IF EXISTS driver_quests;
+DROP TABLE IF EXISTS driver_online_sessions;
@@ -0,0 +1,46 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS popular_places (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  city VARCHAR(64) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  address VARCHAR(255) NOT NULL,
+  lon DOUBLE NOT NULL,
+  lat DOUBLE NOT NULL,
+  priority INT NOT NULL DEFAULT 0,
+  is_active TINYINT(1) NOT NULL DEFAULT 1,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE INDEX idx_popular_places_city ON popular_places(city, is_active, priority);
+
+INSERT INTO popular_places (city, name, address, lon, lat, priority) VALUES
+  ('astana', 'Аэропорт Нурсултан Назарбаев', 'Астана, проспект Кабанбай батыра, 1', 71.4669, 51.0222, 100),
+  ('astana', 'Вокзал Нурлы Жол', 'Астана, проспект Тауелсиздик, 53', 71.5318, 51.1125, 90),
+  ('astana', 'Железнодорожный вокзал Астана-1', 'Астана, улица Бейбитшилик, 1', 71.4098, 51.1951, 80),
+  ('astana', 'ТРЦ Хан Шатыр', 'Астана, проспект Туран, 37', 71.4035, 51.1324, 70),
+  ('astana', 'ТРЦ Mega Silk Way', 'Астана, проспект Кабанбай батыра, 62', 71.4145, 51.0894, 70),
+  ('astana', 'Байтерек', 'Астана, бульвар Нуржол, 14', 71.4305, 51.1283, 60),
+  ('almaty', 'Международный аэропорт Алматы', 'Алматы, улица Майлина, 2', 77.0405, 43.3521, 100),
+  ('almaty', 'Железнодорожный вокзал Алматы-2', 'Алматы, улица Абылай хана, 1', 76.9409, 43.2712, 90),
+  ('almaty', 'Железнодорожный вокзал Алматы-1', 'Алматы, улица Сейфуллина, 1', 76.9434, 43.3417, 80),
+  ('almaty', 'ТРЦ Mega Alma-Ata', 'Алматы, улица Розыбакиева, 247а', 76.9279, 43.2020, 70),
+  ('almaty', 'ТРЦ Dostyk Plaza', 'Алматы, микрорайон Самал-2, 111', 76.9560, 43.2337, 70);
+
+CREATE TABLE IF NOT EXISTS passenger_places (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  passenger_id BIGINT NOT NULL,
+  kind ENUM('home','work','other') NOT NULL DEFAULT 'other',
+  -- slot равен kind для дома и работы, у остальных NULL: у пассажира один дом и одна работа
+  slot VARCHAR(16) NULL,
+  title VARCHAR(128) NOT NULL,
+  address VARCHAR(255) NOT NULL,
+  lon DOUBLE NOT NULL,
+  lat DOUBLE NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  CONSTRAINT fk_passenger_place_user FOREIGN KEY (passenger_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE UNIQUE INDEX ux_passenger_places_slot ON passenger_places(passenger_id, slot);
+
+-- +migrate Down
+DROP TABLE IF EXISTS passenger_places;
+DROP TABLE IF EXISTS popular_places;
@@ -0,0 +1,8 @@
+-- +migrate Up
+-- геометрия участка маршрута от этой точки до следующей (encoded polyline); у последней точки NULL
+ALTER TABLE order_addresses
+    ADD COLUMN leg_polyline MEDIUMTEXT NULL AFTER address;
+
+-- +migrate Down
+ALTER TABLE order_addresses
+    DROP COLUMN leg_polyline;
@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS driver_rest_periods (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  driver_id BIGINT NOT NULL,
+  online_seconds INT NOT NULL,
+  started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  rest_until TIMESTAMP NOT NULL,
+  CONSTRAINT fk_rest_period_driver FOREIGN KEY (driver_id) REFERENCES drivers(id) ON UPDATE CASCADE ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE INDEX idx_rest_periods_driver ON driver_rest_periods(driver_id, rest_until);
+CREATE INDEX idx_rest_periods_started ON driver_rest_periods(started_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS driver_rest_periods;
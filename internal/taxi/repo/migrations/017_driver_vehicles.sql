@@ -0,0 +1,39 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS driver_vehicles (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  driver_id BIGINT NOT NULL,
+  car_model VARCHAR(64),
+  car_color VARCHAR(32),
+  car_number VARCHAR(32) NOT NULL,
+  tech_passport VARCHAR(128) NOT NULL,
+  car_photo_front VARCHAR(255) NOT NULL,
+  car_photo_back VARCHAR(255) NOT NULL,
+  car_photo_left VARCHAR(255) NOT NULL,
+  car_photo_right VARCHAR(255) NOT NULL,
+  tariff_class VARCHAR(32) NOT NULL DEFAULT 'standard',
+  approval_status ENUM('pending','approved','rejected') NOT NULL DEFAULT 'pending',
+  is_active TINYINT(1) NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  CONSTRAINT fk_vehicle_driver FOREIGN KEY (driver_id) REFERENCES drivers(id) ON UPDATE CASCADE ON DELETE CASCADE,
+  UNIQUE KEY uq_driver_vehicle_number (driver_id, car_number)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE INDEX idx_driver_vehicles_active ON driver_vehicles(driver_id, is_active);
+
+ALTER TABLE drivers ADD COLUMN active_vehicle_id BIGINT NULL AFTER car_photo_right;
+
+-- Машина, с которой водитель регистрировался, становится его первым авто
+INSERT INTO driver_vehicles (driver_id, car_model, car_color, car_number, tech_passport,
+    car_photo_front, car_photo_back, car_photo_left, car_photo_right, approval_status, is_active)
+SELECT id, car_model, car_color, car_number, tech_passport,
+    car_photo_front, car_photo_back, car_photo_left, car_photo_right, approval_status, approval_status = 'approved'
+FROM drivers
+WHERE car_number IS NOT NULL AND car_number <> '';
+
+UPDATE drivers d
+JOIN driver_vehicles v ON v.driver_id = d.id AND v.is_active = 1
+SET d.active_vehicle_id = v.id;
+
+-- +migrate Down
+ALTER TABLE drivers DROP COLUMN active_vehicle_id;
+DROP TABLE IF EXISTS driver_vehicles;
@@ -0,0 +1,29 @@
+-- +migrate Up
+ALTER TABLE orders
+    ADD COLUMN order_type ENUM('ride','lost_item_return') NOT NULL DEFAULT 'ride' AFTER payment_method;
+
+CREATE TABLE IF NOT EXISTS lost_item_cases (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  order_id BIGINT NOT NULL,
+  passenger_id BIGINT NOT NULL,
+  driver_id BIGINT NOT NULL,
+  description VARCHAR(500) NOT NULL,
+  status ENUM('open','found','not_found','returning','returned','closed') NOT NULL DEFAULT 'open',
+  driver_reply VARCHAR(500) NULL,
+  return_order_id BIGINT NULL,
+  return_fee INT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  resolved_at TIMESTAMP NULL,
+  CONSTRAINT fk_lost_item_order FOREIGN KEY (order_id) REFERENCES orders(id) ON UPDATE CASCADE ON DELETE RESTRICT,
+  CONSTRAINT fk_lost_item_driver FOREIGN KEY (driver_id) REFERENCES drivers(id) ON UPDATE CASCADE ON DELETE RESTRICT,
+  CONSTRAINT fk_lost_item_return_order FOREIGN KEY (return_order_id) REFERENCES orders(id) ON UPDATE CASCADE ON DELETE SET NULL,
+  UNIQUE KEY uq_lost_item_order (order_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE INDEX idx_lost_item_passenger ON lost_item_cases(passenger_id, created_at);
+CREATE INDEX idx_lost_item_driver ON lost_item_cases(driver_id, status);
+CREATE INDEX idx_lost_item_status ON lost_item_cases(status, created_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS lost_item_cases;
+ALTER TABLE orders DROP COLUMN order_type;
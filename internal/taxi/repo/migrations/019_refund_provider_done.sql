@@ -0,0 +1,8 @@
+-- +migrate Up
+ALTER TABLE order_refunds
+    MODIFY COLUMN status ENUM('pending','provider_done','succeeded','failed') NOT NULL DEFAULT 'pending';
+
+-- +migrate Down
+UPDATE order_refunds SET status = 'pending' WHERE status = 'provider_done';
+ALTER TABLE order_refunds
+    MODIFY COLUMN status ENUM('pending','succeeded','failed') NOT NULL DEFAULT 'pending';
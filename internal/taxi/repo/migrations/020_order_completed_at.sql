@@ -0,0 +1,6 @@
+-- +migrate Up
+ALTER TABLE orders ADD COLUMN completed_at TIMESTAMP NULL DEFAULT NULL AFTER updated_at;
+UPDATE orders SET completed_at = updated_at WHERE status IN ('completed','paid','closed');
+
+-- +migrate Down
+ALTER TABLE orders DROP COLUMN completed_at;
@@ -63,6 +63,8 @@ var (
 	ErrReviewForbidden        = errors.New("review forbidden")
 	ErrReviewOrderNotFinished = errors.New("order not completed")
 	ErrReviewDriverMissing    = errors.New("order has no driver")
+	// ErrDriverBusy — у водителя уже есть активный заказ.
+	ErrDriverBusy = errors.New("driver has an active order")
 )
 
 type OrdersStats struct {
@@ -94,11 +96,26 @@ type Order struct {
 	RecommendedPrice int
 	ClientPrice      int
 	PaymentMethod    string
-	Status           string
-	Notes            sql.NullString
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
-	Addresses        []OrderAddress
+	// OrderType отличает обычную поездку от возврата забытой вещи.
+	OrderType string
+	Status    string
+	Notes     sql.NullString
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// CompletedAt — момент перехода в completed; updated_at меняется при любой правке строки.
+	CompletedAt sql.NullTime
+	Addresses   []OrderAddress
+	// Commission is recorded on completion together with the rule or shift pass that produced it.
+	Commission       sql.NullInt64
+	CommissionRuleID sql.NullInt64
+	CommissionPassID sql.NullInt64
+}
+
+// OrderCommission is the commission charged to the driver on completion.
+type OrderCommission struct {
+	Amount int
+	RuleID sql.NullInt64
+	PassID sql.NullInt64
 }
 
 // OrderAddress represents a waypoint for the order.
@@ -109,6 +126,8 @@ type OrderAddress struct {
 	Lon     float64
 	Lat     float64
 	Address sql.NullString
+	// LegPolyline is the encoded route geometry from this point to the next one.
+	LegPolyline sql.NullString
 }
 
 // OrdersRepo provides access to orders data.
@@ -229,6 +248,7 @@ func (r *OrdersRepo) GetWithDriver(ctx context.Context, id int64) (Order, *Drive
 		driverPhotoBack    sql.NullString
 		driverPhotoLeft    sql.NullString
 		driverPhotoRight   sql.NullString
+		driverVehicleID    sql.NullInt64
 		driverDriverPhoto  sql.NullString
 		driverPhone        sql.NullString
 		driverIIN          sql.NullString
@@ -243,10 +263,10 @@ func (r *OrdersRepo) GetWithDriver(ctx context.Context, id int64) (Order, *Drive
 
 	row := r.db.QueryRowContext(ctx, `SELECT
         o.id, o.passenger_id, o.driver_id, o.from_lon, o.from_lat, o.to_lon, o.to_lat,
-        o.distance_m, o.eta_s, o.recommended_price, o.client_price, o.payment_method,
-        o.status, o.notes, o.created_at, o.updated_at,
+        o.distance_m, o.eta_s, o.recommended_price, o.client_price, o.payment_method, o.order_type,
+        o.status, o.notes, o.created_at, o.updated_at, o.completed_at, o.commission, o.commission_rule_id, o.commission_pass_id,
         d.id, d.user_id, d.status, d.car_model, d.car_color, d.car_number,
-        d.tech_passport, d.car_photo_front, d.car_photo_back, d.car_photo_left, d.car_photo_right,
+        d.tech_passport, d.car_photo_front, d.car_photo_back, d.car_photo_left, d.car_photo_right, d.active_vehicle_id,
         d.driver_photo, d.phone, d.iin, d.id_card_front, d.id_card_back, d.rating, d.updated_at,
         u.name, u.surname, u.middlename
     FROM orders o
@@ -255,10 +275,10 @@ func (r *OrdersRepo) GetWithDriver(ctx context.Context, id int64) (Order, *Drive
     WHERE o.id = ?`, id)
 	err := row.Scan(
 		&o.ID, &o.PassengerID, &o.DriverID, &o.FromLon, &o.FromLat, &o.ToLon, &o.ToLat,
-		&o.DistanceM, &o.EtaSeconds, &o.RecommendedPrice, &o.ClientPrice, &o.PaymentMethod,
-		&o.Status, &o.Notes, &o.CreatedAt, &o.UpdatedAt,
+		&o.DistanceM, &o.EtaSeconds, &o.RecommendedPrice, &o.ClientPrice, &o.PaymentMethod, &o.OrderType,
+		&o.Status, &o.Notes, &o.CreatedAt, &o.UpdatedAt, &o.CompletedAt, &o.Commission, &o.CommissionRuleID, &o.CommissionPassID,
 		&driverID, &driverUserID, &driverStatus, &driverCarModel, &driverCarColor, &driverCarNumber,
-		&driverTechPassport, &driverPhotoFront, &driverPhotoBack, &driverPhotoLeft, &driverPhotoRight,
+		&driverTechPassport, &driverPhotoFront, &driverPhotoBack, &driverPhotoLeft, &driverPhotoRight, &driverVehicleID,
 		&driverDriverPhoto, &driverPhone, &driverIIN, &driverIDCardFront, &driverIDCardBack, &driverRating, &driverUpdatedAt,
 		&driverName, &driverSurname, &driverMiddlename,
 	)
@@ -276,9 +296,10 @@ func (r *OrdersRepo) GetWithDriver(ctx context.Context, id int64) (Order, *Drive
 	}
 
 	driver := Driver{
-		ID:       driverID.Int64,
-		CarModel: driverCarModel,
-		CarColor: driverCarColor,
+		ID:              driverID.Int64,
+		CarModel:        driverCarModel,
+		CarColor:        driverCarColor,
+		ActiveVehicleID: driverVehicleID,
 	}
 	if driverUserID.Valid {
 		driver.UserID = driverUserID.Int64
@@ -449,6 +470,41 @@ func (r *OrdersRepo) ListAll(ctx context.Context, limit, offset int) ([]Order, e
 	return orders, nil
 }
 
+// LiveOrder is an active order together with its current search radius.
+type LiveOrder struct {
+	Order
+	// RadiusM is set while the dispatcher is still searching for a driver.
+	RadiusM sql.NullInt64
+}
+
+// ListLive returns every active order for the operations map, oldest first.
+func (r *OrdersRepo) ListLive(ctx context.Context) ([]LiveOrder, error) {
+	args := make([]interface{}, 0, len(passengerActiveStatuses))
+	for _, status := range passengerActiveStatuses {
+		args = append(args, status)
+	}
+	query := fmt.Sprintf(`SELECT o.id, o.passenger_id, o.driver_id, o.from_lon, o.from_lat, o.to_lon, o.to_lat, o.distance_m, o.eta_s, o.recommended_price, o.client_price, o.payment_method, o.status, o.notes, o.created_at, o.updated_at, d.radius_m
+        FROM orders o
+        LEFT JOIN order_dispatch d ON d.order_id = o.id AND d.state = 'searching'
+        WHERE o.status IN (%s)
+        ORDER BY o.created_at`, placeholders(len(passengerActiveStatuses)))
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]LiveOrder, 0)
+	for rows.Next() {
+		var o LiveOrder
+		if err := rows.Scan(&o.ID, &o.PassengerID, &o.DriverID, &o.FromLon, &o.FromLat, &o.ToLon, &o.ToLat, &o.DistanceM, &o.EtaSeconds, &o.RecommendedPrice, &o.ClientPrice, &o.PaymentMethod, &o.Status, &o.Notes, &o.CreatedAt, &o.UpdatedAt, &o.RadiusM); err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
 // GetActiveOrderIDByPassenger returns the most recent active order ID for the passenger.
 func (r *OrdersRepo) GetActiveOrderIDByPassenger(ctx context.Context, passengerID int64) (Order, error) {
 	args := make([]interface{}, 0, len(passengerActiveStatuses)+1)
@@ -515,7 +571,7 @@ func (r *OrdersRepo) ListCompletedByDriverBetween(ctx context.Context, driverID
 	}
 	args = append(args, from, to)
 
-	query := fmt.Sprintf(`SELECT id, passenger_id, driver_id, from_lon, from_lat, to_lon, to_lat, distance_m, eta_s, recommended_price, client_price, payment_method, status, notes, created_at, updated_at FROM orders WHERE driver_id = ? AND status IN (%s) AND updated_at >= ? AND updated_at < ? ORDER BY updated_at ASC`, placeholders(len(driverCompletedStatuses)))
+	query := fmt.Sprintf(`SELECT id, passenger_id, driver_id, from_lon, from_lat, to_lon, to_lat, distance_m, eta_s, recommended_price, client_price, payment_method, status, notes, created_at, updated_at, commission FROM orders WHERE driver_id = ? AND status IN (%s) AND updated_at >= ? AND updated_at < ? ORDER BY updated_at ASC`, placeholders(len(driverCompletedStatuses)))
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -526,7 +582,7 @@ func (r *OrdersRepo) ListCompletedByDriverBetween(ctx context.Context, driverID
 	var orders []Order
 	for rows.Next() {
 		var o Order
-		if err := rows.Scan(&o.ID, &o.PassengerID, &o.DriverID, &o.FromLon, &o.FromLat, &o.ToLon, &o.ToLat, &o.DistanceM, &o.EtaSeconds, &o.RecommendedPrice, &o.ClientPrice, &o.PaymentMethod, &o.Status, &o.Notes, &o.CreatedAt, &o.UpdatedAt); err != nil {
+		if err := rows.Scan(&o.ID, &o.PassengerID, &o.DriverID, &o.FromLon, &o.FromLat, &o.ToLon, &o.ToLat, &o.DistanceM, &o.EtaSeconds, &o.RecommendedPrice, &o.ClientPrice, &o.PaymentMethod, &o.Status, &o.Notes, &o.CreatedAt, &o.UpdatedAt, &o.Commission); err != nil {
 			return nil, err
 		}
 		orders = append(orders, o)
@@ -639,7 +695,7 @@ func (r *OrdersRepo) ListDriverReviews(ctx context.Context, driverID int64) ([]D
 }
 
 func insertOrderAddresses(ctx context.Context, tx *sql.Tx, orderID int64, addresses []OrderAddress) error {
-	stmt, err := tx.PrepareContext(ctx, `INSERT INTO order_addresses (order_id, seq, lon, lat, address) VALUES (?,?,?,?,?)`)
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO order_addresses (order_id, seq, lon, lat, address, leg_polyline) VALUES (?,?,?,?,?,?)`)
 	if err != nil {
 		return err
 	}
@@ -650,7 +706,7 @@ func insertOrderAddresses(ctx context.Context, tx *sql.Tx, orderID int64, addres
 		if seq == 0 {
 			seq = i
 		}
-		if _, err := stmt.ExecContext(ctx, orderID, seq, addr.Lon, addr.Lat, addr.Address); err != nil {
+		if _, err := stmt.ExecContext(ctx, orderID, seq, addr.Lon, addr.Lat, addr.Address, addr.LegPolyline); err != nil {
 			return err
 		}
 	}
@@ -658,7 +714,7 @@ func insertOrderAddresses(ctx context.Context, tx *sql.Tx, orderID int64, addres
 }
 
 func (r *OrdersRepo) listAddresses(ctx context.Context, orderID int64) ([]OrderAddress, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, order_id, seq, lon, lat, address FROM order_addresses WHERE order_id = ? ORDER BY seq ASC`, orderID)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, order_id, seq, lon, lat, address, leg_polyline FROM order_addresses WHERE order_id = ? ORDER BY seq ASC`, orderID)
 	if err != nil {
 		return nil, err
 	}
@@ -667,7 +723,7 @@ func (r *OrdersRepo) listAddresses(ctx context.Context, orderID int64) ([]OrderA
 	var addresses []OrderAddress
 	for rows.Next() {
 		var addr OrderAddress
-		if err := rows.Scan(&addr.ID, &addr.OrderID, &addr.Seq, &addr.Lon, &addr.Lat, &addr.Address); err != nil {
+		if err := rows.Scan(&addr.ID, &addr.OrderID, &addr.Seq, &addr.Lon, &addr.Lat, &addr.Address, &addr.LegPolyline); err != nil {
 			return nil, err
 		}
 		addresses = append(addresses, addr)
@@ -865,9 +921,58 @@ func (r *OrdersRepo) AssignDriver(ctx context.Context, orderID, driverID int64)
 	return nil
 }
 
+// AssignDriverCAS assigns a driver only if the order is still in the expected status and the
+// driver has no other active order; a busy driver yields ErrDriverBusy.
+func (r *OrdersRepo) AssignDriverCAS(ctx context.Context, orderID, driverID int64, fromStatus string) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// блокируем строку водителя, чтобы два параллельных назначения не дали ему два заказа
+	var x int
+	if err = tx.QueryRowContext(ctx, `SELECT 1 FROM drivers WHERE id = ? FOR UPDATE`, driverID).Scan(&x); err != nil {
+		return err
+	}
+	args := make([]interface{}, 0, len(driverActiveStatuses)+1)
+	args = append(args, driverID)
+	for _, status := range driverActiveStatuses {
+		args = append(args, status)
+	}
+	var activeID int64
+	err = tx.QueryRowContext(ctx, activeDriverQuery, args...).Scan(&activeID)
+	if err == nil {
+		err = ErrDriverBusy
+		return err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `UPDATE orders SET driver_id = ?, status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?`, driverID, "accepted", orderID, fromStatus)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		err = sql.ErrNoRows
+		return err
+	}
+	return tx.Commit()
+}
+
 // UpdateStatusCAS updates status when current status matches expected.
 func (r *OrdersRepo) UpdateStatusCAS(ctx context.Context, orderID int64, fromStatus, toStatus string) error {
-	res, err := r.db.ExecContext(ctx, `UPDATE orders SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?`, toStatus, orderID, fromStatus)
+	res, err := r.db.ExecContext(ctx, `UPDATE orders SET status = ?, updated_at = CURRENT_TIMESTAMP,
+        completed_at = IF(? = 'completed', CURRENT_TIMESTAMP, completed_at) WHERE id = ? AND status = ?`, toStatus, toStatus, orderID, fromStatus)
 	if err != nil {
 		return err
 	}
@@ -881,8 +986,8 @@ func (r *OrdersRepo) UpdateStatusCAS(ctx context.Context, orderID int64, fromSta
 	return nil
 }
 
-// UpdateStatusWithDriverCharge atomically updates order status and deducts commission from driver.
-func (r *OrdersRepo) UpdateStatusWithDriverCharge(ctx context.Context, orderID int64, fromStatus, toStatus string, driverID int64, commission int) error {
+// UpdateStatusWithDriverCharge atomically updates order status, records the commission and deducts it from driver.
+func (r *OrdersRepo) UpdateStatusWithDriverCharge(ctx context.Context, orderID int64, fromStatus, toStatus string, driverID int64, charge OrderCommission) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -893,7 +998,9 @@ func (r *OrdersRepo) UpdateStatusWithDriverCharge(ctx context.Context, orderID i
 		}
 	}()
 
-	res, err := tx.ExecContext(ctx, `UPDATE orders SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?`, toStatus, orderID, fromStatus)
+	res, err := tx.ExecContext(ctx, `UPDATE orders SET status = ?, commission = ?, commission_rule_id = ?, commission_pass_id = ?, updated_at = CURRENT_TIMESTAMP,
+        completed_at = IF(? = 'completed', CURRENT_TIMESTAMP, completed_at)
+        WHERE id = ? AND status = ?`, toStatus, charge.Amount, charge.RuleID, charge.PassID, toStatus, orderID, fromStatus)
 	if err != nil {
 		return err
 	}
@@ -905,8 +1012,8 @@ func (r *OrdersRepo) UpdateStatusWithDriverCharge(ctx context.Context, orderID i
 		return sql.ErrNoRows
 	}
 
-	if commission > 0 && driverID > 0 {
-		res, err = tx.ExecContext(ctx, `UPDATE drivers SET balance = balance - ? WHERE id = ?`, commission, driverID)
+	if charge.Amount > 0 && driverID > 0 {
+		res, err = tx.ExecContext(ctx, `UPDATE drivers SET balance = balance - ? WHERE id = ?`, charge.Amount, driverID)
 		if err != nil {
 			return err
 		}
@@ -1145,3 +1252,15 @@ func (r *OffersRepo) GetActiveOfferDriverIDs(ctx context.Context, orderID int64)
 	}
 	return ids, rows.Err()
 }
+
+// CloseOffers closes every pending offer of the order and returns the drivers who had one.
+func (r *OffersRepo) CloseOffers(ctx context.Context, orderID int64) ([]int64, error) {
+	ids, err := r.GetActiveOfferDriverIDs(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.db.ExecContext(ctx, `UPDATE driver_order_offers SET state = 'closed' WHERE order_id = ? AND state = 'pending'`, orderID); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
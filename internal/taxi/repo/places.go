@@ -0,0 +1,194 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Saved place kinds. A passenger keeps at most one home and one work place.
+const (
+	PlaceKindHome  = "home"
+	PlaceKindWork  = "work"
+	PlaceKindOther = "other"
+)
+
+// PopularPlace is a pre-seeded place (airport, station, mall) suggested before the user types.
+type PopularPlace struct {
+	ID       int64
+	City     string
+	Name     string
+	Address  string
+	Lon      float64
+	Lat      float64
+	Priority int
+}
+
+// SavedPlace is a passenger's saved address.
+type SavedPlace struct {
+	ID          int64
+	PassengerID int64
+	Kind        string
+	Title       string
+	Address     string
+	Lon         float64
+	Lat         float64
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// RecentAddress is an address from the passenger's recent orders.
+type RecentAddress struct {
+	Address    string
+	Lon        float64
+	Lat        float64
+	LastUsedAt time.Time
+}
+
+// PlacesRepo stores popular and saved places.
+type PlacesRepo struct {
+	db *sql.DB
+}
+
+// NewPlacesRepo builds a places repo.
+func NewPlacesRepo(db *sql.DB) *PlacesRepo {
+	return &PlacesRepo{db: db}
+}
+
+// ListPopular returns active popular places of the city, optionally matching the query.
+// An empty city returns places of all cities.
+func (r *PlacesRepo) ListPopular(ctx context.Context, city, query string, limit int) ([]PopularPlace, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	where = append(where, "is_active = 1")
+	if city = strings.ToLower(strings.TrimSpace(city)); city != "" {
+		where = append(where, "city = ?")
+		args = append(args, city)
+	}
+	if query = strings.TrimSpace(query); query != "" {
+		like := "%" + escapeLike(query) + "%"
+		where = append(where, "(name LIKE ? OR address LIKE ?)")
+		args = append(args, like, like)
+	}
+	q := `SELECT id, city, name, address, lon, lat, priority FROM popular_places WHERE ` + strings.Join(where, " AND ") +
+		` ORDER BY priority DESC, id`
+	if limit > 0 {
+		q += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var places []PopularPlace
+	for rows.Next() {
+		var p PopularPlace
+		if err := rows.Scan(&p.ID, &p.City, &p.Name, &p.Address, &p.Lon, &p.Lat, &p.Priority); err != nil {
+			return nil, err
+		}
+		places = append(places, p)
+	}
+	return places, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func placeSlot(kind string) interface{} {
+	if kind == PlaceKindHome || kind == PlaceKindWork {
+		return kind
+	}
+	return nil
+}
+
+// SavePlace stores a saved place. Home and work replace the previous home and work of the passenger.
+func (r *PlacesRepo) SavePlace(ctx context.Context, p SavedPlace) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO passenger_places (passenger_id, kind, slot, title, address, lon, lat)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id), title = VALUES(title), address = VALUES(address), lon = VALUES(lon), lat = VALUES(lat)`,
+		p.PassengerID, p.Kind, placeSlot(p.Kind), p.Title, p.Address, p.Lon, p.Lat)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdatePlace changes title and location of a passenger's saved place.
+func (r *PlacesRepo) UpdatePlace(ctx context.Context, p SavedPlace) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE passenger_places SET title = ?, address = ?, lon = ?, lat = ?
+        WHERE id = ? AND passenger_id = ?`, p.Title, p.Address, p.Lon, p.Lat, p.ID, p.PassengerID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// DeletePlace removes a passenger's saved place.
+func (r *PlacesRepo) DeletePlace(ctx context.Context, passengerID, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM passenger_places WHERE id = ? AND passenger_id = ?`, id, passengerID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// GetPlace loads a passenger's saved place.
+func (r *PlacesRepo) GetPlace(ctx context.Context, passengerID, id int64) (SavedPlace, error) {
+	var p SavedPlace
+	err := r.db.QueryRowContext(ctx, `SELECT id, passenger_id, kind, title, address, lon, lat, created_at, updated_at
+        FROM passenger_places WHERE id = ? AND passenger_id = ?`, id, passengerID).
+		Scan(&p.ID, &p.PassengerID, &p.Kind, &p.Title, &p.Address, &p.Lon, &p.Lat, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// ListPlaces returns saved places of the passenger: home and work first.
+func (r *PlacesRepo) ListPlaces(ctx context.Context, passengerID int64) ([]SavedPlace, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, passenger_id, kind, title, address, lon, lat, created_at, updated_at
+        FROM passenger_places WHERE passenger_id = ? ORDER BY FIELD(kind, 'home', 'work', 'other'), title, id`, passengerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var places []SavedPlace
+	for rows.Next() {
+		var p SavedPlace
+		if err := rows.Scan(&p.ID, &p.PassengerID, &p.Kind, &p.Title, &p.Address, &p.Lon, &p.Lat, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		places = append(places, p)
+	}
+	return places, rows.Err()
+}
+
+// RecentAddresses returns distinct addresses of the passenger's orders, most recently used first.
+func (r *PlacesRepo) RecentAddresses(ctx context.Context, passengerID int64, limit int) ([]RecentAddress, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT a.address, MAX(a.lon), MAX(a.lat), MAX(o.created_at) AS last_used
+        FROM order_addresses a
+        JOIN orders o ON o.id = a.order_id
+        WHERE o.passenger_id = ? AND a.address IS NOT NULL AND a.address <> ''
+        GROUP BY a.address
+        ORDER BY last_used DESC
+        LIMIT ?`, passengerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recent []RecentAddress
+	for rows.Next() {
+		var a RecentAddress
+		if err := rows.Scan(&a.Address, &a.Lon, &a.Lat, &a.LastUsedAt); err != nil {
+			return nil, err
+		}
+		recent = append(recent, a)
+	}
+	return recent, rows.Err()
+}
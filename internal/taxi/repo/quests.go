@@ -0,0 +1,374 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Quest metrics and statuses.
+const (
+	QuestMetricTrips          = "trips"
+	QuestMetricOnlineHours    = "online_hours"
+	QuestMetricAcceptanceRate = "acceptance_rate"
+
+	QuestStatusActive   = "active"
+	QuestStatusCanceled = "canceled"
+	QuestStatusSettled  = "settled"
+)
+
+// ErrQuestEvaluated is returned when the driver already has an evaluation for the quest.
+var ErrQuestEvaluated = errors.New("quest already evaluated for driver")
+
+// Quest is an incentive campaign: reach Target of Metric within [StartsAt, EndsAt) and get Reward.
+type Quest struct {
+	ID          int64
+	Title       string
+	Description sql.NullString
+	Metric      string
+	// Target — поездки, часы онлайн или процент принятия в зависимости от метрики.
+	Target int
+	// MinOffers — минимум офферов для acceptance_rate, чтобы 1 из 1 не давал 100%.
+	MinOffers int
+	Reward    int
+	// Cities — допустимые города (пусто — все).
+	Cities    []string
+	StartsAt  time.Time
+	EndsAt    time.Time
+	Status    string
+	SettledAt sql.NullTime
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// QuestEvaluation is the audit record of a driver's final result for a quest.
+type QuestEvaluation struct {
+	ID           int64
+	QuestID      int64
+	DriverID     int64
+	Metric       string
+	Value        int
+	Target       int
+	Achieved     bool
+	Reward       int
+	BalanceAfter sql.NullInt64
+	EvaluatedAt  time.Time
+}
+
+// OfferStats counts driver's answers to offers; closed offers (taken by someone else) are not counted.
+type OfferStats struct {
+	Accepted int
+	Declined int
+	Expired  int
+}
+
+// QuestsRepo stores quests, their evaluations and driver online sessions.
+type QuestsRepo struct {
+	db *sql.DB
+}
+
+// NewQuestsRepo builds a quests repo.
+func NewQuestsRepo(db *sql.DB) *QuestsRepo {
+	return &QuestsRepo{db: db}
+}
+
+const questColumns = `id, title, description, metric, target, min_offers, reward, cities, starts_at, ends_at, status, settled_at, created_at, updated_at`
+
+func scanQuest(row interface{ Scan(...interface{}) error }) (Quest, error) {
+	var (
+		q      Quest
+		cities string
+	)
+	err := row.Scan(&q.ID, &q.Title, &q.Description, &q.Metric, &q.Target, &q.MinOffers, &q.Reward, &cities,
+		&q.StartsAt, &q.EndsAt, &q.Status, &q.SettledAt, &q.CreatedAt, &q.UpdatedAt)
+	q.Cities = splitCities(cities)
+	return q, err
+}
+
+func splitCities(value string) []string {
+	var cities []string
+	for _, c := range strings.Split(value, ",") {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+			cities = append(cities, c)
+		}
+	}
+	return cities
+}
+
+func joinCities(cities []string) string {
+	return strings.Join(splitCities(strings.Join(cities, ",")), ",")
+}
+
+// CreateQuest inserts a new active quest.
+func (r *QuestsRepo) CreateQuest(ctx context.Context, q Quest) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO driver_quests (title, description, metric, target, min_offers, reward, cities, starts_at, ends_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		q.Title, q.Description, q.Metric, q.Target, q.MinOffers, q.Reward, joinCities(q.Cities), q.StartsAt, q.EndsAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateQuest changes an active quest. Returns sql.ErrNoRows when the quest is missing or already closed.
+func (r *QuestsRepo) UpdateQuest(ctx context.Context, q Quest) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE driver_quests SET title = ?, description = ?, metric = ?, target = ?, min_offers = ?, reward = ?, cities = ?, starts_at = ?, ends_at = ?
+        WHERE id = ? AND status = 'active'`,
+		q.Title, q.Description, q.Metric, q.Target, q.MinOffers, q.Reward, joinCities(q.Cities), q.StartsAt, q.EndsAt, q.ID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// CancelQuest stops an active quest without paying rewards.
+func (r *QuestsRepo) CancelQuest(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE driver_quests SET status = 'canceled' WHERE id = ? AND status = 'active'`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// MarkSettled closes a quest after all rewards were evaluated.
+func (r *QuestsRepo) MarkSettled(ctx context.Context, id int64, at time.Time) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE driver_quests SET status = 'settled', settled_at = ? WHERE id = ? AND status = 'active'`, at, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func expectAffected(res sql.Result) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetQuest returns a quest by id.
+func (r *QuestsRepo) GetQuest(ctx context.Context, id int64) (Quest, error) {
+	return scanQuest(r.db.QueryRowContext(ctx, `SELECT `+questColumns+` FROM driver_quests WHERE id = ?`, id))
+}
+
+// ListQuests returns quests for the admin view, newest first. Empty status means any.
+func (r *QuestsRepo) ListQuests(ctx context.Context, status string, limit, offset int) ([]Quest, error) {
+	query := `SELECT ` + questColumns + ` FROM driver_quests`
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT %d OFFSET %d", limit, offset)
+	return r.listQuests(ctx, query, args...)
+}
+
+// ListActiveQuests returns quests running at the moment.
+func (r *QuestsRepo) ListActiveQuests(ctx context.Context, now time.Time) ([]Quest, error) {
+	return r.listQuests(ctx, `SELECT `+questColumns+` FROM driver_quests WHERE status = 'active' AND starts_at <= ? AND ends_at > ? ORDER BY ends_at ASC`, now, now)
+}
+
+// ListDueQuests returns active quests whose window has ended and rewards are not yet settled.
+func (r *QuestsRepo) ListDueQuests(ctx context.Context, now time.Time) ([]Quest, error) {
+	return r.listQuests(ctx, `SELECT `+questColumns+` FROM driver_quests WHERE status = 'active' AND ends_at <= ? ORDER BY ends_at ASC`, now)
+}
+
+func (r *QuestsRepo) listQuests(ctx context.Context, query string, args ...interface{}) ([]Quest, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []Quest
+	for rows.Next() {
+		q, err := scanQuest(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, q)
+	}
+	return items, rows.Err()
+}
+
+// QuestCandidates returns drivers who had any activity for the quest metric within its window.
+func (r *QuestsRepo) QuestCandidates(ctx context.Context, q Quest) ([]int64, error) {
+	var (
+		query string
+		args  []interface{}
+	)
+	switch q.Metric {
+	case QuestMetricTrips:
+		query = fmt.Sprintf(`SELECT DISTINCT driver_id FROM orders WHERE driver_id IS NOT NULL AND status IN (%s) AND updated_at >= ? AND updated_at < ?`,
+			placeholders(len(driverCompletedStatuses)))
+		for _, status := range driverCompletedStatuses {
+			args = append(args, status)
+		}
+		args = append(args, q.StartsAt, q.EndsAt)
+	case QuestMetricOnlineHours:
+		query = `SELECT DISTINCT driver_id FROM driver_online_sessions WHERE started_at < ? AND (ended_at IS NULL OR ended_at > ?)`
+		args = append(args, q.EndsAt, q.StartsAt)
+	case QuestMetricAcceptanceRate:
+		query = `SELECT DISTINCT driver_id FROM driver_order_offers WHERE created_at >= ? AND created_at < ?`
+		args = append(args, q.StartsAt, q.EndsAt)
+	default:
+		return nil, fmt.Errorf("unknown quest metric %q", q.Metric)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// RecordEvaluation stores the audit record and credits the reward to the driver's balance atomically.
+// Returns ErrQuestEvaluated when the driver was already evaluated for the quest.
+func (r *QuestsRepo) RecordEvaluation(ctx context.Context, e QuestEvaluation) (res QuestEvaluation, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return QuestEvaluation{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// блокируем квест, чтобы параллельные воркеры не начислили награду дважды
+	var status string
+	if err = tx.QueryRowContext(ctx, `SELECT status FROM driver_quests WHERE id = ? FOR UPDATE`, e.QuestID).Scan(&status); err != nil {
+		return QuestEvaluation{}, err
+	}
+	var x int
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM driver_quest_evaluations WHERE quest_id = ? AND driver_id = ?`, e.QuestID, e.DriverID).Scan(&x)
+	if err == nil {
+		err = ErrQuestEvaluated
+		return QuestEvaluation{}, err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return QuestEvaluation{}, err
+	}
+
+	if !e.Achieved {
+		e.Reward = 0
+	}
+	if e.Reward > 0 {
+		if _, err = tx.ExecContext(ctx, `UPDATE drivers SET balance = balance + ? WHERE id = ?`, e.Reward, e.DriverID); err != nil {
+			return QuestEvaluation{}, err
+		}
+		var balance int64
+		if err = tx.QueryRowContext(ctx, `SELECT balance FROM drivers WHERE id = ?`, e.DriverID).Scan(&balance); err != nil {
+			return QuestEvaluation{}, err
+		}
+		e.BalanceAfter = sql.NullInt64{Int64: balance, Valid: true}
+	}
+
+	if e.EvaluatedAt.IsZero() {
+		e.EvaluatedAt = time.Now()
+	}
+	result, err := tx.ExecContext(ctx, `INSERT INTO driver_quest_evaluations (quest_id, driver_id, metric, value, target, achieved, reward, balance_after, evaluated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.QuestID, e.DriverID, e.Metric, e.Value, e.Target, e.Achieved, e.Reward, e.BalanceAfter, e.EvaluatedAt)
+	if err != nil {
+		return QuestEvaluation{}, err
+	}
+	if e.ID, err = result.LastInsertId(); err != nil {
+		return QuestEvaluation{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return QuestEvaluation{}, err
+	}
+	return e, nil
+}
+
+// ListEvaluations returns the audit trail of a quest.
+func (r *QuestsRepo) ListEvaluations(ctx context.Context, questID int64, limit, offset int) ([]QuestEvaluation, error) {
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`SELECT id, quest_id, driver_id, metric, value, target, achieved, reward, balance_after, evaluated_at
+        FROM driver_quest_evaluations WHERE quest_id = ? ORDER BY id ASC LIMIT %d OFFSET %d`, limit, offset), questID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []QuestEvaluation
+	for rows.Next() {
+		var e QuestEvaluation
+		if err := rows.Scan(&e.ID, &e.QuestID, &e.DriverID, &e.Metric, &e.Value, &e.Target, &e.Achieved, &e.Reward, &e.BalanceAfter, &e.EvaluatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+	}
+	return items, rows.Err()
+}
+
+// StartOnlineSession opens a new online session, closing the previous one if it was left open.
+func (r *QuestsRepo) StartOnlineSession(ctx context.Context, driverID int64, city string, at time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE driver_online_sessions SET ended_at = ? WHERE driver_id = ? AND ended_at IS NULL`, at, driverID); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `INSERT INTO driver_online_sessions (driver_id, city, started_at) VALUES (?, ?, ?)`, driverID, city, at)
+	return err
+}
+
+// EndOnlineSession closes the driver's open session.
+func (r *QuestsRepo) EndOnlineSession(ctx context.Context, driverID int64, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE driver_online_sessions SET ended_at = ? WHERE driver_id = ? AND ended_at IS NULL`, at, driverID)
+	return err
+}
+
+// CloseOnlineSessions closes sessions left open by a previous process (websocket state is in memory).
+func (r *QuestsRepo) CloseOnlineSessions(ctx context.Context, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE driver_online_sessions SET ended_at = ? WHERE ended_at IS NULL AND started_at <= ?`, at, at)
+	return err
+}
+
+// OnlineSeconds sums the driver's online time clipped to [from, to); open sessions count up to now.
+func (r *QuestsRepo) OnlineSeconds(ctx context.Context, driverID int64, from, to, now time.Time) (int64, error) {
+	var total sql.NullInt64
+	err := r.db.QueryRowContext(ctx, `SELECT SUM(GREATEST(0, TIMESTAMPDIFF(SECOND, GREATEST(started_at, ?), LEAST(COALESCE(ended_at, ?), ?))))
+        FROM driver_online_sessions WHERE driver_id = ? AND started_at < ? AND (ended_at IS NULL OR ended_at > ?)`,
+		from, now, to, driverID, to, from).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+	return total.Int64, nil
+}
+
+// DriverCity returns the city of the driver's latest online session.
+func (r *QuestsRepo) DriverCity(ctx context.Context, driverID int64) (string, error) {
+	var city string
+	err := r.db.QueryRowContext(ctx, `SELECT city FROM driver_online_sessions WHERE driver_id = ? ORDER BY started_at DESC, id DESC LIMIT 1`, driverID).Scan(&city)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return city, err
+}
+
+// OfferStats counts the driver's answers to offers created within [from, to).
+func (r *QuestsRepo) OfferStats(ctx context.Context, driverID int64, from, to time.Time) (OfferStats, error) {
+	var s OfferStats
+	err := r.db.QueryRowContext(ctx, `SELECT
+            COALESCE(SUM(state = 'accepted'), 0),
+            COALESCE(SUM(state = 'declined'), 0),
+            COALESCE(SUM(state = 'expired'), 0)
+        FROM driver_order_offers WHERE driver_id = ? AND created_at >= ? AND created_at < ?`, driverID, from, to).Scan(&s.Accepted, &s.Declined, &s.Expired)
+	return s, err
+}
@@ -0,0 +1,225 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Refund statuses.
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+	// RefundStatusProviderDone: провайдер вернул деньги, но возврат ещё не проведён у нас.
+	RefundStatusProviderDone = "provider_done"
+)
+
+var (
+	// ErrPaymentNotRefundable is returned when order has no paid card payment.
+	ErrPaymentNotRefundable = errors.New("payment not refundable")
+	// ErrRefundExceedsPayment is returned when refunds would exceed the paid amount.
+	ErrRefundExceedsPayment = errors.New("refund exceeds paid amount")
+)
+
+// Refund describes a full or partial card payment refund.
+type Refund struct {
+	ID               int64
+	OrderID          int64
+	PaymentID        int64
+	Amount           int
+	Reason           sql.NullString
+	IdempotencyKey   string
+	ChargeDriver     bool
+	DriverAdjustment int
+	Status           string
+	Error            sql.NullString
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+
+	// Данные платежа на момент создания возврата.
+	PaymentAmount   int
+	PaymentRefunded int
+	ProviderTxnID   string
+}
+
+// RefundsRepo stores order refunds.
+type RefundsRepo struct {
+	db *sql.DB
+}
+
+// NewRefundsRepo builds a refunds repo.
+func NewRefundsRepo(db *sql.DB) *RefundsRepo {
+	return &RefundsRepo{db: db}
+}
+
+const refundColumns = `id, order_id, payment_id, amount, reason, idempotency_key, charge_driver, driver_adjustment, status, error, created_at, updated_at`
+
+func scanRefund(row interface{ Scan(...interface{}) error }) (Refund, error) {
+	var rf Refund
+	err := row.Scan(&rf.ID, &rf.OrderID, &rf.PaymentID, &rf.Amount, &rf.Reason, &rf.IdempotencyKey, &rf.ChargeDriver,
+		&rf.DriverAdjustment, &rf.Status, &rf.Error, &rf.CreatedAt, &rf.UpdatedAt)
+	return rf, err
+}
+
+// Begin registers a pending refund for the order's paid payment. Amount <= 0 means full remaining amount.
+// Repeated calls with the same idempotency key return the existing refund (created=false);
+// a failed refund is reset to pending so it can be retried. Pending and provider_done refunds
+// are returned as is — the caller resumes them.
+func (r *RefundsRepo) Begin(ctx context.Context, refund Refund) (result Refund, created bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Refund{}, false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var (
+		paymentID              int64
+		paymentAmount, settled int
+		txn                    sql.NullString
+	)
+	err = tx.QueryRowContext(ctx, `SELECT id, amount, refunded_amount, provider_txn_id FROM payments
+        WHERE order_id = ? AND state IN ('paid','partially_refunded')
+        ORDER BY id DESC LIMIT 1 FOR UPDATE`, refund.OrderID).Scan(&paymentID, &paymentAmount, &settled, &txn)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrPaymentNotRefundable
+		return Refund{}, false, err
+	}
+	if err != nil {
+		return Refund{}, false, err
+	}
+
+	existing, err := scanRefund(tx.QueryRowContext(ctx, `SELECT `+refundColumns+` FROM order_refunds WHERE order_id = ? AND idempotency_key = ? FOR UPDATE`,
+		refund.OrderID, refund.IdempotencyKey))
+	switch {
+	case err == nil && existing.Status != RefundStatusFailed:
+		existing.PaymentAmount, existing.PaymentRefunded, existing.ProviderTxnID = paymentAmount, settled, txn.String
+		if err = tx.Commit(); err != nil {
+			return Refund{}, false, err
+		}
+		return existing, false, nil
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return Refund{}, false, err
+	}
+	retry := err == nil
+	err = nil
+
+	var pending int
+	if err = tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount), 0) FROM order_refunds WHERE payment_id = ? AND status IN ('pending','provider_done')`, paymentID).Scan(&pending); err != nil {
+		return Refund{}, false, err
+	}
+	remaining := paymentAmount - settled - pending
+	if refund.Amount <= 0 {
+		refund.Amount = remaining
+	}
+	if refund.Amount <= 0 || refund.Amount > remaining {
+		err = ErrRefundExceedsPayment
+		return Refund{}, false, err
+	}
+
+	refund.PaymentID = paymentID
+	refund.Status = RefundStatusPending
+	if retry {
+		refund.ID = existing.ID
+		_, err = tx.ExecContext(ctx, `UPDATE order_refunds SET payment_id = ?, amount = ?, reason = ?, charge_driver = ?, status = 'pending', error = NULL WHERE id = ?`,
+			paymentID, refund.Amount, refund.Reason, refund.ChargeDriver, refund.ID)
+		if err != nil {
+			return Refund{}, false, err
+		}
+	} else {
+		var res sql.Result
+		res, err = tx.ExecContext(ctx, `INSERT INTO order_refunds (order_id, payment_id, amount, reason, idempotency_key, charge_driver) VALUES (?, ?, ?, ?, ?, ?)`,
+			refund.OrderID, paymentID, refund.Amount, refund.Reason, refund.IdempotencyKey, refund.ChargeDriver)
+		if err != nil {
+			return Refund{}, false, err
+		}
+		if refund.ID, err = res.LastInsertId(); err != nil {
+			return Refund{}, false, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return Refund{}, false, err
+	}
+	refund.PaymentAmount, refund.PaymentRefunded, refund.ProviderTxnID = paymentAmount, settled, txn.String
+	return refund, true, nil
+}
+
+// Complete marks refund succeeded, updates payment state and applies driver balance adjustment.
+func (r *RefundsRepo) Complete(ctx context.Context, refundID, driverID int64, driverAdjustment int) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var (
+		paymentID int64
+		amount    int
+	)
+	if err = tx.QueryRowContext(ctx, `SELECT payment_id, amount FROM order_refunds WHERE id = ? AND status IN ('pending','provider_done') FOR UPDATE`, refundID).Scan(&paymentID, &amount); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE order_refunds SET status = 'succeeded', driver_adjustment = ? WHERE id = ?`, driverAdjustment, refundID); err != nil {
+		return err
+	}
+	// MySQL применяет присваивания слева направо — state считается по уже увеличенной сумме
+	if _, err = tx.ExecContext(ctx, `UPDATE payments SET refunded_amount = refunded_amount + ?,
+        state = IF(refunded_amount >= amount, 'refunded', 'partially_refunded'), updated_at = CURRENT_TIMESTAMP WHERE id = ?`, amount, paymentID); err != nil {
+		return err
+	}
+	if driverAdjustment != 0 && driverID > 0 {
+		if _, err = tx.ExecContext(ctx, `UPDATE drivers SET balance = balance + ? WHERE id = ?`, driverAdjustment, driverID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// MarkProviderDone records that the provider has returned the money, so a replay completes the refund
+// instead of calling the provider again.
+func (r *RefundsRepo) MarkProviderDone(ctx context.Context, refundID int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE order_refunds SET status = 'provider_done' WHERE id = ? AND status = 'pending'`, refundID)
+	return err
+}
+
+// Fail marks pending refund as failed with provider error.
+func (r *RefundsRepo) Fail(ctx context.Context, refundID int64, reason string) error {
+	if len(reason) > 255 {
+		reason = reason[:255]
+	}
+	_, err := r.db.ExecContext(ctx, `UPDATE order_refunds SET status = 'failed', error = ? WHERE id = ? AND status = 'pending'`, reason, refundID)
+	return err
+}
+
+// Get returns refund by id.
+func (r *RefundsRepo) Get(ctx context.Context, id int64) (Refund, error) {
+	return scanRefund(r.db.QueryRowContext(ctx, `SELECT `+refundColumns+` FROM order_refunds WHERE id = ?`, id))
+}
+
+// ListByOrder returns refunds of the order, newest first.
+func (r *RefundsRepo) ListByOrder(ctx context.Context, orderID int64) ([]Refund, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+refundColumns+` FROM order_refunds WHERE order_id = ? ORDER BY id DESC`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []Refund
+	for rows.Next() {
+		rf, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, rf)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,320 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Vehicle approval states.
+const (
+	VehiclePending  = "pending"
+	VehicleApproved = "approved"
+	VehicleRejected = "rejected"
+)
+
+var (
+	// ErrVehicleNotApproved is returned when a driver tries to activate an unapproved vehicle.
+	ErrVehicleNotApproved = errors.New("vehicle is not approved")
+	// ErrVehicleActive is returned when deleting the vehicle the driver currently uses.
+	ErrVehicleActive = errors.New("vehicle is active")
+	// ErrVehicleExists is returned when the driver already registered the same plate.
+	ErrVehicleExists = errors.New("vehicle already exists")
+)
+
+// Vehicle — машина водителя со своими документами, классом и модерацией.
+type Vehicle struct {
+	ID             int64
+	DriverID       int64
+	CarModel       sql.NullString
+	CarColor       sql.NullString
+	CarNumber      string
+	TechPassport   string
+	CarPhotoFront  string
+	CarPhotoBack   string
+	CarPhotoLeft   string
+	CarPhotoRight  string
+	TariffClass    string
+	ApprovalStatus string
+	IsActive       bool
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+const vehicleColumns = `id, driver_id, car_model, car_color, car_number, tech_passport,
+        car_photo_front, car_photo_back, car_photo_left, car_photo_right,
+        tariff_class, approval_status, is_active, created_at, updated_at`
+
+func scanVehicle(row interface{ Scan(...interface{}) error }) (Vehicle, error) {
+	var v Vehicle
+	err := row.Scan(&v.ID, &v.DriverID, &v.CarModel, &v.CarColor, &v.CarNumber, &v.TechPassport,
+		&v.CarPhotoFront, &v.CarPhotoBack, &v.CarPhotoLeft, &v.CarPhotoRight,
+		&v.TariffClass, &v.ApprovalStatus, &v.IsActive, &v.CreatedAt, &v.UpdatedAt)
+	return v, err
+}
+
+// CreateVehicle registers a vehicle for moderation and returns its id.
+func (r *DriversRepo) CreateVehicle(ctx context.Context, v Vehicle) (id int64, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if id, err = createVehicleTx(ctx, tx, v); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// CreateWithVehicle registers the driver together with the vehicle from the application form,
+// so a driver never ends up without a vehicle to moderate.
+func (r *DriversRepo) CreateWithVehicle(ctx context.Context, d Driver, v Vehicle) (id int64, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if id, err = createDriverTx(ctx, tx, d); err != nil {
+		return 0, err
+	}
+	v.DriverID = id
+	if _, err = createVehicleTx(ctx, tx, v); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func createVehicleTx(ctx context.Context, tx *sql.Tx, v Vehicle) (int64, error) {
+	if v.TariffClass == "" {
+		v.TariffClass = "standard"
+	}
+	var dup int
+	err := tx.QueryRowContext(ctx, `SELECT 1 FROM driver_vehicles WHERE driver_id = ? AND car_number = ? LIMIT 1`, v.DriverID, v.CarNumber).Scan(&dup)
+	if err == nil {
+		return 0, ErrVehicleExists
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO driver_vehicles (
+        driver_id, car_model, car_color, car_number, tech_passport,
+        car_photo_front, car_photo_back, car_photo_left, car_photo_right, tariff_class, approval_status
+    ) VALUES (?,?,?,?,?,?,?,?,?,?, 'pending')`,
+		v.DriverID, v.CarModel, v.CarColor, v.CarNumber, v.TechPassport,
+		v.CarPhotoFront, v.CarPhotoBack, v.CarPhotoLeft, v.CarPhotoRight, v.TariffClass,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetVehicle returns the driver's vehicle; sql.ErrNoRows when it belongs to someone else.
+func (r *DriversRepo) GetVehicle(ctx context.Context, driverID, vehicleID int64) (Vehicle, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+vehicleColumns+` FROM driver_vehicles WHERE id = ? AND driver_id = ?`, vehicleID, driverID)
+	return scanVehicle(row)
+}
+
+// ListVehicles returns every vehicle of the driver, active first.
+func (r *DriversRepo) ListVehicles(ctx context.Context, driverID int64) ([]Vehicle, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+vehicleColumns+` FROM driver_vehicles
+    WHERE driver_id = ?
+    ORDER BY is_active DESC, id`, driverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vehicles := make([]Vehicle, 0)
+	for rows.Next() {
+		v, err := scanVehicle(rows)
+		if err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return vehicles, nil
+}
+
+// ActiveVehicle returns the vehicle the driver currently works on or sql.ErrNoRows.
+func (r *DriversRepo) ActiveVehicle(ctx context.Context, driverID int64) (Vehicle, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+vehicleColumns+` FROM driver_vehicles
+    WHERE driver_id = ? AND is_active = 1 AND approval_status = 'approved'
+    LIMIT 1`, driverID)
+	return scanVehicle(row)
+}
+
+// ActiveVehicles loads the working vehicles of the given drivers in one query, keyed by driver ID.
+// Drivers without an approved active vehicle are absent from the map.
+func (r *DriversRepo) ActiveVehicles(ctx context.Context, driverIDs []int64) (map[int64]Vehicle, error) {
+	result := make(map[int64]Vehicle, len(driverIDs))
+	if len(driverIDs) == 0 {
+		return result, nil
+	}
+	args := make([]interface{}, len(driverIDs))
+	for i, id := range driverIDs {
+		args[i] = id
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT `+vehicleColumns+` FROM driver_vehicles
+    WHERE is_active = 1 AND approval_status = 'approved' AND driver_id IN (`+placeholders(len(driverIDs))+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		v, err := scanVehicle(rows)
+		if err != nil {
+			return nil, err
+		}
+		result[v.DriverID] = v
+	}
+	return result, rows.Err()
+}
+
+// DeleteVehicle removes a vehicle that is not in use.
+func (r *DriversRepo) DeleteVehicle(ctx context.Context, driverID, vehicleID int64) error {
+	v, err := r.GetVehicle(ctx, driverID, vehicleID)
+	if err != nil {
+		return err
+	}
+	if v.IsActive {
+		return ErrVehicleActive
+	}
+	_, err = r.db.ExecContext(ctx, `DELETE FROM driver_vehicles WHERE id = ? AND driver_id = ? AND is_active = 0`, vehicleID, driverID)
+	return err
+}
+
+// ActivateVehicle switches the driver to an approved vehicle. Car fields of drivers
+// mirror the active vehicle so offers and passenger views keep reading them as is.
+func (r *DriversRepo) ActivateVehicle(ctx context.Context, driverID, vehicleID int64) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	v, err := scanVehicle(tx.QueryRowContext(ctx, `SELECT `+vehicleColumns+` FROM driver_vehicles WHERE id = ? AND driver_id = ? FOR UPDATE`, vehicleID, driverID))
+	if err != nil {
+		return err
+	}
+	if v.ApprovalStatus != VehicleApproved {
+		return ErrVehicleNotApproved
+	}
+	if err = activateVehicleTx(ctx, tx, v); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func activateVehicleTx(ctx context.Context, tx *sql.Tx, v Vehicle) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE driver_vehicles SET is_active = (id = ?) WHERE driver_id = ?`, v.ID, v.DriverID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE drivers SET
+        car_model = ?, car_color = ?, car_number = ?, tech_passport = ?,
+        car_photo_front = ?, car_photo_back = ?, car_photo_left = ?, car_photo_right = ?,
+        active_vehicle_id = ?
+    WHERE id = ?`,
+		v.CarModel, v.CarColor, v.CarNumber, v.TechPassport,
+		v.CarPhotoFront, v.CarPhotoBack, v.CarPhotoLeft, v.CarPhotoRight,
+		v.ID, v.DriverID,
+	)
+	return err
+}
+
+// SetVehicleApproval moderates a vehicle. Rejecting the active vehicle takes the driver
+// off it; approving the first vehicle of a driver without one activates it right away.
+func (r *DriversRepo) SetVehicleApproval(ctx context.Context, driverID, vehicleID int64, status, tariffClass string) (v Vehicle, err error) {
+	switch status {
+	case VehicleApproved, VehicleRejected:
+	default:
+		return Vehicle{}, fmt.Errorf("invalid approval status: %s", status)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Vehicle{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	v, err = scanVehicle(tx.QueryRowContext(ctx, `SELECT `+vehicleColumns+` FROM driver_vehicles WHERE id = ? AND driver_id = ? FOR UPDATE`, vehicleID, driverID))
+	if err != nil {
+		return Vehicle{}, err
+	}
+	if tariffClass != "" {
+		v.TariffClass = tariffClass
+	}
+	v.ApprovalStatus = status
+	if _, err = tx.ExecContext(ctx, `UPDATE driver_vehicles SET approval_status = ?, tariff_class = ? WHERE id = ?`, status, v.TariffClass, v.ID); err != nil {
+		return Vehicle{}, err
+	}
+
+	switch status {
+	case VehicleRejected:
+		if v.IsActive {
+			v.IsActive = false
+			if _, err = tx.ExecContext(ctx, `UPDATE driver_vehicles SET is_active = 0 WHERE id = ?`, v.ID); err != nil {
+				return Vehicle{}, err
+			}
+			if _, err = tx.ExecContext(ctx, `UPDATE drivers SET active_vehicle_id = NULL WHERE id = ?`, driverID); err != nil {
+				return Vehicle{}, err
+			}
+		}
+	case VehicleApproved:
+		var active sql.NullInt64
+		if err = tx.QueryRowContext(ctx, `SELECT active_vehicle_id FROM drivers WHERE id = ? FOR UPDATE`, driverID).Scan(&active); err != nil {
+			return Vehicle{}, err
+		}
+		if !active.Valid {
+			if err = activateVehicleTx(ctx, tx, v); err != nil {
+				return Vehicle{}, err
+			}
+			v.IsActive = true
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Vehicle{}, err
+	}
+	return v, nil
+}
+
+// PendingVehicleIDs returns vehicles of the driver still waiting for moderation.
+func (r *DriversRepo) PendingVehicleIDs(ctx context.Context, driverID int64) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id FROM driver_vehicles WHERE driver_id = ? AND approval_status = 'pending' ORDER BY id`, driverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,65 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"naimuBack/internal/chat"
+)
+
+// SetChatHandler attaches order chat processing for messages sent by drivers.
+func (h *DriverHub) SetChatHandler(handler chat.Handler) {
+	h.mu.Lock()
+	h.chatHandler = handler
+	h.mu.Unlock()
+}
+
+// SendChatEvent pushes an order chat event to the driver.
+func (h *DriverHub) SendChatEvent(driverID int64, evt chat.Event) {
+	h.safeWrite(driverID, func(c *websocket.Conn) error {
+		return c.WriteJSON(evt)
+	})
+}
+
+func (h *DriverHub) handleChatMessage(driverID int64, in chat.Incoming) {
+	h.mu.RLock()
+	handler := h.chatHandler
+	h.mu.RUnlock()
+	if err := dispatchChat(handler, chat.RoleDriver, driverID, in); err != nil {
+		h.SendChatEvent(driverID, chat.Event{Type: chat.TypeError, OrderID: in.OrderID, Error: chat.ErrorText(err)})
+	}
+}
+
+// SetChatHandler attaches order chat processing for messages sent by passengers.
+func (h *PassengerHub) SetChatHandler(handler chat.Handler) {
+	h.mu.Lock()
+	h.chatHandler = handler
+	h.mu.Unlock()
+}
+
+// SendChatEvent pushes an order chat event to the passenger.
+func (h *PassengerHub) SendChatEvent(passengerID int64, evt chat.Event) {
+	h.safeWrite(passengerID, func(c *websocket.Conn) error {
+		return c.WriteJSON(evt)
+	})
+}
+
+func (h *PassengerHub) handleChatMessage(passengerID int64, in chat.Incoming) {
+	h.mu.RLock()
+	handler := h.chatHandler
+	h.mu.RUnlock()
+	if err := dispatchChat(handler, chat.RolePassenger, passengerID, in); err != nil {
+		h.SendChatEvent(passengerID, chat.Event{Type: chat.TypeError, OrderID: in.OrderID, Error: chat.ErrorText(err)})
+	}
+}
+
+func dispatchChat(handler chat.Handler, role string, senderID int64, in chat.Incoming) error {
+	if handler == nil {
+		return chat.ErrChatClosed
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return handler.ChatMessage(ctx, role, senderID, in)
+}
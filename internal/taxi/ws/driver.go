@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/gorilla/websocket"
 	"math" // 👈 добавь для проверки "near-zero"
+	"naimuBack/internal/chat"
 	"naimuBack/internal/taxi/geo"
 	"net/http"
 	"strconv"
@@ -25,6 +26,8 @@ type DriverRoutePoint struct {
 	Lon     float64 `json:"lon"`
 	Lat     float64 `json:"lat"`
 	Address string  `json:"address,omitempty"`
+	// LegPolyline — геометрия участка до следующей точки (encoded polyline).
+	LegPolyline string `json:"leg_polyline,omitempty"`
 }
 
 // DriverOfferPayload represents an offer sent to driver over WS.
@@ -41,6 +44,21 @@ type DriverOfferPayload struct {
 	ExpiresInSec int                `json:"expires_in"`
 	Route        []DriverRoutePoint `json:"route,omitempty"`
 	Passenger    *DriverPassenger   `json:"passenger,omitempty"`
+	// Chained — заказ предложен до завершения текущей поездки водителя (AfterOrderID).
+	Chained           bool  `json:"chained,omitempty"`
+	AfterOrderID      int64 `json:"after_order_id,omitempty"`
+	CurrentTripEtaSec int   `json:"current_trip_eta_s,omitempty"`
+	// Vehicle — активная машина водителя, на которую приходит предложение.
+	Vehicle *DriverOfferVehicle `json:"vehicle,omitempty"`
+}
+
+// DriverOfferVehicle describes the active vehicle the offer is made for.
+type DriverOfferVehicle struct {
+	ID          int64  `json:"id"`
+	CarModel    string `json:"car_model,omitempty"`
+	CarColor    string `json:"car_color,omitempty"`
+	CarNumber   string `json:"car_number"`
+	TariffClass string `json:"tariff_class"`
 }
 
 // DriverOfferClosedPayload notifies driver that offer is no longer available.
@@ -91,6 +109,19 @@ type DriverHub struct {
 	wmu        map[int64]*sync.Mutex
 	cities     map[int64]string
 	lastStatus map[int64]string // 👈 добавь это поле
+
+	destHandler        DestinationHandler
+	destArrivalRadiusM float64
+	destinations       map[int64]DriverDestination
+
+	presence    PresenceHandler
+	chatHandler chat.Handler
+	location    LocationHandler
+
+	fatigue FatigueGuard
+	resting map[int64]time.Time
+
+	positions map[int64]DriverPosition
 }
 
 // NewDriverHub creates driver hub.
@@ -103,6 +134,10 @@ func NewDriverHub(locator *geo.DriverLocator, logger Logger) *DriverHub {
 		wmu:        make(map[int64]*sync.Mutex),
 		cities:     make(map[int64]string),
 		lastStatus: make(map[int64]string), // 👈
+
+		destinations: make(map[int64]DriverDestination),
+		resting:      make(map[int64]time.Time),
+		positions:    make(map[int64]DriverPosition),
 	}
 }
 
@@ -140,6 +175,10 @@ func (h *DriverHub) ServeWS(w http.ResponseWriter, r *http.Request) {
 	h.mu.Unlock()
 
 	h.logger.Infof("driver %d connected (city=%s)", driverID, city)
+	// водитель на обязательном отдыхе остаётся на связи, но не выходит на линию
+	if !h.restOnConnect(driverID) {
+		h.notifyPresence(driverID, city, true)
+	}
 
 	go func(id int64, born *websocket.Conn) {
 		ticker := time.NewTicker(pingPeriod)
@@ -160,6 +199,7 @@ func (h *DriverHub) ServeWS(w http.ResponseWriter, r *http.Request) {
 		}
 	}(driverID, conn)
 
+	go h.loadDestination(driverID)
 	go h.readLoop(driverID, conn, city)
 }
 
@@ -189,9 +229,12 @@ func (h *DriverHub) readLoop(driverID int64, conn *websocket.Conn, city string)
 	}
 
 	type payloadRaw struct {
-		Lon    interface{} `json:"lon"`
-		Lat    interface{} `json:"lat"`
-		Status string      `json:"status"`
+		Type       string      `json:"type"`
+		Lon        interface{} `json:"lon"`
+		Lat        interface{} `json:"lat"`
+		Status     string      `json:"status"`
+		Address    string      `json:"address"`
+		MaxDetourM int         `json:"max_detour_m"`
 	}
 
 	for {
@@ -222,6 +265,27 @@ func (h *DriverHub) readLoop(driverID int64, conn *websocket.Conn, city string)
 			continue
 		}
 
+		if in, ok := chat.ParseIncoming([]byte(trimmed)); ok {
+			h.handleChatMessage(driverID, in)
+			continue
+		}
+
+		// режим "еду домой": destination_set {lon,lat,address,max_detour_m} / destination_clear
+		if msgType := strings.ToLower(strings.TrimSpace(raw.Type)); strings.HasPrefix(msgType, "destination_") {
+			var dest DriverDestination
+			if msgType == "destination_set" {
+				dLon, errLon := parseCoordinate(raw.Lon)
+				dLat, errLat := parseCoordinate(raw.Lat)
+				if errLon != nil || errLat != nil {
+					h.sendDestination(driverID, DriverDestinationPayload{Status: "error", Message: "invalid destination coordinates"})
+					continue
+				}
+				dest = DriverDestination{Lon: dLon, Lat: dLat, Address: strings.TrimSpace(raw.Address), MaxDetourM: raw.MaxDetourM}
+			}
+			h.handleDestinationMessage(driverID, msgType, dest)
+			continue
+		}
+
 		lon, err := parseCoordinate(raw.Lon)
 		if err != nil {
 			h.logger.Errorf("driver %d invalid lon %v: %v", driverID, raw.Lon, err)
@@ -249,6 +313,11 @@ func (h *DriverHub) readLoop(driverID int64, conn *websocket.Conn, city string)
 			continue
 		}
 
+		h.rememberPosition(driverID, payload.Lon, payload.Lat)
+		if h.isResting(driverID) {
+			continue
+		}
+
 		status := strings.ToLower(strings.TrimSpace(payload.Status))
 		if status == "" {
 			status = "free"
@@ -283,6 +352,9 @@ func (h *DriverHub) readLoop(driverID int64, conn *websocket.Conn, city string)
 		}
 		cancel()
 
+		h.checkDestinationArrival(driverID, payload.Lon, payload.Lat)
+		h.notifyLocation(driverID, payload.Lon, payload.Lat)
+
 		// при желании включай отладочный дамп (но не на каждом сообщении в проде)
 		// h.locator.DebugDumpFree(context.Background(), city)
 	}
@@ -291,16 +363,38 @@ func (h *DriverHub) readLoop(driverID int64, conn *websocket.Conn, city string)
 func (h *DriverHub) closeConn(id int64, c *websocket.Conn) {
 	_ = c.Close()
 	h.mu.Lock()
+	// при переподключении старое соединение закрывается позже — сессию онлайна не трогаем
+	current, known := h.conns[id]
+	lastConn := !known || current == c
 	delete(h.conns, id)
 	delete(h.wmu, id)
 	delete(h.cities, id)
 	delete(h.lastStatus, id)
+	delete(h.destinations, id)
+	delete(h.positions, id)
+	if lastConn {
+		delete(h.resting, id)
+	}
 	h.mu.Unlock()
+	if lastConn {
+		h.notifyPresence(id, "", false)
+	}
 	if h.logger != nil {
 		h.logger.Infof("🔌 closed ws driver=%d", id)
 	}
 }
 
+// City returns the city the driver connected from; "default" means the app did not send one.
+func (h *DriverHub) City(driverID int64) (string, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	city, ok := h.cities[driverID]
+	if city == "default" {
+		return "", false
+	}
+	return city, ok
+}
+
 // SendOffer sends an order offer to a driver.
 func (h *DriverHub) SendOffer(driverID int64, payload DriverOfferPayload) {
 	payload.Type = "order_offer"
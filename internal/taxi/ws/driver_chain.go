@@ -0,0 +1,20 @@
+package ws
+
+import "github.com/gorilla/websocket"
+
+// DriverChainPayload informs driver about the queued next order.
+type DriverChainPayload struct {
+	Type         string `json:"type"`
+	OrderID      int64  `json:"order_id"`
+	AfterOrderID int64  `json:"after_order_id"`
+	Status       string `json:"status"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// NotifyChain sends chained order status (confirmed/released) to the driver.
+func (h *DriverHub) NotifyChain(driverID int64, payload DriverChainPayload) {
+	payload.Type = "order_chain"
+	h.safeWrite(driverID, func(c *websocket.Conn) error {
+		return c.WriteJSON(payload)
+	})
+}
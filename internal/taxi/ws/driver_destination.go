@@ -0,0 +1,136 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"naimuBack/internal/taxi/geo"
+)
+
+// DriverDestination is an active "going home" destination of a driver.
+type DriverDestination struct {
+	Lon        float64 `json:"lon"`
+	Lat        float64 `json:"lat"`
+	Address    string  `json:"address,omitempty"`
+	MaxDetourM int     `json:"max_detour_m"`
+}
+
+// DriverDestinationPayload notifies driver about destination mode changes.
+type DriverDestinationPayload struct {
+	Type        string             `json:"type"`
+	Status      string             `json:"status"`
+	Destination *DriverDestination `json:"destination,omitempty"`
+	Message     string             `json:"message,omitempty"`
+}
+
+// DestinationHandler persists destination mode changes requested over WebSocket.
+type DestinationHandler interface {
+	ActiveDestination(ctx context.Context, driverID int64) (*DriverDestination, error)
+	SetDestination(ctx context.Context, driverID int64, dest DriverDestination) (*DriverDestination, error)
+	ClearDestination(ctx context.Context, driverID int64) error
+	DestinationArrived(ctx context.Context, driverID int64) error
+}
+
+// SetDestinationHandler attaches destination storage and the arrival radius used for auto-disable.
+func (h *DriverHub) SetDestinationHandler(handler DestinationHandler, arrivalRadiusM int) {
+	h.mu.Lock()
+	h.destHandler = handler
+	h.destArrivalRadiusM = float64(arrivalRadiusM)
+	h.mu.Unlock()
+}
+
+// UpdateDestination syncs cached destination of a connected driver and notifies the driver.
+// A nil destination means the mode was switched off.
+func (h *DriverHub) UpdateDestination(driverID int64, dest *DriverDestination, status string) {
+	h.mu.Lock()
+	if dest != nil {
+		h.destinations[driverID] = *dest
+	} else {
+		delete(h.destinations, driverID)
+	}
+	h.mu.Unlock()
+	h.sendDestination(driverID, DriverDestinationPayload{Status: status, Destination: dest})
+}
+
+func (h *DriverHub) sendDestination(driverID int64, payload DriverDestinationPayload) {
+	payload.Type = "destination"
+	h.safeWrite(driverID, func(c *websocket.Conn) error {
+		return c.WriteJSON(payload)
+	})
+}
+
+func (h *DriverHub) loadDestination(driverID int64) {
+	h.mu.RLock()
+	handler := h.destHandler
+	h.mu.RUnlock()
+	if handler == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	dest, err := handler.ActiveDestination(ctx, driverID)
+	if err != nil {
+		h.logger.Errorf("driver %d load destination failed: %v", driverID, err)
+		return
+	}
+	if dest == nil {
+		return
+	}
+	h.UpdateDestination(driverID, dest, "active")
+}
+
+// handleDestinationMessage processes destination_set / destination_clear messages from the driver app.
+func (h *DriverHub) handleDestinationMessage(driverID int64, msgType string, dest DriverDestination) {
+	h.mu.RLock()
+	handler := h.destHandler
+	h.mu.RUnlock()
+	if handler == nil {
+		h.sendDestination(driverID, DriverDestinationPayload{Status: "error", Message: "destination mode unavailable"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	switch msgType {
+	case "destination_set":
+		saved, err := handler.SetDestination(ctx, driverID, dest)
+		if err != nil {
+			h.sendDestination(driverID, DriverDestinationPayload{Status: "error", Message: err.Error()})
+			return
+		}
+		h.UpdateDestination(driverID, saved, "active")
+	case "destination_clear":
+		if err := handler.ClearDestination(ctx, driverID); err != nil {
+			h.sendDestination(driverID, DriverDestinationPayload{Status: "error", Message: err.Error()})
+			return
+		}
+		h.UpdateDestination(driverID, nil, "canceled")
+	}
+}
+
+// checkDestinationArrival switches destination mode off once the driver reaches the destination.
+func (h *DriverHub) checkDestinationArrival(driverID int64, lon, lat float64) {
+	h.mu.RLock()
+	dest, ok := h.destinations[driverID]
+	handler := h.destHandler
+	radius := h.destArrivalRadiusM
+	h.mu.RUnlock()
+	if !ok || handler == nil || radius <= 0 {
+		return
+	}
+	if geo.DistanceMeters(lon, lat, dest.Lon, dest.Lat) > radius {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := handler.DestinationArrived(ctx, driverID); err != nil {
+		h.logger.Errorf("driver %d destination arrival failed: %v", driverID, err)
+		return
+	}
+	h.logger.Infof("driver %d arrived at destination → mode off", driverID)
+	h.UpdateDestination(driverID, nil, "arrived")
+}
@@ -0,0 +1,23 @@
+package ws
+
+// LocationHandler receives driver positions to keep the live ETA of the current order up to date.
+// Implementations must not block: it is called from the driver read loop.
+type LocationHandler interface {
+	DriverLocation(driverID int64, lon, lat float64)
+}
+
+// SetLocationHandler attaches live ETA tracking.
+func (h *DriverHub) SetLocationHandler(handler LocationHandler) {
+	h.mu.Lock()
+	h.location = handler
+	h.mu.Unlock()
+}
+
+func (h *DriverHub) notifyLocation(driverID int64, lon, lat float64) {
+	h.mu.RLock()
+	handler := h.location
+	h.mu.RUnlock()
+	if handler != nil {
+		handler.DriverLocation(driverID, lon, lat)
+	}
+}
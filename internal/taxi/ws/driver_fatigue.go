@@ -0,0 +1,127 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Fatigue events sent to drivers.
+const (
+	FatigueWarning  = "fatigue_warning"
+	FatigueOffline  = "fatigue_offline"
+	FatigueRestOver = "fatigue_rest_over"
+)
+
+// DriverFatiguePayload tells the driver about the online time limit and the mandatory break.
+type DriverFatiguePayload struct {
+	Type             string     `json:"type"`
+	OnlineSeconds    int64      `json:"online_s"`
+	RemainingSeconds int64      `json:"remaining_s"`
+	RestUntil        *time.Time `json:"rest_until,omitempty"`
+	// Deferred — лимит достигнут во время поездки: новые заказы не приходят, отдых начнётся после завершения.
+	Deferred bool `json:"deferred,omitempty"`
+}
+
+// FatigueGuard tells the hub whether a connecting driver is still on a mandatory break.
+type FatigueGuard interface {
+	RestUntil(ctx context.Context, driverID int64) (time.Time, bool)
+}
+
+// SetFatigueGuard attaches the online time limit check.
+func (h *DriverHub) SetFatigueGuard(guard FatigueGuard) {
+	h.mu.Lock()
+	h.fatigue = guard
+	h.mu.Unlock()
+}
+
+// restOnConnect keeps a driver on a mandatory break offline after reconnecting.
+func (h *DriverHub) restOnConnect(driverID int64) bool {
+	h.mu.RLock()
+	guard := h.fatigue
+	h.mu.RUnlock()
+	if guard == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	until, resting := guard.RestUntil(ctx, driverID)
+	cancel()
+	if !resting {
+		return false
+	}
+	h.mu.Lock()
+	h.resting[driverID] = until
+	h.mu.Unlock()
+	h.sendFatigue(driverID, DriverFatiguePayload{Type: FatigueOffline, RestUntil: &until})
+	return true
+}
+
+// ForceOffline takes the driver off the line until the rest is over: the driver is removed from
+// the geo index, the online session is closed and location updates are ignored. The websocket stays
+// open so the driver sees the countdown and the chat keeps working.
+func (h *DriverHub) ForceOffline(driverID int64, payload DriverFatiguePayload) {
+	h.mu.Lock()
+	city, connected := h.cities[driverID]
+	if connected && payload.RestUntil != nil {
+		h.resting[driverID] = *payload.RestUntil
+	}
+	h.mu.Unlock()
+	if !connected {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	if err := h.locator.GoOffline(ctx, driverID, city); err != nil {
+		h.logger.Errorf("driver %d fatigue GoOffline failed: %v", driverID, err)
+	}
+	cancel()
+	h.notifyPresence(driverID, "", false)
+
+	payload.Type = FatigueOffline
+	h.sendFatigue(driverID, payload)
+}
+
+// EndRest returns a connected driver to the line after the mandatory break.
+func (h *DriverHub) EndRest(driverID int64) {
+	h.mu.Lock()
+	_, resting := h.resting[driverID]
+	delete(h.resting, driverID)
+	city := h.cities[driverID]
+	h.mu.Unlock()
+	if !resting {
+		return
+	}
+	h.notifyPresence(driverID, city, true)
+	h.sendFatigue(driverID, DriverFatiguePayload{Type: FatigueRestOver})
+}
+
+// RestingDriverIDs returns connected drivers on a mandatory break with its end.
+func (h *DriverHub) RestingDriverIDs() map[int64]time.Time {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	res := make(map[int64]time.Time, len(h.resting))
+	for id, until := range h.resting {
+		res[id] = until
+	}
+	return res
+}
+
+// SendFatigueWarning warns the driver that the online time limit is approaching.
+func (h *DriverHub) SendFatigueWarning(driverID int64, payload DriverFatiguePayload) {
+	payload.Type = FatigueWarning
+	h.sendFatigue(driverID, payload)
+}
+
+func (h *DriverHub) isResting(driverID int64) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.resting[driverID]
+	return ok
+}
+
+func (h *DriverHub) sendFatigue(driverID int64, payload DriverFatiguePayload) {
+	h.safeWrite(driverID, func(c *websocket.Conn) error {
+		return c.WriteJSON(payload)
+	})
+}
@@ -0,0 +1,44 @@
+package ws
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// HeatmapCell describes demand in a single geohash cell.
+type HeatmapCell struct {
+	Geohash   string  `json:"geohash"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	Pickups   int     `json:"pickups"`
+	NotFound  int     `json:"not_found"`
+	Searching int     `json:"searching"`
+	Intensity float64 `json:"intensity"`
+}
+
+// DriverHeatmapPayload is periodically pushed to drivers to show where demand is.
+type DriverHeatmapPayload struct {
+	Type        string        `json:"type"`
+	GeneratedAt string        `json:"generated_at"`
+	WindowSec   int           `json:"window_s"`
+	Precision   int           `json:"precision"`
+	Cells       []HeatmapCell `json:"cells"`
+}
+
+// SendHeatmap pushes the demand heatmap of one city to its drivers; the payload is encoded once.
+func (h *DriverHub) SendHeatmap(driverIDs []int64, payload DriverHeatmapPayload) {
+	payload.Type = "heatmap"
+	data, err := json.Marshal(payload)
+	if err != nil {
+		h.logger.Errorf("driver heatmap marshal failed: %v", err)
+		return
+	}
+	for _, id := range driverIDs {
+		h.safeWrite(id, func(c *websocket.Conn) error {
+			c.SetWriteDeadline(time.Now().Add(writeWait))
+			return c.WriteMessage(websocket.TextMessage, data)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DriverPosition is the last coordinate received from the driver socket.
+type DriverPosition struct {
+	Lon       float64
+	Lat       float64
+	UpdatedAt time.Time
+}
+
+// OnlineDriver describes a connected driver for the live operations map.
+type OnlineDriver struct {
+	ID      int64
+	City    string
+	Status  string
+	Resting bool
+	// HasPosition is false until the driver sends the first coordinates.
+	HasPosition bool
+	Position    DriverPosition
+}
+
+func (h *DriverHub) rememberPosition(driverID int64, lon, lat float64) {
+	h.mu.Lock()
+	h.positions[driverID] = DriverPosition{Lon: lon, Lat: lat, UpdatedAt: time.Now()}
+	h.mu.Unlock()
+}
+
+// OnlineDrivers returns every connected driver with the last known position.
+func (h *DriverHub) OnlineDrivers() []OnlineDriver {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	drivers := make([]OnlineDriver, 0, len(h.conns))
+	for id := range h.conns {
+		status := h.lastStatus[id]
+		if status == "" {
+			status = "free"
+		}
+		_, resting := h.resting[id]
+		pos, ok := h.positions[id]
+		drivers = append(drivers, OnlineDriver{
+			ID:          id,
+			City:        h.cities[id],
+			Status:      status,
+			Resting:     resting,
+			HasPosition: ok,
+			Position:    pos,
+		})
+	}
+	return drivers
+}
+
+// NotifyAssignedByDispatcher tells the driver that an operator assigned the order to them.
+func (h *DriverHub) NotifyAssignedByDispatcher(driverID, orderID int64) {
+	payload := map[string]interface{}{
+		"type":     "order_assigned",
+		"order_id": orderID,
+		"status":   "accepted",
+		"by":       "dispatcher",
+	}
+	h.safeWrite(driverID, func(c *websocket.Conn) error {
+		return c.WriteJSON(payload)
+	})
+}
+
+// Position returns the last known coordinate of a connected driver.
+func (h *DriverHub) Position(driverID int64) (DriverPosition, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	pos, ok := h.positions[driverID]
+	return pos, ok
+}
@@ -0,0 +1,22 @@
+package ws
+
+import "github.com/gorilla/websocket"
+
+// DriverLostItemPayload informs the driver about a lost item case on one of their trips.
+type DriverLostItemPayload struct {
+	Type          string `json:"type"`
+	CaseID        int64  `json:"case_id"`
+	OrderID       int64  `json:"order_id"`
+	Status        string `json:"status"`
+	Description   string `json:"description,omitempty"`
+	ReturnOrderID int64  `json:"return_order_id,omitempty"`
+	ReturnFee     int    `json:"return_fee,omitempty"`
+}
+
+// NotifyLostItem sends a lost item case update (new case, return trip, closing) to the driver.
+func (h *DriverHub) NotifyLostItem(driverID int64, payload DriverLostItemPayload) {
+	payload.Type = "lost_item"
+	h.safeWrite(driverID, func(c *websocket.Conn) error {
+		return c.WriteJSON(payload)
+	})
+}
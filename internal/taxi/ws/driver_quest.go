@@ -0,0 +1,98 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DriverQuestProgress describes the driver's progress in a running quest.
+type DriverQuestProgress struct {
+	QuestID  int64     `json:"quest_id"`
+	Title    string    `json:"title"`
+	Metric   string    `json:"metric"`
+	Value    int       `json:"value"`
+	Target   int       `json:"target"`
+	Offers   int       `json:"offers,omitempty"`
+	Reward   int       `json:"reward"`
+	Achieved bool      `json:"achieved"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// DriverQuestPayload pushes live quest progress to the driver.
+type DriverQuestPayload struct {
+	Type   string                `json:"type"`
+	Quests []DriverQuestProgress `json:"quests"`
+}
+
+// DriverQuestResultPayload tells the driver the final result of an ended quest.
+type DriverQuestResultPayload struct {
+	Type     string `json:"type"`
+	QuestID  int64  `json:"quest_id"`
+	Value    int    `json:"value"`
+	Target   int    `json:"target"`
+	Achieved bool   `json:"achieved"`
+	Reward   int    `json:"reward"`
+	Balance  *int64 `json:"balance,omitempty"`
+}
+
+// PresenceHandler records when drivers go online and offline (used for online-hours quests).
+type PresenceHandler interface {
+	DriverOnline(ctx context.Context, driverID int64, city string) error
+	DriverOffline(ctx context.Context, driverID int64) error
+}
+
+// SetPresenceHandler attaches online session storage.
+func (h *DriverHub) SetPresenceHandler(handler PresenceHandler) {
+	h.mu.Lock()
+	h.presence = handler
+	h.mu.Unlock()
+}
+
+func (h *DriverHub) notifyPresence(driverID int64, city string, online bool) {
+	h.mu.RLock()
+	handler := h.presence
+	h.mu.RUnlock()
+	if handler == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var err error
+	if online {
+		err = handler.DriverOnline(ctx, driverID, city)
+	} else {
+		err = handler.DriverOffline(ctx, driverID)
+	}
+	if err != nil {
+		h.logger.Errorf("driver %d presence (online=%t) failed: %v", driverID, online, err)
+	}
+}
+
+// ConnectedDriverIDs returns drivers currently connected to the hub.
+func (h *DriverHub) ConnectedDriverIDs() []int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ids := make([]int64, 0, len(h.conns))
+	for id := range h.conns {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// SendQuestProgress pushes quest progress to a connected driver.
+func (h *DriverHub) SendQuestProgress(driverID int64, quests []DriverQuestProgress) {
+	payload := DriverQuestPayload{Type: "quest_progress", Quests: quests}
+	h.safeWrite(driverID, func(c *websocket.Conn) error {
+		return c.WriteJSON(payload)
+	})
+}
+
+// SendQuestResult notifies the driver about the evaluated quest and credited reward.
+func (h *DriverHub) SendQuestResult(driverID int64, payload DriverQuestResultPayload) {
+	payload.Type = "quest_result"
+	h.safeWrite(driverID, func(c *websocket.Conn) error {
+		return c.WriteJSON(payload)
+	})
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"naimuBack/internal/chat"
 )
 
 const (
@@ -26,6 +28,19 @@ type PassengerEvent struct {
 	DriverID int64            `json:"driver_id,omitempty"`
 	Price    int              `json:"price,omitempty"`
 	Driver   *PassengerDriver `json:"driver,omitempty"`
+	// ETA — позиция водителя и оставшийся путь до подачи или до точки назначения (type "driver_eta").
+	ETA *PassengerETA `json:"eta,omitempty"`
+}
+
+// PassengerETA is the live position of the assigned driver and the time left to the target.
+type PassengerETA struct {
+	Target     string  `json:"target"`
+	DriverLon  float64 `json:"driver_lon"`
+	DriverLat  float64 `json:"driver_lat"`
+	DistanceM  int     `json:"distance_m"`
+	EtaSeconds int     `json:"eta_s"`
+	// OffRoute — водитель отклонился от построенного маршрута.
+	OffRoute bool `json:"off_route,omitempty"`
 }
 
 // PassengerDriver describes driver card sent to passengers with offer events.
@@ -42,6 +57,7 @@ type PassengerDriver struct {
 	CarPhotoBack  string  `json:"car_photo_back,omitempty"`
 	CarPhotoLeft  string  `json:"car_photo_left,omitempty"`
 	CarPhotoRight string  `json:"car_photo_right,omitempty"`
+	VehicleID     int64   `json:"vehicle_id,omitempty"`
 }
 
 // PassengerHub manages passenger WS connections.
@@ -52,6 +68,8 @@ type PassengerHub struct {
 	mu    sync.RWMutex
 	conns map[int64]*websocket.Conn
 	wmu   map[int64]*sync.Mutex
+
+	chatHandler chat.Handler
 }
 
 // NewPassengerHub constructs passenger hub.
@@ -132,6 +150,12 @@ func (h *PassengerHub) readLoop(passengerID int64, conn *websocket.Conn) {
 		if mt == websocket.TextMessage && strings.EqualFold(strings.TrimSpace(string(msg)), "ping") {
 			// optional: отвечаем для совместимости
 			_ = conn.WriteMessage(websocket.TextMessage, []byte("pong"))
+			continue
+		}
+		if mt == websocket.TextMessage {
+			if in, ok := chat.ParseIncoming(msg); ok {
+				h.handleChatMessage(passengerID, in)
+			}
 		}
 	}
 }